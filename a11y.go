@@ -0,0 +1,164 @@
+package pubengine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/eringen/pubengine/markdown"
+)
+
+// minContrastRatio is the WCAG AA contrast ratio for normal text.
+const minContrastRatio = 4.5
+
+// minOpacity is the lowest inline opacity not flagged as hard to see.
+const minOpacity = 0.5
+
+// accessibilityWarnings flags common accessibility problems in post Markdown:
+// images without alt text, heading levels that skip a level, and inline
+// styles with low contrast or opacity. Post pages render the title as <h1>,
+// so headings in the content are expected to start at level 2 at most.
+func accessibilityWarnings(content string) []string {
+	var warnings []string
+
+	refs, _ := markdown.Refs(content)
+	for _, r := range refs {
+		if !r.Image {
+			continue
+		}
+		if strings.TrimSpace(r.Text) == "" {
+			warnings = append(warnings, fmt.Sprintf("line %d: image %q has no alt text", r.Line, r.URL))
+		}
+		if msg := styleContrastProblem(r.Attrs); msg != "" {
+			warnings = append(warnings, fmt.Sprintf("line %d: image %q %s", r.Line, r.URL, msg))
+		}
+	}
+
+	prev := 1
+	for _, h := range markdown.Headings(content) {
+		if h.Level > prev+1 {
+			warnings = append(warnings, fmt.Sprintf("line %d: heading %q jumps from h%d to h%d", h.Line, h.Text, prev, h.Level))
+		}
+		prev = h.Level
+	}
+	return warnings
+}
+
+// styleContrastProblem checks the style part of an image's {style|...}
+// suffix and describes any low-contrast color pair or low opacity.
+func styleContrastProblem(attrs string) string {
+	style, _, _ := strings.Cut(attrs, "|")
+	decls := make(map[string]string)
+	for _, d := range strings.Split(style, ";") {
+		prop, val, ok := strings.Cut(d, ":")
+		if !ok {
+			continue
+		}
+		decls[strings.ToLower(strings.TrimSpace(prop))] = strings.ToLower(strings.TrimSpace(val))
+	}
+
+	if v, ok := decls["opacity"]; ok {
+		if o, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64); err == nil {
+			if strings.HasSuffix(v, "%") {
+				o /= 100
+			}
+			if o < minOpacity {
+				return fmt.Sprintf("uses low opacity %s", v)
+			}
+		}
+	}
+
+	bgVal := decls["background-color"]
+	if bgVal == "" {
+		bgVal = decls["background"]
+	}
+	fg, okFg := parseCSSColor(decls["color"])
+	bg, okBg := parseCSSColor(bgVal)
+	if okFg && okBg {
+		if ratio := contrastRatio(fg, bg); ratio < minContrastRatio {
+			return fmt.Sprintf("has low color contrast %.1f:1 (minimum %.1f:1)", ratio, minContrastRatio)
+		}
+	}
+	return ""
+}
+
+// namedColors covers the CSS color keywords most often used inline.
+var namedColors = map[string][3]float64{
+	"black":     {0, 0, 0},
+	"white":     {255, 255, 255},
+	"gray":      {128, 128, 128},
+	"grey":      {128, 128, 128},
+	"silver":    {192, 192, 192},
+	"lightgray": {211, 211, 211},
+	"lightgrey": {211, 211, 211},
+	"darkgray":  {169, 169, 169},
+	"darkgrey":  {169, 169, 169},
+	"red":       {255, 0, 0},
+	"green":     {0, 128, 0},
+	"blue":      {0, 0, 255},
+	"yellow":    {255, 255, 0},
+	"orange":    {255, 165, 0},
+}
+
+// parseCSSColor parses #rgb, #rrggbb, rgb()/rgba() and a few named colors.
+func parseCSSColor(s string) ([3]float64, bool) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "!important"))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return [3]float64{}, false
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return [3]float64{}, false
+		}
+		return [3]float64{float64(n >> 16 & 0xff), float64(n >> 8 & 0xff), float64(n & 0xff)}, true
+	}
+	if strings.HasPrefix(s, "rgb(") || strings.HasPrefix(s, "rgba(") {
+		inner := s[strings.Index(s, "(")+1:]
+		inner = strings.TrimSuffix(inner, ")")
+		parts := strings.FieldsFunc(inner, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) < 3 {
+			return [3]float64{}, false
+		}
+		var c [3]float64
+		for i := 0; i < 3; i++ {
+			v, err := strconv.ParseFloat(parts[i], 64)
+			if err != nil {
+				return [3]float64{}, false
+			}
+			c[i] = v
+		}
+		return c, true
+	}
+	return [3]float64{}, false
+}
+
+// contrastRatio returns the WCAG contrast ratio between two sRGB colors.
+func contrastRatio(a, b [3]float64) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+func relativeLuminance(c [3]float64) float64 {
+	var lin [3]float64
+	for i, v := range c {
+		v /= 255
+		if v <= 0.03928 {
+			lin[i] = v / 12.92
+		} else {
+			lin[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*lin[0] + 0.7152*lin[1] + 0.0722*lin[2]
+}
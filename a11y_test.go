@@ -0,0 +1,46 @@
+package pubengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessibilityWarnings(t *testing.T) {
+	content := "# Part\n\n### Too deep\n\n![](/a.png){}\n\n![ok](/b.png){color:#777;background:#888}\n\n![fine](/c.png){color:#000;background-color:white}\n\n```\n### in code\n```"
+	warnings := accessibilityWarnings(content)
+	if len(warnings) != 3 {
+		t.Fatalf("expected 3 warnings, got %d: %q", len(warnings), warnings)
+	}
+	for i, want := range []string{"no alt text", "low color contrast", "jumps from h1 to h3"} {
+		if !strings.Contains(warnings[i], want) {
+			t.Errorf("warning %d = %q, want it to mention %q", i, warnings[i], want)
+		}
+	}
+}
+
+func TestAccessibilityWarningsHeadingStart(t *testing.T) {
+	if w := accessibilityWarnings("### Starts too deep"); len(w) != 1 {
+		t.Errorf("expected a jump from the h1 title to h3, got %q", w)
+	}
+	if w := accessibilityWarnings("## A\n### B\n## C\n### D"); len(w) != 0 {
+		t.Errorf("expected no warnings, got %q", w)
+	}
+}
+
+func TestStyleContrastProblem(t *testing.T) {
+	tests := []struct {
+		style string
+		bad   bool
+	}{
+		{"color:#fff;background:#000", false},
+		{"color:rgb(200, 200, 200);background-color:#fff", true},
+		{"opacity:0.3", true},
+		{"opacity:80%", false},
+		{"max-width:100%|800|600", false},
+	}
+	for _, tt := range tests {
+		if got := styleContrastProblem(tt.style) != ""; got != tt.bad {
+			t.Errorf("styleContrastProblem(%q) flagged = %v, want %v", tt.style, got, tt.bad)
+		}
+	}
+}
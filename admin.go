@@ -3,8 +3,10 @@ package pubengine
 import (
 	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,15 +33,29 @@ func (a *App) handleAdminPost(c echo.Context) error {
 	}
 	slug := c.Param("slug")
 	if slug == "new" {
-		return Render(c, a.Views.AdminFormPartial(BlogPost{}, CsrfToken(c)))
+		post, err := a.newPostFromTemplate(c.QueryParam("template"))
+		if err != nil {
+			return err
+		}
+		if post.Syndications, err = a.postSyndications(""); err != nil {
+			return err
+		}
+		return Render(c, a.Views.AdminFormPartial(post, CsrfToken(c)))
 	}
-	post, err := a.Store.GetPostAny(slug)
+	post, err := a.Content.GetPostAny(slug)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return c.NoContent(http.StatusNotFound)
 		}
 		return err
 	}
+	post.Backlinks, err = a.postBacklinks(slug)
+	if err != nil {
+		return err
+	}
+	if post.Syndications, err = a.postSyndications(slug); err != nil {
+		return err
+	}
 	return Render(c, a.Views.AdminFormPartial(post, CsrfToken(c)))
 }
 
@@ -59,6 +75,22 @@ func (a *App) handleAdminLogin(c echo.Context) error {
 	return Render(c, a.Views.AdminLogin("Invalid password.", CsrfToken(c), a.googleLoginURL()))
 }
 
+// LoginStats returns the login limiter's lockouts and recent failures, for
+// exposing brute-force activity in custom metrics.
+func (a *App) LoginStats() LoginStats {
+	return a.loginLimiter.Stats()
+}
+
+func (a *App) handleAdminSecurity(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminSecurity == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return Render(c, a.Views.AdminSecurity(a.loginLimiter.Stats()))
+}
+
 func (a *App) googleLoginURL() string {
 	if a.Config.GoogleAuthEnabled() {
 		return "/admin/auth/google/"
@@ -103,37 +135,326 @@ func (a *App) handleAdminSave(c echo.Context) error {
 	summary := c.FormValue("summary")
 	content := c.FormValue("content")
 	published := c.FormValue("published") != ""
-	if err := a.Store.SavePost(BlogPost{
-		Slug:      slug,
-		Title:     title,
-		Date:      date,
-		Tags:      tags,
-		Summary:   summary,
-		Content:   content,
-		Published: published,
-	}); err != nil {
+	publishAt, err := parsePublishAt(strings.TrimSpace(c.FormValue("publish_at")))
+	if err != nil {
+		return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("Invalid publish time. Use YYYY-MM-DDTHH:MM."))
+	}
+	ogImage := strings.TrimSpace(c.FormValue("og_image"))
+	if ogImage != "" && !validImageRef(ogImage) {
+		return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("OG image must be a site path like /public/uploads/photo.jpg or an http(s) URL."))
+	}
+	canonicalURL := strings.TrimSpace(c.FormValue("canonical_url"))
+	if canonicalURL != "" && !validCanonicalURL(canonicalURL) {
+		return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("Canonical URL must be an absolute http(s) URL."))
+	}
+	seriesSlug := Slugify(c.FormValue("series"))
+	if seriesSlug != "" {
+		if msg := ValidateSlug(seriesSlug); msg != "" {
+			return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("Series: "+msg))
+		}
+	}
+	seriesOrder, _ := strconv.Atoi(strings.TrimSpace(c.FormValue("series_order")))
+	excludeFromFeed := c.FormValue("exclude_from_feed") != ""
+	excludeFromSitemap := c.FormValue("exclude_from_sitemap") != ""
+	noIndex := c.FormValue("no_index") != ""
+	authorSlug := Slugify(c.FormValue("author"))
+	if authorSlug != "" {
+		if msg := ValidateSlug(authorSlug); msg != "" {
+			return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("Author: "+msg))
+		}
+	}
+	meta, msg := parseMetaForm(c.Request().Form["meta_key"], c.Request().Form["meta_value"])
+	if msg != "" {
+		return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape(msg))
+	}
+	// The form of a saved post carries the version it was opened at, so
+	// edits made in another tab or by another admin meanwhile aren't
+	// overwritten.
+	original := strings.TrimSpace(c.FormValue("original_slug"))
+	versioned := original != "" && c.FormValue("version") != ""
+	version, _ := strconv.Atoi(c.FormValue("version"))
+	var changed *BlogPost
+	if versioned {
+		if current, err := a.Content.GetPostAny(original); err == nil && current.Version != version {
+			changed = &current
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	// Changing the slug of a saved post renames it and redirects the old
+	// slug to the new one. The edits are saved under the old slug first,
+	// so a post that changed meanwhile is neither saved nor renamed.
+	var renamed BlogPost
+	if changed == nil && original != "" && original != slug {
+		if _, err := a.Content.GetPostAny(slug); err == nil {
+			return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("A post with this slug already exists. Choose a different slug."))
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		old, err := a.Content.GetPostAny(original)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && old.TrashedAt == "" {
+			renamed = old
+		}
+	}
+	previous := renamed
+	if renamed.Slug == "" {
+		previous, _ = a.Content.GetPostAny(slug)
+	}
+	if previous.TrashedAt != "" {
+		return c.Redirect(http.StatusSeeOther, "/admin/?msg="+url.QueryEscape("A post with this slug is in the trash. Restore it or delete it for good first."))
+	}
+	post := BlogPost{
+		Slug:        slug,
+		Title:       title,
+		Date:        date,
+		Tags:        tags,
+		Summary:     summary,
+		Content:     content,
+		Published:   published,
+		Featured:    previous.Featured,
+		OGImage:     ogImage,
+		SeriesSlug:  seriesSlug,
+		SeriesOrder: seriesOrder,
+		AuthorSlug:  authorSlug,
+
+		ExcludeFromFeed:    excludeFromFeed,
+		ExcludeFromSitemap: excludeFromSitemap,
+		NoIndex:            noIndex,
+		CanonicalURL:       canonicalURL,
+		Meta:               meta,
+	}
+	// A draft with a publish time is published then by the publisher, and
+	// dated the day it goes out.
+	if !published && !publishAt.IsZero() {
+		post.PublishAt = publishAt.UTC().Format(time.RFC3339)
+		post.Date = publishAt.Format("2006-01-02")
+	}
+	if changed != nil {
+		return a.renderPostConflict(c, post, *changed)
+	}
+	// A new post that looks like a copy of another, e.g. from an importer
+	// rerun or a double submit, is kept as a draft until published again.
+	var dupWarning string
+	if previous.Slug == "" {
+		existing, err := a.Content.ListAllPosts()
+		if err != nil {
+			return err
+		}
+		if dups := FindDuplicates(post, existing); len(dups) > 0 {
+			dupWarning = duplicateWarning(dups)
+			if post.Published {
+				post.Published = false
+				dupWarning = "saved as a draft because it " + dupWarning + "; publish it again if that's intended"
+			}
+		}
+	}
+	save := a.Content.SavePost
+	if versioned {
+		save = func(p BlogPost) error { return a.savePostIfVersion(p, version) }
+	}
+	saved := post
+	if renamed.Slug != "" {
+		saved.Slug = renamed.Slug
+	}
+	if err := save(saved); err == ErrPostChanged {
+		current, err := a.Content.GetPostAny(saved.Slug)
+		if err != nil {
+			return err
+		}
+		return a.renderPostConflict(c, post, current)
+	} else if err != nil {
+		return err
+	}
+	if renamed.Slug != "" {
+		if err := a.renamePost(renamed.Slug, slug); err != nil {
+			return err
+		}
+	}
+	if err := a.keepRevision(slug); err != nil {
 		return err
 	}
 	a.Cache.Invalidate()
+	if previous.Published || post.Published {
+		a.purge(renamed, previous, post)
+	}
+	warnings := a.contentWarnings(content)
+	if w := a.ogImageWarning(ogImage); w != "" {
+		warnings = append([]string{w}, warnings...)
+	}
+	if dupWarning != "" {
+		warnings = append([]string{dupWarning}, warnings...)
+	}
+	a.queueLinkPreviews(post.Content)
+	if err := a.syndicate(post, c.Request().Form["syndicate"]); err != nil {
+		warnings = append(warnings, "cross-posting failed: "+err.Error())
+	}
+	if len(warnings) > 0 {
+		return a.renderAdminDashboard(c, "Post saved with warnings: "+strings.Join(warnings, "; "))
+	}
 	return a.renderAdminDashboard(c, "saved")
 }
 
+// renderPostConflict shows the post form again with edits that weren't
+// saved because current, the saved post, changed since the form was
+// opened. The form is at current's version, so saving it again replaces
+// current; a slug change in the edits is dropped. Without an
+// AdminPostConflict view the edits are lost and the dashboard says why.
+func (a *App) renderPostConflict(c echo.Context, edited, current BlogPost) error {
+	if a.Views.AdminPostConflict == nil {
+		return a.renderAdminDashboard(c, "Not saved: the post changed since you opened it. Open it again and redo your edits.")
+	}
+	edited.Slug = current.Slug
+	edited.Version = current.Version
+	edited.Featured = current.Featured
+	return RenderStatus(c, http.StatusConflict, a.Views.AdminPostConflict(edited, current, CsrfToken(c)))
+}
+
+// handleAdminDuplicate copies a post into a new draft and opens the copy
+// in the post form.
+func (a *App) handleAdminDuplicate(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	post, err := duplicatePost(a.Content, c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	a.Cache.Invalidate()
+	return Render(c, a.Views.AdminFormPartial(post, CsrfToken(c)))
+}
+
+// handleAdminFeature marks a post as featured, or not with featured=false,
+// from the admin post list.
+func (a *App) handleAdminFeature(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	featured := c.FormValue("featured") != "false"
+	if err := a.setPostFeatured(c.Param("slug"), featured); err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	a.Cache.Invalidate()
+	return c.NoContent(http.StatusNoContent)
+}
+
 func (a *App) handleAdminDelete(c echo.Context) error {
 	if !IsAdmin(c) {
 		return c.Redirect(http.StatusSeeOther, "/admin/")
 	}
 	slug := c.Param("slug")
-	if err := a.Store.DeletePost(slug); err != nil {
+	post, _ := a.Content.GetPostAny(slug)
+	trashed, err := a.removePost(slug)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
 		return err
 	}
 	a.Cache.Invalidate()
-	return a.renderAdminDashboard(c, "deleted")
+	if post.Published {
+		a.purge(post)
+	}
+	if !trashed {
+		return a.renderAdminDashboard(c, "deleted")
+	}
+	return a.renderAdminDashboard(c, "trashed")
+}
+
+// handleAdminBulk applies an action to the posts ticked in the admin post
+// list: publish, unpublish, delete (to the trash where there is one) or
+// tag, which adds the tag form value.
+func (a *App) handleAdminBulk(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if err := c.Request().ParseForm(); err != nil {
+		return err
+	}
+	slugs := FilterEmpty(c.Request().Form["slug"])
+	if len(slugs) == 0 {
+		return a.renderAdminDashboard(c, "Select one or more posts first.")
+	}
+	// Published posts that change are purged from the CDN, before and
+	// after, as the home page and feeds list them.
+	var before []BlogPost
+	for _, slug := range slugs {
+		if p, err := a.Content.GetPostAny(slug); err == nil {
+			before = append(before, p)
+		}
+	}
+
+	var n int
+	var err error
+	var done string
+	switch action := c.FormValue("action"); action {
+	case "publish", "unpublish":
+		n, err = a.setPostsPublished(slugs, action == "publish")
+		done = action + "ed"
+		if err == nil && action == "publish" {
+			for _, p := range before {
+				if !p.Published {
+					err = errors.Join(err, a.queueSyndication(p.Slug))
+				}
+			}
+		}
+	case "delete":
+		var trashed bool
+		n, trashed, err = a.removePosts(slugs)
+		done = "deleted"
+		if trashed {
+			done = "moved to the trash"
+		}
+	case "tag":
+		tag := strings.TrimSpace(c.FormValue("tag"))
+		if tag == "" || strings.Contains(tag, ",") {
+			return a.renderAdminDashboard(c, "Enter one tag to add, without commas.")
+		}
+		n, err = a.tagPosts(slugs, tag)
+		done = "tagged " + strings.ToLower(tag)
+	default:
+		return c.String(http.StatusBadRequest, "Unknown action")
+	}
+	if n > 0 {
+		a.Cache.Invalidate()
+		var changed []BlogPost
+		for _, p := range before {
+			after, _ := a.Content.GetPostAny(p.Slug)
+			if p.Published || after.Published {
+				changed = append(changed, p, after)
+			}
+		}
+		if len(changed) > 0 {
+			a.purge(changed...)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if n == 1 {
+		return a.renderAdminDashboard(c, "1 post "+done+".")
+	}
+	return a.renderAdminDashboard(c, strconv.Itoa(n)+" posts "+done+".")
 }
 
 func (a *App) renderAdminDashboard(c echo.Context, msg string) error {
-	posts, err := a.Store.ListAllPosts()
+	if msg == "" {
+		msg = healthMessage(a.Health())
+	}
+	posts, err := a.Content.ListAllPosts()
 	if err != nil {
 		return err
 	}
+	if err := a.withSyndications(posts); err != nil {
+		return err
+	}
 	return Render(c, a.Views.AdminDashboard(posts, msg, CsrfToken(c)))
 }
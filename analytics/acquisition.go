@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+)
+
+// SetGoalPaths sets the paths whose visits count as conversions in the
+// acquisition report, e.g. "/newsletter/thanks/". A session converts when
+// any of its page views is to a goal path. Call it before serving.
+func (s *Store) SetGoalPaths(paths []string) {
+	if len(paths) == 0 {
+		s.goals = ""
+		return
+	}
+	data, _ := json.Marshal(paths)
+	s.goals = string(data)
+}
+
+// HasGoals reports whether goal paths are set, see SetGoalPaths.
+func (s *Store) HasGoals() bool {
+	return s.goals != ""
+}
+
+// acquisitionStats groups the sessions that started in the period by their
+// first touch, attributing every page view and conversion of a session to
+// it, unlike the referrer stats, which count each page view's own referrer.
+// An empty site covers all sites.
+func (s *Store) acquisitionStats(ctx context.Context, site string, from, to time.Time) ([]AcquisitionStat, error) {
+	goals := s.goals
+	if goals == "" {
+		goals = "[]"
+	}
+	rows, err := s.q.AcquisitionStats(ctx, sqlcgen.AcquisitionStatsParams{
+		Goals:    goals,
+		FromTime: from,
+		ToTime:   to,
+		Site:     site,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("acquisition stats: %w", err)
+	}
+	result := make([]AcquisitionStat, len(rows))
+	for i, r := range rows {
+		result[i] = AcquisitionStat{
+			Source:      r.Source,
+			Medium:      r.UtmMedium,
+			Campaign:    r.UtmCampaign,
+			Sessions:    int(r.Sessions),
+			Views:       int(r.Views),
+			Conversions: int(r.Conversions),
+		}
+		if r.Sessions > 0 {
+			result[i].ConversionRate = math.Round(float64(r.Conversions)/float64(r.Sessions)*1000) / 10
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAcquisition(t *testing.T) {
+	store := newTestStore(t)
+	store.SetGoalPaths([]string{"/thanks/"})
+
+	now := time.Now().UTC().Add(-time.Hour)
+	visit := func(session, referrer, utmSource, path string, at time.Time) {
+		t.Helper()
+		v := &Visit{VisitorID: session, SessionID: session, Referrer: referrer, UTMSource: utmSource, Path: path, Timestamp: at}
+		if utmSource != "" {
+			v.UTMMedium = "email"
+		}
+		if err := store.SaveVisit(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Later page views carry the site's own referrer, but count towards
+	// where the session started.
+	visit("a", "news.ycombinator.com", "", "/blog/post/", now)
+	visit("a", "example.com", "", "/thanks/", now.Add(time.Minute))
+	visit("b", "Direct", "newsletter", "/", now)
+	visit("b", "example.com", "", "/about/", now.Add(time.Minute))
+	visit("b", "example.com", "", "/thanks/", now.Add(2*time.Minute))
+	// An event queued offline arrives after a later one of its session.
+	visit("c", "example.com", "", "/about/", now.Add(time.Minute))
+	visit("c", "news.ycombinator.com", "", "/", now)
+
+	stats, err := store.GetStats(now.Add(-time.Hour), now.Add(time.Hour), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AcquisitionStat{
+		{Source: "news.ycombinator.com", Sessions: 2, Views: 4, Conversions: 1, ConversionRate: 50},
+		{Source: "newsletter", Medium: "email", Sessions: 1, Views: 3, Conversions: 1, ConversionRate: 100},
+	}
+	if !reflect.DeepEqual(stats.Acquisition, want) {
+		t.Errorf("Acquisition = %+v, want %+v", stats.Acquisition, want)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -62,8 +64,16 @@ type Visit struct {
 	Path        string    `json:"path"`         // Page path
 	Referrer    string    `json:"referrer"`     // Referrer URL
 	ScreenSize  string    `json:"screen_size"`  // e.g., "1920x1080"
+	Viewport    string    `json:"viewport"`     // Screen class derived from ScreenSize, e.g. "laptop"
+	Language    string    `json:"language"`     // Primary language code, e.g. "en"
+	UTMSource   string    `json:"utm_source"`   // utm_source query parameter
+	UTMMedium   string    `json:"utm_medium"`   // utm_medium query parameter
+	UTMCampaign string    `json:"utm_campaign"` // utm_campaign query parameter
 	Timestamp   time.Time `json:"timestamp"`
 	DurationSec int       `json:"duration_sec"` // Time spent on page (0 if not available)
+	UserAgent   string    `json:"-"`            // Full user agent string, kept for ReclassifyVisits
+	Weight      int       `json:"-"`            // Page views the visit stands for, the sample rate when it was stored
+	Hostname    string    `json:"hostname"`     // Site the page was viewed on, e.g. "example.com"
 }
 
 // BotVisit represents a single bot/crawler page view.
@@ -76,6 +86,25 @@ type BotVisit struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// DurationUpdate is an engaged-time update for a visitor's latest visit to a path.
+type DurationUpdate struct {
+	VisitorID   string
+	Path        string
+	DurationSec int
+}
+
+// Batch groups the writes produced by one collect request.
+type Batch struct {
+	Visits    []*Visit
+	BotVisits []*BotVisit
+	Durations []DurationUpdate
+}
+
+// Len returns the number of writes in the batch.
+func (b *Batch) Len() int {
+	return len(b.Visits) + len(b.BotVisits) + len(b.Durations)
+}
+
 // VisitRequest is the data sent from client.
 type VisitRequest struct {
 	Path       string `json:"path"`
@@ -92,12 +121,32 @@ type Stats struct {
 	TotalViews    int               `json:"total_views"`
 	AvgDuration   int               `json:"avg_duration_sec"`
 	TopPages      []PageStat        `json:"top_pages"`
+	EntryPages    []PageStat        `json:"entry_pages"`
+	ExitPages     []PageStat        `json:"exit_pages"`
 	LatestPages   []LatestPageVisit `json:"latest_pages"`
 	BrowserStats  []DimensionStat   `json:"browsers"`
 	OSStats       []DimensionStat   `json:"os"`
 	DeviceStats   []DimensionStat   `json:"devices"`
 	ReferrerStats []DimensionStat   `json:"referrers"`
+	LanguageStats []DimensionStat   `json:"languages"`
+	ViewportStats []DimensionStat   `json:"viewports"`
+	Campaigns     []CampaignStat    `json:"campaigns"`
+	Acquisition   []AcquisitionStat `json:"acquisition"`
 	DailyViews    []DailyView       `json:"daily_views"`
+	Comparison    *PeriodComparison `json:"comparison"`
+}
+
+// PeriodComparison holds the totals of the preceding period of equal length
+// and the percentage change of the current period against it. A nil change
+// means the previous period had no data to compare against.
+type PeriodComparison struct {
+	Period         string   `json:"period"`
+	UniqueVisitors int      `json:"unique_visitors"`
+	TotalViews     int      `json:"total_views"`
+	AvgDuration    int      `json:"avg_duration_sec"`
+	VisitorsChange *float64 `json:"unique_visitors_change"`
+	ViewsChange    *float64 `json:"total_views_change"`
+	DurationChange *float64 `json:"avg_duration_change"`
 }
 
 // BotStats holds aggregated bot analytics data.
@@ -128,12 +177,43 @@ type DimensionStat struct {
 	Count int    `json:"count"`
 }
 
+// CampaignStat represents visits attributed to a UTM campaign.
+type CampaignStat struct {
+	Campaign string `json:"campaign"`
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Visits   int    `json:"visits"`
+}
+
+// AcquisitionStat represents the sessions that started from one source,
+// with the page views and conversions of those sessions attributed to it.
+// Source is the session's utm_source, or its referrer without one.
+type AcquisitionStat struct {
+	Source         string  `json:"source"`
+	Medium         string  `json:"medium"`
+	Campaign       string  `json:"campaign"`
+	Sessions       int     `json:"sessions"`
+	Views          int     `json:"views"`
+	Conversions    int     `json:"conversions"`     // sessions that visited a goal path, see Store.SetGoalPaths
+	ConversionRate float64 `json:"conversion_rate"` // percent of sessions, rounded to one decimal
+}
+
 // DailyView represents views per day.
 type DailyView struct {
 	Date  string `json:"date"`
 	Views int    `json:"views"`
 }
 
+// PercentChange returns the relative change from previous to current in percent,
+// rounded to one decimal. Returns nil when previous is zero.
+func PercentChange(current, previous int) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round(float64(current-previous)/float64(previous)*1000) / 10
+	return &change
+}
+
 // HashIP creates a salted SHA-256 hash of an IP address.
 func HashIP(ip string) string {
 	h := sha256.New()
@@ -251,6 +331,70 @@ func ExtractBotName(ua string) string {
 	return "Unknown"
 }
 
+// NormalizeLanguage reduces a language tag or Accept-Language header value
+// to its lowercase primary language code, e.g. "en-US,en;q=0.9" -> "en".
+// Returns an empty string when no valid code can be extracted.
+func NormalizeLanguage(lang string) string {
+	if i := strings.IndexAny(lang, ",;"); i >= 0 {
+		lang = lang[:i]
+	}
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if len(lang) < 2 || len(lang) > 3 {
+		return ""
+	}
+	for _, r := range lang {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return lang
+}
+
+// Viewport classes returned by ClassifyScreen.
+const (
+	ViewportMobile    = "mobile"
+	ViewportTablet    = "tablet"
+	ViewportLaptop    = "laptop"
+	ViewportDesktop   = "desktop"
+	ViewportUltrawide = "ultrawide"
+)
+
+// ClassifyScreen buckets a "WIDTHxHEIGHT" screen size into a viewport class
+// using the screen width in CSS pixels: mobile below 600, tablet below 1024,
+// laptop below 1600 and desktop above. Screens at least 2560 wide with an
+// aspect ratio of 21:10 or more are ultrawide. Returns an empty string for
+// sizes that can't be parsed.
+func ClassifyScreen(size string) string {
+	ws, hs, ok := strings.Cut(size, "x")
+	if !ok {
+		return ""
+	}
+	w, err := strconv.Atoi(ws)
+	if err != nil || w <= 0 {
+		return ""
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil || h <= 0 {
+		return ""
+	}
+
+	switch {
+	case w < 600:
+		return ViewportMobile
+	case w < 1024:
+		return ViewportTablet
+	case w < 1600:
+		return ViewportLaptop
+	case w >= 2560 && w*10 >= h*21:
+		return ViewportUltrawide
+	default:
+		return ViewportDesktop
+	}
+}
+
 // referrerDomainRegex is pre-compiled for use in CleanReferrer.
 var referrerDomainRegex = regexp.MustCompile(`^https?://(?:www\.)?([^/]+)`)
 
@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestStore opens a Store in a temporary directory, closed when the
+// test ends.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "analytics.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for in, want := range map[string]string{
+		"en":             "en",
+		"en-US":          "en",
+		"pt_BR":          "pt",
+		"de-DE,de;q=0.9": "de",
+		" FR ":           "fr",
+		"fil;q=0.8":      "fil",
+		"*":              "",
+		"e":              "",
+		"engl":           "",
+		"x1":             "",
+		"":               "",
+	} {
+		if got := NormalizeLanguage(in); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	for _, tc := range []struct {
+		current, previous int
+		want              float64
+	}{
+		{112, 100, 12},
+		{97, 100, -3},
+		{1, 3, -66.7},
+		{5, 5, 0},
+	} {
+		if got := PercentChange(tc.current, tc.previous); got == nil || *got != tc.want {
+			t.Errorf("PercentChange(%d, %d) = %v, want %v", tc.current, tc.previous, got, tc.want)
+		}
+	}
+	if got := PercentChange(10, 0); got != nil {
+		t.Errorf("PercentChange(10, 0) = %v, want nil", *got)
+	}
+}
+
+func TestClassifyScreen(t *testing.T) {
+	for size, want := range map[string]string{
+		"390x844":   ViewportMobile,
+		"599x900":   ViewportMobile,
+		"600x960":   ViewportTablet,
+		"1023x768":  ViewportTablet,
+		"1024x768":  ViewportLaptop,
+		"1536x864":  ViewportLaptop,
+		"1918x1051": ViewportDesktop,
+		"2560x1440": ViewportDesktop,
+		"2560x1080": ViewportUltrawide,
+		"3440x1440": ViewportUltrawide,
+		"1920":      "",
+		"0x1080":    "",
+		"1920x-1":   "",
+		"axb":       "",
+		"":          "",
+	} {
+		if got := ClassifyScreen(size); got != want {
+			t.Errorf("ClassifyScreen(%q) = %q, want %q", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+)
+
+// VariantResult holds the outcome of one experiment variant.
+type VariantResult struct {
+	Variant        string   `json:"variant"`
+	Exposures      int      `json:"exposures"`       // unique visitors who saw the variant
+	Conversions    int      `json:"conversions"`     // exposed visitors who later reached the goal path
+	ConversionRate float64  `json:"conversion_rate"` // percent, rounded to one decimal
+	Lift           *float64 `json:"lift"`            // percent change of the rate against the control, nil for the control itself
+}
+
+// RecordExposure stores that a visitor was shown a variant of an experiment.
+// Only the first exposure per visitor is kept.
+func (s *Store) RecordExposure(experiment, variant, visitorID string) error {
+	return s.q.InsertExposure(context.Background(), sqlcgen.InsertExposureParams{
+		Experiment: experiment,
+		Variant:    variant,
+		VisitorID:  visitorID,
+		Timestamp:  time.Now().UTC(),
+	})
+}
+
+// ExperimentResults returns exposures and conversions per variant. A conversion is
+// a visit to goalPath by an exposed visitor at or after their first exposure.
+// Lift is computed against the variant named control.
+func (s *Store) ExperimentResults(experiment, goalPath, control string) ([]VariantResult, error) {
+	rows, err := s.q.ExperimentResults(context.Background(), goalPath, experiment)
+	if err != nil {
+		return nil, fmt.Errorf("experiment results: %w", err)
+	}
+
+	results := make([]VariantResult, len(rows))
+	var controlRate float64
+	for i, r := range rows {
+		results[i] = VariantResult{
+			Variant:     r.Variant,
+			Exposures:   int(r.Exposures),
+			Conversions: int(r.Conversions),
+		}
+		if r.Exposures > 0 {
+			results[i].ConversionRate = math.Round(float64(r.Conversions)/float64(r.Exposures)*1000) / 10
+		}
+		if r.Variant == control {
+			controlRate = results[i].ConversionRate
+		}
+	}
+
+	for i := range results {
+		if results[i].Variant == control || controlRate == 0 {
+			continue
+		}
+		lift := math.Round((results[i].ConversionRate-controlRate)/controlRate*1000) / 10
+		results[i].Lift = &lift
+	}
+	return results, nil
+}
@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExperimentResults(t *testing.T) {
+	store := newTestStore(t)
+	visit := func(visitor, path string, at time.Time) {
+		t.Helper()
+		if err := store.SaveVisit(&Visit{VisitorID: visitor, SessionID: visitor, Path: path, Timestamp: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expose := func(variant string, visitors ...string) {
+		t.Helper()
+		for _, v := range visitors {
+			if err := store.RecordExposure("title", variant, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expose("a", "v1", "v2", "v3", "v4")
+	expose("b", "v5", "v6", "v7", "v8")
+	// Only a visitor's first exposure counts.
+	expose("b", "v1")
+	// Another experiment doesn't mix in.
+	if err := store.RecordExposure("summary", "a", "v9"); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().UTC().Add(time.Minute)
+	for _, v := range []string{"v1", "v2", "v5", "v7", "v8", "v9"} {
+		visit(v, "/thanks/", later)
+	}
+	visit("v3", "/about/", later)
+	// Reaching the goal before seeing the variant isn't a conversion.
+	visit("v6", "/thanks/", later.Add(-time.Hour))
+
+	results, err := store.ExperimentResults("title", "/thanks/", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want 2 variants", results)
+	}
+	a, b := results[0], results[1]
+	if a.Variant != "a" || a.Exposures != 4 || a.Conversions != 2 || a.ConversionRate != 50 || a.Lift != nil {
+		t.Errorf("control = %+v, want 2 of 4 converted and no lift", a)
+	}
+	if b.Variant != "b" || b.Exposures != 4 || b.Conversions != 3 || b.ConversionRate != 75 || b.Lift == nil || *b.Lift != 50 {
+		t.Errorf("variant = %+v, want 3 of 4 converted, 50%% lift", b)
+	}
+
+	// Without control conversions there is no lift to report.
+	results, err = store.ExperimentResults("title", "/nowhere/", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Conversions != 0 || r.Lift != nil {
+			t.Errorf("result = %+v, want no conversions or lift", r)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+	"github.com/eringen/pubengine/analytics/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// FeedHit represents a single server-side request to a feed or API endpoint.
+type FeedHit struct {
+	Path        string    `json:"path"`
+	Reader      string    `json:"reader"`      // Feed reader or client name (e.g., "Feedly")
+	Subscribers int       `json:"subscribers"` // Subscriber count reported by the reader (0 if not reported)
+	IPHash      string    `json:"-"`
+	UserAgent   string    `json:"user_agent"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// FeedStats holds aggregated feed and API analytics data.
+type FeedStats struct {
+	Period      string           `json:"period"`
+	TotalHits   int              `json:"total_hits"`
+	Subscribers int              `json:"estimated_subscribers"`
+	Readers     []FeedReaderStat `json:"readers"`
+	TopPaths    []PageStat       `json:"top_paths"`
+	DailyHits   []DailyView      `json:"daily_hits"`
+}
+
+// FeedReaderStat represents hits from one feed reader.
+type FeedReaderStat struct {
+	Reader      string `json:"reader"`
+	Hits        int    `json:"hits"`
+	Clients     int    `json:"clients"`     // Distinct IP hashes
+	Subscribers int    `json:"subscribers"` // Highest reported subscriber count
+}
+
+// feedPaths are always tracked by FeedMiddleware, in addition to API routes.
+var feedPaths = map[string]bool{
+	"/feed.xml": true,
+	"/atom.xml": true,
+}
+
+// isFeedPath reports whether requests to path are recorded as feed hits.
+// The analytics collect endpoint is excluded since it is tracked separately.
+func isFeedPath(path string) bool {
+	if feedPaths[path] {
+		return true
+	}
+	return strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/analytics/")
+}
+
+// subscribersRegex matches counts like "123 subscribers" in aggregator user agents.
+var subscribersRegex = regexp.MustCompile(`(?i)(\d+)\s+(?:subscribers|readers)`)
+
+// feedReaders maps lowercase user agent fragments to reader names. Checked in order.
+var feedReaders = []struct {
+	pattern string
+	name    string
+}{
+	{"feedly", "Feedly"},
+	{"inoreader", "Inoreader"},
+	{"newsblur", "NewsBlur"},
+	{"theoldreader", "The Old Reader"},
+	{"feedbin", "Feedbin"},
+	{"netnewswire", "NetNewsWire"},
+	{"miniflux", "Miniflux"},
+	{"freshrss", "FreshRSS"},
+	{"tiny tiny rss", "Tiny Tiny RSS"},
+	{"reeder", "Reeder"},
+	{"feeder", "Feeder"},
+	{"readwise", "Readwise"},
+	{"newsboat", "Newsboat"},
+	{"bazqux", "BazQux"},
+	{"thunderbird", "Thunderbird"},
+	{"feedburner", "FeedBurner"},
+	{"curl", "curl"},
+	{"python", "Python"},
+	{"go-http-client", "Go"},
+}
+
+// ParseFeedReader extracts the reader name and reported subscriber count from a
+// request's User-Agent and X-Subscribers header. Unknown clients are named after
+// the first product token of their user agent.
+func ParseFeedReader(ua, subscribersHeader string) (reader string, subscribers int) {
+	if m := subscribersRegex.FindStringSubmatch(ua); m != nil {
+		subscribers, _ = strconv.Atoi(m[1])
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(subscribersHeader)); err == nil && n > subscribers {
+		subscribers = n
+	}
+
+	lower := strings.ToLower(ua)
+	for _, r := range feedReaders {
+		if strings.Contains(lower, r.pattern) {
+			return r.name, subscribers
+		}
+	}
+
+	switch {
+	case ua == "":
+		return "Unknown", subscribers
+	case strings.HasPrefix(ua, "Mozilla/"):
+		if IsBot(ua) {
+			return ExtractBotName(ua), subscribers
+		}
+		return "Browser", subscribers
+	}
+
+	name, _, _ := strings.Cut(ua, "/")
+	name, _, _ = strings.Cut(name, " ")
+	if name == "" || len(name) > 64 {
+		return "Unknown", subscribers
+	}
+	return name, subscribers
+}
+
+// SaveFeedHit stores a new feed or API hit.
+func (s *Store) SaveFeedHit(h *FeedHit) error {
+	return s.q.InsertFeedHit(context.Background(), sqlcgen.InsertFeedHitParams{
+		Path:        h.Path,
+		Reader:      h.Reader,
+		Subscribers: int64(h.Subscribers),
+		IpHash:      h.IPHash,
+		UserAgent:   h.UserAgent,
+		Timestamp:   h.Timestamp.UTC(),
+	})
+}
+
+// GetFeedStats returns aggregated feed and API statistics for the given time period.
+// The subscriber estimate sums the highest count each reader reported, and counts
+// distinct clients for readers that report none.
+func (s *Store) GetFeedStats(from, to time.Time, hourly, monthly bool) (*FeedStats, error) {
+	ctx := context.Background()
+	stats := &FeedStats{
+		Period:    from.Format("2006-01-02") + " to " + to.Format("2006-01-02"),
+		Readers:   []FeedReaderStat{},
+		TopPaths:  []PageStat{},
+		DailyHits: []DailyView{},
+	}
+
+	// Total hits
+	count, err := s.q.CountFeedHits(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("count feed hits: %w", err)
+	}
+	stats.TotalHits = int(count)
+
+	// Readers and subscriber estimate
+	readers, err := s.q.FeedReaderStats(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("feed reader stats: %w", err)
+	}
+	for _, r := range readers {
+		stats.Readers = append(stats.Readers, FeedReaderStat{
+			Reader:      r.Reader,
+			Hits:        int(r.Hits),
+			Clients:     int(r.Clients),
+			Subscribers: int(r.Subscribers),
+		})
+		if r.Subscribers > 0 {
+			stats.Subscribers += int(r.Subscribers)
+		} else {
+			stats.Subscribers += int(r.Clients)
+		}
+	}
+
+	// Top paths
+	paths, err := s.q.TopFeedPaths(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("top feed paths: %w", err)
+	}
+	for _, r := range paths {
+		stats.TopPaths = append(stats.TopPaths, PageStat{Path: r.Path, Views: int(r.Views)})
+	}
+
+	// Daily/hourly/monthly hits
+	if hourly {
+		rows, err := s.q.HourlyFeedHits(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("feed hits: %w", err)
+		}
+		sparse := make([]DailyView, len(rows))
+		for i, r := range rows {
+			sparse[i] = DailyView{Date: r.Date, Views: int(r.Views)}
+		}
+		stats.DailyHits = fillHourlyGaps(from, sparse)
+	} else if monthly {
+		rows, err := s.q.MonthlyFeedHits(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("feed hits: %w", err)
+		}
+		for _, r := range rows {
+			stats.DailyHits = append(stats.DailyHits, DailyView{Date: r.Date, Views: int(r.Views)})
+		}
+	} else {
+		rows, err := s.q.DailyFeedHits(ctx, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("feed hits: %w", err)
+		}
+		for _, r := range rows {
+			stats.DailyHits = append(stats.DailyHits, DailyView{Date: r.Date, Views: int(r.Views)})
+		}
+	}
+
+	return stats, nil
+}
+
+// FeedMiddleware records successful GET requests to feeds (/feed.xml, /atom.xml)
+// and API routes (/api/...) as feed hits. Feed readers and API clients never run
+// the JS beacon, so this is the only way to see them.
+func (h *Handler) FeedMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			req := c.Request()
+			if req.Method != http.MethodGet || !isFeedPath(req.URL.Path) {
+				return err
+			}
+			if status := c.Response().Status; err != nil || (status != http.StatusOK && status != http.StatusNotModified) {
+				return err
+			}
+
+			ua := req.UserAgent()
+			if len(ua) > maxUserAgentLen {
+				ua = ua[:maxUserAgentLen]
+			}
+			reader, subscribers := ParseFeedReader(ua, req.Header.Get("X-Subscribers"))
+			hit := &FeedHit{
+				Path:        req.URL.Path,
+				Reader:      reader,
+				Subscribers: subscribers,
+				IPHash:      HashIP(c.RealIP()),
+				UserAgent:   ua,
+				Timestamp:   time.Now().UTC(),
+			}
+			if saveErr := h.store.SaveFeedHit(hit); saveErr != nil {
+				c.Logger().Errorf("Failed to save feed hit: %v", saveErr)
+			}
+			return err
+		}
+	}
+}
+
+// FeedStatsResponse is the JSON response for the feed stats endpoint.
+type FeedStatsResponse struct {
+	Stats      *FeedStats `json:"stats"`
+	PeriodDays int        `json:"period_days"`
+	Hourly     bool       `json:"hourly"`
+	Monthly    bool       `json:"monthly"`
+}
+
+// GetFeedStats returns feed and API statistics as JSON.
+func (h *Handler) GetFeedStats(c echo.Context) error {
+	_, days, hourly, monthly := parsePeriod(c.QueryParam("period"))
+
+	from, to := periodTimeRange(days, hourly)
+
+	stats, err := h.store.GetFeedStats(from, to, hourly, monthly)
+	if err != nil {
+		return fmt.Errorf("get feed stats: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, FeedStatsResponse{
+		Stats:      stats,
+		PeriodDays: days,
+		Hourly:     hourly,
+		Monthly:    monthly,
+	})
+}
+
+// GetFeedStatsFragment returns HTML fragment for feed stats (talkdom)
+func (h *Handler) GetFeedStatsFragment(c echo.Context) error {
+	_, days, hourly, monthly := parsePeriod(c.QueryParam("period"))
+
+	from, to := periodTimeRange(days, hourly)
+
+	stats, err := h.store.GetFeedStats(from, to, hourly, monthly)
+	if err != nil {
+		c.Logger().Errorf("Failed to get feed stats fragment: %v", err)
+		return c.HTML(http.StatusInternalServerError, "<div class='loading'>Error loading data</div>")
+	}
+
+	component := templates.FeedStatsFragmentOnly(convertFeedStatsToViewModel(stats), hourly, monthly)
+	return component.Render(c.Request().Context(), c.Response())
+}
+
+// convertFeedStatsToViewModel converts analytics.FeedStats to templates.FeedStatsViewModel
+func convertFeedStatsToViewModel(stats *FeedStats) *templates.FeedStatsViewModel {
+	vm := &templates.FeedStatsViewModel{
+		Period:      stats.Period,
+		TotalHits:   stats.TotalHits,
+		Subscribers: stats.Subscribers,
+	}
+
+	vm.Readers = make([]templates.FeedReaderViewModel, len(stats.Readers))
+	for i, r := range stats.Readers {
+		vm.Readers[i] = templates.FeedReaderViewModel{
+			Reader:      r.Reader,
+			Hits:        r.Hits,
+			Clients:     r.Clients,
+			Subscribers: r.Subscribers,
+		}
+	}
+
+	vm.TopPaths = make([]templates.PageStatViewModel, len(stats.TopPaths))
+	for i, p := range stats.TopPaths {
+		vm.TopPaths[i] = templates.PageStatViewModel{
+			Path:  p.Path,
+			Views: p.Views,
+		}
+	}
+
+	vm.DailyHits = make([]templates.DailyViewViewModel, len(stats.DailyHits))
+	for i, v := range stats.DailyHits {
+		vm.DailyHits[i] = templates.DailyViewViewModel{
+			Date:  v.Date,
+			Views: v.Views,
+		}
+	}
+
+	return vm
+}
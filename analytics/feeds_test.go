@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestParseFeedReader(t *testing.T) {
+	for _, tc := range []struct {
+		ua, header string
+		reader     string
+		subs       int
+	}{
+		{"Feedly/1.0 (+http://www.feedly.com/fetcher.html; 1234 subscribers)", "", "Feedly", 1234},
+		{"Inoreader/1.0 (+http://www.inoreader.com/feed-fetcher; 56 subscribers)", "80", "Inoreader", 80},
+		{"NetNewsWire (RSS Reader; https://netnewswire.com/)", "", "NetNewsWire", 0},
+		{"Mozilla/5.0 (Windows NT 10.0) Firefox/130.0", "", "Browser", 0},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", "", "Googlebot", 0},
+		{"SomeReader/2.3 (12 readers)", "nope", "SomeReader", 12},
+		{"", "", "Unknown", 0},
+	} {
+		reader, subs := ParseFeedReader(tc.ua, tc.header)
+		if reader != tc.reader || subs != tc.subs {
+			t.Errorf("ParseFeedReader(%q, %q) = %q, %d, want %q, %d", tc.ua, tc.header, reader, subs, tc.reader, tc.subs)
+		}
+	}
+}
+
+func TestFeedMiddleware(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store)
+	e := echo.New()
+	e.Use(h.FeedMiddleware())
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e.GET("/feed.xml", ok)
+	e.GET("/atom.xml", ok)
+	e.GET("/api/posts", ok)
+	e.GET("/api/analytics/collect", ok)
+	e.GET("/about/", ok)
+	e.POST("/api/posts", ok)
+	request := func(method, path, ua string, header http.Header) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("User-Agent", ua)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	const feedly = "Feedly/1.0 (+http://www.feedly.com/fetcher.html; 120 subscribers)"
+	request(http.MethodGet, "/feed.xml", feedly, nil)
+	request(http.MethodGet, "/feed.xml", "Feedly/1.0 (+http://www.feedly.com/fetcher.html; 100 subscribers)", nil)
+	request(http.MethodGet, "/atom.xml", "NetNewsWire (RSS Reader)", http.Header{"X-Real-Ip": {"10.0.0.1"}})
+	request(http.MethodGet, "/atom.xml", "NetNewsWire (RSS Reader)", http.Header{"X-Real-Ip": {"10.0.0.2"}})
+	request(http.MethodGet, "/api/posts", "curl/8.5.0", nil)
+	// Not recorded: other pages, the collect endpoint, writes and errors.
+	request(http.MethodGet, "/about/", feedly, nil)
+	request(http.MethodGet, "/api/analytics/collect", feedly, nil)
+	request(http.MethodPost, "/api/posts", feedly, nil)
+	request(http.MethodGet, "/api/missing", feedly, nil)
+
+	stats, err := store.GetFeedStats(time.Now().UTC().Add(-time.Hour), time.Now().UTC().Add(time.Hour), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalHits != 5 {
+		t.Errorf("TotalHits = %d, want 5", stats.TotalHits)
+	}
+	readers := make(map[string]FeedReaderStat)
+	for _, r := range stats.Readers {
+		readers[r.Reader] = r
+	}
+	if r := readers["Feedly"]; r.Hits != 2 || r.Subscribers != 120 {
+		t.Errorf("Feedly = %+v, want 2 hits and 120 subscribers", r)
+	}
+	if r := readers["NetNewsWire"]; r.Hits != 2 || r.Clients != 2 {
+		t.Errorf("NetNewsWire = %+v, want 2 hits from 2 clients", r)
+	}
+	// Readers reporting subscribers count those, the others their clients.
+	if stats.Subscribers != 120+2+1 {
+		t.Errorf("Subscribers = %d, want 123", stats.Subscribers)
+	}
+	if len(stats.TopPaths) != 3 {
+		t.Errorf("TopPaths = %+v, want 3 paths", stats.TopPaths)
+	}
+}
@@ -1,20 +1,30 @@
 package analytics
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/eringen/pubengine/analytics/templates"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 // Handler handles analytics HTTP requests.
 type Handler struct {
 	store          *Store
 	collectLimiter *rateLimiter
+	sites          []string // hostnames allowed to send events, see SetSites
 }
 
 // NewHandler creates a new analytics handler.
@@ -26,13 +36,102 @@ func NewHandler(store *Store) *Handler {
 	}
 }
 
+// SetSites limits the collect endpoint to events from the given hostnames
+// and allows those sites to send events cross-origin, so several sites can
+// share one analytics store. Each visit records the hostname it was
+// collected on, and the dashboard and stats API can be filtered by it.
+// Without sites, events from any hostname are accepted from the same
+// origin only. Call it before serving.
+func (h *Handler) SetSites(hosts []string) {
+	h.sites = nil
+	for _, host := range hosts {
+		if host = normalizeHostname(host); host != "" {
+			h.sites = append(h.sites, host)
+		}
+	}
+}
+
+// allowedSite reports whether events from the hostname are recorded.
+func (h *Handler) allowedSite(host string) bool {
+	if len(h.sites) == 0 {
+		return true
+	}
+	for _, site := range h.sites {
+		if site == host {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHostname lowercases a hostname or URL host and strips its port.
+func normalizeHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// requestHostname returns the hostname the page was served from: the one
+// the script reports, else the host of the Origin header of a cross-origin
+// request, else the host the request was sent to.
+func requestHostname(c echo.Context, reported string) string {
+	if host := normalizeHostname(reported); host != "" {
+		return host
+	}
+	if origin := c.Request().Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			return normalizeHostname(u.Host)
+		}
+	}
+	return normalizeHostname(c.Request().Host)
+}
+
+// allowOrigin sets the CORS headers of a collect request from one of the
+// sites, see SetSites. It reports whether the origin is allowed.
+func (h *Handler) allowOrigin(c echo.Context) bool {
+	origin := c.Request().Header.Get("Origin")
+	if origin == "" || len(h.sites) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || !h.allowedSite(normalizeHostname(u.Host)) {
+		return false
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderAccessControlAllowOrigin, origin)
+	header.Add(echo.HeaderVary, echo.HeaderOrigin)
+	return true
+}
+
+// CollectPreflight answers the CORS preflight of a cross-origin collect
+// request from one of the sites.
+func (h *Handler) CollectPreflight(c echo.Context) error {
+	if !h.allowOrigin(c) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderAccessControlAllowMethods, http.MethodPost)
+	header.Set(echo.HeaderAccessControlAllowHeaders, echo.HeaderContentType)
+	header.Set(echo.HeaderAccessControlMaxAge, "86400")
+	return c.NoContent(http.StatusNoContent)
+}
+
 // CollectRequest is the expected request body for the collect endpoint.
 type CollectRequest struct {
 	Path        string `json:"path"`
 	Referrer    string `json:"referrer"`
 	ScreenSize  string `json:"screen_size"`
 	UserAgent   string `json:"user_agent"`
+	Language    string `json:"language"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	Hostname    string `json:"hostname"` // Hostname of the page; the request's origin when empty
 	DurationSec int    `json:"duration_sec"`
+	Heartbeat   bool   `json:"heartbeat"`
+	Timestamp   int64  `json:"ts"` // Unix milliseconds when the event happened; set for events queued offline
 }
 
 // Input validation limits for the collect endpoint.
@@ -41,9 +140,19 @@ const (
 	maxReferrerLen   = 2048
 	maxScreenSizeLen = 32
 	maxUserAgentLen  = 512
+	maxLanguageLen   = 64
+	maxUTMLen        = 128
+	maxHostnameLen   = 253
 	maxDurationSec   = 86400 // 24 hours
+	maxBatchSize     = 50
+	maxEventAge      = 24 * time.Hour // oldest accepted queued event
+	maxClockSkew     = time.Minute
 )
 
+// MaxCollectBytes is the largest request body the collect endpoint reads,
+// enough for a full batch of events.
+const MaxCollectBytes = 256 << 10
+
 // validateCollectRequest checks field lengths and value ranges.
 func validateCollectRequest(req *CollectRequest) error {
 	if len(req.Path) > maxPathLen {
@@ -58,38 +167,104 @@ func validateCollectRequest(req *CollectRequest) error {
 	if len(req.UserAgent) > maxUserAgentLen {
 		return fmt.Errorf("user_agent exceeds maximum length of %d", maxUserAgentLen)
 	}
+	if len(req.Language) > maxLanguageLen {
+		return fmt.Errorf("language exceeds maximum length of %d", maxLanguageLen)
+	}
+	if len(req.UTMSource) > maxUTMLen || len(req.UTMMedium) > maxUTMLen || len(req.UTMCampaign) > maxUTMLen {
+		return fmt.Errorf("utm parameters exceed maximum length of %d", maxUTMLen)
+	}
+	if len(req.Hostname) > maxHostnameLen {
+		return fmt.Errorf("hostname exceeds maximum length of %d", maxHostnameLen)
+	}
 	if req.DurationSec < 0 {
 		return fmt.Errorf("duration_sec must not be negative")
 	}
 	if req.DurationSec > maxDurationSec {
 		return fmt.Errorf("duration_sec exceeds maximum of %d", maxDurationSec)
 	}
+	if req.Timestamp != 0 {
+		ts := time.UnixMilli(req.Timestamp)
+		if time.Since(ts) > maxEventAge || time.Until(ts) > maxClockSkew {
+			return fmt.Errorf("ts must be within the last %s", maxEventAge)
+		}
+	}
 	return nil
 }
 
+// parseCollectBody decodes either a single event object or an array of events.
+func parseCollectBody(body []byte) ([]CollectRequest, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []CollectRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, err
+		}
+		if len(reqs) > maxBatchSize {
+			return nil, fmt.Errorf("batch exceeds maximum size of %d", maxBatchSize)
+		}
+		return reqs, nil
+	}
+	var req CollectRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return []CollectRequest{req}, nil
+}
+
 // Collect handles incoming analytics data from clients.
 func (h *Handler) Collect(c echo.Context) error {
 	// Rate limit by IP to prevent analytics flooding.
 	if !h.collectLimiter.allow(c.RealIP()) {
-		return c.NoContent(http.StatusTooManyRequests)
+		return echo.ErrTooManyRequests
 	}
+	h.allowOrigin(c)
 
 	// Check for Do Not Track
 	if c.Request().Header.Get("DNT") == "1" {
 		return c.NoContent(http.StatusNoContent)
 	}
 
-	// Parse request
-	var req CollectRequest
-	if err := c.Bind(&req); err != nil {
-		return c.String(http.StatusBadRequest, "Invalid request")
+	// Parse request: a single event or a batch of queued events
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, MaxCollectBytes))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	reqs, err := parseCollectBody(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
 
-	// Validate input
-	if err := validateCollectRequest(&req); err != nil {
-		return c.String(http.StatusBadRequest, "Invalid request")
+	// Validate each event; invalid events are dropped without failing the batch
+	batch := &Batch{}
+	invalid := 0
+	for i := range reqs {
+		if err := validateCollectRequest(&reqs[i]); err != nil {
+			invalid++
+			continue
+		}
+		host := requestHostname(c, reqs[i].Hostname)
+		if !h.allowedSite(host) {
+			continue
+		}
+		h.addEvent(c, batch, &reqs[i], host)
+	}
+	if invalid > 0 && invalid == len(reqs) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
 	}
 
+	// Save all events in one transaction
+	if batch.Len() > 0 {
+		if err := h.store.SaveBatch(batch); err != nil {
+			c.Logger().Errorf("Failed to save analytics batch: %v", err)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// addEvent turns a validated collect event from the site host into a
+// pending write on the batch.
+func (h *Handler) addEvent(c echo.Context, batch *Batch, req *CollectRequest, host string) {
 	// Get User-Agent from request if not provided
 	userAgent := req.UserAgent
 	if userAgent == "" {
@@ -99,60 +274,80 @@ func (h *Handler) Collect(c echo.Context) error {
 	// Get client IP
 	ip := c.RealIP()
 
+	// Events queued offline carry the time they happened
+	timestamp := time.Now().UTC()
+	if req.Timestamp != 0 {
+		timestamp = time.UnixMilli(req.Timestamp).UTC()
+	}
+
 	// Handle bot visits separately
 	if IsBot(userAgent) {
-		botVisit := &BotVisit{
+		batch.BotVisits = append(batch.BotVisits, &BotVisit{
 			BotName:   ExtractBotName(userAgent),
 			IPHash:    HashIP(ip),
 			UserAgent: userAgent,
 			Path:      req.Path,
-			Timestamp: time.Now().UTC(),
-		}
-		if err := h.store.SaveBotVisit(botVisit); err != nil {
-			c.Logger().Errorf("Failed to save bot visit: %v", err)
-		}
-		return c.NoContent(http.StatusNoContent)
+			Timestamp: timestamp,
+		})
+		return
 	}
 
 	// Generate visitor ID
 	visitorID := GenerateVisitorID(ip, userAgent)
 
-	// If duration > 0 this is an unload beacon — update the existing visit
-	// instead of creating a duplicate row.
-	if req.DurationSec > 0 {
-		if err := h.store.UpdateVisitDuration(visitorID, req.Path, req.DurationSec); err != nil {
-			c.Logger().Errorf("Failed to update visit duration: %v", err)
+	// With sampling on, every page view counts towards unique visitors but
+	// only those of sampled visitors are stored.
+	if !req.Heartbeat && req.DurationSec == 0 {
+		h.store.countVisitor(visitorID, host, timestamp)
+	}
+	if !h.store.Sampled(visitorID) {
+		return
+	}
+
+	// Heartbeats and unload beacons carry the engaged time for a page that
+	// was already recorded — update the existing visit instead of creating
+	// a duplicate row.
+	if req.Heartbeat || req.DurationSec > 0 {
+		if req.DurationSec > 0 {
+			batch.Durations = append(batch.Durations, DurationUpdate{
+				VisitorID:   visitorID,
+				Path:        req.Path,
+				DurationSec: req.DurationSec,
+			})
 		}
-		return c.NoContent(http.StatusNoContent)
+		return
 	}
 
 	// Parse browser, OS, device
 	browser, os, device := ParseUserAgent(userAgent)
 
-	// Clean referrer
-	referrer := CleanReferrer(req.Referrer)
+	// Prefer the language reported by the browser, fall back to Accept-Language
+	language := NormalizeLanguage(req.Language)
+	if language == "" {
+		language = NormalizeLanguage(c.Request().Header.Get("Accept-Language"))
+	}
 
-	// Create visit
-	visit := &Visit{
+	batch.Visits = append(batch.Visits, &Visit{
 		VisitorID:   visitorID,
-		SessionID:   generateSessionID(visitorID),
+		SessionID:   generateSessionID(visitorID, timestamp),
 		IPHash:      HashIP(ip),
 		Browser:     browser,
 		OS:          os,
 		Device:      device,
 		Path:        req.Path,
-		Referrer:    referrer,
+		Referrer:    CleanReferrer(req.Referrer),
 		ScreenSize:  req.ScreenSize,
-		Timestamp:   time.Now().UTC(),
+		Viewport:    ClassifyScreen(req.ScreenSize),
+		Language:    language,
+		UTMSource:   strings.TrimSpace(req.UTMSource),
+		UTMMedium:   strings.TrimSpace(req.UTMMedium),
+		UTMCampaign: strings.TrimSpace(req.UTMCampaign),
+		Timestamp:   timestamp,
 		DurationSec: req.DurationSec,
-	}
-
-	// Save to database
-	if err := h.store.SaveVisit(visit); err != nil {
-		c.Logger().Errorf("Failed to save visit: %v", err)
-	}
-
-	return c.NoContent(http.StatusNoContent)
+		UserAgent:   userAgent,
+		Weight:      h.store.SampleRate(),
+		Hostname:    host,
+	})
 }
 
 // StatsResponse is the JSON response for stats endpoint.
@@ -162,21 +357,24 @@ type StatsResponse struct {
 	PeriodDays int    `json:"period_days"`
 	Hourly     bool   `json:"hourly"`
 	Monthly    bool   `json:"monthly"`
+	SampleRate int    `json:"sample_rate"` // 1 when every page view is stored
+	Site       string `json:"site"`        // Hostname the stats are filtered by; empty for all sites
 }
 
-// GetStats returns analytics statistics as JSON.
+// GetStats returns analytics statistics as JSON. The site query parameter
+// limits them to the visits recorded on one hostname.
 func (h *Handler) GetStats(c echo.Context) error {
 	_, days, hourly, monthly := parsePeriod(c.QueryParam("period"))
+	site := normalizeHostname(c.QueryParam("site"))
 
 	from, to := periodTimeRange(days, hourly)
 
-	stats, err := h.store.GetStats(from, to, hourly, monthly)
+	stats, err := h.store.GetSiteStats(site, from, to, hourly, monthly)
 	if err != nil {
-		c.Logger().Errorf("Failed to get stats: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return fmt.Errorf("get stats: %w", err)
 	}
 
-	realtime, _ := h.store.GetRealtimeVisitors()
+	realtime, _ := h.store.GetSiteRealtimeVisitors(site)
 
 	return c.JSON(http.StatusOK, StatsResponse{
 		Stats:      stats,
@@ -184,25 +382,44 @@ func (h *Handler) GetStats(c echo.Context) error {
 		PeriodDays: days,
 		Hourly:     hourly,
 		Monthly:    monthly,
+		SampleRate: h.store.SampleRate(),
+		Site:       site,
 	})
 }
 
 // GetStatsFragment returns HTML fragment for visitor stats (talkdom)
 func (h *Handler) GetStatsFragment(c echo.Context) error {
 	_, days, hourly, monthly := parsePeriod(c.QueryParam("period"))
+	site := normalizeHostname(c.QueryParam("site"))
 
 	from, to := periodTimeRange(days, hourly)
 
-	stats, err := h.store.GetStats(from, to, hourly, monthly)
+	stats, err := h.store.GetSiteStats(site, from, to, hourly, monthly)
 	if err != nil {
 		c.Logger().Errorf("Failed to get stats fragment: %v", err)
 		return c.HTML(http.StatusInternalServerError, "<div class='loading'>Error loading data</div>")
 	}
 
-	realtime, _ := h.store.GetRealtimeVisitors()
+	realtime, _ := h.store.GetSiteRealtimeVisitors(site)
+
+	sites, err := h.store.Hostnames()
+	if err != nil {
+		c.Logger().Errorf("Failed to list sites: %v", err)
+	}
+
+	widgets, err := h.store.DashboardWidgets()
+	if err != nil {
+		c.Logger().Errorf("Failed to get dashboard widgets: %v", err)
+		widgets = DefaultWidgetIDs()
+	}
 
 	// Convert to view model
 	statsVM := convertStatsToViewModel(stats)
+	statsVM.Widgets = widgets
+	statsVM.HasGoals = h.store.HasGoals()
+	statsVM.SampleRate = h.store.SampleRate()
+	statsVM.Site = site
+	statsVM.Sites = sites
 
 	// Return only the stats content, not the period selector (to avoid duplication)
 	component := templates.StatsFragmentOnly(statsVM, realtime, days, hourly, monthly)
@@ -225,8 +442,7 @@ func (h *Handler) GetBotStats(c echo.Context) error {
 
 	stats, err := h.store.GetBotStats(from, to, hourly, monthly)
 	if err != nil {
-		c.Logger().Errorf("Failed to get bot stats: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return fmt.Errorf("get bot stats: %w", err)
 	}
 
 	return c.JSON(http.StatusOK, BotStatsResponse{
@@ -259,11 +475,103 @@ func (h *Handler) GetBotStatsFragment(c echo.Context) error {
 
 // GetSetupFragment returns HTML fragment for setup tab (talkdom)
 func (h *Handler) GetSetupFragment(c echo.Context) error {
+	return h.renderSetup(c, "")
+}
+
+// renderSetup renders the setup tab, showing newToken when an API token was
+// just created.
+func (h *Handler) renderSetup(c echo.Context, newToken string) error {
 	origin := c.Scheme() + "://" + c.Request().Host
-	component := templates.SetupContent(origin)
+
+	enabled, err := h.store.DashboardWidgets()
+	if err != nil {
+		c.Logger().Errorf("Failed to get dashboard widgets: %v", err)
+		return c.HTML(http.StatusInternalServerError, "<div class='loading'>Error loading data</div>")
+	}
+	tokens, err := h.store.ListAPITokens()
+	if err != nil {
+		c.Logger().Errorf("Failed to list API tokens: %v", err)
+		return c.HTML(http.StatusInternalServerError, "<div class='loading'>Error loading data</div>")
+	}
+
+	csrfToken, _ := c.Get(middleware.DefaultCSRFConfig.ContextKey).(string)
+	component := templates.SetupContent(origin, widgetOptions(enabled), apiTokenSettings(tokens, newToken), csrfToken)
 	return component.Render(c.Request().Context(), c.Response())
 }
 
+// apiTokenSettings converts the API tokens for the setup tab.
+func apiTokenSettings(tokens []APIToken, newToken string) templates.APITokenSettingsViewModel {
+	vm := templates.APITokenSettingsViewModel{
+		Scopes:   APIScopes,
+		NewToken: newToken,
+		Tokens:   make([]templates.APITokenViewModel, len(tokens)),
+	}
+	for i, t := range tokens {
+		vm.Tokens[i] = templates.APITokenViewModel{
+			ID:        t.ID,
+			Name:      t.Name,
+			Scopes:    strings.Join(t.Scopes, ", "),
+			CreatedAt: t.CreatedAt.Format("2006-01-02"),
+		}
+		if !t.LastUsedAt.IsZero() {
+			vm.Tokens[i].LastUsedAt = t.LastUsedAt.Format("2006-01-02 15:04")
+		}
+	}
+	return vm
+}
+
+// SaveWidgets stores the dashboard widget layout submitted from the setup tab.
+// Checked widgets are kept and ordered by their position field.
+func (h *Handler) SaveWidgets(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+
+	ids := form["widget"]
+	positions := make(map[string]int, len(ids))
+	for _, id := range ids {
+		pos, err := strconv.Atoi(form.Get("position_" + id))
+		if err != nil {
+			pos = len(Widgets)
+		}
+		positions[id] = pos
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return positions[ids[i]] < positions[ids[j]]
+	})
+
+	if err := h.store.SetDashboardWidgets(ids); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/analytics/")
+}
+
+// widgetOptions lists enabled widgets in display order followed by the disabled ones.
+func widgetOptions(enabled []string) []templates.WidgetOptionViewModel {
+	opts := make([]templates.WidgetOptionViewModel, 0, len(Widgets))
+	seen := make(map[string]bool, len(enabled))
+	for _, id := range enabled {
+		seen[id] = true
+	}
+	for _, id := range enabled {
+		for _, w := range Widgets {
+			if w.ID == id {
+				opts = append(opts, templates.WidgetOptionViewModel{ID: w.ID, Title: w.Title, Enabled: true})
+			}
+		}
+	}
+	for _, w := range Widgets {
+		if !seen[w.ID] {
+			opts = append(opts, templates.WidgetOptionViewModel{ID: w.ID, Title: w.Title})
+		}
+	}
+	for i := range opts {
+		opts[i].Position = i + 1
+	}
+	return opts
+}
+
 // parsePeriod parses the period query parameter
 func parsePeriod(period string) (string, int, bool, bool) {
 	var days int
@@ -306,6 +614,12 @@ func convertStatsToViewModel(stats *Stats) *templates.StatsViewModel {
 		AvgDuration:    stats.AvgDuration,
 	}
 
+	if cmp := stats.Comparison; cmp != nil {
+		vm.VisitorsTrend = trendViewModel(cmp.VisitorsChange)
+		vm.ViewsTrend = trendViewModel(cmp.ViewsChange)
+		vm.DurationTrend = trendViewModel(cmp.DurationChange)
+	}
+
 	vm.TopPages = make([]templates.PageStatViewModel, len(stats.TopPages))
 	for i, p := range stats.TopPages {
 		vm.TopPages[i] = templates.PageStatViewModel{
@@ -314,6 +628,22 @@ func convertStatsToViewModel(stats *Stats) *templates.StatsViewModel {
 		}
 	}
 
+	vm.EntryPages = make([]templates.PageStatViewModel, len(stats.EntryPages))
+	for i, p := range stats.EntryPages {
+		vm.EntryPages[i] = templates.PageStatViewModel{
+			Path:  p.Path,
+			Views: p.Views,
+		}
+	}
+
+	vm.ExitPages = make([]templates.PageStatViewModel, len(stats.ExitPages))
+	for i, p := range stats.ExitPages {
+		vm.ExitPages[i] = templates.PageStatViewModel{
+			Path:  p.Path,
+			Views: p.Views,
+		}
+	}
+
 	vm.LatestPages = make([]templates.LatestPageVisitViewModel, len(stats.LatestPages))
 	for i, p := range stats.LatestPages {
 		vm.LatestPages[i] = templates.LatestPageVisitViewModel{
@@ -355,6 +685,45 @@ func convertStatsToViewModel(stats *Stats) *templates.StatsViewModel {
 		}
 	}
 
+	vm.LanguageStats = make([]templates.DimensionStatViewModel, len(stats.LanguageStats))
+	for i, s := range stats.LanguageStats {
+		vm.LanguageStats[i] = templates.DimensionStatViewModel{
+			Name:  s.Name,
+			Count: s.Count,
+		}
+	}
+
+	vm.ViewportStats = make([]templates.DimensionStatViewModel, len(stats.ViewportStats))
+	for i, s := range stats.ViewportStats {
+		vm.ViewportStats[i] = templates.DimensionStatViewModel{
+			Name:  s.Name,
+			Count: s.Count,
+		}
+	}
+
+	vm.Campaigns = make([]templates.CampaignStatViewModel, len(stats.Campaigns))
+	for i, s := range stats.Campaigns {
+		vm.Campaigns[i] = templates.CampaignStatViewModel{
+			Campaign: s.Campaign,
+			Source:   s.Source,
+			Medium:   s.Medium,
+			Visits:   s.Visits,
+		}
+	}
+
+	vm.Acquisition = make([]templates.AcquisitionStatViewModel, len(stats.Acquisition))
+	for i, s := range stats.Acquisition {
+		vm.Acquisition[i] = templates.AcquisitionStatViewModel{
+			Source:         s.Source,
+			Medium:         s.Medium,
+			Campaign:       s.Campaign,
+			Sessions:       s.Sessions,
+			Views:          s.Views,
+			Conversions:    s.Conversions,
+			ConversionRate: s.ConversionRate,
+		}
+	}
+
 	vm.DailyViews = make([]templates.DailyViewViewModel, len(stats.DailyViews))
 	for i, v := range stats.DailyViews {
 		vm.DailyViews[i] = templates.DailyViewViewModel{
@@ -366,6 +735,14 @@ func convertStatsToViewModel(stats *Stats) *templates.StatsViewModel {
 	return vm
 }
 
+// trendViewModel converts a percentage change into a templates.TrendViewModel
+func trendViewModel(change *float64) templates.TrendViewModel {
+	if change == nil {
+		return templates.TrendViewModel{}
+	}
+	return templates.TrendViewModel{Change: *change, HasBaseline: true}
+}
+
 // convertBotStatsToViewModel converts analytics.BotStats to templates.BotStatsViewModel
 func convertBotStatsToViewModel(stats *BotStats) *templates.BotStatsViewModel {
 	vm := &templates.BotStatsViewModel{
@@ -416,9 +793,9 @@ func periodTimeRange(days int, hourly bool) (time.Time, time.Time) {
 	return from, to
 }
 
-// generateSessionID creates a session ID derived from visitor identity and date.
-func generateSessionID(visitorID string) string {
-	day := time.Now().UTC().Format("2006-01-02")
+// generateSessionID creates a session ID derived from visitor identity and the day of t.
+func generateSessionID(visitorID string, t time.Time) string {
+	day := t.UTC().Format("2006-01-02")
 	h := sha256.New()
 	h.Write([]byte(visitorID + "|" + day))
 	return hex.EncodeToString(h.Sum(nil))[:16]
@@ -426,19 +803,33 @@ func generateSessionID(visitorID string) string {
 
 // RegisterRoutes registers analytics routes with the Echo router.
 func (h *Handler) RegisterRoutes(e *echo.Echo, publicGroup *echo.Group, authMiddleware echo.MiddlewareFunc) {
-	// Public endpoint for collecting analytics (with CORS)
+	// Public endpoint for collecting analytics (with CORS for the sites
+	// set by SetSites)
 	publicGroup.POST("/api/analytics/collect", h.Collect)
+	publicGroup.OPTIONS("/api/analytics/collect", h.CollectPreflight)
+
+	// Admin API endpoints (JSON), also readable with an API token
+	api := e.Group("/admin/analytics/api")
+	api.Use(h.APIAuth(authMiddleware))
+	api.GET("/stats", h.GetStats)
+	api.GET("/bot-stats", h.GetBotStats)
+	api.GET("/feed-stats", h.GetFeedStats)
+	api.GET("/shortlink-stats", h.GetShortLinkStats)
 
-	// Admin API endpoints (JSON)
 	admin := e.Group("/admin/analytics")
 	admin.Use(authMiddleware)
-	admin.GET("/api/stats", h.GetStats)
-	admin.GET("/api/bot-stats", h.GetBotStats)
 
 	// Admin fragment endpoints (HTML for talkdom)
 	admin.GET("/fragments/stats", h.GetStatsFragment)
 	admin.GET("/fragments/bot-stats", h.GetBotStatsFragment)
+	admin.GET("/fragments/feed-stats", h.GetFeedStatsFragment)
 	admin.GET("/fragments/setup", h.GetSetupFragment)
+
+	// Admin settings (form POST, CSRF-protected)
+	admin.POST("/widgets/", h.SaveWidgets)
+	admin.POST("/reclassify/", h.Reclassify)
+	admin.POST("/tokens/", h.CreateToken)
+	admin.POST("/tokens/:id/delete/", h.DeleteToken)
 }
 
 // Dashboard renders the analytics dashboard HTML.
@@ -0,0 +1,265 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// postCollect sends body to the collect endpoint of h from the client ip,
+// with a browser user agent and any extra headers.
+func postCollect(t *testing.T, h *Handler, ip, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/analytics/collect", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+	req.Header.Set("X-Real-IP", ip)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	if err := h.Collect(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	return rec
+}
+
+// getStatsJSON fetches the stats API of h for the period.
+func getStatsJSON(t *testing.T, h *Handler, period string) StatsResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/admin/analytics/api/stats?period="+period, nil)
+	rec := httptest.NewRecorder()
+	if err := h.GetStats(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	var resp StatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	return resp
+}
+
+func TestSites(t *testing.T) {
+	store := newTestStore(t)
+
+	e := echo.New()
+	h := NewHandler(store)
+	h.SetSites([]string{"example.com", "Docs.Example.com:443"})
+	collect := func(ip, origin, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/analytics/collect", strings.NewReader(body))
+		req.Host = "example.com"
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+		req.Header.Set("X-Real-IP", ip)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		if err := h.Collect(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+		return rec
+	}
+	collect("10.0.0.1", "", `{"path":"/"}`)
+	collect("10.0.0.2", "", `{"path":"/about/","hostname":"example.com"}`)
+	rec := collect("10.0.0.3", "https://docs.example.com", `{"path":"/guide/"}`)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://docs.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the docs origin", got)
+	}
+	rec = collect("10.0.0.4", "https://evil.test", `{"path":"/","hostname":"evil.test"}`)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an unknown site", got)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/analytics/collect", nil)
+	req.Header.Set("Origin", "https://docs.example.com")
+	rec = httptest.NewRecorder()
+	if err := h.CollectPreflight(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("preflight = %d %v", rec.Code, rec.Header())
+	}
+
+	from, to := time.Now().UTC().Add(-time.Hour), time.Now().UTC().Add(time.Hour)
+	for site, want := range map[string]int{"": 3, "example.com": 2, "docs.example.com": 1, "evil.test": 0} {
+		stats, err := store.GetSiteStats(site, from, to, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.TotalViews != want || stats.UniqueVisitors != want {
+			t.Errorf("site %q: %d views, %d visitors, want %d", site, stats.TotalViews, stats.UniqueVisitors, want)
+		}
+	}
+	hosts, err := store.Hostnames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0] != "example.com" || hosts[1] != "docs.example.com" {
+		t.Errorf("Hostnames() = %v", hosts)
+	}
+
+	// The JSON API filters by the site query parameter.
+	req = httptest.NewRequest(http.MethodGet, "/admin/analytics/api/stats?period=today&site=docs.example.com", nil)
+	rec = httptest.NewRecorder()
+	if err := h.GetStats(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	var resp StatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Site != "docs.example.com" || resp.Stats.TotalViews != 1 {
+		t.Errorf("stats API: site %q, %d views", resp.Site, resp.Stats.TotalViews)
+	}
+}
+
+func TestCollectLanguage(t *testing.T) {
+	h := NewHandler(newTestStore(t))
+	// The language the script reports wins over Accept-Language, which
+	// counts when there is none.
+	postCollect(t, h, "10.0.0.1", `{"path":"/","language":"de-DE"}`, http.Header{"Accept-Language": {"fr"}})
+	postCollect(t, h, "10.0.0.2", `{"path":"/","language":"de"}`, nil)
+	postCollect(t, h, "10.0.0.3", `{"path":"/"}`, http.Header{"Accept-Language": {"de-AT,de;q=0.9,en;q=0.8"}})
+	postCollect(t, h, "10.0.0.4", `{"path":"/"}`, http.Header{"Accept-Language": {"fr-CH, fr;q=0.9"}})
+	postCollect(t, h, "10.0.0.5", `{"path":"/","language":"fr"}`, nil)
+	postCollect(t, h, "10.0.0.6", `{"path":"/","language":"*"}`, nil)
+
+	want := []DimensionStat{{Name: "de", Count: 3}, {Name: "fr", Count: 2}, {Name: "Unknown", Count: 1}}
+	resp := getStatsJSON(t, h, "today")
+	if !reflect.DeepEqual(resp.Stats.LanguageStats, want) {
+		t.Errorf("languages = %+v, want %+v", resp.Stats.LanguageStats, want)
+	}
+}
+
+func TestCollectHeartbeat(t *testing.T) {
+	h := NewHandler(newTestStore(t))
+	postCollect(t, h, "10.0.0.1", `{"path":"/a/"}`, nil)
+	postCollect(t, h, "10.0.0.1", `{"path":"/a/","heartbeat":true,"duration_sec":15}`, nil)
+	// A late heartbeat doesn't shorten the engaged time.
+	postCollect(t, h, "10.0.0.1", `{"path":"/a/","heartbeat":true,"duration_sec":10}`, nil)
+	postCollect(t, h, "10.0.0.1", `{"path":"/a/","heartbeat":true,"duration_sec":45}`, nil)
+	postCollect(t, h, "10.0.0.2", `{"path":"/b/"}`, nil)
+	postCollect(t, h, "10.0.0.2", `{"path":"/b/","duration_sec":15}`, nil)
+	// Heartbeats for a page that was never recorded are dropped.
+	postCollect(t, h, "10.0.0.3", `{"path":"/c/","heartbeat":true,"duration_sec":600}`, nil)
+
+	stats := getStatsJSON(t, h, "today").Stats
+	if stats.TotalViews != 2 || stats.UniqueVisitors != 2 {
+		t.Errorf("%d views, %d visitors, want 2 and 2", stats.TotalViews, stats.UniqueVisitors)
+	}
+	if stats.AvgDuration != 30 {
+		t.Errorf("AvgDuration = %d, want 30", stats.AvgDuration)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/analytics/collect", strings.NewReader(`{"path":"/a/","heartbeat":true,"duration_sec":-1}`))
+	err := h.Collect(echo.New().NewContext(req, httptest.NewRecorder()))
+	if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusBadRequest {
+		t.Errorf("negative duration: got %v, want a 400 error", err)
+	}
+}
+
+func TestCollectCampaigns(t *testing.T) {
+	h := NewHandler(newTestStore(t))
+	postCollect(t, h, "10.0.0.1", `{"path":"/","utm_source":"newsletter","utm_medium":"email","utm_campaign":"launch"}`, nil)
+	postCollect(t, h, "10.0.0.2", `{"path":"/","utm_source":" newsletter ","utm_medium":"email","utm_campaign":"launch"}`, nil)
+	postCollect(t, h, "10.0.0.3", `{"path":"/","utm_source":"mastodon","utm_campaign":"launch"}`, nil)
+	postCollect(t, h, "10.0.0.4", `{"path":"/"}`, nil)
+
+	want := []CampaignStat{
+		{Campaign: "launch", Source: "newsletter", Medium: "email", Visits: 2},
+		{Campaign: "launch", Source: "mastodon", Visits: 1},
+	}
+	if got := getStatsJSON(t, h, "today").Stats.Campaigns; !reflect.DeepEqual(got, want) {
+		t.Errorf("campaigns = %+v, want %+v", got, want)
+	}
+}
+
+func TestCollectBatch(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store)
+	queued := time.Now().Add(-10 * time.Minute).UnixMilli()
+	body := fmt.Sprintf(`[
+		{"path":"/a/","ts":%d},
+		{"path":"/a/","duration_sec":40,"ts":%d},
+		{"path":"/b/"},
+		{"path":"/old/","ts":%d},
+		{"path":"/`+strings.Repeat("x", maxPathLen)+`"}
+	]`, queued, queued, time.Now().Add(-48*time.Hour).UnixMilli())
+	if rec := postCollect(t, h, "10.0.0.1", body, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want 204", rec.Code)
+	}
+
+	// The invalid events were dropped and the rest saved, the queued page
+	// view at the time it happened.
+	stats := getStatsJSON(t, h, "today").Stats
+	if stats.TotalViews != 2 || stats.AvgDuration != 40 {
+		t.Errorf("%d views, %ds average, want 2 views and 40s", stats.TotalViews, stats.AvgDuration)
+	}
+	if len(stats.LatestPages) != 2 || stats.LatestPages[1].Path != "/a/" ||
+		stats.LatestPages[1].Timestamp != time.UnixMilli(queued).UTC().Format("2006-01-02 15:04:05") {
+		t.Errorf("LatestPages = %+v, want /a/ at the queued time", stats.LatestPages)
+	}
+
+	e := echo.New()
+	for name, body := range map[string]string{
+		"all invalid": `[{"path":"/","duration_sec":-1},{"path":"/","ts":1}]`,
+		"too many":    "[" + strings.Repeat(`{"path":"/"},`, maxBatchSize) + `{"path":"/"}]`,
+		"malformed":   `[{"path":"/"`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/analytics/collect", strings.NewReader(body))
+		req.Header.Set("X-Real-IP", "10.0.0.2")
+		err := h.Collect(e.NewContext(req, httptest.NewRecorder()))
+		if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %v, want a 400 error", name, err)
+		}
+	}
+	if views := getStatsJSON(t, h, "today").Stats.TotalViews; views != 2 {
+		t.Errorf("rejected batches saved views: %d, want 2", views)
+	}
+}
+
+func TestCollectViewport(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store)
+	postCollect(t, h, "10.0.0.1", `{"path":"/","screen_size":"1918x1051"}`, nil)
+	postCollect(t, h, "10.0.0.2", `{"path":"/","screen_size":"1920x1080"}`, nil)
+	postCollect(t, h, "10.0.0.3", `{"path":"/","screen_size":"390x844"}`, nil)
+	postCollect(t, h, "10.0.0.4", `{"path":"/"}`, nil)
+	// Visits stored before viewports were classified get one on migration.
+	if err := store.SaveVisit(&Visit{VisitorID: "old", Path: "/", ScreenSize: "2048x1152", Timestamp: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.backfillViewports(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DimensionStat{{Name: "desktop", Count: 3}, {Name: "mobile", Count: 1}, {Name: "Unknown", Count: 1}}
+	if got := getStatsJSON(t, h, "today").Stats.ViewportStats; !reflect.DeepEqual(got, want) {
+		t.Errorf("viewports = %+v, want %+v", got, want)
+	}
+	// The raw screen size is kept.
+	var sizes []string
+	rows, err := store.db.Query(`SELECT screen_size FROM visits WHERE viewport = 'desktop' ORDER BY screen_size`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, s)
+	}
+	if want := []string{"1918x1051", "1920x1080", "2048x1152"}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("screen sizes = %v, want %v", sizes, want)
+	}
+}
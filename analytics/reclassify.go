@@ -0,0 +1,137 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+	"github.com/labstack/echo/v4"
+)
+
+// Reclassification counts the rows moved or renamed by ReclassifyVisits.
+type Reclassification struct {
+	ToBots     int `json:"to_bots"`     // Visits now recognised as bots, moved to bot_visits
+	ToHumans   int `json:"to_humans"`   // Bot visits no longer recognised as bots, moved to visits
+	BotRenamed int `json:"bot_renamed"` // Bot visits whose bot name changed
+}
+
+// ReclassifyVisits checks the visits and bot visits since the given time
+// against the current IsBot and ExtractBotName patterns, and moves rows that
+// are now misclassified between the visits and bot_visits tables in one
+// transaction. Stats are computed from these tables, so they reflect the
+// change right away. Visits recorded before user agents were stored can't
+// be checked and are left alone.
+//
+// Visits moved back from bot_visits have no referrer, screen size or
+// language, and a visitor ID derived from the hashed IP, so they count as
+// visitors of their own.
+func (s *Store) ReclassifyVisits(since time.Time) (Reclassification, error) {
+	var res Reclassification
+	ctx := context.Background()
+	since = since.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return res, fmt.Errorf("begin reclassify: %w", err)
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	visits, err := q.RecentVisitAgents(ctx, since)
+	if err != nil {
+		return res, fmt.Errorf("list visits: %w", err)
+	}
+	bots, err := q.RecentBotVisits(ctx, since)
+	if err != nil {
+		return res, fmt.Errorf("list bot visits: %w", err)
+	}
+
+	for _, v := range visits {
+		if !IsBot(v.UserAgent) {
+			continue
+		}
+		if err := q.InsertBotVisit(ctx, sqlcgen.InsertBotVisitParams{
+			BotName:   ExtractBotName(v.UserAgent),
+			IpHash:    v.IpHash,
+			UserAgent: v.UserAgent,
+			Path:      v.Path,
+			Timestamp: v.Timestamp.UTC(),
+		}); err != nil {
+			return res, fmt.Errorf("insert bot visit: %w", err)
+		}
+		if err := q.DeleteVisit(ctx, v.ID); err != nil {
+			return res, fmt.Errorf("delete visit: %w", err)
+		}
+		res.ToBots++
+	}
+
+	for _, bv := range bots {
+		if IsBot(bv.UserAgent) {
+			if name := ExtractBotName(bv.UserAgent); name != bv.BotName {
+				if err := q.UpdateBotVisitName(ctx, name, bv.ID); err != nil {
+					return res, fmt.Errorf("rename bot visit: %w", err)
+				}
+				res.BotRenamed++
+			}
+			continue
+		}
+		browser, os, device := ParseUserAgent(bv.UserAgent)
+		visitorID := GenerateVisitorID(bv.IpHash, bv.UserAgent)
+		if err := q.InsertVisit(ctx, visitParams(&Visit{
+			VisitorID: visitorID,
+			SessionID: generateSessionID(visitorID, bv.Timestamp),
+			IPHash:    bv.IpHash,
+			Browser:   browser,
+			OS:        os,
+			Device:    device,
+			Path:      bv.Path,
+			Timestamp: bv.Timestamp,
+			UserAgent: bv.UserAgent,
+		})); err != nil {
+			return res, fmt.Errorf("insert visit: %w", err)
+		}
+		if err := q.DeleteBotVisit(ctx, bv.ID); err != nil {
+			return res, fmt.Errorf("delete bot visit: %w", err)
+		}
+		res.ToHumans++
+	}
+
+	// Sessions follow their visits: drop the ones left without any and
+	// recompute the first touch of the rest.
+	if res.ToBots > 0 || res.ToHumans > 0 {
+		if err := q.DeleteOrphanSessions(ctx); err != nil {
+			return res, fmt.Errorf("delete orphan sessions: %w", err)
+		}
+		if err := q.RebuildSessions(ctx, since); err != nil {
+			return res, fmt.Errorf("rebuild sessions: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, fmt.Errorf("commit reclassify: %w", err)
+	}
+	return res, nil
+}
+
+// Reclassify re-checks the visits of the last ?days= days (default 30, at
+// most 365) against the current bot patterns, see ReclassifyVisits. It
+// answers JSON with the counts, or redirects back to the dashboard for the
+// form on the setup tab.
+func (h *Handler) Reclassify(c echo.Context) error {
+	days := 30
+	if d, err := strconv.Atoi(c.FormValue("days")); err == nil && d > 0 && d <= 365 {
+		days = d
+	}
+	res, err := h.store.ReclassifyVisits(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.Logger().Errorf("Failed to reclassify visits: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reclassify visits")
+	}
+	if c.Request().Header.Get(echo.HeaderAccept) == echo.MIMEApplicationJSON {
+		return c.JSON(http.StatusOK, res)
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/analytics/")
+}
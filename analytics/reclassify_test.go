@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReclassifyVisits(t *testing.T) {
+	store := newTestStore(t)
+
+	now := time.Now().UTC()
+	// Rows as an older build would have classified them: a crawler counted
+	// as a visitor and a browser counted as a bot.
+	const crawlerUA = "Mozilla/5.0 (compatible; ExampleSpider/1.0)"
+	const browserUA = "Mozilla/5.0 (Windows NT 10.0) Firefox/120.0"
+	if err := store.SaveVisit(&Visit{VisitorID: "v1", Path: "/", Timestamp: now, UserAgent: crawlerUA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVisit(&Visit{VisitorID: "v2", Path: "/", Timestamp: now, UserAgent: browserUA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveVisit(&Visit{VisitorID: "v3", Path: "/", Timestamp: now.AddDate(0, 0, -60), UserAgent: crawlerUA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveBotVisit(&BotVisit{BotName: "Other Bot", Path: "/about/", Timestamp: now, UserAgent: browserUA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveBotVisit(&BotVisit{BotName: "Other Bot", Path: "/", Timestamp: now, UserAgent: "Googlebot/2.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := store.ReclassifyVisits(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("ReclassifyVisits: %v", err)
+	}
+	if want := (Reclassification{ToBots: 1, ToHumans: 1, BotRenamed: 1}); res != want {
+		t.Errorf("ReclassifyVisits = %+v, want %+v", res, want)
+	}
+	from, to := now.Add(-time.Hour), now.Add(time.Hour)
+	stats, err := store.GetStats(from, to, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bots, err := store.GetBotStats(from, to, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalViews != 2 || bots.TotalVisits != 2 {
+		t.Errorf("after reclassifying: %d views, %d bot visits, want 2 and 2", stats.TotalViews, bots.TotalVisits)
+	}
+	if res, _ := store.ReclassifyVisits(now.AddDate(0, 0, -30)); res != (Reclassification{}) {
+		t.Errorf("second run = %+v, want nothing to do", res)
+	}
+}
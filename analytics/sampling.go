@@ -0,0 +1,206 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+)
+
+// sketchDay is the layout of the day a visitor sketch counts.
+const sketchDay = "2006-01-02"
+
+// sketchKey names the sketch of the visitors of one hostname on one day.
+type sketchKey struct {
+	day      string
+	hostname string
+}
+
+// SetSampleRate turns on sampling for sites with too much traffic to store
+// every page view: only the page views of 1 in n visitors are stored, and
+// reports scale each stored page view up by n. Whole visitors are sampled
+// so sessions, durations and experiments stay consistent. Unique visitors
+// are still counted from all traffic, in a HyperLogLog sketch per UTC day
+// kept in memory and written by FlushSketches. A rate below 2 stores every
+// page view. Call it before serving.
+func (s *Store) SetSampleRate(n int) {
+	if n < 2 {
+		n = 1
+	}
+	s.sampleRate = n
+}
+
+// SampleRate returns the number of visitors each stored visitor stands
+// for, 1 when sampling is off.
+func (s *Store) SampleRate() int {
+	if s.sampleRate < 1 {
+		return 1
+	}
+	return s.sampleRate
+}
+
+// Sampled reports whether the page views of the visitor are stored. The
+// choice depends only on the visitor ID, so it is the same for every page
+// view and every process.
+func (s *Store) Sampled(visitorID string) bool {
+	rate := s.SampleRate()
+	if rate == 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(visitorID))
+	return h.Sum32()%uint32(rate) == 0
+}
+
+// countVisitor adds the visitor to the sketch of the hostname on the day
+// of t when sampling is on. It only touches memory; FlushSketches writes
+// the sketches to the database.
+func (s *Store) countVisitor(visitorID, hostname string, t time.Time) {
+	if s.SampleRate() == 1 {
+		return
+	}
+	key := sketchKey{day: t.UTC().Format(sketchDay), hostname: hostname}
+	s.sketchMu.Lock()
+	defer s.sketchMu.Unlock()
+	if s.sketches == nil {
+		s.sketches = make(map[sketchKey]sketch)
+	}
+	sk, ok := s.sketches[key]
+	if !ok {
+		sk = newSketch()
+		s.sketches[key] = sk
+	}
+	sk.add(visitorID)
+}
+
+// FlushSketches merges the visitors counted since the last flush into the
+// stored sketches. Close calls it, and StartSketchFlusher runs it
+// periodically.
+func (s *Store) FlushSketches() error {
+	s.sketchMu.Lock()
+	pending := s.sketches
+	s.sketches = nil
+	s.sketchMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := s.saveSketches(pending); err != nil {
+		// Keep the visitors for the next flush; merging is idempotent.
+		s.sketchMu.Lock()
+		if s.sketches == nil {
+			s.sketches = make(map[sketchKey]sketch)
+		}
+		for key, sk := range pending {
+			if cur, ok := s.sketches[key]; ok {
+				sk.merge(cur)
+			}
+			s.sketches[key] = sk
+		}
+		s.sketchMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// saveSketches merges the given sketches into the stored ones.
+func (s *Store) saveSketches(pending map[sketchKey]sketch) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin sketch flush: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := s.q.WithTx(tx)
+	for key, sk := range pending {
+		stored, err := q.GetVisitorSketch(ctx, key.day, key.hostname)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("get visitor sketch: %w", err)
+		}
+		merged := loadSketch(stored)
+		merged.merge(sk)
+		if err := q.UpsertVisitorSketch(ctx, sqlcgen.UpsertVisitorSketchParams{
+			Day:       key.day,
+			Hostname:  key.hostname,
+			Registers: merged,
+		}); err != nil {
+			return fmt.Errorf("save visitor sketch: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// StartSketchFlusher writes the visitor sketches every interval. Returns a
+// stop function.
+func (s *Store) StartSketchFlusher(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.FlushSketches(); err != nil {
+					fmt.Printf("sketch flush error: %v\n", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// uniqueVisitors counts the distinct visitors of the period on the site,
+// or on all sites when site is empty. Sketches are kept per hostname, and
+// merging them counts a visitor of several sites once. Without
+// sketches it counts the stored visits exactly. Once the period overlaps
+// days with sketches, it merges those with the visitors of the stored
+// visits, which covers days from before sampling was turned on. Sketches
+// count whole days, so a period starting or ending mid-day may include
+// some visitors from outside it.
+func (s *Store) uniqueVisitors(ctx context.Context, site string, from, to time.Time) (int, error) {
+	fromDay := from.UTC().Format(sketchDay)
+	toDay := to.UTC().Add(-time.Second).Format(sketchDay)
+	stored, err := s.q.VisitorSketches(ctx, sqlcgen.VisitorSketchesParams{
+		FromDay: fromDay,
+		ToDay:   toDay,
+		Site:    site,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("visitor sketches: %w", err)
+	}
+
+	merged := newSketch()
+	found := len(stored) > 0
+	for _, data := range stored {
+		merged.merge(loadSketch(data))
+	}
+	s.sketchMu.Lock()
+	for key, sk := range s.sketches {
+		if key.day >= fromDay && key.day <= toDay && (site == "" || key.hostname == site) {
+			merged.merge(sk)
+			found = true
+		}
+	}
+	s.sketchMu.Unlock()
+
+	if !found {
+		count, err := s.q.CountUniqueVisitors(ctx, sqlcgen.CountUniqueVisitorsParams{FromTime: from, ToTime: to, Site: site})
+		return int(count), err
+	}
+	ids, err := s.q.DistinctVisitorIDs(ctx, sqlcgen.DistinctVisitorIDsParams{FromTime: from, ToTime: to, Site: site})
+	if err != nil {
+		return 0, fmt.Errorf("distinct visitor ids: %w", err)
+	}
+	for _, id := range ids {
+		merged.add(id)
+	}
+	return merged.estimate(), nil
+}
@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestSampling(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "analytics.db")
+	store, err := NewStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetSampleRate(10)
+
+	e := echo.New()
+	h := NewHandler(store)
+	const visitors = 1000
+	for i := range visitors {
+		req := httptest.NewRequest(http.MethodPost, "/api/analytics/collect", strings.NewReader(`[{"path":"/"},{"path":"/about/"}]`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+		req.Header.Set("X-Real-IP", fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+		rec := httptest.NewRecorder()
+		if err := h.Collect(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+	}
+
+	from, to := time.Now().UTC().Add(-time.Hour), time.Now().UTC().Add(time.Hour)
+	check := func(store *Store) {
+		t.Helper()
+		stats, err := store.GetStats(from, to, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Unique visitors come from the sketch of all traffic, page views
+		// from 1 in 10 visitors scaled up.
+		if stats.UniqueVisitors < visitors*98/100 || stats.UniqueVisitors > visitors*102/100 {
+			t.Errorf("UniqueVisitors = %d, want about %d", stats.UniqueVisitors, visitors)
+		}
+		if stats.TotalViews%20 != 0 || stats.TotalViews < visitors || stats.TotalViews > 3*visitors {
+			t.Errorf("TotalViews = %d, want a multiple of 20 near %d", stats.TotalViews, 2*visitors)
+		}
+	}
+	check(store)
+
+	// Close writes the sketch, so the count survives a restart.
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	check(store)
+}
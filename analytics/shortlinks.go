@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+	"github.com/labstack/echo/v4"
+)
+
+// ShortLinkHit represents one follow of a post's short link (/s/:code).
+type ShortLinkHit struct {
+	Code      string    `json:"code"`
+	Slug      string    `json:"slug"`
+	IPHash    string    `json:"-"`
+	Referrer  string    `json:"referrer"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ShortLinkStat holds the hits of one short link over a period.
+type ShortLinkStat struct {
+	Code     string `json:"code"`
+	Slug     string `json:"slug"`
+	Hits     int    `json:"hits"`
+	Visitors int    `json:"visitors"` // Distinct IP hashes
+}
+
+// SaveShortLinkHit stores a new short link hit.
+func (s *Store) SaveShortLinkHit(h *ShortLinkHit) error {
+	return s.q.InsertShortLinkHit(context.Background(), sqlcgen.InsertShortLinkHitParams{
+		Code:      h.Code,
+		Slug:      h.Slug,
+		IpHash:    h.IPHash,
+		Referrer:  h.Referrer,
+		Timestamp: h.Timestamp.UTC(),
+	})
+}
+
+// GetShortLinkStats returns hits per short link in the given time range, most
+// followed first.
+func (s *Store) GetShortLinkStats(from, to time.Time) ([]ShortLinkStat, error) {
+	rows, err := s.q.ShortLinkStats(context.Background(), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("short link stats: %w", err)
+	}
+	stats := make([]ShortLinkStat, len(rows))
+	for i, r := range rows {
+		stats[i] = ShortLinkStat{
+			Code:     r.Code,
+			Slug:     r.Slug,
+			Hits:     int(r.Hits),
+			Visitors: int(r.Visitors),
+		}
+	}
+	return stats, nil
+}
+
+// ShortLinkStatsResponse is the JSON response for the short link stats endpoint.
+type ShortLinkStatsResponse struct {
+	Links      []ShortLinkStat `json:"links"`
+	PeriodDays int             `json:"period_days"`
+}
+
+// GetShortLinkStats returns short link statistics as JSON.
+func (h *Handler) GetShortLinkStats(c echo.Context) error {
+	_, days, hourly, _ := parsePeriod(c.QueryParam("period"))
+
+	from, to := periodTimeRange(days, hourly)
+
+	links, err := h.store.GetShortLinkStats(from, to)
+	if err != nil {
+		return fmt.Errorf("get short link stats: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, ShortLinkStatsResponse{
+		Links:      links,
+		PeriodDays: days,
+	})
+}
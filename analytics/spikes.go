@@ -0,0 +1,155 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+)
+
+// ReferrerSpike is a burst of visits from a referrer that sent no traffic
+// before, such as a post reaching Hacker News or Reddit.
+type ReferrerSpike struct {
+	Referrer string    `json:"referrer"` // Referrer domain, e.g. "news.ycombinator.com"
+	Path     string    `json:"path"`     // Page most of the visits landed on
+	Visits   int       `json:"visits"`
+	Visitors int       `json:"visitors"` // Distinct visitor IDs
+	Since    time.Time `json:"since"`    // Start of the detection window
+}
+
+// Message returns a one-line description of the spike for notifications.
+func (sp ReferrerSpike) Message() string {
+	return fmt.Sprintf("%d visits from %s to %s since %s", sp.Visits, sp.Referrer, sp.Path, sp.Since.Format("15:04 MST"))
+}
+
+// SpikeConfig configures referrer spike detection.
+type SpikeConfig struct {
+	Threshold int                       // Visits within Window that make a spike (default 50)
+	Window    time.Duration             // Detection window (default 1 hour)
+	Lookback  time.Duration             // How long a referrer must have been absent to count as new (default 30 days)
+	Interval  time.Duration             // How often to check (default 5 minutes)
+	Notify    func(ReferrerSpike) error // Called once per spike; failed notifications are retried on the next check
+}
+
+func (cfg *SpikeConfig) setDefaults() {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 50
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Hour
+	}
+	if cfg.Lookback <= 0 {
+		cfg.Lookback = 30 * 24 * time.Hour
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+}
+
+// DetectReferrerSpikes returns the referrers with at least cfg.Threshold
+// visits in the cfg.Window before now that sent no visits in the cfg.Lookback
+// before that and haven't been alerted yet, most visits first.
+func (s *Store) DetectReferrerSpikes(now time.Time, cfg SpikeConfig) ([]ReferrerSpike, error) {
+	cfg.setDefaults()
+	ctx := context.Background()
+	since := now.UTC().Add(-cfg.Window)
+	rows, err := s.q.ReferrerSpikes(ctx, sqlcgen.ReferrerSpikesParams{
+		Since:      since,
+		KnownSince: since.Add(-cfg.Lookback),
+		Threshold:  int64(cfg.Threshold),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("referrer spikes: %w", err)
+	}
+	spikes := make([]ReferrerSpike, len(rows))
+	for i, r := range rows {
+		path, err := s.q.TopReferredPath(ctx, r.Referrer, since)
+		if err != nil {
+			return nil, fmt.Errorf("top referred path: %w", err)
+		}
+		spikes[i] = ReferrerSpike{
+			Referrer: r.Referrer.String,
+			Path:     path,
+			Visits:   int(r.Visits),
+			Visitors: int(r.Visitors),
+			Since:    since,
+		}
+	}
+	return spikes, nil
+}
+
+// MarkReferrerAlerted records that a spike from referrer was notified, so
+// DetectReferrerSpikes doesn't report it again.
+func (s *Store) MarkReferrerAlerted(referrer string, at time.Time) error {
+	return s.q.InsertReferrerAlert(context.Background(), referrer, at.UTC())
+}
+
+// StartSpikeDetector checks for referrer spikes every cfg.Interval and calls
+// cfg.Notify for each new one. Returns a stop function.
+func (s *Store) StartSpikeDetector(cfg SpikeConfig) func() {
+	cfg.setDefaults()
+	ticker := time.NewTicker(cfg.Interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.notifySpikes(time.Now(), cfg); err != nil {
+					fmt.Printf("spike detector error: %v\n", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// notifySpikes notifies and marks each spike detected at now.
+func (s *Store) notifySpikes(now time.Time, cfg SpikeConfig) error {
+	spikes, err := s.DetectReferrerSpikes(now, cfg)
+	if err != nil {
+		return err
+	}
+	for _, sp := range spikes {
+		if err := cfg.Notify(sp); err != nil {
+			return fmt.Errorf("notify spike from %s: %w", sp.Referrer, err)
+		}
+		if err := s.MarkReferrerAlerted(sp.Referrer, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookNotifier returns a SpikeConfig.Notify function that POSTs each
+// spike as JSON to url. The "text" field holds the Message, which chat
+// webhooks such as Slack's display as is.
+func WebhookNotifier(url string) func(ReferrerSpike) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(sp ReferrerSpike) error {
+		body, err := json.Marshal(struct {
+			Text string `json:"text"`
+			ReferrerSpike
+		}{sp.Message(), sp})
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+}
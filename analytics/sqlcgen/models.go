@@ -9,6 +9,15 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	ID         int64
+	Name       string
+	TokenHash  string
+	Scopes     string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
 type BotVisit struct {
 	ID        int64
 	BotName   string
@@ -18,11 +27,56 @@ type BotVisit struct {
 	Timestamp time.Time
 }
 
+type ExperimentExposure struct {
+	ID         int64
+	Experiment string
+	Variant    string
+	VisitorID  string
+	Timestamp  time.Time
+}
+
+type FeedHit struct {
+	ID          int64
+	Path        string
+	Reader      string
+	Subscribers int64
+	IpHash      string
+	UserAgent   string
+	Timestamp   time.Time
+}
+
+type ReferrerAlert struct {
+	Referrer  string
+	Timestamp time.Time
+}
+
+type Session struct {
+	SessionID   string
+	VisitorID   string
+	StartedAt   time.Time
+	LandingPath string
+	Referrer    string
+	UtmSource   string
+	UtmMedium   string
+	UtmCampaign string
+	Weight      int64
+	Hostname    string
+}
+
 type Setting struct {
 	Key   string
 	Value string
 }
 
+type ShortLinkHit struct {
+	ID        int64
+	Code      string
+	Slug      string
+	IpHash    string
+	Referrer  string
+	Timestamp time.Time
+}
+
 type Visit struct {
 	ID          int64
 	VisitorID   string
@@ -36,4 +90,18 @@ type Visit struct {
 	ScreenSize  sql.NullString
 	Timestamp   time.Time
 	DurationSec sql.NullInt64
+	Language    string
+	UtmSource   string
+	UtmMedium   string
+	UtmCampaign string
+	Viewport    string
+	UserAgent   string
+	Weight      int64
+	Hostname    string
+}
+
+type VisitorSketch struct {
+	Day       string
+	Hostname  string
+	Registers []byte
 }
@@ -11,39 +11,92 @@ import (
 )
 
 type Querier interface {
-	AvgDuration(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (sql.NullFloat64, error)
-	BrowserStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]BrowserStatsRow, error)
+	AcquisitionStats(ctx context.Context, arg AcquisitionStatsParams) ([]AcquisitionStatsRow, error)
+	AvgDuration(ctx context.Context, arg AvgDurationParams) (sql.NullFloat64, error)
+	BrowserStats(ctx context.Context, arg BrowserStatsParams) ([]BrowserStatsRow, error)
+	CampaignStats(ctx context.Context, arg CampaignStatsParams) ([]CampaignStatsRow, error)
 	// Bot aggregations
 	CountBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error)
+	CountFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error)
 	// Realtime
-	CountRealtimeVisitors(ctx context.Context, timestamp time.Time) (int64, error)
-	CountUniqueVisitors(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error)
+	CountRealtimeVisitors(ctx context.Context, since time.Time, site string) (int64, error)
+	CountUniqueVisitors(ctx context.Context, arg CountUniqueVisitorsParams) (int64, error)
 	// Visitor aggregations
-	CountVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error)
+	CountVisits(ctx context.Context, arg CountVisitsParams) (int64, error)
+	// API tokens
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) error
 	DailyBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DailyBotVisitsRow, error)
-	DailyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DailyViewsRow, error)
+	DailyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DailyFeedHitsRow, error)
+	DailyViews(ctx context.Context, arg DailyViewsParams) ([]DailyViewsRow, error)
+	DeleteAPIToken(ctx context.Context, id int64) error
+	DeleteBotVisit(ctx context.Context, id int64) error
 	DeleteOldBotVisits(ctx context.Context, timestamp time.Time) error
+	DeleteOldExposures(ctx context.Context, timestamp time.Time) error
+	DeleteOldFeedHits(ctx context.Context, timestamp time.Time) error
+	DeleteOldReferrerAlerts(ctx context.Context, timestamp time.Time) error
+	DeleteOldSessions(ctx context.Context, startedAt time.Time) error
+	DeleteOldShortLinkHits(ctx context.Context, timestamp time.Time) error
 	// Cleanup
+	DeleteOldVisitorSketches(ctx context.Context, day string) error
 	DeleteOldVisits(ctx context.Context, timestamp time.Time) error
-	DeviceStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DeviceStatsRow, error)
+	DeleteOrphanSessions(ctx context.Context) error
+	DeleteVisit(ctx context.Context, id int64) error
+	DeviceStats(ctx context.Context, arg DeviceStatsParams) ([]DeviceStatsRow, error)
+	DistinctVisitorIDs(ctx context.Context, arg DistinctVisitorIDsParams) ([]string, error)
+	EntryPages(ctx context.Context, arg EntryPagesParams) ([]EntryPagesRow, error)
+	ExitPages(ctx context.Context, arg ExitPagesParams) ([]ExitPagesRow, error)
+	ExperimentResults(ctx context.Context, goalPath string, experiment string) ([]ExperimentResultsRow, error)
+	FeedReaderStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]FeedReaderStatsRow, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error)
 	// Settings
 	GetSetting(ctx context.Context, key string) (string, error)
+	// Unique visitor sketches (sampling mode)
+	GetVisitorSketch(ctx context.Context, day string, hostname string) ([]byte, error)
 	HourlyBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]HourlyBotVisitsRow, error)
-	HourlyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]HourlyViewsRow, error)
+	HourlyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]HourlyFeedHitsRow, error)
+	HourlyViews(ctx context.Context, arg HourlyViewsParams) ([]HourlyViewsRow, error)
 	InsertBotVisit(ctx context.Context, arg InsertBotVisitParams) error
+	// Experiments
+	InsertExposure(ctx context.Context, arg InsertExposureParams) error
+	// Feed and API aggregations
+	InsertFeedHit(ctx context.Context, arg InsertFeedHitParams) error
+	InsertReferrerAlert(ctx context.Context, referrer string, timestamp time.Time) error
+	// Short links
+	InsertShortLinkHit(ctx context.Context, arg InsertShortLinkHitParams) error
 	// Inserts
 	InsertVisit(ctx context.Context, arg InsertVisitParams) error
-	LatestPages(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]LatestPagesRow, error)
+	LanguageStats(ctx context.Context, arg LanguageStatsParams) ([]LanguageStatsRow, error)
+	LatestPages(ctx context.Context, arg LatestPagesParams) ([]LatestPagesRow, error)
+	ListAPITokens(ctx context.Context) ([]ApiToken, error)
+	// Sites
+	ListHostnames(ctx context.Context, timestamp time.Time) ([]string, error)
 	MonthlyBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyBotVisitsRow, error)
-	MonthlyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyViewsRow, error)
-	OSStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]OSStatsRow, error)
-	ReferrerStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]ReferrerStatsRow, error)
+	MonthlyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyFeedHitsRow, error)
+	MonthlyViews(ctx context.Context, arg MonthlyViewsParams) ([]MonthlyViewsRow, error)
+	OSStats(ctx context.Context, arg OSStatsParams) ([]OSStatsRow, error)
+	RebuildSessions(ctx context.Context, timestamp time.Time) error
+	RecentBotVisits(ctx context.Context, timestamp time.Time) ([]BotVisit, error)
+	// Reclassification
+	RecentVisitAgents(ctx context.Context, timestamp time.Time) ([]RecentVisitAgentsRow, error)
+	// Referrer spikes
+	ReferrerSpikes(ctx context.Context, arg ReferrerSpikesParams) ([]ReferrerSpikesRow, error)
+	ReferrerStats(ctx context.Context, arg ReferrerStatsParams) ([]ReferrerStatsRow, error)
+	ShortLinkStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]ShortLinkStatsRow, error)
 	TopBotPages(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopBotPagesRow, error)
 	TopBots(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopBotsRow, error)
-	TopPages(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopPagesRow, error)
-	// Duration update
+	TopFeedPaths(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopFeedPathsRow, error)
+	TopPages(ctx context.Context, arg TopPagesParams) ([]TopPagesRow, error)
+	TopReferredPath(ctx context.Context, referrer sql.NullString, timestamp time.Time) (string, error)
+	TouchAPIToken(ctx context.Context, lastUsedAt sql.NullTime, id int64) error
+	UpdateBotVisitName(ctx context.Context, botName string, id int64) error
+	// Duration update (heartbeats and unload beacons only ever extend the duration)
 	UpdateVisitDuration(ctx context.Context, arg UpdateVisitDurationParams) error
+	// Acquisition (first touch of each session)
+	UpsertSession(ctx context.Context, arg UpsertSessionParams) error
 	UpsertSetting(ctx context.Context, key string, value string) error
+	UpsertVisitorSketch(ctx context.Context, arg UpsertVisitorSketchParams) error
+	ViewportStats(ctx context.Context, arg ViewportStatsParams) ([]ViewportStatsRow, error)
+	VisitorSketches(ctx context.Context, arg VisitorSketchesParams) ([][]byte, error)
 }
 
 var _ Querier = (*Queries)(nil)
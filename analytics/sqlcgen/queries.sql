@@ -10,8 +10,8 @@ ON CONFLICT(key) DO UPDATE SET value = excluded.value;
 -- Inserts
 
 -- name: InsertVisit :exec
-INSERT INTO visits (visitor_id, session_id, ip_hash, browser, os, device, path, referrer, screen_size, timestamp, duration_sec)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO visits (visitor_id, session_id, ip_hash, browser, os, device, path, referrer, screen_size, viewport, timestamp, duration_sec, language, utm_source, utm_medium, utm_campaign, user_agent, weight, hostname)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: InsertBotVisit :exec
 INSERT INTO bot_visits (bot_name, ip_hash, user_agent, path, timestamp)
@@ -20,18 +20,45 @@ VALUES (?, ?, ?, ?, ?);
 -- Visitor aggregations
 
 -- name: CountVisits :one
-SELECT COUNT(*) FROM visits WHERE timestamp >= ? AND timestamp < ?;
+SELECT CAST(COALESCE(SUM(weight), 0) AS INTEGER) FROM visits WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site));
 
 -- name: CountUniqueVisitors :one
-SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= ? AND timestamp < ?;
+SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site));
+
+-- name: DistinctVisitorIDs :many
+SELECT DISTINCT visitor_id FROM visits WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site));
 
 -- name: AvgDuration :one
-SELECT AVG(duration_sec) FROM visits WHERE timestamp >= ? AND timestamp < ? AND duration_sec > 0;
+SELECT AVG(duration_sec) FROM visits WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site)) AND duration_sec > 0;
 
 -- name: TopPages :many
-SELECT path, COUNT(*) AS views
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10;
+
+-- name: EntryPages :many
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
+FROM (
+    SELECT path, weight, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC, id ASC) AS rn
+    FROM visits
+    WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+)
+WHERE rn = 1
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10;
+
+-- name: ExitPages :many
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
+FROM (
+    SELECT path, weight, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp DESC, id DESC) AS rn
+    FROM visits
+    WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+)
+WHERE rn = 1
 GROUP BY path
 ORDER BY views DESC
 LIMIT 10;
@@ -39,31 +66,54 @@ LIMIT 10;
 -- name: LatestPages :many
 SELECT path, timestamp, browser
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 ORDER BY timestamp DESC
 LIMIT 10;
 
 -- name: BrowserStats :many
-SELECT browser AS name, COUNT(*) AS count
+SELECT browser AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY browser
 ORDER BY count DESC;
 
 -- name: OSStats :many
-SELECT os AS name, COUNT(*) AS count
+SELECT os AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY os
 ORDER BY count DESC;
 
 -- name: DeviceStats :many
-SELECT device AS name, COUNT(*) AS count
+SELECT device AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY device
 ORDER BY count DESC;
 
+-- name: LanguageStats :many
+SELECT CAST(CASE WHEN language = '' THEN 'Unknown' ELSE language END AS TEXT) AS name, CAST(SUM(weight) AS INTEGER) AS count
+FROM visits
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+GROUP BY 1
+ORDER BY count DESC
+LIMIT 20;
+
+-- name: CampaignStats :many
+SELECT utm_campaign, utm_source, utm_medium, CAST(SUM(weight) AS INTEGER) AS visits
+FROM visits
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site)) AND (utm_campaign != '' OR utm_source != '')
+GROUP BY utm_campaign, utm_source, utm_medium
+ORDER BY visits DESC
+LIMIT 20;
+
+-- name: ViewportStats :many
+SELECT CAST(CASE WHEN viewport = '' THEN 'Unknown' ELSE viewport END AS TEXT) AS name, CAST(SUM(weight) AS INTEGER) AS count
+FROM visits
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+GROUP BY 1
+ORDER BY count DESC;
+
 -- name: ReferrerStats :many
 SELECT
     CASE
@@ -75,30 +125,30 @@ SELECT
         WHEN referrer LIKE '%github.%' THEN 'GitHub'
         ELSE 'Other'
     END AS name,
-    COUNT(*) AS count
+    CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY 1
 ORDER BY count DESC;
 
 -- name: DailyViews :many
-SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, COUNT(*) AS views
+SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY 1
 ORDER BY date;
 
 -- name: HourlyViews :many
-SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, COUNT(*) AS views
+SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY 1
 ORDER BY date;
 
 -- name: MonthlyViews :many
-SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, COUNT(*) AS views
+SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= sqlc.arg(from_time) AND timestamp < sqlc.arg(to_time) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
 GROUP BY 1
 ORDER BY date;
 
@@ -144,17 +194,214 @@ WHERE timestamp >= ? AND timestamp < ?
 GROUP BY 1
 ORDER BY date;
 
--- Duration update
+-- Feed and API aggregations
+
+-- name: InsertFeedHit :exec
+INSERT INTO feed_hits (path, reader, subscribers, ip_hash, user_agent, timestamp)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: CountFeedHits :one
+SELECT COUNT(*) FROM feed_hits WHERE timestamp >= ? AND timestamp < ?;
+
+-- name: FeedReaderStats :many
+SELECT reader,
+    COUNT(*) AS hits,
+    COUNT(DISTINCT ip_hash) AS clients,
+    CAST(MAX(subscribers) AS INTEGER) AS subscribers
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY reader
+ORDER BY hits DESC
+LIMIT 20;
+
+-- name: TopFeedPaths :many
+SELECT path, COUNT(*) AS views
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10;
+
+-- name: DailyFeedHits :many
+SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, COUNT(*) AS views
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY 1
+ORDER BY date;
+
+-- name: HourlyFeedHits :many
+SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, COUNT(*) AS views
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY 1
+ORDER BY date;
+
+-- name: MonthlyFeedHits :many
+SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, COUNT(*) AS views
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY 1
+ORDER BY date;
+
+-- Duration update (heartbeats and unload beacons only ever extend the duration)
 
 -- name: UpdateVisitDuration :exec
-UPDATE visits SET duration_sec = ?
+UPDATE visits SET duration_sec = MAX(COALESCE(duration_sec, 0), CAST(sqlc.arg(duration_sec) AS INTEGER))
 WHERE id = (
   SELECT v.id FROM visits v
-  WHERE v.visitor_id = ? AND v.path = ?
+  WHERE v.visitor_id = sqlc.arg(visitor_id) AND v.path = sqlc.arg(path)
   ORDER BY v.timestamp DESC
   LIMIT 1
 );
 
+-- Experiments
+
+-- name: InsertExposure :exec
+INSERT OR IGNORE INTO experiment_exposures (experiment, variant, visitor_id, timestamp)
+VALUES (?, ?, ?, ?);
+
+-- name: ExperimentResults :many
+SELECT e.variant,
+    COUNT(DISTINCT e.visitor_id) AS exposures,
+    COUNT(DISTINCT v.visitor_id) AS conversions
+FROM experiment_exposures e
+LEFT JOIN visits v
+    ON v.visitor_id = e.visitor_id
+    AND v.path = sqlc.arg(goal_path)
+    AND v.timestamp >= e.timestamp
+WHERE e.experiment = sqlc.arg(experiment)
+GROUP BY e.variant
+ORDER BY e.variant;
+
+-- Short links
+
+-- name: InsertShortLinkHit :exec
+INSERT INTO short_link_hits (code, slug, ip_hash, referrer, timestamp)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ShortLinkStats :many
+SELECT code, slug,
+    COUNT(*) AS hits,
+    COUNT(DISTINCT ip_hash) AS visitors
+FROM short_link_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY code, slug
+ORDER BY hits DESC;
+
+-- Referrer spikes
+
+-- name: ReferrerSpikes :many
+SELECT v.referrer,
+    CAST(SUM(v.weight) AS INTEGER) AS visits,
+    COUNT(DISTINCT v.visitor_id) AS visitors
+FROM visits v
+WHERE v.timestamp >= sqlc.arg(since)
+    AND v.referrer NOT IN ('', 'Direct')
+    AND v.referrer NOT IN (
+        SELECT p.referrer FROM visits p
+        WHERE p.timestamp >= sqlc.arg(known_since) AND p.timestamp < sqlc.arg(since)
+    )
+    AND v.referrer NOT IN (SELECT a.referrer FROM referrer_alerts a)
+GROUP BY v.referrer
+HAVING SUM(v.weight) >= CAST(sqlc.arg(threshold) AS INTEGER)
+ORDER BY visits DESC;
+
+-- name: TopReferredPath :one
+SELECT path FROM visits
+WHERE referrer = ? AND timestamp >= ?
+GROUP BY path
+ORDER BY SUM(weight) DESC
+LIMIT 1;
+
+-- name: InsertReferrerAlert :exec
+INSERT OR REPLACE INTO referrer_alerts (referrer, timestamp) VALUES (?, ?);
+
+-- Acquisition (first touch of each session)
+
+-- name: UpsertSession :exec
+INSERT INTO sessions (session_id, visitor_id, started_at, landing_path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(session_id) DO UPDATE SET
+    started_at = excluded.started_at,
+    hostname = excluded.hostname,
+    landing_path = excluded.landing_path,
+    referrer = excluded.referrer,
+    utm_source = excluded.utm_source,
+    utm_medium = excluded.utm_medium,
+    utm_campaign = excluded.utm_campaign
+WHERE excluded.started_at < sessions.started_at;
+
+-- name: RebuildSessions :exec
+INSERT OR REPLACE INTO sessions (session_id, visitor_id, started_at, landing_path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname)
+SELECT session_id, visitor_id, timestamp, path, COALESCE(referrer, ''), utm_source, utm_medium, utm_campaign, weight, hostname
+FROM (
+    SELECT session_id, visitor_id, timestamp, path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname,
+        ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC, id ASC) AS rn
+    FROM visits
+    WHERE session_id IN (SELECT r.session_id FROM visits r WHERE r.timestamp >= ?)
+)
+WHERE rn = 1;
+
+-- name: DeleteOrphanSessions :exec
+DELETE FROM sessions WHERE session_id NOT IN (SELECT session_id FROM visits);
+
+-- name: AcquisitionStats :many
+SELECT
+    CAST(CASE
+        WHEN s.utm_source != '' THEN s.utm_source
+        WHEN s.referrer = '' THEN 'Direct'
+        ELSE s.referrer
+    END AS TEXT) AS source,
+    s.utm_medium,
+    s.utm_campaign,
+    CAST(SUM(s.weight) AS INTEGER) AS sessions,
+    CAST(SUM((SELECT SUM(v.weight) FROM visits v WHERE v.session_id = s.session_id)) AS INTEGER) AS views,
+    CAST(SUM(s.weight * EXISTS (
+        SELECT 1 FROM visits g
+        WHERE g.session_id = s.session_id
+            AND g.path IN (SELECT value FROM json_each(CAST(sqlc.arg(goals) AS TEXT)))
+    )) AS INTEGER) AS conversions
+FROM sessions s
+WHERE s.started_at >= sqlc.arg(from_time) AND s.started_at < sqlc.arg(to_time)
+    AND (sqlc.arg(site) = '' OR s.hostname = sqlc.arg(site))
+GROUP BY 1, 2, 3
+ORDER BY sessions DESC
+LIMIT 20;
+
+-- Unique visitor sketches (sampling mode)
+
+-- name: GetVisitorSketch :one
+SELECT registers FROM visitor_sketches WHERE day = ? AND hostname = ?;
+
+-- name: UpsertVisitorSketch :exec
+INSERT INTO visitor_sketches (day, hostname, registers) VALUES (?, ?, ?)
+ON CONFLICT(day, hostname) DO UPDATE SET registers = excluded.registers;
+
+-- name: VisitorSketches :many
+SELECT registers FROM visitor_sketches
+WHERE day >= sqlc.arg(from_day) AND day <= sqlc.arg(to_day) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site));
+
+-- Reclassification
+
+-- name: RecentVisitAgents :many
+SELECT id, ip_hash, user_agent, path, timestamp
+FROM visits
+WHERE timestamp >= ? AND user_agent != '';
+
+-- name: RecentBotVisits :many
+SELECT id, bot_name, ip_hash, user_agent, path, timestamp
+FROM bot_visits
+WHERE timestamp >= ?;
+
+-- name: DeleteVisit :exec
+DELETE FROM visits WHERE id = ?;
+
+-- name: DeleteBotVisit :exec
+DELETE FROM bot_visits WHERE id = ?;
+
+-- name: UpdateBotVisitName :exec
+UPDATE bot_visits SET bot_name = ? WHERE id = ?;
+
 -- Cleanup
 
 -- name: DeleteOldVisits :exec
@@ -163,7 +410,55 @@ DELETE FROM visits WHERE timestamp < ?;
 -- name: DeleteOldBotVisits :exec
 DELETE FROM bot_visits WHERE timestamp < ?;
 
+-- name: DeleteOldFeedHits :exec
+DELETE FROM feed_hits WHERE timestamp < ?;
+
+-- name: DeleteOldExposures :exec
+DELETE FROM experiment_exposures WHERE timestamp < ?;
+
+-- name: DeleteOldShortLinkHits :exec
+DELETE FROM short_link_hits WHERE timestamp < ?;
+
+-- name: DeleteOldReferrerAlerts :exec
+DELETE FROM referrer_alerts WHERE timestamp < ?;
+
+-- name: DeleteOldSessions :exec
+DELETE FROM sessions WHERE started_at < ?;
+
+-- name: DeleteOldVisitorSketches :exec
+DELETE FROM visitor_sketches WHERE day < ?;
+
 -- Realtime
 
 -- name: CountRealtimeVisitors :one
-SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= ?;
+SELECT CAST(COALESCE(SUM(weight), 0) AS INTEGER)
+FROM (
+    SELECT MAX(weight) AS weight FROM visits
+    WHERE timestamp >= sqlc.arg(since) AND (sqlc.arg(site) = '' OR hostname = sqlc.arg(site))
+    GROUP BY visitor_id
+);
+
+-- Sites
+
+-- name: ListHostnames :many
+SELECT hostname FROM visits
+WHERE hostname != '' AND timestamp >= ?
+GROUP BY hostname
+ORDER BY SUM(weight) DESC;
+
+-- API tokens
+
+-- name: CreateAPIToken :exec
+INSERT INTO api_tokens (name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?);
+
+-- name: GetAPITokenByHash :one
+SELECT id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens WHERE token_hash = ?;
+
+-- name: ListAPITokens :many
+SELECT id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens ORDER BY created_at DESC, id DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = ? WHERE id = ?;
+
+-- name: DeleteAPIToken :exec
+DELETE FROM api_tokens WHERE id = ?;
@@ -11,21 +11,102 @@ import (
 	"time"
 )
 
+const acquisitionStats = `-- name: AcquisitionStats :many
+SELECT
+    CAST(CASE
+        WHEN s.utm_source != '' THEN s.utm_source
+        WHEN s.referrer = '' THEN 'Direct'
+        ELSE s.referrer
+    END AS TEXT) AS source,
+    s.utm_medium,
+    s.utm_campaign,
+    CAST(SUM(s.weight) AS INTEGER) AS sessions,
+    CAST(SUM((SELECT SUM(v.weight) FROM visits v WHERE v.session_id = s.session_id)) AS INTEGER) AS views,
+    CAST(SUM(s.weight * EXISTS (
+        SELECT 1 FROM visits g
+        WHERE g.session_id = s.session_id
+            AND g.path IN (SELECT value FROM json_each(CAST(?1 AS TEXT)))
+    )) AS INTEGER) AS conversions
+FROM sessions s
+WHERE s.started_at >= ?2 AND s.started_at < ?3
+    AND (?4 = '' OR s.hostname = ?4)
+GROUP BY 1, 2, 3
+ORDER BY sessions DESC
+LIMIT 20
+`
+
+type AcquisitionStatsParams struct {
+	Goals    string
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+type AcquisitionStatsRow struct {
+	Source      string
+	UtmMedium   string
+	UtmCampaign string
+	Sessions    int64
+	Views       int64
+	Conversions int64
+}
+
+func (q *Queries) AcquisitionStats(ctx context.Context, arg AcquisitionStatsParams) ([]AcquisitionStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, acquisitionStats,
+		arg.Goals,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Site,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AcquisitionStatsRow
+	for rows.Next() {
+		var i AcquisitionStatsRow
+		if err := rows.Scan(
+			&i.Source,
+			&i.UtmMedium,
+			&i.UtmCampaign,
+			&i.Sessions,
+			&i.Views,
+			&i.Conversions,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const avgDuration = `-- name: AvgDuration :one
-SELECT AVG(duration_sec) FROM visits WHERE timestamp >= ? AND timestamp < ? AND duration_sec > 0
+SELECT AVG(duration_sec) FROM visits WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3) AND duration_sec > 0
 `
 
-func (q *Queries) AvgDuration(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (sql.NullFloat64, error) {
-	row := q.db.QueryRowContext(ctx, avgDuration, timestamp, timestamp_2)
+type AvgDurationParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) AvgDuration(ctx context.Context, arg AvgDurationParams) (sql.NullFloat64, error) {
+	row := q.db.QueryRowContext(ctx, avgDuration, arg.FromTime, arg.ToTime, arg.Site)
 	var avg sql.NullFloat64
 	err := row.Scan(&avg)
 	return avg, err
 }
 
 const browserStats = `-- name: BrowserStats :many
-SELECT browser AS name, COUNT(*) AS count
+SELECT browser AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 GROUP BY browser
 ORDER BY count DESC
 `
@@ -35,8 +116,14 @@ type BrowserStatsRow struct {
 	Count int64
 }
 
-func (q *Queries) BrowserStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]BrowserStatsRow, error) {
-	rows, err := q.db.QueryContext(ctx, browserStats, timestamp, timestamp_2)
+type BrowserStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) BrowserStats(ctx context.Context, arg BrowserStatsParams) ([]BrowserStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, browserStats, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -58,6 +145,56 @@ func (q *Queries) BrowserStats(ctx context.Context, timestamp time.Time, timesta
 	return items, nil
 }
 
+const campaignStats = `-- name: CampaignStats :many
+SELECT utm_campaign, utm_source, utm_medium, CAST(SUM(weight) AS INTEGER) AS visits
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3) AND (utm_campaign != '' OR utm_source != '')
+GROUP BY utm_campaign, utm_source, utm_medium
+ORDER BY visits DESC
+LIMIT 20
+`
+
+type CampaignStatsRow struct {
+	UtmCampaign string
+	UtmSource   string
+	UtmMedium   string
+	Visits      int64
+}
+
+type CampaignStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) CampaignStats(ctx context.Context, arg CampaignStatsParams) ([]CampaignStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, campaignStats, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CampaignStatsRow
+	for rows.Next() {
+		var i CampaignStatsRow
+		if err := rows.Scan(
+			&i.UtmCampaign,
+			&i.UtmSource,
+			&i.UtmMedium,
+			&i.Visits,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const countBotVisits = `-- name: CountBotVisits :one
 
 SELECT COUNT(*) FROM bot_visits WHERE timestamp >= ? AND timestamp < ?
@@ -71,25 +208,47 @@ func (q *Queries) CountBotVisits(ctx context.Context, timestamp time.Time, times
 	return count, err
 }
 
+const countFeedHits = `-- name: CountFeedHits :one
+SELECT COUNT(*) FROM feed_hits WHERE timestamp >= ? AND timestamp < ?
+`
+
+func (q *Queries) CountFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeedHits, timestamp, timestamp_2)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countRealtimeVisitors = `-- name: CountRealtimeVisitors :one
 
-SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= ?
+SELECT CAST(COALESCE(SUM(weight), 0) AS INTEGER)
+FROM (
+    SELECT MAX(weight) AS weight FROM visits
+    WHERE timestamp >= ?1 AND (?2 = '' OR hostname = ?2)
+    GROUP BY visitor_id
+)
 `
 
 // Realtime
-func (q *Queries) CountRealtimeVisitors(ctx context.Context, timestamp time.Time) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countRealtimeVisitors, timestamp)
+func (q *Queries) CountRealtimeVisitors(ctx context.Context, since time.Time, site string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRealtimeVisitors, since, site)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
 const countUniqueVisitors = `-- name: CountUniqueVisitors :one
-SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= ? AND timestamp < ?
+SELECT COUNT(DISTINCT visitor_id) FROM visits WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 `
 
-func (q *Queries) CountUniqueVisitors(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countUniqueVisitors, timestamp, timestamp_2)
+type CountUniqueVisitorsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) CountUniqueVisitors(ctx context.Context, arg CountUniqueVisitorsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUniqueVisitors, arg.FromTime, arg.ToTime, arg.Site)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -97,17 +256,46 @@ func (q *Queries) CountUniqueVisitors(ctx context.Context, timestamp time.Time,
 
 const countVisits = `-- name: CountVisits :one
 
-SELECT COUNT(*) FROM visits WHERE timestamp >= ? AND timestamp < ?
+SELECT CAST(COALESCE(SUM(weight), 0) AS INTEGER) FROM visits WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 `
 
+type CountVisitsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
 // Visitor aggregations
-func (q *Queries) CountVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countVisits, timestamp, timestamp_2)
+func (q *Queries) CountVisits(ctx context.Context, arg CountVisitsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countVisits, arg.FromTime, arg.ToTime, arg.Site)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
+const createAPIToken = `-- name: CreateAPIToken :exec
+
+INSERT INTO api_tokens (name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?)
+`
+
+type CreateAPITokenParams struct {
+	Name      string
+	TokenHash string
+	Scopes    string
+	CreatedAt time.Time
+}
+
+// API tokens
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) error {
+	_, err := q.db.ExecContext(ctx, createAPIToken,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scopes,
+		arg.CreatedAt,
+	)
+	return err
+}
+
 const dailyBotVisits = `-- name: DailyBotVisits :many
 SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, COUNT(*) AS views
 FROM bot_visits
@@ -144,21 +332,63 @@ func (q *Queries) DailyBotVisits(ctx context.Context, timestamp time.Time, times
 	return items, nil
 }
 
-const dailyViews = `-- name: DailyViews :many
+const dailyFeedHits = `-- name: DailyFeedHits :many
 SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, COUNT(*) AS views
-FROM visits
+FROM feed_hits
 WHERE timestamp >= ? AND timestamp < ?
 GROUP BY 1
 ORDER BY date
 `
 
+type DailyFeedHitsRow struct {
+	Date  string
+	Views int64
+}
+
+func (q *Queries) DailyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DailyFeedHitsRow, error) {
+	rows, err := q.db.QueryContext(ctx, dailyFeedHits, timestamp, timestamp_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DailyFeedHitsRow
+	for rows.Next() {
+		var i DailyFeedHitsRow
+		if err := rows.Scan(&i.Date, &i.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const dailyViews = `-- name: DailyViews :many
+SELECT CAST(substr(timestamp, 1, 10) AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+GROUP BY 1
+ORDER BY date
+`
+
 type DailyViewsRow struct {
 	Date  string
 	Views int64
 }
 
-func (q *Queries) DailyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DailyViewsRow, error) {
-	rows, err := q.db.QueryContext(ctx, dailyViews, timestamp, timestamp_2)
+type DailyViewsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) DailyViews(ctx context.Context, arg DailyViewsParams) ([]DailyViewsRow, error) {
+	rows, err := q.db.QueryContext(ctx, dailyViews, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -180,6 +410,24 @@ func (q *Queries) DailyViews(ctx context.Context, timestamp time.Time, timestamp
 	return items, nil
 }
 
+const deleteAPIToken = `-- name: DeleteAPIToken :exec
+DELETE FROM api_tokens WHERE id = ?
+`
+
+func (q *Queries) DeleteAPIToken(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteAPIToken, id)
+	return err
+}
+
+const deleteBotVisit = `-- name: DeleteBotVisit :exec
+DELETE FROM bot_visits WHERE id = ?
+`
+
+func (q *Queries) DeleteBotVisit(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteBotVisit, id)
+	return err
+}
+
 const deleteOldBotVisits = `-- name: DeleteOldBotVisits :exec
 DELETE FROM bot_visits WHERE timestamp < ?
 `
@@ -189,6 +437,60 @@ func (q *Queries) DeleteOldBotVisits(ctx context.Context, timestamp time.Time) e
 	return err
 }
 
+const deleteOldExposures = `-- name: DeleteOldExposures :exec
+DELETE FROM experiment_exposures WHERE timestamp < ?
+`
+
+func (q *Queries) DeleteOldExposures(ctx context.Context, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteOldExposures, timestamp)
+	return err
+}
+
+const deleteOldFeedHits = `-- name: DeleteOldFeedHits :exec
+DELETE FROM feed_hits WHERE timestamp < ?
+`
+
+func (q *Queries) DeleteOldFeedHits(ctx context.Context, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteOldFeedHits, timestamp)
+	return err
+}
+
+const deleteOldReferrerAlerts = `-- name: DeleteOldReferrerAlerts :exec
+DELETE FROM referrer_alerts WHERE timestamp < ?
+`
+
+func (q *Queries) DeleteOldReferrerAlerts(ctx context.Context, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteOldReferrerAlerts, timestamp)
+	return err
+}
+
+const deleteOldSessions = `-- name: DeleteOldSessions :exec
+DELETE FROM sessions WHERE started_at < ?
+`
+
+func (q *Queries) DeleteOldSessions(ctx context.Context, startedAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteOldSessions, startedAt)
+	return err
+}
+
+const deleteOldShortLinkHits = `-- name: DeleteOldShortLinkHits :exec
+DELETE FROM short_link_hits WHERE timestamp < ?
+`
+
+func (q *Queries) DeleteOldShortLinkHits(ctx context.Context, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, deleteOldShortLinkHits, timestamp)
+	return err
+}
+
+const deleteOldVisitorSketches = `-- name: DeleteOldVisitorSketches :exec
+DELETE FROM visitor_sketches WHERE day < ?
+`
+
+func (q *Queries) DeleteOldVisitorSketches(ctx context.Context, day string) error {
+	_, err := q.db.ExecContext(ctx, deleteOldVisitorSketches, day)
+	return err
+}
+
 const deleteOldVisits = `-- name: DeleteOldVisits :exec
 
 DELETE FROM visits WHERE timestamp < ?
@@ -200,10 +502,28 @@ func (q *Queries) DeleteOldVisits(ctx context.Context, timestamp time.Time) erro
 	return err
 }
 
+const deleteOrphanSessions = `-- name: DeleteOrphanSessions :exec
+DELETE FROM sessions WHERE session_id NOT IN (SELECT session_id FROM visits)
+`
+
+func (q *Queries) DeleteOrphanSessions(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteOrphanSessions)
+	return err
+}
+
+const deleteVisit = `-- name: DeleteVisit :exec
+DELETE FROM visits WHERE id = ?
+`
+
+func (q *Queries) DeleteVisit(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteVisit, id)
+	return err
+}
+
 const deviceStats = `-- name: DeviceStats :many
-SELECT device AS name, COUNT(*) AS count
+SELECT device AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 GROUP BY device
 ORDER BY count DESC
 `
@@ -213,8 +533,14 @@ type DeviceStatsRow struct {
 	Count int64
 }
 
-func (q *Queries) DeviceStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]DeviceStatsRow, error) {
-	rows, err := q.db.QueryContext(ctx, deviceStats, timestamp, timestamp_2)
+type DeviceStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) DeviceStats(ctx context.Context, arg DeviceStatsParams) ([]DeviceStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, deviceStats, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -236,6 +562,241 @@ func (q *Queries) DeviceStats(ctx context.Context, timestamp time.Time, timestam
 	return items, nil
 }
 
+const distinctVisitorIDs = `-- name: DistinctVisitorIDs :many
+SELECT DISTINCT visitor_id FROM visits WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+`
+
+type DistinctVisitorIDsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) DistinctVisitorIDs(ctx context.Context, arg DistinctVisitorIDsParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, distinctVisitorIDs, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var visitor_id string
+		if err := rows.Scan(&visitor_id); err != nil {
+			return nil, err
+		}
+		items = append(items, visitor_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const entryPages = `-- name: EntryPages :many
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
+FROM (
+    SELECT path, weight, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC, id ASC) AS rn
+    FROM visits
+    WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+)
+WHERE rn = 1
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10
+`
+
+type EntryPagesRow struct {
+	Path  string
+	Views int64
+}
+
+type EntryPagesParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) EntryPages(ctx context.Context, arg EntryPagesParams) ([]EntryPagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, entryPages, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EntryPagesRow
+	for rows.Next() {
+		var i EntryPagesRow
+		if err := rows.Scan(&i.Path, &i.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exitPages = `-- name: ExitPages :many
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
+FROM (
+    SELECT path, weight, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp DESC, id DESC) AS rn
+    FROM visits
+    WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+)
+WHERE rn = 1
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10
+`
+
+type ExitPagesRow struct {
+	Path  string
+	Views int64
+}
+
+type ExitPagesParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) ExitPages(ctx context.Context, arg ExitPagesParams) ([]ExitPagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, exitPages, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExitPagesRow
+	for rows.Next() {
+		var i ExitPagesRow
+		if err := rows.Scan(&i.Path, &i.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const experimentResults = `-- name: ExperimentResults :many
+SELECT e.variant,
+    COUNT(DISTINCT e.visitor_id) AS exposures,
+    COUNT(DISTINCT v.visitor_id) AS conversions
+FROM experiment_exposures e
+LEFT JOIN visits v
+    ON v.visitor_id = e.visitor_id
+    AND v.path = ?1
+    AND v.timestamp >= e.timestamp
+WHERE e.experiment = ?2
+GROUP BY e.variant
+ORDER BY e.variant
+`
+
+type ExperimentResultsRow struct {
+	Variant     string
+	Exposures   int64
+	Conversions int64
+}
+
+func (q *Queries) ExperimentResults(ctx context.Context, goalPath string, experiment string) ([]ExperimentResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, experimentResults, goalPath, experiment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExperimentResultsRow
+	for rows.Next() {
+		var i ExperimentResultsRow
+		if err := rows.Scan(&i.Variant, &i.Exposures, &i.Conversions); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const feedReaderStats = `-- name: FeedReaderStats :many
+SELECT reader,
+    COUNT(*) AS hits,
+    COUNT(DISTINCT ip_hash) AS clients,
+    CAST(MAX(subscribers) AS INTEGER) AS subscribers
+FROM feed_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY reader
+ORDER BY hits DESC
+LIMIT 20
+`
+
+type FeedReaderStatsRow struct {
+	Reader      string
+	Hits        int64
+	Clients     int64
+	Subscribers int64
+}
+
+func (q *Queries) FeedReaderStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]FeedReaderStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, feedReaderStats, timestamp, timestamp_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedReaderStatsRow
+	for rows.Next() {
+		var i FeedReaderStatsRow
+		if err := rows.Scan(
+			&i.Reader,
+			&i.Hits,
+			&i.Clients,
+			&i.Subscribers,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAPITokenByHash = `-- name: GetAPITokenByHash :one
+SELECT id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens WHERE token_hash = ?
+`
+
+func (q *Queries) GetAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getAPITokenByHash, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
 const getSetting = `-- name: GetSetting :one
 
 SELECT value FROM settings WHERE key = ?
@@ -249,6 +810,19 @@ func (q *Queries) GetSetting(ctx context.Context, key string) (string, error) {
 	return value, err
 }
 
+const getVisitorSketch = `-- name: GetVisitorSketch :one
+
+SELECT registers FROM visitor_sketches WHERE day = ? AND hostname = ?
+`
+
+// Unique visitor sketches (sampling mode)
+func (q *Queries) GetVisitorSketch(ctx context.Context, day string, hostname string) ([]byte, error) {
+	row := q.db.QueryRowContext(ctx, getVisitorSketch, day, hostname)
+	var registers []byte
+	err := row.Scan(&registers)
+	return registers, err
+}
+
 const hourlyBotVisits = `-- name: HourlyBotVisits :many
 SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, COUNT(*) AS views
 FROM bot_visits
@@ -285,21 +859,63 @@ func (q *Queries) HourlyBotVisits(ctx context.Context, timestamp time.Time, time
 	return items, nil
 }
 
-const hourlyViews = `-- name: HourlyViews :many
+const hourlyFeedHits = `-- name: HourlyFeedHits :many
 SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, COUNT(*) AS views
-FROM visits
+FROM feed_hits
 WHERE timestamp >= ? AND timestamp < ?
 GROUP BY 1
 ORDER BY date
 `
 
+type HourlyFeedHitsRow struct {
+	Date  string
+	Views int64
+}
+
+func (q *Queries) HourlyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]HourlyFeedHitsRow, error) {
+	rows, err := q.db.QueryContext(ctx, hourlyFeedHits, timestamp, timestamp_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HourlyFeedHitsRow
+	for rows.Next() {
+		var i HourlyFeedHitsRow
+		if err := rows.Scan(&i.Date, &i.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hourlyViews = `-- name: HourlyViews :many
+SELECT CAST(substr(timestamp, 12, 2) || ':00' AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+GROUP BY 1
+ORDER BY date
+`
+
 type HourlyViewsRow struct {
 	Date  string
 	Views int64
 }
 
-func (q *Queries) HourlyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]HourlyViewsRow, error) {
-	rows, err := q.db.QueryContext(ctx, hourlyViews, timestamp, timestamp_2)
+type HourlyViewsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) HourlyViews(ctx context.Context, arg HourlyViewsParams) ([]HourlyViewsRow, error) {
+	rows, err := q.db.QueryContext(ctx, hourlyViews, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -345,10 +961,97 @@ func (q *Queries) InsertBotVisit(ctx context.Context, arg InsertBotVisitParams)
 	return err
 }
 
+const insertExposure = `-- name: InsertExposure :exec
+
+INSERT OR IGNORE INTO experiment_exposures (experiment, variant, visitor_id, timestamp)
+VALUES (?, ?, ?, ?)
+`
+
+type InsertExposureParams struct {
+	Experiment string
+	Variant    string
+	VisitorID  string
+	Timestamp  time.Time
+}
+
+// Experiments
+func (q *Queries) InsertExposure(ctx context.Context, arg InsertExposureParams) error {
+	_, err := q.db.ExecContext(ctx, insertExposure,
+		arg.Experiment,
+		arg.Variant,
+		arg.VisitorID,
+		arg.Timestamp,
+	)
+	return err
+}
+
+const insertFeedHit = `-- name: InsertFeedHit :exec
+
+INSERT INTO feed_hits (path, reader, subscribers, ip_hash, user_agent, timestamp)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type InsertFeedHitParams struct {
+	Path        string
+	Reader      string
+	Subscribers int64
+	IpHash      string
+	UserAgent   string
+	Timestamp   time.Time
+}
+
+// Feed and API aggregations
+func (q *Queries) InsertFeedHit(ctx context.Context, arg InsertFeedHitParams) error {
+	_, err := q.db.ExecContext(ctx, insertFeedHit,
+		arg.Path,
+		arg.Reader,
+		arg.Subscribers,
+		arg.IpHash,
+		arg.UserAgent,
+		arg.Timestamp,
+	)
+	return err
+}
+
+const insertReferrerAlert = `-- name: InsertReferrerAlert :exec
+INSERT OR REPLACE INTO referrer_alerts (referrer, timestamp) VALUES (?, ?)
+`
+
+func (q *Queries) InsertReferrerAlert(ctx context.Context, referrer string, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, insertReferrerAlert, referrer, timestamp)
+	return err
+}
+
+const insertShortLinkHit = `-- name: InsertShortLinkHit :exec
+
+INSERT INTO short_link_hits (code, slug, ip_hash, referrer, timestamp)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type InsertShortLinkHitParams struct {
+	Code      string
+	Slug      string
+	IpHash    string
+	Referrer  string
+	Timestamp time.Time
+}
+
+// Short links
+func (q *Queries) InsertShortLinkHit(ctx context.Context, arg InsertShortLinkHitParams) error {
+	_, err := q.db.ExecContext(ctx, insertShortLinkHit,
+		arg.Code,
+		arg.Slug,
+		arg.IpHash,
+		arg.Referrer,
+		arg.Timestamp,
+	)
+	return err
+}
+
 const insertVisit = `-- name: InsertVisit :exec
 
-INSERT INTO visits (visitor_id, session_id, ip_hash, browser, os, device, path, referrer, screen_size, timestamp, duration_sec)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO visits (visitor_id, session_id, ip_hash, browser, os, device, path, referrer, screen_size, viewport, timestamp, duration_sec, language, utm_source, utm_medium, utm_campaign, user_agent, weight, hostname)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type InsertVisitParams struct {
@@ -361,8 +1064,16 @@ type InsertVisitParams struct {
 	Path        string
 	Referrer    sql.NullString
 	ScreenSize  sql.NullString
+	Viewport    string
 	Timestamp   time.Time
 	DurationSec sql.NullInt64
+	Language    string
+	UtmSource   string
+	UtmMedium   string
+	UtmCampaign string
+	UserAgent   string
+	Weight      int64
+	Hostname    string
 }
 
 // Inserts
@@ -377,36 +1088,195 @@ func (q *Queries) InsertVisit(ctx context.Context, arg InsertVisitParams) error
 		arg.Path,
 		arg.Referrer,
 		arg.ScreenSize,
+		arg.Viewport,
 		arg.Timestamp,
 		arg.DurationSec,
+		arg.Language,
+		arg.UtmSource,
+		arg.UtmMedium,
+		arg.UtmCampaign,
+		arg.UserAgent,
+		arg.Weight,
+		arg.Hostname,
 	)
 	return err
 }
 
+const languageStats = `-- name: LanguageStats :many
+SELECT CAST(CASE WHEN language = '' THEN 'Unknown' ELSE language END AS TEXT) AS name, CAST(SUM(weight) AS INTEGER) AS count
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+GROUP BY 1
+ORDER BY count DESC
+LIMIT 20
+`
+
+type LanguageStatsRow struct {
+	Name  string
+	Count int64
+}
+
+type LanguageStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) LanguageStats(ctx context.Context, arg LanguageStatsParams) ([]LanguageStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, languageStats, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LanguageStatsRow
+	for rows.Next() {
+		var i LanguageStatsRow
+		if err := rows.Scan(&i.Name, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const latestPages = `-- name: LatestPages :many
 SELECT path, timestamp, browser
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 ORDER BY timestamp DESC
 LIMIT 10
 `
 
-type LatestPagesRow struct {
-	Path      string
-	Timestamp time.Time
-	Browser   string
+type LatestPagesRow struct {
+	Path      string
+	Timestamp time.Time
+	Browser   string
+}
+
+type LatestPagesParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) LatestPages(ctx context.Context, arg LatestPagesParams) ([]LatestPagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, latestPages, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LatestPagesRow
+	for rows.Next() {
+		var i LatestPagesRow
+		if err := rows.Scan(&i.Path, &i.Timestamp, &i.Browser); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAPITokens = `-- name: ListAPITokens :many
+SELECT id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) ListAPITokens(ctx context.Context) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scopes,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHostnames = `-- name: ListHostnames :many
+
+SELECT hostname FROM visits
+WHERE hostname != '' AND timestamp >= ?
+GROUP BY hostname
+ORDER BY SUM(weight) DESC
+`
+
+// Sites
+func (q *Queries) ListHostnames(ctx context.Context, timestamp time.Time) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listHostnames, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var hostname string
+		if err := rows.Scan(&hostname); err != nil {
+			return nil, err
+		}
+		items = append(items, hostname)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const monthlyBotVisits = `-- name: MonthlyBotVisits :many
+SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, COUNT(*) AS views
+FROM bot_visits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY 1
+ORDER BY date
+`
+
+type MonthlyBotVisitsRow struct {
+	Date  string
+	Views int64
 }
 
-func (q *Queries) LatestPages(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]LatestPagesRow, error) {
-	rows, err := q.db.QueryContext(ctx, latestPages, timestamp, timestamp_2)
+func (q *Queries) MonthlyBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyBotVisitsRow, error) {
+	rows, err := q.db.QueryContext(ctx, monthlyBotVisits, timestamp, timestamp_2)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []LatestPagesRow
+	var items []MonthlyBotVisitsRow
 	for rows.Next() {
-		var i LatestPagesRow
-		if err := rows.Scan(&i.Path, &i.Timestamp, &i.Browser); err != nil {
+		var i MonthlyBotVisitsRow
+		if err := rows.Scan(&i.Date, &i.Views); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -420,28 +1290,28 @@ func (q *Queries) LatestPages(ctx context.Context, timestamp time.Time, timestam
 	return items, nil
 }
 
-const monthlyBotVisits = `-- name: MonthlyBotVisits :many
+const monthlyFeedHits = `-- name: MonthlyFeedHits :many
 SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, COUNT(*) AS views
-FROM bot_visits
+FROM feed_hits
 WHERE timestamp >= ? AND timestamp < ?
 GROUP BY 1
 ORDER BY date
 `
 
-type MonthlyBotVisitsRow struct {
+type MonthlyFeedHitsRow struct {
 	Date  string
 	Views int64
 }
 
-func (q *Queries) MonthlyBotVisits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyBotVisitsRow, error) {
-	rows, err := q.db.QueryContext(ctx, monthlyBotVisits, timestamp, timestamp_2)
+func (q *Queries) MonthlyFeedHits(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyFeedHitsRow, error) {
+	rows, err := q.db.QueryContext(ctx, monthlyFeedHits, timestamp, timestamp_2)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []MonthlyBotVisitsRow
+	var items []MonthlyFeedHitsRow
 	for rows.Next() {
-		var i MonthlyBotVisitsRow
+		var i MonthlyFeedHitsRow
 		if err := rows.Scan(&i.Date, &i.Views); err != nil {
 			return nil, err
 		}
@@ -457,9 +1327,9 @@ func (q *Queries) MonthlyBotVisits(ctx context.Context, timestamp time.Time, tim
 }
 
 const monthlyViews = `-- name: MonthlyViews :many
-SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, COUNT(*) AS views
+SELECT CAST(substr(timestamp, 1, 7) AS TEXT) AS date, CAST(SUM(weight) AS INTEGER) AS views
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 GROUP BY 1
 ORDER BY date
 `
@@ -469,8 +1339,14 @@ type MonthlyViewsRow struct {
 	Views int64
 }
 
-func (q *Queries) MonthlyViews(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]MonthlyViewsRow, error) {
-	rows, err := q.db.QueryContext(ctx, monthlyViews, timestamp, timestamp_2)
+type MonthlyViewsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) MonthlyViews(ctx context.Context, arg MonthlyViewsParams) ([]MonthlyViewsRow, error) {
+	rows, err := q.db.QueryContext(ctx, monthlyViews, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -493,9 +1369,9 @@ func (q *Queries) MonthlyViews(ctx context.Context, timestamp time.Time, timesta
 }
 
 const oSStats = `-- name: OSStats :many
-SELECT os AS name, COUNT(*) AS count
+SELECT os AS name, CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 GROUP BY os
 ORDER BY count DESC
 `
@@ -505,8 +1381,14 @@ type OSStatsRow struct {
 	Count int64
 }
 
-func (q *Queries) OSStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]OSStatsRow, error) {
-	rows, err := q.db.QueryContext(ctx, oSStats, timestamp, timestamp_2)
+type OSStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) OSStats(ctx context.Context, arg OSStatsParams) ([]OSStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, oSStats, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -528,6 +1410,158 @@ func (q *Queries) OSStats(ctx context.Context, timestamp time.Time, timestamp_2
 	return items, nil
 }
 
+const rebuildSessions = `-- name: RebuildSessions :exec
+INSERT OR REPLACE INTO sessions (session_id, visitor_id, started_at, landing_path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname)
+SELECT session_id, visitor_id, timestamp, path, COALESCE(referrer, ''), utm_source, utm_medium, utm_campaign, weight, hostname
+FROM (
+    SELECT session_id, visitor_id, timestamp, path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname,
+        ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp ASC, id ASC) AS rn
+    FROM visits
+    WHERE session_id IN (SELECT r.session_id FROM visits r WHERE r.timestamp >= ?)
+)
+WHERE rn = 1
+`
+
+func (q *Queries) RebuildSessions(ctx context.Context, timestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, rebuildSessions, timestamp)
+	return err
+}
+
+const recentBotVisits = `-- name: RecentBotVisits :many
+SELECT id, bot_name, ip_hash, user_agent, path, timestamp
+FROM bot_visits
+WHERE timestamp >= ?
+`
+
+func (q *Queries) RecentBotVisits(ctx context.Context, timestamp time.Time) ([]BotVisit, error) {
+	rows, err := q.db.QueryContext(ctx, recentBotVisits, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BotVisit
+	for rows.Next() {
+		var i BotVisit
+		if err := rows.Scan(
+			&i.ID,
+			&i.BotName,
+			&i.IpHash,
+			&i.UserAgent,
+			&i.Path,
+			&i.Timestamp,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recentVisitAgents = `-- name: RecentVisitAgents :many
+
+SELECT id, ip_hash, user_agent, path, timestamp
+FROM visits
+WHERE timestamp >= ? AND user_agent != ''
+`
+
+type RecentVisitAgentsRow struct {
+	ID        int64
+	IpHash    string
+	UserAgent string
+	Path      string
+	Timestamp time.Time
+}
+
+// Reclassification
+func (q *Queries) RecentVisitAgents(ctx context.Context, timestamp time.Time) ([]RecentVisitAgentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, recentVisitAgents, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RecentVisitAgentsRow
+	for rows.Next() {
+		var i RecentVisitAgentsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.IpHash,
+			&i.UserAgent,
+			&i.Path,
+			&i.Timestamp,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const referrerSpikes = `-- name: ReferrerSpikes :many
+
+SELECT v.referrer,
+    CAST(SUM(v.weight) AS INTEGER) AS visits,
+    COUNT(DISTINCT v.visitor_id) AS visitors
+FROM visits v
+WHERE v.timestamp >= ?1
+    AND v.referrer NOT IN ('', 'Direct')
+    AND v.referrer NOT IN (
+        SELECT p.referrer FROM visits p
+        WHERE p.timestamp >= ?2 AND p.timestamp < ?1
+    )
+    AND v.referrer NOT IN (SELECT a.referrer FROM referrer_alerts a)
+GROUP BY v.referrer
+HAVING SUM(v.weight) >= CAST(?3 AS INTEGER)
+ORDER BY visits DESC
+`
+
+type ReferrerSpikesParams struct {
+	Since      time.Time
+	KnownSince time.Time
+	Threshold  int64
+}
+
+type ReferrerSpikesRow struct {
+	Referrer sql.NullString
+	Visits   int64
+	Visitors int64
+}
+
+// Referrer spikes
+func (q *Queries) ReferrerSpikes(ctx context.Context, arg ReferrerSpikesParams) ([]ReferrerSpikesRow, error) {
+	rows, err := q.db.QueryContext(ctx, referrerSpikes, arg.Since, arg.KnownSince, arg.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReferrerSpikesRow
+	for rows.Next() {
+		var i ReferrerSpikesRow
+		if err := rows.Scan(&i.Referrer, &i.Visits, &i.Visitors); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const referrerStats = `-- name: ReferrerStats :many
 SELECT
     CASE
@@ -539,9 +1573,9 @@ SELECT
         WHEN referrer LIKE '%github.%' THEN 'GitHub'
         ELSE 'Other'
     END AS name,
-    COUNT(*) AS count
+    CAST(SUM(weight) AS INTEGER) AS count
 FROM visits
-WHERE timestamp >= ? AND timestamp < ?
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
 GROUP BY 1
 ORDER BY count DESC
 `
@@ -551,8 +1585,14 @@ type ReferrerStatsRow struct {
 	Count int64
 }
 
-func (q *Queries) ReferrerStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]ReferrerStatsRow, error) {
-	rows, err := q.db.QueryContext(ctx, referrerStats, timestamp, timestamp_2)
+type ReferrerStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) ReferrerStats(ctx context.Context, arg ReferrerStatsParams) ([]ReferrerStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, referrerStats, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -574,6 +1614,51 @@ func (q *Queries) ReferrerStats(ctx context.Context, timestamp time.Time, timest
 	return items, nil
 }
 
+const shortLinkStats = `-- name: ShortLinkStats :many
+SELECT code, slug,
+    COUNT(*) AS hits,
+    COUNT(DISTINCT ip_hash) AS visitors
+FROM short_link_hits
+WHERE timestamp >= ? AND timestamp < ?
+GROUP BY code, slug
+ORDER BY hits DESC
+`
+
+type ShortLinkStatsRow struct {
+	Code     string
+	Slug     string
+	Hits     int64
+	Visitors int64
+}
+
+func (q *Queries) ShortLinkStats(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]ShortLinkStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, shortLinkStats, timestamp, timestamp_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ShortLinkStatsRow
+	for rows.Next() {
+		var i ShortLinkStatsRow
+		if err := rows.Scan(
+			&i.Code,
+			&i.Slug,
+			&i.Hits,
+			&i.Visitors,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const topBotPages = `-- name: TopBotPages :many
 SELECT path, COUNT(*) AS views
 FROM bot_visits
@@ -648,22 +1733,65 @@ func (q *Queries) TopBots(ctx context.Context, timestamp time.Time, timestamp_2
 	return items, nil
 }
 
-const topPages = `-- name: TopPages :many
+const topFeedPaths = `-- name: TopFeedPaths :many
 SELECT path, COUNT(*) AS views
-FROM visits
+FROM feed_hits
 WHERE timestamp >= ? AND timestamp < ?
 GROUP BY path
 ORDER BY views DESC
 LIMIT 10
 `
 
+type TopFeedPathsRow struct {
+	Path  string
+	Views int64
+}
+
+func (q *Queries) TopFeedPaths(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopFeedPathsRow, error) {
+	rows, err := q.db.QueryContext(ctx, topFeedPaths, timestamp, timestamp_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TopFeedPathsRow
+	for rows.Next() {
+		var i TopFeedPathsRow
+		if err := rows.Scan(&i.Path, &i.Views); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const topPages = `-- name: TopPages :many
+SELECT path, CAST(SUM(weight) AS INTEGER) AS views
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+GROUP BY path
+ORDER BY views DESC
+LIMIT 10
+`
+
 type TopPagesRow struct {
 	Path  string
 	Views int64
 }
 
-func (q *Queries) TopPages(ctx context.Context, timestamp time.Time, timestamp_2 time.Time) ([]TopPagesRow, error) {
-	rows, err := q.db.QueryContext(ctx, topPages, timestamp, timestamp_2)
+type TopPagesParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) TopPages(ctx context.Context, arg TopPagesParams) ([]TopPagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, topPages, arg.FromTime, arg.ToTime, arg.Site)
 	if err != nil {
 		return nil, err
 	}
@@ -685,29 +1813,107 @@ func (q *Queries) TopPages(ctx context.Context, timestamp time.Time, timestamp_2
 	return items, nil
 }
 
+const topReferredPath = `-- name: TopReferredPath :one
+SELECT path FROM visits
+WHERE referrer = ? AND timestamp >= ?
+GROUP BY path
+ORDER BY SUM(weight) DESC
+LIMIT 1
+`
+
+func (q *Queries) TopReferredPath(ctx context.Context, referrer sql.NullString, timestamp time.Time) (string, error) {
+	row := q.db.QueryRowContext(ctx, topReferredPath, referrer, timestamp)
+	var path string
+	err := row.Scan(&path)
+	return path, err
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = ? WHERE id = ?
+`
+
+func (q *Queries) TouchAPIToken(ctx context.Context, lastUsedAt sql.NullTime, id int64) error {
+	_, err := q.db.ExecContext(ctx, touchAPIToken, lastUsedAt, id)
+	return err
+}
+
+const updateBotVisitName = `-- name: UpdateBotVisitName :exec
+UPDATE bot_visits SET bot_name = ? WHERE id = ?
+`
+
+func (q *Queries) UpdateBotVisitName(ctx context.Context, botName string, id int64) error {
+	_, err := q.db.ExecContext(ctx, updateBotVisitName, botName, id)
+	return err
+}
+
 const updateVisitDuration = `-- name: UpdateVisitDuration :exec
 
-UPDATE visits SET duration_sec = ?
+UPDATE visits SET duration_sec = MAX(COALESCE(duration_sec, 0), CAST(?1 AS INTEGER))
 WHERE id = (
   SELECT v.id FROM visits v
-  WHERE v.visitor_id = ? AND v.path = ?
+  WHERE v.visitor_id = ?2 AND v.path = ?3
   ORDER BY v.timestamp DESC
   LIMIT 1
 )
 `
 
 type UpdateVisitDurationParams struct {
-	DurationSec sql.NullInt64
+	DurationSec int64
 	VisitorID   string
 	Path        string
 }
 
-// Duration update
+// Duration update (heartbeats and unload beacons only ever extend the duration)
 func (q *Queries) UpdateVisitDuration(ctx context.Context, arg UpdateVisitDurationParams) error {
 	_, err := q.db.ExecContext(ctx, updateVisitDuration, arg.DurationSec, arg.VisitorID, arg.Path)
 	return err
 }
 
+const upsertSession = `-- name: UpsertSession :exec
+
+INSERT INTO sessions (session_id, visitor_id, started_at, landing_path, referrer, utm_source, utm_medium, utm_campaign, weight, hostname)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(session_id) DO UPDATE SET
+    started_at = excluded.started_at,
+    hostname = excluded.hostname,
+    landing_path = excluded.landing_path,
+    referrer = excluded.referrer,
+    utm_source = excluded.utm_source,
+    utm_medium = excluded.utm_medium,
+    utm_campaign = excluded.utm_campaign
+WHERE excluded.started_at < sessions.started_at
+`
+
+type UpsertSessionParams struct {
+	SessionID   string
+	VisitorID   string
+	StartedAt   time.Time
+	LandingPath string
+	Referrer    string
+	UtmSource   string
+	UtmMedium   string
+	UtmCampaign string
+	Weight      int64
+	Hostname    string
+}
+
+// Acquisition (first touch of each session)
+func (q *Queries) UpsertSession(ctx context.Context, arg UpsertSessionParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSession,
+		arg.SessionID,
+		arg.VisitorID,
+		arg.StartedAt,
+		arg.LandingPath,
+		arg.Referrer,
+		arg.UtmSource,
+		arg.UtmMedium,
+		arg.UtmCampaign,
+		arg.Weight,
+		arg.Hostname,
+	)
+	return err
+}
+
 const upsertSetting = `-- name: UpsertSetting :exec
 INSERT INTO settings (key, value) VALUES (?, ?)
 ON CONFLICT(key) DO UPDATE SET value = excluded.value
@@ -717,3 +1923,95 @@ func (q *Queries) UpsertSetting(ctx context.Context, key string, value string) e
 	_, err := q.db.ExecContext(ctx, upsertSetting, key, value)
 	return err
 }
+
+const upsertVisitorSketch = `-- name: UpsertVisitorSketch :exec
+INSERT INTO visitor_sketches (day, hostname, registers) VALUES (?, ?, ?)
+ON CONFLICT(day, hostname) DO UPDATE SET registers = excluded.registers
+`
+
+type UpsertVisitorSketchParams struct {
+	Day       string
+	Hostname  string
+	Registers []byte
+}
+
+func (q *Queries) UpsertVisitorSketch(ctx context.Context, arg UpsertVisitorSketchParams) error {
+	_, err := q.db.ExecContext(ctx, upsertVisitorSketch, arg.Day, arg.Hostname, arg.Registers)
+	return err
+}
+
+const viewportStats = `-- name: ViewportStats :many
+SELECT CAST(CASE WHEN viewport = '' THEN 'Unknown' ELSE viewport END AS TEXT) AS name, CAST(SUM(weight) AS INTEGER) AS count
+FROM visits
+WHERE timestamp >= ?1 AND timestamp < ?2 AND (?3 = '' OR hostname = ?3)
+GROUP BY 1
+ORDER BY count DESC
+`
+
+type ViewportStatsRow struct {
+	Name  string
+	Count int64
+}
+
+type ViewportStatsParams struct {
+	FromTime time.Time
+	ToTime   time.Time
+	Site     string
+}
+
+func (q *Queries) ViewportStats(ctx context.Context, arg ViewportStatsParams) ([]ViewportStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, viewportStats, arg.FromTime, arg.ToTime, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ViewportStatsRow
+	for rows.Next() {
+		var i ViewportStatsRow
+		if err := rows.Scan(&i.Name, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const visitorSketches = `-- name: VisitorSketches :many
+SELECT registers FROM visitor_sketches
+WHERE day >= ?1 AND day <= ?2 AND (?3 = '' OR hostname = ?3)
+`
+
+type VisitorSketchesParams struct {
+	FromDay string
+	ToDay   string
+	Site    string
+}
+
+func (q *Queries) VisitorSketches(ctx context.Context, arg VisitorSketchesParams) ([][]byte, error) {
+	rows, err := q.db.QueryContext(ctx, visitorSketches, arg.FromDay, arg.ToDay, arg.Site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items [][]byte
+	for rows.Next() {
+		var registers []byte
+		if err := rows.Scan(&registers); err != nil {
+			return nil, err
+		}
+		items = append(items, registers)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -13,7 +13,15 @@ CREATE TABLE visits (
     referrer TEXT,
     screen_size TEXT,
     timestamp DATETIME NOT NULL,
-    duration_sec INTEGER DEFAULT 0
+    duration_sec INTEGER DEFAULT 0,
+    language TEXT NOT NULL DEFAULT '',
+    utm_source TEXT NOT NULL DEFAULT '',
+    utm_medium TEXT NOT NULL DEFAULT '',
+    utm_campaign TEXT NOT NULL DEFAULT '',
+    viewport TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    weight INTEGER NOT NULL DEFAULT 1,
+    hostname TEXT NOT NULL DEFAULT ''
 );
 
 CREATE TABLE bot_visits (
@@ -25,6 +33,68 @@ CREATE TABLE bot_visits (
     timestamp DATETIME NOT NULL
 );
 
+CREATE TABLE feed_hits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT NOT NULL,
+    reader TEXT NOT NULL,
+    subscribers INTEGER NOT NULL DEFAULT 0,
+    ip_hash TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    timestamp DATETIME NOT NULL
+);
+
+CREATE TABLE experiment_exposures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    experiment TEXT NOT NULL,
+    variant TEXT NOT NULL,
+    visitor_id TEXT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    UNIQUE (experiment, visitor_id)
+);
+
+CREATE TABLE short_link_hits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    ip_hash TEXT NOT NULL,
+    referrer TEXT NOT NULL,
+    timestamp DATETIME NOT NULL
+);
+
+CREATE TABLE referrer_alerts (
+    referrer TEXT PRIMARY KEY,
+    timestamp DATETIME NOT NULL
+);
+
+CREATE TABLE sessions (
+    session_id TEXT PRIMARY KEY,
+    visitor_id TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    landing_path TEXT NOT NULL,
+    referrer TEXT NOT NULL,
+    utm_source TEXT NOT NULL DEFAULT '',
+    utm_medium TEXT NOT NULL DEFAULT '',
+    utm_campaign TEXT NOT NULL DEFAULT '',
+    weight INTEGER NOT NULL DEFAULT 1,
+    hostname TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE visitor_sketches (
+    day TEXT NOT NULL,
+    hostname TEXT NOT NULL DEFAULT '',
+    registers BLOB NOT NULL,
+    PRIMARY KEY (day, hostname)
+);
+
+CREATE TABLE api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME
+);
+
 CREATE TABLE settings (
     key TEXT PRIMARY KEY,
     value TEXT NOT NULL
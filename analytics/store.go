@@ -14,8 +14,13 @@ import (
 
 // Store provides database operations for analytics.
 type Store struct {
-	db *sql.DB
-	q  *sqlcgen.Queries
+	db         *sql.DB
+	q          *sqlcgen.Queries
+	goals      string // JSON array of goal paths, see SetGoalPaths
+	sampleRate int    // see SetSampleRate
+
+	sketchMu sync.Mutex
+	sketches map[sketchKey]sketch // visitors counted since the last FlushSketches, by day and hostname
 }
 
 // NewStore creates a new analytics store.
@@ -55,11 +60,28 @@ func NewStore(dbPath string) (*Store, error) {
 	return s, nil
 }
 
-// Close closes the database connection.
+// Close writes pending visitor sketches and closes the database connection.
 func (s *Store) Close() error {
+	if err := s.FlushSketches(); err != nil {
+		s.db.Close()
+		return err
+	}
 	return s.db.Close()
 }
 
+// Ping checks that the database answers a query.
+func (s *Store) Ping() error {
+	var one int
+	return s.db.QueryRow(`SELECT 1`).Scan(&one)
+}
+
+// Snapshot writes a consistent copy of the database to path, which must
+// not exist, with VACUUM INTO. Writers are not blocked while it runs.
+func (s *Store) Snapshot(path string) error {
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
 // ensureSchema creates the necessary tables if they don't exist.
 func (s *Store) ensureSchema() error {
 	_, err := s.db.Exec(`
@@ -106,7 +128,7 @@ func (s *Store) ensureSchema() error {
 }
 
 // currentSchemaVersion is the latest schema version. Increment when adding migrations.
-const currentSchemaVersion = 1
+const currentSchemaVersion = 13
 
 // migrate applies incremental schema migrations based on a version stored in the settings table.
 func (s *Store) migrate() error {
@@ -122,14 +144,231 @@ func (s *Store) migrate() error {
 			return fmt.Errorf("parse schema version %q: %w", verStr, err)
 		}
 	}
+	backfillSessions := false
 
 	if version < 1 {
 		version = 1
 	}
 
+	// v2: visitor language (primary language code from the browser).
+	if version < 2 {
+		if _, err := s.db.Exec(`ALTER TABLE visits ADD COLUMN language TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add visits.language: %w", err)
+		}
+		version = 2
+	}
+
+	// v3: UTM campaign parameters from the landing page URL.
+	if version < 3 {
+		for _, col := range []string{"utm_source", "utm_medium", "utm_campaign"} {
+			if _, err := s.db.Exec(`ALTER TABLE visits ADD COLUMN ` + col + ` TEXT NOT NULL DEFAULT ''`); err != nil {
+				return fmt.Errorf("add visits.%s: %w", col, err)
+			}
+		}
+		version = 3
+	}
+
+	// v4: A/B experiment exposures (one row per experiment and visitor).
+	if version < 4 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS experiment_exposures (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				experiment TEXT NOT NULL,
+				variant TEXT NOT NULL,
+				visitor_id TEXT NOT NULL,
+				timestamp DATETIME NOT NULL,
+				UNIQUE (experiment, visitor_id)
+			)`); err != nil {
+			return fmt.Errorf("create experiment_exposures: %w", err)
+		}
+		version = 4
+	}
+
+	// v5: viewport class bucketed from screen_size, backfilled for existing rows.
+	if version < 5 {
+		if _, err := s.db.Exec(`ALTER TABLE visits ADD COLUMN viewport TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add visits.viewport: %w", err)
+		}
+		if err := s.backfillViewports(); err != nil {
+			return err
+		}
+		version = 5
+	}
+
+	// v6: server-side feed and API hits (feed readers never run the JS beacon).
+	if version < 6 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS feed_hits (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				path TEXT NOT NULL,
+				reader TEXT NOT NULL,
+				subscribers INTEGER NOT NULL DEFAULT 0,
+				ip_hash TEXT NOT NULL,
+				user_agent TEXT NOT NULL,
+				timestamp DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_feed_hits_timestamp ON feed_hits(timestamp);`); err != nil {
+			return fmt.Errorf("create feed_hits: %w", err)
+		}
+		version = 6
+	}
+
+	// v7: short link redirects (/s/:code), recorded server side.
+	if version < 7 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS short_link_hits (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				code TEXT NOT NULL,
+				slug TEXT NOT NULL,
+				ip_hash TEXT NOT NULL,
+				referrer TEXT NOT NULL,
+				timestamp DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_short_link_hits_timestamp ON short_link_hits(timestamp);`); err != nil {
+			return fmt.Errorf("create short_link_hits: %w", err)
+		}
+		version = 7
+	}
+
+	// v8: referrer spike alerts already sent.
+	if version < 8 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS referrer_alerts (
+				referrer TEXT PRIMARY KEY,
+				timestamp DATETIME NOT NULL
+			);`); err != nil {
+			return fmt.Errorf("create referrer_alerts: %w", err)
+		}
+		version = 8
+	}
+
+	// v9: user agent of human visits, so they can be reclassified when the
+	// bot patterns change.
+	if version < 9 {
+		if _, err := s.db.Exec(`ALTER TABLE visits ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add visits.user_agent: %w", err)
+		}
+		version = 9
+	}
+
+	// v10: first touch (landing page, referrer and UTM parameters) of each
+	// session, backfilled from the earliest visit of existing sessions once
+	// visits have their v11 weight.
+	if version < 10 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS sessions (
+				session_id TEXT PRIMARY KEY,
+				visitor_id TEXT NOT NULL,
+				started_at DATETIME NOT NULL,
+				landing_path TEXT NOT NULL,
+				referrer TEXT NOT NULL,
+				utm_source TEXT NOT NULL DEFAULT '',
+				utm_medium TEXT NOT NULL DEFAULT '',
+				utm_campaign TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX IF NOT EXISTS idx_sessions_started_at ON sessions(started_at);
+			CREATE INDEX IF NOT EXISTS idx_visits_session_id ON visits(session_id);`); err != nil {
+			return fmt.Errorf("create sessions: %w", err)
+		}
+		version = 10
+		backfillSessions = true
+	}
+
+	// v11: sampling. Each visit and session stands for weight of them, and
+	// unique visitors are counted in a sketch per day.
+	if version < 11 {
+		if _, err := s.db.Exec(`
+			ALTER TABLE visits ADD COLUMN weight INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE sessions ADD COLUMN weight INTEGER NOT NULL DEFAULT 1;
+			CREATE TABLE IF NOT EXISTS visitor_sketches (
+				day TEXT PRIMARY KEY,
+				registers BLOB NOT NULL
+			);`); err != nil {
+			return fmt.Errorf("add sampling weight: %w", err)
+		}
+		version = 11
+	}
+
+	// v12: hostname of each visit and session, for sites sharing one
+	// analytics database. Visitor sketches become one per day and hostname;
+	// existing ones keep an empty hostname.
+	if version < 12 {
+		if _, err := s.db.Exec(`
+			ALTER TABLE visits ADD COLUMN hostname TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN hostname TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS idx_visits_hostname ON visits(hostname);
+			CREATE TABLE visitor_sketches_v12 (
+				day TEXT NOT NULL,
+				hostname TEXT NOT NULL DEFAULT '',
+				registers BLOB NOT NULL,
+				PRIMARY KEY (day, hostname)
+			);
+			INSERT INTO visitor_sketches_v12 (day, hostname, registers)
+				SELECT day, '', registers FROM visitor_sketches;
+			DROP TABLE visitor_sketches;
+			ALTER TABLE visitor_sketches_v12 RENAME TO visitor_sketches;`); err != nil {
+			return fmt.Errorf("add hostname: %w", err)
+		}
+		version = 12
+	}
+
+	// v13: read-only API tokens for external dashboards.
+	if version < 13 {
+		if _, err := s.db.Exec(`
+			CREATE TABLE IF NOT EXISTS api_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				scopes TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				last_used_at DATETIME
+			);`); err != nil {
+			return fmt.Errorf("create api_tokens: %w", err)
+		}
+		version = 13
+	}
+
+	if backfillSessions {
+		if err := s.q.RebuildSessions(context.Background(), time.Time{}); err != nil {
+			return fmt.Errorf("backfill sessions: %w", err)
+		}
+	}
+
 	return s.SetSetting("schema_version", strconv.Itoa(version))
 }
 
+// backfillViewports sets the viewport class of existing visits from their screen size.
+func (s *Store) backfillViewports() error {
+	rows, err := s.db.Query(`SELECT DISTINCT screen_size FROM visits WHERE screen_size IS NOT NULL AND screen_size != ''`)
+	if err != nil {
+		return fmt.Errorf("list screen sizes: %w", err)
+	}
+	var sizes []string
+	for rows.Next() {
+		var size string
+		if err := rows.Scan(&size); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan screen size: %w", err)
+		}
+		sizes = append(sizes, size)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list screen sizes: %w", err)
+	}
+
+	for _, size := range sizes {
+		viewport := ClassifyScreen(size)
+		if viewport == "" {
+			continue
+		}
+		if _, err := s.db.Exec(`UPDATE visits SET viewport = ? WHERE screen_size = ?`, viewport, size); err != nil {
+			return fmt.Errorf("backfill viewport: %w", err)
+		}
+	}
+	return nil
+}
+
 // GetSetting retrieves a setting value by key. Returns empty string if not found.
 func (s *Store) GetSetting(key string) (string, error) {
 	val, err := s.q.GetSetting(context.Background(), key)
@@ -144,9 +383,37 @@ func (s *Store) SetSetting(key, value string) error {
 	return s.q.UpsertSetting(context.Background(), key, value)
 }
 
-// SaveVisit stores a new visit in the database.
+// SaveVisit stores a new visit in the database, together with the first
+// touch of its session.
 func (s *Store) SaveVisit(v *Visit) error {
-	return s.q.InsertVisit(context.Background(), sqlcgen.InsertVisitParams{
+	ctx := context.Background()
+	if err := s.q.InsertVisit(ctx, visitParams(v)); err != nil {
+		return err
+	}
+	return s.q.UpsertSession(ctx, sessionParams(v))
+}
+
+// sessionParams maps a Visit to the sqlc session upsert parameters. The
+// upsert keeps the earliest visit of the session, so events queued offline
+// that arrive late still count as the first touch.
+func sessionParams(v *Visit) sqlcgen.UpsertSessionParams {
+	return sqlcgen.UpsertSessionParams{
+		SessionID:   v.SessionID,
+		VisitorID:   v.VisitorID,
+		StartedAt:   v.Timestamp.UTC(),
+		LandingPath: v.Path,
+		Referrer:    v.Referrer,
+		UtmSource:   v.UTMSource,
+		UtmMedium:   v.UTMMedium,
+		UtmCampaign: v.UTMCampaign,
+		Weight:      visitWeight(v),
+		Hostname:    v.Hostname,
+	}
+}
+
+// visitParams maps a Visit to the sqlc insert parameters.
+func visitParams(v *Visit) sqlcgen.InsertVisitParams {
+	return sqlcgen.InsertVisitParams{
 		VisitorID:   v.VisitorID,
 		SessionID:   v.SessionID,
 		IpHash:      v.IPHash,
@@ -156,42 +423,119 @@ func (s *Store) SaveVisit(v *Visit) error {
 		Path:        v.Path,
 		Referrer:    sql.NullString{String: v.Referrer, Valid: true},
 		ScreenSize:  sql.NullString{String: v.ScreenSize, Valid: true},
+		Viewport:    v.Viewport,
 		Timestamp:   v.Timestamp.UTC(),
 		DurationSec: sql.NullInt64{Int64: int64(v.DurationSec), Valid: true},
-	})
+		Language:    v.Language,
+		UtmSource:   v.UTMSource,
+		UtmMedium:   v.UTMMedium,
+		UtmCampaign: v.UTMCampaign,
+		UserAgent:   v.UserAgent,
+		Weight:      visitWeight(v),
+		Hostname:    v.Hostname,
+	}
 }
 
-// UpdateVisitDuration updates the duration of the most recent visit for a visitor+path.
+// visitWeight returns the number of page views v stands for.
+func visitWeight(v *Visit) int64 {
+	if v.Weight < 1 {
+		return 1
+	}
+	return int64(v.Weight)
+}
+
+// UpdateVisitDuration records engaged time on the most recent visit for a visitor+path.
+// The stored duration only grows, so late or out-of-order heartbeats never shorten it.
 func (s *Store) UpdateVisitDuration(visitorID, path string, durationSec int) error {
-	return s.q.UpdateVisitDuration(context.Background(), sqlcgen.UpdateVisitDurationParams{
-		DurationSec: sql.NullInt64{Int64: int64(durationSec), Valid: true},
+	return s.q.UpdateVisitDuration(context.Background(), durationParams(DurationUpdate{
 		VisitorID:   visitorID,
 		Path:        path,
-	})
+		DurationSec: durationSec,
+	}))
+}
+
+// durationParams maps a DurationUpdate to the sqlc update parameters.
+func durationParams(d DurationUpdate) sqlcgen.UpdateVisitDurationParams {
+	return sqlcgen.UpdateVisitDurationParams{
+		DurationSec: int64(d.DurationSec),
+		VisitorID:   d.VisitorID,
+		Path:        d.Path,
+	}
 }
 
 // SaveBotVisit stores a new bot visit in the database.
 func (s *Store) SaveBotVisit(bv *BotVisit) error {
-	return s.q.InsertBotVisit(context.Background(), sqlcgen.InsertBotVisitParams{
+	return s.q.InsertBotVisit(context.Background(), botVisitParams(bv))
+}
+
+// botVisitParams maps a BotVisit to the sqlc insert parameters.
+func botVisitParams(bv *BotVisit) sqlcgen.InsertBotVisitParams {
+	return sqlcgen.InsertBotVisitParams{
 		BotName:   bv.BotName,
 		IpHash:    bv.IPHash,
 		UserAgent: bv.UserAgent,
 		Path:      bv.Path,
 		Timestamp: bv.Timestamp.UTC(),
-	})
+	}
 }
 
-// GetStats returns aggregated statistics for the given time period.
+// SaveBatch stores all writes of a batch in a single transaction. Visits are
+// inserted before duration updates so an update can target a visit from the
+// same batch.
+func (s *Store) SaveBatch(b *Batch) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := s.q.WithTx(tx)
+	for _, v := range b.Visits {
+		if err := q.InsertVisit(ctx, visitParams(v)); err != nil {
+			return fmt.Errorf("insert visit: %w", err)
+		}
+		if err := q.UpsertSession(ctx, sessionParams(v)); err != nil {
+			return fmt.Errorf("upsert session: %w", err)
+		}
+	}
+	for _, bv := range b.BotVisits {
+		if err := q.InsertBotVisit(ctx, botVisitParams(bv)); err != nil {
+			return fmt.Errorf("insert bot visit: %w", err)
+		}
+	}
+	for _, d := range b.Durations {
+		if err := q.UpdateVisitDuration(ctx, durationParams(d)); err != nil {
+			return fmt.Errorf("update visit duration: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetStats returns aggregated statistics for the given time period across
+// all sites.
 func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, error) {
+	return s.GetSiteStats("", from, to, hourly, monthly)
+}
+
+// GetSiteStats returns aggregated statistics for the given time period of
+// the visits recorded on one hostname, or of all sites when site is empty.
+func (s *Store) GetSiteStats(site string, from, to time.Time, hourly, monthly bool) (*Stats, error) {
 	ctx := context.Background()
 	stats := &Stats{
 		Period:        from.Format("2006-01-02") + " to " + to.Format("2006-01-02"),
 		TopPages:      []PageStat{},
+		EntryPages:    []PageStat{},
+		ExitPages:     []PageStat{},
 		LatestPages:   []LatestPageVisit{},
 		BrowserStats:  []DimensionStat{},
 		OSStats:       []DimensionStat{},
 		DeviceStats:   []DimensionStat{},
 		ReferrerStats: []DimensionStat{},
+		LanguageStats: []DimensionStat{},
+		ViewportStats: []DimensionStat{},
+		Campaigns:     []CampaignStat{},
+		Acquisition:   []AcquisitionStat{},
 		DailyViews:    []DailyView{},
 	}
 
@@ -211,7 +555,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		count, err := s.q.CountVisits(ctx, from, to)
+		count, err := s.q.CountVisits(ctx, sqlcgen.CountVisitsParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("count views: %w", err))
 			return
@@ -225,13 +569,13 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		count, err := s.q.CountUniqueVisitors(ctx, from, to)
+		count, err := s.uniqueVisitors(ctx, site, from, to)
 		if err != nil {
 			setErr(fmt.Errorf("count unique visitors: %w", err))
 			return
 		}
 		mu.Lock()
-		stats.UniqueVisitors = int(count)
+		stats.UniqueVisitors = count
 		mu.Unlock()
 	}()
 
@@ -239,7 +583,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		avg, err := s.q.AvgDuration(ctx, from, to)
+		avg, err := s.q.AvgDuration(ctx, sqlcgen.AvgDurationParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("avg duration: %w", err))
 			return
@@ -255,7 +599,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.TopPages(ctx, from, to)
+		rows, err := s.q.TopPages(ctx, sqlcgen.TopPagesParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("top pages: %w", err))
 			return
@@ -269,11 +613,84 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 		mu.Unlock()
 	}()
 
+	// Entry pages (earliest page of each session)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := s.q.EntryPages(ctx, sqlcgen.EntryPagesParams{FromTime: from, ToTime: to, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("entry pages: %w", err))
+			return
+		}
+		pages := make([]PageStat, len(rows))
+		for i, r := range rows {
+			pages[i] = PageStat{Path: r.Path, Views: int(r.Views)}
+		}
+		mu.Lock()
+		stats.EntryPages = pages
+		mu.Unlock()
+	}()
+
+	// Exit pages (latest page of each session)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := s.q.ExitPages(ctx, sqlcgen.ExitPagesParams{FromTime: from, ToTime: to, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("exit pages: %w", err))
+			return
+		}
+		pages := make([]PageStat, len(rows))
+		for i, r := range rows {
+			pages[i] = PageStat{Path: r.Path, Views: int(r.Views)}
+		}
+		mu.Lock()
+		stats.ExitPages = pages
+		mu.Unlock()
+	}()
+
+	// UTM campaigns
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := s.q.CampaignStats(ctx, sqlcgen.CampaignStatsParams{FromTime: from, ToTime: to, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("campaign stats: %w", err))
+			return
+		}
+		campaigns := make([]CampaignStat, len(rows))
+		for i, r := range rows {
+			campaigns[i] = CampaignStat{
+				Campaign: r.UtmCampaign,
+				Source:   r.UtmSource,
+				Medium:   r.UtmMedium,
+				Visits:   int(r.Visits),
+			}
+		}
+		mu.Lock()
+		stats.Campaigns = campaigns
+		mu.Unlock()
+	}()
+
+	// Acquisition (sessions by first touch)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		result, err := s.acquisitionStats(ctx, site, from, to)
+		if err != nil {
+			setErr(err)
+			return
+		}
+		mu.Lock()
+		stats.Acquisition = result
+		mu.Unlock()
+	}()
+
 	// Latest pages
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.LatestPages(ctx, from, to)
+		rows, err := s.q.LatestPages(ctx, sqlcgen.LatestPagesParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("latest pages: %w", err))
 			return
@@ -295,7 +712,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.BrowserStats(ctx, from, to)
+		rows, err := s.q.BrowserStats(ctx, sqlcgen.BrowserStatsParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("browser stats: %w", err))
 			return
@@ -313,7 +730,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.OSStats(ctx, from, to)
+		rows, err := s.q.OSStats(ctx, sqlcgen.OSStatsParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("os stats: %w", err))
 			return
@@ -331,7 +748,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.DeviceStats(ctx, from, to)
+		rows, err := s.q.DeviceStats(ctx, sqlcgen.DeviceStatsParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("device stats: %w", err))
 			return
@@ -349,7 +766,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := s.q.ReferrerStats(ctx, from, to)
+		rows, err := s.q.ReferrerStats(ctx, sqlcgen.ReferrerStatsParams{FromTime: from, ToTime: to, Site: site})
 		if err != nil {
 			setErr(fmt.Errorf("referrer stats: %w", err))
 			return
@@ -363,13 +780,81 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 		mu.Unlock()
 	}()
 
+	// Previous period of equal length, for trend comparison
+	prevFrom, prevTo := from.Add(-to.Sub(from)), from
+	prev := &PeriodComparison{
+		Period: prevFrom.Format("2006-01-02") + " to " + prevTo.Format("2006-01-02"),
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		views, err := s.q.CountVisits(ctx, sqlcgen.CountVisitsParams{FromTime: prevFrom, ToTime: prevTo, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("count previous views: %w", err))
+			return
+		}
+		visitors, err := s.uniqueVisitors(ctx, site, prevFrom, prevTo)
+		if err != nil {
+			setErr(fmt.Errorf("count previous unique visitors: %w", err))
+			return
+		}
+		avg, err := s.q.AvgDuration(ctx, sqlcgen.AvgDurationParams{FromTime: prevFrom, ToTime: prevTo, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("previous avg duration: %w", err))
+			return
+		}
+		mu.Lock()
+		prev.TotalViews = int(views)
+		prev.UniqueVisitors = visitors
+		if avg.Valid {
+			prev.AvgDuration = int(avg.Float64)
+		}
+		mu.Unlock()
+	}()
+
+	// Language stats
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := s.q.LanguageStats(ctx, sqlcgen.LanguageStatsParams{FromTime: from, ToTime: to, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("language stats: %w", err))
+			return
+		}
+		result := make([]DimensionStat, len(rows))
+		for i, r := range rows {
+			result[i] = DimensionStat{Name: r.Name, Count: int(r.Count)}
+		}
+		mu.Lock()
+		stats.LanguageStats = result
+		mu.Unlock()
+	}()
+
+	// Viewport stats
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := s.q.ViewportStats(ctx, sqlcgen.ViewportStatsParams{FromTime: from, ToTime: to, Site: site})
+		if err != nil {
+			setErr(fmt.Errorf("viewport stats: %w", err))
+			return
+		}
+		result := make([]DimensionStat, len(rows))
+		for i, r := range rows {
+			result[i] = DimensionStat{Name: r.Name, Count: int(r.Count)}
+		}
+		mu.Lock()
+		stats.ViewportStats = result
+		mu.Unlock()
+	}()
+
 	// Daily/hourly/monthly views
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		var result []DailyView
 		if hourly {
-			rows, err := s.q.HourlyViews(ctx, from, to)
+			rows, err := s.q.HourlyViews(ctx, sqlcgen.HourlyViewsParams{FromTime: from, ToTime: to, Site: site})
 			if err != nil {
 				setErr(fmt.Errorf("hourly views: %w", err))
 				return
@@ -380,7 +865,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 			}
 			result = fillHourlyGaps(from, sparse)
 		} else if monthly {
-			rows, err := s.q.MonthlyViews(ctx, from, to)
+			rows, err := s.q.MonthlyViews(ctx, sqlcgen.MonthlyViewsParams{FromTime: from, ToTime: to, Site: site})
 			if err != nil {
 				setErr(fmt.Errorf("monthly views: %w", err))
 				return
@@ -390,7 +875,7 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 				result[i] = DailyView{Date: r.Date, Views: int(r.Views)}
 			}
 		} else {
-			rows, err := s.q.DailyViews(ctx, from, to)
+			rows, err := s.q.DailyViews(ctx, sqlcgen.DailyViewsParams{FromTime: from, ToTime: to, Site: site})
 			if err != nil {
 				setErr(fmt.Errorf("daily views: %w", err))
 				return
@@ -411,6 +896,11 @@ func (s *Store) GetStats(from, to time.Time, hourly, monthly bool) (*Stats, erro
 		return nil, firstErr
 	}
 
+	prev.VisitorsChange = PercentChange(stats.UniqueVisitors, prev.UniqueVisitors)
+	prev.ViewsChange = PercentChange(stats.TotalViews, prev.TotalViews)
+	prev.DurationChange = PercentChange(stats.AvgDuration, prev.AvgDuration)
+	stats.Comparison = prev
+
 	return stats, nil
 }
 
@@ -498,16 +988,34 @@ func fillHourlyGaps(from time.Time, sparse []DailyView) []DailyView {
 	return result
 }
 
-// CleanupOldVisits removes visits and bot visits older than the retention period.
+// CleanupOldVisits removes visits, sessions, bot visits, feed hits, experiment exposures, short link hits, referrer alerts and visitor sketches older than the retention period.
 func (s *Store) CleanupOldVisits(retentionDays int) error {
 	ctx := context.Background()
 	cutoff := time.Now().UTC().AddDate(0, 0, -retentionDays)
 	if err := s.q.DeleteOldVisits(ctx, cutoff); err != nil {
 		return fmt.Errorf("cleanup visits: %w", err)
 	}
+	if err := s.q.DeleteOldSessions(ctx, cutoff); err != nil {
+		return fmt.Errorf("cleanup sessions: %w", err)
+	}
 	if err := s.q.DeleteOldBotVisits(ctx, cutoff); err != nil {
 		return fmt.Errorf("cleanup bot_visits: %w", err)
 	}
+	if err := s.q.DeleteOldFeedHits(ctx, cutoff); err != nil {
+		return fmt.Errorf("cleanup feed_hits: %w", err)
+	}
+	if err := s.q.DeleteOldExposures(ctx, cutoff); err != nil {
+		return fmt.Errorf("cleanup experiment_exposures: %w", err)
+	}
+	if err := s.q.DeleteOldShortLinkHits(ctx, cutoff); err != nil {
+		return fmt.Errorf("cleanup short_link_hits: %w", err)
+	}
+	if err := s.q.DeleteOldReferrerAlerts(ctx, cutoff); err != nil {
+		return fmt.Errorf("cleanup referrer_alerts: %w", err)
+	}
+	if err := s.q.DeleteOldVisitorSketches(ctx, cutoff.Format(sketchDay)); err != nil {
+		return fmt.Errorf("cleanup visitor_sketches: %w", err)
+	}
 	return nil
 }
 
@@ -533,9 +1041,27 @@ func (s *Store) StartCleanupScheduler(retentionDays int, interval time.Duration)
 	return func() { close(done) }
 }
 
-// GetRealtimeVisitors returns the number of unique visitors in the last 5 minutes.
+// GetRealtimeVisitors returns the number of unique visitors in the last 5
+// minutes, scaled up by the sample rate when sampling is on.
 func (s *Store) GetRealtimeVisitors() (int, error) {
+	return s.GetSiteRealtimeVisitors("")
+}
+
+// GetSiteRealtimeVisitors is GetRealtimeVisitors for one hostname, or for
+// all sites when site is empty.
+func (s *Store) GetSiteRealtimeVisitors(site string) (int, error) {
 	cutoff := time.Now().UTC().Add(-5 * time.Minute)
-	count, err := s.q.CountRealtimeVisitors(context.Background(), cutoff)
+	count, err := s.q.CountRealtimeVisitors(context.Background(), cutoff, site)
 	return int(count), err
 }
+
+// Hostnames returns the hostnames visits were recorded on in the last
+// year, busiest first.
+func (s *Store) Hostnames() ([]string, error) {
+	since := time.Now().UTC().AddDate(-1, 0, 0)
+	hosts, err := s.q.ListHostnames(context.Background(), since)
+	if err != nil {
+		return nil, fmt.Errorf("list hostnames: %w", err)
+	}
+	return hosts, nil
+}
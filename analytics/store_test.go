@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/templates"
+)
+
+func TestStatsComparison(t *testing.T) {
+	store := newTestStore(t)
+	to := time.Now().UTC().Truncate(time.Hour)
+	from := to.AddDate(0, 0, -7)
+	visit := func(visitor string, at time.Time, duration int) {
+		t.Helper()
+		if err := store.SaveVisit(&Visit{VisitorID: visitor, SessionID: visitor, Path: "/", Timestamp: at, DurationSec: duration}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// This week: 3 views by 3 visitors, 20s on average.
+	visit("a", from.Add(time.Hour), 10)
+	visit("b", from.Add(2*time.Hour), 20)
+	visit("c", to.Add(-time.Hour), 30)
+	// The week before: 2 views by 2 visitors, 10s on average.
+	visit("a", from.Add(-time.Hour), 10)
+	visit("d", from.AddDate(0, 0, -7), 10)
+	// Before that, outside both periods.
+	visit("e", from.AddDate(0, 0, -8), 90)
+
+	stats, err := store.GetStats(from, to, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := stats.Comparison
+	if prev == nil || prev.TotalViews != 2 || prev.UniqueVisitors != 2 || prev.AvgDuration != 10 {
+		t.Fatalf("Comparison = %+v, want 2 views, 2 visitors, 10s", prev)
+	}
+	for name, tc := range map[string]struct {
+		got  *float64
+		want float64
+	}{
+		"views":    {prev.ViewsChange, 50},
+		"visitors": {prev.VisitorsChange, 50},
+		"duration": {prev.DurationChange, 100},
+	} {
+		if tc.got == nil || *tc.got != tc.want {
+			t.Errorf("%s change = %v, want %v", name, tc.got, tc.want)
+		}
+	}
+	vm := convertStatsToViewModel(stats)
+	if vm.ViewsTrend != (templates.TrendViewModel{Change: 50, HasBaseline: true}) {
+		t.Errorf("ViewsTrend = %+v", vm.ViewsTrend)
+	}
+
+	// Without data in the previous period there is nothing to compare to.
+	stats, err = store.GetStats(to.AddDate(0, 0, -14), to.AddDate(0, 0, -14).Add(time.Hour), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := stats.Comparison; c.ViewsChange != nil || c.VisitorsChange != nil || c.DurationChange != nil {
+		t.Errorf("changes without a baseline = %v, %v, %v, want nil", c.ViewsChange, c.VisitorsChange, c.DurationChange)
+	}
+	if vm := convertStatsToViewModel(stats); vm.ViewsTrend.HasBaseline {
+		t.Errorf("ViewsTrend = %+v without a baseline", vm.ViewsTrend)
+	}
+}
+
+func TestEntryExitPages(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Now().UTC().Add(-time.Hour)
+	sessions := map[string][]string{
+		"s1": {"/", "/a/", "/b/"},
+		"s2": {"/a/", "/c/"},
+		"s3": {"/a/"},
+		"s4": {"/x/", "/c/"},
+	}
+	for session, paths := range sessions {
+		// Saved last page first, as events queued offline can arrive.
+		for i := len(paths) - 1; i >= 0; i-- {
+			v := &Visit{VisitorID: session, SessionID: session, Path: paths[i], Timestamp: start.Add(time.Duration(i) * time.Minute)}
+			if err := store.SaveVisit(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stats, err := store.GetStats(start.Add(-time.Hour), start.Add(time.Hour), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	views := func(pages []PageStat) map[string]int {
+		m := make(map[string]int)
+		for _, p := range pages {
+			m[p.Path] = p.Views
+		}
+		return m
+	}
+	if got, want := views(stats.EntryPages), map[string]int{"/a/": 2, "/": 1, "/x/": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entry pages = %v, want %v", got, want)
+	}
+	if got, want := views(stats.ExitPages), map[string]int{"/c/": 2, "/b/": 1, "/a/": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("exit pages = %v, want %v", got, want)
+	}
+	if stats.EntryPages[0].Path != "/a/" || stats.ExitPages[0].Path != "/c/" {
+		t.Errorf("want the most common first: %v, %v", stats.EntryPages, stats.ExitPages)
+	}
+}
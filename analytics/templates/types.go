@@ -8,15 +8,35 @@ type StatsViewModel struct {
 	UniqueVisitors int
 	TotalViews     int
 	AvgDuration    int
+	VisitorsTrend  TrendViewModel
+	ViewsTrend     TrendViewModel
+	DurationTrend  TrendViewModel
+	Widgets        []string // enabled widget IDs in display order
 	TopPages       []PageStatViewModel
+	EntryPages     []PageStatViewModel
+	ExitPages      []PageStatViewModel
 	LatestPages    []LatestPageVisitViewModel
 	BrowserStats   []DimensionStatViewModel
 	OSStats        []DimensionStatViewModel
 	DeviceStats    []DimensionStatViewModel
 	ReferrerStats  []DimensionStatViewModel
+	LanguageStats  []DimensionStatViewModel
+	ViewportStats  []DimensionStatViewModel
+	Campaigns      []CampaignStatViewModel
+	Acquisition    []AcquisitionStatViewModel
+	HasGoals       bool     // conversions are only counted with goal paths set
+	SampleRate     int      // 1 in SampleRate visitors is stored, see Store.SetSampleRate
+	Site           string   // hostname the stats are filtered by, empty for all sites
+	Sites          []string // hostnames with visits, busiest first
 	DailyViews     []DailyViewViewModel
 }
 
+// TrendViewModel represents the change of a summary value against the previous period.
+type TrendViewModel struct {
+	Change      float64 // percent
+	HasBaseline bool    // false when the previous period had no data
+}
+
 // BotStatsViewModel represents bot analytics statistics for templating.
 type BotStatsViewModel struct {
 	Period      string
@@ -26,6 +46,24 @@ type BotStatsViewModel struct {
 	DailyVisits []DailyViewViewModel
 }
 
+// FeedStatsViewModel represents feed and API analytics statistics for templating.
+type FeedStatsViewModel struct {
+	Period      string
+	TotalHits   int
+	Subscribers int
+	Readers     []FeedReaderViewModel
+	TopPaths    []PageStatViewModel
+	DailyHits   []DailyViewViewModel
+}
+
+// FeedReaderViewModel represents hits from one feed reader.
+type FeedReaderViewModel struct {
+	Reader      string
+	Hits        int
+	Clients     int
+	Subscribers int
+}
+
 // PageStatViewModel represents page view statistics.
 type PageStatViewModel struct {
 	Path  string
@@ -45,6 +83,49 @@ type DimensionStatViewModel struct {
 	Count int
 }
 
+// CampaignStatViewModel represents visits attributed to a UTM campaign.
+type CampaignStatViewModel struct {
+	Campaign string
+	Source   string
+	Medium   string
+	Visits   int
+}
+
+// AcquisitionStatViewModel represents the sessions started from one source.
+type AcquisitionStatViewModel struct {
+	Source         string
+	Medium         string
+	Campaign       string
+	Sessions       int
+	Views          int
+	Conversions    int
+	ConversionRate float64
+}
+
+// WidgetOptionViewModel represents a dashboard widget in the layout settings form.
+type WidgetOptionViewModel struct {
+	ID       string
+	Title    string
+	Enabled  bool
+	Position int
+}
+
+// APITokenSettingsViewModel represents the API tokens section of the setup tab.
+type APITokenSettingsViewModel struct {
+	Scopes   []string // endpoints a token can be allowed to read
+	NewToken string   // token just created, shown once
+	Tokens   []APITokenViewModel
+}
+
+// APITokenViewModel represents a stored API token.
+type APITokenViewModel struct {
+	ID         int64
+	Name       string
+	Scopes     string
+	CreatedAt  string
+	LastUsedAt string // empty when never used
+}
+
 // DailyViewViewModel represents views per day.
 type DailyViewViewModel struct {
 	Date  string
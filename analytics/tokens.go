@@ -0,0 +1,183 @@
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eringen/pubengine/analytics/sqlcgen"
+	"github.com/labstack/echo/v4"
+)
+
+// APIScopes are the analytics JSON endpoints an API token can be allowed to
+// read, named after their path under /admin/analytics/api/.
+var APIScopes = []string{"stats", "bot-stats", "feed-stats", "shortlink-stats", "experiments"}
+
+// apiTokenPrefix starts every API token, so leaked tokens are easy to
+// recognise in logs and secret scanners.
+const apiTokenPrefix = "nla_"
+
+// APIToken is a read-only token for external dashboards. Only a hash of the
+// token is stored; the token itself is shown once, when it is created.
+type APIToken struct {
+	ID         int64
+	Name       string   // what the token is for, e.g. "Grafana"
+	Scopes     []string // endpoints it may read, see APIScopes
+	CreatedAt  time.Time
+	LastUsedAt time.Time // zero when never used
+}
+
+// Allows reports whether the token may read the endpoint scope.
+func (t APIToken) Allows(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// CreateAPIToken stores a new token allowed to read the given scopes and
+// returns it. Unknown scopes are an error.
+func (s *Store) CreateAPIToken(name string, scopes []string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("choose at least one endpoint")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(APIScopes, scope) {
+			return "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate api token: %w", err)
+	}
+	token := apiTokenPrefix + hex.EncodeToString(b)
+	err := s.q.CreateAPIToken(context.Background(), sqlcgen.CreateAPITokenParams{
+		Name:      name,
+		TokenHash: hashAPIToken(token),
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create api token: %w", err)
+	}
+	return token, nil
+}
+
+// ListAPITokens returns every token, newest first.
+func (s *Store) ListAPITokens() ([]APIToken, error) {
+	rows, err := s.q.ListAPITokens(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	tokens := make([]APIToken, len(rows))
+	for i, r := range rows {
+		tokens[i] = apiTokenFromRow(r)
+	}
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes a token.
+func (s *Store) DeleteAPIToken(id int64) error {
+	return s.q.DeleteAPIToken(context.Background(), id)
+}
+
+// VerifyAPIToken returns the stored token matching token and records that
+// it was used. It returns sql.ErrNoRows for an unknown or revoked token.
+func (s *Store) VerifyAPIToken(token string) (APIToken, error) {
+	ctx := context.Background()
+	row, err := s.q.GetAPITokenByHash(ctx, hashAPIToken(token))
+	if err != nil {
+		return APIToken{}, err
+	}
+	now := time.Now().UTC()
+	if err := s.q.TouchAPIToken(ctx, sql.NullTime{Time: now, Valid: true}, row.ID); err != nil {
+		return APIToken{}, fmt.Errorf("touch api token: %w", err)
+	}
+	row.LastUsedAt = sql.NullTime{Time: now, Valid: true}
+	return apiTokenFromRow(row), nil
+}
+
+func apiTokenFromRow(r sqlcgen.ApiToken) APIToken {
+	t := APIToken{
+		ID:        r.ID,
+		Name:      r.Name,
+		Scopes:    strings.Split(r.Scopes, ","),
+		CreatedAt: r.CreatedAt,
+	}
+	if r.LastUsedAt.Valid {
+		t.LastUsedAt = r.LastUsedAt.Time
+	}
+	return t
+}
+
+// hashAPIToken returns the hash a token is stored and looked up by. Tokens
+// are long and random, so a plain SHA-256 is enough.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIAuth protects an analytics JSON endpoint. Requests with an
+// "Authorization: Bearer <token>" header are let through when the token
+// allows the endpoint's scope, the last segment of its path; other requests
+// go through adminAuth, the admin session check.
+func (h *Handler) APIAuth(adminAuth echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAdmin := adminAuth(next)
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			token, ok := strings.CutPrefix(auth, "Bearer ")
+			if !ok {
+				return withAdmin(c)
+			}
+			t, err := h.store.VerifyAPIToken(strings.TrimSpace(token))
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API token")
+			}
+			if err != nil {
+				return err
+			}
+			scope := strings.TrimSuffix(c.Path(), "/")
+			scope = scope[strings.LastIndex(scope, "/")+1:]
+			if !t.Allows(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API token is not allowed to read "+scope)
+			}
+			return next(c)
+		}
+	}
+}
+
+// CreateToken creates an API token from the setup tab and shows the setup
+// tab again with the new token, which is never shown afterwards.
+func (h *Handler) CreateToken(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	token, err := h.store.CreateAPIToken(form.Get("name"), form["scope"])
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	return h.renderSetup(c, token)
+}
+
+// DeleteToken revokes an API token from the setup tab.
+func (h *Handler) DeleteToken(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request")
+	}
+	if err := h.store.DeleteAPIToken(id); err != nil {
+		return err
+	}
+	return h.renderSetup(c, "")
+}
@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAPITokens(t *testing.T) {
+	store := newTestStore(t)
+
+	if _, err := store.CreateAPIToken("Grafana", []string{"nope"}); err == nil {
+		t.Error("CreateAPIToken accepted an unknown scope")
+	}
+	token, err := store.CreateAPIToken("Grafana", []string{"stats"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	adminOnly := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return c.Redirect(http.StatusSeeOther, "/admin/")
+		}
+	}
+	NewHandler(store).RegisterRoutes(e, e.Group(""), adminOnly)
+	get := func(path, auth string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, tc := range []struct {
+		path, auth string
+		want       int
+	}{
+		{"/admin/analytics/api/stats?period=today", "Bearer " + token, http.StatusOK},
+		{"/admin/analytics/api/bot-stats?period=today", "Bearer " + token, http.StatusForbidden},
+		{"/admin/analytics/api/stats?period=today", "Bearer nla_wrong", http.StatusUnauthorized},
+		{"/admin/analytics/api/stats?period=today", "", http.StatusSeeOther},
+		{"/admin/analytics/fragments/stats", "Bearer " + token, http.StatusSeeOther},
+	} {
+		if got := get(tc.path, tc.auth); got != tc.want {
+			t.Errorf("GET %s (%q) = %d, want %d", tc.path, tc.auth, got, tc.want)
+		}
+	}
+
+	tokens, err := store.ListAPITokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "Grafana" || tokens[0].LastUsedAt.IsZero() {
+		t.Fatalf("ListAPITokens() = %+v", tokens)
+	}
+	if err := store.DeleteAPIToken(tokens[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/admin/analytics/api/stats?period=today", "Bearer "+token); got != http.StatusUnauthorized {
+		t.Errorf("revoked token: GET = %d, want 401", got)
+	}
+}
@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Widget describes a section that can be shown on the visitors dashboard.
+type Widget struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// Widgets lists every available dashboard widget in its default order.
+var Widgets = []Widget{
+	{ID: "summary", Title: "Summary cards"},
+	{ID: "chart", Title: "Views over time"},
+	{ID: "top_pages", Title: "Top pages"},
+	{ID: "entry_pages", Title: "Top entry pages"},
+	{ID: "exit_pages", Title: "Top exit pages"},
+	{ID: "latest_pages", Title: "Latest visited pages"},
+	{ID: "campaigns", Title: "UTM campaigns"},
+	{ID: "acquisition", Title: "Acquisition"},
+	{ID: "browsers", Title: "Browsers"},
+	{ID: "os", Title: "Operating systems"},
+	{ID: "devices", Title: "Devices"},
+	{ID: "viewports", Title: "Viewports"},
+	{ID: "referrers", Title: "Referrers"},
+	{ID: "languages", Title: "Languages"},
+}
+
+// dashboardWidgetsKey is the settings key holding the comma-separated widget layout.
+const dashboardWidgetsKey = "dashboard_widgets"
+
+// IsWidget reports whether id names a known dashboard widget.
+func IsWidget(id string) bool {
+	for _, w := range Widgets {
+		if w.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultWidgetIDs returns the IDs of all widgets in their default order.
+func DefaultWidgetIDs() []string {
+	ids := make([]string, len(Widgets))
+	for i, w := range Widgets {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+// DashboardWidgets returns the enabled widget IDs in display order.
+// Falls back to all widgets when no layout has been saved yet.
+func (s *Store) DashboardWidgets() ([]string, error) {
+	val, err := s.GetSetting(dashboardWidgetsKey)
+	if err != nil {
+		return nil, fmt.Errorf("read dashboard widgets: %w", err)
+	}
+	if val == "" {
+		return DefaultWidgetIDs(), nil
+	}
+
+	// Unknown IDs (e.g. from a removed widget) are skipped.
+	var ids []string
+	for _, id := range strings.Split(val, ",") {
+		if IsWidget(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// SetDashboardWidgets stores the enabled widget IDs in display order.
+// Unknown and duplicate IDs are rejected.
+func (s *Store) SetDashboardWidgets(ids []string) error {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !IsWidget(id) {
+			return fmt.Errorf("unknown dashboard widget %q", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate dashboard widget %q", id)
+		}
+		seen[id] = true
+	}
+	if len(ids) == 0 {
+		// An empty setting means "defaults", so store a sentinel that
+		// matches no widget to keep an intentionally empty dashboard.
+		return s.SetSetting(dashboardWidgetsKey, "none")
+	}
+	return s.SetSetting(dashboardWidgetsKey, strings.Join(ids, ","))
+}
@@ -0,0 +1,101 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestDashboardWidgets(t *testing.T) {
+	store := newTestStore(t)
+	ids, err := store.DashboardWidgets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, DefaultWidgetIDs()) {
+		t.Errorf("without a layout got %v, want every widget", ids)
+	}
+
+	for _, bad := range [][]string{{"summary", "nope"}, {"summary", "chart", "summary"}} {
+		if err := store.SetDashboardWidgets(bad); err == nil {
+			t.Errorf("SetDashboardWidgets(%v) accepted", bad)
+		}
+	}
+	if err := store.SetDashboardWidgets([]string{"languages", "summary"}); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := store.DashboardWidgets(); !reflect.DeepEqual(ids, []string{"languages", "summary"}) {
+		t.Errorf("got %v, want the saved order", ids)
+	}
+	// Widgets removed since the layout was saved are skipped.
+	if err := store.SetSetting("dashboard_widgets", "retired,summary"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := store.DashboardWidgets(); !reflect.DeepEqual(ids, []string{"summary"}) {
+		t.Errorf("got %v, want the known widgets", ids)
+	}
+	// An empty dashboard stays empty instead of falling back to the defaults.
+	if err := store.SetDashboardWidgets(nil); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := store.DashboardWidgets(); len(ids) != 0 {
+		t.Errorf("got %v, want no widgets", ids)
+	}
+}
+
+func TestSaveWidgets(t *testing.T) {
+	store := newTestStore(t)
+	h := NewHandler(store)
+	e := echo.New()
+	form := url.Values{
+		"widget":               {"summary", "exit_pages", "entry_pages"},
+		"position_summary":     {"3"},
+		"position_exit_pages":  {"2"},
+		"position_entry_pages": {"1"},
+		"position_browsers":    {"4"}, // unchecked
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/analytics/widgets/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	if err := h.SaveWidgets(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status %d, want 303", rec.Code)
+	}
+	want := []string{"entry_pages", "exit_pages", "summary"}
+	if ids, _ := store.DashboardWidgets(); !reflect.DeepEqual(ids, want) {
+		t.Errorf("saved %v, want %v", ids, want)
+	}
+	opts := widgetOptions(want)
+	if opts[0].ID != "entry_pages" || !opts[0].Enabled || opts[3].Enabled || opts[3].Position != 4 {
+		t.Errorf("widgetOptions = %+v, want the enabled widgets first", opts[:4])
+	}
+
+	// The dashboard renders only the enabled widgets, in order.
+	postCollect(t, h, "10.0.0.1", `{"path":"/landing/","language":"en"}`, nil)
+	req = httptest.NewRequest(http.MethodGet, "/admin/analytics/fragments/stats?period=today", nil)
+	rec = httptest.NewRecorder()
+	if err := h.GetStatsFragment(e.NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	body := rec.Body.String()
+	entry, exit, summary := strings.Index(body, "Top Entry Pages"), strings.Index(body, "Top Exit Pages"), strings.Index(body, "Unique Visitors")
+	if entry < 0 || entry > exit || exit > summary || !strings.Contains(body, "/landing/") {
+		t.Errorf("want entry pages, exit pages and the summary in order, got %s", body)
+	}
+	if strings.Contains(body, "Languages") || strings.Contains(body, "Browsers") {
+		t.Errorf("disabled widgets rendered in %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/analytics/widgets/", strings.NewReader("widget=nope"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := h.SaveWidgets(e.NewContext(req, httptest.NewRecorder())); err == nil {
+		t.Error("SaveWidgets accepted an unknown widget")
+	}
+}
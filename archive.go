@@ -0,0 +1,212 @@
+package pubengine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ArchiveYear is a year of published posts, for archive pages and sidebars.
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	Months []ArchiveMonth // newest first
+}
+
+// URL returns the path of the year's archive page, e.g. "/archive/2024/".
+func (y ArchiveYear) URL() string {
+	return fmt.Sprintf("/archive/%04d/", y.Year)
+}
+
+// ArchiveMonth is a month of published posts.
+type ArchiveMonth struct {
+	Month string     // "2006-01"
+	Title string     // e.g. "May 2024"
+	Count int        // number of posts
+	Posts []BlogPost // newest first; empty from Store.ArchiveCounts
+}
+
+// URL returns the path of the month's archive page, e.g. "/archive/2024/05/".
+func (m ArchiveMonth) URL() string {
+	return "/archive/" + strings.Replace(m.Month, "-", "/", 1) + "/"
+}
+
+// BuildArchive groups posts by year and month, newest first. posts must be
+// sorted by date descending, as ListPosts returns them. Posts without a
+// valid date are left out.
+func BuildArchive(posts []BlogPost) []ArchiveYear {
+	var years []ArchiveYear
+	for _, p := range posts {
+		t, err := time.Parse("2006-01-02", p.Date)
+		if err != nil {
+			continue
+		}
+		if len(years) == 0 || years[len(years)-1].Year != t.Year() {
+			years = append(years, ArchiveYear{Year: t.Year()})
+		}
+		y := &years[len(years)-1]
+		month := t.Format("2006-01")
+		if len(y.Months) == 0 || y.Months[len(y.Months)-1].Month != month {
+			y.Months = append(y.Months, ArchiveMonth{Month: month, Title: t.Format("January 2006")})
+		}
+		m := &y.Months[len(y.Months)-1]
+		m.Posts = append(m.Posts, p)
+		m.Count++
+		y.Count++
+	}
+	return years
+}
+
+// ArchiveCounts returns the number of published posts in each year and
+// month, newest first, without loading the posts.
+func (s *Store) ArchiveCounts() ([]ArchiveYear, error) {
+	rows, err := s.db.Query(`SELECT substr(date, 1, 7) AS month, COUNT(*) FROM posts WHERE published = 1 AND trashed_at = '' GROUP BY month ORDER BY month DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var years []ArchiveYear
+	for rows.Next() {
+		var month string
+		var count int
+		if err := rows.Scan(&month, &count); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			continue
+		}
+		if len(years) == 0 || years[len(years)-1].Year != t.Year() {
+			years = append(years, ArchiveYear{Year: t.Year()})
+		}
+		y := &years[len(years)-1]
+		y.Months = append(y.Months, ArchiveMonth{Month: month, Title: t.Format("January 2006"), Count: count})
+		y.Count += count
+	}
+	return years, rows.Err()
+}
+
+// archivePrefix returns the start of the dates of the year, or of one
+// month of it when month is 1-12.
+func archivePrefix(year, month int) string {
+	if month == 0 {
+		return fmt.Sprintf("%04d-", year)
+	}
+	return fmt.Sprintf("%04d-%02d-", year, month)
+}
+
+// ListPostsByYearMonth returns the published posts of a year, or of one
+// month of it when month is 1-12, newest first. A month of 0 lists the
+// whole year.
+func (s *Store) ListPostsByYearMonth(year, month int) ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT `+s.postColumns+` FROM posts WHERE published = 1 AND trashed_at = '' AND date LIKE ? ORDER BY date DESC`,
+		archivePrefix(year, month)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// ListPostsByYearMonth returns the cached published posts of a year, or of
+// one month of it when month is 1-12, newest first.
+func (c *PostCache) ListPostsByYearMonth(year, month int) ([]BlogPost, error) {
+	posts, _, err := c.ensureLoaded()
+	if err != nil {
+		return nil, err
+	}
+	prefix := archivePrefix(year, month)
+	var filtered []BlogPost
+	for _, p := range posts {
+		if strings.HasPrefix(p.Date, prefix) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+// Archive returns the cached published posts grouped by year and month.
+func (c *PostCache) Archive() ([]ArchiveYear, error) {
+	posts, _, err := c.ensureLoaded()
+	if err != nil {
+		return nil, err
+	}
+	return BuildArchive(posts), nil
+}
+
+func (a *App) handleArchive(c echo.Context) error {
+	if a.Views.Archive == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	archive, err := a.Cache.Archive()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.Archive(archive, a.Config.URL))
+}
+
+// archivePeriodKey is the request context key of the archive period.
+type archivePeriodKey struct{}
+
+// ArchivePeriod returns the period an archive page is limited to: "2024"
+// on /archive/2024/, "2024-05" on /archive/2024/05/, and "" on /archive/
+// and other pages. Archive views use it for titles and canonical URLs.
+func ArchivePeriod(ctx context.Context) string {
+	period, _ := ctx.Value(archivePeriodKey{}).(string)
+	return period
+}
+
+// ArchiveURL returns the absolute URL of the archive page of period, as
+// returned by ArchivePeriod: the whole archive, a year or a month.
+func ArchiveURL(siteURL, period string) string {
+	if period == "" {
+		return BuildURL(siteURL, "archive")
+	}
+	return BuildURL(siteURL, "archive", strings.Replace(period, "-", "/", 1))
+}
+
+// handleArchivePeriod renders the archive of one year, or of one month
+// when the route has a month. Periods without posts 404.
+func (a *App) handleArchivePeriod(c echo.Context) error {
+	if a.Views.Archive == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	yearParam, monthParam := c.Param("year"), c.Param("month")
+	year, err := strconv.Atoi(yearParam)
+	if err != nil || len(yearParam) != 4 || year < 1 {
+		return echo.ErrNotFound
+	}
+	period, month := yearParam, 0
+	if monthParam != "" {
+		month, err = strconv.Atoi(monthParam)
+		if err != nil || len(monthParam) != 2 || month < 1 || month > 12 {
+			return echo.ErrNotFound
+		}
+		period += "-" + monthParam
+	}
+	posts, err := a.Cache.ListPostsByYearMonth(year, month)
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return echo.ErrNotFound
+	}
+	req := c.Request()
+	c.SetRequest(req.WithContext(context.WithValue(req.Context(), archivePeriodKey{}, period)))
+	return Render(c, a.Views.Archive(BuildArchive(posts), a.Config.URL))
+}
@@ -0,0 +1,53 @@
+package pubengine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestArchivePeriodRoutes(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.Archive = func(archive []ArchiveYear, _ string) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			n := 0
+			for _, y := range archive {
+				n += y.Count
+			}
+			_, err := fmt.Fprintf(w, "%q %d %s", ArchivePeriod(ctx), n, ArchiveURL("https://example.com", ArchivePeriod(ctx)))
+			return err
+		})
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "a", Title: "A", Date: "2024-05-20", Published: true},
+		BlogPost{Slug: "b", Title: "B", Date: "2024-03-02", Published: true},
+		BlogPost{Slug: "c", Title: "C", Date: "2023-12-31", Published: true},
+	)
+	app.Cache.Invalidate()
+
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/archive/", http.StatusOK, `"" 3 https://example.com/archive/`},
+		{"/archive/2024/", http.StatusOK, `"2024" 2 https://example.com/archive/2024/`},
+		{"/archive/2024/03/", http.StatusOK, `"2024-03" 1 https://example.com/archive/2024/03/`},
+		{"/archive/2024/04/", http.StatusNotFound, ""},
+		{"/archive/2024/3/", http.StatusNotFound, ""},
+		{"/archive/2024/13/", http.StatusNotFound, ""},
+		{"/archive/24/", http.StatusNotFound, ""},
+	} {
+		rec := serveGet(app, tc.path)
+		if rec.Code != tc.code || (tc.body != "" && rec.Body.String() != tc.body) {
+			t.Errorf("GET %s = %d %q, want %d %q", tc.path, rec.Code, rec.Body.String(), tc.code, tc.body)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package pubengine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/eringen/pubengine/markdown"
+)
+
+// filesSubdir holds attachments, inside the uploads directory so that
+// deployments persisting uploads keep them too.
+const filesSubdir = "files"
+
+// AttachmentPolicy is a file type accepted by the attachment library.
+type AttachmentPolicy struct {
+	ContentType string // served Content-Type, matched against the sniffed type
+	Ext         string // extension given to stored files
+	MaxSize     int64  // largest accepted upload in bytes
+	Download    bool   // served as a download rather than shown in the browser
+}
+
+// AttachmentPolicies are the file types accepted by the attachment library.
+// The type of an upload is detected from its content, not its name.
+var AttachmentPolicies = []AttachmentPolicy{
+	{ContentType: "application/pdf", Ext: ".pdf", MaxSize: 50 << 20},
+	{ContentType: "audio/mpeg", Ext: ".mp3", MaxSize: 200 << 20},
+	{ContentType: "audio/ogg", Ext: ".ogg", MaxSize: 200 << 20},
+	{ContentType: "audio/wav", Ext: ".wav", MaxSize: 200 << 20},
+	{ContentType: "video/mp4", Ext: ".mp4", MaxSize: 500 << 20},
+	{ContentType: "video/webm", Ext: ".webm", MaxSize: 500 << 20},
+	{ContentType: "application/zip", Ext: ".zip", MaxSize: 100 << 20, Download: true},
+}
+
+// sniffAttachmentType returns the content type of a file from its first
+// bytes, normalized to the names used in AttachmentPolicies.
+func sniffAttachmentType(head []byte) string {
+	// MP3 files without an ID3 tag start with a frame sync, which
+	// http.DetectContentType doesn't recognize.
+	if len(head) > 1 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 {
+		return "audio/mpeg"
+	}
+	switch ct := http.DetectContentType(head); ct {
+	case "application/ogg":
+		return "audio/ogg"
+	case "audio/wave":
+		return "audio/wav"
+	default:
+		return ct
+	}
+}
+
+// attachmentPolicy returns the policy for contentType.
+func attachmentPolicy(contentType string) (AttachmentPolicy, bool) {
+	for _, p := range AttachmentPolicies {
+		if p.ContentType == contentType {
+			return p, true
+		}
+	}
+	return AttachmentPolicy{}, false
+}
+
+// attachmentsDir returns the directory attachments are stored in.
+func (a *App) attachmentsDir() string {
+	return filepath.Join(a.staticDir, uploadsSubdir, filesSubdir)
+}
+
+// uniqueAttachmentName appends a counter to base until no attachment file
+// or record uses the name.
+func (a *App) uniqueAttachmentName(base, ext string) string {
+	candidate := base + ext
+	for counter := 2; ; counter++ {
+		_, statErr := os.Stat(filepath.Join(a.attachmentsDir(), candidate))
+		_, dbErr := a.Store.GetAttachment(candidate)
+		if os.IsNotExist(statErr) && errors.Is(dbErr, sql.ErrNoRows) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, counter, ext)
+	}
+}
+
+func (a *App) handleAttachmentUpload(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			return err
+		}
+		return c.String(http.StatusBadRequest, "No file provided")
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return c.String(http.StatusBadRequest, "Empty file")
+	}
+	ct := sniffAttachmentType(head[:n])
+	policy, ok := attachmentPolicy(ct)
+	if !ok {
+		return c.String(http.StatusBadRequest, "Unsupported file type "+ct)
+	}
+	if file.Size > policy.MaxSize {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("File too large (max %dMB)", policy.MaxSize>>20))
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	dir := a.attachmentsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create files dir: %w", err)
+	}
+	name := a.uniqueAttachmentName(slugifyFilename(file.Filename), policy.Ext)
+	dst, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("create attachment: %w", err)
+	}
+	size, err := io.Copy(dst, io.LimitReader(src, policy.MaxSize))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(filepath.Join(dir, name))
+		return fmt.Errorf("write attachment: %w", err)
+	}
+
+	att := Attachment{
+		Filename:     name,
+		OriginalName: file.Filename,
+		ContentType:  ct,
+		Size:         size,
+		UploadedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if strings.HasPrefix(ct, "video/") {
+		a.processVideo(c, &att)
+	}
+	if err := a.Store.SaveAttachment(att); err != nil {
+		return err
+	}
+	return a.renderAttachmentList(c)
+}
+
+func (a *App) handleAttachmentDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+
+	filename := filepath.Base(c.Param("filename"))
+	if filename == "" || filename == "." || filename == "/" {
+		return c.String(http.StatusBadRequest, "Filename required")
+	}
+	_ = os.Remove(filepath.Join(a.attachmentsDir(), filename)) // ignore error if file already gone
+	if att, err := a.Store.GetAttachment(filename); err == nil && att.Poster != "" {
+		_ = os.Remove(filepath.Join(a.attachmentsDir(), att.Poster))
+	}
+	if err := a.Store.DeleteAttachment(filename); err != nil {
+		return err
+	}
+	return a.renderAttachmentList(c)
+}
+
+func (a *App) handleAttachmentList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderAttachmentList(c)
+}
+
+func (a *App) renderAttachmentList(c echo.Context) error {
+	if a.Views.AdminFiles == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	files, err := a.Store.ListAttachments()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminFiles(files, CsrfToken(c)))
+}
+
+// handleAttachment serves an attachment from the library with its detected
+// Content-Type. Zip archives and other Download types are sent as
+// downloads; PDFs, audio and video open in the browser.
+func (a *App) handleAttachment(c echo.Context) error {
+	att, err := a.Store.GetAttachment(c.Param("filename"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.attachmentsDir(), att.Filename)
+	c.Response().Header().Set(echo.HeaderContentType, att.ContentType)
+	if policy, ok := attachmentPolicy(att.ContentType); ok && policy.Download {
+		return c.Attachment(path, att.Filename)
+	}
+	return c.Inline(path, att.Filename)
+}
+
+// AttachmentURL returns the site path of an attachment, the link the
+// {{file name}} shortcode renders.
+func AttachmentURL(filename string) string {
+	return markdown.AttachmentsPath + filename
+}
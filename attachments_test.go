@@ -0,0 +1,87 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	for _, tc := range []struct {
+		head []byte
+		want string
+	}{
+		{[]byte("%PDF-1.7\n"), "application/pdf"},
+		{[]byte("ID3\x03\x00"), "audio/mpeg"},
+		{[]byte{0xFF, 0xFB, 0x90, 0x64}, "audio/mpeg"},
+		{[]byte("PK\x03\x04rest"), "application/zip"},
+		{[]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), "video/mp4"},
+		{[]byte("\x1A\x45\xDF\xA3\x9f\x42\x86\x81\x01"), "video/webm"},
+	} {
+		if got := sniffAttachmentType(tc.head); got != tc.want {
+			t.Errorf("sniffAttachmentType(%q) = %q, want %q", tc.head, got, tc.want)
+		}
+	}
+
+	app := newTestApp(t)
+	app.staticDir = t.TempDir()
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := os.MkdirAll(app.attachmentsDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []Attachment{
+		{Filename: "slides.pdf", OriginalName: "Slides.pdf", ContentType: "application/pdf", Size: 9, UploadedAt: "2024-01-01T00:00:00Z"},
+		{Filename: "code.zip", OriginalName: "code.zip", ContentType: "application/zip", Size: 8, UploadedAt: "2024-01-02T00:00:00Z"},
+		{Filename: "talk.mp4", OriginalName: "Talk.mp4", ContentType: "video/mp4", Size: 8, UploadedAt: "2023-12-01T00:00:00Z",
+			Width: 1280, Height: 720, Duration: 95, Poster: posterFilename("talk.mp4")},
+	} {
+		if err := os.WriteFile(filepath.Join(app.attachmentsDir(), a.Filename), []byte("contents"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Store.SaveAttachment(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(app.attachmentsDir(), "talk.mp4.poster.jpg"), []byte("\xFF\xD8\xFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := app.Store.ListAttachments()
+	if err != nil || len(files) != 3 || files[0].Filename != "code.zip" || files[2].Width != 1280 || files[2].Duration != 95 {
+		t.Fatalf("ListAttachments = %+v, %v", files, err)
+	}
+	if name := app.uniqueAttachmentName("slides", ".pdf"); name != "slides-2.pdf" {
+		t.Errorf("uniqueAttachmentName = %q, want slides-2.pdf", name)
+	}
+
+	for path, want := range map[string][2]string{
+		"/files/slides.pdf": {"application/pdf", `inline; filename="slides.pdf"`},
+		"/files/code.zip":   {"application/zip", `attachment; filename="code.zip"`},
+		"/files/talk.mp4":   {"video/mp4", `inline; filename="talk.mp4"`},
+	} {
+		rec := serveGet(app, path)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != want[0] || rec.Header().Get("Content-Disposition") != want[1] {
+			t.Errorf("GET %s = %d %v", path, rec.Code, rec.Header())
+		}
+	}
+	rec := serveGet(app, "/files/talk.mp4/poster.jpg")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("GET poster = %d %v", rec.Code, rec.Header())
+	}
+	for _, path := range []string{"/files/missing.pdf", "/files/slides.pdf/poster.jpg"} {
+		rec := serveGet(app, path)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+	}
+
+	if err := app.Store.DeleteAttachment("code.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Store.GetAttachment("code.zip"); err != sql.ErrNoRows {
+		t.Errorf("GetAttachment after delete = %v, want sql.ErrNoRows", err)
+	}
+}
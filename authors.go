@@ -0,0 +1,266 @@
+package pubengine
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/eringen/pubengine/markdown"
+	"github.com/labstack/echo/v4"
+)
+
+// Author is a person posts are attributed to. Posts name their author with
+// BlogPost.AuthorSlug; posts without one are by SiteConfig.Author.
+type Author struct {
+	Slug   string
+	Name   string
+	Bio    string // Markdown
+	URL    string // the author's own site or profile, optional
+	Avatar string // avatar image, a site path like "/public/uploads/jane.jpg" or an absolute URL, optional
+	Email  string // used only to look up a Gravatar when Avatar is empty, never shown, optional
+}
+
+// Link returns the path of the author's archive page.
+func (au Author) Link() string {
+	return "/author/" + au.Slug + "/"
+}
+
+// AvatarURL returns the author's avatar at size pixels square: Avatar when
+// set, otherwise the Gravatar for Email, or "" with neither. Gravatar falls
+// back to a generic silhouette for addresses without one.
+func (au Author) AvatarURL(size int) string {
+	if au.Avatar != "" {
+		return au.Avatar
+	}
+	email := strings.ToLower(strings.TrimSpace(au.Email))
+	if email == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(email))
+	return fmt.Sprintf("https://gravatar.com/avatar/%s?s=%d&d=mp", hex.EncodeToString(sum[:]), size)
+}
+
+// AuthorCard renders an author's avatar, linked name and Markdown bio as
+// an <aside class="author-card">, for the end of posts and author pages.
+// Themes style it through the author-card classes.
+func AuthorCard(au Author, cfg SiteConfig) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		var b strings.Builder
+		b.WriteString(`<aside class="author-card">` + "\n")
+		if src := au.AvatarURL(128); src != "" {
+			fmt.Fprintf(&b, `<img class="author-card-avatar" src="%s" alt="" width="64" height="64" loading="lazy">`+"\n",
+				html.EscapeString(cfg.mediaURL(src)))
+		}
+		fmt.Fprintf(&b, `<div class="author-card-body">`+"\n"+`<p class="author-card-name"><a href="%s" rel="author">%s</a></p>`+"\n",
+			html.EscapeString(au.Link()), html.EscapeString(au.Name))
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+		if au.Bio != "" {
+			if _, err := io.WriteString(w, `<div class="author-card-bio">`); err != nil {
+				return err
+			}
+			if err := markdown.Markdown(au.Bio).Render(ctx, w); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "</div>\n"); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "</div>\n</aside>\n")
+		return err
+	})
+}
+
+// Byline returns the name of the post's author, or SiteConfig.Author for
+// posts without one.
+func (p BlogPost) Byline(cfg SiteConfig) string {
+	if p.AuthorName != "" {
+		return p.AuthorName
+	}
+	return cfg.Author
+}
+
+// SaveAuthor upserts an author by slug.
+func (s *Store) SaveAuthor(au Author) error {
+	_, err := s.db.Exec(`INSERT INTO authors (slug, name, bio, url, avatar, email) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(slug) DO UPDATE SET name = excluded.name, bio = excluded.bio, url = excluded.url, avatar = excluded.avatar, email = excluded.email`,
+		au.Slug, au.Name, au.Bio, au.URL, au.Avatar, au.Email)
+	return err
+}
+
+// EnsureAuthor creates the author slug, named after it, unless it exists.
+func (s *Store) EnsureAuthor(slug string) error {
+	_, err := s.db.Exec(`INSERT INTO authors (slug, name) VALUES (?, ?) ON CONFLICT(slug) DO NOTHING`, slug, authorName(slug))
+	return err
+}
+
+// GetAuthor returns an author by slug.
+func (s *Store) GetAuthor(slug string) (Author, error) {
+	au := Author{Slug: slug}
+	err := s.db.QueryRow(`SELECT name, bio, url, avatar, email FROM authors WHERE slug = ?`, slug).Scan(&au.Name, &au.Bio, &au.URL, &au.Avatar, &au.Email)
+	if err != nil {
+		return Author{}, err
+	}
+	return au, nil
+}
+
+// ListAuthors returns every author ordered by name.
+func (s *Store) ListAuthors() ([]Author, error) {
+	rows, err := s.db.Query(`SELECT slug, name, bio, url, avatar, email FROM authors ORDER BY name, slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []Author
+	for rows.Next() {
+		var au Author
+		if err := rows.Scan(&au.Slug, &au.Name, &au.Bio, &au.URL, &au.Avatar, &au.Email); err != nil {
+			return nil, err
+		}
+		authors = append(authors, au)
+	}
+	return authors, rows.Err()
+}
+
+// DeleteAuthor removes an author. Their posts are kept and fall back to
+// SiteConfig.Author.
+func (s *Store) DeleteAuthor(slug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE posts SET author_slug = '' WHERE author_slug = ?`, slug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM authors WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// authorName turns an author slug into a name, "jane-doe" into "Jane Doe",
+// for authors created from the post form or frontmatter.
+func authorName(slug string) string {
+	words := strings.Split(slug, "-")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// withAuthor sets post.Author from the cache when the post has an author.
+func (a *App) withAuthor(post BlogPost) (BlogPost, error) {
+	if post.AuthorSlug == "" {
+		return post, nil
+	}
+	au, err := a.Cache.GetAuthor(post.AuthorSlug)
+	if err == sql.ErrNoRows {
+		return post, nil
+	}
+	if err != nil {
+		return post, err
+	}
+	post.Author = &au
+	return post, nil
+}
+
+func (a *App) handleAuthor(c echo.Context) error {
+	if a.Views.Author == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	slug := c.Param("slug")
+	au, err := a.Cache.GetAuthor(slug)
+	if err == sql.ErrNoRows {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	posts, err := a.Cache.ListPostsByAuthor(slug)
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return echo.ErrNotFound
+	}
+	return Render(c, a.Views.Author(au, posts, a.Config.URL))
+}
+
+func (a *App) handleAuthorList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderAuthorList(c)
+}
+
+func (a *App) handleAuthorSave(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	au := Author{
+		Slug:   strings.TrimSpace(c.FormValue("slug")),
+		Name:   strings.TrimSpace(c.FormValue("name")),
+		Bio:    strings.TrimSpace(c.FormValue("bio")),
+		URL:    strings.TrimSpace(c.FormValue("url")),
+		Avatar: strings.TrimSpace(c.FormValue("avatar")),
+		Email:  strings.TrimSpace(c.FormValue("email")),
+	}
+	if au.Slug == "" {
+		au.Slug = au.Name
+	}
+	au.Slug = Slugify(au.Slug)
+	if msg := ValidateSlug(au.Slug); msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	if au.Name == "" {
+		au.Name = authorName(au.Slug)
+	}
+	if au.URL != "" && !strings.HasPrefix(au.URL, "https://") && !strings.HasPrefix(au.URL, "http://") {
+		return c.String(http.StatusBadRequest, "Author URL must start with http:// or https://")
+	}
+	if au.Avatar != "" && !validImageRef(au.Avatar) {
+		return c.String(http.StatusBadRequest, "Avatar must be a site path like /public/uploads/jane.jpg or an http(s) URL")
+	}
+	if au.Email != "" && !strings.Contains(au.Email, "@") {
+		return c.String(http.StatusBadRequest, "Email must be an email address")
+	}
+	if err := a.Store.SaveAuthor(au); err != nil {
+		return err
+	}
+	a.Cache.Invalidate()
+	return a.renderAuthorList(c)
+}
+
+func (a *App) handleAuthorDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if err := a.Store.DeleteAuthor(c.Param("slug")); err != nil {
+		return err
+	}
+	a.Cache.Invalidate()
+	return a.renderAuthorList(c)
+}
+
+func (a *App) renderAuthorList(c echo.Context) error {
+	if a.Views.AdminAuthors == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	authors, err := a.Store.ListAuthors()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminAuthors(authors, CsrfToken(c)))
+}
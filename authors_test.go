@@ -0,0 +1,110 @@
+package pubengine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestAuthors(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.Author = "Site Owner"
+	app.Views.Author = func(au Author, posts []BlogPost, _ string) templ.Component {
+		var slugs []string
+		for _, p := range posts {
+			slugs = append(slugs, p.Slug)
+		}
+		return templ.Raw(au.Name + ": " + strings.Join(slugs, ","))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "one", Title: "One", Date: "2024-01-01", AuthorSlug: "jane-doe", Published: true},
+		BlogPost{Slug: "two", Title: "Two", Date: "2024-02-01", AuthorSlug: "jane-doe", Published: true},
+		BlogPost{Slug: "three", Title: "Three", Date: "2024-03-01", Published: true},
+	)
+
+	p, err := app.Store.GetPost("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AuthorName != "Jane Doe" || p.Byline(app.Config) != "Jane Doe" {
+		t.Errorf("author name = %q, byline %q, want Jane Doe", p.AuthorName, p.Byline(app.Config))
+	}
+	if ld := BlogPostingJsonLD(p, app.Config); !strings.Contains(ld, `"name":"Jane Doe"`) || !strings.Contains(ld, `"url":"http://localhost:3000/author/jane-doe/"`) {
+		t.Errorf("JSON-LD missing the post author: %s", ld)
+	}
+	three, _ := app.Store.GetPost("three")
+	if three.Byline(app.Config) != "Site Owner" {
+		t.Errorf("byline without an author = %q, want the site author", three.Byline(app.Config))
+	}
+
+	get := func(path string) (int, string) {
+		rec := serveGet(app, path)
+		return rec.Code, rec.Body.String()
+	}
+	if code, body := get("/author/jane-doe/"); code != http.StatusOK || body != "Jane Doe: two,one" {
+		t.Errorf("GET /author/jane-doe/ = %d %q", code, body)
+	}
+	if code, _ := get("/author/nobody/"); code != http.StatusNotFound {
+		t.Errorf("GET /author/nobody/ = %d, want 404", code)
+	}
+	_, feed := get("/feed.xml")
+	for _, want := range []string{`xmlns:dc="http://purl.org/dc/elements/1.1/"`, "<dc:creator>Jane Doe</dc:creator>", "<dc:creator>Site Owner</dc:creator>"} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed missing %s", want)
+		}
+	}
+
+	if err := app.Store.SaveAuthor(Author{Slug: "jane-doe", Name: "Jane Q. Doe", Bio: "Writes about Go."}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := app.Store.GetPost("one"); p.AuthorName != "Jane Q. Doe" {
+		t.Errorf("author name after SaveAuthor = %q", p.AuthorName)
+	}
+	au, err := app.Store.GetAuthor("jane-doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if au.AvatarURL(64) != "" {
+		t.Errorf("AvatarURL without avatar or email = %q", au.AvatarURL(64))
+	}
+	au.Email = " Jane@Example.com"
+	if got := au.AvatarURL(64); got != "https://gravatar.com/avatar/"+sha256Hex("jane@example.com")+"?s=64&d=mp" {
+		t.Errorf("Gravatar URL = %q", got)
+	}
+	au.Avatar = "/public/uploads/jane.jpg"
+	if err := app.Store.SaveAuthor(au); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := app.Store.GetAuthor("jane-doe"); got != au {
+		t.Errorf("GetAuthor = %+v, want %+v", got, au)
+	}
+	var card strings.Builder
+	if err := AuthorCard(au, app.Config).Render(context.Background(), &card); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`src="/public/uploads/jane.jpg"`, `<a href="/author/jane-doe/" rel="author">Jane Q. Doe</a>`, "<p>Writes about Go."} {
+		if !strings.Contains(card.String(), want) {
+			t.Errorf("author card missing %s:\n%s", want, card.String())
+		}
+	}
+
+	if err := app.Store.DeleteAuthor("jane-doe"); err != nil {
+		t.Fatalf("DeleteAuthor: %v", err)
+	}
+	if p, _ := app.Store.GetPost("one"); p.AuthorSlug != "" || p.AuthorName != "" {
+		t.Errorf("post still has deleted author: %q %q", p.AuthorSlug, p.AuthorName)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
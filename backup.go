@@ -0,0 +1,326 @@
+package pubengine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// backupJobKind is the job kind of database backups.
+const backupJobKind = "db_backup"
+
+// backupCheckInterval is how often the scheduler looks for a due backup.
+const backupCheckInterval = time.Hour
+
+// Settings recording backups.
+const (
+	backupQueuedKey = "backup_queued_at" // RFC3339 time the last backup job was queued
+	backupLastKey   = "backup_last_at"   // RFC3339 time of the last verified backup
+)
+
+// backupStamp names snapshots; keys sort in the order they were taken.
+const backupStamp = "20060102T150405Z"
+
+// snapshotter is what a backup needs from Store and analytics.Store.
+type snapshotter interface {
+	Snapshot(path string) error
+}
+
+// backupDB is a database backed up under its name.
+type backupDB struct {
+	name  string
+	store snapshotter
+}
+
+// BackupsEnabled reports whether scheduled snapshots are taken, which
+// needs a backup directory or a bucket and credentials.
+func (c *SiteConfig) BackupsEnabled() bool {
+	return c.BackupDir != "" || c.backupToS3()
+}
+
+func (c *SiteConfig) backupToS3() bool {
+	return c.BackupS3Bucket != "" && c.BackupS3AccessKey != "" && c.BackupS3SecretKey != ""
+}
+
+func (c *SiteConfig) backupBucket() s3Bucket {
+	return s3Bucket{
+		Endpoint:  c.BackupS3Endpoint,
+		Bucket:    c.BackupS3Bucket,
+		Region:    c.BackupS3Region,
+		AccessKey: c.BackupS3AccessKey,
+		SecretKey: c.BackupS3SecretKey,
+	}
+}
+
+// Backup snapshots the blog database when it is SQLite, and the analytics
+// database when analytics is enabled, and saves each snapshot as
+// <name>-<time>.db in BackupDir and as <BackupS3Prefix><name>-<time>.db in
+// the backup bucket, whichever are configured. Every copy is checked before
+// older snapshots beyond BackupKeep are deleted, so a broken copy never
+// rotates out a good one. The scheduler runs it every BackupInterval from
+// the job queue. Backups run one at a time; a call made while one runs
+// waits for it to finish.
+func (a *App) Backup() error {
+	if !a.Config.BackupsEnabled() {
+		return errors.New("backup: no backup directory or bucket configured")
+	}
+	return a.backup(time.Now())
+}
+
+func (a *App) backup(now time.Time) error {
+	a.backupMu.Lock()
+	defer a.backupMu.Unlock()
+	// Snapshots are SQLite's VACUUM INTO, so a PostgreSQL or MySQL blog
+	// database is skipped; Setup warns about it.
+	var dbs []backupDB
+	if a.Config.usesSQLite() {
+		dbs = append(dbs, backupDB{"blog", a.Store})
+	}
+	if a.analyticsStore != nil {
+		dbs = append(dbs, backupDB{"analytics", a.analyticsStore})
+	}
+	if len(dbs) == 0 {
+		return fmt.Errorf("backup: nothing to back up: the %s blog database can't be snapshotted and analytics is off", a.Config.DatabaseDriver)
+	}
+
+	dir, err := os.MkdirTemp("", "pubengine-backup-")
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bucket := a.Config.backupBucket()
+	stamp := now.UTC().Format(backupStamp)
+	for _, db := range dbs {
+		if a.Config.BackupDir != "" {
+			if err := backupToDir(db, a.Config.BackupDir, stamp, a.Config.BackupKeep); err != nil {
+				return fmt.Errorf("backup %s: %w", db.name, err)
+			}
+		}
+		if !a.Config.backupToS3() {
+			continue
+		}
+		prefix := a.Config.BackupS3Prefix + db.name + "-"
+		key := prefix + stamp + ".db"
+		path := filepath.Join(dir, db.name+".db")
+		if err := db.store.Snapshot(path); err != nil {
+			return fmt.Errorf("backup %s: snapshot: %w", db.name, err)
+		}
+		if err := bucket.Put(key, path); err != nil {
+			return fmt.Errorf("backup %s: %w", db.name, err)
+		}
+		if err := verifyBackup(bucket, key, path, filepath.Join(dir, db.name+"-restored.db")); err != nil {
+			// Don't let a broken snapshot count towards BackupKeep.
+			bucket.Delete(key)
+			return fmt.Errorf("backup %s: verify %s: %w", db.name, key, err)
+		}
+		if err := rotateBackups(bucket, prefix, a.Config.BackupKeep); err != nil {
+			return fmt.Errorf("backup %s: %w", db.name, err)
+		}
+	}
+	return a.Store.SetSetting(backupLastKey, now.UTC().Format(time.RFC3339))
+}
+
+// verifyBackup downloads key to restored, checks that it matches the
+// snapshot at path byte for byte, and that SQLite finds it intact.
+func verifyBackup(bucket s3Bucket, key, path, restored string) error {
+	want, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(restored)
+	if err != nil {
+		return err
+	}
+	err = bucket.Get(key, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	got, err := fileSHA256(restored)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("downloaded snapshot differs from the upload")
+	}
+	return checkSnapshot(restored)
+}
+
+// checkSnapshot checks that SQLite finds the database file at path intact.
+func checkSnapshot(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	return nil
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// backupToDir snapshots db into dir as <name>-<stamp>.db, checks it, and
+// deletes all but the keep newest snapshots of db there. The snapshot is
+// written under a temporary name first, so a failed one is never counted.
+func backupToDir(db backupDB, dir, stamp string, keep int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, db.name+"-"+stamp+".db")
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := db.store.Snapshot(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := checkSnapshot(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("verify %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	var errs []error
+	for _, name := range oldSnapshots(names, db.name+"-", keep) {
+		errs = append(errs, os.Remove(filepath.Join(dir, name)))
+	}
+	return errors.Join(errs...)
+}
+
+// rotateBackups deletes all but the keep newest snapshots starting with
+// prefix.
+func rotateBackups(bucket s3Bucket, prefix string, keep int) error {
+	keys, err := bucket.List(prefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range oldSnapshots(keys, prefix, keep) {
+		errs = append(errs, bucket.Delete(key))
+	}
+	return errors.Join(errs...)
+}
+
+// oldSnapshots returns the names of snapshots starting with prefix in
+// sorted names, all but the keep newest. Other names are left alone.
+func oldSnapshots(names []string, prefix string, keep int) []string {
+	var snapshots []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if stamp, ok := strings.CutSuffix(strings.TrimPrefix(name, prefix), ".db"); ok && len(stamp) == len(backupStamp) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	if len(snapshots) <= keep {
+		return nil
+	}
+	return snapshots[:len(snapshots)-keep]
+}
+
+// handleAdminBackup downloads a snapshot of the blog database, when it is
+// SQLite. The snapshot is taken before anything is sent, so a failure is
+// an error response rather than a truncated file.
+func (a *App) handleAdminBackup(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if !a.Config.usesSQLite() {
+		return c.NoContent(http.StatusNotFound)
+	}
+	dir, err := os.MkdirTemp("", "pubengine-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blog.db")
+	if err := a.Store.Snapshot(path); err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Attachment(path, "blog-"+time.Now().UTC().Format(backupStamp)+".db")
+}
+
+// runBackup runs a backup job.
+func (a *App) runBackup([]byte) error {
+	return a.Backup()
+}
+
+// startBackups queues a backup job whenever the last one was queued
+// BackupInterval or more ago, checking now and every hour. The time is
+// kept in the database, so restarts don't skip or repeat backups. Returns
+// a stop function.
+func (a *App) startBackups() func() {
+	check := func() {
+		last, err := a.Store.GetSetting(backupQueuedKey)
+		if err != nil {
+			a.Echo.Logger.Errorf("backup schedule: %v", err)
+			return
+		}
+		if t, err := time.Parse(time.RFC3339, last); err == nil && time.Since(t) < a.Config.BackupInterval {
+			return
+		}
+		if err := a.Enqueue(backupJobKind, nil); err != nil {
+			a.Echo.Logger.Errorf("queue backup: %v", err)
+			return
+		}
+		if err := a.Store.SetSetting(backupQueuedKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			a.Echo.Logger.Errorf("backup schedule: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(backupCheckInterval)
+	done := make(chan struct{})
+	go func() {
+		check()
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,168 @@
+package pubengine
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackup(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	corrupt := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/backups/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/backups":
+			var body strings.Builder
+			body.WriteString("<ListBucketResult>")
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					body.WriteString("<Contents><Key>" + k + "</Key></Contents>")
+				}
+			}
+			body.WriteString("<IsTruncated>false</IsTruncated></ListBucketResult>")
+			io.WriteString(w, body.String())
+		case r.Method == http.MethodPut:
+			objects[key], _ = io.ReadAll(r.Body)
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if corrupt {
+				data = data[:len(data)/2]
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+		}
+	}))
+	defer srv.Close()
+
+	app := newTestApp(t)
+	if err := app.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Backup(); err == nil {
+		t.Error("Backup without a bucket succeeded")
+	}
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Content: "Hi", Published: true})
+	app.Config.BackupS3Endpoint = srv.URL
+	app.Config.BackupS3Bucket = "backups"
+	app.Config.BackupS3AccessKey = "AKID"
+	app.Config.BackupS3SecretKey = "secret"
+	app.Config.BackupS3Prefix = "site/"
+	app.Config.BackupKeep = 2
+
+	day := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err := app.backup(day.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("backup %d: %v", i, err)
+		}
+	}
+	var keys []string
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	want := []string{"site/blog-20261002T030000Z.db", "site/blog-20261003T030000Z.db"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("objects = %v, want %v", keys, want)
+	}
+	if last, _ := app.Store.GetSetting(backupLastKey); last != "2026-10-03T03:00:00Z" {
+		t.Errorf("last backup = %q", last)
+	}
+
+	// Restore the newest snapshot and read the post back.
+	path := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(path, objects[want[1]], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if post, err := restored.GetPost("hello"); err != nil || post.Title != "Hello" {
+		t.Errorf("restored GetPost = %+v, %v", post, err)
+	}
+
+	// A snapshot that doesn't download intact is deleted, and older ones kept.
+	corrupt = true
+	if err := app.backup(day.AddDate(0, 0, 3)); err == nil {
+		t.Fatal("backup with a corrupted download succeeded")
+	}
+	if _, ok := objects["site/blog-20261004T030000Z.db"]; ok || len(objects) != 2 {
+		t.Errorf("objects after a failed verify = %d, want the 2 earlier ones", len(objects))
+	}
+}
+
+func TestBackupDir(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blog-notes.db"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app.Config.BackupDir = dir
+	app.Config.BackupKeep = 2
+	if !app.Config.BackupsEnabled() {
+		t.Fatal("BackupDir doesn't turn on backups")
+	}
+
+	day := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err := app.backup(day.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("backup %d: %v", i, err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"blog-20261002T030000Z.db", "blog-20261003T030000Z.db", "blog-notes.db"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("backup dir = %v, want %v", names, want)
+	}
+
+	var buf bytes.Buffer
+	if err := app.Store.Backup(&buf); err != nil {
+		t.Fatalf("Store.Backup: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("SQLite format 3\x00")) {
+		t.Errorf("Store.Backup wrote %d bytes that aren't a SQLite database", buf.Len())
+	}
+	// Only SQLite is snapshotted.
+	pg := &Store{db: &sqlDB{DB: app.Store.db.DB, dialect: dialectPostgres}}
+	if err := pg.Backup(&buf); err != ErrSnapshotUnsupported {
+		t.Errorf("Backup on PostgreSQL = %v, want ErrSnapshotUnsupported", err)
+	}
+
+	rec := serveGet(app, "/admin/backup/")
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("GET /admin/backup/ signed out = %d, want 303", rec.Code)
+	}
+}
@@ -0,0 +1,91 @@
+package pubengine
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/eringen/pubengine/analytics"
+)
+
+// multipartOverhead is added to upload limits for the form fields and
+// part headers around the file.
+const multipartOverhead = 1 << 20
+
+// WithBodyLimit sets the largest request body accepted on paths starting
+// with prefix, overriding SiteConfig.MaxBodySize and the built-in limits.
+// The longest matching prefix wins.
+func WithBodyLimit(prefix string, limit int64) Option {
+	return func(a *App) {
+		if a.bodyLimits == nil {
+			a.bodyLimits = make(map[string]int64)
+		}
+		a.bodyLimits[prefix] = limit
+	}
+}
+
+// defaultBodyLimits are the built-in per-route limits: tiny for the login
+// form and the analytics collect endpoint, large enough for the biggest
+// accepted file on upload routes.
+func defaultBodyLimits() map[string]int64 {
+	var maxAttachment int64
+	for _, p := range AttachmentPolicies {
+		maxAttachment = max(maxAttachment, p.MaxSize)
+	}
+	return map[string]int64{
+		"/admin/login/":          16 << 10,
+		"/api/analytics/collect": analytics.MaxCollectBytes,
+		"/admin/images/upload/":  maxUploadSize + multipartOverhead,
+		"/admin/files/upload/":   maxAttachment + multipartOverhead,
+		"/admin/import/":         maxImportSize + multipartOverhead,
+	}
+}
+
+// setupBodyLimits merges the WithBodyLimit overrides into the built-in
+// limits. AttachmentPolicies must be final by then.
+func (a *App) setupBodyLimits() {
+	limits := defaultBodyLimits()
+	for prefix, n := range a.bodyLimits {
+		limits[prefix] = n
+	}
+	a.bodyLimits = limits
+}
+
+// bodyLimit returns the body size limit for path.
+func (a *App) bodyLimit(path string) int64 {
+	limit, matched := a.Config.MaxBodySize, -1
+	for prefix, n := range a.bodyLimits {
+		if len(prefix) > matched && strings.HasPrefix(path, prefix) {
+			limit, matched = n, len(prefix)
+		}
+	}
+	return limit
+}
+
+// isBodyTooLarge reports whether err comes from reading past the body
+// limit.
+func isBodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// bodyLimitMiddleware rejects requests whose Content-Length is over the
+// path's limit, and caps the body of the rest, so a single large POST can't
+// exhaust memory. Reading past the cap fails with *http.MaxBytesError,
+// which httpErrorHandler turns into a 413.
+func (a *App) bodyLimitMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Body == nil || req.Body == http.NoBody {
+			return next(c)
+		}
+		limit := a.bodyLimit(req.URL.Path)
+		if req.ContentLength > limit {
+			return echo.ErrStatusRequestEntityTooLarge
+		}
+		req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+		return next(c)
+	}
+}
@@ -0,0 +1,55 @@
+package pubengine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestBodyLimit(t *testing.T) {
+	app := newTestApp(t)
+	WithBodyLimit("/api/analytics/echo", 10)(app)
+	app.Echo.POST("/api/analytics/echo", func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "text/plain", body)
+	})
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	post := func(path string, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		app.Echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/api/analytics/echo", "hello", false); rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("small body = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := post("/api/analytics/echo", "hello world", false); rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), `"payload_too_large"`) {
+		t.Errorf("large body = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := post("/api/analytics/echo", "hello world", true); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large chunked body = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := post("/admin/login/", "password="+strings.Repeat("x", 20<<10), false); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large login body = %d", rec.Code)
+	}
+
+	if got := app.bodyLimit("/admin/save/"); got != 1<<20 {
+		t.Errorf("default limit = %d", got)
+	}
+	if got := app.bodyLimit("/admin/files/upload/"); got != 500<<20+multipartOverhead {
+		t.Errorf("attachment upload limit = %d", got)
+	}
+}
@@ -10,18 +10,28 @@ import (
 // ErrNotFound is returned when a requested post does not exist.
 var ErrNotFound = sql.ErrNoRows
 
-// PostCache is an in-memory cache of published blog posts and tags with TTL.
+// PostCache is an in-memory cache of published blog posts, tags, series,
+// authors and links between posts with TTL. With a ContentStore that has
+// ListPostSummaries, like Store, listed posts have no Content, and GetPost
+// reads the content of a post when it is first asked for.
 type PostCache struct {
 	mu      sync.RWMutex
 	posts   []BlogPost
+	full    map[string]BlogPost // posts with their content, by slug
 	tags    []string
+	series  []Series
+	authors []Author
+	links   []PostLink
 	fetched time.Time
 	ttl     time.Duration
-	store   *Store
+	store   ContentStore
 }
 
-// NewPostCache creates a PostCache backed by the given Store.
-func NewPostCache(s *Store, ttl time.Duration) *PostCache {
+// NewPostCache creates a PostCache backed by the given ContentStore. Series,
+// authors and links between posts come from the ContentStore too if it has
+// ListSeries, ListAuthors and ListPostLinks methods like Store; without
+// ListPostLinks links are found in the content of published posts.
+func NewPostCache(s ContentStore, ttl time.Duration) *PostCache {
 	return &PostCache{store: s, ttl: ttl}
 }
 
@@ -33,7 +43,11 @@ func (c *PostCache) valid() bool {
 func (c *PostCache) Invalidate() {
 	c.mu.Lock()
 	c.posts = nil
+	c.full = nil
 	c.tags = nil
+	c.series = nil
+	c.authors = nil
+	c.links = nil
 	c.mu.Unlock()
 }
 
@@ -41,7 +55,13 @@ func (c *PostCache) load() error {
 	if c.valid() {
 		return nil
 	}
-	posts, err := c.store.ListPosts("")
+	var posts []BlogPost
+	var err error
+	if sl, ok := c.store.(postSummaryLister); ok {
+		posts, err = sl.ListPostSummaries("")
+	} else {
+		posts, err = c.store.ListPosts("")
+	}
 	if err != nil {
 		return err
 	}
@@ -49,8 +69,38 @@ func (c *PostCache) load() error {
 	if err != nil {
 		return err
 	}
+	var series []Series
+	if sl, ok := c.store.(seriesLister); ok {
+		if series, err = sl.ListSeries(); err != nil {
+			return err
+		}
+	}
+	var authors []Author
+	if al, ok := c.store.(authorLister); ok {
+		if authors, err = al.ListAuthors(); err != nil {
+			return err
+		}
+	}
+	var links []PostLink
+	if ll, ok := c.store.(postLinkLister); ok {
+		if links, err = ll.ListPostLinks(); err != nil {
+			return err
+		}
+	} else {
+		withContent := posts
+		if _, ok := c.store.(postSummaryLister); ok {
+			if withContent, err = c.store.ListPosts(""); err != nil {
+				return err
+			}
+		}
+		links = linksBetween(withContent)
+	}
 	c.posts = posts
+	c.full = make(map[string]BlogPost)
 	c.tags = tags
+	c.series = series
+	c.authors = authors
+	c.links = links
 	c.fetched = time.Now()
 	return nil
 }
@@ -74,7 +124,8 @@ func (c *PostCache) ensureLoaded() ([]BlogPost, []string, error) {
 	return c.posts, c.tags, nil
 }
 
-// ListPosts returns published posts, optionally filtered by tag.
+// ListPosts returns published posts, optionally filtered by tag, without
+// their content if the ContentStore lists summaries.
 func (c *PostCache) ListPosts(tag string) ([]BlogPost, error) {
 	posts, _, err := c.ensureLoaded()
 	if err != nil {
@@ -102,20 +153,124 @@ func (c *PostCache) ListTags() ([]string, error) {
 	return tags, err
 }
 
-// GetPost returns a single published post by slug from the cache.
+// GetPost returns a single published post by slug, with its content, from
+// the cache.
 func (c *PostCache) GetPost(slug string) (BlogPost, error) {
 	posts, _, err := c.ensureLoaded()
 	if err != nil {
 		return BlogPost{}, err
 	}
+	_, summaries := c.store.(postSummaryLister)
 	for _, p := range posts {
-		if p.Slug == slug {
+		if p.Slug != slug {
+			continue
+		}
+		if !summaries {
 			return p, nil
 		}
+		c.mu.RLock()
+		full, ok := c.full[slug]
+		loaded := c.fetched
+		c.mu.RUnlock()
+		if ok {
+			return full, nil
+		}
+		full, err := c.store.GetPost(slug)
+		if err != nil {
+			return BlogPost{}, err
+		}
+		// Keep it only if the cache wasn't reloaded meanwhile.
+		c.mu.Lock()
+		if c.full != nil && c.fetched.Equal(loaded) {
+			c.full[slug] = full
+		}
+		c.mu.Unlock()
+		return full, nil
 	}
 	return BlogPost{}, ErrNotFound
 }
 
+// ListBacklinks returns the published posts that link to the post slug,
+// newest first, from the cache.
+func (c *PostCache) ListBacklinks(slug string) ([]BlogPost, error) {
+	posts, _, err := c.ensureLoaded()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	links := c.links
+	c.mu.RUnlock()
+	from := make(map[string]bool)
+	for _, l := range links {
+		if l.To == slug {
+			from[l.From] = true
+		}
+	}
+	var backlinks []BlogPost
+	for _, p := range posts {
+		if from[p.Slug] {
+			backlinks = append(backlinks, p)
+		}
+	}
+	return backlinks, nil
+}
+
+// GetSeries returns a series with its published posts in reading order
+// from the cache.
+func (c *PostCache) GetSeries(slug string) (Series, error) {
+	posts, _, err := c.ensureLoaded()
+	if err != nil {
+		return Series{}, err
+	}
+	c.mu.RLock()
+	all := c.series
+	c.mu.RUnlock()
+	for _, sr := range all {
+		if sr.Slug != slug {
+			continue
+		}
+		for _, p := range posts {
+			if p.SeriesSlug == slug {
+				sr.Posts = append(sr.Posts, p)
+			}
+		}
+		sortSeriesPosts(sr.Posts)
+		return sr, nil
+	}
+	return Series{}, ErrNotFound
+}
+
+// GetAuthor returns an author by slug from the cache.
+func (c *PostCache) GetAuthor(slug string) (Author, error) {
+	if _, _, err := c.ensureLoaded(); err != nil {
+		return Author{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, au := range c.authors {
+		if au.Slug == slug {
+			return au, nil
+		}
+	}
+	return Author{}, ErrNotFound
+}
+
+// ListPostsByAuthor returns the published posts by the author slug, newest
+// first.
+func (c *PostCache) ListPostsByAuthor(slug string) ([]BlogPost, error) {
+	posts, _, err := c.ensureLoaded()
+	if err != nil {
+		return nil, err
+	}
+	var filtered []BlogPost
+	for _, p := range posts {
+		if p.AuthorSlug == slug {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
 func normalizeTag(t string) string {
 	return strings.ToLower(strings.TrimSpace(t))
 }
@@ -0,0 +1,141 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CalendarMonth is a month of posts laid out as a grid for the admin
+// content calendar.
+type CalendarMonth struct {
+	Month string          // "2006-01"
+	Title string          // e.g. "May 2024"
+	Prev  string          // previous month, "2006-01"
+	Next  string          // next month, "2006-01"
+	Weeks [][]CalendarDay // Monday-first weeks covering the month
+}
+
+// CalendarDay is one cell of the calendar grid.
+type CalendarDay struct {
+	Date    string // "2006-01-02"
+	Day     int
+	InMonth bool // false for the days of the neighboring months that fill the first and last week
+	Today   bool
+	Posts   []BlogPost // published posts and drafts dated that day
+}
+
+// buildCalendar returns the calendar grid for the month containing month
+// with the posts postsBetween returns for the days in the grid, published
+// or not. today marks the current day.
+func buildCalendar(month, today time.Time, postsBetween func(from, to string) ([]BlogPost, error)) (CalendarMonth, error) {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// Back up to the Monday on or before the first of the month.
+	start := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+	next := first.AddDate(0, 1, 0)
+	end := next.AddDate(0, 0, (7-(int(next.Weekday())+6)%7)%7)
+
+	posts, err := postsBetween(start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return CalendarMonth{}, err
+	}
+	byDate := make(map[string][]BlogPost)
+	for _, p := range posts {
+		byDate[p.Date] = append(byDate[p.Date], p)
+	}
+
+	cal := CalendarMonth{
+		Month: first.Format("2006-01"),
+		Title: first.Format("January 2006"),
+		Prev:  first.AddDate(0, -1, 0).Format("2006-01"),
+		Next:  next.Format("2006-01"),
+	}
+	todayStr := today.Format("2006-01-02")
+	var week []CalendarDay
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		week = append(week, CalendarDay{
+			Date:    date,
+			Day:     d.Day(),
+			InMonth: d.Month() == first.Month(),
+			Today:   date == todayStr,
+			Posts:   byDate[date],
+		})
+		if len(week) == 7 {
+			cal.Weeks = append(cal.Weeks, week)
+			week = nil
+		}
+	}
+	return cal, nil
+}
+
+func (a *App) handleCalendar(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	month := time.Now()
+	if m := c.QueryParam("month"); m != "" {
+		t, err := time.Parse("2006-01", m)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Invalid month. Use YYYY-MM.")
+		}
+		month = t
+	}
+	return a.renderCalendar(c, month)
+}
+
+// handleCalendarMove sets the date of a post dropped on another day and
+// shows the month it was moved to. The date is the one the post shows and
+// is sorted by; moving an unscheduled draft doesn't publish it. A
+// scheduled draft is rescheduled to the same time on the new day, which
+// can't be in the past.
+func (a *App) handleCalendarMove(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	date, err := time.Parse("2006-01-02", c.FormValue("date"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD.")
+	}
+	slug := c.FormValue("slug")
+	post, err := a.Content.GetPostAny(slug)
+	if err == sql.ErrNoRows {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	if at := post.PublishTime(); !at.IsZero() {
+		at = at.In(time.Local)
+		at = time.Date(date.Year(), date.Month(), date.Day(), at.Hour(), at.Minute(), at.Second(), 0, time.Local)
+		if at.Before(time.Now()) {
+			return c.String(http.StatusBadRequest, "A scheduled post can't be moved into the past.")
+		}
+		err = a.schedulePost(slug, at)
+	} else {
+		err = a.setPostDate(slug, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	a.Cache.Invalidate()
+	if post, err := a.Content.GetPostAny(slug); err == nil && post.Published {
+		a.purge(post)
+	}
+	return a.renderCalendar(c, date)
+}
+
+func (a *App) renderCalendar(c echo.Context, month time.Time) error {
+	if a.Views.AdminCalendar == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	cal, err := buildCalendar(month, time.Now(), a.postsBetween)
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminCalendar(cal, CsrfToken(c)))
+}
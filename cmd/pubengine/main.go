@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 // version is set at build time via ldflags.
@@ -16,12 +19,20 @@ func main() {
 
 	switch os.Args[1] {
 	case "new":
-		if len(os.Args) < 3 {
-			fmt.Fprintln(os.Stderr, "Usage: pubengine new <project-name>")
+		name, opts, err := parseNewArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Usage: pubengine new <project-name> [--deploy docker|fly|render|systemd] [--css tailwind|vanilla|none] [--with|--without features] [--seed] [--i18n] [--offline [--vendor]] [--mount]")
+			os.Exit(1)
+		}
+		if err := runNew(name, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		if err := runNew(os.Args[2]); err != nil {
+	case "theme":
+		if err := runTheme(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Usage: pubengine theme export [-o file] [--name name] [dir] | pubengine theme apply <archive> [dir]")
 			os.Exit(1)
 		}
 	case "version":
@@ -43,10 +54,77 @@ Usage:
 
 Commands:
   new <name>    Create a new pubengine project
+                --deploy docker|fly|render|systemd  also generate deployment config
+                --css tailwind|vanilla|none         CSS setup (default tailwind)
+                --seed                              add example posts and an image
+                --i18n                              translated views with an example German locale
+                --offline [--vendor]                pin dependencies, resolve from the module cache only
+                --mount                             main.go mounts pubengine into its own Echo app
+                --with, --without analytics,comments,google,newsletter,search,snippets
+                                                    enable or leave out optional features
+                                                    (comments, newsletter and search are off by default)
+  theme export [dir]
+                Package views, styles and assets into a theme archive
+                -o file                             output archive (default <name>-theme.tar.gz)
+                --name name                         theme name (default the directory name)
+  theme apply <archive> [dir]
+                Apply a theme archive, backing up replaced files
   version       Print the pubengine version
   help          Show this help message
 
 Examples:
   pubengine new myblog
-  pubengine new github.com/user/myblog`)
+  pubengine new github.com/user/myblog
+  pubengine new myblog --deploy docker
+  pubengine new myblog --css vanilla
+  pubengine new myblog --seed
+  pubengine new myblog --without analytics,google
+  pubengine new myblog --with comments,newsletter,search
+  pubengine new myblog --i18n
+  pubengine theme export -o minimal.tar.gz
+  pubengine theme apply minimal.tar.gz ../otherblog`)
+}
+
+// parseNewArgs parses "new" arguments. Flags may come before or after the
+// project name.
+func parseNewArgs(args []string) (name string, opts newOptions, err error) {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.Deploy, "deploy", "", "deployment config to generate (docker, fly, render or systemd)")
+	fs.StringVar(&opts.CSS, "css", "tailwind", "CSS setup (tailwind, vanilla or none)")
+	fs.BoolVar(&opts.Seed, "seed", false, "insert example posts and an image into the database")
+	fs.BoolVar(&opts.I18n, "i18n", false, "generate translated views with an example second locale")
+	fs.BoolVar(&opts.Offline, "offline", false, "pin dependency versions and skip network access")
+	fs.BoolVar(&opts.Vendor, "vendor", false, "with --offline, also run go mod vendor")
+	fs.BoolVar(&opts.Mount, "mount", false, "mount pubengine into an existing Echo app in main.go")
+	fs.Func("with", "comma-separated optional features to enable", func(v string) error {
+		opts.With = append(opts.With, splitList(v)...)
+		return nil
+	})
+	fs.Func("without", "comma-separated optional features to leave out", func(v string) error {
+		opts.Without = append(opts.Without, splitList(v)...)
+		return nil
+	})
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return "", opts, err
+	}
+	if len(positional) == 0 {
+		return "", opts, fmt.Errorf("project name is required")
+	}
+	if len(positional) > 1 {
+		return "", opts, fmt.Errorf("unexpected argument %q", positional[1])
+	}
+	return positional[0], opts, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
 }
@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -17,9 +20,161 @@ type scaffoldData struct {
 	ProjectName string
 	ModuleName  string
 	SiteName    string
+	CSS         string          // "tailwind", "vanilla" or "none"
+	With        map[string]bool // enabled optional features, see features
+	I18n        bool            // views look strings up in views/i18n.go
+	Mount       bool            // main.go mounts pubengine into its own Echo server
 }
 
-func runNew(name string) error {
+// newOptions holds the "pubengine new" flags.
+type newOptions struct {
+	Deploy  string   // deploy target, empty for none
+	CSS     string   // CSS setup, see cssFiles
+	Seed    bool     // insert example posts and an image into the database
+	I18n    bool     // generate translated views with an example second locale
+	Offline bool     // pin module versions and resolve from the module cache only
+	Vendor  bool     // run go mod vendor, requires Offline
+	Mount   bool     // generate main.go that mounts pubengine into an Echo app
+	With    []string // optional features to enable
+	Without []string // optional features to leave out
+}
+
+// features lists the optional subsystems "pubengine new" can toggle with
+// --with and --without, and whether each is enabled by default. Disabled
+// features leave out their views, config and route wiring.
+var features = map[string]bool{
+	"analytics":  true,  // privacy-first analytics: tracking script, admin link, AnalyticsEnabled
+	"comments":   false, // reader comments: post section, moderation panel, Comments and AdminModeration
+	"google":     true,  // Google OAuth admin login: login button, config and .env entries
+	"newsletter": false, // newsletter signup: page, nav link, subscriber panel, Newsletter and AdminSubscribers
+	"search":     false, // post search: page, nav link, Search
+	"snippets":   true,  // admin snippets and post templates: view, button, AdminSnippets
+}
+
+// resolveFeatures applies --with and --without to the feature defaults.
+func resolveFeatures(with, without []string) (map[string]bool, error) {
+	enabled := maps.Clone(features)
+	for _, list := range []struct {
+		names []string
+		on    bool
+	}{{with, true}, {without, false}} {
+		for _, name := range list.names {
+			if _, ok := features[name]; !ok {
+				choices := slices.Sorted(maps.Keys(features))
+				return nil, fmt.Errorf("unknown feature %q (available: %s)", name, strings.Join(choices, ", "))
+			}
+			if !list.on && slices.Contains(with, name) {
+				return nil, fmt.Errorf("feature %q is both in --with and --without", name)
+			}
+			enabled[name] = list.on
+		}
+	}
+	return enabled, nil
+}
+
+// cssFiles lists, for each --css choice, the scaffold files only that choice
+// generates. Files listed under any choice are skipped for the others.
+var cssFiles = map[string][]string{
+	"tailwind": {"package.json.tmpl", "tailwind.config.js.tmpl", "assets/tailwind.css.tmpl", "src/app.js.tmpl"},
+	"vanilla":  {"public/style.css.tmpl", "public/app.js.tmpl"},
+	"none":     {"public/app.js.tmpl"},
+}
+
+// skipCSSFile reports whether the template at relPath belongs to a CSS
+// choice other than css.
+func skipCSSFile(relPath, css string) bool {
+	relPath = filepath.ToSlash(relPath)
+	optional := false
+	for _, files := range cssFiles {
+		if slices.Contains(files, relPath) {
+			optional = true
+			break
+		}
+	}
+	return optional && !slices.Contains(cssFiles[css], relPath)
+}
+
+// i18nFiles are the scaffold files generated only with --i18n.
+var i18nFiles = []string{"views/i18n.go.tmpl"}
+
+// featureFiles lists the scaffold files generated only with a feature
+// enabled. Features not listed live in shared files behind .With checks.
+var featureFiles = map[string][]string{
+	"comments":   {"views/comments.templ.tmpl"},
+	"newsletter": {"views/newsletter.templ.tmpl"},
+	"search":     {"views/search.templ.tmpl"},
+}
+
+// skipFeatureFile reports whether the template at relPath belongs to a
+// feature that is not enabled.
+func skipFeatureFile(relPath string, with map[string]bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	for name, files := range featureFiles {
+		if !with[name] && slices.Contains(files, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// deployTargets maps each "pubengine new --deploy" value to the scaffold/deploy
+// directories it renders. The image directory holds the Dockerfile shared by
+// the container based targets.
+var deployTargets = map[string][]string{
+	"docker":  {"image", "docker"},
+	"fly":     {"image", "fly"},
+	"render":  {"image", "render"},
+	"systemd": {"systemd"},
+}
+
+// deployNextSteps are printed after scaffolding with a deploy target.
+var deployNextSteps = map[string][]string{
+	"docker": {
+		"To deploy with Docker and Caddy, set SITE_DOMAIN and SITE_URL in .env, then run:",
+		"  docker compose up -d --build",
+	},
+	"fly": {
+		"To deploy to Fly.io (see the comments in fly.toml):",
+		"  fly launch --no-deploy && fly volumes create data --size 1",
+		"  fly secrets set ADMIN_PASSWORD=... ADMIN_SESSION_SECRET=... SITE_URL=https://...",
+		"  fly deploy",
+	},
+	"render": {
+		"To deploy to Render, push the project to a Git repository and create a",
+		"Blueprint from it; render.yaml defines the service and its disk.",
+	},
+	"systemd": {
+		"To run under systemd, follow the install steps at the top of %s.service.",
+	},
+}
+
+func runNew(name string, opts newOptions) error {
+	deploy := opts.Deploy
+	if _, ok := deployTargets[deploy]; deploy != "" && !ok {
+		targets := slices.Sorted(maps.Keys(deployTargets))
+		return fmt.Errorf("unknown deploy target %q (available: %s)", deploy, strings.Join(targets, ", "))
+	}
+	if opts.CSS == "" {
+		opts.CSS = "tailwind"
+	}
+	if _, ok := cssFiles[opts.CSS]; !ok {
+		choices := slices.Sorted(maps.Keys(cssFiles))
+		return fmt.Errorf("unknown CSS option %q (available: %s)", opts.CSS, strings.Join(choices, ", "))
+	}
+	with, err := resolveFeatures(opts.With, opts.Without)
+	if err != nil {
+		return err
+	}
+	if opts.Vendor && !opts.Offline {
+		return fmt.Errorf("--vendor requires --offline")
+	}
+	var buildInfo *debug.BuildInfo
+	if opts.Offline {
+		if buildInfo, err = offlineBuildInfo(); err != nil {
+			return err
+		}
+	}
+
 	// Derive project directory name from the last path segment.
 	dirName := name
 	if idx := strings.LastIndex(name, "/"); idx >= 0 {
@@ -36,13 +191,80 @@ func runNew(name string) error {
 		ProjectName: dirName,
 		ModuleName:  name,
 		SiteName:    toTitle(dirName),
+		CSS:         opts.CSS,
+		With:        with,
+		I18n:        opts.I18n,
+		Mount:       opts.Mount,
 	}
 
 	fmt.Printf("Creating new pubengine project: %s\n\n", dirName)
 
-	root := "templates"
+	if err := renderTemplates(scaffold.Templates, "templates", dirName, data); err != nil {
+		return err
+	}
+	for _, dir := range deployTargets[deploy] {
+		if err := renderTemplates(scaffold.Deploy, "deploy/"+dir, dirName, data); err != nil {
+			return err
+		}
+	}
+
+	if opts.Seed {
+		fmt.Println("\nSeeding example content...")
+		if err := seedProject(dirName); err != nil {
+			return fmt.Errorf("seed: %w", err)
+		}
+	}
+
+	if opts.Offline {
+		// Pin versions and resolve from the module cache, never the network.
+		if err := pinModules(dirName, buildInfo); err != nil {
+			return err
+		}
+		resolveOffline(dirName, opts.Vendor)
+	} else {
+		// Resolve dependencies and generate go.sum.
+		fmt.Println("\nResolving Go dependencies...")
+		tidy := exec.Command("go", "mod", "tidy")
+		tidy.Dir = dirName
+		tidy.Stdout = os.Stdout
+		tidy.Stderr = os.Stderr
+		if err := tidy.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "\nWarning: go mod tidy failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Run 'cd %s && go mod tidy' manually after fixing.\n", dirName)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Done! Next steps:")
+	fmt.Println()
+	fmt.Printf("  cd %s\n", dirName)
+	fmt.Println("  cp .env.example .env")
+	if opts.CSS == "tailwind" {
+		fmt.Println("  npm install")
+	}
+	fmt.Println("  make run")
+	fmt.Println()
+	fmt.Printf("Edit views/*.templ to customize your templates, then run 'make templ'.\n")
+	if opts.CSS == "vanilla" {
+		fmt.Printf("Styles live in public/style.css and need no build step.\n")
+	}
+	fmt.Printf("Update ADMIN_PASSWORD and ADMIN_SESSION_SECRET in .env before deploying.\n")
+	if steps := deployNextSteps[deploy]; len(steps) > 0 {
+		fmt.Println()
+		for _, line := range steps {
+			if strings.Contains(line, "%s") {
+				line = fmt.Sprintf(line, dirName)
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
 
-	err := fs.WalkDir(scaffold.Templates, root, func(path string, d fs.DirEntry, err error) error {
+// renderTemplates executes every .tmpl file under root in fsys into dirName,
+// keeping the directory layout and stripping the .tmpl suffix.
+func renderTemplates(fsys fs.ReadFileFS, root, dirName string, data scaffoldData) error {
+	return fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -63,14 +285,27 @@ func runNew(name string) error {
 			outPath = filepath.Join(filepath.Dir(outPath), ".env.example")
 		case "dotgitignore":
 			outPath = filepath.Join(filepath.Dir(outPath), ".gitignore")
+		case "dotdockerignore":
+			outPath = filepath.Join(filepath.Dir(outPath), ".dockerignore")
+		case "project.service":
+			outPath = filepath.Join(filepath.Dir(outPath), data.ProjectName+".service")
 		}
 
 		if d.IsDir() {
 			return os.MkdirAll(outPath, 0o755)
 		}
+		if skipCSSFile(relPath, data.CSS) {
+			return nil
+		}
+		if !data.I18n && slices.Contains(i18nFiles, filepath.ToSlash(relPath)) {
+			return nil
+		}
+		if skipFeatureFile(relPath, data.With) {
+			return nil
+		}
 
 		// Read the template file.
-		content, err := scaffold.Templates.ReadFile(path)
+		content, err := fsys.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("read %s: %w", path, err)
 		}
@@ -104,32 +339,6 @@ func runNew(name string) error {
 		fmt.Printf("  created %s\n", outPath)
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-
-	// Resolve dependencies and generate go.sum.
-	fmt.Println("\nResolving Go dependencies...")
-	tidy := exec.Command("go", "mod", "tidy")
-	tidy.Dir = dirName
-	tidy.Stdout = os.Stdout
-	tidy.Stderr = os.Stderr
-	if err := tidy.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "\nWarning: go mod tidy failed: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Run 'cd %s && go mod tidy' manually after fixing.\n", dirName)
-	}
-
-	fmt.Println()
-	fmt.Println("Done! Next steps:")
-	fmt.Println()
-	fmt.Printf("  cd %s\n", dirName)
-	fmt.Println("  cp .env.example .env")
-	fmt.Println("  npm install")
-	fmt.Println("  make run")
-	fmt.Println()
-	fmt.Printf("Edit views/*.templ to customize your templates, then run 'make templ'.\n")
-	fmt.Printf("Update ADMIN_PASSWORD and ADMIN_SESSION_SECRET in .env before deploying.\n")
-	return nil
 }
 
 // toTitle converts a hyphenated or lowercase name to a title-case string.
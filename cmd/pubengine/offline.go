@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// directModules are the modules generated projects import themselves; every
+// other pinned module is marked indirect.
+var directModules = []string{"github.com/eringen/pubengine", "github.com/a-h/templ"}
+
+// offlineBuildInfo returns the build info whose module versions --offline
+// pins. Development builds have no pinnable pubengine version.
+func offlineBuildInfo() (*debug.BuildInfo, error) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil, fmt.Errorf("--offline needs module build info, which this binary lacks")
+	}
+	if v := info.Main.Version; v == "" || v == "(devel)" || strings.Contains(v, "+") {
+		return nil, fmt.Errorf("--offline needs a released pubengine CLI to pin versions, not a development build (%s); install one with 'go install github.com/eringen/pubengine/cmd/pubengine@<version>'", v)
+	}
+	return info, nil
+}
+
+// pinModules appends require directives to the project's go.mod pinning
+// pubengine and its dependencies to the exact versions in info, and seeds
+// go.sum with the module hashes recorded in the binary.
+func pinModules(dirName string, info *debug.BuildInfo) error {
+	self := info.Main
+	mods := append([]*debug.Module{&self}, info.Deps...)
+	var direct, indirect, sums strings.Builder
+	for _, m := range mods {
+		if slices.Contains(directModules, m.Path) {
+			fmt.Fprintf(&direct, "\t%s %s\n", m.Path, m.Version)
+		} else {
+			fmt.Fprintf(&indirect, "\t%s %s // indirect\n", m.Path, m.Version)
+		}
+		if m.Sum != "" && m.Replace == nil {
+			fmt.Fprintf(&sums, "%s %s %s\n", m.Path, m.Version, m.Sum)
+		}
+	}
+
+	modPath := filepath.Join(dirName, "go.mod")
+	f, err := os.OpenFile(modPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "\nrequire (\n%s)\n\nrequire (\n%s)\n", direct.String(), indirect.String())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", modPath, err)
+	}
+	fmt.Printf("  pinned %d modules in %s\n", len(mods), modPath)
+
+	sumPath := filepath.Join(dirName, "go.sum")
+	if err := os.WriteFile(sumPath, []byte(sums.String()), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", sumPath, err)
+	}
+	return nil
+}
+
+// offlineGo runs a go command in the project with downloads disabled, so it
+// resolves everything from the local module cache.
+func offlineGo(dirName string, args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dirName
+	cmd.Env = append(os.Environ(), "GOPROXY=off")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// resolveOffline completes go.sum from the module cache and, with vendor,
+// copies the dependencies into vendor/ so the project builds without it.
+// Failures are reported as warnings, like the online go mod tidy.
+func resolveOffline(dirName string, vendor bool) {
+	fmt.Println("\nResolving Go dependencies from the module cache...")
+	if err := offlineGo(dirName, "mod", "tidy"); err != nil {
+		fmt.Fprintf(os.Stderr, "\nWarning: offline go mod tidy failed: %v\n", err)
+		fmt.Fprintln(os.Stderr, "The module cache is missing some pinned modules. Populate it with 'go mod download'")
+		fmt.Fprintf(os.Stderr, "on a connected machine or point GOPROXY at a mirror, then run 'cd %s && go mod tidy'.\n", dirName)
+		return
+	}
+	if vendor {
+		fmt.Println("\nVendoring dependencies...")
+		if err := offlineGo(dirName, "mod", "vendor"); err != nil {
+			fmt.Fprintf(os.Stderr, "\nWarning: go mod vendor failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Run 'cd %s && go mod vendor' manually after fixing.\n", dirName)
+		}
+	}
+}
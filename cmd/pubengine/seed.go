@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eringen/pubengine"
+)
+
+// Seed image dimensions, matching the 800px width uploads are resized to.
+const (
+	seedImageName   = "example-cover.jpg"
+	seedImageWidth  = 800
+	seedImageHeight = 420
+)
+
+// seedPosts are the example posts written by "pubengine new --seed". Dates
+// are days before today so the newest post is listed first.
+var seedPosts = []struct {
+	daysAgo int
+	post    pubengine.BlogPost
+}{
+	{0, pubengine.BlogPost{
+		Slug:    "welcome",
+		Title:   "Welcome to your new blog",
+		Tags:    []string{"meta"},
+		Summary: "A quick tour of where things live and how to publish.",
+		Content: `This post was added by ` + "`pubengine new --seed`" + `. Edit or delete it from the [admin dashboard](/admin/).
+
+![Example cover](/public/uploads/` + seedImageName + `){max-width:100%|800|420}
+
+## Where things live
+
+- **views/** holds your templ templates
+- **public/** holds static assets, with uploads in public/uploads
+- **data/** holds the SQLite databases
+
+## Next steps
+
+1. Change ` + "`ADMIN_PASSWORD`" + ` in .env
+2. Restyle the views
+3. Write your first real post
+`,
+	}},
+	{3, pubengine.BlogPost{
+		Slug:    "markdown-tour",
+		Title:   "A tour of the Markdown syntax",
+		Tags:    []string{"meta", "markdown"},
+		Summary: "Everything the renderer supports, in one post for theming.",
+		Content: `Text can be **bold**, *italic* or ` + "`inline code`" + `. Bare links like https://github.com/eringen/pubengine work too.
+
+> Blockquotes can span
+> several lines.
+>
+> And several paragraphs.
+
+### Code
+
+` + "```go title=main.go {3}" + `
+package main
+
+func main() {
+	println("hello")
+}
+` + "```" + `
+
+### Tables
+
+| Feature | Supported |
+|---|---|
+| Tables | yes |
+| Footnotes | no |
+
+### Definition lists
+
+Slug
+: The URL segment of a post.
+
+Draft
+: A post that is saved but not published.
+
+---
+
+That's the lot.
+`,
+	}},
+	{7, pubengine.BlogPost{
+		Slug:    "notes-on-go",
+		Title:   "Notes on Go",
+		Tags:    []string{"go"},
+		Summary: "A short example post with a tag of its own.",
+		Content: `Posts can share tags; the home page filters by them.
+
+` + "```go" + `
+for i := range 3 {
+	fmt.Println(i)
+}
+` + "```" + `
+`,
+	}},
+	{14, pubengine.BlogPost{
+		Slug:    "draft-ideas",
+		Title:   "Draft: ideas for next posts",
+		Tags:    []string{"meta"},
+		Summary: "An unpublished draft, only visible in the admin.",
+		Content: "- Write about deployment\n- Write about themes\n",
+	}},
+}
+
+// seedProject writes the example posts and cover image into a freshly
+// scaffolded project's database and uploads directory.
+func seedProject(dirName string) error {
+	store, err := pubengine.NewStore(filepath.Join(dirName, "data", "blog.db"))
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer store.Close()
+
+	img, data, err := seedImage()
+	if err != nil {
+		return err
+	}
+	uploads := filepath.Join(dirName, "public", "uploads")
+	if err := os.MkdirAll(uploads, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(uploads, img.Filename), data, 0o644); err != nil {
+		return err
+	}
+	if err := store.SaveImage(img); err != nil {
+		return fmt.Errorf("save image: %w", err)
+	}
+
+	today := time.Now()
+	for _, sp := range seedPosts {
+		p := sp.post
+		p.Date = today.AddDate(0, 0, -sp.daysAgo).Format("2006-01-02")
+		p.Published = p.Slug != "draft-ideas"
+		if err := store.SavePost(p); err != nil {
+			return fmt.Errorf("save post %s: %w", p.Slug, err)
+		}
+	}
+
+	fmt.Printf("  seeded %d posts and 1 image into %s\n", len(seedPosts), filepath.Join(dirName, "data", "blog.db"))
+	return nil
+}
+
+// seedImage draws a simple diagonal gradient cover image as JPEG.
+func seedImage() (pubengine.Image, []byte, error) {
+	m := image.NewRGBA(image.Rect(0, 0, seedImageWidth, seedImageHeight))
+	for y := 0; y < seedImageHeight; y++ {
+		for x := 0; x < seedImageWidth; x++ {
+			t := float64(x+y) / float64(seedImageWidth+seedImageHeight)
+			m.Set(x, y, color.RGBA{
+				R: uint8(30 + 60*t),
+				G: uint8(64 + 100*t),
+				B: uint8(175 + 60*t),
+				A: 255,
+			})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 80}); err != nil {
+		return pubengine.Image{}, nil, fmt.Errorf("encode seed image: %w", err)
+	}
+	return pubengine.Image{
+		Filename:     seedImageName,
+		OriginalName: seedImageName,
+		Width:        seedImageWidth,
+		Height:       seedImageHeight,
+		Size:         buf.Len(),
+		UploadedAt:   time.Now().UTC().Format(time.RFC3339),
+	}, buf.Bytes(), nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// themeManifestName is the manifest stored at the root of a theme archive.
+const themeManifestName = "theme.json"
+
+// maxThemeSize caps the unpacked size of a theme archive.
+const maxThemeSize = 64 << 20
+
+// themeManifest describes a theme archive.
+type themeManifest struct {
+	Name     string   `json:"name"`
+	Module   string   `json:"module"` // module path of the exporting project, rewritten on apply
+	CSS      string   `json:"css"`    // "tailwind", "vanilla" or "none", see detectCSS
+	Exported string   `json:"exported"`
+	Files    []string `json:"files"`
+}
+
+// themeDirs and themeRootFiles are the parts of a project a theme is made
+// of: templates, styles, scripts and static assets.
+var (
+	themeDirs      = []string{"views", "assets", "src", "public"}
+	themeRootFiles = []string{"tailwind.config.js"}
+)
+
+// themeExcludes are build output and site content left out of themes.
+var themeExcludes = []string{"public/uploads", "public/tailwind.css", "public/robots.txt"}
+
+// isThemeFile reports whether the slash separated project path rel belongs
+// in a theme.
+func isThemeFile(rel string) bool {
+	if strings.HasSuffix(rel, "_templ.go") {
+		return false
+	}
+	for _, ex := range themeExcludes {
+		if rel == ex || strings.HasPrefix(rel, ex+"/") {
+			return false
+		}
+	}
+	if slices.Contains(themeRootFiles, rel) {
+		return true
+	}
+	dir, _, ok := strings.Cut(rel, "/")
+	return ok && slices.Contains(themeDirs, dir)
+}
+
+func runTheme(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("theme subcommand is required (export or apply)")
+	}
+	switch args[0] {
+	case "export":
+		return runThemeExport(args[1:])
+	case "apply":
+		return runThemeApply(args[1:])
+	default:
+		return fmt.Errorf("unknown theme subcommand %q (available: export, apply)", args[0])
+	}
+}
+
+// parseArgs parses flags from args, allowing flags before and after the
+// positional arguments, and returns the positional arguments.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func runThemeExport(args []string) error {
+	flags := flag.NewFlagSet("theme export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	out := flags.String("o", "", "output archive (default <project>-theme.tar.gz)")
+	name := flags.String("name", "", "theme name (default the project directory name)")
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("unexpected argument %q", positional[1])
+	}
+	dir := "."
+	if len(positional) == 1 {
+		dir = positional[0]
+	}
+
+	module, err := readModulePath(dir)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	m := themeManifest{
+		Name:     *name,
+		Module:   module,
+		CSS:      detectCSS(dir),
+		Exported: time.Now().UTC().Format(time.RFC3339),
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(abs)
+	}
+	if *out == "" {
+		*out = m.Name + "-theme.tar.gz"
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			topLevel := rel != "." && !strings.Contains(rel, "/")
+			if topLevel && !slices.Contains(themeDirs, rel) || slices.Contains(themeExcludes, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && isThemeFile(rel) {
+			m.Files = append(m.Files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(m.Files) == 0 {
+		return fmt.Errorf("no theme files found in %s", dir)
+	}
+
+	if err := writeThemeArchive(*out, dir, m); err != nil {
+		os.Remove(*out)
+		return err
+	}
+	fmt.Printf("Exported theme %q (%d files, %s CSS) to %s\n", m.Name, len(m.Files), m.CSS, *out)
+	return nil
+}
+
+// writeThemeArchive writes the manifest and m.Files, read from dir, into a
+// gzipped tar archive at out.
+func writeThemeArchive(out, dir string, m themeManifest) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, themeManifestName, manifest); err != nil {
+		return err
+	}
+	for _, rel := range m.Files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, rel, data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func runThemeApply(args []string) error {
+	flags := flag.NewFlagSet("theme apply", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("theme archive is required")
+	}
+	if len(positional) > 2 {
+		return fmt.Errorf("unexpected argument %q", positional[2])
+	}
+	dir := "."
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+
+	module, err := readModulePath(dir)
+	if err != nil {
+		return err
+	}
+	m, files, err := readThemeArchive(positional[0])
+	if err != nil {
+		return err
+	}
+	if css := detectCSS(dir); css != m.CSS {
+		fmt.Fprintf(os.Stderr, "Warning: theme %q uses %s CSS but this project uses %s; the Makefile and package.json may need updating.\n", m.Name, m.CSS, css)
+	}
+	if _, ok := files["views/i18n.go"]; ok {
+		if main, err := os.ReadFile(filepath.Join(dir, "main.go")); err == nil && !bytes.Contains(main, []byte("WithTranslations")) {
+			fmt.Fprintf(os.Stderr, "Warning: theme %q is translated; add pubengine.WithTranslations(\"en\", views.Locales) to the options in main.go.\n", m.Name)
+		}
+	}
+
+	backup := filepath.Join(dir, ".theme-backup", time.Now().Format("20060102-150405"))
+	backedUp := 0
+	for _, rel := range m.Files {
+		data := files[rel]
+		if m.Module != "" && m.Module != module && (strings.HasSuffix(rel, ".go") || strings.HasSuffix(rel, ".templ")) {
+			data = bytes.ReplaceAll(data, []byte(`"`+m.Module+`/`), []byte(`"`+module+`/`))
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if old, err := os.ReadFile(dst); err == nil {
+			if bytes.Equal(old, data) {
+				continue
+			}
+			b := filepath.Join(backup, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(b), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(b, old, 0o644); err != nil {
+				return err
+			}
+			backedUp++
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("  wrote %s\n", dst)
+	}
+
+	fmt.Println()
+	fmt.Printf("Applied theme %q.\n", m.Name)
+	if backedUp > 0 {
+		fmt.Printf("Replaced files were backed up to %s.\n", backup)
+	}
+	fmt.Println("Run 'make templ' to regenerate the views, then rebuild.")
+	return nil
+}
+
+// readThemeArchive reads a theme archive, checking that every file is a
+// theme file listed in its manifest.
+func readThemeArchive(name string) (themeManifest, map[string][]byte, error) {
+	var m themeManifest
+	f, err := os.Open(name)
+	if err != nil {
+		return m, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return m, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return m, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		clean := path.Clean(hdr.Name)
+		if clean != hdr.Name || path.IsAbs(clean) || strings.HasPrefix(clean, "../") {
+			return m, nil, fmt.Errorf("%s: unsafe path %q", name, hdr.Name)
+		}
+		if clean != themeManifestName && !isThemeFile(clean) {
+			return m, nil, fmt.Errorf("%s: %q is not a theme file", name, hdr.Name)
+		}
+		total += hdr.Size
+		if total > maxThemeSize {
+			return m, nil, fmt.Errorf("%s: theme is larger than %d MB", name, maxThemeSize>>20)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return m, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[clean] = data
+	}
+
+	manifest, ok := files[themeManifestName]
+	if !ok {
+		return m, nil, fmt.Errorf("%s: no %s, not a pubengine theme", name, themeManifestName)
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return m, nil, fmt.Errorf("%s: %s: %w", name, themeManifestName, err)
+	}
+	for _, rel := range m.Files {
+		if _, ok := files[rel]; !ok {
+			return m, nil, fmt.Errorf("%s: %s is listed in %s but missing", name, rel, themeManifestName)
+		}
+	}
+	return m, files, nil
+}
+
+// readModulePath returns the module path declared in dir/go.mod.
+func readModulePath(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("%s is not a project directory: %w", dir, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("%s/go.mod has no module directive", dir)
+}
+
+// detectCSS guesses a project's --css choice from the files it has.
+func detectCSS(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "tailwind.config.js")); err == nil {
+		return "tailwind"
+	}
+	if _, err := os.Stat(filepath.Join(dir, "public", "style.css")); err == nil {
+		return "vanilla"
+	}
+	return "none"
+}
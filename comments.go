@@ -0,0 +1,117 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Limits for draft comments. Reader comments share maxCommentLength.
+const (
+	maxCommentLength = 2000
+	maxQuoteLength   = 1000
+)
+
+func (a *App) handleCommentList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderComments(c, c.Param("slug"))
+}
+
+func (a *App) handleCommentAdd(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	slug := c.Param("slug")
+	if _, err := a.Content.GetPostAny(slug); err != nil {
+		if err == sql.ErrNoRows {
+			return c.String(http.StatusBadRequest, "Save the post before commenting on it")
+		}
+		return err
+	}
+	body := strings.TrimSpace(c.FormValue("body"))
+	if body == "" {
+		return c.String(http.StatusBadRequest, "Comment is required")
+	}
+	if len(body) > maxCommentLength {
+		return c.String(http.StatusBadRequest, "Comment is too long (max 2000 characters)")
+	}
+	// Long selections keep their start; the quote only helps find them again.
+	quote := c.FormValue("quote")
+	if r := []rune(quote); len(r) > maxQuoteLength {
+		quote = string(r[:maxQuoteLength])
+	}
+	start, err1 := strconv.Atoi(c.FormValue("start"))
+	end, err2 := strconv.Atoi(c.FormValue("end"))
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return c.String(http.StatusBadRequest, "Invalid selection")
+	}
+	if _, err := a.Store.AddDraftComment(DraftComment{
+		Slug:      slug,
+		Start:     start,
+		End:       end,
+		Quote:     quote,
+		Body:      body,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		return err
+	}
+	return a.renderComments(c, slug)
+}
+
+func (a *App) handleCommentResolve(c echo.Context) error {
+	return a.setCommentResolved(c, true)
+}
+
+func (a *App) handleCommentReopen(c echo.Context) error {
+	return a.setCommentResolved(c, false)
+}
+
+func (a *App) setCommentResolved(c echo.Context, resolved bool) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	slug := c.Param("slug")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.SetDraftCommentResolved(slug, id, resolved); err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	return a.renderComments(c, slug)
+}
+
+func (a *App) handleCommentDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	slug := c.Param("slug")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.DeleteDraftComment(slug, id); err != nil {
+		return err
+	}
+	return a.renderComments(c, slug)
+}
+
+func (a *App) renderComments(c echo.Context, slug string) error {
+	if a.Views.AdminComments == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	comments, err := a.Store.ListDraftComments(slug)
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminComments(slug, comments, CsrfToken(c)))
+}
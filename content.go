@@ -0,0 +1,351 @@
+package pubengine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContentStore is where posts are kept. *Store keeps them in the blog
+// database; WithContentStore swaps in another backend, such as Markdown
+// files on disk or a headless CMS, while images, settings, series, authors,
+// redirects and jobs stay in the database. The PostCache and handlers read
+// and write posts only through it.
+//
+// Methods that look up one post return ErrNotFound when there is no post
+// with the slug. Tags are compared in lowercase.
+type ContentStore interface {
+	ListPosts(tag string) ([]BlogPost, error) // published posts, newest first, with the tag if not empty
+	ListTags() ([]string, error)              // tags of published posts, lowercase and sorted
+	GetPost(slug string) (BlogPost, error)    // a published post
+	GetPostAny(slug string) (BlogPost, error) // a post, published or a draft
+	ListAllPosts() ([]BlogPost, error)        // published posts and drafts, newest first
+	SavePost(p BlogPost) error                // creates the post or replaces the one with p.Slug
+	DeletePost(slug string) error
+}
+
+var _ ContentStore = (*Store)(nil)
+
+// ErrPostChanged is returned by Store.SavePostIfVersion when the post was
+// changed after it was read for editing.
+var ErrPostChanged = errors.New("post changed since it was read")
+
+// WithContentStore serves posts from cs instead of the blog database. The
+// trash and short links are features of the built-in Store, so deleting a
+// post from the admin deletes it for good. Links between posts are found
+// in post content unless cs implements ListPostLinks and ListBacklinks
+// like Store does.
+func WithContentStore(cs ContentStore) Option {
+	return func(a *App) {
+		a.Content = cs
+	}
+}
+
+// The optional methods of a ContentStore. Store implements them all; the
+// App falls back to the ContentStore methods for backends that don't.
+type (
+	versionedPostSaver interface {
+		SavePostIfVersion(p BlogPost, version int) error
+	}
+	postSummaryLister interface {
+		ListPostSummaries(tag string) ([]BlogPost, error)
+	}
+	postTrasher interface {
+		TrashPost(slug string) error
+	}
+	postRenamer interface {
+		RenamePost(oldSlug, newSlug string) error
+	}
+	postFeaturer interface {
+		SetPostFeatured(slug string, featured bool) error
+	}
+	postRescheduler interface {
+		SetPostDate(slug, date string) error
+		ListPostsBetween(from, to string) ([]BlogPost, error)
+	}
+	postLinkLister interface {
+		ListPostLinks() ([]PostLink, error)
+	}
+	backlinkLister interface {
+		ListBacklinks(slug string) ([]BlogPost, error)
+	}
+	seriesLister interface {
+		ListSeries() ([]Series, error)
+	}
+	authorLister interface {
+		ListAuthors() ([]Author, error)
+	}
+	bulkPublisher interface {
+		BulkUpdatePublished(slugs []string, published bool) (int, error)
+	}
+	bulkTrasher interface {
+		BulkTrash(slugs []string) (int, error)
+	}
+	tagCounter interface {
+		ListTagCounts() ([]TagCount, error)
+	}
+	tagMerger interface {
+		MergeTags(into string, from ...string) (int, error)
+	}
+	revisionKeeper interface {
+		ListRevisions(slug string) ([]PostRevision, error)
+	}
+	postScheduler interface {
+		SchedulePost(slug string, at time.Time) error
+		PublishDuePosts(now time.Time) ([]BlogPost, error)
+	}
+	postSearcher interface {
+		SearchPosts(query string) ([]BlogPost, error)
+	}
+)
+
+// contentWithMeta is a custom ContentStore with the series and authors
+// managed in the admin, which live in the blog database whatever the
+// ContentStore.
+type contentWithMeta struct {
+	ContentStore
+	meta *Store
+}
+
+func (c contentWithMeta) ListSeries() ([]Series, error)  { return c.meta.ListSeries() }
+func (c contentWithMeta) ListAuthors() ([]Author, error) { return c.meta.ListAuthors() }
+
+// linksBetween returns the links between posts found in their content, for
+// ContentStores that don't track links themselves.
+func linksBetween(posts []BlogPost) []PostLink {
+	var links []PostLink
+	for _, p := range posts {
+		for _, to := range ParsePostLinks(p.Content) {
+			if to != p.Slug {
+				links = append(links, PostLink{From: p.Slug, To: to})
+			}
+		}
+	}
+	return links
+}
+
+// duplicatePost copies a post into a new unfeatured, unscheduled draft
+// dated today under the first free slug of "<slug>-copy", "<slug>-copy-2"
+// and so on, and returns the copy.
+func duplicatePost(cs ContentStore, slug string) (BlogPost, error) {
+	post, err := cs.GetPostAny(slug)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	base := slug + "-copy"
+	post.Slug = base
+	for n := 2; ; n++ {
+		if _, err := cs.GetPostAny(post.Slug); err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			return BlogPost{}, err
+		}
+		post.Slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	post.Title += " (copy)"
+	post.Date = time.Now().Format("2006-01-02")
+	post.Published = false
+	post.Featured = false
+	post.PublishAt = ""
+	if err := cs.SavePost(post); err != nil {
+		return BlogPost{}, err
+	}
+	return cs.GetPostAny(post.Slug)
+}
+
+// removePost moves a post to the trash, or deletes it if the ContentStore
+// has no trash, reporting which. It returns ErrNotFound if there is no
+// post with the slug.
+func (a *App) removePost(slug string) (trashed bool, err error) {
+	if t, ok := a.Content.(postTrasher); ok {
+		return true, t.TrashPost(slug)
+	}
+	if _, err := a.Content.GetPostAny(slug); err != nil {
+		return false, err
+	}
+	return false, a.Content.DeletePost(slug)
+}
+
+// renamePost moves the post oldSlug to newSlug and redirects the old slug
+// to the new one.
+func (a *App) renamePost(oldSlug, newSlug string) error {
+	if r, ok := a.Content.(postRenamer); ok {
+		return r.RenamePost(oldSlug, newSlug)
+	}
+	post, err := a.Content.GetPostAny(oldSlug)
+	if err != nil {
+		return err
+	}
+	post.Slug = newSlug
+	if err := a.Content.SavePost(post); err != nil {
+		return err
+	}
+	if err := a.Content.DeletePost(oldSlug); err != nil {
+		return err
+	}
+	return a.Store.SaveRedirect(oldSlug, newSlug)
+}
+
+// savePostIfVersion saves post like SavePost if the saved post is still at
+// version, and returns ErrPostChanged if not. Posts of ContentStores
+// without versions are saved unchecked.
+func (a *App) savePostIfVersion(post BlogPost, version int) error {
+	if v, ok := a.Content.(versionedPostSaver); ok {
+		return v.SavePostIfVersion(post, version)
+	}
+	return a.Content.SavePost(post)
+}
+
+// setPostFeatured marks a post as featured, or not. It returns ErrNotFound
+// if there is no post with the slug.
+func (a *App) setPostFeatured(slug string, featured bool) error {
+	if f, ok := a.Content.(postFeaturer); ok {
+		return f.SetPostFeatured(slug, featured)
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return err
+	}
+	post.Featured = featured
+	return a.Content.SavePost(post)
+}
+
+// setPostDate changes the date of a post. It returns ErrNotFound if there
+// is no post with the slug.
+func (a *App) setPostDate(slug, date string) error {
+	if r, ok := a.Content.(postRescheduler); ok {
+		return r.SetPostDate(slug, date)
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return err
+	}
+	post.Date = date
+	return a.Content.SavePost(post)
+}
+
+// postsBetween returns every post dated from from up to but not including
+// to, both "2006-01-02", oldest first.
+func (a *App) postsBetween(from, to string) ([]BlogPost, error) {
+	if r, ok := a.Content.(postRescheduler); ok {
+		return r.ListPostsBetween(from, to)
+	}
+	all, err := a.Content.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	var posts []BlogPost
+	for _, p := range all {
+		if p.Date >= from && p.Date < to {
+			posts = append(posts, p)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].Date != posts[j].Date {
+			return posts[i].Date < posts[j].Date
+		}
+		return posts[i].Title < posts[j].Title
+	})
+	return posts, nil
+}
+
+// postBacklinks returns the posts, drafts included, that link to the post
+// slug, newest first.
+func (a *App) postBacklinks(slug string) ([]BlogPost, error) {
+	if b, ok := a.Content.(backlinkLister); ok {
+		return b.ListBacklinks(slug)
+	}
+	all, err := a.Content.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	from := make(map[string]bool)
+	for _, l := range linksBetween(all) {
+		if l.To == slug {
+			from[l.From] = true
+		}
+	}
+	var backlinks []BlogPost
+	for _, p := range all {
+		if from[p.Slug] {
+			backlinks = append(backlinks, p)
+		}
+	}
+	return backlinks, nil
+}
+
+// setPostsPublished publishes or unpublishes the posts with the given
+// slugs and returns how many changed.
+func (a *App) setPostsPublished(slugs []string, published bool) (int, error) {
+	if b, ok := a.Content.(bulkPublisher); ok {
+		return b.BulkUpdatePublished(slugs, published)
+	}
+	n := 0
+	for _, slug := range slugs {
+		post, err := a.Content.GetPostAny(slug)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return n, err
+		}
+		if post.Published == published {
+			continue
+		}
+		post.Published = published
+		post.PublishAt = ""
+		if err := a.Content.SavePost(post); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// removePosts moves the posts with the given slugs to the trash, or
+// deletes them if the ContentStore has no trash, and returns how many were
+// removed and whether they were trashed.
+func (a *App) removePosts(slugs []string) (n int, trashed bool, err error) {
+	if b, ok := a.Content.(bulkTrasher); ok {
+		n, err = b.BulkTrash(slugs)
+		return n, true, err
+	}
+	for _, slug := range slugs {
+		t, err := a.removePost(slug)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return n, t, err
+		}
+		trashed = t
+		n++
+	}
+	return n, trashed, nil
+}
+
+// tagPosts adds tag to the posts with the given slugs that don't have it
+// and returns how many changed.
+func (a *App) tagPosts(slugs []string, tag string) (int, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	n := 0
+	for _, slug := range slugs {
+		post, err := a.Content.GetPostAny(slug)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return n, err
+		}
+		if slices.Contains(post.Tags, tag) || post.TrashedAt != "" {
+			continue
+		}
+		post.Tags = append(post.Tags, tag)
+		if err := a.Content.SavePost(post); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,135 @@
+package pubengine
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memContent is a ContentStore without any of Store's optional methods.
+type memContent struct {
+	mu    sync.Mutex
+	posts map[string]BlogPost
+}
+
+func (m *memContent) ListAllPosts() ([]BlogPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var posts []BlogPost
+	for _, p := range m.posts {
+		posts = append(posts, p)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Date > posts[j].Date })
+	return posts, nil
+}
+
+func (m *memContent) ListPosts(tag string) ([]BlogPost, error) {
+	all, _ := m.ListAllPosts()
+	var posts []BlogPost
+	for _, p := range all {
+		if p.Published && (tag == "" || strings.Contains(","+strings.Join(p.Tags, ",")+",", ","+tag+",")) {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+func (m *memContent) ListTags() ([]string, error) {
+	posts, _ := m.ListPosts("")
+	var tags []string
+	for _, p := range posts {
+		tags = append(tags, p.Tags...)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (m *memContent) GetPost(slug string) (BlogPost, error) {
+	p, err := m.GetPostAny(slug)
+	if err == nil && !p.Published {
+		return BlogPost{}, ErrNotFound
+	}
+	return p, err
+}
+
+func (m *memContent) GetPostAny(slug string) (BlogPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.posts[slug]
+	if !ok {
+		return BlogPost{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *memContent) SavePost(p BlogPost) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.posts[p.Slug] = p
+	return nil
+}
+
+func (m *memContent) DeletePost(slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.posts, slug)
+	return nil
+}
+
+func TestContentStore(t *testing.T) {
+	mem := &memContent{posts: map[string]BlogPost{
+		"hello": {Slug: "hello", Title: "Hello", Date: "2024-01-01", Tags: []string{"go"}, Published: true, SeriesSlug: "intro"},
+		"later": {Slug: "later", Title: "Later", Date: "2024-02-01", Content: "See [hello](/blog/hello/).", Published: true},
+		"draft": {Slug: "draft", Title: "Draft", Date: "2024-03-01", Content: "Also [hello](/blog/hello/)."},
+	}}
+	app := newTestApp(t)
+	WithContentStore(mem)(app)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := app.Store.SaveSeries(Series{Slug: "intro", Title: "Intro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serveGet(app, "/blog/hello/")
+	if rec.Code != http.StatusOK || rec.Body.String() != "Hello" {
+		t.Errorf("GET /blog/hello/ = %d %q", rec.Code, rec.Body.String())
+	}
+	if posts, _ := app.Store.ListAllPosts(); len(posts) != 0 {
+		t.Errorf("the blog database has %d posts, want none", len(posts))
+	}
+	if got, _ := app.Cache.ListBacklinks("hello"); len(got) != 1 || got[0].Slug != "later" {
+		t.Errorf("cached backlinks = %v, want later", got)
+	}
+	if got, _ := app.postBacklinks("hello"); len(got) != 2 {
+		t.Errorf("admin backlinks = %v, want draft and later", got)
+	}
+	if sr, err := app.Cache.GetSeries("intro"); err != nil || len(sr.Posts) != 1 {
+		t.Errorf("GetSeries = %+v, %v", sr, err)
+	}
+
+	copied, err := duplicatePost(app.Content, "hello")
+	if err != nil || copied.Slug != "hello-copy" || copied.Published {
+		t.Errorf("duplicatePost = %+v, %v", copied, err)
+	}
+	if err := app.setPostFeatured("hello", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renamePost("hello", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := mem.GetPostAny("hi"); err != nil || !p.Featured {
+		t.Errorf("renamed post = %+v, %v", p, err)
+	}
+	if to, _ := app.Store.ResolveRedirect("hello"); to != "hi" {
+		t.Errorf("redirect from hello = %q, want hi", to)
+	}
+	if trashed, err := app.removePost("hi"); trashed || err != nil {
+		t.Errorf("removePost = %v, %v, want deleted", trashed, err)
+	}
+	if _, err := app.removePost("hi"); err != ErrNotFound {
+		t.Errorf("removePost of a deleted post = %v, want ErrNotFound", err)
+	}
+}
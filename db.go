@@ -0,0 +1,340 @@
+package pubengine
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dialect is the SQL flavor of the blog database. Store queries are written
+// once, with ? placeholders, in the SQL that SQLite and PostgreSQL share;
+// the dialect rewrites them where MySQL differs, rewrites placeholders and
+// supplies the few expressions that differ.
+type dialect string
+
+// Supported dialects.
+const (
+	dialectSQLite   dialect = "sqlite"
+	dialectPostgres dialect = "postgres"
+	dialectMySQL    dialect = "mysql"
+)
+
+// driverDialects maps database/sql driver names to their dialect. The
+// server drivers register themselves when imported: pgx's stdlib package
+// as "pgx", lib/pq as "postgres" and go-sql-driver/mysql as "mysql".
+var driverDialects = map[string]dialect{
+	"sqlite":   dialectSQLite,
+	"pgx":      dialectPostgres,
+	"postgres": dialectPostgres,
+	"mysql":    dialectMySQL,
+}
+
+var (
+	reOnConflictUpdate  = regexp.MustCompile(`ON CONFLICT\s*(\([^)]*\))?\s*DO UPDATE SET`)
+	reOnConflictNothing = regexp.MustCompile(`\s*ON CONFLICT\s*(\([^)]*\))?\s*DO NOTHING`)
+	reExcluded          = regexp.MustCompile(`excluded\.(\w+)`)
+)
+
+// rebind rewrites query for the dialect. For PostgreSQL, ? placeholders
+// become $1, $2, .... For MySQL, upserts become ON DUPLICATE KEY UPDATE or
+// INSERT IGNORE and the key column, a reserved word there, is quoted.
+// Question marks and words in string literals are left alone.
+func (d dialect) rebind(query string) string {
+	switch d {
+	case dialectPostgres:
+		if !strings.Contains(query, "?") {
+			return query
+		}
+	case dialectMySQL:
+		if reOnConflictNothing.MatchString(query) {
+			query = reOnConflictNothing.ReplaceAllString(query, "")
+			query = strings.Replace(query, "INSERT INTO", "INSERT IGNORE INTO", 1)
+		}
+		if reOnConflictUpdate.MatchString(query) {
+			query = reOnConflictUpdate.ReplaceAllString(query, "ON DUPLICATE KEY UPDATE")
+			query = reExcluded.ReplaceAllString(query, "VALUES($1)")
+		}
+	default:
+		return query
+	}
+	var b strings.Builder
+	n, quoted := 0, false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '?' && d == dialectPostgres:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		case d == dialectMySQL && strings.HasPrefix(query[i:], "key") &&
+			(i == 0 || !isIdentByte(query[i-1])) && (i+3 == len(query) || !isIdentByte(query[i+3])):
+			b.WriteString("`key`")
+			i += 2
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '`' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// mysqlKeyColumns are the TEXT columns that are keys or indexed, which
+// MySQL needs as VARCHAR. Other TEXT columns become MEDIUMTEXT.
+var mysqlKeyColumns = map[string]bool{
+	"slug": true, "filename": true, "name": true, "code": true, "key": true,
+	"from_slug": true, "to_slug": true, "run_at": true, "dead_at": true,
+	"service": true, "url_hash": true, "publish_at": true, "email": true,
+	"token": true,
+}
+
+// ddl adapts a CREATE TABLE statement, or a column definition for ALTER
+// TABLE ADD COLUMN, written for SQLite.
+func (d dialect) ddl(stmt string) string {
+	switch d {
+	case dialectPostgres:
+		return strings.ReplaceAll(stmt, "INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY")
+	case dialectMySQL:
+		stmt = strings.ReplaceAll(stmt, "INTEGER PRIMARY KEY AUTOINCREMENT", "BIGINT PRIMARY KEY AUTO_INCREMENT")
+		lines := strings.Split(stmt, "\n")
+		for i, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[1] != "TEXT" {
+				continue
+			}
+			if mysqlKeyColumns[fields[0]] {
+				lines[i] = strings.Replace(line, " TEXT", " VARCHAR(255)", 1)
+				continue
+			}
+			// MySQL's TEXT stops at 64 KB, and only takes expressions as
+			// defaults.
+			line = strings.Replace(line, " TEXT", " MEDIUMTEXT", 1)
+			lines[i] = strings.Replace(line, "DEFAULT ''", "DEFAULT ('')", 1)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return stmt
+}
+
+// createIndex returns a statement creating an index, unless it exists
+// where the dialect supports that.
+func (d dialect) createIndex(name, table, columns string) string {
+	if d == dialectMySQL {
+		return "CREATE INDEX " + name + " ON " + table + "(" + columns + ")"
+	}
+	return "CREATE INDEX IF NOT EXISTS " + name + " ON " + table + "(" + columns + ")"
+}
+
+// strpos returns an expression for the 1-based position of needle in
+// haystack, 0 when it isn't there.
+func (d dialect) strpos(haystack, needle string) string {
+	if d == dialectPostgres {
+		return "strpos(" + haystack + ", " + needle + ")"
+	}
+	return "instr(" + haystack + ", " + needle + ")"
+}
+
+// concat returns an expression joining two strings.
+func (d dialect) concat(a, b string) string {
+	if d == dialectMySQL {
+		return "CONCAT(" + a + ", " + b + ")"
+	}
+	return a + " || " + b
+}
+
+// jsonObjectAgg returns an aggregate building a JSON object text from key
+// and value columns, NULL for no rows.
+func (d dialect) jsonObjectAgg(key, value string) string {
+	switch d {
+	case dialectPostgres:
+		return "json_object_agg(" + key + ", " + value + ")::text"
+	case dialectMySQL:
+		return "JSON_OBJECTAGG(" + key + ", " + value + ")"
+	}
+	return "json_group_object(" + key + ", " + value + ")"
+}
+
+// columnExistsQuery returns a query counting the columns of the table
+// given as its first argument with the name given as its second.
+func (d dialect) columnExistsQuery() string {
+	switch d {
+	case dialectPostgres:
+		return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+	case dialectMySQL:
+		return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	}
+	return `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+}
+
+// indexExistsQuery returns a query counting the indexes of the table given
+// as its first argument with the name given as its second.
+func (d dialect) indexExistsQuery() string {
+	switch d {
+	case dialectPostgres:
+		return `SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ? AND indexname = ?`
+	case dialectMySQL:
+		return `SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`
+	}
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?`
+}
+
+// sqlDB runs queries written for the dialect-neutral SQL of the Store on
+// a database of any dialect.
+type sqlDB struct {
+	*sql.DB
+	dialect dialect
+}
+
+func (db *sqlDB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (db *sqlDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...any) *sql.Row {
+	return db.DB.QueryRow(db.dialect.rebind(query), sqlArgs(args)...)
+}
+
+// addColumn adds a column, given as e.g. "views INTEGER NOT NULL DEFAULT 0"
+// in SQLite types, to table unless the table has a column of that name.
+func (db *sqlDB) addColumn(table, column string) error {
+	fields := strings.Fields(column)
+	if len(fields) == 0 {
+		return fmt.Errorf("add column to %s: empty definition", table)
+	}
+	if ok, err := db.exists(db.dialect.columnExistsQuery(), table, fields[0]); err != nil || ok {
+		return err
+	}
+	_, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + db.dialect.ddl(column))
+	return err
+}
+
+// createIndex creates an index on columns of table unless the table has
+// an index of that name.
+func (db *sqlDB) createIndex(name, table, columns string) error {
+	if ok, err := db.exists(db.dialect.indexExistsQuery(), table, name); err != nil || ok {
+		return err
+	}
+	_, err := db.Exec(db.dialect.createIndex(name, table, columns))
+	return err
+}
+
+// exists reports whether the COUNT(*) query counts any rows.
+func (db *sqlDB) exists(query string, args ...any) (bool, error) {
+	var n int
+	err := db.QueryRow(query, args...).Scan(&n)
+	return n > 0, err
+}
+
+// insert runs an INSERT into a table with an id column and returns the id
+// of the new row.
+func (db *sqlDB) insert(query string, args ...any) (int64, error) {
+	if db.dialect == dialectPostgres {
+		var id int64
+		err := db.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (db *sqlDB) Begin() (*sqlTx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx is a transaction on a sqlDB.
+type sqlTx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (tx *sqlTx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (tx *sqlTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), sqlArgs(args)...)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), sqlArgs(args)...)
+}
+
+// sqlArgs stores bools as 0 and 1, as the INTEGER flag columns expect;
+// PostgreSQL drivers won't convert them.
+func sqlArgs(args []any) []any {
+	var out []any
+	for i, arg := range args {
+		b, ok := arg.(bool)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = append([]any(nil), args...)
+		}
+		out[i] = 0
+		if b {
+			out[i] = 1
+		}
+	}
+	if out == nil {
+		return args
+	}
+	return out
+}
+
+// OpenStore opens the blog database with the database/sql driver named
+// driver and runs schema migrations. "sqlite" (or "") opens the SQLite file
+// at dsn like NewStore. "pgx" and "postgres" open a PostgreSQL database and
+// "mysql" a MySQL 8.0.13+ or MariaDB 10.5+ one, which every instance of the
+// app can share, with the connection string dsn; import a driver that
+// registers that name, e.g.
+//
+//	import _ "github.com/jackc/pgx/v5/stdlib" // "pgx"
+//	import _ "github.com/go-sql-driver/mysql" // "mysql"
+func OpenStore(driver, dsn string) (*Store, error) {
+	if driver == "" {
+		driver = "sqlite"
+	}
+	d, ok := driverDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	if d == dialectSQLite {
+		return NewStore(dsn)
+	}
+	if d == dialectMySQL && !strings.Contains(dsn, "clientFoundRows=") {
+		// Count the rows an UPDATE matches, not only those it changes, as
+		// the other databases do.
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "clientFoundRows=true"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newStore(&sqlDB{DB: db, dialect: d})
+}
@@ -0,0 +1,70 @@
+package pubengine
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// dialectCases are statements as the Store writes them, each rewritten by
+// every dialect and compared with testdata/dialect/<dialect>.sql.
+var dialectCases = []struct {
+	name string
+	sql  func(d dialect) string
+}{
+	{"select", func(d dialect) string {
+		return d.rebind(`SELECT slug FROM posts WHERE tags LIKE ? AND summary != 'why?' AND date < ?`)
+	}},
+	{"upsert", func(d dialect) string {
+		return d.rebind(`INSERT INTO settings (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value`)
+	}},
+	{"insert or ignore", func(d dialect) string {
+		return d.rebind(`INSERT INTO series (slug, title) VALUES (?, ?) ON CONFLICT(slug) DO NOTHING`)
+	}},
+	{"create table", func(d dialect) string {
+		return d.ddl(`CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL DEFAULT '',
+    run_at TEXT NOT NULL,
+    dead_at TEXT NOT NULL DEFAULT ''
+);`)
+	}},
+	{"add column", func(d dialect) string {
+		return d.ddl(`content_image TEXT NOT NULL DEFAULT ''`)
+	}},
+	{"create index", func(d dialect) string {
+		return d.createIndex("idx_jobs_due", "jobs", "dead_at, run_at")
+	}},
+	{"column exists", func(d dialect) string { return d.rebind(d.columnExistsQuery()) }},
+	{"index exists", func(d dialect) string { return d.rebind(d.indexExistsQuery()) }},
+}
+
+func TestDialectGolden(t *testing.T) {
+	for _, d := range []dialect{dialectSQLite, dialectPostgres, dialectMySQL} {
+		var b strings.Builder
+		for _, tc := range dialectCases {
+			b.WriteString("-- " + tc.name + "\n" + tc.sql(d) + "\n\n")
+		}
+		got := b.String()
+		path := filepath.Join("testdata", "dialect", string(d)+".sql")
+		if *updateGolden {
+			if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v; run go test -update to create it", err)
+		}
+		if got != string(want) {
+			t.Errorf("%s statements differ from %s:\n%s", d, path, got)
+		}
+	}
+}
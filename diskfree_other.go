@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package pubengine
+
+// freeDisk returns -1: free disk space is not checked on this platform.
+func freeDisk(dir string) int64 {
+	return -1
+}
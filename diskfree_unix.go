@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package pubengine
+
+import "syscall"
+
+// freeDisk returns the bytes available to the app on the file system
+// holding dir, or -1 if unknown.
+func freeDisk(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return -1
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
@@ -0,0 +1,104 @@
+package pubengine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// duplicateSimilarity is the share of word triples two posts' content must
+// have in common to count as near-identical.
+const duplicateSimilarity = 0.9
+
+// reNumberSuffix matches the "-2" an importer or a copy adds to a slug.
+var reNumberSuffix = regexp.MustCompile(`-\d+$`)
+
+// Duplicate is an existing post a new post looks like a copy of.
+type Duplicate struct {
+	Post    BlogPost
+	Reasons []string // "same title", "similar slug", "same content"
+}
+
+// FindDuplicates returns the posts in existing that post looks like a copy
+// of: posts with the same title ignoring case and punctuation, a slug that
+// differs only by a number suffix, or content with at least 90% of its word
+// triples in common. Posts with post's slug are skipped, since saving over
+// them is an edit. Numbered slugs are expected within a series, so they
+// don't count between posts of the same series.
+func FindDuplicates(post BlogPost, existing []BlogPost) []Duplicate {
+	title := normalizeWords(post.Title)
+	slug := reNumberSuffix.ReplaceAllString(post.Slug, "")
+	shingles := wordShingles(post.Content)
+
+	var dups []Duplicate
+	for _, p := range existing {
+		if p.Slug == post.Slug {
+			continue
+		}
+		var reasons []string
+		if title != "" && normalizeWords(p.Title) == title {
+			reasons = append(reasons, "same title")
+		}
+		sameSeries := post.SeriesSlug != "" && p.SeriesSlug == post.SeriesSlug
+		if !sameSeries && reNumberSuffix.ReplaceAllString(p.Slug, "") == slug {
+			reasons = append(reasons, "similar slug")
+		}
+		if strings.TrimSpace(post.Content) != "" && jaccard(shingles, wordShingles(p.Content)) >= duplicateSimilarity {
+			reasons = append(reasons, "same content")
+		}
+		if len(reasons) > 0 {
+			dups = append(dups, Duplicate{Post: p, Reasons: reasons})
+		}
+	}
+	return dups
+}
+
+// duplicateWarning describes dups for the admin dashboard.
+func duplicateWarning(dups []Duplicate) string {
+	var parts []string
+	for _, d := range dups {
+		parts = append(parts, fmt.Sprintf("%q (/blog/%s/, %s)", d.Post.Title, d.Post.Slug, strings.Join(d.Reasons, ", ")))
+	}
+	return "looks like a duplicate of " + strings.Join(parts, " and ")
+}
+
+// normalizeWords lowercases s and keeps only its letters and digits, one
+// space between words.
+func normalizeWords(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// wordShingles returns the set of consecutive word triples in s, or of its
+// words when it has fewer than three.
+func wordShingles(s string) map[string]bool {
+	words := strings.Fields(normalizeWords(s))
+	set := make(map[string]bool)
+	if len(words) < 3 {
+		if len(words) > 0 {
+			set[strings.Join(words, " ")] = true
+		}
+		return set
+	}
+	for i := 0; i+3 <= len(words); i++ {
+		set[strings.Join(words[i:i+3], " ")] = true
+	}
+	return set
+}
+
+// jaccard returns the size of the intersection of a and b over the size of
+// their union.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var common int
+	for k := range a {
+		if b[k] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,45 @@
+package pubengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	body := "Go makes it easy to build simple, reliable and efficient software. " +
+		"This post walks through the standard library packages used most often."
+	existing := []BlogPost{
+		{Slug: "hello-world", Title: "Hello, World!", Content: body},
+		{Slug: "other", Title: "Other", Content: "Something else entirely, about gardening and tomatoes."},
+		{Slug: "tour-1", Title: "Tour: basics", Content: "Variables, functions and types.", SeriesSlug: "tour"},
+	}
+	reasons := func(p BlogPost) []string {
+		var got []string
+		for _, d := range FindDuplicates(p, existing) {
+			got = append(got, d.Post.Slug+": "+strings.Join(d.Reasons, ", "))
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		post BlogPost
+		want []string
+	}{
+		{"importer rerun", BlogPost{Slug: "hello-world-2", Title: "Hello World", Content: body}, []string{"hello-world: same title, similar slug, same content"}},
+		{"small edit", BlogPost{Slug: "copy", Title: "Copy", Content: body + " Thanks."}, []string{"hello-world: same content"}},
+		{"title only", BlogPost{Slug: "greeting", Title: "hello world", Content: "New words."}, []string{"hello-world: same title"}},
+		{"edit of itself", BlogPost{Slug: "hello-world", Title: "Hello, World!", Content: body}, nil},
+		{"different", BlogPost{Slug: "new", Title: "New", Content: "A post about something new that shares nothing."}, nil},
+		{"empty content", BlogPost{Slug: "empty", Title: "Empty"}, nil},
+		{"next part of a series", BlogPost{Slug: "tour-2", Title: "Tour: methods", Content: "Methods and interfaces.", SeriesSlug: "tour"}, nil},
+		{"numbered outside the series", BlogPost{Slug: "tour-2", Title: "Tour: methods", Content: "Methods and interfaces."}, []string{"tour-1: similar slug"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reasons(tt.post); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
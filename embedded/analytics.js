@@ -1 +1 @@
-"use strict";(function(){const r="application/json",c=["1","yes"];function w(){const n=document.referrer;if(!n)return"";try{return new URL(n).host===window.location.host?"":n}catch{return""}}function o(n,e){(function(d,f){const p=JSON.stringify(d);if(typeof navigator.sendBeacon=="function"){const g=new Blob([p],{type:r});if(navigator.sendBeacon(f,g))return}fetch(f,{method:"POST",headers:{"Content-Type":r},body:p,keepalive:!0}).catch(()=>{})})((function(d){return{path:window.location.pathname,referrer:w(),screen_size:`${screen.width}x${screen.height}`,user_agent:navigator.userAgent,duration_sec:Math.max(0,Math.round(d))}})(n),e)}const t={pageLoadTime:0,isInitialized:!1};let a=!1;const i={endpoint:(function(){const n=document.currentScript;if(!n)return"";const e=n.src;if(!e)return"";try{return new URL(e).origin}catch{return""}})()+"/api/analytics/collect",doNotTrack:(function(){const n=navigator.doNotTrack,e=window.doNotTrack;return c.includes(n||"")||c.includes(e||"")})()};function u(){t.pageLoadTime=Date.now(),t.isInitialized=!0,o(0,i.endpoint)}function s(){t.isInitialized&&!a&&(a=!0,o((Date.now()-t.pageLoadTime)/1e3,i.endpoint))}function l(n){if(n.type!=="talkdom:done"||!("detail"in n)||n.detail===null||typeof n.detail!=="object"||!("receiver"in n.detail))return;if(n.detail.receiver==="content"&&t.isInitialized){o((Date.now()-t.pageLoadTime)/1e3,i.endpoint);t.pageLoadTime=Date.now();a=!1;setTimeout(()=>o(0,i.endpoint),10)}}typeof window<"u"&&typeof document<"u"&&typeof navigator<"u"&&(i.doNotTrack||(document.readyState==="loading"?document.addEventListener("DOMContentLoaded",u):u(),window.addEventListener("beforeunload",s),window.addEventListener("pagehide",s),window.talkDOM&&document.addEventListener("talkdom:done",l),window.Nanolytica={track:()=>{t.pageLoadTime=Date.now(),o(0,i.endpoint)}}))})();
+"use strict";(function(){const r="application/json",c=["1","yes"],h=15e3,y="nanolytica_q";function w(){const n=document.referrer;if(!n)return"";try{return new URL(n).host===window.location.host?"":n}catch{return""}}function q(n){try{return new URLSearchParams(window.location.search).get(n)||""}catch{return""}}function k(d){try{const q=JSON.parse(localStorage.getItem(y)||"[]");d.ts=d.ts||Date.now(),q.push(d),localStorage.setItem(y,JSON.stringify(q.slice(-50)))}catch{}}function z(){let q;try{q=JSON.parse(localStorage.getItem(y)||"[]"),localStorage.removeItem(y)}catch{return}q.length&&fetch(i.endpoint,{method:"POST",headers:{"Content-Type":r},body:JSON.stringify(q),keepalive:!0}).catch(()=>q.forEach(k))}function o(n,e,b){(function(d,f){if(navigator.onLine===!1){k(d);return}const p=JSON.stringify(d);if(typeof navigator.sendBeacon=="function"){const g=new Blob([p],{type:r});if(navigator.sendBeacon(f,g))return}fetch(f,{method:"POST",headers:{"Content-Type":r},body:p,keepalive:!0}).catch(()=>k(d))})((function(d){return{path:window.location.pathname,referrer:w(),screen_size:`${screen.width}x${screen.height}`,user_agent:navigator.userAgent,language:navigator.language||"",utm_source:q("utm_source"),utm_medium:q("utm_medium"),utm_campaign:q("utm_campaign"),hostname:window.location.hostname,duration_sec:Math.max(0,Math.round(d)),heartbeat:!!b}})(n),e)}const t={pageLoadTime:0,isInitialized:!1,engaged:0,visibleSince:0};let a=!1;const i={endpoint:(function(){const n=document.currentScript;if(!n)return"";const e=n.src;if(!e)return"";try{return new URL(e).origin}catch{return""}})()+"/api/analytics/collect",doNotTrack:(function(){const n=navigator.doNotTrack,e=window.doNotTrack;return c.includes(n||"")||c.includes(e||"")})()};function v(){return(t.engaged+(t.visibleSince?Date.now()-t.visibleSince:0))/1e3}function m(){t.pageLoadTime=Date.now(),t.engaged=0,t.visibleSince=document.visibilityState==="hidden"?0:Date.now()}function u(){m(),t.isInitialized=!0,z(),o(0,i.endpoint),setInterval(p,h)}function p(){t.isInitialized&&!a&&t.visibleSince&&o(v(),i.endpoint,!0)}function x(){t.isInitialized&&!a&&(document.visibilityState==="hidden"?(t.visibleSince&&(t.engaged+=Date.now()-t.visibleSince,t.visibleSince=0),o(v(),i.endpoint,!0)):t.visibleSince||(t.visibleSince=Date.now()))}function s(){t.isInitialized&&!a&&(a=!0,o(v(),i.endpoint))}function l(n){if(n.type!=="talkdom:done"||!("detail"in n)||n.detail===null||typeof n.detail!=="object"||!("receiver"in n.detail))return;if(n.detail.receiver==="content"&&t.isInitialized){o(v(),i.endpoint);m();a=!1;setTimeout(()=>o(0,i.endpoint),10)}}typeof window<"u"&&typeof document<"u"&&typeof navigator<"u"&&(i.doNotTrack||(document.readyState==="loading"?document.addEventListener("DOMContentLoaded",u):u(),document.addEventListener("visibilitychange",x),window.addEventListener("beforeunload",s),window.addEventListener("pagehide",s),window.addEventListener("online",z),window.talkDOM&&document.addEventListener("talkdom:done",l),window.Nanolytica={track:()=>{m(),o(0,i.endpoint)}}))})();
\ No newline at end of file
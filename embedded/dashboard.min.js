@@ -1 +1 @@
-"use strict";!function(){var t={currentTab:"visitors",visitorPeriod:"week",botPeriod:"week"};function n(){var e="bots"===t.currentTab?"/admin/analytics/fragments/bot-stats":"/admin/analytics/fragments/stats",o="bots"===t.currentTab?t.botPeriod:t.visitorPeriod;return e+"?period="+o}function i(e){t.currentTab=e,document.querySelectorAll(".tab-btn").forEach(function(b){b.classList.toggle("active",b.dataset.tab===e)});var o=document.getElementById("period-selector");o&&("setup"===e?o.style.display="none":(o.style.display="block",function(e){var o="bots"===e?t.botPeriod:t.visitorPeriod;document.querySelectorAll(".period-btn").forEach(function(b){b.classList.toggle("active",b.dataset.period===o)})}(e))),"setup"===e?talkDOM.send("content get: /admin/analytics/fragments/setup apply: inner"):talkDOM.send("content get: "+n()+" apply: inner")}function r(e){"bots"===t.currentTab?t.botPeriod=e:t.visitorPeriod=e,document.querySelectorAll(".period-btn").forEach(function(b){b.classList.toggle("active",b.dataset.period===e)}),talkDOM.send("content get: "+n()+" apply: inner")}window.switchTab=i,window.loadPeriod=r,setInterval(function(){"setup"!==t.currentTab&&talkDOM.send("content get: "+n()+" apply: inner")},6e4),talkDOM.send("content get: /admin/analytics/fragments/stats?period=week apply: inner")}();
+"use strict";!function(){var t={currentTab:"visitors",site:"",periods:{visitors:"week",bots:"week",feeds:"week"}},f={visitors:"stats",bots:"bot-stats",feeds:"feed-stats"};function n(){return"/admin/analytics/fragments/"+f[t.currentTab]+"?period="+t.periods[t.currentTab]+("visitors"===t.currentTab&&t.site?"&site="+encodeURIComponent(t.site):"")}function i(e){t.currentTab=e,document.querySelectorAll(".tab-btn").forEach(function(b){b.classList.toggle("active",b.dataset.tab===e)});var o=document.getElementById("period-selector");o&&("setup"===e?o.style.display="none":(o.style.display="block",function(e){var o=t.periods[e];document.querySelectorAll(".period-btn").forEach(function(b){b.classList.toggle("active",b.dataset.period===o)})}(e))),"setup"===e?talkDOM.send("content get: /admin/analytics/fragments/setup apply: inner"):talkDOM.send("content get: "+n()+" apply: inner")}function r(e){t.periods[t.currentTab]=e,document.querySelectorAll(".period-btn").forEach(function(b){b.classList.toggle("active",b.dataset.period===e)}),talkDOM.send("content get: "+n()+" apply: inner")}window.switchTab=i,window.loadPeriod=r,window.loadSite=function(e){t.site=e,talkDOM.send("content get: "+n()+" apply: inner")},setInterval(function(){"setup"!==t.currentTab&&talkDOM.send("content get: "+n()+" apply: inner")},6e4),talkDOM.send("content get: /admin/analytics/fragments/stats?period=week apply: inner")}();
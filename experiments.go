@@ -0,0 +1,150 @@
+package pubengine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/eringen/pubengine/analytics"
+)
+
+// Experiment is an A/B test on a single post. Each visitor is assigned a
+// variant deterministically from their anonymous analytics visitor ID, so
+// they keep seeing the same variant across page loads.
+type Experiment struct {
+	Name     string    // unique identifier, recorded with each exposure
+	Slug     string    // slug of the post under test
+	GoalPath string    // visiting this path after exposure counts as a conversion, e.g. "/newsletter/"
+	Variants []Variant // the first variant is the control
+}
+
+// Variant is one version of a post in an Experiment. Empty fields leave
+// the post unchanged.
+type Variant struct {
+	Name     string
+	Title    string // replaces BlogPost.Title
+	Summary  string // replaces BlogPost.Summary
+	Template string // exposed as BlogPost.Variant so templates can switch layouts
+}
+
+// ExperimentReport is the JSON response of the experiment report endpoint.
+type ExperimentReport struct {
+	Name     string                    `json:"name"`
+	Slug     string                    `json:"slug"`
+	GoalPath string                    `json:"goal_path"`
+	Control  string                    `json:"control"`
+	Variants []analytics.VariantResult `json:"variants"`
+}
+
+// WithExperiments registers A/B experiments. Exposures and conversions are
+// recorded only when analytics is enabled.
+func WithExperiments(exps ...Experiment) Option {
+	return func(a *App) {
+		a.experiments = append(a.experiments, exps...)
+	}
+}
+
+// Assign returns the variant shown to the given visitor.
+func (e Experiment) Assign(visitorID string) Variant {
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + "|" + visitorID))
+	return e.Variants[h.Sum32()%uint32(len(e.Variants))]
+}
+
+// Apply returns a copy of post with the variant's overrides applied.
+func (v Variant) Apply(post BlogPost) BlogPost {
+	if v.Title != "" {
+		post.Title = v.Title
+	}
+	if v.Summary != "" {
+		post.Summary = v.Summary
+	}
+	post.Variant = v.Template
+	return post
+}
+
+// validateExperiments checks that experiment names are unique, that each
+// post has at most one experiment and that every experiment has variants.
+func validateExperiments(exps []Experiment) error {
+	names := make(map[string]bool, len(exps))
+	slugs := make(map[string]bool, len(exps))
+	for _, e := range exps {
+		if e.Name == "" || e.Slug == "" || e.GoalPath == "" {
+			return fmt.Errorf("pubengine: experiment %q: Name, Slug and GoalPath are required", e.Name)
+		}
+		if len(e.Variants) < 2 {
+			return fmt.Errorf("pubengine: experiment %q: at least two variants are required", e.Name)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("pubengine: duplicate experiment %q", e.Name)
+		}
+		if slugs[e.Slug] {
+			return fmt.Errorf("pubengine: experiment %q: post %q already has an experiment", e.Name, e.Slug)
+		}
+		variants := make(map[string]bool, len(e.Variants))
+		for _, v := range e.Variants {
+			if v.Name == "" || variants[v.Name] {
+				return fmt.Errorf("pubengine: experiment %q: variant names must be unique and non-empty", e.Name)
+			}
+			variants[v.Name] = true
+		}
+		names[e.Name] = true
+		slugs[e.Slug] = true
+	}
+	return nil
+}
+
+// applyExperiment assigns the visitor a variant of post if an experiment
+// runs on it, and records the exposure in analytics.
+func (a *App) applyExperiment(c echo.Context, post BlogPost) BlogPost {
+	var exp *Experiment
+	for i := range a.experiments {
+		if a.experiments[i].Slug == post.Slug {
+			exp = &a.experiments[i]
+			break
+		}
+	}
+	if exp == nil {
+		return post
+	}
+
+	ua := c.Request().UserAgent()
+	visitorID := analytics.GenerateVisitorID(c.RealIP(), ua)
+	variant := exp.Assign(visitorID)
+
+	// The page now differs per visitor, so shared caches must not store it.
+	c.Response().Header().Set("Cache-Control", "private, no-cache")
+
+	// With analytics sampling on, only sampled visitors are exposed, as
+	// conversions are only stored for them.
+	if a.analyticsStore != nil && !analytics.IsBot(ua) && a.analyticsStore.Sampled(visitorID) {
+		if err := a.analyticsStore.RecordExposure(exp.Name, variant.Name, visitorID); err != nil {
+			c.Logger().Errorf("record experiment exposure: %v", err)
+		}
+	}
+	return variant.Apply(post)
+}
+
+// handleExperimentReport serves the experiment results as JSON. Its route
+// is protected by analytics.Handler.APIAuth, so admins and API tokens with
+// the experiments scope can read it.
+func (a *App) handleExperimentReport(c echo.Context) error {
+	reports := make([]ExperimentReport, 0, len(a.experiments))
+	for _, e := range a.experiments {
+		control := e.Variants[0].Name
+		results, err := a.analyticsStore.ExperimentResults(e.Name, e.GoalPath, control)
+		if err != nil {
+			return fmt.Errorf("experiment report: %w", err)
+		}
+		reports = append(reports, ExperimentReport{
+			Name:     e.Name,
+			Slug:     e.Slug,
+			GoalPath: e.GoalPath,
+			Control:  control,
+			Variants: results,
+		})
+	}
+	return c.JSON(http.StatusOK, reports)
+}
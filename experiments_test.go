@@ -0,0 +1,61 @@
+package pubengine
+
+import "testing"
+
+func testExperiment() Experiment {
+	return Experiment{
+		Name:     "headline",
+		Slug:     "hello",
+		GoalPath: "/newsletter/",
+		Variants: []Variant{
+			{Name: "control"},
+			{Name: "short", Title: "Hi", Template: "compact"},
+		},
+	}
+}
+
+func TestExperimentAssignIsDeterministic(t *testing.T) {
+	exp := testExperiment()
+	first := exp.Assign("visitor-1")
+	for i := 0; i < 10; i++ {
+		if got := exp.Assign("visitor-1"); got.Name != first.Name {
+			t.Fatalf("expected %q on every call, got %q", first.Name, got.Name)
+		}
+	}
+}
+
+func TestExperimentAssignUsesAllVariants(t *testing.T) {
+	exp := testExperiment()
+	seen := map[string]bool{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		seen[exp.Assign(id).Name] = true
+	}
+	if len(seen) != len(exp.Variants) {
+		t.Fatalf("expected all variants to be assigned, got %v", seen)
+	}
+}
+
+func TestVariantApply(t *testing.T) {
+	post := BlogPost{Title: "Hello world", Summary: "Original"}
+	got := testExperiment().Variants[1].Apply(post)
+	if got.Title != "Hi" || got.Summary != "Original" || got.Variant != "compact" {
+		t.Fatalf("unexpected post after apply: %+v", got)
+	}
+	if post.Title != "Hello world" {
+		t.Fatalf("expected original post to be unchanged")
+	}
+}
+
+func TestValidateExperiments(t *testing.T) {
+	if err := validateExperiments([]Experiment{testExperiment()}); err != nil {
+		t.Fatalf("expected valid experiment, got %v", err)
+	}
+	if err := validateExperiments([]Experiment{testExperiment(), testExperiment()}); err == nil {
+		t.Fatalf("expected duplicate experiment to be rejected")
+	}
+	single := testExperiment()
+	single.Variants = single.Variants[:1]
+	if err := validateExperiments([]Experiment{single}); err == nil {
+		t.Fatalf("expected experiment with one variant to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package pubengine
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/eringen/pubengine/markdown"
+	"github.com/labstack/echo/v4"
+)
+
+// markdownFileName returns the name a post is exported under, <slug>.md.
+func markdownFileName(p BlogPost) string {
+	return filepath.Base(p.Slug) + ".md"
+}
+
+// ExportMarkdown writes every post outside the trash, drafts included, to
+// dir as <slug>.md with YAML frontmatter, see BlogPost.ToMarkdownFile. dir
+// is created if needed and files of the same name are replaced.
+func (s *Store) ExportMarkdown(dir string) error {
+	posts, err := s.ListAllPosts()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, p := range posts {
+		if err := os.WriteFile(filepath.Join(dir, markdownFileName(p)), p.ToMarkdownFile(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMarkdownZip writes posts to w as a zip of <slug>.md files with YAML
+// frontmatter, each dated by its last change.
+func WriteMarkdownZip(w io.Writer, posts []BlogPost) error {
+	zw := zip.NewWriter(w)
+	for _, p := range posts {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     markdownFileName(p),
+			Method:   zip.Deflate,
+			Modified: lastModifiedTime(p),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(p.ToMarkdownFile()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// handleAdminExport downloads every post outside the trash as a zip of
+// Markdown files.
+func (a *App) handleAdminExport(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	posts, err := a.Content.ListAllPosts()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdownZip(&buf, posts); err != nil {
+		return err
+	}
+	name := "posts-" + time.Now().Format("2006-01-02") + ".zip"
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`"`)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// reSiteLink matches the site-relative URL of an inline Markdown link or
+// image, "](/path", or of a reference definition, "[ref]: /path".
+var reSiteLink = regexp.MustCompile(`(?m)(\]\(|^ {0,3}\[[^\]]+\]:[ \t]*)(/[^/\s)][^\s)]*)`)
+
+// ForCrossPost returns the post ready to paste into another site, such as
+// dev.to or Medium, or to send to someone: site-relative links and images
+// in its content become absolute URLs on cfg.URL, or MediaURL for media,
+// and a published post without a CanonicalURL points its canonical URL
+// back here.
+func (p BlogPost) ForCrossPost(cfg SiteConfig) BlogPost {
+	p.Content = reSiteLink.ReplaceAllStringFunc(p.Content, func(m string) string {
+		sub := reSiteLink.FindStringSubmatch(m)
+		return sub[1] + AbsoluteURL(BuildURL(cfg.URL), cfg.mediaURL(sub[2]))
+	})
+	if p.Published && p.CanonicalURL == "" {
+		p.CanonicalURL = p.CanonicalLink(cfg)
+	}
+	return p
+}
+
+// handleAdminCopyMarkdown returns a post, drafts included, as a Markdown
+// file with frontmatter, see BlogPost.ForCrossPost.
+func (a *App) handleAdminCopyMarkdown(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	post, err := a.Content.GetPostAny(c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Blob(http.StatusOK, mimeTextMarkdown, post.ForCrossPost(a.Config).ToMarkdownFile())
+}
+
+// handleAdminCopyHTML returns the content of a post, drafts included, as
+// an HTML fragment rendered like on the site, see BlogPost.ForCrossPost.
+// Raw HTML in the Markdown is escaped, as it is on the site.
+func (a *App) handleAdminCopyHTML(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	post, err := a.Content.GetPostAny(c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	var buf bytes.Buffer
+	if err := markdown.Markdown(post.ForCrossPost(a.Config).Content).Render(c.Request().Context(), &buf); err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
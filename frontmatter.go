@@ -0,0 +1,377 @@
+package pubengine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frontmatter delimiters. YAML frontmatter is fenced by "---" lines and TOML
+// frontmatter by "+++" lines.
+const (
+	yamlFence = "---"
+	tomlFence = "+++"
+)
+
+// ErrNoFrontmatter is returned by ParseFrontmatter when the file does not
+// start with a YAML or TOML frontmatter block.
+var ErrNoFrontmatter = errors.New("missing frontmatter")
+
+// ParseFrontmatter parses a Markdown file with YAML ("---") or TOML ("+++")
+// frontmatter into a BlogPost. The body after the closing fence becomes the
+// post content. Only the keys pubengine uses are understood: title, date,
+// slug, summary (or description), link, image (or og_image), tags, series,
+// series_order, author, canonical_url, featured, exclude_from_feed,
+// exclude_from_sitemap, noindex, published and draft, and meta, a map of
+// custom fields. Unknown keys are ignored. Posts without a published or
+// draft key are treated as published.
+func ParseFrontmatter(data []byte) (BlogPost, error) {
+	p, err := parseFrontmatter(data)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	if p.Title == "" {
+		return BlogPost{}, errors.New("frontmatter: title is required")
+	}
+	if p.Slug == "" {
+		p.Slug = Slugify(p.Title)
+	}
+	return p, nil
+}
+
+// parseFrontmatter is ParseFrontmatter without the required fields check,
+// for post templates that leave the title to the author.
+func parseFrontmatter(data []byte) (BlogPost, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\ufeff"))), "\r\n", "\n")
+
+	first, rest, _ := strings.Cut(text, "\n")
+	fence := strings.TrimSpace(first)
+	if fence != yamlFence && fence != tomlFence {
+		return BlogPost{}, ErrNoFrontmatter
+	}
+	toml := fence == tomlFence
+
+	var header []string
+	closed := false
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		if strings.TrimSpace(line) == fence {
+			closed = true
+			break
+		}
+		header = append(header, line)
+	}
+	if !closed {
+		return BlogPost{}, fmt.Errorf("frontmatter: missing closing %q", fence)
+	}
+
+	fields, err := parseFrontmatterFields(header, toml)
+	if err != nil {
+		return BlogPost{}, err
+	}
+
+	p := BlogPost{
+		Content:   strings.TrimLeft(rest, "\n"),
+		Published: true,
+	}
+	for key, v := range fields {
+		switch key {
+		case "title":
+			p.Title = v.str
+		case "date":
+			p.Date = normalizeFrontmatterDate(v.str)
+		case "slug":
+			p.Slug = v.str
+		case "summary":
+			p.Summary = v.str
+		case "description":
+			if _, ok := fields["summary"]; !ok {
+				p.Summary = v.str
+			}
+		case "link":
+			p.Link = v.str
+		case "image", "og_image":
+			p.OGImage = v.str
+		case "featured", "exclude_from_feed", "exclude_from_sitemap", "noindex":
+			b, err := strconv.ParseBool(v.str)
+			if err != nil {
+				return BlogPost{}, fmt.Errorf("frontmatter: %s: %w", key, err)
+			}
+			switch key {
+			case "featured":
+				p.Featured = b
+			case "exclude_from_feed":
+				p.ExcludeFromFeed = b
+			case "exclude_from_sitemap":
+				p.ExcludeFromSitemap = b
+			default:
+				p.NoIndex = b
+			}
+		case "canonical_url":
+			if v.str != "" && !validCanonicalURL(v.str) {
+				return BlogPost{}, fmt.Errorf("frontmatter: canonical_url: %q is not an absolute http(s) URL", v.str)
+			}
+			p.CanonicalURL = v.str
+		case "meta":
+			for k := range v.fields {
+				if !validMetaKey(k) {
+					return BlogPost{}, fmt.Errorf("frontmatter: meta: invalid key %q", k)
+				}
+			}
+			p.Meta = v.fields
+		case "author":
+			p.AuthorSlug = Slugify(v.str)
+		case "series":
+			p.SeriesSlug = Slugify(v.str)
+		case "series_order":
+			n, err := strconv.Atoi(v.str)
+			if err != nil {
+				return BlogPost{}, fmt.Errorf("frontmatter: series_order: %w", err)
+			}
+			p.SeriesOrder = n
+		case "tags":
+			p.Tags = v.list
+			if v.list == nil && v.str != "" {
+				p.Tags = []string{v.str}
+			}
+		case "published":
+			b, err := strconv.ParseBool(v.str)
+			if err != nil {
+				return BlogPost{}, fmt.Errorf("frontmatter: published: %w", err)
+			}
+			p.Published = b
+		case "draft":
+			if _, ok := fields["published"]; ok {
+				continue
+			}
+			b, err := strconv.ParseBool(v.str)
+			if err != nil {
+				return BlogPost{}, fmt.Errorf("frontmatter: draft: %w", err)
+			}
+			p.Published = !b
+		}
+	}
+	return p, nil
+}
+
+// ToMarkdownFile serializes the post as a Markdown file with YAML
+// frontmatter. The output round-trips through ParseFrontmatter.
+func (p BlogPost) ToMarkdownFile() []byte {
+	var b bytes.Buffer
+	b.WriteString(yamlFence + "\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(p.Title))
+	if p.Date != "" {
+		fmt.Fprintf(&b, "date: %s\n", p.Date)
+	}
+	fmt.Fprintf(&b, "slug: %s\n", strconv.Quote(p.Slug))
+	if p.Summary != "" {
+		fmt.Fprintf(&b, "summary: %s\n", strconv.Quote(p.Summary))
+	}
+	if p.Link != "" {
+		fmt.Fprintf(&b, "link: %s\n", strconv.Quote(p.Link))
+	}
+	if p.OGImage != "" {
+		fmt.Fprintf(&b, "image: %s\n", strconv.Quote(p.OGImage))
+	}
+	quoted := make([]string, len(p.Tags))
+	for i, t := range p.Tags {
+		quoted[i] = strconv.Quote(t)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(quoted, ", "))
+	if p.AuthorSlug != "" {
+		fmt.Fprintf(&b, "author: %s\n", strconv.Quote(p.AuthorSlug))
+	}
+	if p.SeriesSlug != "" {
+		fmt.Fprintf(&b, "series: %s\n", strconv.Quote(p.SeriesSlug))
+		fmt.Fprintf(&b, "series_order: %d\n", p.SeriesOrder)
+	}
+	if p.CanonicalURL != "" {
+		fmt.Fprintf(&b, "canonical_url: %s\n", strconv.Quote(p.CanonicalURL))
+	}
+	if len(p.Meta) > 0 {
+		b.WriteString("meta:\n")
+		for _, k := range p.MetaKeys() {
+			fmt.Fprintf(&b, "  %s: %s\n", k, strconv.Quote(p.Meta[k]))
+		}
+	}
+	if p.Featured {
+		b.WriteString("featured: true\n")
+	}
+	if p.ExcludeFromFeed {
+		b.WriteString("exclude_from_feed: true\n")
+	}
+	if p.ExcludeFromSitemap {
+		b.WriteString("exclude_from_sitemap: true\n")
+	}
+	if p.NoIndex {
+		b.WriteString("noindex: true\n")
+	}
+	fmt.Fprintf(&b, "published: %t\n", p.Published)
+	b.WriteString(yamlFence + "\n\n")
+	b.WriteString(p.Content)
+	if p.Content != "" && !strings.HasSuffix(p.Content, "\n") {
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// frontmatterValue is a scalar (str), list (list) or map (fields)
+// frontmatter value.
+type frontmatterValue struct {
+	str    string
+	list   []string
+	fields map[string]string
+}
+
+// parseFrontmatterFields parses flat "key: value" (YAML) or "key = value"
+// (TOML) lines. Lists may be inline ([a, b]) or, in YAML, a block of
+// "- item" lines following an empty value. The meta key holds a map of
+// scalars: indented "key: value" lines following "meta:" in YAML, or a
+// [meta] table in TOML.
+func parseFrontmatterFields(lines []string, toml bool) (map[string]frontmatterValue, error) {
+	sep := ":"
+	if toml {
+		sep = "="
+	}
+	fields := make(map[string]frontmatterValue)
+	lastKey := ""
+	inMeta := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if toml && strings.HasPrefix(trimmed, "[") {
+			if strings.TrimSpace(strings.Trim(trimmed, "[]")) == "meta" {
+				inMeta = true
+				continue
+			}
+			// Other TOML tables are nested sections pubengine doesn't use.
+			break
+		}
+		nested := inMeta || (!toml && lastKey == "meta" && trimmed != line && !strings.HasPrefix(trimmed, "- "))
+		if nested {
+			key, raw, ok := strings.Cut(trimmed, sep)
+			if !ok {
+				return nil, fmt.Errorf("frontmatter line %d: expected key%svalue", i+2, sep)
+			}
+			v, err := unquoteFrontmatter(strings.TrimSpace(raw))
+			if err != nil {
+				return nil, fmt.Errorf("frontmatter line %d: %w", i+2, err)
+			}
+			m := fields["meta"]
+			if m.fields == nil {
+				m.fields = make(map[string]string)
+			}
+			m.fields[strings.ToLower(strings.TrimSpace(key))] = v
+			fields["meta"] = m
+			continue
+		}
+		if !toml && strings.HasPrefix(trimmed, "- ") && lastKey != "" {
+			v := fields[lastKey]
+			item, err := unquoteFrontmatter(strings.TrimSpace(trimmed[2:]))
+			if err != nil {
+				return nil, fmt.Errorf("frontmatter line %d: %w", i+2, err)
+			}
+			v.list = append(v.list, item)
+			fields[lastKey] = v
+			continue
+		}
+		key, raw, ok := strings.Cut(trimmed, sep)
+		if !ok {
+			return nil, fmt.Errorf("frontmatter line %d: expected key%svalue", i+2, sep)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		raw = strings.TrimSpace(raw)
+		lastKey = key
+
+		if strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]") {
+			list, err := splitFrontmatterList(raw[1 : len(raw)-1])
+			if err != nil {
+				return nil, fmt.Errorf("frontmatter line %d: %w", i+2, err)
+			}
+			fields[key] = frontmatterValue{list: list}
+			continue
+		}
+		s, err := unquoteFrontmatter(raw)
+		if err != nil {
+			return nil, fmt.Errorf("frontmatter line %d: %w", i+2, err)
+		}
+		fields[key] = frontmatterValue{str: s}
+	}
+	return fields, nil
+}
+
+// splitFrontmatterList splits the inside of an inline list on commas that
+// are outside quotes, unquoting each item.
+func splitFrontmatterList(s string) ([]string, error) {
+	var items []string
+	var cur strings.Builder
+	var quote rune
+	escaped := false
+	flush := func() error {
+		item := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if item == "" {
+			return nil
+		}
+		v, err := unquoteFrontmatter(item)
+		if err != nil {
+			return err
+		}
+		items = append(items, v)
+		return nil
+	}
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// unquoteFrontmatter strips double or single quotes from a scalar. Unquoted
+// scalars lose any trailing " # comment".
+func unquoteFrontmatter(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
+
+// normalizeFrontmatterDate reduces full timestamps to the YYYY-MM-DD form
+// pubengine stores. Other values are kept as written.
+func normalizeFrontmatterDate(s string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return s
+}
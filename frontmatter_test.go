@@ -0,0 +1,250 @@
+package pubengine
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFrontmatterYAML(t *testing.T) {
+	input := "---\ntitle: \"Hello: World\"\ndate: 2024-03-01T10:00:00Z\ndescription: A summary\ntags:\n  - go\n  - 'web dev'\ndraft: true\n---\n\n# Body\n"
+	p, err := ParseFrontmatter([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseFrontmatter: %v", err)
+	}
+	want := BlogPost{
+		Title:   "Hello: World",
+		Date:    "2024-03-01",
+		Tags:    []string{"go", "web dev"},
+		Summary: "A summary",
+		Slug:    "hello-world",
+		Content: "# Body\n",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+}
+
+func TestParseFrontmatterTOML(t *testing.T) {
+	input := "+++\ntitle = \"TOML post\"\nslug = \"toml\"\ndate = 2024-01-02\ntags = [\"a\", \"b, c\"]\n+++\nBody"
+	p, err := ParseFrontmatter([]byte(input))
+	if err != nil {
+		t.Fatalf("ParseFrontmatter: %v", err)
+	}
+	if p.Title != "TOML post" || p.Slug != "toml" || p.Date != "2024-01-02" || !p.Published {
+		t.Errorf("unexpected post: %+v", p)
+	}
+	if !reflect.DeepEqual(p.Tags, []string{"a", "b, c"}) {
+		t.Errorf("tags = %q", p.Tags)
+	}
+	if p.Content != "Body" {
+		t.Errorf("content = %q", p.Content)
+	}
+}
+
+func TestParseFrontmatterMeta(t *testing.T) {
+	yaml := "---\ntitle: Meta\nmeta:\n  layout: wide\n  hero_image: \"/public/uploads/hero.jpg\"\ntags: [go]\n---\n"
+	toml := "+++\ntitle = \"Meta\"\ntags = [\"go\"]\n\n[meta]\nlayout = \"wide\"\nhero_image = \"/public/uploads/hero.jpg\"\n\n[extra]\nignored = \"x\"\n+++\n"
+	want := map[string]string{"layout": "wide", "hero_image": "/public/uploads/hero.jpg"}
+	for name, input := range map[string]string{"yaml": yaml, "toml": toml} {
+		p, err := ParseFrontmatter([]byte(input))
+		if err != nil {
+			t.Fatalf("%s: ParseFrontmatter: %v", name, err)
+		}
+		if !reflect.DeepEqual(p.Meta, want) {
+			t.Errorf("%s: meta = %v, want %v", name, p.Meta, want)
+		}
+		if !reflect.DeepEqual(p.Tags, []string{"go"}) {
+			t.Errorf("%s: tags = %q", name, p.Tags)
+		}
+	}
+	if _, err := ParseFrontmatter([]byte("---\ntitle: x\nmeta:\n  Bad Key: 1\n---\n")); err == nil {
+		t.Error("expected error for invalid meta key")
+	}
+}
+
+func TestParseFrontmatterErrors(t *testing.T) {
+	if _, err := ParseFrontmatter([]byte("# no frontmatter")); !errors.Is(err, ErrNoFrontmatter) {
+		t.Errorf("expected ErrNoFrontmatter, got %v", err)
+	}
+	if _, err := ParseFrontmatter([]byte("---\ntitle: x\n")); err == nil {
+		t.Error("expected error for unclosed frontmatter")
+	}
+	if _, err := ParseFrontmatter([]byte("---\nslug: x\n---\n")); err == nil {
+		t.Error("expected error for missing title")
+	}
+}
+
+func TestToMarkdownFileRoundTrip(t *testing.T) {
+	post := BlogPost{
+		Title:       `Quotes "and" backslashes \ here`,
+		Date:        "2024-05-06",
+		Tags:        []string{"go", "a, b"},
+		Summary:     "Line one\nline two",
+		Link:        "https://example.com/post",
+		Slug:        "quotes",
+		Content:     "Some **markdown**\n\n---\n\nafter a rule\n",
+		Published:   false,
+		Featured:    true,
+		OGImage:     "/public/uploads/cover.jpg",
+		AuthorSlug:  "jane-doe",
+		NoIndex:     true,
+		SeriesSlug:  "go-basics",
+		SeriesOrder: 2,
+
+		CanonicalURL: "https://dev.to/jane/quotes",
+		Meta:         map[string]string{"hero_image": "/public/uploads/hero.jpg", "layout": "wide: \"full\""},
+	}
+	got, err := ParseFrontmatter(post.ToMarkdownFile())
+	if err != nil {
+		t.Fatalf("ParseFrontmatter: %v", err)
+	}
+	if !reflect.DeepEqual(got, post) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, post)
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	posts := []BlogPost{
+		{Slug: "hello", Title: "Hello", Date: "2024-01-01", Tags: []string{"go"}, Content: "Hi", Published: true},
+		{Slug: "draft", Title: "Draft", Date: "2024-02-01", Content: "Soon"},
+		{Slug: "gone", Title: "Gone", Date: "2024-03-01", Published: true},
+	}
+	for _, p := range posts {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TrashPost("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "export")
+	if err := s.ExportMarkdown(dir); err != nil {
+		t.Fatalf("ExportMarkdown: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("exported %d files, want 2", len(entries))
+	}
+	for _, want := range posts[:2] {
+		data, err := os.ReadFile(filepath.Join(dir, want.Slug+".md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseFrontmatter(data)
+		if err != nil {
+			t.Fatalf("ParseFrontmatter(%s.md): %v", want.Slug, err)
+		}
+		if got.Title != want.Title || got.Date != want.Date || got.Published != want.Published || got.Content != want.Content+"\n" {
+			t.Errorf("%s.md = %+v", want.Slug, got)
+		}
+	}
+
+	all, _ := s.ListAllPosts()
+	var buf bytes.Buffer
+	if err := WriteMarkdownZip(&buf, all); err != nil {
+		t.Fatalf("WriteMarkdownZip: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "draft.md,hello.md" {
+		t.Errorf("zip files = %v", names)
+	}
+}
+
+func TestImportMarkdownDir(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	if err := s.SavePost(BlogPost{Slug: "hello", Title: "Existing", Date: "2023-01-01", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"_posts/2024-03-01-hello.md": "---\ntitle: Hello\ntags: [go]\n---\nHi\n",
+		"posts/toml.markdown":        "+++\ntitle = \"From Hugo\"\ndate = 2024-04-01\ndraft = true\n+++\nBody\n",
+		"posts/_index.md":            "---\ntitle: Posts\n---\n",
+		"notes.md":                   "No frontmatter\n",
+		".git/README.md":             "---\ntitle: Hidden\n---\n",
+		"image.png":                  "png",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.ImportMarkdownDir(dir)
+	if err != nil {
+		t.Fatalf("ImportMarkdownDir: %v", err)
+	}
+	if !reflect.DeepEqual(res.Imported, []string{"hello-2", "from-hugo"}) {
+		t.Errorf("Imported = %v", res.Imported)
+	}
+	if res.Renamed["_posts/2024-03-01-hello.md"] != "hello-2" {
+		t.Errorf("Renamed = %v", res.Renamed)
+	}
+	if len(res.Skipped) != 2 {
+		t.Errorf("Skipped = %v, want _index.md and notes.md", res.Skipped)
+	}
+
+	existing, _ := s.GetPostAny("hello")
+	if existing.Title != "Existing" {
+		t.Errorf("existing post overwritten: %+v", existing)
+	}
+	p, err := s.GetPostAny("hello-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Hello" || p.Date != "2024-03-01" || !p.Published || p.Content != "Hi\n" {
+		t.Errorf("hello-2 = %+v", p)
+	}
+	p, err = s.GetPostAny("from-hugo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Date != "2024-04-01" || p.Published {
+		t.Errorf("from-hugo = %+v", p)
+	}
+}
+
+func TestForCrossPost(t *testing.T) {
+	cfg := SiteConfig{URL: "https://example.com"}
+	post := BlogPost{
+		Slug:      "hello",
+		Title:     "Hello",
+		Published: true,
+		Content:   "See [this](/blog/other/) and ![a cat](/public/uploads/cat.jpg).\n\n[ref]: /about/\n\n[ext](https://go.dev/) and [proto](//cdn.example.com/x.js)\n",
+	}
+	got := post.ForCrossPost(cfg)
+	want := "See [this](https://example.com/blog/other/) and ![a cat](https://example.com/public/uploads/cat.jpg).\n\n[ref]: https://example.com/about/\n\n[ext](https://go.dev/) and [proto](//cdn.example.com/x.js)\n"
+	if got.Content != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+	if got.CanonicalURL != "https://example.com/blog/hello/" {
+		t.Errorf("CanonicalURL = %q", got.CanonicalURL)
+	}
+
+	post.Published = false
+	if got := post.ForCrossPost(cfg); got.CanonicalURL != "" {
+		t.Errorf("draft CanonicalURL = %q, want none", got.CanonicalURL)
+	}
+}
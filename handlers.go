@@ -2,7 +2,9 @@ package pubengine
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -13,11 +15,23 @@ func (a *App) handleHome(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if tag == "" {
+		posts = featuredFirst(posts)
+	}
 	tags, err := a.Cache.ListTags()
 	if err != nil {
 		return err
 	}
 	partial := c.QueryParam("partial")
+	if tag == "" && partial == "" && a.Views.HomeSections != nil {
+		sections, err := a.homeSections()
+		if err != nil {
+			return err
+		}
+		if len(sections) > 0 {
+			return Render(c, a.Views.HomeSections(sections, tags, a.Config.URL))
+		}
+	}
 	switch partial {
 	case "blog":
 		return Render(c, a.Views.BlogSection(posts, tag, tags))
@@ -27,12 +41,38 @@ func (a *App) handleHome(c echo.Context) error {
 	return Render(c, a.Views.Home(posts, tag, tags, a.Config.URL))
 }
 
+// featuredFirst returns the posts with the featured ones moved to the
+// front, each group keeping its order.
+func featuredFirst(posts []BlogPost) []BlogPost {
+	sorted := make([]BlogPost, 0, len(posts))
+	for _, p := range posts {
+		if p.Featured {
+			sorted = append(sorted, p)
+		}
+	}
+	for _, p := range posts {
+		if !p.Featured {
+			sorted = append(sorted, p)
+		}
+	}
+	return sorted
+}
+
 func (a *App) handlePost(c echo.Context) error {
+	if a.Config.ServeMarkdown {
+		c.Response().Header().Add("Vary", "Accept")
+		if prefersMarkdown(c.Request().Header.Get(echo.HeaderAccept)) {
+			return a.handlePostMarkdown(c)
+		}
+	}
 	slug := c.Param("slug")
 	post, err := a.Cache.GetPost(slug)
+	if err == sql.ErrNoRows {
+		post, err = a.previewPost(c, slug)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return RenderStatus(c, http.StatusNotFound, a.Views.NotFound())
+			return a.redirectPost(c, slug)
 		}
 		return err
 	}
@@ -40,12 +80,34 @@ func (a *App) handlePost(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	post = a.applyExperiment(c, post)
+	post, err = a.withSeries(post)
+	if err != nil {
+		return err
+	}
+	post, err = a.withAuthor(post)
+	if err != nil {
+		return err
+	}
+	post.Backlinks, err = a.Cache.ListBacklinks(post.Slug)
+	if err != nil {
+		return err
+	}
+	setNoIndex(c, post)
 	if c.QueryParam("partial") == "post" {
 		return Render(c, a.Views.PostPartial(post, posts, a.Config.URL))
 	}
 	return Render(c, a.Views.Post(post, posts, a.Config.URL))
 }
 
+// setNoIndex sends X-Robots-Tag: noindex for NoIndex posts, which also
+// covers their plain and Markdown versions.
+func setNoIndex(c echo.Context, post BlogPost) {
+	if post.NoIndex {
+		c.Response().Header().Set("X-Robots-Tag", "noindex")
+	}
+}
+
 func (a *App) handleSitemap(c echo.Context) error {
 	posts, err := a.Cache.ListPosts("")
 	if err != nil {
@@ -54,12 +116,15 @@ func (a *App) handleSitemap(c echo.Context) error {
 	return a.renderSitemap(c, posts)
 }
 
+// handleFeed serves the RSS feed of all posts, or of the posts tagged with
+// the tag query parameter.
 func (a *App) handleFeed(c echo.Context) error {
-	posts, err := a.Cache.ListPosts("")
+	tag := strings.ToLower(strings.TrimSpace(c.QueryParam("tag")))
+	posts, err := a.Cache.ListPosts(tag)
 	if err != nil {
 		return err
 	}
-	return a.renderRSS(c, posts)
+	return a.renderRSS(c, posts, tag)
 }
 
 func handleBlogRedirect(c echo.Context) error {
@@ -70,27 +135,39 @@ func (a *App) handleFavicon(c echo.Context) error {
 	return c.File(a.staticDir + "/favicon.svg")
 }
 
-func (a *App) handleRobots(c echo.Context) error {
-	return c.File(a.staticDir + "/robots.txt")
-}
-
+// httpErrorHandler sends errors as problem details (see Problem) on API
+// routes and to JSON clients, and as the theme's NotFound and ServerError
+// pages elsewhere. Other page errors are sent as plain text.
 func (a *App) httpErrorHandler(err error, c echo.Context) {
 	if c.Response().Committed {
 		return
 	}
-	he, ok := err.(*echo.HTTPError)
-	if ok && he.Code == http.StatusNotFound {
-		_ = RenderStatus(c, http.StatusNotFound, a.Views.NotFound())
-		return
-	}
+	var he *echo.HTTPError
 	code := http.StatusInternalServerError
-	if ok {
+	switch {
+	case errors.As(err, &he):
+		code = he.Code
+	case isBodyTooLarge(err):
+		he = echo.ErrStatusRequestEntityTooLarge
 		code = he.Code
 	}
 	if code >= 500 {
 		c.Logger().Errorf("server error: %v", err)
-		_ = RenderStatus(c, code, a.Views.ServerError())
+	}
+	if wantsProblemJSON(c) {
+		_ = renderProblem(c, code, he)
 		return
 	}
-	a.Echo.DefaultHTTPErrorHandler(err, c)
+	switch {
+	case code == http.StatusNotFound:
+		_ = RenderStatus(c, code, a.Views.NotFound())
+	case code >= 500:
+		_ = RenderStatus(c, code, a.Views.ServerError())
+	default:
+		msg := http.StatusText(code)
+		if m, ok := he.Message.(string); ok {
+			msg = m
+		}
+		_ = c.String(code, msg)
+	}
 }
@@ -0,0 +1,49 @@
+package pubengine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPostVisibilityFlags(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app,
+		BlogPost{Slug: "listed", Title: "Listed", Date: "2024-01-01", Published: true},
+		BlogPost{Slug: "no-feed", Title: "No Feed", Date: "2024-01-02", ExcludeFromFeed: true, Published: true},
+		BlogPost{Slug: "no-sitemap", Title: "No Sitemap", Date: "2024-01-03", ExcludeFromSitemap: true, Published: true},
+		BlogPost{Slug: "no-index", Title: "No Index", Date: "2024-01-04", NoIndex: true, Published: true},
+	)
+
+	feed := serveGet(app, "/feed.xml").Body.String()
+	if strings.Contains(feed, "/blog/no-feed/") || !strings.Contains(feed, "/blog/no-sitemap/") {
+		t.Errorf("feed should leave out only the post excluded from it:\n%s", feed)
+	}
+	sitemap := serveGet(app, "/sitemap.xml").Body.String()
+	for slug, want := range map[string]bool{"listed": true, "no-feed": true, "no-sitemap": false, "no-index": false} {
+		if got := strings.Contains(sitemap, "/blog/"+slug+"/"); got != want {
+			t.Errorf("sitemap lists %s = %v, want %v", slug, got, want)
+		}
+	}
+	if strings.Contains(serveGet(app, "/llms.txt").Body.String(), "No Index") {
+		t.Error("llms.txt should leave out NoIndex posts")
+	}
+
+	if rec := serveGet(app, "/blog/no-index/"); rec.Header().Get("X-Robots-Tag") != "noindex" {
+		t.Errorf("X-Robots-Tag = %q, want noindex", rec.Header().Get("X-Robots-Tag"))
+	}
+	if rec := serveGet(app, "/blog/listed/"); rec.Header().Get("X-Robots-Tag") != "" {
+		t.Errorf("X-Robots-Tag on an indexed post = %q", rec.Header().Get("X-Robots-Tag"))
+	}
+	post, err := app.Store.GetPost("no-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := HeadMeta(app.Config, &post).Component().Render(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<meta name="robots" content="noindex">`) {
+		t.Errorf("head missing robots meta:\n%s", b.String())
+	}
+}
@@ -0,0 +1,189 @@
+package pubengine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// Head is the complete SEO metadata of a page: canonical URL, OpenGraph and
+// Twitter card tags, feed discovery, the web app manifest and JSON-LD. Build
+// it with HeadMeta and render it inside <head> with Component, so themes
+// don't have to get every tag right themselves.
+type Head struct {
+	Title       string // og:title and twitter:title; <title> adds " | SiteName"
+	SiteName    string // og:site_name
+	Description string // meta description, og:description and twitter:description
+	Canonical   string // canonical link and og:url
+	OGType      string // "website" or "article"
+	Image       string // absolute og:image and twitter:image URL, optional
+	TwitterCard string // "summary_large_image" with an image, otherwise "summary"
+	Published   string // article:published_time, posts only
+	Modified    string // article:modified_time, posts only
+	Tags        []string
+	Feeds       []FeedLink // RSS feeds advertised with <link rel="alternate">, see FeedLinks
+	Plain       string     // plain reader version advertised with <link rel="alternate">, posts only
+	Markdown    string     // Markdown source advertised with <link rel="alternate">, posts with ServeMarkdown only
+	JSONLD      string     // WebSite or BlogPosting JSON-LD
+	Manifest    string     // web app manifest link
+	ThemeColor  string     // theme-color meta tag, optional
+	Robots      string     // robots meta tag, "noindex" for NoIndex posts
+}
+
+// HeadMeta returns the head metadata for post, or for the site home page
+// when post is nil. Set Title and Description afterwards for other pages.
+func HeadMeta(cfg SiteConfig, post *BlogPost) Head {
+	h := Head{
+		Title:       cfg.Name,
+		SiteName:    cfg.Name,
+		Description: cfg.Description,
+		Canonical:   BuildURL(cfg.URL),
+		OGType:      "website",
+		Feeds:       FeedLinks(cfg, ""),
+		JSONLD:      WebsiteJsonLD(cfg),
+		Manifest:    "/manifest.json",
+		ThemeColor:  cfg.ThemeColor,
+	}
+	if post != nil {
+		h.Title = post.Title
+		h.Description = post.Excerpt(cfg)
+		h.Canonical = post.CanonicalLink(cfg)
+		h.OGType = "article"
+		h.Image = AbsoluteURL(cfg.URL, cfg.mediaURL(post.SocialImage()))
+		h.Published = post.Date
+		h.Modified = post.LastModified()
+		h.Plain = PlainURL(cfg.URL, post.Slug)
+		if cfg.ServeMarkdown {
+			h.Markdown = MarkdownURL(cfg.URL, post.Slug)
+		}
+		h.Tags = post.Tags
+		h.JSONLD = BlogPostingJsonLD(*post, cfg)
+		if post.NoIndex {
+			h.Robots = "noindex"
+		}
+	}
+	h.TwitterCard = "summary"
+	if h.Image != "" {
+		h.TwitterCard = "summary_large_image"
+	}
+	return h
+}
+
+// HeadMetaForTag returns the head metadata for the home page filtered to
+// tag, which also advertises the tag's feed. It is HeadMeta(cfg, nil) when
+// tag is empty.
+func HeadMetaForTag(cfg SiteConfig, tag string) Head {
+	h := HeadMeta(cfg, nil)
+	if tag == "" {
+		return h
+	}
+	h.Title = "Posts tagged " + tag
+	h.Canonical = TagURL(cfg.URL, tag)
+	h.Feeds = FeedLinks(cfg, tag)
+	return h
+}
+
+// FeedLink is an RSS feed a page advertises.
+type FeedLink struct {
+	Title string // e.g. "Blog" or "Blog: go"
+	URL   string
+}
+
+// FeedLinks returns the feeds for a page: the site feed, followed by the
+// feed of tag when tag is non-empty. Themes can also render them as
+// subscribe links.
+func FeedLinks(cfg SiteConfig, tag string) []FeedLink {
+	feed := AbsoluteURL(BuildURL(cfg.URL), "feed.xml")
+	links := []FeedLink{{Title: cfg.Name, URL: feed}}
+	if tag != "" {
+		links = append(links, FeedLink{Title: cfg.Name + ": " + tag, URL: feed + "?tag=" + url.QueryEscape(tag)})
+	}
+	return links
+}
+
+// TagURL returns the URL of the home page filtered to tag, e.g.
+// "https://example.com/?tag=go".
+func TagURL(base, tag string) string {
+	return strings.TrimSuffix(BuildURL(base), "/") + "/?tag=" + url.QueryEscape(tag)
+}
+
+// DocumentTitle returns the <title> text: "Title | SiteName", or whichever
+// of the two is set when they are the same or one is empty.
+func (h Head) DocumentTitle() string {
+	if h.Title == "" || h.Title == h.SiteName {
+		return h.SiteName
+	}
+	if h.SiteName == "" {
+		return h.Title
+	}
+	return h.Title + " | " + h.SiteName
+}
+
+// Component renders the metadata as <title>, <meta>, <link> and JSON-LD <script>
+// elements for use inside a theme's <head>. Empty fields are left out.
+func (h Head) Component() templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		var b strings.Builder
+		attr := func(format string, args ...string) {
+			escaped := make([]any, len(args))
+			for i, a := range args {
+				escaped[i] = html.EscapeString(a)
+			}
+			fmt.Fprintf(&b, format+"\n", escaped...)
+		}
+		meta := func(key, name, content string) {
+			if content != "" {
+				attr(`<meta %s="%s" content="%s">`, key, name, content)
+			}
+		}
+
+		if title := h.DocumentTitle(); title != "" {
+			fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+		}
+		meta("name", "description", h.Description)
+		meta("name", "robots", h.Robots)
+		if h.Canonical != "" {
+			attr(`<link rel="canonical" href="%s">`, h.Canonical)
+		}
+		if h.Manifest != "" {
+			attr(`<link rel="manifest" href="%s">`, h.Manifest)
+		}
+		meta("name", "theme-color", h.ThemeColor)
+		for _, f := range h.Feeds {
+			attr(`<link rel="alternate" type="application/rss+xml" title="%s" href="%s">`, f.Title, f.URL)
+		}
+		if h.Plain != "" {
+			attr(`<link rel="alternate" type="text/html" title="Plain version" href="%s">`, h.Plain)
+		}
+		if h.Markdown != "" {
+			attr(`<link rel="alternate" type="text/markdown" title="Markdown source" href="%s">`, h.Markdown)
+		}
+		meta("property", "og:title", h.Title)
+		meta("property", "og:site_name", h.SiteName)
+		meta("property", "og:description", h.Description)
+		meta("property", "og:url", h.Canonical)
+		meta("property", "og:type", h.OGType)
+		meta("property", "og:image", h.Image)
+		meta("property", "article:published_time", h.Published)
+		meta("property", "article:modified_time", h.Modified)
+		for _, t := range h.Tags {
+			meta("property", "article:tag", t)
+		}
+		meta("name", "twitter:card", h.TwitterCard)
+		meta("name", "twitter:title", h.Title)
+		meta("name", "twitter:description", h.Description)
+		meta("name", "twitter:image", h.Image)
+		if h.JSONLD != "" {
+			// json.Marshal escapes <, > and &, so the data can't close the script.
+			fmt.Fprintf(&b, "<script type=\"application/ld+json\">%s</script>\n", h.JSONLD)
+		}
+
+		_, err := io.WriteString(w, b.String())
+		return err
+	})
+}
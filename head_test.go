@@ -0,0 +1,120 @@
+package pubengine
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHeadMeta(t *testing.T) {
+	cfg := SiteConfig{Name: "Site", URL: "https://example.com", Description: "A blog", ThemeColor: "#112233"}
+	post := BlogPost{
+		Slug:    "hello",
+		Title:   `Hello "World"`,
+		Date:    "2024-01-15",
+		Summary: "Greetings",
+		Tags:    []string{"go"},
+		OGImage: "/public/uploads/cover.jpg",
+	}
+
+	h := HeadMeta(cfg, &post)
+	if h.DocumentTitle() != `Hello "World" | Site` {
+		t.Errorf("DocumentTitle = %q", h.DocumentTitle())
+	}
+	if h.Canonical != "https://example.com/blog/hello/" || h.OGType != "article" {
+		t.Errorf("unexpected canonical/type: %q %q", h.Canonical, h.OGType)
+	}
+	if len(h.Feeds) != 1 || h.Feeds[0] != (FeedLink{Title: "Site", URL: "https://example.com/feed.xml"}) {
+		t.Errorf("Feeds = %+v", h.Feeds)
+	}
+
+	var b bytes.Buffer
+	if err := h.Component().Render(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`<title>Hello &#34;World&#34; | Site</title>`,
+		`<link rel="canonical" href="https://example.com/blog/hello/">`,
+		`<meta property="og:title" content="Hello &#34;World&#34;">`,
+		`<meta property="og:image" content="https://example.com/public/uploads/cover.jpg">`,
+		`<meta property="article:tag" content="go">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<link rel="manifest" href="/manifest.json">`,
+		`<meta name="theme-color" content="#112233">`,
+		`<link rel="alternate" type="application/rss+xml" title="Site" href="https://example.com/feed.xml">`,
+		`<link rel="alternate" type="text/html" title="Plain version" href="https://example.com/blog/hello/plain/">`,
+		`<script type="application/ld+json">{"@context":"https://schema.org","@type":"BlogPosting"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+
+	home := HeadMeta(cfg, nil)
+	if home.DocumentTitle() != "Site" || home.Description != "A blog" || home.TwitterCard != "summary" {
+		t.Errorf("unexpected home head: %+v", home)
+	}
+	if !strings.Contains(home.JSONLD, `"@type":"WebSite"`) {
+		t.Errorf("home JSON-LD = %s", home.JSONLD)
+	}
+	if home.Plain != "" {
+		t.Errorf("home Plain = %q, want none", home.Plain)
+	}
+
+	tag := HeadMetaForTag(cfg, "go & web")
+	if tag.Canonical != "https://example.com/?tag=go+%26+web" || tag.DocumentTitle() != "Posts tagged go & web | Site" {
+		t.Errorf("unexpected tag head: %+v", tag)
+	}
+	b.Reset()
+	if err := tag.Component().Render(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<link rel="alternate" type="application/rss+xml" title="Site" href="https://example.com/feed.xml">`,
+		`<link rel="alternate" type="application/rss+xml" title="Site: go &amp; web" href="https://example.com/feed.xml?tag=go+%26+web">`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %s in:\n%s", want, b.String())
+		}
+	}
+	if HeadMetaForTag(cfg, "").Canonical != home.Canonical {
+		t.Error("HeadMetaForTag with no tag differs from the home page head")
+	}
+}
+
+func TestSharePreview(t *testing.T) {
+	cfg := SiteConfig{Name: "Site", URL: "https://example.com"}
+	post := BlogPost{Slug: "hello", Title: "Hello", Summary: "Greetings", OGImage: "/public/uploads/cover.jpg"}
+	postURL := BuildURL(cfg.URL, "blog", post.Slug)
+
+	var b bytes.Buffer
+	if err := HeadMeta(cfg, &post).Component().Render(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	p := extractSharePreview([]byte("<html><head>" + b.String() + "</head><body></body></html>"))
+	if p.Title != "Hello" || p.Description != "Greetings" || p.Image != "https://example.com/public/uploads/cover.jpg" ||
+		p.URL != postURL || p.SiteName != "Site" || p.Card != "summary_large_image" {
+		t.Errorf("unexpected preview: %+v", p)
+	}
+	if len(p.JSONLD) != 1 || p.JSONLD[0] != "BlogPosting" {
+		t.Errorf("JSONLD = %v", p.JSONLD)
+	}
+	if w := shareWarnings(p, postURL); len(w) != 0 {
+		t.Errorf("expected no warnings for HeadMeta output, got %q", w)
+	}
+
+	page := `<head><title>Bare</title><meta name="description" content="` + strings.Repeat("x", 250) + `">` +
+		`<script type="application/ld+json">{broken</script></head>`
+	p = extractSharePreview([]byte(page))
+	if p.Title != "Bare" {
+		t.Errorf("Title = %q, want the <title> fallback", p.Title)
+	}
+	w := strings.Join(shareWarnings(p, postURL), "\n")
+	for _, want := range []string{"No og:title", "Description is 250 characters", "No image", "No og:url", "No twitter:card", "JSON-LD is not valid JSON"} {
+		if !strings.Contains(w, want) {
+			t.Errorf("missing warning %q in:\n%s", want, w)
+		}
+	}
+}
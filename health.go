@@ -0,0 +1,193 @@
+package pubengine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Health statuses.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded" // analytics is failing, the blog is fine
+	HealthDown     = "down"     // the blog database is failing
+)
+
+// minFreeDisk is the free disk space below which a database check fails,
+// leaving room for the WAL to grow and checkpoint.
+const minFreeDisk = 64 << 20
+
+// healthSettingKey is the setting rewritten by each check to prove the
+// database still accepts writes.
+const healthSettingKey = "health_checked_at"
+
+// Health is the state of the app's databases, served at /readyz.
+type Health struct {
+	Status    string     `json:"status"` // HealthOK, HealthDegraded or HealthDown
+	Databases []DBHealth `json:"databases"`
+}
+
+// DBHealth is the result of a database self-check.
+type DBHealth struct {
+	Name      string    `json:"name"` // "blog" or "analytics"
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+	WALSize   int64     `json:"wal_size"`  // bytes in the write-ahead log
+	FreeDisk  int64     `json:"free_disk"` // bytes free on its file system, -1 when unknown
+	LastWrite time.Time `json:"last_write,omitzero"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// healthStore is what a self-check needs from Store and analytics.Store.
+type healthStore interface {
+	Ping() error
+	SetSetting(key, value string) error
+}
+
+// healthChecker keeps the latest Health.
+type healthChecker struct {
+	mu     sync.Mutex
+	health Health
+}
+
+// Health returns the result of the latest database self-check. Checks run
+// every 30 seconds while the app is running.
+func (a *App) Health() Health {
+	a.health.mu.Lock()
+	defer a.health.mu.Unlock()
+	h := a.health.health
+	h.Databases = append([]DBHealth(nil), h.Databases...)
+	return h
+}
+
+// checkHealth checks the databases and records the result.
+func (a *App) checkHealth() Health {
+	a.health.mu.Lock()
+	previous := a.health.health.Databases
+	a.health.mu.Unlock()
+	lastWrite := func(i int) time.Time {
+		if i < len(previous) {
+			return previous[i].LastWrite
+		}
+		return time.Time{}
+	}
+
+	h := Health{Status: HealthOK}
+	blogPath := ""
+	if a.Config.usesSQLite() {
+		blogPath = a.Config.DatabasePath
+	}
+	blog := checkDB("blog", a.Store, blogPath, lastWrite(0))
+	h.Databases = append(h.Databases, blog)
+	if a.analyticsStore != nil {
+		an := checkDB("analytics", a.analyticsStore, a.Config.AnalyticsDatabasePath, lastWrite(1))
+		h.Databases = append(h.Databases, an)
+		if !an.OK {
+			h.Status = HealthDegraded
+		}
+	}
+	if !blog.OK {
+		h.Status = HealthDown
+	}
+
+	a.health.mu.Lock()
+	a.health.health = h
+	a.health.mu.Unlock()
+	return h
+}
+
+// checkDB pings the database at path, writes to it and checks its WAL and
+// the disk it's on; path is empty for a database on a server, which is
+// only pinged and written to. lastWrite is the time of the last successful
+// write.
+func checkDB(name string, s healthStore, path string, lastWrite time.Time) DBHealth {
+	now := time.Now().UTC()
+	d := DBHealth{Name: name, FreeDisk: -1, LastWrite: lastWrite, CheckedAt: now}
+	var errs []error
+	if err := s.Ping(); err != nil {
+		errs = append(errs, fmt.Errorf("ping: %w", err))
+	} else if err := s.SetSetting(healthSettingKey, now.Format(time.RFC3339)); err != nil {
+		errs = append(errs, fmt.Errorf("write: %w", err))
+	} else {
+		d.LastWrite = now
+	}
+	if path != "" && path != ":memory:" {
+		if fi, err := os.Stat(path + "-wal"); err == nil {
+			d.WALSize = fi.Size()
+		}
+		d.FreeDisk = freeDisk(filepath.Dir(path))
+		if d.FreeDisk >= 0 && d.FreeDisk < minFreeDisk {
+			errs = append(errs, fmt.Errorf("low disk space: %d MB free", d.FreeDisk>>20))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		d.Error = err.Error()
+	}
+	d.OK = d.Error == ""
+	return d
+}
+
+// startHealthChecks checks the databases every interval. Returns a stop
+// function.
+func (a *App) startHealthChecks(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if h := a.checkHealth(); h.Status != HealthOK {
+					for _, d := range h.Databases {
+						if !d.OK {
+							a.Echo.Logger.Errorf("%s database unhealthy: %s", d.Name, d.Error)
+						}
+					}
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// handleReadyz reports whether the app can serve the blog: 200 while the
+// blog database is healthy, even if analytics is degraded, 503 otherwise.
+func (a *App) handleReadyz(c echo.Context) error {
+	h := a.Health()
+	if h.Status == HealthDown {
+		return c.JSON(http.StatusServiceUnavailable, h)
+	}
+	return c.JSON(http.StatusOK, h)
+}
+
+func (a *App) handleAdminStatus(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminStatus == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return Render(c, a.Views.AdminStatus(a.checkHealth()))
+}
+
+// healthMessage returns the admin dashboard notice for a degraded or down
+// app, or "".
+func healthMessage(h Health) string {
+	switch h.Status {
+	case HealthDown:
+		return "The blog database is failing its health checks. See Status for details."
+	case HealthDegraded:
+		return "Analytics is unavailable; the blog is unaffected. See Status for details."
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.AnalyticsEnabled = true
+	app.Config.AnalyticsDatabasePath = filepath.Join(t.TempDir(), "analytics.db")
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	readyz := func() (int, Health) {
+		t.Helper()
+		rec := serveGet(app, "/readyz")
+		var h Health
+		if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+			t.Fatalf("decode %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, h
+	}
+
+	code, h := readyz()
+	if code != http.StatusOK || h.Status != HealthOK || len(h.Databases) != 2 {
+		t.Fatalf("healthy readyz = %d %+v", code, h)
+	}
+	an := h.Databases[1]
+	if an.Name != "analytics" || !an.OK || an.LastWrite.IsZero() || an.FreeDisk == 0 {
+		t.Errorf("analytics health = %+v", an)
+	}
+
+	app.analyticsStore.Close()
+	app.checkHealth()
+	code, h = readyz()
+	if code != http.StatusOK || h.Status != HealthDegraded || h.Databases[1].OK || h.Databases[1].Error == "" {
+		t.Errorf("degraded readyz = %d %+v", code, h)
+	}
+	if h.Databases[1].LastWrite != an.LastWrite {
+		t.Errorf("last write = %v, want %v kept from the healthy check", h.Databases[1].LastWrite, an.LastWrite)
+	}
+	if msg := healthMessage(h); !strings.Contains(msg, "Analytics") {
+		t.Errorf("degraded message = %q", msg)
+	}
+
+	app.Store.Close()
+	app.checkHealth()
+	if code, h = readyz(); code != http.StatusServiceUnavailable || h.Status != HealthDown {
+		t.Errorf("down readyz = %d %+v", code, h)
+	}
+}
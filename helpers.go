@@ -4,7 +4,12 @@ import (
 	"encoding/json"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eringen/pubengine/markdown"
 )
 
 const maxSlugLength = 128
@@ -136,26 +141,198 @@ func WebsiteJsonLD(cfg SiteConfig) string {
 	return string(b)
 }
 
+// AbsoluteURL resolves ref, a site path or absolute URL, against base.
+func AbsoluteURL(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	return u.ResolveReference(r).String()
+}
+
+// SocialImage returns the image shown when the post is shared: OGImage when
+// set, otherwise the first image in the content, or "" if there is none.
+func (p BlogPost) SocialImage() string {
+	if p.OGImage != "" {
+		return p.OGImage
+	}
+	if p.contentImage != "" {
+		return p.contentImage
+	}
+	return firstImage(p.Content)
+}
+
+// firstImage returns the URL of the first image in Markdown content, or ""
+// if there is none.
+func firstImage(content string) string {
+	refs, _ := markdown.Refs(content)
+	for _, r := range refs {
+		if r.Image && markdown.SafeURL(r.URL) != "" {
+			return r.URL
+		}
+	}
+	return ""
+}
+
+// CanonicalLink returns the canonical URL of a post: its CanonicalURL for
+// cross-posts, otherwise its URL on this site.
+func (p BlogPost) CanonicalLink(cfg SiteConfig) string {
+	if p.CanonicalURL != "" {
+		return p.CanonicalURL
+	}
+	return BuildURL(cfg.URL, "blog", p.Slug)
+}
+
+// LastModified returns when the post last changed: UpdatedAt, or its date
+// for posts that haven't been saved by the store.
+func (p BlogPost) LastModified() string {
+	if p.UpdatedAt != "" {
+		return p.UpdatedAt
+	}
+	return p.Date
+}
+
+// UpdatedDate returns the "2006-01-02" day the post was last edited when
+// that is after its date, for "Updated on" labels, or "" otherwise.
+func (p BlogPost) UpdatedDate() string {
+	if len(p.UpdatedAt) < len("2006-01-02") {
+		return ""
+	}
+	if day := p.UpdatedAt[:len("2006-01-02")]; day > p.Date {
+		return day
+	}
+	return ""
+}
+
+// LastEdited returns when the post was last saved, as "2006-01-02 15:04"
+// in UTC, for admin lists, or "" for posts the store hasn't saved.
+func (p BlogPost) LastEdited() string {
+	t, err := time.Parse(time.RFC3339, p.UpdatedAt)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// TruncateText shortens s to at most n characters, cutting at a word
+// boundary and ending with "…" when it cuts. Runs of whitespace become
+// single spaces. Texts of n characters or less, and any text when n is
+// negative, are returned whole.
+func TruncateText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n == 0 {
+		return ""
+	}
+	runes := []rune(s)
+	cut := string(runes[:n-1])
+	// Drop a word cut in the middle, unless it is the only one.
+	if runes[n-1] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " ,;:.-–—") + "…"
+}
+
+// Excerpt returns the post's summary shortened to cfg.SummaryLength with
+// TruncateText, so feeds, meta descriptions and listings agree on length.
+// The summary is returned whole when SummaryLength is unset.
+func (p BlogPost) Excerpt(cfg SiteConfig) string {
+	n := cfg.SummaryLength
+	if n <= 0 {
+		n = -1
+	}
+	return TruncateText(p.Summary, n)
+}
+
+// readingWordsPerMinute is the reading speed ReadingMinutes assumes for prose.
+const readingWordsPerMinute = 200
+
+// codeLineWords is how many words of prose a line of code counts as. Code
+// is mostly skimmed, so a line counts less than its words would.
+const codeLineWords = 5
+
+// ReadingMinutes estimates how long the Markdown content takes to read, at
+// 200 words per minute. Fenced code blocks count by line rather than by
+// word. It rounds up, so any content takes at least a minute, and returns
+// 0 for empty content.
+func ReadingMinutes(content string) int {
+	words, inCode := 0, false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			if trimmed != "" {
+				words += codeLineWords
+			}
+			continue
+		}
+		words += len(strings.Fields(line))
+	}
+	return (words + readingWordsPerMinute - 1) / readingWordsPerMinute
+}
+
+// ReadingTime returns the post's estimated reading time for labels, e.g.
+// "6 min read", or "" when it has no content.
+func (p BlogPost) ReadingTime() string {
+	if p.ReadingMinutes == 0 {
+		return ""
+	}
+	return strconv.Itoa(p.ReadingMinutes) + " min read"
+}
+
+// OriginalSource returns the host a cross-posted post was first published
+// on, e.g. "dev.to", or "" for original posts.
+func (p BlogPost) OriginalSource() string {
+	if p.CanonicalURL == "" {
+		return ""
+	}
+	u, err := url.Parse(p.CanonicalURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
 // BlogPostingJsonLD returns a JSON-LD string for a BlogPosting schema.
 func BlogPostingJsonLD(post BlogPost, cfg SiteConfig) string {
 	postURL := BuildURL(cfg.URL, "blog", post.Slug)
+	canonical := post.CanonicalLink(cfg)
 	data := map[string]interface{}{
 		"@context":      "https://schema.org",
 		"@type":         "BlogPosting",
 		"headline":      post.Title,
-		"description":   post.Summary,
+		"description":   post.Excerpt(cfg),
 		"datePublished": post.Date,
+		"dateModified":  post.LastModified(),
 		"url":           postURL,
 		"mainEntityOfPage": map[string]string{
 			"@type": "WebPage",
-			"@id":   postURL,
+			"@id":   canonical,
 		},
 	}
-	if cfg.Author != "" {
-		data["author"] = map[string]string{
+	if name := post.Byline(cfg); name != "" {
+		author := map[string]string{
 			"@type": "Person",
-			"name":  cfg.Author,
+			"name":  name,
 		}
+		if post.AuthorSlug != "" {
+			author["url"] = BuildURL(cfg.URL, "author", post.AuthorSlug)
+		}
+		data["author"] = author
 	}
 	if cfg.Name != "" {
 		data["publisher"] = map[string]string{
@@ -166,6 +343,9 @@ func BlogPostingJsonLD(post BlogPost, cfg SiteConfig) string {
 	if len(post.Tags) > 0 {
 		data["keywords"] = strings.Join(post.Tags, ", ")
 	}
+	if img := post.SocialImage(); img != "" {
+		data["image"] = AbsoluteURL(cfg.URL, cfg.mediaURL(img))
+	}
 	b, err := json.Marshal(data)
 	if err != nil {
 		return "{}"
@@ -0,0 +1,161 @@
+package pubengine
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The kinds of home page sections.
+const (
+	HomeIntro    = "intro"    // a post shown in full, e.g. an about page
+	HomeFeatured = "featured" // the featured posts
+	HomeLatest   = "latest"   // the newest posts not shown in an earlier section
+	HomeTag      = "tag"      // the newest posts with a tag
+)
+
+// Default number of posts in latest and tag sections.
+const (
+	defaultHomeLatest = 10
+	defaultHomeTag    = 5
+)
+
+// HomeSection is a part of a home page composed from
+// SiteSettings.HomeLayout.
+type HomeSection struct {
+	Kind  string     // HomeIntro, HomeFeatured, HomeLatest or HomeTag
+	Tag   string     // the tag of a HomeTag section
+	Intro BlogPost   // the post of a HomeIntro section
+	Posts []BlogPost // the posts of the other sections, newest first
+}
+
+// homeSectionSpec is a line of a home layout.
+type homeSectionSpec struct {
+	kind  string
+	arg   string // the intro post's slug or the tag
+	limit int    // most posts shown, 0 for all
+}
+
+// parseHomeLayout parses a home layout: one section per line, in order,
+// out of
+//
+//	intro <slug>    the post with the slug in full, published or a draft
+//	featured        the featured posts
+//	latest [n]      the n newest posts not shown above (default 10)
+//	tag <tag> [n]   the n newest posts tagged tag (default 5)
+//
+// Blank lines are ignored. An empty layout keeps the plain list of posts.
+func parseHomeLayout(layout string) ([]homeSectionSpec, error) {
+	var specs []homeSectionSpec
+	for i, line := range strings.Split(layout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		spec := homeSectionSpec{kind: strings.ToLower(fields[0])}
+		args := fields[1:]
+		switch spec.kind {
+		case HomeIntro:
+			if len(args) != 1 || ValidateSlug(args[0]) != "" {
+				return nil, fmt.Errorf("line %d: intro needs the slug of a post", i+1)
+			}
+			spec.arg = args[0]
+		case HomeFeatured:
+			if len(args) != 0 {
+				return nil, fmt.Errorf("line %d: featured takes no arguments", i+1)
+			}
+		case HomeLatest:
+			spec.limit = defaultHomeLatest
+			if len(args) > 1 {
+				return nil, fmt.Errorf("line %d: latest takes at most a number of posts", i+1)
+			}
+		case HomeTag:
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("line %d: tag needs a tag and optionally a number of posts", i+1)
+			}
+			spec.arg = strings.ToLower(args[0])
+			spec.limit = defaultHomeTag
+			args = args[1:]
+		default:
+			return nil, fmt.Errorf("line %d: unknown section %q", i+1, fields[0])
+		}
+		if spec.kind == HomeLatest || spec.kind == HomeTag {
+			if len(args) == 1 {
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("line %d: %q is not a number of posts", i+1, args[0])
+				}
+				spec.limit = n
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// homeSections builds the sections of the home layout setting, or returns
+// nil when there is none. Intro posts that don't exist and sections
+// without posts are left out.
+func (a *App) homeSections() ([]HomeSection, error) {
+	layout, err := a.Store.GetSetting(settingHomeLayout)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := parseHomeLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	var sections []HomeSection
+	shown := make(map[string]bool)
+	for _, spec := range specs {
+		section := HomeSection{Kind: spec.kind}
+		var posts []BlogPost
+		switch spec.kind {
+		case HomeIntro:
+			intro, err := a.Content.GetPostAny(spec.arg)
+			if err == sql.ErrNoRows || err == nil && intro.TrashedAt != "" {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			section.Intro = intro
+			sections = append(sections, section)
+			continue
+		case HomeFeatured:
+			all, err := a.Cache.ListPosts("")
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range all {
+				if p.Featured {
+					posts = append(posts, p)
+				}
+			}
+		case HomeLatest:
+			all, err := a.Cache.ListPosts("")
+			if err != nil {
+				return nil, err
+			}
+			posts = slices.DeleteFunc(slices.Clone(all), func(p BlogPost) bool { return shown[p.Slug] })
+		case HomeTag:
+			section.Tag = spec.arg
+			if posts, err = a.Cache.ListPosts(spec.arg); err != nil {
+				return nil, err
+			}
+		}
+		if spec.limit > 0 && len(posts) > spec.limit {
+			posts = posts[:spec.limit]
+		}
+		if len(posts) == 0 {
+			continue
+		}
+		for _, p := range posts {
+			shown[p.Slug] = true
+		}
+		section.Posts = posts
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
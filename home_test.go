@@ -0,0 +1,68 @@
+package pubengine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestHomeSections(t *testing.T) {
+	app := newTestApp(t)
+	var got []HomeSection
+	app.Views.HomeSections = func(sections []HomeSection, _ []string, _ string) templ.Component {
+		got = sections
+		return templ.Raw("sections")
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "a", Title: "A", Date: "2024-01-01", Tags: []string{"go"}, Published: true},
+		BlogPost{Slug: "b", Title: "B", Date: "2024-01-02", Tags: []string{"go"}, Published: true},
+		BlogPost{Slug: "c", Title: "C", Date: "2024-01-03", Published: true},
+		BlogPost{Slug: "about", Title: "About", Date: "2024-01-01", Content: "Hello."},
+	)
+	if err := app.Store.SetPostFeatured("a", true); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) string {
+		rec := serveGet(app, path)
+		return rec.Body.String()
+	}
+
+	if body := get("/"); body != "home" {
+		t.Errorf("home without a layout = %q, want the Home view", body)
+	}
+	layout := "intro about\nfeatured\n\nlatest 2\ntag go 1\ntag missing\nintro gone"
+	if _, err := parseHomeLayout(layout); err != nil {
+		t.Fatalf("parseHomeLayout: %v", err)
+	}
+	if err := app.Store.SetSetting(settingHomeLayout, layout); err != nil {
+		t.Fatal(err)
+	}
+	if body := get("/"); body != "sections" {
+		t.Fatalf("home with a layout = %q, want the HomeSections view", body)
+	}
+	var summary []string
+	for _, sec := range got {
+		line := sec.Kind + " " + sec.Tag + sec.Intro.Slug + ":"
+		for _, p := range sec.Posts {
+			line += " " + p.Slug
+		}
+		summary = append(summary, line)
+	}
+	want := []string{"intro about:", "featured : a", "latest : c b", "tag go: b"}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("sections = %q, want %q", summary, want)
+	}
+	if body := get("/?tag=go"); body != "home" {
+		t.Errorf("tag page = %q, want the Home view", body)
+	}
+
+	for _, bad := range []string{"sidebar", "intro", "latest many", "tag", "featured 3", "latest 0"} {
+		if _, err := parseHomeLayout(bad); err == nil {
+			t.Errorf("parseHomeLayout(%q) = nil, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package pubengine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// langCookie remembers a language picked with the ?lang= query parameter.
+const langCookie = "lang"
+
+// Locale maps message IDs to the translated strings of one language.
+// Strings may contain fmt verbs filled from the arguments passed to T.
+type Locale map[string]string
+
+// translations holds the locales registered with WithTranslations.
+type translations struct {
+	defaultLang string
+	langs       []string // default language first, then sorted
+	locales     map[string]Locale
+}
+
+// WithTranslations enables translated views. Each request is served in the
+// language picked with ?lang= (remembered in a cookie), then the best
+// Accept-Language match, then defaultLang. Views look strings up with T.
+func WithTranslations(defaultLang string, locales map[string]Locale) Option {
+	return func(a *App) {
+		t := &translations{defaultLang: defaultLang, locales: locales}
+		for lang := range locales {
+			if lang != defaultLang {
+				t.langs = append(t.langs, lang)
+			}
+		}
+		sort.Strings(t.langs)
+		t.langs = append([]string{defaultLang}, t.langs...)
+		a.translations = t
+	}
+}
+
+func (t *translations) validate() error {
+	if _, ok := t.locales[t.defaultLang]; !ok {
+		return fmt.Errorf("pubengine: default language %q has no locale", t.defaultLang)
+	}
+	return nil
+}
+
+// localeKey is the request context key holding the request's localeContext.
+type localeKey struct{}
+
+type localeContext struct {
+	lang string
+	t    *translations
+}
+
+// localeMiddleware picks the request language and stores it in the request
+// context, where T, Lang and Languages read it.
+func (t *translations) localeMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if strings.HasPrefix(c.Request().URL.Path, "/public/") {
+			return next(c)
+		}
+		lang := ""
+		if q := c.QueryParam("lang"); q != "" {
+			if l := t.match(q); l != "" {
+				lang = l
+				c.SetCookie(&http.Cookie{
+					Name:     langCookie,
+					Value:    l,
+					Path:     "/",
+					MaxAge:   365 * 24 * 60 * 60,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+		}
+		if lang == "" {
+			if ck, err := c.Cookie(langCookie); err == nil {
+				lang = t.match(ck.Value)
+			}
+		}
+		if lang == "" {
+			lang = t.negotiate(c.Request().Header.Get("Accept-Language"))
+		}
+		// The language depends on the cookie and header, so shared caches
+		// must not serve one visitor's page to another.
+		c.Response().Header().Add("Vary", "Accept-Language, Cookie")
+
+		ctx := context.WithValue(c.Request().Context(), localeKey{}, &localeContext{lang: lang, t: t})
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// match returns the registered language for tag, trying the full tag and
+// then its primary subtag ("de-AT" matches "de"), or "" if none matches.
+func (t *translations) match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return ""
+	}
+	for _, l := range t.langs {
+		if strings.ToLower(l) == tag {
+			return l
+		}
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	for _, l := range t.langs {
+		if strings.ToLower(l) == base {
+			return l
+		}
+	}
+	return ""
+}
+
+// negotiate picks the best registered language for an Accept-Language
+// header, falling back to the default language.
+func (t *translations) negotiate(header string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			prefs = append(prefs, pref{tag, q})
+		}
+	}
+	slices.SortStableFunc(prefs, func(a, b pref) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, p := range prefs {
+		if l := t.match(p.tag); l != "" {
+			return l
+		}
+	}
+	return t.defaultLang
+}
+
+// T returns the message with the given ID in the request's language, falling
+// back to the default language and then to the ID itself. Arguments are
+// formatted into the message with fmt.Sprintf. Without WithTranslations
+// every lookup returns the ID.
+func T(ctx context.Context, id string, args ...any) string {
+	msg := id
+	if lc, ok := ctx.Value(localeKey{}).(*localeContext); ok {
+		if m, ok := lc.t.locales[lc.lang][id]; ok {
+			msg = m
+		} else if m, ok := lc.t.locales[lc.t.defaultLang][id]; ok {
+			msg = m
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Lang returns the request's language code, for the <html lang> attribute.
+// It is "en" when WithTranslations is not used.
+func Lang(ctx context.Context) string {
+	if lc, ok := ctx.Value(localeKey{}).(*localeContext); ok {
+		return lc.lang
+	}
+	return "en"
+}
+
+// Languages returns the registered language codes, default first, for
+// building a language switcher. It is nil when WithTranslations is not used.
+func Languages(ctx context.Context) []string {
+	if lc, ok := ctx.Value(localeKey{}).(*localeContext); ok {
+		return lc.t.langs
+	}
+	return nil
+}
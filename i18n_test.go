@@ -0,0 +1,113 @@
+package pubengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func testTranslations() *translations {
+	a := &App{}
+	WithTranslations("en", map[string]Locale{
+		"en":    {"nav.blog": "Blog", "posts.count": "%d posts"},
+		"de":    {"nav.blog": "Beiträge"},
+		"pt-BR": {"nav.blog": "Artigos"},
+	})(a)
+	return a.translations
+}
+
+func TestTranslationsNegotiate(t *testing.T) {
+	tr := testTranslations()
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de-AT,de;q=0.9,en;q=0.8", "de"},
+		{"fr;q=0.9,de;q=0.5", "de"},
+		{"en;q=0.3,de;q=0.7", "de"},
+		{"pt-br", "pt-BR"},
+		{"fr,*", "en"},
+		{"de;q=0", "en"},
+	}
+	for _, tt := range tests {
+		if got := tr.negotiate(tt.header); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTranslationsLanguages(t *testing.T) {
+	got := testTranslations().langs
+	want := []string{"en", "de", "pt-BR"}
+	if len(got) != len(want) {
+		t.Fatalf("langs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("langs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tr := testTranslations()
+	ctx := context.WithValue(context.Background(), localeKey{}, &localeContext{lang: "de", t: tr})
+	if got := T(ctx, "nav.blog"); got != "Beiträge" {
+		t.Errorf("T(nav.blog) = %q, want Beiträge", got)
+	}
+	if got := T(ctx, "posts.count", 3); got != "3 posts" {
+		t.Errorf("expected fallback to default language, got %q", got)
+	}
+	if got := T(ctx, "missing.id"); got != "missing.id" {
+		t.Errorf("expected the ID for a missing message, got %q", got)
+	}
+	if got := T(context.Background(), "nav.blog"); got != "nav.blog" {
+		t.Errorf("expected the ID without translations, got %q", got)
+	}
+	if got := Lang(context.Background()); got != "en" {
+		t.Errorf("Lang without translations = %q, want en", got)
+	}
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	tr := testTranslations()
+	e := echo.New()
+	h := tr.localeMiddleware(func(c echo.Context) error {
+		return c.String(http.StatusOK, Lang(c.Request().Context()))
+	})
+
+	serve := func(target string, cookie *http.Cookie, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if accept != "" {
+			req.Header.Set("Accept-Language", accept)
+		}
+		rec := httptest.NewRecorder()
+		if err := h(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	rec := serve("/?lang=de", nil, "en")
+	if rec.Body.String() != "de" {
+		t.Fatalf("?lang=de served %q", rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != langCookie || cookies[0].Value != "de" {
+		t.Fatalf("expected lang cookie to be set, got %v", cookies)
+	}
+
+	if got := serve("/", cookies[0], "en").Body.String(); got != "de" {
+		t.Fatalf("expected the cookie to win over Accept-Language, got %q", got)
+	}
+	if got := serve("/?lang=xx", nil, "pt-BR").Body.String(); got != "pt-BR" {
+		t.Fatalf("expected unknown ?lang to fall through to Accept-Language, got %q", got)
+	}
+}
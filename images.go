@@ -63,6 +63,78 @@ func processImage(src io.Reader, originalName string) (Image, []byte, error) {
 	}, buf.Bytes(), nil
 }
 
+// Content types accepted for upload, detected from the file content.
+const (
+	mimeJPEG = "image/jpeg"
+	mimePNG  = "image/png"
+	mimeGIF  = "image/gif"
+	mimeSVG  = "image/svg+xml"
+)
+
+// polyglotMarkers are byte sequences that don't belong in a raster image.
+// A file containing one can also be read as HTML, a script or an archive.
+var polyglotMarkers = []string{"<script", "<html", "<body", "<iframe", "<?php"}
+
+// sniffImageType returns the content type of data, detected from its bytes
+// rather than its name: JPEG, PNG, GIF, or SVG when allowSVG is set.
+// Anything else is an error.
+func sniffImageType(data []byte, allowSVG bool) (string, error) {
+	switch ct := http.DetectContentType(data); ct {
+	case mimeJPEG, mimePNG, mimeGIF:
+		return ct, nil
+	default:
+		if allowSVG && looksLikeSVG(data) {
+			return mimeSVG, nil
+		}
+		return "", fmt.Errorf("unsupported file type %s", ct)
+	}
+}
+
+// checkPolyglot rejects raster images that are also valid as another
+// format: an archive appended after the image, as in GIFAR files, or markup
+// and script tags hidden in metadata or trailing data.
+func checkPolyglot(data []byte) error {
+	tail := data[max(0, len(data)-(64<<10)-22):]
+	if bytes.Contains(tail, []byte("PK\x05\x06")) {
+		return fmt.Errorf("file contains a zip archive")
+	}
+	lower := bytes.ToLower(data)
+	for _, m := range polyglotMarkers {
+		if bytes.Contains(lower, []byte(m)) {
+			return fmt.Errorf("file contains %q", m)
+		}
+	}
+	return nil
+}
+
+// processUpload validates an uploaded file by its content and prepares it
+// for the media library. Raster images are checked for polyglots and
+// re-encoded by processImage. SVGs, accepted with allowSVG, are sanitized.
+func processUpload(data []byte, originalName string, allowSVG bool) (Image, []byte, error) {
+	ct, err := sniffImageType(data, allowSVG)
+	if err != nil {
+		return Image{}, nil, err
+	}
+	if ct != mimeSVG {
+		if err := checkPolyglot(data); err != nil {
+			return Image{}, nil, err
+		}
+		return processImage(bytes.NewReader(data), originalName)
+	}
+	clean, w, h, err := sanitizeSVG(data)
+	if err != nil {
+		return Image{}, nil, err
+	}
+	return Image{
+		Filename:     slugifyFilename(originalName) + ".svg",
+		OriginalName: originalName,
+		Width:        w,
+		Height:       h,
+		Size:         len(clean),
+		UploadedAt:   time.Now().UTC().Format(time.RFC3339),
+	}, clean, nil
+}
+
 // slugifyFilename converts a filename (without extension) to a URL-safe slug.
 func slugifyFilename(name string) string {
 	ext := filepath.Ext(name)
@@ -73,14 +145,15 @@ func slugifyFilename(name string) string {
 // ensureUniqueFilename appends a counter if filename already exists in the directory or database.
 func (a *App) ensureUniqueFilename(img *Image) {
 	dir := filepath.Join(a.staticDir, uploadsSubdir)
-	base := strings.TrimSuffix(img.Filename, ".jpg")
+	ext := filepath.Ext(img.Filename)
+	base := strings.TrimSuffix(img.Filename, ext)
 	candidate := img.Filename
 	counter := 1
 	for {
 		// Check filesystem
 		if _, err := os.Stat(filepath.Join(dir, candidate)); err == nil {
 			counter++
-			candidate = fmt.Sprintf("%s-%d.jpg", base, counter)
+			candidate = fmt.Sprintf("%s-%d%s", base, counter, ext)
 			continue
 		}
 		// Check database
@@ -94,7 +167,7 @@ func (a *App) ensureUniqueFilename(img *Image) {
 		}
 		if found {
 			counter++
-			candidate = fmt.Sprintf("%s-%d.jpg", base, counter)
+			candidate = fmt.Sprintf("%s-%d%s", base, counter, ext)
 			continue
 		}
 		break
@@ -109,6 +182,9 @@ func (a *App) handleImageUpload(c echo.Context) error {
 
 	file, err := c.FormFile("image")
 	if err != nil {
+		if isBodyTooLarge(err) {
+			return err
+		}
 		return c.String(http.StatusBadRequest, "No image file provided")
 	}
 	if file.Size > maxUploadSize {
@@ -121,7 +197,11 @@ func (a *App) handleImageUpload(c echo.Context) error {
 	}
 	defer src.Close()
 
-	img, data, err := processImage(src, file.Filename)
+	raw, err := io.ReadAll(io.LimitReader(src, maxUploadSize))
+	if err != nil {
+		return err
+	}
+	img, data, err := processUpload(raw, file.Filename, a.Config.AllowSVGUploads)
 	if err != nil {
 		return c.String(http.StatusBadRequest, "Invalid image: "+err.Error())
 	}
@@ -176,6 +256,26 @@ func (a *App) handleImageList(c echo.Context) error {
 	return a.renderImageList(c)
 }
 
+// handleImagePicker renders the media library as a picker that fills the
+// admin form input named by ?target=, such as the post's OG image.
+func (a *App) handleImagePicker(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminImagePicker == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	target := c.QueryParam("target")
+	if target == "" {
+		return c.String(http.StatusBadRequest, "Picker target required")
+	}
+	images, err := a.Store.ListImages()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminImagePicker(images, target))
+}
+
 func (a *App) renderImageList(c echo.Context) error {
 	images, err := a.Store.ListImages()
 	if err != nil {
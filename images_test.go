@@ -0,0 +1,72 @@
+package pubengine
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProcessUpload(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+	pngData := buf.Bytes()
+
+	img, data, err := processUpload(pngData, "Photo.gif", false)
+	if err != nil {
+		t.Fatalf("png upload: %v", err)
+	}
+	if img.Filename != "photo.jpg" || img.Width != 40 || img.Height != 20 || http.DetectContentType(data) != "image/jpeg" {
+		t.Errorf("png upload = %+v (%s)", img, http.DetectContentType(data))
+	}
+
+	if _, _, err := processUpload([]byte("<html><body>hi</body></html>"), "x.jpg", false); err == nil {
+		t.Error("expected HTML named .jpg to be rejected")
+	}
+	gifar := append(append([]byte{}, pngData...), []byte("PK\x03\x04payloadPK\x05\x06\x00\x00")...)
+	if _, _, err := processUpload(gifar, "x.png", false); err == nil || !strings.Contains(err.Error(), "zip") {
+		t.Errorf("expected image with appended zip to be rejected, got %v", err)
+	}
+	withScript := append(append([]byte{}, pngData...), []byte("<SCRIPT>alert(1)</script>")...)
+	if _, _, err := processUpload(withScript, "x.png", false); err == nil {
+		t.Error("expected image with a script tag to be rejected")
+	}
+
+	svg := []byte(`<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 120 60" onload="alert(1)">
+<script>alert(1)</script>
+<foreignObject><div xmlns="http://www.w3.org/1999/xhtml">x</div></foreignObject>
+<a xlink:href="javascript:alert(1)"><text x="1" y="2">A &amp; B</text></a>
+<use href="#shape"/><image href="https://evil.example/x.png"/>
+<set attributeName="href" to="javascript:alert(1)"/>
+</svg>`)
+	if _, _, err := processUpload(svg, "logo.svg", false); err == nil {
+		t.Error("expected SVG to be rejected without AllowSVGUploads")
+	}
+	img, data, err = processUpload(svg, "Logo.svg", true)
+	if err != nil {
+		t.Fatalf("svg upload: %v", err)
+	}
+	if img.Filename != "logo.svg" || img.Width != 120 || img.Height != 60 {
+		t.Errorf("svg upload = %+v", img)
+	}
+	got := string(data)
+	for _, bad := range []string{"onload", "<script", "alert", "foreignObject", "<div", "evil.example", "<set"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("sanitized svg contains %q:\n%s", bad, got)
+		}
+	}
+	for _, want := range []string{`xmlns:xlink="http://www.w3.org/1999/xlink"`, `<text x="1" y="2">A &amp; B</text>`, `<use href="#shape"></use>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sanitized svg missing %q:\n%s", want, got)
+		}
+	}
+
+	if _, _, err := processUpload([]byte(`<!DOCTYPE svg [<!ENTITY x "y">]><svg xmlns="http://www.w3.org/2000/svg">&x;</svg>`), "x.svg", true); err == nil {
+		t.Error("expected SVG with a DOCTYPE to be rejected")
+	}
+}
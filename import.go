@@ -0,0 +1,227 @@
+package pubengine
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Import size limits: the upload as a whole and each Markdown file in it,
+// which also bounds what a zip can expand to per file.
+const (
+	maxImportSize     = 32 << 20
+	maxImportFileSize = 4 << 20
+)
+
+// reDatedFileName matches the date Jekyll puts in front of post file names,
+// e.g. "2024-03-01-hello.md".
+var reDatedFileName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-`)
+
+// ImportResult reports what an import of Markdown files did.
+type ImportResult struct {
+	Imported []string          // slugs of the imported posts
+	Renamed  map[string]string // file path to the slug it was imported under, when its own was taken
+	Skipped  []string          // files left out, as "path: reason"
+}
+
+// Summary returns the result in one line, e.g. for the admin dashboard.
+func (r ImportResult) Summary() string {
+	parts := []string{fmt.Sprintf("Imported %d posts", len(r.Imported))}
+	names := make([]string, 0, len(r.Renamed))
+	for name := range r.Renamed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s imported as %s", name, r.Renamed[name]))
+	}
+	if len(r.Skipped) > 0 {
+		parts = append(parts, "skipped "+strings.Join(r.Skipped, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ImportMarkdown saves every .md and .markdown file in fsys, in any
+// directory, as a post, reading its YAML or TOML frontmatter with
+// ParseFrontmatter. Posts without a date take the one at the start of a
+// Jekyll-style file name, else today. A post whose slug is taken is saved
+// under the first free one of "<slug>-2", "<slug>-3" and so on, so
+// importing never overwrites. Files without frontmatter or a title, and
+// Hugo's _index.md section pages, are skipped and reported.
+func ImportMarkdown(cs ContentStore, fsys fs.FS) (ImportResult, error) {
+	var res ImportResult
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := path.Base(name)
+		if d.IsDir() {
+			// Skip .git and other hidden directories.
+			if name != "." && strings.HasPrefix(base, ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownFile(base) {
+			return nil
+		}
+		data, err := readImportFile(fsys, name)
+		if err != nil {
+			res.Skipped = append(res.Skipped, name+": "+err.Error())
+			return nil
+		}
+		return importMarkdownFile(cs, name, data, &res)
+	})
+	return res, err
+}
+
+// isMarkdownFile reports whether name is a Markdown file to import.
+func isMarkdownFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return (ext == ".md" || ext == ".markdown") && !strings.HasPrefix(name, ".")
+}
+
+// importMarkdownFile saves the Markdown file name as a post, recording the
+// outcome in res. Only failing to save is an error.
+func importMarkdownFile(cs ContentStore, name string, data []byte, res *ImportResult) error {
+	base := path.Base(name)
+	if base == "_index.md" {
+		res.Skipped = append(res.Skipped, name+": section page")
+		return nil
+	}
+	p, err := ParseFrontmatter(data)
+	if err != nil {
+		res.Skipped = append(res.Skipped, name+": "+err.Error())
+		return nil
+	}
+	if p.Date == "" {
+		if m := reDatedFileName.FindStringSubmatch(base); m != nil {
+			p.Date = m[1]
+		} else {
+			p.Date = time.Now().Format("2006-01-02")
+		}
+	}
+	if msg := ValidateSlug(p.Slug); msg != "" {
+		res.Skipped = append(res.Skipped, name+": "+msg)
+		return nil
+	}
+	slug, err := freeSlug(cs, p.Slug)
+	if err != nil {
+		return err
+	}
+	if slug != p.Slug {
+		if res.Renamed == nil {
+			res.Renamed = make(map[string]string)
+		}
+		res.Renamed[name] = slug
+		p.Slug = slug
+	}
+	if err := cs.SavePost(p); err != nil {
+		return fmt.Errorf("import %s: %w", name, err)
+	}
+	res.Imported = append(res.Imported, p.Slug)
+	return nil
+}
+
+// readImportFile reads the file name from fsys, refusing files over
+// maxImportFileSize.
+func readImportFile(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImportFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportFileSize {
+		return nil, fmt.Errorf("larger than %d MB", maxImportFileSize>>20)
+	}
+	return data, nil
+}
+
+// freeSlug returns slug, or the first of "<slug>-2", "<slug>-3" and so on
+// that no post uses.
+func freeSlug(cs ContentStore, slug string) (string, error) {
+	candidate := slug
+	for n := 2; ; n++ {
+		if _, err := cs.GetPostAny(candidate); err == ErrNotFound {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, n)
+	}
+}
+
+// ImportMarkdownDir imports the Markdown files in dir and its
+// subdirectories, e.g. the content directory of a Hugo or Jekyll site, see
+// ImportMarkdown.
+func (s *Store) ImportMarkdownDir(dir string) (ImportResult, error) {
+	return ImportMarkdown(s, os.DirFS(dir))
+}
+
+// handleAdminImport imports an uploaded zip of Markdown files, or a single
+// Markdown file, and shows what was imported on the dashboard.
+func (a *App) handleAdminImport(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		if isBodyTooLarge(err) {
+			return err
+		}
+		return c.String(http.StatusBadRequest, "No file provided")
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, maxImportSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxImportSize {
+		return echo.ErrStatusRequestEntityTooLarge
+	}
+
+	var res ImportResult
+	if strings.EqualFold(path.Ext(file.Filename), ".zip") {
+		zr, zerr := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if zerr != nil {
+			return c.String(http.StatusBadRequest, "Invalid zip file: "+zerr.Error())
+		}
+		res, err = ImportMarkdown(a.Content, zr)
+	} else {
+		name := path.Base(strings.ReplaceAll(file.Filename, `\`, "/"))
+		if !isMarkdownFile(name) {
+			return c.String(http.StatusBadRequest, "Upload a .zip, .md or .markdown file")
+		}
+		if len(data) > maxImportFileSize {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: larger than %d MB", name, maxImportFileSize>>20))
+		} else {
+			err = importMarkdownFile(a.Content, name, data, &res)
+		}
+	}
+	if len(res.Imported) > 0 {
+		a.Cache.Invalidate()
+	}
+	if err != nil {
+		return err
+	}
+	return a.renderAdminDashboard(c, res.Summary()+".")
+}
@@ -0,0 +1,216 @@
+package pubengine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Job queue tuning.
+const (
+	jobLease        = 5 * time.Minute  // how long a claimed job is locked before another worker may retry it
+	jobPollInterval = time.Second      // how often idle workers look for due jobs
+	jobMinBackoff   = 30 * time.Second // delay before the first retry, doubled on each attempt
+	jobMaxBackoff   = 6 * time.Hour
+)
+
+// JobHandler runs a job with its JSON payload. Returning an error retries
+// the job later. Handlers may run more than once for the same job, e.g.
+// after a crash, so they should be safe to repeat.
+type JobHandler func(payload []byte) error
+
+// WithJobHandler registers fn to run the jobs of kind queued with
+// App.Enqueue, e.g. sending a webmention or a newsletter.
+func WithJobHandler(kind string, fn JobHandler) Option {
+	return func(a *App) {
+		if a.jobHandlers == nil {
+			a.jobHandlers = make(map[string]JobHandler)
+		}
+		a.jobHandlers[kind] = fn
+	}
+}
+
+// Enqueue adds a job of kind to the persistent queue, with payload encoded
+// as JSON. A worker runs it with the handler registered for kind, retrying
+// with exponential backoff on failure, up to SiteConfig.JobMaxAttempts
+// times. Queued jobs survive restarts.
+func (a *App) Enqueue(kind string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s job: %w", kind, err)
+	}
+	if _, err := a.Store.EnqueueJob(kind, string(body), a.Config.JobMaxAttempts, time.Now()); err != nil {
+		return err
+	}
+	select {
+	case a.jobWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// jobBackoff returns the delay before retrying a job that failed attempts
+// times.
+func jobBackoff(attempts int) time.Duration {
+	d := jobMinBackoff
+	for i := 1; i < attempts && d < jobMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, jobMaxBackoff)
+}
+
+// runJob runs one claimed job and records the outcome. The job's lease is
+// renewed while it runs, so jobs that take longer than jobLease, like
+// backups to a slow bucket, aren't claimed twice.
+func (a *App) runJob(job Job) {
+	stop := a.keepJobLease(job.ID)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		fn, ok := a.jobHandlers[job.Kind]
+		if !ok {
+			return fmt.Errorf("no handler for job kind %q", job.Kind)
+		}
+		return fn([]byte(job.Payload))
+	}()
+	stop()
+	if err == nil {
+		err = a.Store.CompleteJob(job.ID)
+		if err == nil {
+			return
+		}
+	}
+	a.Echo.Logger.Errorf("job %d (%s) attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+	if err := a.Store.FailJob(job.ID, err.Error(), time.Now().Add(jobBackoff(job.Attempts))); err != nil {
+		a.Echo.Logger.Errorf("job %d (%s): record failure: %v", job.ID, job.Kind, err)
+	}
+}
+
+// keepJobLease renews the lease of job id every half jobLease until the
+// returned stop function is called.
+func (a *App) keepJobLease(id int64) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobLease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.Store.ExtendJobLease(id, time.Now().Add(jobLease)); err != nil {
+					a.Echo.Logger.Errorf("job %d: extend lease: %v", id, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// startJobWorkers runs n workers taking due jobs off the queue. Returns a
+// stop function that waits for running jobs to finish.
+func (a *App) startJobWorkers(n int) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(jobPollInterval)
+			defer ticker.Stop()
+			for {
+				job, err := a.Store.ClaimJob(time.Now(), jobLease)
+				if err == nil {
+					a.runJob(job)
+					continue
+				}
+				if err != sql.ErrNoRows {
+					a.Echo.Logger.Errorf("claim job: %v", err)
+				}
+				select {
+				case <-ticker.C:
+				case <-a.jobWake:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (a *App) handleJobList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderJobs(c)
+}
+
+// handleJobRetry puts a dead job back in the queue.
+func (a *App) handleJobRetry(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.RetryJob(id); err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	select {
+	case a.jobWake <- struct{}{}:
+	default:
+	}
+	return a.renderJobs(c)
+}
+
+func (a *App) handleJobDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.DeleteJob(id); err != nil {
+		return err
+	}
+	return a.renderJobs(c)
+}
+
+// renderJobs renders the dead jobs and the number of queued ones.
+func (a *App) renderJobs(c echo.Context) error {
+	if a.Views.AdminJobs == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	dead, err := a.Store.ListDeadJobs()
+	if err != nil {
+		return err
+	}
+	queued, _, err := a.Store.CountJobs()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminJobs(dead, queued, CsrfToken(c)))
+}
@@ -0,0 +1,63 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobQueue(t *testing.T) {
+	app := newTestApp(t)
+	ran := make(chan string, 1)
+	WithJobHandler("greet", func(payload []byte) error {
+		var name string
+		if err := json.Unmarshal(payload, &name); err != nil {
+			return err
+		}
+		ran <- name
+		return nil
+	})(app)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	if err := app.Enqueue("greet", "ada"); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case name := <-ran:
+		if name != "ada" {
+			t.Errorf("handler got %q", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not run")
+	}
+	// The handler returns before the worker removes its job.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if queued, _, err := app.Store.CountJobs(); err == nil && queued == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("queued jobs = %d, %v", queued, err)
+		}
+	}
+
+	if _, err := app.Store.EnqueueJob("unknown", "{}", 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	job, err := app.Store.ClaimJob(time.Now().Add(2*time.Hour), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.runJob(job)
+	dead, err := app.Store.ListDeadJobs()
+	if err != nil || len(dead) != 1 || !strings.Contains(dead[0].LastError, "no handler") {
+		t.Errorf("dead jobs = %+v, %v", dead, err)
+	}
+
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: 6 * time.Hour} {
+		if got := jobBackoff(attempts); got != want {
+			t.Errorf("jobBackoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -1,24 +1,67 @@
 package pubengine
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
 	"sync"
 	"time"
 )
 
+// maxRecentFailures is how many failed logins LoginLimiter.Stats reports.
+const maxRecentFailures = 50
+
 // LoginLimiter rate-limits login attempts per IP address.
 type LoginLimiter struct {
 	mu       sync.Mutex
 	attempts map[string][]time.Time
 	max      int
 	window   time.Duration
+	key      []byte         // per-process key for hashing IPs in Stats
+	failures []LoginFailure // most recent last, at most maxRecentFailures
+	total    int64          // failed attempts since start
+	rejected int64          // attempts refused while locked out since start
+}
+
+// LoginStats is a snapshot of a LoginLimiter, for the admin security page
+// and custom metrics. IP addresses are hashed with a key that changes on
+// every restart, so hashes can be compared within a snapshot but don't
+// identify anyone.
+type LoginStats struct {
+	Max            int
+	Window         time.Duration
+	Lockouts       int             // IPs currently locked out
+	Attempts       []LoginAttempts // IPs with failures in the window, most first
+	RecentFailures []LoginFailure  // newest first
+	TotalFailures  int64           // failed attempts since start
+	Rejected       int64           // attempts refused while locked out since start
+}
+
+// LoginAttempts are the failed logins of one IP within the limiter window.
+type LoginAttempts struct {
+	IPHash string
+	Count  int
+	Last   time.Time
+	Locked bool
+}
+
+// LoginFailure is a failed login attempt.
+type LoginFailure struct {
+	IPHash string
+	At     time.Time
 }
 
 // NewLoginLimiter creates a LoginLimiter that allows max attempts per window.
 func NewLoginLimiter(max int, window time.Duration) *LoginLimiter {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
 	l := &LoginLimiter{
 		attempts: make(map[string][]time.Time),
 		max:      max,
 		window:   window,
+		key:      key,
 	}
 	go l.cleanup()
 	return l
@@ -72,12 +115,72 @@ func (l *LoginLimiter) Check(ip string) bool {
 		}
 	}
 	l.attempts[ip] = kept
-	return len(kept) < l.max
+	if len(kept) >= l.max {
+		l.rejected++
+		return false
+	}
+	return true
 }
 
 // Record registers a failed login attempt for the given IP.
 func (l *LoginLimiter) Record(ip string) {
+	now := time.Now()
 	l.mu.Lock()
-	l.attempts[ip] = append(l.attempts[ip], time.Now())
+	l.attempts[ip] = append(l.attempts[ip], now)
+	l.total++
+	l.failures = append(l.failures, LoginFailure{IPHash: l.hashIP(ip), At: now})
+	if len(l.failures) > maxRecentFailures {
+		l.failures = l.failures[len(l.failures)-maxRecentFailures:]
+	}
 	l.mu.Unlock()
 }
+
+// Stats returns the current lockouts, failed attempts per IP hash and the
+// most recent failures.
+func (l *LoginLimiter) Stats() LoginStats {
+	cutoff := time.Now().Add(-l.window)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := LoginStats{
+		Max:           l.max,
+		Window:        l.window,
+		TotalFailures: l.total,
+		Rejected:      l.rejected,
+	}
+	for ip, hits := range l.attempts {
+		var n int
+		var last time.Time
+		for _, t := range hits {
+			if t.After(cutoff) {
+				n++
+				last = t
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		locked := n >= l.max
+		if locked {
+			stats.Lockouts++
+		}
+		stats.Attempts = append(stats.Attempts, LoginAttempts{IPHash: l.hashIP(ip), Count: n, Last: last, Locked: locked})
+	}
+	sort.Slice(stats.Attempts, func(i, j int) bool {
+		if stats.Attempts[i].Count != stats.Attempts[j].Count {
+			return stats.Attempts[i].Count > stats.Attempts[j].Count
+		}
+		return stats.Attempts[i].Last.After(stats.Attempts[j].Last)
+	})
+	for i := len(l.failures) - 1; i >= 0; i-- {
+		stats.RecentFailures = append(stats.RecentFailures, l.failures[i])
+	}
+	return stats
+}
+
+func (l *LoginLimiter) hashIP(ip string) string {
+	h := hmac.New(sha256.New, l.key)
+	h.Write([]byte(ip))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
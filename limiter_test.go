@@ -1,6 +1,7 @@
 package pubengine
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -50,3 +51,46 @@ func TestLoginLimiterIsPerIP(t *testing.T) {
 		t.Fatalf("expected first ip to be blocked after max")
 	}
 }
+
+func TestLoginLimiterStats(t *testing.T) {
+	limiter := NewLoginLimiter(2, time.Minute)
+
+	limiter.Record("203.0.113.40")
+	limiter.Record("203.0.113.40")
+	limiter.Record("203.0.113.41")
+	if limiter.Check("203.0.113.40") {
+		t.Fatalf("expected first ip to be locked out")
+	}
+
+	stats := limiter.Stats()
+	if stats.Max != 2 || stats.Window != time.Minute {
+		t.Errorf("limits = %d/%v", stats.Max, stats.Window)
+	}
+	if stats.Lockouts != 1 || stats.TotalFailures != 3 || stats.Rejected != 1 {
+		t.Errorf("lockouts = %d, failures = %d, rejected = %d", stats.Lockouts, stats.TotalFailures, stats.Rejected)
+	}
+	if len(stats.Attempts) != 2 {
+		t.Fatalf("attempts = %+v", stats.Attempts)
+	}
+	if a := stats.Attempts[0]; a.Count != 2 || !a.Locked {
+		t.Errorf("first attempts = %+v", a)
+	}
+	if a := stats.Attempts[1]; a.Count != 1 || a.Locked {
+		t.Errorf("second attempts = %+v", a)
+	}
+	if len(stats.RecentFailures) != 3 || stats.RecentFailures[0].IPHash != stats.Attempts[1].IPHash {
+		t.Errorf("recent failures = %+v", stats.RecentFailures)
+	}
+	for _, a := range stats.Attempts {
+		if strings.Contains(a.IPHash, "203.0.113") || len(a.IPHash) != 16 {
+			t.Errorf("ip hash = %q", a.IPHash)
+		}
+	}
+
+	for range maxRecentFailures {
+		limiter.Record("203.0.113.42")
+	}
+	if n := len(limiter.Stats().RecentFailures); n != maxRecentFailures {
+		t.Errorf("recent failures kept = %d", n)
+	}
+}
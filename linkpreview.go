@@ -0,0 +1,345 @@
+package pubengine
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eringen/pubengine/markdown"
+	"golang.org/x/net/html"
+)
+
+// linkPreviewJobKind is the job kind of link preview fetches.
+const linkPreviewJobKind = "link_preview"
+
+// How long fetched link previews are used before they are fetched again,
+// and how long a failed fetch waits before the next try.
+const (
+	linkPreviewMaxAge   = 30 * 24 * time.Hour
+	linkPreviewRetryAge = 24 * time.Hour
+)
+
+// Size limits of fetched pages and oEmbed responses. The tags previews are
+// made of are in the <head>, at the start of the page.
+const (
+	maxLinkPreviewPage   = 1 << 20
+	maxLinkPreviewOEmbed = 64 << 10
+)
+
+// linkPreviewClient fetches link previews from public addresses only. The
+// address is checked on every connection, so neither redirects nor oEmbed
+// URLs, which come from the fetched page, can reach the server's own
+// network, e.g. cloud metadata at 169.254.169.254.
+var linkPreviewClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: publicAddrOnly}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddrOnly is a net.Dialer Control function that refuses to connect
+// to loopback, private, link-local and other non-public addresses.
+func publicAddrOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// LinkPreview is the cached preview of a page linked with the
+// {{bookmark url}} shortcode, fetched on the server so visitors' browsers
+// make no requests to the page's site beyond its image.
+type LinkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	Image       string // absolute https URL, or empty
+	SiteName    string
+	Error       string // why the last fetch failed; the preview is not shown
+	FetchedAt   string // RFC3339 time of the last fetch
+}
+
+// stale reports whether the preview is due to be fetched again.
+func (p LinkPreview) stale(now time.Time) bool {
+	fetched, err := time.Parse(time.RFC3339, p.FetchedAt)
+	if err != nil {
+		return true
+	}
+	if p.Error != "" {
+		return now.Sub(fetched) > linkPreviewRetryAge
+	}
+	return now.Sub(fetched) > linkPreviewMaxAge
+}
+
+// linkPreviewKey returns the key of the preview of u. URLs are hashed so
+// long ones fit the key column of every database.
+func linkPreviewKey(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetLinkPreview returns the cached preview of the page at u, or
+// sql.ErrNoRows if it was never fetched.
+func (s *Store) GetLinkPreview(u string) (LinkPreview, error) {
+	var p LinkPreview
+	err := s.db.QueryRow(`SELECT url, title, description, image, site_name, error, fetched_at FROM link_previews WHERE url_hash = ?`, linkPreviewKey(u)).
+		Scan(&p.URL, &p.Title, &p.Description, &p.Image, &p.SiteName, &p.Error, &p.FetchedAt)
+	return p, err
+}
+
+// SaveLinkPreview inserts or replaces the cached preview of p.URL.
+// FetchedAt is set to now.
+func (s *Store) SaveLinkPreview(p LinkPreview) error {
+	_, err := s.db.Exec(`INSERT INTO link_previews (url_hash, url, title, description, image, site_name, error, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(url_hash) DO UPDATE SET url = excluded.url, title = excluded.title, description = excluded.description, image = excluded.image,
+    site_name = excluded.site_name, error = excluded.error, fetched_at = excluded.fetched_at`,
+		linkPreviewKey(p.URL), p.URL, p.Title, p.Description, p.Image, p.SiteName, p.Error, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// linkPreview looks up the preview of a bookmarked page for the markdown
+// renderer. Pages without a preview, or with a stale one, are queued to be
+// fetched, once until the fetch is done; a stale preview is shown
+// meanwhile.
+func (a *App) linkPreview(u string) (markdown.LinkPreview, bool) {
+	p, err := a.Store.GetLinkPreview(u)
+	if err != nil && err != sql.ErrNoRows {
+		return markdown.LinkPreview{}, false
+	}
+	if err == sql.ErrNoRows || p.stale(time.Now()) {
+		a.queueLinkPreview(u)
+	}
+	if err != nil || p.Error != "" {
+		return markdown.LinkPreview{}, false
+	}
+	return markdown.LinkPreview{Title: p.Title, Description: p.Description, Image: p.Image, SiteName: p.SiteName}, true
+}
+
+// queueLinkPreviews queues a fetch of the preview of each page bookmarked
+// in content that has none yet or a stale one.
+func (a *App) queueLinkPreviews(content string) {
+	now := time.Now()
+	for _, u := range markdown.BookmarkURLs(content) {
+		if p, err := a.Store.GetLinkPreview(u); err == sql.ErrNoRows || err == nil && p.stale(now) {
+			a.queueLinkPreview(u)
+		}
+	}
+}
+
+// queueLinkPreview queues a fetch of the preview of u unless one is queued.
+func (a *App) queueLinkPreview(u string) {
+	if _, queued := a.queuedPreviews.LoadOrStore(u, true); queued {
+		return
+	}
+	if err := a.Enqueue(linkPreviewJobKind, u); err != nil {
+		a.queuedPreviews.Delete(u)
+		a.Echo.Logger.Errorf("queue link preview: %v", err)
+	}
+}
+
+// runLinkPreview fetches and caches the preview of a bookmarked page, and
+// purges the published posts bookmarking it. A failed fetch is cached too,
+// with its error, and tried again after linkPreviewRetryAge.
+func (a *App) runLinkPreview(payload []byte) error {
+	var u string
+	if err := json.Unmarshal(payload, &u); err != nil {
+		return err
+	}
+	defer a.queuedPreviews.Delete(u)
+	if p, err := a.Store.GetLinkPreview(u); err == nil && !p.stale(time.Now()) {
+		return nil
+	}
+	p, err := fetchLinkPreview(context.Background(), u)
+	if err != nil {
+		p = LinkPreview{URL: u, Error: err.Error()}
+	}
+	if err := a.Store.SaveLinkPreview(p); err != nil {
+		return err
+	}
+	if p.Error == "" {
+		a.purgeBookmarking(u)
+	}
+	return nil
+}
+
+// purgeBookmarking purges the published posts with a {{bookmark}} of u,
+// so CDNs pick up the preview.
+func (a *App) purgeBookmarking(u string) {
+	if len(a.allPurgers()) == 0 {
+		return
+	}
+	posts, err := a.Content.ListPosts("")
+	if err != nil {
+		a.Echo.Logger.Errorf("link preview: %v", err)
+		return
+	}
+	var bookmarking []BlogPost
+	for _, p := range posts {
+		for _, b := range markdown.BookmarkURLs(p.Content) {
+			if b == u {
+				bookmarking = append(bookmarking, p)
+				break
+			}
+		}
+	}
+	if len(bookmarking) > 0 {
+		a.purge(bookmarking...)
+	}
+}
+
+// fetchLinkPreview fetches the page at u and builds its preview from the
+// page's oEmbed endpoint, if it has one, and its OpenGraph, Twitter card
+// and <title> tags. oEmbed's title, provider and thumbnail win; the
+// description comes from the page.
+func fetchLinkPreview(ctx context.Context, u string) (LinkPreview, error) {
+	p := LinkPreview{URL: u}
+	page, base, err := getLinkPreviewURL(ctx, u, "text/html", maxLinkPreviewPage)
+	if err != nil {
+		return p, err
+	}
+	sp := extractSharePreview(page)
+	p.Title = sp.Title
+	p.Description = sp.Description
+	p.Image = sp.Image
+	p.SiteName = sp.SiteName
+	if href := findOEmbedLink(page); href != "" {
+		if o, err := fetchOEmbed(ctx, resolveLinkPreviewURL(base, href)); err == nil {
+			p.Title = cmp.Or(o.Title, p.Title)
+			p.SiteName = cmp.Or(o.ProviderName, p.SiteName)
+			p.Image = cmp.Or(o.ThumbnailURL, p.Image)
+		}
+	}
+	if p.Title == "" {
+		return p, fmt.Errorf("no title")
+	}
+	p.Title = TruncateText(p.Title, maxShareTitle)
+	p.Description = TruncateText(p.Description, maxShareDescription)
+	p.SiteName = TruncateText(p.SiteName, maxShareTitle)
+	// Only https images get past the Content-Security-Policy's img-src.
+	p.Image = resolveLinkPreviewURL(base, p.Image)
+	if !strings.HasPrefix(p.Image, "https://") {
+		p.Image = ""
+	}
+	return p, nil
+}
+
+// oEmbed holds the fields of an oEmbed response a preview uses.
+type oEmbed struct {
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// fetchOEmbed fetches a JSON oEmbed response.
+func fetchOEmbed(ctx context.Context, u string) (oEmbed, error) {
+	var o oEmbed
+	body, _, err := getLinkPreviewURL(ctx, u, "application/json", maxLinkPreviewOEmbed)
+	if err != nil {
+		return o, err
+	}
+	return o, json.Unmarshal(body, &o)
+}
+
+// findOEmbedLink returns the href of a page's JSON oEmbed discovery link.
+func findOEmbedLink(page []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "body" {
+				return ""
+			}
+			if tok.Data != "link" {
+				continue
+			}
+			var rel, typ, href string
+			for _, a := range tok.Attr {
+				switch a.Key {
+				case "rel":
+					rel = a.Val
+				case "type":
+					typ = a.Val
+				case "href":
+					href = a.Val
+				}
+			}
+			if rel == "alternate" && typ == "application/json+oembed" {
+				return href
+			}
+		}
+	}
+}
+
+// getLinkPreviewURL fetches an http(s) URL whose content type contains
+// accept, reading at most limit bytes of it, and returns the body with the
+// URL it came from after redirects.
+func getLinkPreviewURL(ctx context.Context, u, accept string, limit int64) ([]byte, *url.URL, error) {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, nil, fmt.Errorf("not an http or https URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "pubengine link preview")
+	resp, err := linkPreviewClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("%s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, accept) {
+		return nil, nil, fmt.Errorf("content type %q, want %s", ct, accept)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
+
+// resolveLinkPreviewURL resolves ref, e.g. a relative og:image, against
+// the URL of the page it was found on.
+func resolveLinkPreviewURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	r, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(r).String()
+}
@@ -0,0 +1,119 @@
+package pubengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkPreviewPublicOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title>Internal</title>`))
+	}))
+	defer srv.Close()
+	_, err := fetchLinkPreview(context.Background(), srv.URL+"/")
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("fetching from %s = %v, want it refused", srv.URL, err)
+	}
+
+	for addr, public := range map[string]bool{
+		"93.184.216.34:443":    true,
+		"[2606:4700::1]:443":   true,
+		"127.0.0.1:80":         false,
+		"10.1.2.3:80":          false,
+		"192.168.0.1:80":       false,
+		"169.254.169.254:80":   false,
+		"100.64.0.1:80":        false,
+		"0.0.0.0:80":           false,
+		"[::1]:80":             false,
+		"[fe80::1]:80":         false,
+		"[fd00::1]:80":         false,
+		"[::ffff:10.0.0.1]:80": false,
+	} {
+		if err := publicAddrOnly("tcp", addr, nil); (err == nil) != public {
+			t.Errorf("publicAddrOnly(%s) = %v, want public %v", addr, err, public)
+		}
+	}
+}
+
+func TestLinkPreview(t *testing.T) {
+	// The test server is on 127.0.0.1, which the client refuses.
+	client := linkPreviewClient
+	linkPreviewClient = &http.Client{Timeout: 10 * time.Second}
+	defer func() { linkPreviewClient = client }()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<html><head><title>Fallback</title>
+<meta property="og:title" content="Page &amp; title">
+<meta property="og:description" content="What the page is about.">
+<meta property="og:image" content="/cover.png">
+<link rel="alternate" type="application/json+oembed" href="%s/oembed">
+</head><body></body></html>`, srv.URL)
+		case "/oembed":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title": "oEmbed title", "provider_name": "Example", "thumbnail_url": "https://img.example.com/t.jpg"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := fetchLinkPreview(context.Background(), srv.URL+"/article")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if p.Title != "oEmbed title" || p.Description != "What the page is about." || p.SiteName != "Example" || p.Image != "https://img.example.com/t.jpg" {
+		t.Errorf("preview = %+v", p)
+	}
+	if _, err := fetchLinkPreview(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("fetching a missing page succeeded")
+	}
+
+	app := newSetUpTestApp(t)
+	u := srv.URL + "/article"
+	if _, ok := app.linkPreview(u); ok {
+		t.Fatal("preview before fetching")
+	}
+	// The lookup queued a fetch.
+	for range 100 {
+		if p, err := app.Store.GetLinkPreview(u); err == nil && p.Title != "" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	lp, ok := app.linkPreview(u)
+	if !ok || lp.Title != "oEmbed title" {
+		t.Fatalf("preview = %+v, %v", lp, ok)
+	}
+	var buf bytes.Buffer
+	app.markdownOpts.Render(&buf, "{{bookmark "+u+"}}")
+	if !strings.Contains(buf.String(), `<span class="bookmark-title">oEmbed title</span>`) {
+		t.Errorf("bookmark = %q", buf.String())
+	}
+
+	// Failed fetches are cached with their error and not shown.
+	missing := srv.URL + "/missing"
+	app.queueLinkPreviews("{{bookmark " + missing + "}}")
+	for range 100 {
+		if _, err := app.Store.GetLinkPreview(missing); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if p, err := app.Store.GetLinkPreview(missing); err != nil || p.Error == "" {
+		t.Errorf("failed preview = %+v, %v", p, err)
+	}
+	if _, ok := app.linkPreview(missing); ok {
+		t.Error("failed preview shown")
+	}
+}
@@ -0,0 +1,144 @@
+package pubengine
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// PostLink is a link in the content of one post to another.
+type PostLink struct {
+	From string // slug of the linking post
+	To   string // slug of the linked post
+}
+
+// rePostLink matches the target of a Markdown link, reference-style link
+// definition or HTML href that points to a post with a site-relative URL,
+// capturing the slug.
+var rePostLink = regexp.MustCompile(`(?:\]\(\s*<?|^\s*\[[^\]]+\]:\s*<?|href=["'])/blog/([^/\s)>"'#?]+)`)
+
+// ParsePostLinks returns the slugs of the posts content links to with
+// site-relative URLs like /blog/some-post/, in order of first appearance.
+// Links inside fenced code blocks are ignored.
+func ParsePostLinks(content string) []string {
+	var slugs []string
+	seen := make(map[string]bool)
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		for _, m := range rePostLink.FindAllStringSubmatch(line, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				slugs = append(slugs, m[1])
+			}
+		}
+	}
+	return slugs
+}
+
+// savePostLinks replaces the links from the post slug with the posts its
+// content links to. Links to a renamed post's old slug are stored under
+// the slug it redirects to.
+func (s *Store) savePostLinks(slug, content string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM post_links WHERE from_slug = ?`, slug); err != nil {
+		return err
+	}
+	for _, to := range ParsePostLinks(content) {
+		var target string
+		err := tx.QueryRow(`SELECT to_slug FROM redirects WHERE from_slug = ?`, to).Scan(&target)
+		if err == nil {
+			to = target
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		if to == slug {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO post_links (from_slug, to_slug) VALUES (?, ?) ON CONFLICT DO NOTHING`, slug, to); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillPostLinks records the links of posts saved before links were
+// tracked. It does nothing once any link is recorded.
+func (s *Store) backfillPostLinks() error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM post_links`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	rows, err := s.db.Query(`SELECT slug, content FROM posts`)
+	if err != nil {
+		return err
+	}
+	contents := make(map[string]string)
+	for rows.Next() {
+		var slug, content string
+		if err := rows.Scan(&slug, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[slug] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for slug, content := range contents {
+		if err := s.savePostLinks(slug, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPostLinks returns every link between posts.
+func (s *Store) ListPostLinks() ([]PostLink, error) {
+	rows, err := s.db.Query(`SELECT from_slug, to_slug FROM post_links ORDER BY from_slug, to_slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []PostLink
+	for rows.Next() {
+		var l PostLink
+		if err := rows.Scan(&l.From, &l.To); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// ListBacklinks returns the posts outside the trash, drafts included, that
+// link to the post slug, newest first.
+func (s *Store) ListBacklinks(slug string) ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT `+s.postColumns+` FROM posts WHERE trashed_at = '' AND slug IN (SELECT from_slug FROM post_links WHERE to_slug = ?) ORDER BY date DESC`, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,92 @@
+package pubengine
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxLinkSuggestions is how many posts SuggestLinks returns at most.
+const maxLinkSuggestions = 8
+
+// LinkSuggestion is a published post a draft could link to.
+type LinkSuggestion struct {
+	Post    BlogPost
+	Reasons []string // e.g. "title", "tag go", "words concurrency, channels"
+	score   int
+}
+
+// SuggestLinks returns the posts in posts that content mentions and doesn't
+// link to yet, best first: posts whose title appears in the content, posts
+// with tags that appear in it as words, and posts sharing at least two
+// words of four or more letters of their title with it. The post slug, the
+// one being edited, is left out. Words are compared in lowercase, ignoring
+// punctuation.
+func SuggestLinks(content, slug string, posts []BlogPost) []LinkSuggestion {
+	text := " " + normalizeWords(content) + " "
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(text) {
+		words[w] = true
+	}
+	linked := ParsePostLinks(content)
+
+	var suggestions []LinkSuggestion
+	for _, p := range posts {
+		if p.Slug == slug || slices.Contains(linked, p.Slug) {
+			continue
+		}
+		var s LinkSuggestion
+		if title := normalizeWords(p.Title); title != "" && strings.Contains(text, " "+title+" ") {
+			s.Reasons = append(s.Reasons, "title")
+			s.score += 10
+		}
+		for _, t := range p.Tags {
+			if t = normalizeWords(t); t != "" && strings.Contains(text, " "+t+" ") {
+				s.Reasons = append(s.Reasons, "tag "+t)
+				s.score += 3
+			}
+		}
+		if len(s.Reasons) == 0 {
+			var shared []string
+			for _, w := range strings.Fields(normalizeWords(p.Title)) {
+				if utf8.RuneCountInString(w) >= 4 && words[w] && !slices.Contains(shared, w) {
+					shared = append(shared, w)
+				}
+			}
+			if len(shared) >= 2 {
+				s.Reasons = append(s.Reasons, "words "+strings.Join(shared, ", "))
+				s.score += len(shared)
+			}
+		}
+		if s.score > 0 {
+			s.Post = p
+			suggestions = append(suggestions, s)
+		}
+	}
+	// posts are newest first, so newer posts win ties.
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].score > suggestions[j].score })
+	if len(suggestions) > maxLinkSuggestions {
+		suggestions = suggestions[:maxLinkSuggestions]
+	}
+	return suggestions
+}
+
+// handleLinkSuggestions suggests published posts to link to from the
+// content of the post form, posted as content along with the post's slug.
+func (a *App) handleLinkSuggestions(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminSuggestLinks == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	posts, err := a.Cache.ListPosts("")
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminSuggestLinks(SuggestLinks(c.FormValue("content"), c.FormValue("slug"), posts)))
+}
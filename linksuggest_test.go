@@ -0,0 +1,29 @@
+package pubengine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggestLinks(t *testing.T) {
+	posts := []BlogPost{
+		{Slug: "channels", Title: "Go Channels", Tags: []string{"concurrency"}},
+		{Slug: "generics", Title: "Generics in practice", Tags: []string{"go"}},
+		{Slug: "garden", Title: "Tomato season", Tags: []string{"gardening"}},
+		{Slug: "draft-post", Title: "Concurrency patterns explained"},
+		{Slug: "linked", Title: "Linked already", Tags: []string{"concurrency"}},
+	}
+	content := "Pipelines build on go channels. See [linked](/blog/linked/) on concurrency.\n\n" +
+		"The patterns, once explained, make generics less scary."
+	var got []string
+	for _, s := range SuggestLinks(content, "draft-post", posts) {
+		got = append(got, s.Post.Slug+": "+strings.Join(s.Reasons, "; "))
+	}
+	want := []string{"channels: title; tag concurrency", "generics: tag go"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if s := SuggestLinks("Some patterns are better explained with concurrency in mind.", "", posts[3:4]); len(s) != 1 || s[0].Reasons[0] != "words concurrency, patterns, explained" {
+		t.Errorf("title words: got %+v", s)
+	}
+}
@@ -0,0 +1,89 @@
+package pubengine
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleRobots serves robots.txt from the static dir, or a default that
+// allows everything, followed by a group disallowing each AI crawler
+// blocked in the admin settings.
+func (a *App) handleRobots(c echo.Context) error {
+	var b strings.Builder
+	data, err := os.ReadFile(filepath.Join(a.staticDir, "robots.txt"))
+	switch {
+	case err == nil:
+		b.Write(data)
+		if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+			b.WriteByte('\n')
+		}
+	case os.IsNotExist(err):
+		fmt.Fprintf(&b, "User-agent: *\nAllow: /\n\nSitemap: %s\n", AbsoluteURL(BuildURL(a.Config.URL), "sitemap.xml"))
+	default:
+		return err
+	}
+	settings, err := a.siteSettings()
+	if err != nil {
+		return err
+	}
+	if blocked := settings.BlockedCrawlers(); len(blocked) > 0 {
+		b.WriteString("\n# AI crawlers, managed in the admin settings\n")
+		for _, name := range blocked {
+			fmt.Fprintf(&b, "User-agent: %s\n", name)
+		}
+		b.WriteString("Disallow: /\n")
+	}
+	return c.String(http.StatusOK, b.String())
+}
+
+// handleLLMs serves /llms.txt: the site name and description, the
+// preferred citation from the admin settings, and the published posts
+// except NoIndex ones. Posts link to their Markdown source when
+// ServeMarkdown is enabled.
+func (a *App) handleLLMs(c echo.Context) error {
+	posts, err := a.Cache.ListPosts("")
+	if err != nil {
+		return err
+	}
+	settings, err := a.siteSettings()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", a.Config.Name)
+	if a.Config.Description != "" {
+		fmt.Fprintf(&b, "\n> %s\n", a.Config.Description)
+	}
+	if a.Config.Author != "" {
+		fmt.Fprintf(&b, "\nWritten by %s. Site: %s\n", a.Config.Author, BuildURL(a.Config.URL))
+	}
+	if settings.Citation != "" {
+		fmt.Fprintf(&b, "\nCitation: %s\n", settings.Citation)
+	}
+	b.WriteString("\n## Posts\n\n")
+	for _, p := range posts {
+		if p.NoIndex {
+			continue
+		}
+		link := BuildURL(a.Config.URL, "blog", p.Slug)
+		if a.Config.ServeMarkdown {
+			link = MarkdownURL(a.Config.URL, p.Slug)
+		}
+		title := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(p.Title)
+		fmt.Fprintf(&b, "- [%s](%s)", title, link)
+		if excerpt := p.Excerpt(a.Config); excerpt != "" {
+			fmt.Fprintf(&b, ": %s", excerpt)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("\n## Optional\n\n")
+	fmt.Fprintf(&b, "- [RSS feed](%s)\n", AbsoluteURL(BuildURL(a.Config.URL), "feed.xml"))
+	fmt.Fprintf(&b, "- [Sitemap](%s)\n", AbsoluteURL(BuildURL(a.Config.URL), "sitemap.xml"))
+	return c.String(http.StatusOK, b.String())
+}
@@ -0,0 +1,55 @@
+package pubengine
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLLMsAndRobots(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.Description = "Notes on Go"
+	app.Config.ServeMarkdown = true
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello [1]", Date: "2024-01-01", Summary: "First post", Published: true})
+	savePosts(t, app, BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-02"})
+
+	get := func(path string) string {
+		rec := serveGet(app, path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	robots := get("/robots.txt")
+	if !strings.HasPrefix(robots, "User-agent: *\nAllow: /\n") || strings.Contains(robots, "GPTBot") {
+		t.Errorf("default robots.txt = %q", robots)
+	}
+
+	if err := app.Store.SetSetting(settingBlockedCrawlers, "GPTBot,CCBot,NotABot"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Store.SetSetting(settingCitation, "Cite as Blog, 2024."); err != nil {
+		t.Fatal(err)
+	}
+	robots = get("/robots.txt")
+	if !strings.HasSuffix(robots, "User-agent: GPTBot\nUser-agent: CCBot\nDisallow: /\n") || strings.Contains(robots, "NotABot") {
+		t.Errorf("robots.txt with blocked crawlers = %q", robots)
+	}
+
+	llms := get("/llms.txt")
+	for _, want := range []string{
+		"# Blog\n\n> Notes on Go\n",
+		"Citation: Cite as Blog, 2024.\n",
+		"- [Hello \\[1\\]](http://localhost:3000/blog/hello/index.md): First post\n",
+	} {
+		if !strings.Contains(llms, want) {
+			t.Errorf("missing %q in llms.txt:\n%s", want, llms)
+		}
+	}
+	if strings.Contains(llms, "Draft") {
+		t.Errorf("llms.txt lists a draft:\n%s", llms)
+	}
+}
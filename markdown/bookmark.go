@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// reBookmark matches a {{bookmark https://example.com/page}} line.
+var reBookmark = regexp.MustCompile(`^\{\{bookmark (https?://[^\s{}]+)\}\}\s*$`)
+
+// LinkPreview is what a {{bookmark}} card shows of the linked page.
+type LinkPreview struct {
+	Title       string
+	Description string
+	Image       string // absolute https URL, or empty
+	SiteName    string // e.g. "GitHub"; the host name is shown when empty
+}
+
+// BookmarkURLs returns the URLs of the {{bookmark}} shortcodes in md, in
+// order and without repeats. Shortcodes in code blocks are left out.
+func BookmarkURLs(md string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	inCode := false
+	for _, line := range strings.Split(md, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if m := reBookmark.FindStringSubmatch(line); m != nil && !inCode && !seen[m[1]] {
+			seen[m[1]] = true
+			urls = append(urls, m[1])
+		}
+	}
+	return urls
+}
+
+// renderBookmark renders the {{bookmark}} shortcode for the raw URL u: a
+// card with the page's title, description and image, or a paragraph with a
+// link when there is no preview.
+func (o Options) renderBookmark(u string) string {
+	href := SafeURL(u)
+	if href == "" {
+		return "<p>" + html.EscapeString(u) + "</p>"
+	}
+	var p LinkPreview
+	ok := false
+	if o.LinkPreviews != nil {
+		p, ok = o.LinkPreviews(u)
+	}
+	if !ok || p.Title == "" {
+		return `<p><a href="` + href + `" class="underline decoration-2 underline-offset-4">` + html.EscapeString(u) + `</a></p>`
+	}
+	site := p.SiteName
+	if site == "" {
+		if parsed, err := url.Parse(u); err == nil {
+			site = strings.TrimPrefix(parsed.Hostname(), "www.")
+		}
+	}
+	var b strings.Builder
+	b.WriteString(`<a href="` + href + `" class="bookmark" rel="noopener noreferrer">`)
+	b.WriteString(`<span class="bookmark-text">`)
+	b.WriteString(`<span class="bookmark-title">` + html.EscapeString(p.Title) + `</span>`)
+	if p.Description != "" {
+		b.WriteString(`<span class="bookmark-description">` + html.EscapeString(p.Description) + `</span>`)
+	}
+	b.WriteString(`<span class="bookmark-site">` + html.EscapeString(site) + `</span>`)
+	b.WriteString(`</span>`)
+	if img := SafeURL(p.Image); img != "" && strings.HasPrefix(p.Image, "https://") {
+		b.WriteString(`<img src="` + img + `" alt="" class="bookmark-image" loading="lazy" decoding="async">`)
+	}
+	b.WriteString(`</a>`)
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ImageOptions controls the loading attributes of rendered images. Images
+// can override them individually in the {style|...} suffix.
+type ImageOptions struct {
+	Loading  string // loading attribute for images after the first: "lazy" or "eager"
+	Decoding string // decoding attribute: "async", "sync" or "auto"
+	Sizes    string // sizes attribute, omitted when empty
+}
+
+// DefaultImageOptions lazy loads every image but the first, which gets
+// fetchpriority="high" instead since it is usually the LCP element.
+var DefaultImageOptions = ImageOptions{Loading: "lazy", Decoding: "async"}
+
+// Validate reports an error if Loading or Decoding is set to a value the
+// attribute doesn't allow.
+func (o ImageOptions) Validate() error {
+	o = o.withDefaults()
+	if !validLoading(o.Loading) {
+		return fmt.Errorf("markdown: invalid image loading %q (want lazy or eager)", o.Loading)
+	}
+	if !validDecoding(o.Decoding) {
+		return fmt.Errorf("markdown: invalid image decoding %q (want async, sync or auto)", o.Decoding)
+	}
+	return nil
+}
+
+// withDefaults returns o with empty fields set from DefaultImageOptions.
+func (o ImageOptions) withDefaults() ImageOptions {
+	if o.Loading == "" {
+		o.Loading = DefaultImageOptions.Loading
+	}
+	if o.Decoding == "" {
+		o.Decoding = DefaultImageOptions.Decoding
+	}
+	return o
+}
+
+func validLoading(s string) bool {
+	return s == "lazy" || s == "eager"
+}
+
+func validDecoding(s string) bool {
+	return s == "async" || s == "sync" || s == "auto"
+}
+
+// imageAttrs are the attributes parsed from an image's {...} suffix.
+type imageAttrs struct {
+	style    string
+	width    string
+	height   string
+	loading  string // per-image override, empty for the default
+	decoding string
+	sizes    string
+}
+
+// parseImageAttrs parses the inside of an image's braces:
+//
+//	style|width|height|lazy|decoding=sync|sizes=(max-width: 600px) 100vw
+//
+// Everything after the style is optional and, apart from width and height
+// (the first two numeric fields), may appear in any order. "lazy" and
+// "eager" are shorthands for loading=lazy and loading=eager.
+func parseImageAttrs(s string) imageAttrs {
+	fields := strings.Split(s, "|")
+	a := imageAttrs{style: fields[0], width: "1024", height: "768"}
+	var dims []string
+	for _, f := range fields[1:] {
+		f = strings.TrimSpace(f)
+		key, val, hasVal := strings.Cut(f, "=")
+		switch {
+		case f != "" && isDigits(f):
+			dims = append(dims, f)
+		case validLoading(f):
+			a.loading = f
+		case hasVal && key == "loading" && validLoading(val):
+			a.loading = val
+		case hasVal && key == "decoding" && validDecoding(val):
+			a.decoding = val
+		case hasVal && key == "sizes":
+			a.sizes = strings.TrimSpace(val)
+		}
+	}
+	if len(dims) >= 2 {
+		a.width, a.height = dims[0], dims[1]
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteMedia applies o.MediaURL to an HTML escaped URL.
+func (o Options) rewriteMedia(u string) string {
+	if o.MediaURL == nil || !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") {
+		return u
+	}
+	return html.EscapeString(o.MediaURL(html.UnescapeString(u)))
+}
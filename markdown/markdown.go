@@ -22,39 +22,97 @@ var (
 	reInlineCode       = regexp.MustCompile("`([^`]+)`")
 	reLink             = regexp.MustCompile(`\[(.*?)\]\((.*?)\)(\^)?`)
 	reOrderedList = regexp.MustCompile(`^(\d+)\.\s`)
-	// ![alt](url){style} or ![alt](url){style|width|height}
-	reImg = regexp.MustCompile(`\!\[(.*?)\]\((.*?)\)\{([^|}]*?)(?:\|(\d+)\|(\d+))?\}`)
+	// reAutolink matches bare http(s) URLs and email addresses in escaped
+	// text. Entities other than &amp; end a URL, so quotes stay outside.
+	reAutolink = regexp.MustCompile(`https?://(?:[^\s<&\x00]|&amp;)+|[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// ![alt](url){style|width|height|...}
+	reImg = regexp.MustCompile(`\!\[(.*?)\]\((.*?)\)\{([^}]*)\}`)
+	// {{file name.pdf Optional label}}, {{audio name.mp3}} and
+	// {{video name.mp4 1280x720 metadata}}. {{bookmark url}} is a line of
+	// its own, see reBookmark.
+	reShortcode = regexp.MustCompile(`\{\{(file|audio|video) ([A-Za-z0-9][A-Za-z0-9._-]*)(?: ([^}]+))?\}\}`)
+	reVideoSize = regexp.MustCompile(`^[0-9]{1,5}x[0-9]{1,5}$`)
 )
 
-// Markdown returns a templ.Component that renders md as HTML.
+// AttachmentsPath is the site path attachments are served under, used by
+// the {{file}}, {{audio}} and {{video}} shortcodes. A video's poster frame
+// is served at its path plus "/poster.jpg".
+const AttachmentsPath = "/files/"
+
+// Options are the site's rendering settings. The zero value renders with
+// DefaultImageOptions, leaves media URLs alone, skips the typography pass
+// and renders {{bookmark}} shortcodes as plain links.
+type Options struct {
+	Images ImageOptions // image loading policy; empty fields use DefaultImageOptions
+
+	// MediaURL rewrites image sources and attachment shortcode URLs that
+	// are site paths, such as "/public/uploads/x.jpg", for example to
+	// serve them from a CDN. Absolute URLs are left alone.
+	MediaURL func(path string) string
+
+	// Typography turns straight quotes into curly ones, "--" and "---"
+	// between words or spaces into em dashes, and the space between a number
+	// and a unit such as "10 km" or "5 %" into a non-breaking one. Code, URLs
+	// and attributes are left alone.
+	Typography bool
+
+	// LinkPreviews looks up the preview of a page linked with the
+	// {{bookmark url}} shortcode, reporting whether there is one. Without a
+	// preview the shortcode renders as a plain link. Rendering must not wait
+	// on the network, so it should only read previews fetched beforehand.
+	LinkPreviews func(url string) (LinkPreview, bool)
+}
+
+type optionsKey struct{}
+
+// WithOptions returns a copy of ctx that makes Markdown components
+// rendered with it use o.
+func WithOptions(ctx context.Context, o Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, o)
+}
+
+// Markdown returns a templ.Component that renders md as HTML, with the
+// Options of the render context set by WithOptions.
 func Markdown(content string) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		o, _ := ctx.Value(optionsKey{}).(Options)
 		var buf bytes.Buffer
-		RenderMarkdown(&buf, content)
+		o.Render(&buf, content)
 		_, err := w.Write(buf.Bytes())
 		return err
 	})
 }
 
-// RenderMarkdown writes the HTML representation of md to buf.
+// RenderMarkdown writes the HTML representation of md to buf, rendered
+// with the zero Options.
 func RenderMarkdown(buf *bytes.Buffer, md string) {
+	Options{}.Render(buf, md)
+}
+
+// Render writes the HTML representation of md to buf.
+func (o Options) Render(buf *bytes.Buffer, md string) {
 	imageCount := 0
 	lines := strings.Split(md, "\n")
 	inList := false
 	inOrderedList := false
 	inPara := false
 	inQuote := false
+	inQuotePara := false
+	inDefList := false
 	inCode := false
-	codeLang := false // whether the current code block has a language badge
+	codeWrapped := false // whether the current code block is inside a wrapper div
+	codeCount := 0
+	var fence codeFence // options of the current code block
+	codeLine := 0
 	inTable := false
 	tableHeaderDone := false
 
 	flushCode := func() {
 		if inCode {
 			buf.WriteString("</code></pre>")
-			if codeLang {
+			if codeWrapped {
 				buf.WriteString("</div>")
-				codeLang = false
+				codeWrapped = false
 			}
 			inCode = false
 			inPara = false
@@ -66,12 +124,25 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 			inPara = false
 		}
 	}
+	flushQuotePara := func() {
+		if inQuotePara {
+			buf.WriteString("</p>")
+			inQuotePara = false
+		}
+	}
 	flushQuote := func() {
 		if inQuote {
+			flushQuotePara()
 			buf.WriteString("</blockquote>")
 			inQuote = false
 		}
 	}
+	flushDefList := func() {
+		if inDefList {
+			buf.WriteString("</dl>")
+			inDefList = false
+		}
+	}
 	flushList := func() {
 		if inList {
 			buf.WriteString("</ul>")
@@ -95,7 +166,7 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 		}
 	}
 
-	for _, raw := range lines {
+	for i, raw := range lines {
 		line := strings.TrimRight(raw, "\r")
 		if strings.HasPrefix(line, "```") {
 			if inCode {
@@ -105,14 +176,24 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 				flushList()
 				flushOrderedList()
 				flushQuote()
-				lang := strings.TrimSpace(line[3:])
-				if lang != "" {
-					codeLang = true
-					escapedLang := html.EscapeString(lang)
-					buf.WriteString("<div class=\"code-block-wrapper\"><span class=\"code-lang code-lang-" + escapedLang + "\">" + escapedLang + "</span>")
-					buf.WriteString("<pre class=\"code-block\"><code class=\"language-" + escapedLang + "\">")
+				flushDefList()
+				fence = parseCodeFence(line[3:])
+				codeLine = 0
+				codeCount++
+				id := "code-" + strconv.Itoa(codeCount)
+				if fence.lang != "" || fence.title != "" {
+					codeWrapped = true
+					buf.WriteString("<div class=\"code-block-wrapper\">")
+				}
+				if fence.title != "" {
+					buf.WriteString("<div class=\"code-block-header\"><span class=\"code-title\">" + html.EscapeString(fence.title) + "</span></div>")
+				}
+				if fence.lang != "" {
+					escapedLang := html.EscapeString(fence.lang)
+					buf.WriteString("<span class=\"code-lang code-lang-" + escapedLang + "\">" + escapedLang + "</span>")
+					buf.WriteString("<pre class=\"code-block\" id=\"" + id + "\"><code class=\"language-" + escapedLang + "\">")
 				} else {
-					buf.WriteString("<pre class=\"code-block\"><code>")
+					buf.WriteString("<pre class=\"code-block\" id=\"" + id + "\"><code>")
 				}
 				inCode = true
 				inPara = true
@@ -121,6 +202,21 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 		}
 
 		if inCode {
+			if fence.lineNumbers || len(fence.highlight) > 0 {
+				codeLine++
+				n := strconv.Itoa(codeLine)
+				buf.WriteString("<span class=\"line")
+				if fence.highlighted(codeLine) {
+					buf.WriteString(" highlighted")
+				}
+				buf.WriteString("\" data-line=\"" + n + "\">")
+				if fence.lineNumbers {
+					buf.WriteString("<span class=\"line-number\" aria-hidden=\"true\">" + n + "</span>")
+				}
+				buf.WriteString(html.EscapeString(line))
+				buf.WriteString("</span>\n")
+				continue
+			}
 			buf.WriteString(html.EscapeString(line))
 			buf.WriteString("\n")
 			continue
@@ -131,6 +227,7 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 			flushList()
 			flushOrderedList()
 			flushQuote()
+			flushDefList()
 			flushTable()
 			continue
 		}
@@ -141,34 +238,46 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 			flushList()
 			flushOrderedList()
 			flushQuote()
+			flushDefList()
 			flushTable()
 			buf.WriteString("<hr/>")
+		case reBookmark.MatchString(line):
+			flushPara()
+			flushList()
+			flushOrderedList()
+			flushQuote()
+			flushDefList()
+			flushTable()
+			buf.WriteString(o.renderBookmark(reBookmark.FindStringSubmatch(line)[1]))
 		case strings.HasPrefix(line, "# "):
 			flushPara()
 			flushList()
 			flushOrderedList()
 			flushQuote()
+			flushDefList()
 			flushTable()
 			buf.WriteString("<h1>")
-			buf.WriteString(FormatInline(strings.TrimSpace(line[2:]), &imageCount))
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line[2:]), &imageCount))
 			buf.WriteString("</h1>")
 		case strings.HasPrefix(line, "## "):
 			flushPara()
 			flushList()
 			flushOrderedList()
 			flushQuote()
+			flushDefList()
 			flushTable()
 			buf.WriteString("<h2>")
-			buf.WriteString(FormatInline(strings.TrimSpace(line[3:]), &imageCount))
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line[3:]), &imageCount))
 			buf.WriteString("</h2>")
 		case strings.HasPrefix(line, "### "):
 			flushPara()
 			flushList()
 			flushOrderedList()
 			flushQuote()
+			flushDefList()
 			flushTable()
 			buf.WriteString("<h3>")
-			buf.WriteString(FormatInline(strings.TrimSpace(line[4:]), &imageCount))
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line[4:]), &imageCount))
 			buf.WriteString("</h3>")
 		case strings.HasPrefix(line, "|"):
 			if !inTable {
@@ -176,13 +285,14 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 				flushList()
 				flushOrderedList()
 				flushQuote()
+				flushDefList()
 				buf.WriteString("<table>")
 				inTable = true
 				// First row is the header
 				buf.WriteString("<thead><tr>")
 				for _, cell := range parseTableCells(line) {
 					buf.WriteString("<th>")
-					buf.WriteString(FormatInline(cell, &imageCount))
+					buf.WriteString(o.FormatInline(cell, &imageCount))
 					buf.WriteString("</th>")
 				}
 				buf.WriteString("</tr></thead>")
@@ -200,7 +310,7 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 				buf.WriteString("<tr>")
 				for _, cell := range parseTableCells(line) {
 					buf.WriteString("<td>")
-					buf.WriteString(FormatInline(cell, &imageCount))
+					buf.WriteString(o.FormatInline(cell, &imageCount))
 					buf.WriteString("</td>")
 				}
 				buf.WriteString("</tr>")
@@ -210,58 +320,200 @@ func RenderMarkdown(buf *bytes.Buffer, md string) {
 				flushPara()
 				flushOrderedList()
 				flushQuote()
+				flushDefList()
 				flushTable()
 				buf.WriteString("<ul>")
 				inList = true
 			}
 			buf.WriteString("<li>")
-			buf.WriteString(FormatInline(strings.TrimSpace(line[2:]), &imageCount))
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line[2:]), &imageCount))
 			buf.WriteString("</li>")
 		case reOrderedList.MatchString(line):
 			if !inOrderedList {
 				flushPara()
 				flushList()
 				flushQuote()
+				flushDefList()
 				flushTable()
 				buf.WriteString("<ol>")
 				inOrderedList = true
 			}
 			content := reOrderedList.ReplaceAllString(line, "")
 			buf.WriteString("<li>")
-			buf.WriteString(FormatInline(strings.TrimSpace(content), &imageCount))
+			buf.WriteString(o.FormatInline(strings.TrimSpace(content), &imageCount))
 			buf.WriteString("</li>")
-		case strings.HasPrefix(line, "> "):
+		case line == ">" || strings.HasPrefix(line, "> "):
 			if !inQuote {
 				flushPara()
 				flushList()
 				flushOrderedList()
 				flushTable()
+				flushDefList()
 				buf.WriteString("<blockquote>")
 				inQuote = true
 			}
-			buf.WriteString(FormatInline(strings.TrimSpace(line[2:]), &imageCount))
+			// A bare ">" line separates paragraphs inside the quote.
+			content := strings.TrimSpace(line[1:])
+			if content == "" {
+				flushQuotePara()
+				continue
+			}
+			if !inQuotePara {
+				buf.WriteString("<p>")
+				inQuotePara = true
+			} else {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(o.FormatInline(content, &imageCount))
+		case strings.HasPrefix(line, ": "):
+			// Definition, following a term line
+			if !inDefList {
+				flushPara()
+				flushList()
+				flushOrderedList()
+				flushQuote()
+				flushTable()
+				buf.WriteString("<dl>")
+				inDefList = true
+			}
+			buf.WriteString("<dd>")
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line[2:]), &imageCount))
+			buf.WriteString("</dd>")
+		case i+1 < len(lines) && strings.HasPrefix(lines[i+1], ": "):
+			// Term of a definition list
+			if !inDefList {
+				flushPara()
+				flushList()
+				flushOrderedList()
+				flushQuote()
+				flushTable()
+				buf.WriteString("<dl>")
+				inDefList = true
+			}
+			buf.WriteString("<dt>")
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line), &imageCount))
+			buf.WriteString("</dt>")
 		default:
 			if !inPara {
 				flushList()
 				flushOrderedList()
 				flushQuote()
+				flushDefList()
 				flushTable()
 				buf.WriteString("<p>")
 				inPara = true
 			} else {
 				buf.WriteString(" ")
 			}
-			buf.WriteString(FormatInline(strings.TrimSpace(line), &imageCount) + "\n")
+			buf.WriteString(o.FormatInline(strings.TrimSpace(line), &imageCount) + "\n")
 		}
 	}
 	flushPara()
 	flushList()
 	flushOrderedList()
 	flushQuote()
+	flushDefList()
 	flushTable()
 	flushCode()
 }
 
+// codeFence holds the options of a fenced code block's info string,
+// e.g. ```go title=main.go {3-5,8} linenos
+type codeFence struct {
+	lang        string
+	title       string
+	highlight   []lineRange // lines to mark, from {3-5,8}
+	lineNumbers bool        // emit a line-number gutter
+}
+
+// lineRange is an inclusive range of 1-based line numbers.
+type lineRange struct{ from, to int }
+
+// highlighted reports whether line n is marked in the fence's {…} ranges.
+func (f codeFence) highlighted(n int) bool {
+	for _, r := range f.highlight {
+		if n >= r.from && n <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLineRanges parses "3-5,8" into line ranges, skipping malformed parts.
+func parseLineRanges(spec string) []lineRange {
+	var ranges []lineRange
+	for _, part := range strings.Split(spec, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil || from < 1 {
+			continue
+		}
+		to := from
+		if isRange {
+			to, err = strconv.Atoi(hi)
+			if err != nil || to < from {
+				continue
+			}
+		}
+		ranges = append(ranges, lineRange{from, to})
+	}
+	return ranges
+}
+
+// parseCodeFence parses the text after ``` into a language and key=value
+// options. Values may be double-quoted to include spaces: title="my file.go".
+func parseCodeFence(info string) codeFence {
+	var fence codeFence
+	for _, field := range splitFenceFields(info) {
+		if strings.HasPrefix(field, "{") && strings.HasSuffix(field, "}") {
+			fence.highlight = append(fence.highlight, parseLineRanges(field[1:len(field)-1])...)
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			switch {
+			case field == "linenos" || field == "showLineNumbers":
+				fence.lineNumbers = true
+			case fence.lang == "":
+				fence.lang = field
+			}
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "title", "filename":
+			fence.title = strings.Trim(value, `"`)
+		case "linenos":
+			fence.lineNumbers = value != "false"
+		}
+	}
+	return fence
+}
+
+// splitFenceFields splits a fence info string on spaces outside double quotes.
+func splitFenceFields(info string) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range strings.TrimSpace(info) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
 func parseTableCells(line string) []string {
 	line = strings.TrimSpace(line)
 	line = strings.Trim(line, "|")
@@ -309,10 +561,19 @@ func ApplyOutsideTags(s string, fn func(string) string) string {
 	return buf.String()
 }
 
-// FormatInline applies inline formatting (bold, italic, links, images) to s.
+// FormatInline applies inline formatting (bold, italic, links, images) to
+// s, rendered with the zero Options.
 func FormatInline(s string, imageCount *int) string {
+	return Options{}.FormatInline(s, imageCount)
+}
+
+// FormatInline applies inline formatting (bold, italic, links, images) to
+// s. imageCount counts the images rendered so far, as only the first gets
+// fetchpriority="high".
+func (o Options) FormatInline(s string, imageCount *int) string {
 	escaped := html.EscapeString(s)
-	// ![alt](url){style} or ![alt](url){style|width|height}
+	// ![alt](url){style}, ![alt](url){style|width|height} and optional
+	// loading, decoding and sizes fields (see parseImageAttrs)
 	escaped = reImg.ReplaceAllStringFunc(escaped, func(m string) string {
 		match := reImg.FindStringSubmatch(m)
 		if len(match) < 4 {
@@ -322,25 +583,36 @@ func FormatInline(s string, imageCount *int) string {
 		if src == "" {
 			return match[1]
 		}
+		src = o.rewriteMedia(src)
 
 		alt := match[1]
-		style := match[3]
-		width := "1024"
-		height := "768"
-		if len(match) >= 6 && match[4] != "" && match[5] != "" {
-			width = match[4]
-			height = match[5]
-		}
+		attrs := parseImageAttrs(match[3])
+		opts := o.Images.withDefaults()
 
 		*imageCount++
 		var loadAttr string
-		if *imageCount == 1 {
+		switch {
+		case attrs.loading != "":
+			loadAttr = `loading="` + attrs.loading + `"`
+		case *imageCount == 1:
 			loadAttr = `fetchpriority="high"`
-		} else {
-			loadAttr = `loading="eager"`
+		default:
+			loadAttr = `loading="` + opts.Loading + `"`
+		}
+		decoding := opts.Decoding
+		if attrs.decoding != "" {
+			decoding = attrs.decoding
+		}
+		sizes := opts.Sizes
+		if attrs.sizes != "" {
+			sizes = attrs.sizes
+		}
+		var sizesAttr string
+		if sizes != "" {
+			sizesAttr = ` sizes="` + sizes + `"`
 		}
 
-		return `<img ` + loadAttr + ` width="` + width + `" height="` + height + `" alt="` + alt + `" src="` + src + `" style="` + style + `" decoding="async"/>`
+		return `<img ` + loadAttr + ` width="` + attrs.width + `" height="` + attrs.height + `" alt="` + alt + `" src="` + src + `" style="` + attrs.style + `"` + sizesAttr + ` decoding="` + decoding + `"/>`
 	})
 	escaped = reLink.ReplaceAllStringFunc(escaped, func(m string) string {
 		match := reLink.FindStringSubmatch(m)
@@ -366,6 +638,22 @@ func FormatInline(s string, imageCount *int) string {
 		inlineCodeBlocks = append(inlineCodeBlocks, "<code>"+match[1]+"</code>")
 		return placeholder
 	})
+	// Attachment shortcodes. Placeholders keep underscores in file names
+	// from being read as emphasis.
+	var shortcodes []string
+	escaped = reShortcode.ReplaceAllStringFunc(escaped, func(m string) string {
+		placeholder := "\x00SC" + strconv.Itoa(len(shortcodes)) + "\x00"
+		shortcodes = append(shortcodes, o.renderShortcode(reShortcode.FindStringSubmatch(m)))
+		return placeholder
+	})
+	// Bare URLs and emails become links. They are swapped for placeholders
+	// too, so underscores and asterisks in URLs are not read as emphasis.
+	var autolinks []string
+	escaped = autolink(escaped, func(a string) string {
+		placeholder := "\x00AL" + strconv.Itoa(len(autolinks)) + "\x00"
+		autolinks = append(autolinks, a)
+		return placeholder
+	})
 	// Apply bold/italic only outside HTML tags so URLs in href are not corrupted
 	escaped = ApplyOutsideTags(escaped, func(seg string) string {
 		seg = reBold.ReplaceAllString(seg, "<strong>$1</strong>")
@@ -374,6 +662,15 @@ func FormatInline(s string, imageCount *int) string {
 		seg = reItalicUnderscore.ReplaceAllString(seg, "<em>$1</em>")
 		return seg
 	})
+	if o.Typography {
+		escaped = applyTypography(escaped)
+	}
+	for i, a := range autolinks {
+		escaped = strings.Replace(escaped, "\x00AL"+strconv.Itoa(i)+"\x00", a, 1)
+	}
+	for i, sc := range shortcodes {
+		escaped = strings.Replace(escaped, "\x00SC"+strconv.Itoa(i)+"\x00", sc, 1)
+	}
 	// Restore inline code blocks
 	for i, code := range inlineCodeBlocks {
 		escaped = strings.Replace(escaped, "\x00IC"+strconv.Itoa(i)+"\x00", code, 1)
@@ -381,6 +678,123 @@ func FormatInline(s string, imageCount *int) string {
 	return escaped
 }
 
+// renderShortcode renders a reShortcode match: a link to the attachment,
+// labelled with the optional text or else the file name, or an audio or
+// video player with a fallback link. The label is already escaped.
+func (o Options) renderShortcode(match []string) string {
+	kind, name, rest := match[1], match[2], match[3]
+	src := o.rewriteMedia(AttachmentsPath + name)
+	label := rest
+	if label == "" || kind == "video" {
+		label = name
+	}
+	link := `<a href="` + src + `" class="underline decoration-2 underline-offset-4">` + label + `</a>`
+	switch kind {
+	case "audio":
+		return `<audio controls preload="none" src="` + src + `">` + link + `</audio>`
+	case "video":
+		return o.renderVideo(name, rest) + link + `</video>`
+	default:
+		return link
+	}
+}
+
+// renderVideo returns the opening <video> tag for the {{video}} shortcode
+// of attachment name.
+// opts holds optional space separated fields, in any order: the size as
+// WIDTHxHEIGHT and the preload value (none, metadata or auto, default
+// metadata). The poster is the frame the server extracts on upload.
+func (o Options) renderVideo(name, opts string) string {
+	preload := "metadata"
+	var size string
+	for _, f := range strings.Fields(opts) {
+		switch {
+		case f == "none" || f == "metadata" || f == "auto":
+			preload = f
+		case reVideoSize.MatchString(f):
+			w, h, _ := strings.Cut(f, "x")
+			size = ` width="` + w + `" height="` + h + `"`
+		}
+	}
+	poster := o.rewriteMedia(AttachmentsPath + name + "/poster.jpg")
+	src := o.rewriteMedia(AttachmentsPath + name)
+	return `<video controls playsinline preload="` + preload + `" poster="` + poster + `"` + size + ` src="` + src + `">`
+}
+
+// autolink links bare http(s) URLs and email addresses in already escaped
+// text, skipping HTML tags and the text of existing links. Each generated
+// anchor is passed through wrap before insertion.
+func autolink(s string, wrap func(string) string) string {
+	var buf strings.Builder
+	inAnchor := false
+	for len(s) > 0 {
+		lt := strings.Index(s, "<")
+		text := s
+		if lt >= 0 {
+			text = s[:lt]
+		}
+		if inAnchor {
+			buf.WriteString(text)
+		} else {
+			buf.WriteString(autolinkText(text, wrap))
+		}
+		if lt < 0 {
+			break
+		}
+		gt := strings.Index(s[lt:], ">")
+		if gt < 0 {
+			buf.WriteString(s[lt:])
+			break
+		}
+		tag := s[lt : lt+gt+1]
+		switch {
+		case strings.HasPrefix(tag, "<a "):
+			inAnchor = true
+		case tag == "</a>":
+			inAnchor = false
+		}
+		buf.WriteString(tag)
+		s = s[lt+gt+1:]
+	}
+	return buf.String()
+}
+
+// autolinkText replaces URLs and emails in a text segment with anchors.
+func autolinkText(s string, wrap func(string) string) string {
+	return reAutolink.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.Contains(m, "://") {
+			trimmed := trimURLTail(m)
+			href := SafeURL(trimmed)
+			if href == "" {
+				return m
+			}
+			return wrap(`<a href="`+href+`" class="underline decoration-2 underline-offset-4">`+trimmed+`</a>`) + m[len(trimmed):]
+		}
+		href := SafeURL("mailto:" + m)
+		if href == "" {
+			return m
+		}
+		return wrap(`<a href="` + href + `" class="underline decoration-2 underline-offset-4">` + m + `</a>`)
+	})
+}
+
+// trimURLTail drops trailing punctuation that usually ends the sentence
+// rather than the URL, keeping a closing paren when it balances one inside.
+func trimURLTail(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?*_~]", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
 // SafeURL validates and sanitizes a URL for use in HTML attributes.
 func SafeURL(raw string) string {
 	val := strings.TrimSpace(html.UnescapeString(raw))
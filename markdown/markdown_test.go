@@ -2,6 +2,7 @@ package markdown
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
 )
@@ -109,6 +110,92 @@ func TestRenderMarkdownCodeBlockWithoutLanguage(t *testing.T) {
 	}
 }
 
+func TestRenderMarkdownCodeBlockWithTitle(t *testing.T) {
+	input := "```go title=main.go\npackage main\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	if !strings.Contains(got, `<div class="code-block-header"><span class="code-title">main.go</span></div>`) {
+		t.Errorf("code block should have a filename header: %q", got)
+	}
+	if !strings.Contains(got, `<span class="code-lang code-lang-go">go</span>`) {
+		t.Errorf("code block should keep its language badge: %q", got)
+	}
+	if !strings.Contains(got, `<pre class="code-block" id="code-1">`) {
+		t.Errorf("code block should have an id: %q", got)
+	}
+}
+
+func TestRenderMarkdownCodeBlockQuotedTitle(t *testing.T) {
+	input := "```title=\"my <file>.txt\"\nhello\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	if !strings.Contains(got, `<span class="code-title">my &lt;file&gt;.txt</span>`) {
+		t.Errorf("quoted title should be escaped and keep spaces: %q", got)
+	}
+	if strings.Contains(got, "code-lang") {
+		t.Errorf("code block without language should not have badge: %q", got)
+	}
+	if !strings.Contains(got, `<div class="code-block-wrapper">`) {
+		t.Errorf("titled code block should be wrapped in div: %q", got)
+	}
+}
+
+func TestRenderMarkdownCodeBlockIDsAreSequential(t *testing.T) {
+	input := "```\na\n```\n\n```go\nb\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	if !strings.Contains(got, `id="code-1"`) || !strings.Contains(got, `id="code-2"`) {
+		t.Errorf("code blocks should have sequential ids: %q", got)
+	}
+}
+
+func TestRenderMarkdownCodeBlockHighlightedLines(t *testing.T) {
+	input := "```go {2-3,5}\na\nb\nc\nd\ne\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	for _, n := range []string{"2", "3", "5"} {
+		if !strings.Contains(got, `<span class="line highlighted" data-line="`+n+`">`) {
+			t.Errorf("line %s should be highlighted: %q", n, got)
+		}
+	}
+	for _, n := range []string{"1", "4"} {
+		if !strings.Contains(got, `<span class="line" data-line="`+n+`">`) {
+			t.Errorf("line %s should not be highlighted: %q", n, got)
+		}
+	}
+	if strings.Contains(got, "line-number") {
+		t.Errorf("line numbers should be off by default: %q", got)
+	}
+	if !strings.Contains(got, `class="language-go"`) {
+		t.Errorf("language should still be parsed: %q", got)
+	}
+}
+
+func TestRenderMarkdownCodeBlockLineNumbers(t *testing.T) {
+	input := "```linenos\nx < y\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	expected := `<span class="line" data-line="1"><span class="line-number" aria-hidden="true">1</span>x &lt; y</span>`
+	if !strings.Contains(got, expected) {
+		t.Errorf("RenderMarkdown(%q) = %q, want line number gutter", input, got)
+	}
+	if strings.Contains(got, "language-linenos") {
+		t.Errorf("linenos should not be treated as a language: %q", got)
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	got := parseLineRanges("3-5, 8,x,9-7,0")
+	if len(got) != 2 || got[0] != (lineRange{3, 5}) || got[1] != (lineRange{8, 8}) {
+		t.Errorf("parseLineRanges = %v, want [{3 5} {8 8}]", got)
+	}
+}
+
 func TestRenderMarkdownHeadings(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -254,3 +341,235 @@ func TestRenderMarkdownOrderedListFollowedByParagraph(t *testing.T) {
 		t.Errorf("expected paragraph after list: %q", got)
 	}
 }
+
+func TestRefs(t *testing.T) {
+	input := "See [docs](https://example.com) and `[x](y)`.\n![logo](/public/uploads/logo.png){}\n```\n[skip](me)\n```\n![bare](pic.png)"
+	refs, problems := Refs(input)
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems: %+v", problems)
+	}
+	want := []Ref{
+		{Line: 1, Text: "docs", URL: "https://example.com"},
+		{Line: 2, Text: "logo", URL: "/public/uploads/logo.png", Image: true, Style: true},
+		{Line: 6, Text: "bare", URL: "pic.png", Image: true},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("Refs returned %d refs, want %d: %+v", len(refs), len(want), refs)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("ref %d = %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
+
+func TestRefsMalformed(t *testing.T) {
+	_, problems := Refs("fine\n[broken](https://example.com")
+	if len(problems) != 1 || problems[0].Line != 2 {
+		t.Errorf("expected one problem on line 2, got %+v", problems)
+	}
+}
+
+func TestFormatInlineAutolink(t *testing.T) {
+	n := 0
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"see https://example.com/a_b_c.", `see <a href="https://example.com/a_b_c" class="underline decoration-2 underline-offset-4">https://example.com/a_b_c</a>.`},
+		{"(https://example.com/wiki/Go_(lang))", `(<a href="https://example.com/wiki/Go_(lang)" class="underline decoration-2 underline-offset-4">https://example.com/wiki/Go_(lang)</a>)`},
+		{"q?a=1&b=2 https://x.io/?a=1&b=2", `q?a=1&amp;b=2 <a href="https://x.io/?a=1&amp;b=2" class="underline decoration-2 underline-offset-4">https://x.io/?a=1&amp;b=2</a>`},
+		{"mail me@example.com", `mail <a href="mailto:me@example.com" class="underline decoration-2 underline-offset-4">me@example.com</a>`},
+		{"**https://example.com**", `<strong><a href="https://example.com" class="underline decoration-2 underline-offset-4">https://example.com</a></strong>`},
+	}
+	for _, tt := range tests {
+		if got := FormatInline(tt.input, &n); got != tt.expected {
+			t.Errorf("FormatInline(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatInlineAutolinkSkips(t *testing.T) {
+	n := 0
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`https://example.com`", "<code>https://example.com</code>"},
+		{"[https://example.com](https://example.com)", `<a href="https://example.com" class="underline decoration-2 underline-offset-4">https://example.com</a>`},
+		{"javascript://alert(1)", "javascript://alert(1)"},
+	}
+	for _, tt := range tests {
+		if got := FormatInline(tt.input, &n); got != tt.expected {
+			t.Errorf("FormatInline(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderMarkdownBlockquoteParagraphs(t *testing.T) {
+	input := "> first line\n> continues\n>\n> second paragraph\n\nafter"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	expected := "<blockquote><p>first line continues</p><p>second paragraph</p></blockquote><p>after\n</p>"
+	if got != expected {
+		t.Errorf("RenderMarkdown(%q) = %q, want %q", input, got, expected)
+	}
+}
+
+func TestRenderMarkdownDefinitionList(t *testing.T) {
+	input := "Go\n: A programming language\n: Also a board game\nSQLite\n: An *embedded* database\n\ntext"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, input)
+	got := buf.String()
+	expected := "<dl><dt>Go</dt><dd>A programming language</dd><dd>Also a board game</dd><dt>SQLite</dt><dd>An <em>embedded</em> database</dd></dl><p>text\n</p>"
+	if got != expected {
+		t.Errorf("RenderMarkdown(%q) = %q, want %q", input, got, expected)
+	}
+}
+
+func TestFormatInlineImageLoading(t *testing.T) {
+	n := 0
+	first := FormatInline("![a](/a.png){}", &n)
+	if !strings.Contains(first, `fetchpriority="high"`) || strings.Contains(first, "loading=") {
+		t.Errorf("first image should have fetchpriority only: %q", first)
+	}
+	second := FormatInline("![b](/b.png){}", &n)
+	if !strings.Contains(second, `loading="lazy"`) || !strings.Contains(second, `decoding="async"`) {
+		t.Errorf("later images should be lazy: %q", second)
+	}
+}
+
+func TestFormatInlineImageOverrides(t *testing.T) {
+	n := 1
+	got := FormatInline("![b](/b.png){width:50%|800|600|eager|decoding=sync|sizes=(max-width: 600px) 100vw}", &n)
+	expected := `<img loading="eager" width="800" height="600" alt="b" src="/b.png" style="width:50%" sizes="(max-width: 600px) 100vw" decoding="sync"/>`
+	if got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+}
+
+func TestImageOptions(t *testing.T) {
+	if err := (ImageOptions{Loading: "sometimes"}).Validate(); err == nil {
+		t.Error("expected error for invalid loading")
+	}
+	if err := (ImageOptions{Decoding: "later"}).Validate(); err == nil {
+		t.Error("expected error for invalid decoding")
+	}
+	o := Options{Images: ImageOptions{Loading: "eager", Sizes: "100vw"}}
+	if err := o.Images.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	n := 1
+	got := o.FormatInline("![b](/b.png){}", &n)
+	if !strings.Contains(got, `loading="eager"`) || !strings.Contains(got, `sizes="100vw"`) || !strings.Contains(got, `decoding="async"`) {
+		t.Errorf("options not applied: %q", got)
+	}
+}
+
+func TestMarkdownOptions(t *testing.T) {
+	const md = "![a](/a.png){} ![b](/b.png){}"
+	var buf bytes.Buffer
+	if err := Markdown(md).Render(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `loading="lazy"`) {
+		t.Errorf("without options got %q", buf.String())
+	}
+	buf.Reset()
+	ctx := WithOptions(context.Background(), Options{Images: ImageOptions{Loading: "eager"}})
+	if err := Markdown(md).Render(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `loading="eager"`) {
+		t.Errorf("with the context's options got %q", buf.String())
+	}
+}
+
+func TestFormatInlineShortcodes(t *testing.T) {
+	n := 0
+	got := FormatInline("Slides: {{file my_talk.pdf The _slides_ & notes}} and `{{file x.pdf}}`", &n)
+	expected := `Slides: <a href="/files/my_talk.pdf" class="underline decoration-2 underline-offset-4">The _slides_ &amp; notes</a> and <code>{{file x.pdf}}</code>`
+	if got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+	got = FormatInline("{{audio episode-1.mp3}}", &n)
+	expected = `<audio controls preload="none" src="/files/episode-1.mp3"><a href="/files/episode-1.mp3" class="underline decoration-2 underline-offset-4">episode-1.mp3</a></audio>`
+	if got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+	if got := FormatInline(`{{file ../x.pdf}}`, &n); strings.Contains(got, "<a") {
+		t.Errorf("path in shortcode rendered a link: %q", got)
+	}
+}
+
+func TestFormatInlineVideoShortcode(t *testing.T) {
+	n := 0
+	got := FormatInline("{{video talk.mp4 1280x720 none}}", &n)
+	expected := `<video controls playsinline preload="none" poster="/files/talk.mp4/poster.jpg" width="1280" height="720" src="/files/talk.mp4"><a href="/files/talk.mp4" class="underline decoration-2 underline-offset-4">talk.mp4</a></video>`
+	if got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+	got = FormatInline(`{{video clip.webm 10x"20 onload}}`, &n)
+	if !strings.Contains(got, `<video controls playsinline preload="metadata" poster="/files/clip.webm/poster.jpg" src="/files/clip.webm">`) {
+		t.Errorf("invalid options not ignored: %q", got)
+	}
+}
+
+func TestBookmarkShortcode(t *testing.T) {
+	md := "{{bookmark https://example.com/a?x=1&y=2}}\n\n```\n{{bookmark https://example.com/b}}\n```"
+	var buf bytes.Buffer
+	RenderMarkdown(&buf, md)
+	if !strings.HasPrefix(buf.String(), `<p><a href="https://example.com/a?x=1&amp;y=2" class="underline decoration-2 underline-offset-4">https://example.com/a?x=1&amp;y=2</a></p>`) {
+		t.Errorf("without a preview got %q", buf.String())
+	}
+	if got := BookmarkURLs(md); len(got) != 1 || got[0] != "https://example.com/a?x=1&y=2" {
+		t.Errorf("BookmarkURLs = %q", got)
+	}
+
+	o := Options{LinkPreviews: func(u string) (LinkPreview, bool) {
+		return LinkPreview{Title: "A <b>", Description: "About A", Image: "http://example.com/a.png"}, true
+	}}
+	buf.Reset()
+	o.Render(&buf, "{{bookmark https://www.example.com/a}}")
+	expected := `<a href="https://www.example.com/a" class="bookmark" rel="noopener noreferrer"><span class="bookmark-text"><span class="bookmark-title">A &lt;b&gt;</span><span class="bookmark-description">About A</span><span class="bookmark-site">example.com</span></span></a>`
+	if buf.String() != expected {
+		t.Errorf("got %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestMediaURL(t *testing.T) {
+	o := Options{MediaURL: func(p string) string { return "https://cdn.example.com" + p }}
+	n := 0
+	got := o.FormatInline("![a](/public/uploads/a.jpg){} ![b](https://other.example/b.png){} {{file x.pdf}}", &n)
+	for _, want := range []string{`src="https://cdn.example.com/public/uploads/a.jpg"`, `src="https://other.example/b.png"`, `href="https://cdn.example.com/files/x.pdf"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %q", want, got)
+		}
+	}
+}
+
+func TestTypography(t *testing.T) {
+	n := 0
+	plain := `"Hi" -- it's 5 km`
+	if got := FormatInline(plain, &n); got != `&#34;Hi&#34; -- it&#39;s 5 km` {
+		t.Errorf("typography off: %q", got)
+	}
+
+	o := Options{Typography: true}
+	tests := []struct {
+		in, want string
+	}{
+		{`"Hi," she said -- it's 5 km away`, "“Hi,” she said — it’s 5\u00a0km away"},
+		{`'quoted' and ("nested 'single'")`, "‘quoted’ and (“nested ‘single’”)"},
+		{`pre---post and 50 % off, 3 ms`, "pre—post and 50\u00a0% off, 3\u00a0ms"},
+		{`5 kittens, run --verbose`, `5 kittens, run --verbose`},
+		{`"**bold**" and [a "link"](https://example.com/?q="x")`, `“<strong>bold</strong>” and <a href="https://example.com/?q=&#34;x&#34;" class="underline decoration-2 underline-offset-4">a “link”</a>`},
+		{"`\"code\" -- 5 km`", "<code>&#34;code&#34; -- 5 km</code>"},
+	}
+	for _, tt := range tests {
+		if got := o.FormatInline(tt.in, &n); got != tt.want {
+			t.Errorf("FormatInline(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reImgNoStyle matches image syntax, with or without the {style} suffix.
+var reImgNoStyle = regexp.MustCompile(`\!\[(.*?)\]\((.*?)\)(\{([^}]*)\})?`)
+
+// Ref is a link or image found in Markdown source.
+type Ref struct {
+	Line  int    // 1-based source line
+	Text  string // link text or image alt
+	URL   string // raw URL as written
+	Image bool
+	Style bool   // image has the {style} suffix the renderer requires
+	Attrs string // contents of the {style|...} suffix
+}
+
+// Heading is an ATX heading found in Markdown source.
+type Heading struct {
+	Line  int
+	Level int // 1 to 3
+	Text  string
+}
+
+// Problem is a syntax issue found by Refs.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+// Refs returns the links and images in md in source order, along with
+// syntax problems such as unclosed link brackets. Fenced code blocks and
+// inline code are skipped, matching what RenderMarkdown formats.
+func Refs(md string) ([]Ref, []Problem) {
+	var refs []Ref
+	var problems []Problem
+	inCode := false
+	for i, raw := range strings.Split(md, "\n") {
+		line := strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		n := i + 1
+		rest := reInlineCode.ReplaceAllString(line, "")
+
+		for _, m := range reImgNoStyle.FindAllStringSubmatch(rest, -1) {
+			refs = append(refs, Ref{Line: n, Text: m[1], URL: m[2], Image: true, Style: m[3] != "", Attrs: m[4]})
+		}
+		rest = reImgNoStyle.ReplaceAllString(rest, "")
+
+		for _, m := range reLink.FindAllStringSubmatch(rest, -1) {
+			refs = append(refs, Ref{Line: n, Text: m[1], URL: m[2]})
+		}
+		rest = reLink.ReplaceAllString(rest, "")
+
+		if strings.Contains(rest, "](") || strings.Contains(rest, "![") {
+			problems = append(problems, Problem{Line: n, Message: "malformed link or image syntax"})
+		}
+	}
+	return refs, problems
+}
+
+// Headings returns the headings in md that RenderMarkdown turns into
+// <h1> to <h3>, skipping fenced code blocks.
+func Headings(md string) []Heading {
+	var headings []Heading
+	inCode := false
+	for i, raw := range strings.Split(md, "\n") {
+		line := strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		for level, prefix := range []string{"# ", "## ", "### "} {
+			if strings.HasPrefix(line, prefix) {
+				headings = append(headings, Heading{Line: i + 1, Level: level + 1, Text: strings.TrimSpace(line[len(prefix):])})
+				break
+			}
+		}
+	}
+	return headings
+}
@@ -0,0 +1,65 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	reEmDash = regexp.MustCompile(`(\s)---?(\s)|(\w)---?(\w)`)
+	reUnit   = regexp.MustCompile(`(\d) (%|‰|°C|°F|°|km|cm|mm|µm|nm|m|kg|mg|g|ml|mL|l|L|ms|µs|ns|s|min|h|kHz|MHz|GHz|Hz|KiB|MiB|GiB|TiB|KB|kB|MB|GB|TB|B|px|pt|rem|em|kW|W|V|mAh|€|£)([^\pL\pN_]|$)`)
+)
+
+// applyTypography runs the typography pass of Options.Typography on s,
+// HTML escaped text that may contain tags.
+func applyTypography(s string) string {
+	s = ApplyOutsideTags(s, func(seg string) string {
+		seg = reEmDash.ReplaceAllString(seg, "$1$3—$2$4")
+		return reUnit.ReplaceAllString(seg, "$1\u00a0$2$3")
+	})
+	return smartQuotes(s)
+}
+
+// smartQuotes replaces the escaped straight quotes in s with curly ones,
+// opening after spaces and opening punctuation and closing elsewhere, so
+// apostrophes come out as closing single quotes.
+func smartQuotes(s string) string {
+	var b strings.Builder
+	prev := ' '
+	for i := 0; i < len(s); {
+		if s[i] == '<' {
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				b.WriteString(s[i:])
+				break
+			}
+			b.WriteString(s[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		quote, opening, closing := "", "", ""
+		switch {
+		case strings.HasPrefix(s[i:], "&#34;"):
+			quote, opening, closing = "&#34;", "“", "”"
+		case strings.HasPrefix(s[i:], "&#39;"):
+			quote, opening, closing = "&#39;", "‘", "’"
+		}
+		if quote != "" {
+			q := closing
+			if unicode.IsSpace(prev) || strings.ContainsRune("([{“‘—–-/", prev) {
+				q = opening
+			}
+			b.WriteString(q)
+			prev, _ = utf8.DecodeRuneInString(q)
+			i += len(quote)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+size])
+		prev = r
+		i += size
+	}
+	return b.String()
+}
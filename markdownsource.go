@@ -0,0 +1,62 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mimeTextMarkdown is the content type of post Markdown sources.
+const mimeTextMarkdown = "text/markdown; charset=UTF-8"
+
+// MarkdownURL returns the URL of a post's Markdown source, e.g.
+// "https://example.com/blog/my-post/index.md".
+func MarkdownURL(base, slug string) string {
+	return AbsoluteURL(BuildURL(base, "blog", slug), "index.md")
+}
+
+// handlePostMarkdown serves the Markdown source of a published post when
+// ServeMarkdown is enabled.
+func (a *App) handlePostMarkdown(c echo.Context) error {
+	if !a.Config.ServeMarkdown {
+		return echo.ErrNotFound
+	}
+	post, err := a.Cache.GetPost(c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	setNoIndex(c, post)
+	return c.Blob(http.StatusOK, mimeTextMarkdown, post.ToMarkdownFile())
+}
+
+// prefersMarkdown reports whether an Accept header ranks text/markdown at
+// least as high as text/html, so clients asking for both get HTML only when
+// they prefer it.
+func prefersMarkdown(accept string) bool {
+	var md, html float64
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch typ {
+		case "text/markdown":
+			md = max(md, q)
+		case "text/html", "text/*", "*/*":
+			html = max(html, q)
+		}
+	}
+	return md > 0 && md >= html
+}
@@ -0,0 +1,60 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestPostMarkdown(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.ServeMarkdown = true
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Content: "**Hi** there", Published: true})
+	savePosts(t, app, BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-02"})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		rec := httptest.NewRecorder()
+		app.Echo.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, tt := range []struct {
+		path, accept string
+		markdown     bool
+	}{
+		{"/blog/hello/index.md", "", true},
+		{"/blog/hello/", "text/markdown", true},
+		{"/blog/hello/", "text/markdown, text/html;q=0.9", true},
+		{"/blog/hello/", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/blog/hello/", "text/html;q=0.9, text/markdown;q=0.5", false},
+	} {
+		rec := get(tt.path, tt.accept)
+		isMarkdown := strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), "text/markdown")
+		if rec.Code != http.StatusOK || isMarkdown != tt.markdown {
+			t.Errorf("GET %s (Accept %q) = %d %s, want markdown %t", tt.path, tt.accept, rec.Code, rec.Header().Get(echo.HeaderContentType), tt.markdown)
+		}
+		if isMarkdown && !strings.HasSuffix(rec.Body.String(), "---\n\n**Hi** there\n") {
+			t.Errorf("GET %s body = %q", tt.path, rec.Body.String())
+		}
+	}
+	if rec := get("/blog/draft/index.md", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("draft source = %d, want 404", rec.Code)
+	}
+
+	app.Config.ServeMarkdown = false
+	if rec := get("/blog/hello/index.md", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("source with ServeMarkdown off = %d, want 404", rec.Code)
+	}
+	if rec := get("/blog/hello/", "text/markdown"); strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), "text/markdown") {
+		t.Error("served Markdown with ServeMarkdown off")
+	}
+}
@@ -0,0 +1,62 @@
+package pubengine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// mediaURL returns ref, an image or attachment URL, as rewritten by
+// MediaURL when it is a site path, or ref unchanged.
+func (c *SiteConfig) mediaURL(ref string) string {
+	if c.MediaURL == nil || !strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "//") {
+		return ref
+	}
+	return c.MediaURL(ref)
+}
+
+// CDNMediaURL returns a SiteConfig.MediaURL function serving media from
+// base, a CDN origin pulling from the site: "/public/uploads/x.jpg" becomes
+// base + "/public/uploads/x.jpg". It returns nil when base is empty, so it
+// can be fed from an optional environment variable.
+func CDNMediaURL(base string) func(path string) string {
+	if base == "" {
+		return nil
+	}
+	base = strings.TrimSuffix(base, "/")
+	return func(p string) string {
+		return base + p
+	}
+}
+
+// SignedMediaURL returns a SiteConfig.MediaURL function for an image resize
+// endpoint or proxy at base that only accepts signed requests. Images get
+// params plus an s parameter: the hex HMAC-SHA256, keyed with secret, of
+// the path, "?" and the encoded params. For example with params w=800,
+// "/public/uploads/x.jpg" becomes
+//
+//	base + "/public/uploads/x.jpg?w=800&s=" + hex(hmac(secret, "/public/uploads/x.jpg?w=800"))
+//
+// Other media is served from base without parameters.
+func SignedMediaURL(base, secret string, params url.Values) func(path string) string {
+	base = strings.TrimSuffix(base, "/")
+	query := params.Encode()
+	return func(p string) string {
+		switch strings.ToLower(path.Ext(p)) {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		default:
+			return base + p
+		}
+		signed := p + "?" + query
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(signed))
+		sep := "&"
+		if query == "" {
+			sep = ""
+		}
+		return base + signed + sep + "s=" + hex.EncodeToString(mac.Sum(nil))
+	}
+}
@@ -0,0 +1,40 @@
+package pubengine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+func TestMediaURL(t *testing.T) {
+	cdn := CDNMediaURL("https://cdn.example.com/")
+	if got := cdn("/public/uploads/x.jpg"); got != "https://cdn.example.com/public/uploads/x.jpg" {
+		t.Errorf("CDNMediaURL = %q", got)
+	}
+	if CDNMediaURL("") != nil {
+		t.Error("CDNMediaURL with an empty base should be nil")
+	}
+
+	signed := SignedMediaURL("https://img.example.com", "secret", url.Values{"w": {"800"}})
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("/public/uploads/x.jpg?w=800"))
+	want := "https://img.example.com/public/uploads/x.jpg?w=800&s=" + hex.EncodeToString(mac.Sum(nil))
+	if got := signed("/public/uploads/x.jpg"); got != want {
+		t.Errorf("SignedMediaURL = %q, want %q", got, want)
+	}
+	if got := signed("/files/slides.pdf"); got != "https://img.example.com/files/slides.pdf" {
+		t.Errorf("SignedMediaURL for a PDF = %q", got)
+	}
+
+	cfg := SiteConfig{Name: "Blog", URL: "https://example.com", MediaURL: cdn}
+	post := BlogPost{Slug: "p", Title: "P", Content: "![a](/public/uploads/a.jpg){}"}
+	if h := HeadMeta(cfg, &post); h.Image != "https://cdn.example.com/public/uploads/a.jpg" {
+		t.Errorf("HeadMeta image = %q", h.Image)
+	}
+	post.OGImage = "https://elsewhere.example/b.jpg"
+	if h := HeadMeta(cfg, &post); h.Image != "https://elsewhere.example/b.jpg" {
+		t.Errorf("HeadMeta absolute image = %q", h.Image)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/eringen/pubengine/markdown"
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
@@ -38,6 +39,11 @@ func (a *App) setupMiddleware() {
 
 	e.Use(middleware.Recover())
 
+	e.Use(a.markdownOptionsMiddleware)
+
+	a.setupBodyLimits()
+	e.Use(a.bodyLimitMiddleware)
+
 	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
 		Level: 5,
 		Skipper: func(c echo.Context) bool {
@@ -55,6 +61,8 @@ func (a *App) setupMiddleware() {
 		HSTSExcludeSubdomains: false,
 	}))
 
+	e.Use(svgUploadPolicyMiddleware)
+
 	e.Use(session.Middleware(a.newSessionStore()))
 
 	e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
@@ -83,14 +91,37 @@ func (a *App) setupMiddleware() {
 			return strings.HasPrefix(path, "/public") ||
 				strings.HasPrefix(path, "/workbench") ||
 				strings.HasPrefix(path, "/api/") ||
+				strings.HasPrefix(path, "/s/") ||
+				strings.HasPrefix(path, "/files/") ||
 				strings.HasPrefix(path, "/admin/analytics/api/") ||
 				strings.HasPrefix(path, "/admin/analytics/fragments/") ||
 				path == "/admin/auth/google/callback" ||
-				path == "/sitemap.xml" || path == "/feed.xml" || path == "/robots.txt"
+				strings.HasPrefix(path, "/.well-known/") ||
+				strings.HasSuffix(path, "/index.md") ||
+				path == "/sitemap.xml" || path == "/feed.xml" || path == "/robots.txt" ||
+				path == "/humans.txt" || path == "/manifest.json" || path == "/llms.txt" ||
+				path == "/readyz"
 		},
 	}))
 
 	e.Use(cacheControlMiddleware)
+
+	if a.translations != nil {
+		e.Use(a.translations.localeMiddleware)
+	}
+}
+
+// svgUploadPolicyMiddleware serves uploaded SVGs with a CSP that blocks
+// scripts and external loads, in case one is opened directly rather than
+// through an <img> tag.
+func svgUploadPolicyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		path := c.Request().URL.Path
+		if strings.HasPrefix(path, uploadsURLPrefix) && strings.HasSuffix(strings.ToLower(path), ".svg") {
+			c.Response().Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox")
+		}
+		return next(c)
+	}
 }
 
 func cacheControlMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -99,9 +130,10 @@ func cacheControlMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		switch {
 		case strings.HasPrefix(path, "/public/"):
 			c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-		case path == "/sitemap.xml" || path == "/feed.xml" || path == "/robots.txt":
+		case path == "/sitemap.xml" || path == "/feed.xml" || path == "/robots.txt",
+			path == "/humans.txt" || path == "/manifest.json" || path == "/llms.txt" || strings.HasPrefix(path, "/.well-known/"):
 			c.Response().Header().Set("Cache-Control", "public, max-age=86400")
-		case strings.HasPrefix(path, "/admin"):
+		case strings.HasPrefix(path, "/admin") || path == "/readyz":
 			c.Response().Header().Set("Cache-Control", "no-store")
 		default:
 			c.Response().Header().Set("Cache-Control", "public, max-age=3600")
@@ -151,3 +183,12 @@ func CsrfToken(c echo.Context) string {
 	token, _ := c.Get(middleware.DefaultCSRFConfig.ContextKey).(string)
 	return token
 }
+
+// markdownOptionsMiddleware puts the app's Markdown rendering options in
+// the request context, where markdown.Markdown components read them.
+func (a *App) markdownOptionsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.SetRequest(c.Request().WithContext(markdown.WithOptions(c.Request().Context(), a.markdownOpts)))
+		return next(c)
+	}
+}
@@ -0,0 +1,312 @@
+package pubengine
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a numbered change to the blog database schema. Migrate runs
+// each migration once, in Version order, and records the last version run
+// in the settings table.
+type Migration struct {
+	Version int    // 1 and up, unique within a list of migrations
+	Name    string // what the migration does, for errors
+	Up      func(db MigrationDB) error
+}
+
+// MigrationDB is the blog database as a Migration sees it. Statements are
+// written for SQLite, with ? placeholders, and adapted to the database the
+// Store uses. Migrations don't run in a transaction, as MySQL can't roll
+// back schema changes anyway, so a migration that fails halfway should be
+// safe to run again.
+type MigrationDB struct {
+	db *sqlDB
+}
+
+// Driver returns the dialect of the database: "sqlite", "postgres" or
+// "mysql".
+func (m MigrationDB) Driver() string {
+	return string(m.db.dialect)
+}
+
+// Exec runs a statement.
+func (m MigrationDB) Exec(query string, args ...any) (sql.Result, error) {
+	return m.db.Exec(query, args...)
+}
+
+// Query runs a query returning rows.
+func (m MigrationDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return m.db.Query(query, args...)
+}
+
+// QueryRow runs a query returning at most one row.
+func (m MigrationDB) QueryRow(query string, args ...any) *sql.Row {
+	return m.db.QueryRow(query, args...)
+}
+
+// CreateTable runs a CREATE TABLE statement, adapting SQLite column types
+// such as INTEGER PRIMARY KEY AUTOINCREMENT to the database.
+func (m MigrationDB) CreateTable(stmt string) error {
+	_, err := m.db.Exec(m.db.dialect.ddl(stmt))
+	return err
+}
+
+// AddColumn adds a column, given as e.g. "views INTEGER NOT NULL DEFAULT 0",
+// to table unless it exists, so that a migration which failed after adding
+// it can run again.
+func (m MigrationDB) AddColumn(table, column string) error {
+	return m.db.addColumn(table, column)
+}
+
+// CreateIndex creates an index on columns of table unless it exists.
+func (m MigrationDB) CreateIndex(name, table, columns string) error {
+	return m.db.createIndex(name, table, columns)
+}
+
+// Settings keys of the schema versions Migrate has reached.
+const (
+	schemaVersionKey    = "schema_version"
+	appSchemaVersionKey = "app_schema_version"
+)
+
+// storeMigrations returns pubengine's own migrations of the blog
+// database. Append new ones; never change or renumber one that shipped.
+func (s *Store) storeMigrations() []Migration {
+	return []Migration{
+		// Databases from before migrations were numbered may be missing
+		// any later table or column, so the baseline creates whatever is
+		// missing.
+		{1, "baseline schema", func(MigrationDB) error { return s.baselineSchema() }},
+		{2, "syndications", func(db MigrationDB) error {
+			return db.CreateTable(`
+CREATE TABLE IF NOT EXISTS syndications (
+    slug TEXT NOT NULL,
+    service TEXT NOT NULL,
+    status TEXT NOT NULL,
+    remote_id TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (slug, service)
+);
+`)
+		}},
+		{3, "link previews", func(db MigrationDB) error {
+			return db.CreateTable(`
+CREATE TABLE IF NOT EXISTS link_previews (
+    url_hash TEXT PRIMARY KEY,
+    url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    fetched_at TEXT NOT NULL
+);
+`)
+		}},
+		{4, "post reading time and image", func(db MigrationDB) error {
+			// Kept so posts can be listed without their content.
+			if err := db.AddColumn("posts", "reading_minutes INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := db.AddColumn("posts", "content_image TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return s.backfillPostStats()
+		}},
+		{5, "post versions", func(db MigrationDB) error {
+			return db.AddColumn("posts", "version INTEGER NOT NULL DEFAULT 0")
+		}},
+		{6, "post search", func(MigrationDB) error { return s.createSearchIndex() }},
+		{7, "scheduled posts", func(db MigrationDB) error {
+			if err := db.AddColumn("posts", "publish_at TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return db.CreateIndex("idx_posts_publish_at", "posts", "publish_at")
+		}},
+		{8, "post revisions", func(db MigrationDB) error {
+			if err := db.CreateTable(`
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    date TEXT NOT NULL,
+    tags TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+`); err != nil {
+				return err
+			}
+			if err := db.CreateIndex("idx_post_revisions_slug", "post_revisions", "slug"); err != nil {
+				return err
+			}
+			return db.AddColumn("draft_comments", "revision_id INTEGER NOT NULL DEFAULT 0")
+		}},
+		{9, "comments", func(db MigrationDB) error {
+			if err := db.CreateTable(`
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL,
+    name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    approved INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+`); err != nil {
+				return err
+			}
+			return db.CreateIndex("idx_comments_slug", "comments", "slug, approved")
+		}},
+		{10, "newsletter subscribers", func(db MigrationDB) error {
+			if err := db.CreateTable(`
+CREATE TABLE IF NOT EXISTS subscribers (
+    email TEXT PRIMARY KEY,
+    token TEXT NOT NULL,
+    subscribed_at TEXT NOT NULL
+);
+`); err != nil {
+				return err
+			}
+			return db.CreateIndex("idx_subscribers_token", "subscribers", "token")
+		}},
+	}
+}
+
+// postStatsBatch is how many posts an UPDATE of backfillPostStats sets,
+// keeping its arguments under every database's limit on placeholders.
+const postStatsBatch = 500
+
+// backfillPostStats sets the reading time and first image of every post,
+// in one transaction with an UPDATE per batch of posts.
+func (s *Store) backfillPostStats() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT slug, content FROM posts`)
+	if err != nil {
+		return err
+	}
+	var slugs, contents []string
+	for rows.Next() {
+		var slug, content string
+		if err := rows.Scan(&slug, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		slugs = append(slugs, slug)
+		contents = append(contents, content)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for start := 0; start < len(slugs); start += postStatsBatch {
+		end := min(start+postStatsBatch, len(slugs))
+		var minutes, images, in strings.Builder
+		var minuteArgs, imageArgs, inArgs []any
+		for i := start; i < end; i++ {
+			minutes.WriteString(" WHEN ? THEN ?")
+			minuteArgs = append(minuteArgs, slugs[i], ReadingMinutes(contents[i]))
+			images.WriteString(" WHEN ? THEN ?")
+			imageArgs = append(imageArgs, slugs[i], firstImage(contents[i]))
+			if i > start {
+				in.WriteString(", ")
+			}
+			in.WriteString("?")
+			inArgs = append(inArgs, slugs[i])
+		}
+		query := `UPDATE posts SET reading_minutes = CASE slug` + minutes.String() + ` END, content_image = CASE slug` +
+			images.String() + ` END WHERE slug IN (` + in.String() + `)`
+		args := append(append(minuteArgs, imageArgs...), inArgs...)
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Migrate brings the blog database schema up to date, then runs the
+// migrations of the app using pubengine that haven't run yet. The app's
+// migrations are versioned separately from pubengine's, under the
+// app_schema_version setting, so they can number theirs from 1. NewStore
+// and OpenStore run pubengine's migrations; WithMigrations runs an app's
+// at Setup.
+func (s *Store) Migrate(migrations ...Migration) error {
+	// The versions are kept in settings, which has to exist to read them.
+	if _, err := s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+`)); err != nil {
+		return err
+	}
+	if err := s.runMigrations(schemaVersionKey, s.storeMigrations()); err != nil {
+		return err
+	}
+	return s.runMigrations(appSchemaVersionKey, migrations)
+}
+
+// runMigrations runs the migrations above the version recorded under the
+// settings key, recording the version after each.
+func (s *Store) runMigrations(key string, migrations []Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	migrations = append([]Migration(nil), migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version < 1 || m.Up == nil {
+			return fmt.Errorf("migration %d %q: needs a version of 1 or more and an Up function", m.Version, m.Name)
+		}
+		if i > 0 && migrations[i-1].Version == m.Version {
+			return fmt.Errorf("migration %d: used by %q and %q", m.Version, migrations[i-1].Name, m.Name)
+		}
+	}
+
+	verStr, err := s.GetSetting(key)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", key, err)
+	}
+	version := 0
+	if verStr != "" {
+		if version, err = strconv.Atoi(verStr); err != nil {
+			return fmt.Errorf("parse %s %q: %w", key, verStr, err)
+		}
+	}
+	if latest := migrations[len(migrations)-1].Version; version > latest {
+		return fmt.Errorf("%s %d is newer than the latest migration, %d; was the database used by a newer version?", key, version, latest)
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Up(MigrationDB{s.db}); err != nil {
+			return fmt.Errorf("migration %d %q: %w", m.Version, m.Name, err)
+		}
+		version = m.Version
+		if err := s.SetSetting(key, strconv.Itoa(version)); err != nil {
+			return fmt.Errorf("record %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// WithMigrations registers the app's own migrations of the blog database,
+// e.g. for tables used by custom routes. Setup runs those that haven't run
+// yet, after pubengine's.
+func WithMigrations(migrations ...Migration) Option {
+	return func(a *App) {
+		a.migrations = append(a.migrations, migrations...)
+	}
+}
@@ -0,0 +1,219 @@
+package pubengine
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxEmailLength is how long a subscriber's email address can be.
+const maxEmailLength = 254
+
+// NewsletterPage is what the Newsletter view shows: the signup form, the
+// outcome of a signup, or the confirmation of an unsubscribe link.
+type NewsletterPage struct {
+	Message     string // outcome of the last submission, "" for the plain form
+	Unsubscribe string // token of an unsubscribe link to confirm, "" otherwise
+}
+
+// subscriberColumns are the subscribers columns read by scanSubscriber, in
+// order.
+const subscriberColumns = "email, token, subscribed_at"
+
+func scanSubscriber(row interface{ Scan(...any) error }) (Subscriber, error) {
+	var sub Subscriber
+	err := row.Scan(&sub.Email, &sub.Token, &sub.SubscribedAt)
+	return sub, err
+}
+
+// AddSubscriber subscribes an email address, lowercased, to the newsletter
+// and returns the subscriber. Subscribing an address again returns the
+// existing subscriber.
+func (s *Store) AddSubscriber(email string) (Subscriber, error) {
+	email = strings.ToLower(email)
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return Subscriber{}, err
+	}
+	if _, err := s.db.Exec(`INSERT INTO subscribers (email, token, subscribed_at) VALUES (?, ?, ?) ON CONFLICT(email) DO NOTHING`,
+		email, hex.EncodeToString(token), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return Subscriber{}, err
+	}
+	return scanSubscriber(s.db.QueryRow(`SELECT `+subscriberColumns+` FROM subscribers WHERE email = ?`, email))
+}
+
+// ListSubscribers returns the newsletter subscribers, oldest first.
+func (s *Store) ListSubscribers() ([]Subscriber, error) {
+	rows, err := s.db.Query(`SELECT ` + subscriberColumns + ` FROM subscribers ORDER BY subscribed_at, email`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []Subscriber
+	for rows.Next() {
+		sub, err := scanSubscriber(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, sub)
+	}
+	return subscribers, rows.Err()
+}
+
+// GetSubscriberByToken returns the subscriber with an unsubscribe token. It
+// returns sql.ErrNoRows if there is none.
+func (s *Store) GetSubscriberByToken(token string) (Subscriber, error) {
+	return scanSubscriber(s.db.QueryRow(`SELECT `+subscriberColumns+` FROM subscribers WHERE token = ?`, token))
+}
+
+// DeleteSubscriber unsubscribes an email address.
+func (s *Store) DeleteSubscriber(email string) error {
+	_, err := s.db.Exec(`DELETE FROM subscribers WHERE email = ?`, strings.ToLower(email))
+	return err
+}
+
+// UnsubscribeURL returns the absolute URL of the page where a subscriber
+// unsubscribes, for the newsletters sent to them.
+func (a *App) UnsubscribeURL(sub Subscriber) string {
+	return strings.TrimSuffix(a.Config.URL, "/") + "/newsletter/unsubscribe/?token=" + url.QueryEscape(sub.Token)
+}
+
+// validEmail reports whether email is a plain address, without a display
+// name, short enough to store.
+func validEmail(email string) bool {
+	if len(email) > maxEmailLength {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func (a *App) handleNewsletter(c echo.Context) error {
+	if a.Views.Newsletter == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return a.renderNewsletter(c, NewsletterPage{})
+}
+
+// handleNewsletterSubscribe subscribes the submitted email address.
+// Submissions filling in the hidden website field are taken for spam and
+// dropped as if subscribed.
+func (a *App) handleNewsletterSubscribe(c echo.Context) error {
+	if a.Views.Newsletter == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	ip := c.RealIP()
+	if !a.formLimiter.Check(ip) {
+		return c.String(http.StatusTooManyRequests, "Too many submissions. Try again later.")
+	}
+	a.formLimiter.Record(ip)
+	email := strings.TrimSpace(c.FormValue("email"))
+	if !validEmail(email) {
+		return a.renderNewsletter(c, NewsletterPage{Message: "Please enter a valid email address."})
+	}
+	if c.FormValue("website") == "" {
+		if _, err := a.Store.AddSubscriber(email); err != nil {
+			return err
+		}
+	}
+	return a.renderNewsletter(c, NewsletterPage{Message: "Thanks for subscribing!"})
+}
+
+// handleNewsletterUnsubscribe asks to confirm an unsubscribe link, so that
+// mail scanners following it don't unsubscribe anyone.
+func (a *App) handleNewsletterUnsubscribe(c echo.Context) error {
+	if a.Views.Newsletter == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	token := c.QueryParam("token")
+	if _, err := a.Store.GetSubscriberByToken(token); err == sql.ErrNoRows {
+		return a.renderNewsletter(c, NewsletterPage{Message: "This unsubscribe link is no longer valid. You may already be unsubscribed."})
+	} else if err != nil {
+		return err
+	}
+	return a.renderNewsletter(c, NewsletterPage{Unsubscribe: token})
+}
+
+func (a *App) handleNewsletterUnsubscribeConfirm(c echo.Context) error {
+	if a.Views.Newsletter == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	sub, err := a.Store.GetSubscriberByToken(c.FormValue("token"))
+	if err == nil {
+		err = a.Store.DeleteSubscriber(sub.Email)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return a.renderNewsletter(c, NewsletterPage{Message: "You're unsubscribed."})
+}
+
+func (a *App) renderNewsletter(c echo.Context, page NewsletterPage) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return Render(c, a.Views.Newsletter(page, CsrfToken(c)))
+}
+
+func (a *App) handleAdminSubscribers(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderSubscribers(c)
+}
+
+// handleAdminSubscribersExport downloads the subscribers as CSV with their
+// unsubscribe URLs, for importing into a mail service.
+func (a *App) handleAdminSubscribersExport(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminSubscribers == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	subscribers, err := a.Store.ListSubscribers()
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="subscribers.csv"`)
+	w := csv.NewWriter(c.Response())
+	if err := w.Write([]string{"email", "subscribed_at", "unsubscribe_url"}); err != nil {
+		return err
+	}
+	for _, sub := range subscribers {
+		if err := w.Write([]string{sub.Email, sub.SubscribedAt, a.UnsubscribeURL(sub)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (a *App) handleAdminSubscriberDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if err := a.Store.DeleteSubscriber(c.FormValue("email")); err != nil {
+		return err
+	}
+	return a.renderSubscribers(c)
+}
+
+func (a *App) renderSubscribers(c echo.Context) error {
+	if a.Views.AdminSubscribers == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	subscribers, err := a.Store.ListSubscribers()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminSubscribers(subscribers, CsrfToken(c)))
+}
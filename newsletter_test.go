@@ -0,0 +1,140 @@
+package pubengine
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestSubscribers(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	ann, err := s.AddSubscriber("Ann@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.Email != "ann@example.com" || len(ann.Token) != 32 || ann.SubscribedAt == "" {
+		t.Errorf("AddSubscriber() = %+v", ann)
+	}
+	if again, err := s.AddSubscriber("ann@example.com"); err != nil || again != ann {
+		t.Errorf("AddSubscriber() again = %+v, %v, want %+v", again, err, ann)
+	}
+	if _, err := s.AddSubscriber("bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if subs, err := s.ListSubscribers(); err != nil || len(subs) != 2 {
+		t.Errorf("ListSubscribers() = %+v, %v", subs, err)
+	}
+	if sub, err := s.GetSubscriberByToken(ann.Token); err != nil || sub.Email != ann.Email {
+		t.Errorf("GetSubscriberByToken() = %+v, %v", sub, err)
+	}
+	if err := s.DeleteSubscriber("ANN@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSubscriberByToken(ann.Token); err != ErrNotFound {
+		t.Errorf("GetSubscriberByToken() after unsubscribing = %v, want ErrNotFound", err)
+	}
+}
+
+func TestValidEmail(t *testing.T) {
+	for email, want := range map[string]bool{
+		"ann@example.com":             true,
+		"ann+news@mail.example.co.uk": true,
+		"":                            false,
+		"ann":                         false,
+		"Ann <ann@example.com>":       false,
+		"ann@example.com, bob@x.org":  false,
+		strings.Repeat("a", 250) + "@example.com": false,
+	} {
+		if got := validEmail(email); got != want {
+			t.Errorf("validEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestNewsletterHandlers(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.URL = "https://example.com/"
+	app.Views.Newsletter = func(page NewsletterPage, _ string) templ.Component {
+		return templ.Raw(page.Message + "|" + page.Unsubscribe)
+	}
+	app.Views.AdminSubscribers = func(subs []Subscriber, _ string) templ.Component {
+		var emails []string
+		for _, s := range subs {
+			emails = append(emails, s.Email)
+		}
+		return templ.Raw(strings.Join(emails, ","))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	subscribe := func(form url.Values) string {
+		rec := adminRequest(app, nil, http.MethodPost, "/newsletter/", form)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("subscribe = %d %q", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	if rec := serveGet(app, "/newsletter/"); rec.Code != http.StatusOK || rec.Body.String() != "|" {
+		t.Errorf("GET /newsletter/ = %d %q", rec.Code, rec.Body.String())
+	}
+	if got := subscribe(url.Values{"email": {"not an address"}}); !strings.HasPrefix(got, "Please enter") {
+		t.Errorf("invalid subscription = %q", got)
+	}
+	if got := subscribe(url.Values{"email": {"ann@example.com"}}); got != "Thanks for subscribing!|" {
+		t.Errorf("subscription = %q", got)
+	}
+	subscribe(url.Values{"email": {"spam@example.com"}, "website": {"x"}})
+	subs, err := app.Store.ListSubscribers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Email != "ann@example.com" {
+		t.Fatalf("subscribers = %+v, want ann only", subs)
+	}
+	ann := subs[0]
+	if got, want := app.UnsubscribeURL(ann), "https://example.com/newsletter/unsubscribe/?token="+ann.Token; got != want {
+		t.Errorf("UnsubscribeURL() = %q, want %q", got, want)
+	}
+
+	session := loginAdmin(t, app)
+	if rec := adminRequest(app, session, http.MethodGet, "/admin/subscribers/", nil); rec.Body.String() != "ann@example.com" {
+		t.Errorf("admin subscribers = %q", rec.Body.String())
+	}
+	rec := adminRequest(app, session, http.MethodGet, "/admin/subscribers/export/", nil)
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != "ann@example.com" || records[1][2] != app.UnsubscribeURL(ann) {
+		t.Errorf("export = %q", records)
+	}
+
+	// Following an unsubscribe link asks to confirm.
+	if rec := serveGet(app, "/newsletter/unsubscribe/?token="+ann.Token); rec.Body.String() != "|"+ann.Token {
+		t.Errorf("unsubscribe link = %q", rec.Body.String())
+	}
+	if _, err := app.Store.GetSubscriberByToken(ann.Token); err != nil {
+		t.Errorf("following the link unsubscribed: %v", err)
+	}
+	rec = adminRequest(app, nil, http.MethodPost, "/newsletter/unsubscribe/", url.Values{"token": {ann.Token}})
+	if rec.Code != http.StatusOK || rec.Body.String() != "You're unsubscribed.|" {
+		t.Errorf("unsubscribe = %d %q", rec.Code, rec.Body.String())
+	}
+	if subs, _ := app.Store.ListSubscribers(); len(subs) != 0 {
+		t.Errorf("subscribers after unsubscribing = %+v", subs)
+	}
+	if rec := serveGet(app, "/newsletter/unsubscribe/?token="+ann.Token); !strings.Contains(rec.Body.String(), "no longer valid") {
+		t.Errorf("used unsubscribe link = %q", rec.Body.String())
+	}
+
+	app = newSetUpTestApp(t)
+	if rec := serveGet(app, "/newsletter/"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /newsletter/ without a Newsletter view = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,92 @@
+package pubengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/a-h/templ"
+	"github.com/eringen/pubengine/markdown"
+	"github.com/labstack/echo/v4"
+)
+
+// PlainURL returns the URL of a post's plain version, e.g.
+// "https://example.com/blog/my-post/plain/".
+func PlainURL(base, slug string) string {
+	return BuildURL(base, "blog", slug, "plain")
+}
+
+// handlePlainPost renders a published post with the PostPlain view, or with
+// PlainPost when the theme doesn't provide one.
+func (a *App) handlePlainPost(c echo.Context) error {
+	post, err := a.Cache.GetPost(c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	setNoIndex(c, post)
+	if a.Views.PostPlain != nil {
+		return Render(c, a.Views.PostPlain(post, a.Config.URL))
+	}
+	return Render(c, PlainPost(post, a.Config))
+}
+
+// PlainPost renders a post as a standalone page of minimal semantic HTML:
+// no navigation, no scripts and a few lines of inline CSS. It suits
+// printing, reader apps and text browsers, and is the default for the
+// /blog/:slug/plain/ route.
+func PlainPost(post BlogPost, cfg SiteConfig) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		canonical := post.CanonicalLink(cfg)
+		byline := ""
+		if name := post.Byline(cfg); name != "" {
+			byline = " · " + name
+		}
+		head := Head{Title: post.Title, SiteName: cfg.Name}
+		if _, err := fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<link rel="canonical" href="%s">
+<style>body{max-width:40em;margin:2em auto;padding:0 1em;font:1.1em/1.6 Georgia,serif}img{max-width:100%%;height:auto}pre{white-space:pre-wrap}</style>
+</head>
+<body>
+<article>
+<header>
+<h1>%s</h1>
+<p><time datetime="%s">%s</time>%s</p>
+</header>
+`,
+			html.EscapeString(Lang(ctx)),
+			html.EscapeString(head.DocumentTitle()),
+			html.EscapeString(canonical),
+			html.EscapeString(post.Title),
+			html.EscapeString(post.Date),
+			html.EscapeString(post.Date),
+			html.EscapeString(byline),
+		); err != nil {
+			return err
+		}
+		if err := markdown.Markdown(post.Content).Render(ctx, w); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, `<footer>
+<p>%s: <a href="%s">%s</a></p>
+</footer>
+</article>
+</body>
+</html>
+`,
+			html.EscapeString(cfg.Name),
+			html.EscapeString(canonical),
+			html.EscapeString(canonical),
+		)
+		return err
+	})
+}
@@ -0,0 +1,39 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPlainPost(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Content: "**Hi** there", Published: true})
+	savePosts(t, app, BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-02"})
+
+	rec := serveGet(app, "/blog/hello/plain/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /blog/hello/plain/ = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<title>Hello | ",
+		`<link rel="canonical" href="http://localhost:3000/blog/hello/">`,
+		"<h1>Hello</h1>",
+		"<strong>Hi</strong>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script") || strings.Contains(body, "<nav") {
+		t.Errorf("plain page has scripts or navigation:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	app.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blog/draft/plain/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /blog/draft/plain/ = %d, want 404", rec.Code)
+	}
+}
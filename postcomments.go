@@ -0,0 +1,205 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxCommentNameLength is how long a commenter's name can be, in
+// characters. Comment bodies share maxCommentLength with draft comments.
+const maxCommentNameLength = 80
+
+// commentColumns are the comments columns read by scanComment, in order.
+const commentColumns = "id, slug, name, body, approved, created_at"
+
+func scanComment(row interface{ Scan(...any) error }) (Comment, error) {
+	var c Comment
+	var approved int
+	if err := row.Scan(&c.ID, &c.Slug, &c.Name, &c.Body, &approved, &c.CreatedAt); err != nil {
+		return Comment{}, err
+	}
+	c.Approved = approved == 1
+	return c, nil
+}
+
+// queryComments returns the comments a query selecting commentColumns
+// finds.
+func (s *Store) queryComments(query string, args ...any) ([]Comment, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// AddComment stores a reader's comment and returns its ID. CreatedAt is
+// set when empty.
+func (s *Store) AddComment(c Comment) (int64, error) {
+	if c.CreatedAt == "" {
+		c.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	approved := 0
+	if c.Approved {
+		approved = 1
+	}
+	return s.db.insert(`INSERT INTO comments (slug, name, body, approved, created_at) VALUES (?, ?, ?, ?, ?)`,
+		c.Slug, c.Name, c.Body, approved, c.CreatedAt)
+}
+
+// ListComments returns the approved comments on a post, oldest first.
+func (s *Store) ListComments(slug string) ([]Comment, error) {
+	return s.queryComments(`SELECT `+commentColumns+` FROM comments WHERE slug = ? AND approved = 1 ORDER BY id`, slug)
+}
+
+// ListPendingComments returns the comments waiting for approval on every
+// post, oldest first.
+func (s *Store) ListPendingComments() ([]Comment, error) {
+	return s.queryComments(`SELECT ` + commentColumns + ` FROM comments WHERE approved = 0 ORDER BY id`)
+}
+
+// ApproveComment shows a comment on its post. It returns sql.ErrNoRows if
+// there is no comment with the ID.
+func (s *Store) ApproveComment(id int64) error {
+	res, err := s.db.Exec(`UPDATE comments SET approved = 1 WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteComment removes a comment, approved or not.
+func (s *Store) DeleteComment(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	return err
+}
+
+// handleComments renders the Comments view with the approved comments on
+// a published post and a form for a new one. It is loaded into the post
+// page, so the cached page doesn't carry a CSRF token.
+func (a *App) handleComments(c echo.Context) error {
+	if a.Views.Comments == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return a.renderPostComments(c, c.Param("slug"), "")
+}
+
+// handleCommentSubmit stores a reader's comment for approval. Submissions
+// filling in the hidden website field are taken for spam and dropped as if
+// stored.
+func (a *App) handleCommentSubmit(c echo.Context) error {
+	if a.Views.Comments == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	slug := c.Param("slug")
+	if _, err := a.Cache.GetPost(slug); err == ErrNotFound {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	ip := c.RealIP()
+	if !a.formLimiter.Check(ip) {
+		return c.String(http.StatusTooManyRequests, "Too many submissions. Try again later.")
+	}
+	a.formLimiter.Record(ip)
+	name := strings.TrimSpace(c.FormValue("name"))
+	body := strings.TrimSpace(c.FormValue("body"))
+	if name == "" || body == "" {
+		return a.renderPostComments(c, slug, "Please enter your name and a comment.")
+	}
+	if utf8.RuneCountInString(name) > maxCommentNameLength {
+		return a.renderPostComments(c, slug, "Your name is too long (max 80 characters).")
+	}
+	if len(body) > maxCommentLength {
+		return a.renderPostComments(c, slug, "Your comment is too long (max 2000 characters).")
+	}
+	if c.FormValue("website") == "" {
+		if _, err := a.Store.AddComment(Comment{Slug: slug, Name: name, Body: body}); err != nil {
+			return err
+		}
+	}
+	return a.renderPostComments(c, slug, "Thanks! Your comment will appear once it's approved.")
+}
+
+func (a *App) renderPostComments(c echo.Context, slug, message string) error {
+	post, err := a.Cache.GetPost(slug)
+	if err == ErrNotFound {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	comments, err := a.Store.ListComments(slug)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return Render(c, a.Views.Comments(post, comments, message, CsrfToken(c)))
+}
+
+func (a *App) handleAdminModeration(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderModeration(c)
+}
+
+func (a *App) handleAdminCommentApprove(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.ApproveComment(id); err == sql.ErrNoRows {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	return a.renderModeration(c)
+}
+
+func (a *App) handleAdminCommentDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := a.Store.DeleteComment(id); err != nil {
+		return err
+	}
+	return a.renderModeration(c)
+}
+
+func (a *App) renderModeration(c echo.Context) error {
+	if a.Views.AdminModeration == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	pending, err := a.Store.ListPendingComments()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminModeration(pending, CsrfToken(c)))
+}
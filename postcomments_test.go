@@ -0,0 +1,151 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestComments(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	for _, c := range []Comment{
+		{Slug: "post", Name: "Ann", Body: "First!", Approved: true},
+		{Slug: "post", Name: "Bob", Body: "Pending."},
+		{Slug: "other", Name: "Cy", Body: "Elsewhere.", Approved: true},
+	} {
+		if _, err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	comments, err := s.ListComments("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Name != "Ann" || comments[0].CreatedAt == "" {
+		t.Fatalf("ListComments() = %+v, want Ann's approved comment", comments)
+	}
+	pending, err := s.ListPendingComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Name != "Bob" || pending[0].Approved {
+		t.Fatalf("ListPendingComments() = %+v, want Bob's", pending)
+	}
+	if err := s.ApproveComment(pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApproveComment(999); err != ErrNotFound {
+		t.Errorf("ApproveComment(missing) = %v, want ErrNotFound", err)
+	}
+	if comments, _ := s.ListComments("post"); len(comments) != 2 || comments[1].Name != "Bob" {
+		t.Errorf("comments after approval = %+v", comments)
+	}
+
+	// Comments follow their post.
+	if err := s.SavePost(BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenamePost("post", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if comments, _ := s.ListComments("renamed"); len(comments) != 2 {
+		t.Errorf("%d comments after a rename, want 2", len(comments))
+	}
+	if err := s.DeletePost("renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if comments, _ := s.ListComments("renamed"); len(comments) != 0 {
+		t.Errorf("%d comments after a delete, want none", len(comments))
+	}
+	if err := s.DeleteComment(comments[0].ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommentHandlers(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.Comments = func(post BlogPost, comments []Comment, message, _ string) templ.Component {
+		var names []string
+		for _, c := range comments {
+			names = append(names, c.Name)
+		}
+		return templ.Raw(post.Title + ": " + strings.Join(names, ",") + " " + message)
+	}
+	app.Views.AdminModeration = func(pending []Comment, _ string) templ.Component {
+		var ids []string
+		for _, c := range pending {
+			ids = append(ids, strconv.FormatInt(c.ID, 10))
+		}
+		return templ.Raw(strings.Join(ids, ","))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Published: true},
+		BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-01"},
+	)
+	submit := func(slug string, form url.Values) (int, string) {
+		rec := adminRequest(app, nil, http.MethodPost, "/blog/"+slug+"/comments/", form)
+		return rec.Code, rec.Body.String()
+	}
+
+	rec := serveGet(app, "/blog/post/comments/")
+	if rec.Code != http.StatusOK || rec.Body.String() != "Post:  " || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("GET comments = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if rec := serveGet(app, "/blog/draft/comments/"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET comments on a draft = %d, want 404", rec.Code)
+	}
+	if code, _ := submit("draft", url.Values{"name": {"Ann"}, "body": {"Hi"}}); code != http.StatusNotFound {
+		t.Errorf("comment on a draft = %d, want 404", code)
+	}
+	if code, body := submit("post", url.Values{"name": {"Ann"}}); code != http.StatusOK || !strings.Contains(body, "Please enter") {
+		t.Errorf("comment without a body = %d %q", code, body)
+	}
+	if code, body := submit("post", url.Values{"name": {"Ann"}, "body": {"Nice post."}}); code != http.StatusOK || !strings.Contains(body, "once it's approved") {
+		t.Errorf("comment = %d %q", code, body)
+	}
+	if code, _ := submit("post", url.Values{"name": {"Spam"}, "body": {"Buy now"}, "website": {"http://spam.example"}}); code != http.StatusOK {
+		t.Errorf("spam comment = %d, want it to look accepted", code)
+	}
+
+	// Comments wait for approval.
+	if rec := serveGet(app, "/blog/post/comments/"); rec.Body.String() != "Post:  " {
+		t.Errorf("comments before approval = %q", rec.Body.String())
+	}
+	session := loginAdmin(t, app)
+	rec = adminRequest(app, session, http.MethodGet, "/admin/moderation/", nil)
+	pending := rec.Body.String()
+	if rec.Code != http.StatusOK || pending == "" || strings.Contains(pending, ",") {
+		t.Fatalf("moderation = %d %q, want one pending comment", rec.Code, pending)
+	}
+	if rec := adminRequest(app, nil, http.MethodPost, "/admin/moderation/"+pending+"/approve/", nil); rec.Code != http.StatusSeeOther {
+		t.Errorf("approve without a session = %d, want a redirect", rec.Code)
+	}
+	if rec := adminRequest(app, session, http.MethodPost, "/admin/moderation/"+pending+"/approve/", nil); rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Errorf("approve = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serveGet(app, "/blog/post/comments/"); rec.Body.String() != "Post: Ann " {
+		t.Errorf("comments after approval = %q", rec.Body.String())
+	}
+
+	// Submissions are rate limited per IP.
+	for i := 0; i < 2; i++ {
+		submit("post", url.Values{"name": {"Ann"}, "body": {"Again."}})
+	}
+	if code, _ := submit("post", url.Values{"name": {"Ann"}, "body": {"Again."}}); code != http.StatusTooManyRequests {
+		t.Errorf("sixth comment = %d, want 429", code)
+	}
+
+	app = newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Published: true})
+	if rec := serveGet(app, "/blog/post/comments/"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET comments without a Comments view = %d, want 404", rec.Code)
+	}
+}
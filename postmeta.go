@@ -0,0 +1,93 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxMetaKeyLen caps the length of a custom field name.
+const maxMetaKeyLen = 64
+
+// MetaKeys returns the names of the post's custom fields in sorted order,
+// for rendering Meta in a stable order.
+func (p BlogPost) MetaKeys() []string {
+	keys := make([]string, 0, len(p.Meta))
+	for k := range p.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validMetaKey reports whether key can name a custom field: 1 to 64
+// lowercase letters, digits, underscores and hyphens, so it survives the
+// frontmatter round trip.
+func validMetaKey(key string) bool {
+	if key == "" || len(key) > maxMetaKeyLen {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMetaForm pairs the meta_key and meta_value fields of the admin post
+// form into custom fields. Rows with an empty key are skipped, and a later
+// row wins over an earlier one with the same key. It returns a message for
+// the admin instead when a key is invalid.
+func parseMetaForm(keys, values []string) (map[string]string, string) {
+	var meta map[string]string
+	for i, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if !validMetaKey(k) {
+			return nil, fmt.Sprintf("Custom field %q: use up to %d lowercase letters, digits, underscores and hyphens.", k, maxMetaKeyLen)
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[k] = ""
+		if i < len(values) {
+			meta[k] = values[i]
+		}
+	}
+	return meta, ""
+}
+
+// parsePostMeta decodes the JSON object postColumns selects from
+// post_meta, returning nil for a post without custom fields.
+func parsePostMeta(data string) (map[string]string, error) {
+	if data == "" || data == "{}" {
+		return nil, nil
+	}
+	var meta map[string]string
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// savePostMeta replaces the custom fields of the post slug with meta.
+func (s *Store) savePostMeta(slug string, meta map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM post_meta WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	for k, v := range meta {
+		if _, err := tx.Exec(`INSERT INTO post_meta (slug, key, value) VALUES (?, ?, ?)`, slug, k, v); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
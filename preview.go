@@ -0,0 +1,85 @@
+package pubengine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PreviewToken returns a token that shows the post slug, even as a draft,
+// at /blog/:slug/?preview=TOKEN until expires, without logging in. It is the
+// expiry in Unix seconds and a base64 HMAC-SHA256 of the slug and expiry,
+// keyed with SessionSecret, so changing the secret revokes every link.
+func (a *App) PreviewToken(slug string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + a.previewSignature(slug, exp)
+}
+
+// PreviewURL returns a draft preview link for slug that works for
+// SiteConfig.PreviewTTL.
+func (a *App) PreviewURL(slug string) string {
+	token := a.PreviewToken(slug, time.Now().Add(a.Config.PreviewTTL))
+	return BuildURL(a.Config.URL, "blog", slug) + "?preview=" + token
+}
+
+func (a *App) previewSignature(slug, exp string) string {
+	mac := hmac.New(sha256.New, []byte(a.Config.SessionSecret))
+	mac.Write([]byte("preview\x00" + slug + "\x00" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validPreviewToken reports whether token is an unexpired preview token
+// for slug.
+func (a *App) validPreviewToken(slug, token string) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(a.previewSignature(slug, exp)))
+}
+
+// previewPost returns the post a valid ?preview= token on the request
+// shows, drafts included, or sql.ErrNoRows. Preview responses are kept out
+// of caches and search engines.
+func (a *App) previewPost(c echo.Context, slug string) (BlogPost, error) {
+	token := c.QueryParam("preview")
+	if token == "" || !a.validPreviewToken(slug, token) {
+		return BlogPost{}, sql.ErrNoRows
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	if post.TrashedAt != "" {
+		return BlogPost{}, sql.ErrNoRows
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("X-Robots-Tag", "noindex")
+	return post, nil
+}
+
+// handlePreviewLink returns a draft preview link for the post as text.
+func (a *App) handlePreviewLink(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	slug := c.Param("slug")
+	if _, err := a.Content.GetPostAny(slug); err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	return c.String(http.StatusOK, a.PreviewURL(slug))
+}
@@ -0,0 +1,46 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDraftPreview(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "draft", Title: "Secret Draft", Date: "2024-01-01"})
+
+	if rec := serveGet(app, "/blog/draft/"); rec.Code != http.StatusNotFound {
+		t.Errorf("draft without a token = %d, want 404", rec.Code)
+	}
+	link := app.PreviewURL("draft")
+	if !strings.HasPrefix(link, "http://localhost:3000/blog/draft/?preview=") {
+		t.Fatalf("PreviewURL = %q", link)
+	}
+	rec := serveGet(app, strings.TrimPrefix(link, "http://localhost:3000"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "Secret Draft" {
+		t.Errorf("draft with a token = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("X-Robots-Tag") != "noindex" {
+		t.Errorf("preview headers: Cache-Control %q, X-Robots-Tag %q", rec.Header().Get("Cache-Control"), rec.Header().Get("X-Robots-Tag"))
+	}
+
+	for name, token := range map[string]string{
+		"expired":    app.PreviewToken("draft", time.Now().Add(-time.Minute)),
+		"other slug": app.PreviewToken("other", time.Now().Add(time.Hour)),
+		"tampered":   strings.Replace(app.PreviewToken("draft", time.Now().Add(time.Hour)), ".", "9.", 1),
+		"garbage":    "nope",
+	} {
+		if rec := serveGet(app, "/blog/draft/?preview="+url.QueryEscape(token)); rec.Code != http.StatusNotFound {
+			t.Errorf("%s token = %d, want 404", name, rec.Code)
+		}
+	}
+	if err := app.Store.TrashPost("draft"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveGet(app, strings.TrimPrefix(link, "http://localhost:3000")); rec.Code != http.StatusNotFound {
+		t.Errorf("trashed draft with a token = %d, want 404", rec.Code)
+	}
+}
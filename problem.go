@@ -0,0 +1,92 @@
+package pubengine
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mimeProblemJSON is the media type of RFC 7807 problem details.
+const mimeProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body, sent for errors on API
+// routes and to clients that ask for JSON.
+type Problem struct {
+	Type     string `json:"type"`  // always "about:blank": Title is the status text
+	Title    string `json:"title"` // e.g. "Not Found"
+	Status   int    `json:"status"`
+	Code     string `json:"code"`             // stable machine readable code, see ProblemCode
+	Detail   string `json:"detail,omitempty"` // explanation of this occurrence, never set for 5xx errors
+	Instance string `json:"instance,omitempty"`
+}
+
+// problemCodes are the Problem codes of common statuses. Clients can rely
+// on them not changing.
+var problemCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusRequestTimeout:        "request_timeout",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// ProblemCode returns the Problem code for an HTTP status: "not_found",
+// "rate_limited", ... Statuses without a fixed code get their status text
+// in snake case.
+func ProblemCode(status int) string {
+	if code, ok := problemCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// NewProblem returns the Problem for status with an optional detail.
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   ProblemCode(status),
+		Detail: detail,
+	}
+}
+
+// wantsProblemJSON reports whether an error response to c should be problem
+// details rather than an HTML page: on API routes, and for clients that
+// accept JSON but not HTML.
+func wantsProblemJSON(c echo.Context) bool {
+	path := c.Request().URL.Path
+	if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/admin/analytics/api/") {
+		return true
+	}
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	return (strings.Contains(accept, "json")) && !strings.Contains(accept, "text/html")
+}
+
+// renderProblem writes err as problem details. Messages of 5xx errors are
+// logged by the caller and not sent to the client.
+func renderProblem(c echo.Context, code int, he *echo.HTTPError) error {
+	var detail string
+	if he != nil && code < 500 {
+		if msg, ok := he.Message.(string); ok && msg != http.StatusText(code) {
+			detail = msg
+		}
+	}
+	p := NewProblem(code, detail)
+	p.Instance = c.Request().URL.Path
+	c.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(code)
+	}
+	return c.JSON(code, p)
+}
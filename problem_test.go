@@ -0,0 +1,70 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestErrorResponses(t *testing.T) {
+	app := newTestApp(t)
+	app.Echo.GET("/api/boom", func(c echo.Context) error { return errors.New("secret database failure") })
+	app.Echo.GET("/api/bad", func(c echo.Context) error { return echo.NewHTTPError(http.StatusBadRequest, "Missing id") })
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		app.Echo.ServeHTTP(rec, req)
+		return rec
+	}
+	problem := func(rec *httptest.ResponseRecorder) Problem {
+		t.Helper()
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("Content-Type = %q, want application/problem+json", ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("decode problem: %v\n%s", err, rec.Body.String())
+		}
+		return p
+	}
+
+	rec := get("/api/missing/", "")
+	if p := problem(rec); rec.Code != http.StatusNotFound || p.Status != 404 || p.Code != "not_found" || p.Title != "Not Found" || p.Instance != "/api/missing/" {
+		t.Errorf("GET /api/missing/ = %d %+v", rec.Code, p)
+	}
+	rec = get("/api/bad", "")
+	if p := problem(rec); rec.Code != http.StatusBadRequest || p.Code != "bad_request" || p.Detail != "Missing id" {
+		t.Errorf("GET /api/bad = %d %+v", rec.Code, p)
+	}
+	rec = get("/api/boom", "")
+	if p := problem(rec); rec.Code != http.StatusInternalServerError || p.Code != "internal_error" || strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("GET /api/boom = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = get("/blog/missing/", "text/html,application/xhtml+xml,*/*;q=0.8")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "not found" {
+		t.Errorf("HTML 404 = %d %q", rec.Code, rec.Body.String())
+	}
+	rec = get("/blog/missing/", "application/json")
+	if p := problem(rec); rec.Code != http.StatusNotFound || p.Code != "not_found" {
+		t.Errorf("JSON 404 = %d %+v", rec.Code, p)
+	}
+
+	if got := ProblemCode(http.StatusTooManyRequests); got != "rate_limited" {
+		t.Errorf("ProblemCode(429) = %q", got)
+	}
+	if got := ProblemCode(http.StatusTeapot); got != "i'm_a_teapot" {
+		t.Errorf("ProblemCode(418) = %q", got)
+	}
+}
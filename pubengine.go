@@ -10,45 +10,100 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
 
 	"github.com/eringen/pubengine/analytics"
+	"github.com/eringen/pubengine/markdown"
 )
 
 // ViewFuncs holds user-provided templ components that the framework calls
 // when rendering pages. This is the inversion-of-control mechanism that
 // lets users own and customize all templates.
 type ViewFuncs struct {
-	Home             func(posts []BlogPost, activeTag string, tags []string, siteURL string) templ.Component
-	HomePartial      func(posts []BlogPost, activeTag string, tags []string, siteURL string) templ.Component
-	BlogSection      func(posts []BlogPost, activeTag string, tags []string) templ.Component
-	Post             func(post BlogPost, posts []BlogPost, siteURL string) templ.Component
-	PostPartial      func(post BlogPost, posts []BlogPost, siteURL string) templ.Component
-	AdminLogin       func(errorMsg string, csrfToken string, googleLoginURL string) templ.Component
-	AdminDashboard   func(posts []BlogPost, message string, csrfToken string) templ.Component
-	AdminFormPartial func(post BlogPost, csrfToken string) templ.Component
-	AdminImages      func(images []Image, csrfToken string) templ.Component
-	NotFound         func() templ.Component
-	ServerError      func() templ.Component
+	Home              func(posts []BlogPost, activeTag string, tags []string, siteURL string) templ.Component
+	HomePartial       func(posts []BlogPost, activeTag string, tags []string, siteURL string) templ.Component
+	BlogSection       func(posts []BlogPost, activeTag string, tags []string) templ.Component
+	HomeSections      func(sections []HomeSection, tags []string, siteURL string) templ.Component // optional; / uses Home when nil or without a home layout
+	Post              func(post BlogPost, posts []BlogPost, siteURL string) templ.Component
+	PostPartial       func(post BlogPost, posts []BlogPost, siteURL string) templ.Component
+	PostPlain         func(post BlogPost, siteURL string) templ.Component                                       // optional; /blog/:slug/plain/ uses PlainPost when nil
+	Archive           func(archive []ArchiveYear, siteURL string) templ.Component                               // optional; /archive/ and its year and month pages 404 when nil
+	Series            func(series Series, siteURL string) templ.Component                                       // optional; /series/:slug/ 404s when nil
+	Author            func(author Author, posts []BlogPost, siteURL string) templ.Component                     // optional; /author/:slug/ 404s when nil
+	Search            func(query string, posts []BlogPost, siteURL string) templ.Component                      // optional; /search/ 404s when nil
+	Comments          func(post BlogPost, comments []Comment, message string, csrfToken string) templ.Component // optional; comment routes 404 when nil
+	Newsletter        func(page NewsletterPage, csrfToken string) templ.Component                               // optional; newsletter routes 404 when nil
+	AdminLogin        func(errorMsg string, csrfToken string, googleLoginURL string) templ.Component
+	AdminDashboard    func(posts []BlogPost, message string, csrfToken string) templ.Component
+	AdminFormPartial  func(post BlogPost, csrfToken string) templ.Component
+	AdminImages       func(images []Image, csrfToken string) templ.Component
+	AdminSnippets     func(snippets []Snippet, csrfToken string) templ.Component                    // optional; snippet routes 404 when nil
+	AdminImagePicker  func(images []Image, target string) templ.Component                           // optional; fills the form input with ID target, 404s when nil
+	AdminSharePreview func(preview SharePreview) templ.Component                                    // optional; share preview route 404s when nil
+	AdminSettings     func(settings SiteSettings, message string, csrfToken string) templ.Component // optional; settings routes 404 when nil
+	AdminComments     func(slug string, comments []DraftComment, csrfToken string) templ.Component  // optional; draft comment routes 404 when nil
+	AdminRevisions    func(slug string, revisions []PostRevision, csrfToken string) templ.Component // optional; revision routes 404 when nil
+	AdminModeration   func(pending []Comment, csrfToken string) templ.Component                     // optional; comment moderation routes 404 when nil
+	AdminSubscribers  func(subscribers []Subscriber, csrfToken string) templ.Component              // optional; subscriber admin routes 404 when nil
+	AdminCalendar     func(cal CalendarMonth, csrfToken string) templ.Component                     // optional; calendar routes 404 when nil
+	AdminFiles        func(files []Attachment, csrfToken string) templ.Component                    // optional; attachment admin routes 404 when nil
+	AdminSecurity     func(stats LoginStats) templ.Component                                        // optional; security route 404s when nil
+	AdminTrash        func(posts []BlogPost, csrfToken string) templ.Component                      // optional; trash routes 404 when nil
+	AdminStatus       func(health Health) templ.Component                                           // optional; status route 404s when nil
+	AdminJobs         func(dead []Job, queued int, csrfToken string) templ.Component                // optional; job routes 404 when nil
+	AdminSeries       func(series []Series, csrfToken string) templ.Component                       // optional; series admin routes 404 when nil
+	AdminAuthors      func(authors []Author, csrfToken string) templ.Component                      // optional; author admin routes 404 when nil
+	AdminRedirects    func(redirects []Redirect, csrfToken string) templ.Component                  // optional; redirect admin routes 404 when nil
+	AdminTags         func(tags []TagCount, csrfToken string) templ.Component                       // optional; tag admin routes 404 when nil
+	AdminSuggestLinks func(suggestions []LinkSuggestion) templ.Component                            // optional; link suggestion route 404s when nil
+	AdminPostConflict func(edited, current BlogPost, csrfToken string) templ.Component              // optional; without it, edits refused as conflicting are lost
+	NotFound          func() templ.Component
+	ServerError       func() templ.Component
 }
 
 // App is the central pubengine application. It wires together the store,
 // cache, handlers, middleware, and user-provided templates.
 type App struct {
-	Config SiteConfig
-	Echo   *echo.Echo
-	Store  *Store
-	Cache  *PostCache
-	Views  ViewFuncs
+	Config  SiteConfig
+	Echo    *echo.Echo
+	Store   *Store
+	Content ContentStore // where posts are kept, Store unless set with WithContentStore
+	Cache   *PostCache
+	Views   ViewFuncs
 
+	markdownOpts   markdown.Options // rendering settings put in each request's context
 	loginLimiter   *LoginLimiter
+	formLimiter    *LoginLimiter // public comment and newsletter submissions
 	analyticsStore *analytics.Store
 	customRoutes   []func(*App)
+	migrations     []Migration
+	experiments    []Experiment
+	translations   *translations
+	wellKnown      map[string]string
+	bodyLimits     map[string]int64
+	spikeNotifiers []func(analytics.ReferrerSpike) error
+	purgers        []Purger
 	staticDir      string
+	stopCleanup    func()
+	stopSketches   func()
+	stopSpikes     func()
+	stopTrash      func()
+	stopHealth     func()
+	stopJobs       func()
+	stopBackups    func()
+	stopPublish    func()
+	jobHandlers    map[string]JobHandler
+	jobWake        chan struct{}
+	queuedPreviews sync.Map   // bookmarked URLs with a preview fetch queued
+	backupMu       sync.Mutex // held while a backup runs
+	health         healthChecker
+	ready          bool
 }
 
 // New creates a new pubengine App with the given configuration and view functions.
@@ -69,8 +124,52 @@ func New(cfg SiteConfig, views ViewFuncs, opts ...Option) *App {
 	return a
 }
 
-// Start initializes the database, cache, middleware, routes, and starts the server.
+// Start initializes the app with Setup, if not done already, and starts the server.
 func (a *App) Start() error {
+	if !a.ready {
+		if err := a.Setup(); err != nil {
+			return err
+		}
+	}
+
+	// Start server
+	if err := a.Echo.Start(a.Config.Addr); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Mount serves the app from host, an existing Echo instance, for every
+// request host has no route of its own for. Middleware registered on host
+// runs before pubengine's, so logging, auth or headers can be shared; values
+// meant for pubengine handlers must travel in the request context. pubengine
+// still serves its routes from the root path. Mount calls Setup if needed;
+// serve with host.Start and call Close on shutdown.
+func (a *App) Mount(host *echo.Echo) error {
+	if !a.ready {
+		if err := a.Setup(); err != nil {
+			return err
+		}
+	}
+	for _, r := range host.Routes() {
+		if r.Path == "/*" {
+			return fmt.Errorf("pubengine: host already has a catch-all route (%s /*)", r.Method)
+		}
+	}
+	h := echo.WrapHandler(a.Echo)
+	host.Any("/", h)
+	host.Any("/*", h)
+	return nil
+}
+
+// Setup initializes the database, cache, middleware, and routes without
+// starting the server. Tests call it and then serve requests through
+// a.Echo.ServeHTTP. Call Close when done.
+func (a *App) Setup() error {
+	if a.ready {
+		return fmt.Errorf("pubengine: Setup called twice")
+	}
+
 	// Validate required config
 	if a.Config.AdminPassword == "" {
 		return fmt.Errorf("pubengine: AdminPassword is required")
@@ -78,19 +177,61 @@ func (a *App) Start() error {
 	if a.Config.SessionSecret == "" {
 		return fmt.Errorf("pubengine: SessionSecret is required")
 	}
+	if err := validateExperiments(a.experiments); err != nil {
+		return err
+	}
+	if a.translations != nil {
+		if err := a.translations.validate(); err != nil {
+			return err
+		}
+	}
+	a.markdownOpts = markdown.Options{
+		Images: markdown.ImageOptions{
+			Loading:  a.Config.ImageLoading,
+			Decoding: a.Config.ImageDecoding,
+			Sizes:    a.Config.ImageSizes,
+		},
+		MediaURL:     a.Config.MediaURL,
+		Typography:   a.Config.Typography,
+		LinkPreviews: a.linkPreview,
+	}
+	if err := a.markdownOpts.Images.Validate(); err != nil {
+		return fmt.Errorf("pubengine: %w", err)
+	}
 
 	// Initialize store
-	store, err := NewStore(a.Config.DatabasePath)
+	dsn := a.Config.DatabasePath
+	if !a.Config.usesSQLite() {
+		dsn = a.Config.DatabaseDSN
+	}
+	store, err := OpenStore(a.Config.DatabaseDriver, dsn)
 	if err != nil {
 		return fmt.Errorf("pubengine: init store: %w", err)
 	}
 	a.Store = store
+	if len(a.migrations) > 0 {
+		if err := store.Migrate(a.migrations...); err != nil {
+			return fmt.Errorf("pubengine: migrate: %w", err)
+		}
+	}
 
-	// Initialize cache
-	a.Cache = NewPostCache(a.Store, a.Config.PostCacheTTL)
+	// Initialize cache. Series and authors stay in the database with a
+	// custom ContentStore.
+	if a.Content == nil {
+		a.Content = store
+		a.Cache = NewPostCache(store, a.Config.PostCacheTTL)
+	} else {
+		a.Cache = NewPostCache(contentWithMeta{a.Content, store}, a.Config.PostCacheTTL)
+	}
+
+	// Empty the trash daily
+	if a.Config.TrashDays > 0 {
+		a.stopTrash = a.startTrashEmptier(a.Config.TrashDays, 24*time.Hour)
+	}
 
 	// Initialize login limiter
 	a.loginLimiter = NewLoginLimiter(5, time.Minute)
+	a.formLimiter = NewLoginLimiter(5, 10*time.Minute)
 
 	// Initialize analytics if enabled
 	if a.Config.AnalyticsEnabled {
@@ -99,13 +240,50 @@ func (a *App) Start() error {
 			return fmt.Errorf("pubengine: init analytics: %w", err)
 		}
 		a.analyticsStore = analyticsStore
+		analyticsStore.SetGoalPaths(a.Config.AnalyticsGoals)
+		analyticsStore.SetSampleRate(a.Config.AnalyticsSampleRate)
+		if analyticsStore.SampleRate() > 1 {
+			a.stopSketches = analyticsStore.StartSketchFlusher(time.Minute)
+		}
 		if err := analytics.InitSalt(analyticsStore); err != nil {
 			return fmt.Errorf("pubengine: init analytics salt: %w", err)
 		}
-		stopCleanup := analyticsStore.StartCleanupScheduler(365, 24*time.Hour)
-		defer stopCleanup()
+		a.stopCleanup = analyticsStore.StartCleanupScheduler(365, 24*time.Hour)
+		if notify := a.spikeNotify(); notify != nil {
+			a.stopSpikes = analyticsStore.StartSpikeDetector(analytics.SpikeConfig{
+				Threshold: a.Config.SpikeThreshold,
+				Notify:    notify,
+			})
+		}
+	}
+
+	// Start the job queue workers
+	if len(a.allPurgers()) > 0 {
+		WithJobHandler(purgeJobKind, a.runPurge)(a)
+	}
+	if a.Config.BackupsEnabled() {
+		WithJobHandler(backupJobKind, a.runBackup)(a)
+	}
+	WithJobHandler(syndicateJobKind, a.runSyndicate)(a)
+	WithJobHandler(linkPreviewJobKind, a.runLinkPreview)(a)
+	a.jobWake = make(chan struct{}, 1)
+	a.stopJobs = a.startJobWorkers(a.Config.JobWorkers)
+
+	// Upload database snapshots when they are due
+	if a.Config.BackupsEnabled() {
+		if !a.Config.usesSQLite() {
+			a.Echo.Logger.Warnf("backups skip the %s blog database, as only SQLite is snapshotted; back it up with its own tools", a.Config.DatabaseDriver)
+		}
+		a.stopBackups = a.startBackups()
 	}
 
+	// Publish scheduled drafts when they are due
+	a.stopPublish = a.startPublisher(publishCheckInterval)
+
+	// Check the databases now and every 30 seconds
+	a.checkHealth()
+	a.stopHealth = a.startHealthChecks(30 * time.Second)
+
 	// Setup middleware
 	a.setupMiddleware()
 
@@ -117,10 +295,7 @@ func (a *App) Start() error {
 		fn(a)
 	}
 
-	// Start server
-	if err := a.Echo.Start(a.Config.Addr); err != nil && err != http.ErrServerClosed {
-		return err
-	}
+	a.ready = true
 	return nil
 }
 
@@ -140,6 +315,11 @@ func (a *App) setupRoutes() {
 	e.Static("/public", a.staticDir)
 	e.GET("/favicon.svg", a.handleFavicon)
 	e.GET("/robots.txt", a.handleRobots)
+	e.GET("/humans.txt", a.handleHumans)
+	e.GET("/llms.txt", a.handleLLMs)
+	e.GET("/manifest.json", a.handleManifest)
+	e.GET("/readyz", a.handleReadyz)
+	e.GET("/.well-known/*", a.handleWellKnown)
 
 	// Public routes
 	e.GET("/sitemap.xml", a.handleSitemap)
@@ -147,6 +327,23 @@ func (a *App) setupRoutes() {
 	e.GET("/blog", handleBlogRedirect)
 	e.GET("/", a.handleHome)
 	e.GET("/blog/:slug/", a.handlePost)
+	e.GET("/blog/:slug/plain/", a.handlePlainPost)
+	e.GET("/archive/", a.handleArchive)
+	e.GET("/archive/:year/", a.handleArchivePeriod)
+	e.GET("/archive/:year/:month/", a.handleArchivePeriod)
+	e.GET("/series/:slug/", a.handleSeries)
+	e.GET("/author/:slug/", a.handleAuthor)
+	e.GET("/search/", a.handleSearch)
+	e.GET("/blog/:slug/comments/", a.handleComments)
+	e.POST("/blog/:slug/comments/", a.handleCommentSubmit)
+	e.GET("/newsletter/", a.handleNewsletter)
+	e.POST("/newsletter/", a.handleNewsletterSubscribe)
+	e.GET("/newsletter/unsubscribe/", a.handleNewsletterUnsubscribe)
+	e.POST("/newsletter/unsubscribe/", a.handleNewsletterUnsubscribeConfirm)
+	e.GET("/files/:filename", a.handleAttachment)
+	e.GET("/files/:filename/poster.jpg", a.handleAttachmentPoster)
+	e.GET("/blog/:slug/index.md", a.handlePostMarkdown)
+	e.GET("/s/:code", a.handleShortLink)
 
 	// Admin routes
 	e.GET("/admin/", a.handleAdmin)
@@ -155,9 +352,63 @@ func (a *App) setupRoutes() {
 	e.GET("/admin/post/:slug/", a.handleAdminPost)
 	e.POST("/admin/save/", a.handleAdminSave)
 	e.DELETE("/admin/post/:slug/", a.handleAdminDelete)
+	e.POST("/admin/post/:slug/duplicate/", a.handleAdminDuplicate)
+	e.POST("/admin/post/:slug/feature/", a.handleAdminFeature)
+	e.POST("/admin/bulk/", a.handleAdminBulk)
+	e.GET("/admin/post/:slug/preview-link/", a.handlePreviewLink)
+	e.GET("/admin/post/:slug/markdown/", a.handleAdminCopyMarkdown)
+	e.GET("/admin/post/:slug/html/", a.handleAdminCopyHTML)
+	e.POST("/admin/link-suggestions/", a.handleLinkSuggestions)
+	e.GET("/admin/trash/", a.handleTrashList)
+	e.POST("/admin/trash/:slug/restore/", a.handleTrashRestore)
+	e.DELETE("/admin/trash/:slug/", a.handleTrashDelete)
+	e.GET("/admin/post/:slug/share/", a.handleSharePreview)
+	e.GET("/admin/post/:slug/comments/", a.handleCommentList)
+	e.POST("/admin/post/:slug/comments/", a.handleCommentAdd)
+	e.POST("/admin/post/:slug/comments/:id/resolve/", a.handleCommentResolve)
+	e.POST("/admin/post/:slug/comments/:id/reopen/", a.handleCommentReopen)
+	e.DELETE("/admin/post/:slug/comments/:id/", a.handleCommentDelete)
+	e.GET("/admin/post/:slug/revisions/", a.handleRevisionList)
+	e.POST("/admin/post/:slug/revisions/:id/restore/", a.handleRevisionRestore)
 	e.GET("/admin/images/", a.handleImageList)
 	e.POST("/admin/images/upload/", a.handleImageUpload)
 	e.DELETE("/admin/images/:filename/", a.handleImageDelete)
+	e.GET("/admin/images/picker/", a.handleImagePicker)
+	e.GET("/admin/files/", a.handleAttachmentList)
+	e.POST("/admin/files/upload/", a.handleAttachmentUpload)
+	e.DELETE("/admin/files/:filename/", a.handleAttachmentDelete)
+	e.GET("/admin/snippets/", a.handleSnippetList)
+	e.POST("/admin/snippets/", a.handleSnippetSave)
+	e.DELETE("/admin/snippets/", a.handleSnippetDelete)
+	e.GET("/admin/series/", a.handleSeriesList)
+	e.POST("/admin/series/", a.handleSeriesSave)
+	e.DELETE("/admin/series/:slug/", a.handleSeriesDelete)
+	e.GET("/admin/authors/", a.handleAuthorList)
+	e.POST("/admin/authors/", a.handleAuthorSave)
+	e.DELETE("/admin/authors/:slug/", a.handleAuthorDelete)
+	e.GET("/admin/redirects/", a.handleRedirectList)
+	e.POST("/admin/redirects/", a.handleRedirectSave)
+	e.DELETE("/admin/redirects/:slug/", a.handleRedirectDelete)
+	e.GET("/admin/tags/", a.handleTagList)
+	e.POST("/admin/tags/", a.handleTagMerge)
+	e.GET("/admin/calendar/", a.handleCalendar)
+	e.POST("/admin/calendar/move/", a.handleCalendarMove)
+	e.GET("/admin/settings/", a.handleSettings)
+	e.POST("/admin/settings/", a.handleSettingsSave)
+	e.GET("/admin/security/", a.handleAdminSecurity)
+	e.GET("/admin/status/", a.handleAdminStatus)
+	e.GET("/admin/backup/", a.handleAdminBackup)
+	e.GET("/admin/export/", a.handleAdminExport)
+	e.POST("/admin/import/", a.handleAdminImport)
+	e.GET("/admin/jobs/", a.handleJobList)
+	e.POST("/admin/jobs/:id/retry/", a.handleJobRetry)
+	e.DELETE("/admin/jobs/:id/", a.handleJobDelete)
+	e.GET("/admin/moderation/", a.handleAdminModeration)
+	e.POST("/admin/moderation/:id/approve/", a.handleAdminCommentApprove)
+	e.DELETE("/admin/moderation/:id/", a.handleAdminCommentDelete)
+	e.GET("/admin/subscribers/", a.handleAdminSubscribers)
+	e.GET("/admin/subscribers/export/", a.handleAdminSubscribersExport)
+	e.DELETE("/admin/subscribers/", a.handleAdminSubscriberDelete)
 
 	// Google OAuth routes
 	if a.Config.GoogleAuthEnabled() {
@@ -168,6 +419,13 @@ func (a *App) setupRoutes() {
 	// Analytics routes
 	if a.Config.AnalyticsEnabled && a.analyticsStore != nil {
 		analyticsHandler := analytics.NewHandler(a.analyticsStore)
+		if len(a.Config.AnalyticsSites) > 0 {
+			sites := a.Config.AnalyticsSites
+			if u, err := url.Parse(a.Config.URL); err == nil && u.Host != "" {
+				sites = append([]string{u.Host}, sites...)
+			}
+			analyticsHandler.SetSites(sites)
+		}
 		analyticsAuthMiddleware := func(next echo.HandlerFunc) echo.HandlerFunc {
 			return func(c echo.Context) error {
 				if !IsAdmin(c) {
@@ -178,17 +436,45 @@ func (a *App) setupRoutes() {
 		}
 		publicGroup := e.Group("")
 		analyticsHandler.RegisterRoutes(e, publicGroup, analyticsAuthMiddleware)
+		if a.Config.AnalyticsTrackFeeds {
+			e.Use(analyticsHandler.FeedMiddleware())
+		}
 		e.GET("/admin/analytics/", func(c echo.Context) error {
 			if !IsAdmin(c) {
 				return c.Redirect(http.StatusSeeOther, "/admin/")
 			}
 			return analyticsHandler.DashboardHTML(c)
 		})
+		e.GET("/admin/analytics/api/experiments", a.handleExperimentReport, analyticsHandler.APIAuth(analyticsAuthMiddleware))
 	}
 }
 
 // Close cleans up resources. Call this when the app is shutting down.
 func (a *App) Close() error {
+	if a.stopCleanup != nil {
+		a.stopCleanup()
+	}
+	if a.stopSketches != nil {
+		a.stopSketches()
+	}
+	if a.stopSpikes != nil {
+		a.stopSpikes()
+	}
+	if a.stopTrash != nil {
+		a.stopTrash()
+	}
+	if a.stopHealth != nil {
+		a.stopHealth()
+	}
+	if a.stopBackups != nil {
+		a.stopBackups()
+	}
+	if a.stopPublish != nil {
+		a.stopPublish()
+	}
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
 	if a.Store != nil {
 		a.Store.Close()
 	}
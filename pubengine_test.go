@@ -0,0 +1,167 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+
+	"github.com/eringen/pubengine/markdown"
+)
+
+// newTestApp returns an App on an in-memory database with views that
+// render plain strings, closed when the test ends. Call Setup once its
+// config is set.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	page := func(s string) templ.Component { return templ.Raw(s) }
+	app := New(SiteConfig{
+		DatabasePath:  ":memory:",
+		AdminPassword: "test-password",
+		SessionSecret: "test-session-secret",
+	}, ViewFuncs{
+		Home:        func([]BlogPost, string, []string, string) templ.Component { return page("home") },
+		Post:        func(p BlogPost, _ []BlogPost, _ string) templ.Component { return page(p.Title) },
+		NotFound:    func() templ.Component { return page("not found") },
+		ServerError: func() templ.Component { return page("error") },
+	})
+	t.Cleanup(func() { app.Close() })
+	return app
+}
+
+// newSetUpTestApp returns a newTestApp with the default config, set up.
+func newSetUpTestApp(t *testing.T) *App {
+	t.Helper()
+	app := newTestApp(t)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	return app
+}
+
+// savePosts saves posts to the app's store.
+func savePosts(t *testing.T, app *App, posts ...BlogPost) {
+	t.Helper()
+	for _, p := range posts {
+		if err := app.Store.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testCSRFToken is the CSRF cookie and token adminRequest sends.
+const testCSRFToken = "test-csrf-token"
+
+// loginAdmin logs in to the app's admin and returns the session cookie.
+func loginAdmin(t *testing.T, app *App) *http.Cookie {
+	t.Helper()
+	rec := adminRequest(app, nil, http.MethodPost, "/admin/login/", url.Values{"password": {app.Config.AdminPassword}})
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionName {
+			return c
+		}
+	}
+	t.Fatalf("login = %d without a session cookie", rec.Code)
+	return nil
+}
+
+// adminRequest sends form to the app with the session cookie, if any, and
+// a valid CSRF token.
+func adminRequest(app *App, session *http.Cookie, method, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set("X-CSRF-Token", testCSRFToken)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: testCSRFToken})
+	if session != nil {
+		req.AddCookie(session)
+	}
+	rec := httptest.NewRecorder()
+	app.Echo.ServeHTTP(rec, req)
+	return rec
+}
+
+// serveGet sends a GET request for path to the app.
+func serveGet(app *App, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestMount(t *testing.T) {
+	app := newTestApp(t)
+	host := echo.New()
+	host.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set("X-Host", "yes")
+			return next(c)
+		}
+	})
+	host.GET("/api/health", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	if err := app.Mount(host); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/health", http.StatusOK, "ok"},
+		{"/", http.StatusOK, "home"},
+		{"/blog/missing/", http.StatusNotFound, "not found"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		host.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.code || rec.Body.String() != tt.body {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, rec.Code, rec.Body.String(), tt.code, tt.body)
+		}
+		if rec.Header().Get("X-Host") != "yes" {
+			t.Errorf("GET %s: host middleware did not run", tt.path)
+		}
+	}
+}
+
+func TestMountRejectsCatchAll(t *testing.T) {
+	app := newTestApp(t)
+	host := echo.New()
+	host.GET("/*", func(c echo.Context) error { return nil })
+	if err := app.Mount(host); err == nil {
+		t.Fatal("expected an error when the host has a catch-all route")
+	}
+}
+
+func TestMarkdownOptionsPerApp(t *testing.T) {
+	setup := func(typography bool) *App {
+		app := newTestApp(t)
+		app.Config.Typography = typography
+		app.Views.Post = func(p BlogPost, _ []BlogPost, _ string) templ.Component {
+			return markdown.Markdown(p.Content)
+		}
+		if err := app.Setup(); err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		if err := app.Store.SavePost(BlogPost{Slug: "quote", Title: "Quote", Date: "2024-01-01", Content: `"Hi"`, Published: true}); err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+	get := func(app *App) string {
+		rec := serveGet(app, "/blog/quote/")
+		return rec.Body.String()
+	}
+	// Each app renders with its own settings, whichever was set up last.
+	curly, straight := setup(true), setup(false)
+	if body := get(curly); !strings.Contains(body, "“Hi”") {
+		t.Errorf("with typography got %q", body)
+	}
+	if body := get(straight); !strings.Contains(body, "&#34;Hi&#34;") {
+		t.Errorf("without typography got %q", body)
+	}
+}
@@ -0,0 +1,196 @@
+package pubengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Purger removes URLs from an edge cache. It is called with absolute URLs
+// after posts change, so pages can be cached by a CDN for a long time.
+type Purger func(urls []string) error
+
+// cloudflarePurgeBatch is the most URLs Cloudflare purges per request.
+const cloudflarePurgeBatch = 30
+
+var purgeClient = &http.Client{Timeout: 10 * time.Second}
+
+// purgeJobKind is the job kind of CDN purges.
+const purgeJobKind = "cdn_purge"
+
+// WithPurger calls fn with the URLs affected whenever a post is saved,
+// deleted or rescheduled: the post, its plain and Markdown versions, the
+// home page and its tag pages, the feeds and the sitemap. It runs
+// alongside PurgeWebhookURL, from the job queue after the change is saved.
+func WithPurger(fn Purger) Option {
+	return func(a *App) {
+		a.purgers = append(a.purgers, fn)
+	}
+}
+
+// CloudflarePurger purges URLs from a Cloudflare zone. The API token needs
+// the Cache Purge permission.
+func CloudflarePurger(zoneID, apiToken string) Purger {
+	endpoint := "https://api.cloudflare.com/client/v4/zones/" + url.PathEscape(zoneID) + "/purge_cache"
+	return func(urls []string) error {
+		for len(urls) > 0 {
+			batch := urls[:min(len(urls), cloudflarePurgeBatch)]
+			urls = urls[len(batch):]
+			body, err := json.Marshal(map[string][]string{"files": batch})
+			if err != nil {
+				return err
+			}
+			req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+apiToken)
+			req.Header.Set("Content-Type", "application/json")
+			if err := doPurge(req); err != nil {
+				return fmt.Errorf("cloudflare: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// FastlyPurger purges URLs from Fastly, one request per URL.
+func FastlyPurger(apiToken string) Purger {
+	return func(urls []string) error {
+		var errs []error
+		for _, u := range urls {
+			req, err := http.NewRequest(http.MethodPost, "https://api.fastly.com/purge/"+strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://"), nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Fastly-Key", apiToken)
+			if err := doPurge(req); err != nil {
+				errs = append(errs, fmt.Errorf("fastly: purge %s: %w", u, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// BunnyPurger purges URLs from a Bunny CDN pull zone, one request per URL.
+func BunnyPurger(apiKey string) Purger {
+	return func(urls []string) error {
+		var errs []error
+		for _, u := range urls {
+			req, err := http.NewRequest(http.MethodPost, "https://api.bunny.net/purge?url="+url.QueryEscape(u), nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("AccessKey", apiKey)
+			if err := doPurge(req); err != nil {
+				errs = append(errs, fmt.Errorf("bunny: purge %s: %w", u, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// WebhookPurger POSTs the URLs as JSON to endpoint: {"urls": [...]}.
+func WebhookPurger(endpoint string) Purger {
+	return func(urls []string) error {
+		body, err := json.Marshal(map[string][]string{"urls": urls})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return doPurge(req)
+	}
+}
+
+func doPurge(req *http.Request) error {
+	resp, err := purgeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("purge returned %s", resp.Status)
+	}
+	return nil
+}
+
+// purgeURLs returns the absolute URLs showing any of posts.
+func (a *App) purgeURLs(posts ...BlogPost) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(u string) {
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	feed := AbsoluteURL(BuildURL(a.Config.URL), "feed.xml")
+	add(BuildURL(a.Config.URL, "/"))
+	add(feed)
+	add(AbsoluteURL(BuildURL(a.Config.URL), "sitemap.xml"))
+	for _, post := range posts {
+		if post.Slug == "" {
+			continue
+		}
+		postURL := BuildURL(a.Config.URL, "blog", post.Slug)
+		add(postURL)
+		add(postURL + "plain/")
+		add(postURL + "index.md")
+		if post.SeriesSlug != "" {
+			add(BuildURL(a.Config.URL, "series", post.SeriesSlug))
+		}
+		if post.AuthorSlug != "" {
+			add(BuildURL(a.Config.URL, "author", post.AuthorSlug))
+		}
+		for _, tag := range post.Tags {
+			tag = strings.ToLower(tag)
+			add(TagURL(a.Config.URL, tag))
+			add(feed + "?tag=" + url.QueryEscape(tag))
+		}
+	}
+	return urls
+}
+
+// allPurgers returns WithPurger's functions and the PurgeWebhookURL webhook.
+func (a *App) allPurgers() []Purger {
+	purgers := a.purgers
+	if a.Config.PurgeWebhookURL != "" {
+		purgers = append([]Purger{WebhookPurger(a.Config.PurgeWebhookURL)}, purgers...)
+	}
+	return purgers
+}
+
+// purge queues a job purging the pages showing posts from edge caches.
+// Pass a post both before and after a change so pages it left are purged
+// too.
+func (a *App) purge(posts ...BlogPost) {
+	if len(a.allPurgers()) == 0 {
+		return
+	}
+	if err := a.Enqueue(purgeJobKind, a.purgeURLs(posts...)); err != nil {
+		a.Echo.Logger.Errorf("queue cdn purge: %v", err)
+	}
+}
+
+// runPurge runs a purge job with every purger. A failed purge retries the
+// whole job, which is harmless since purging is idempotent.
+func (a *App) runPurge(payload []byte) error {
+	var urls []string
+	if err := json.Unmarshal(payload, &urls); err != nil {
+		return err
+	}
+	var errs []error
+	for _, fn := range a.allPurgers() {
+		errs = append(errs, fn(urls))
+	}
+	return errors.Join(errs...)
+}
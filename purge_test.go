@@ -0,0 +1,61 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	var got struct{ URLs []string }
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+	}))
+	defer hook.Close()
+
+	app := newTestApp(t)
+	app.Config.URL = "https://example.com"
+	app.Config.PurgeWebhookURL = hook.URL
+	purged := make(chan []string, 1)
+	WithPurger(func(urls []string) error {
+		purged <- urls
+		return nil
+	})(app)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	before := BlogPost{Slug: "hello", Tags: []string{"Go", "web"}}
+	after := BlogPost{Slug: "hello", Tags: []string{"go"}}
+	app.purge(before, after)
+	var urls []string
+	select {
+	case urls = <-purged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("purger not called")
+	}
+	want := []string{
+		"https://example.com/",
+		"https://example.com/feed.xml",
+		"https://example.com/sitemap.xml",
+		"https://example.com/blog/hello/",
+		"https://example.com/blog/hello/plain/",
+		"https://example.com/blog/hello/index.md",
+		"https://example.com/?tag=go",
+		"https://example.com/feed.xml?tag=go",
+		"https://example.com/?tag=web",
+		"https://example.com/feed.xml?tag=web",
+	}
+	if strings.Join(urls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("purged %q, want %q", urls, want)
+	}
+	// The webhook runs before WithPurger functions.
+	if strings.Join(got.URLs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("webhook got %q", got.URLs)
+	}
+}
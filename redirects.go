@@ -0,0 +1,215 @@
+package pubengine
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Redirect sends requests for the post URL of an old slug to the post
+// that now lives at another slug. Renaming a post in the admin records one;
+// others are added in the admin Redirects panel.
+type Redirect struct {
+	From      string // old slug
+	To        string // slug of the post
+	CreatedAt string // RFC 3339
+}
+
+// FromURL returns the path the redirect answers.
+func (r Redirect) FromURL() string {
+	return "/blog/" + r.From + "/"
+}
+
+// ToURL returns the path the redirect points to.
+func (r Redirect) ToURL() string {
+	return "/blog/" + r.To + "/"
+}
+
+// RenamePost moves the post oldSlug, with its custom fields, links, draft
+// comments and short link, to newSlug and redirects oldSlug to it. Redirects to
+// oldSlug are pointed at newSlug, so old links never chain, and a redirect
+// from newSlug is dropped, as the post now answers there. It returns
+// sql.ErrNoRows if there is no post with oldSlug, and an error if a post
+// already uses newSlug.
+func (s *Store) RenamePost(oldSlug, newSlug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM posts WHERE slug = ?`, newSlug).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("rename post: slug %q is taken", newSlug)
+	}
+	res, err := tx.Exec(`UPDATE posts SET slug = ? WHERE slug = ?`, newSlug, oldSlug)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	for _, q := range []string{
+		`UPDATE post_meta SET slug = ? WHERE slug = ?`,
+		`UPDATE draft_comments SET slug = ? WHERE slug = ?`,
+		`UPDATE post_revisions SET slug = ? WHERE slug = ?`,
+		`UPDATE comments SET slug = ? WHERE slug = ?`,
+		`UPDATE syndications SET slug = ? WHERE slug = ?`,
+		`UPDATE redirects SET to_slug = ? WHERE to_slug = ?`,
+		`UPDATE post_links SET from_slug = ? WHERE from_slug = ?`,
+		`UPDATE post_links SET to_slug = ? WHERE to_slug = ?`,
+	} {
+		if _, err := tx.Exec(q, newSlug, oldSlug); err != nil {
+			return err
+		}
+	}
+	// The short link code printed for this post wins over one left behind
+	// by a deleted post that used newSlug.
+	if _, err := tx.Exec(`DELETE FROM short_links WHERE slug = ?`, newSlug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE short_links SET slug = ? WHERE slug = ?`, newSlug, oldSlug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM redirects WHERE from_slug = ?`, newSlug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO redirects (from_slug, to_slug, created_at) VALUES (?, ?, ?)
+ON CONFLICT(from_slug) DO UPDATE SET to_slug = excluded.to_slug, created_at = excluded.created_at`,
+		oldSlug, newSlug, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveRedirect redirects the post URL of from to the post to, replacing
+// any redirect from from.
+func (s *Store) SaveRedirect(from, to string) error {
+	_, err := s.db.Exec(`INSERT INTO redirects (from_slug, to_slug, created_at) VALUES (?, ?, ?)
+ON CONFLICT(from_slug) DO UPDATE SET to_slug = excluded.to_slug, created_at = excluded.created_at`,
+		from, to, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// ResolveRedirect returns the slug the old slug redirects to, or
+// sql.ErrNoRows if it has no redirect.
+func (s *Store) ResolveRedirect(slug string) (string, error) {
+	var to string
+	err := s.db.QueryRow(`SELECT to_slug FROM redirects WHERE from_slug = ?`, slug).Scan(&to)
+	return to, err
+}
+
+// ListRedirects returns every redirect, newest first.
+func (s *Store) ListRedirects() ([]Redirect, error) {
+	rows, err := s.db.Query(`SELECT from_slug, to_slug, created_at FROM redirects ORDER BY created_at DESC, from_slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var redirects []Redirect
+	for rows.Next() {
+		var r Redirect
+		if err := rows.Scan(&r.From, &r.To, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, r)
+	}
+	return redirects, rows.Err()
+}
+
+// DeleteRedirect removes the redirect from the old slug.
+func (s *Store) DeleteRedirect(from string) error {
+	_, err := s.db.Exec(`DELETE FROM redirects WHERE from_slug = ?`, from)
+	return err
+}
+
+// redirectSlug accepts a slug or a post path like "/blog/old-post/" in
+// the redirect form and returns the slug.
+func redirectSlug(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), "/")
+	return strings.TrimPrefix(s, "blog/")
+}
+
+// redirectPost permanently redirects a request for a post that doesn't
+// exist to the post its slug was renamed to. It returns echo.ErrNotFound
+// when the slug has no redirect.
+func (a *App) redirectPost(c echo.Context, slug string) error {
+	to, err := a.Store.ResolveRedirect(slug)
+	if err == sql.ErrNoRows {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	target := BuildURL(a.Config.URL, "blog", to)
+	if q := c.Request().URL.RawQuery; q != "" {
+		target += "?" + q
+	}
+	return c.Redirect(http.StatusMovedPermanently, target)
+}
+
+func (a *App) handleRedirectList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderRedirectList(c)
+}
+
+func (a *App) handleRedirectSave(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	from := redirectSlug(c.FormValue("from"))
+	to := redirectSlug(c.FormValue("to"))
+	if msg := ValidateSlug(from); msg != "" {
+		return c.String(http.StatusBadRequest, "From: "+msg)
+	}
+	if from == to {
+		return c.String(http.StatusBadRequest, "A slug can't redirect to itself.")
+	}
+	if _, err := a.Content.GetPostAny(from); err == nil {
+		return c.String(http.StatusBadRequest, "A post uses "+from+". Rename or delete it first.")
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	if _, err := a.Content.GetPostAny(to); err == sql.ErrNoRows {
+		return c.String(http.StatusBadRequest, "No post uses "+to+".")
+	} else if err != nil {
+		return err
+	}
+	if err := a.Store.SaveRedirect(from, to); err != nil {
+		return err
+	}
+	return a.renderRedirectList(c)
+}
+
+func (a *App) handleRedirectDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if err := a.Store.DeleteRedirect(c.Param("slug")); err != nil {
+		return err
+	}
+	return a.renderRedirectList(c)
+}
+
+func (a *App) renderRedirectList(c echo.Context) error {
+	if a.Views.AdminRedirects == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	redirects, err := a.Store.ListRedirects()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminRedirects(redirects, CsrfToken(c)))
+}
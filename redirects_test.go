@@ -0,0 +1,87 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestSlugRedirect(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Published: true})
+	if err := app.Store.RenamePost("hello", "hello-world"); err != nil {
+		t.Fatal(err)
+	}
+	app.Cache.Invalidate()
+
+	rec := serveGet(app, "/blog/hello/?ref=hn")
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "http://localhost:3000/blog/hello-world/?ref=hn" {
+		t.Errorf("GET old slug = %d to %q, want a 301 to the new slug", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	app.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blog/nope/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown slug = %d, want 404", rec.Code)
+	}
+}
+
+// staleStore is a Store on which every versioned save finds the post
+// changed, as when another admin saves between the form's check and save.
+type staleStore struct{ *Store }
+
+func (staleStore) SavePostIfVersion(BlogPost, int) error { return ErrPostChanged }
+
+func TestAdminRename(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.AdminDashboard = func(_ []BlogPost, msg, _ string) templ.Component { return templ.Raw(msg) }
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Published: true})
+	post, err := app.Store.GetPostAny("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := loginAdmin(t, app)
+	rename := func() *httptest.ResponseRecorder {
+		return adminRequest(app, session, http.MethodPost, "/admin/save/", url.Values{
+			"original_slug": {"hello"},
+			"version":       {strconv.Itoa(post.Version)},
+			"slug":          {"hello-world"},
+			"title":         {"Hello, world"},
+			"date":          {"2024-01-01"},
+			"published":     {"on"},
+		})
+	}
+
+	// A save that loses the race keeps the post at its old slug.
+	app.Content = staleStore{app.Store}
+	if rec := rename(); rec.Code != http.StatusOK {
+		t.Fatalf("conflicting rename = %d %q", rec.Code, rec.Body.String())
+	}
+	if got, err := app.Store.GetPostAny("hello"); err != nil || got.Title != "Hello" {
+		t.Errorf("post after a conflicting rename = %+v, %v", got, err)
+	}
+	if _, err := app.Store.ResolveRedirect("hello"); err != ErrNotFound {
+		t.Errorf("redirect after a conflicting rename: %v, want ErrNotFound", err)
+	}
+
+	app.Content = app.Store
+	if rec := rename(); rec.Code != http.StatusOK || rec.Body.String() != "saved" {
+		t.Fatalf("rename = %d %q", rec.Code, rec.Body.String())
+	}
+	if got, err := app.Store.GetPostAny("hello-world"); err != nil || got.Title != "Hello, world" {
+		t.Errorf("renamed post = %+v, %v", got, err)
+	}
+	if _, err := app.Store.GetPostAny("hello"); err != ErrNotFound {
+		t.Errorf("old slug: %v, want ErrNotFound", err)
+	}
+	if to, err := app.Store.ResolveRedirect("hello"); err != nil || to != "hello-world" {
+		t.Errorf("ResolveRedirect = %q, %v", to, err)
+	}
+}
@@ -1,7 +1,13 @@
 package pubengine
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
@@ -18,3 +24,41 @@ func RenderStatus(c echo.Context, code int, cmp templ.Component) error {
 	c.Response().WriteHeader(code)
 	return cmp.Render(c.Request().Context(), c.Response().Writer)
 }
+
+// renderXML writes v as an XML document with an ETag of its bytes and, when
+// modified is set, a Last-Modified header, answering requests whose
+// If-None-Match has the ETag with 304 Not Modified. If-Modified-Since is
+// not used: a post leaving the document doesn't make it newer.
+func renderXML(c echo.Context, contentType string, v any, modified time.Time) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	h := c.Response().Header()
+	h.Set("ETag", etag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	h.Set(echo.HeaderContentType, contentType)
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := c.Response().Write(buf.Bytes())
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly since compression may change the bytes sent.
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxRevisions is how many revisions of a post are kept; saving another
+// drops the oldest.
+const maxRevisions = 50
+
+// saveRevision keeps the saved post slug as its latest revision.
+func (s *Store) saveRevision(slug string) error {
+	if _, err := s.db.Exec(`INSERT INTO post_revisions (slug, version, title, date, tags, summary, content, created_at)
+SELECT slug, version, title, date, tags, summary, content, updated_at FROM posts WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	return s.pruneRevisions(slug)
+}
+
+// AddRevision keeps r as the latest revision of the post r.Slug and
+// returns its ID, for posts of ContentStores that don't keep their own.
+// CreatedAt is set when empty.
+func (s *Store) AddRevision(r PostRevision) (int64, error) {
+	if r.CreatedAt == "" {
+		r.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	id, err := s.db.insert(`INSERT INTO post_revisions (slug, version, title, date, tags, summary, content, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Slug, r.Version, r.Title, r.Date, ","+strings.Join(r.Tags, ",")+",", r.Summary, r.Content, r.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.pruneRevisions(r.Slug)
+}
+
+// pruneRevisions drops the revisions of slug older than the newest
+// maxRevisions.
+func (s *Store) pruneRevisions(slug string) error {
+	var oldest int64
+	err := s.db.QueryRow(`SELECT id FROM post_revisions WHERE slug = ? ORDER BY id DESC LIMIT 1 OFFSET ?`, slug, maxRevisions-1).Scan(&oldest)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM post_revisions WHERE slug = ? AND id < ?`, slug, oldest)
+	return err
+}
+
+// latestRevision returns the ID of the latest revision of slug, or 0 if
+// none was kept.
+func (s *Store) latestRevision(slug string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM post_revisions WHERE slug = ?`, slug).Scan(&id)
+	return id, err
+}
+
+// revisionColumns are the post_revisions columns read by scanRevision, in
+// order.
+const revisionColumns = "id, slug, version, title, date, tags, summary, content, created_at"
+
+func scanRevision(row interface{ Scan(...any) error }) (PostRevision, error) {
+	var r PostRevision
+	var tags string
+	if err := row.Scan(&r.ID, &r.Slug, &r.Version, &r.Title, &r.Date, &tags, &r.Summary, &r.Content, &r.CreatedAt); err != nil {
+		return PostRevision{}, err
+	}
+	r.Tags = ParseTags(tags)
+	return r, nil
+}
+
+// ListRevisions returns the kept revisions of a post, newest first, at most
+// maxRevisions.
+func (s *Store) ListRevisions(slug string) ([]PostRevision, error) {
+	rows, err := s.db.Query(`SELECT `+revisionColumns+` FROM post_revisions WHERE slug = ? ORDER BY id DESC`, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []PostRevision
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns a revision of the post slug. It returns sql.ErrNoRows
+// if the post has no revision with the ID.
+func (s *Store) GetRevision(slug string, id int64) (PostRevision, error) {
+	return scanRevision(s.db.QueryRow(`SELECT `+revisionColumns+` FROM post_revisions WHERE id = ? AND slug = ?`, id, slug))
+}
+
+// keepRevision keeps the post slug, just saved through the ContentStore,
+// as a revision when the ContentStore doesn't keep its own.
+func (a *App) keepRevision(slug string) error {
+	if _, ok := a.Content.(revisionKeeper); ok {
+		return nil
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return err
+	}
+	_, err = a.Store.AddRevision(PostRevision{
+		Slug:    post.Slug,
+		Version: post.Version,
+		Title:   post.Title,
+		Date:    post.Date,
+		Tags:    post.Tags,
+		Summary: post.Summary,
+		Content: post.Content,
+	})
+	return err
+}
+
+// restoreRevision saves the title, date, tags, summary and content of a
+// revision over the post, keeping its other fields, and returns the post.
+// It returns ErrNotFound if there is no such revision or the post is gone
+// or in the trash.
+func (a *App) restoreRevision(slug string, id int64) (BlogPost, error) {
+	r, err := a.Store.GetRevision(slug, id)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	if post.TrashedAt != "" {
+		return BlogPost{}, ErrNotFound
+	}
+	previous := post
+	post.Title, post.Date, post.Tags, post.Summary, post.Content = r.Title, r.Date, r.Tags, r.Summary, r.Content
+	if err := a.Content.SavePost(post); err != nil {
+		return BlogPost{}, err
+	}
+	if err := a.keepRevision(slug); err != nil {
+		return BlogPost{}, err
+	}
+	a.Cache.Invalidate()
+	if post.Published {
+		a.purge(previous, post)
+	}
+	return a.Content.GetPostAny(slug)
+}
+
+func (a *App) handleRevisionList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminRevisions == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	slug := c.Param("slug")
+	revisions, err := a.Store.ListRevisions(slug)
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminRevisions(slug, revisions, CsrfToken(c)))
+}
+
+// handleRevisionRestore restores a revision, see App.restoreRevision, and
+// shows the post form with the restored post.
+func (a *App) handleRevisionRestore(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminRevisions == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	post, err := a.restoreRevision(c.Param("slug"), id)
+	if err == sql.ErrNoRows {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	if post.Backlinks, err = a.postBacklinks(post.Slug); err != nil {
+		return err
+	}
+	if post.Syndications, err = a.postSyndications(post.Slug); err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminFormPartial(post, CsrfToken(c)))
+}
@@ -0,0 +1,167 @@
+package pubengine
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+func TestPostRevisions(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	for _, content := range []string{"First.", "Second.", "Third."} {
+		if err := s.SavePost(BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Tags: []string{"Go"}, Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := s.ListRevisions("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 || revisions[0].Content != "Third." || revisions[2].Content != "First." {
+		t.Fatalf("revisions = %+v, want the three saves newest first", revisions)
+	}
+	post, _ := s.GetPostAny("post")
+	if r := revisions[0]; r.Version != post.Version || r.Title != "Post" || strings.Join(r.Tags, ",") != "go" || r.CreatedAt != post.UpdatedAt {
+		t.Errorf("latest revision = %+v, want the saved post %+v", r, post)
+	}
+	if r, err := s.GetRevision("post", revisions[2].ID); err != nil || r.Content != "First." {
+		t.Errorf("GetRevision() = %+v, %v", r, err)
+	}
+	if _, err := s.GetRevision("other", revisions[2].ID); err != ErrNotFound {
+		t.Errorf("GetRevision(other post) = %v, want ErrNotFound", err)
+	}
+
+	// Only the newest maxRevisions are kept.
+	for i := 0; i < maxRevisions; i++ {
+		if err := s.SavePost(BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Content: "Edit " + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err = s.ListRevisions("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != maxRevisions || revisions[len(revisions)-1].Content != "Edit 0" {
+		t.Errorf("%d revisions kept, the oldest %q; want %d from Edit 0", len(revisions), revisions[len(revisions)-1].Content, maxRevisions)
+	}
+
+	if err := s.RenamePost("post", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := s.ListRevisions("renamed"); len(revisions) != maxRevisions {
+		t.Errorf("%d revisions after a rename, want %d", len(revisions), maxRevisions)
+	}
+	if err := s.DeletePost("renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := s.ListRevisions("renamed"); len(revisions) != 0 {
+		t.Errorf("%d revisions after a delete, want none", len(revisions))
+	}
+}
+
+func TestDraftCommentRevisions(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	if err := s.SavePost(BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Content: "Hello, world."}); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := s.latestRevision("post")
+	if err != nil || latest == 0 {
+		t.Fatalf("latestRevision() = %d, %v", latest, err)
+	}
+	if _, err := s.AddDraftComment(DraftComment{Slug: "post", Start: 7, End: 12, Quote: "world", Body: "Which one?", CreatedAt: time.Now().UTC().Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := s.ListDraftComments("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Revision != latest || comments[0].Outdated {
+		t.Fatalf("comments = %+v, want one on revision %d", comments, latest)
+	}
+	r, err := s.GetRevision("post", comments[0].Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Content[comments[0].Start:comments[0].End]; got != "world" {
+		t.Errorf("comment range in its revision = %q, want world", got)
+	}
+
+	if err := s.SavePost(BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Content: "Hi, world."}); err != nil {
+		t.Fatal(err)
+	}
+	if comments, _ := s.ListDraftComments("post"); len(comments) != 1 || !comments[0].Outdated {
+		t.Errorf("comments after a save = %+v, want outdated", comments)
+	}
+}
+
+func TestRevisionHandlers(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.AdminFormPartial = func(p BlogPost, _ string) templ.Component { return templ.Raw(p.Content) }
+	app.Views.AdminRevisions = func(_ string, revisions []PostRevision, _ string) templ.Component {
+		var contents []string
+		for _, r := range revisions {
+			contents = append(contents, r.Content)
+		}
+		return templ.Raw(strings.Join(contents, ","))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	session := loginAdmin(t, app)
+	savePosts(t, app,
+		BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Content: "old", Published: true},
+		BlogPost{Slug: "post", Title: "Post", Date: "2024-01-01", Content: "new", Published: true, Featured: true},
+	)
+
+	rec := adminRequest(app, session, http.MethodGet, "/admin/post/post/revisions/", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "new,old" {
+		t.Fatalf("revisions = %d %q", rec.Code, rec.Body.String())
+	}
+	revisions, err := app.Store.ListRevisions("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore := "/admin/post/post/revisions/" + strconv.FormatInt(revisions[1].ID, 10) + "/restore/"
+	if rec := adminRequest(app, nil, http.MethodPost, restore, nil); rec.Code != http.StatusSeeOther {
+		t.Errorf("restore without a session = %d, want a redirect", rec.Code)
+	}
+	rec = adminRequest(app, session, http.MethodPost, restore, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "old" {
+		t.Fatalf("restore = %d %q", rec.Code, rec.Body.String())
+	}
+	if post, _ := app.Store.GetPostAny("post"); post.Content != "old" || !post.Featured || !post.Published {
+		t.Errorf("restored post = %+v, want the old content with the other fields kept", post)
+	}
+	if rec := adminRequest(app, session, http.MethodGet, "/admin/post/post/revisions/", nil); rec.Body.String() != "old,new,old" {
+		t.Errorf("revisions after a restore = %q", rec.Body.String())
+	}
+	if rec := adminRequest(app, session, http.MethodPost, "/admin/post/other/revisions/"+strconv.FormatInt(revisions[1].ID, 10)+"/restore/", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("restore of another post's revision = %d, want 404", rec.Code)
+	}
+
+	// Posts of ContentStores without revisions of their own get them in
+	// the blog database.
+	mem := &memContent{posts: map[string]BlogPost{}}
+	WithContentStore(mem)(app)
+	if err := mem.SavePost(BlogPost{Slug: "mem", Title: "Mem", Date: "2024-01-01", Content: "Kept."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.keepRevision("mem"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, err := app.Store.ListRevisions("mem"); err != nil || len(revisions) != 1 || revisions[0].Content != "Kept." {
+		t.Errorf("revisions of a custom ContentStore post = %+v, %v", revisions, err)
+	}
+
+	app = newSetUpTestApp(t)
+	session = loginAdmin(t, app)
+	if rec := adminRequest(app, session, http.MethodGet, "/admin/post/post/revisions/", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("revisions without an AdminRevisions view = %d, want 404", rec.Code)
+	}
+}
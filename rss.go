@@ -2,15 +2,25 @@ package pubengine
 
 import (
 	"encoding/xml"
-	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+// Namespaces used in the RSS feed: Media RSS for post images, Dublin Core
+// for post authors and Atom for when posts were last edited.
+const (
+	mediaRSSNamespace   = "http://search.yahoo.com/mrss/"
+	dublinCoreNamespace = "http://purl.org/dc/elements/1.1/"
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+)
+
 type rssXML struct {
 	XMLName xml.Name   `xml:"rss"`
 	Version string     `xml:"version,attr"`
+	MediaNS string     `xml:"xmlns:media,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
 	Channel rssChannel `xml:"channel"`
 }
 
@@ -18,45 +28,113 @@ type rssChannel struct {
 	Title       string    `xml:"title"`
 	Link        string    `xml:"link"`
 	Description string    `xml:"description"`
+	LastBuild   string    `xml:"lastBuildDate,omitempty"`
 	Items       []rssItem `xml:"item"`
 }
 
 type rssItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	PubDate     string     `xml:"pubDate"`
+	Updated     string     `xml:"atom:updated,omitempty"`
+	GUID        string     `xml:"guid"`
+	Creator     string     `xml:"dc:creator,omitempty"`
+	Source      *rssSource `xml:"source,omitempty"`
+	Media       *rssMedia  `xml:"media:content,omitempty"`
+}
+
+// rssSource names the site a cross-posted item was first published on.
+type rssSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+type rssMedia struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
 }
 
-func (a *App) renderRSS(c echo.Context, posts []BlogPost) error {
+// renderRSS writes posts as an RSS feed, titled and linked for tag when it
+// is non-empty. Posts excluded from feeds are left out.
+func (a *App) renderRSS(c echo.Context, posts []BlogPost, tag string) error {
 	base := a.Config.URL
+	title, link := a.Config.Name, base
+	if tag != "" {
+		title, link = a.Config.Name+": "+tag, TagURL(base, tag)
+	}
 	items := make([]rssItem, 0, len(posts))
+	var lastBuild time.Time
 	for _, p := range posts {
+		if p.ExcludeFromFeed {
+			continue
+		}
+		updated := lastModifiedTime(p)
+		if updated.After(lastBuild) {
+			lastBuild = updated
+		}
 		pubDate := ""
 		if t, err := time.Parse("2006-01-02", p.Date); err == nil {
 			pubDate = t.Format(time.RFC1123Z)
 		}
 		postURL := BuildURL(base, "blog", p.Slug)
-		items = append(items, rssItem{
+		item := rssItem{
 			Title:       p.Title,
 			Link:        postURL,
-			Description: p.Summary,
+			Description: feedDescription(a.Config, p),
 			PubDate:     pubDate,
 			GUID:        postURL,
-		})
+			Creator:     p.Byline(a.Config),
+		}
+		if !updated.IsZero() {
+			item.Updated = updated.Format(time.RFC3339)
+		}
+		if p.CanonicalURL != "" {
+			item.Source = &rssSource{URL: p.CanonicalURL, Name: p.OriginalSource()}
+		}
+		if img := p.SocialImage(); img != "" {
+			item.Media = &rssMedia{URL: AbsoluteURL(base, a.Config.mediaURL(img)), Medium: "image"}
+		}
+		items = append(items, item)
 	}
 	feed := rssXML{
 		Version: "2.0",
+		MediaNS: mediaRSSNamespace,
+		DCNS:    dublinCoreNamespace,
+		AtomNS:  atomNamespace,
 		Channel: rssChannel{
-			Title:       a.Config.Name,
-			Link:        base,
+			Title:       title,
+			Link:        link,
 			Description: a.Config.Description,
 			Items:       items,
 		},
 	}
-	c.Response().Header().Set(echo.HeaderContentType, "application/rss+xml; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-	c.Response().Write([]byte(xml.Header))
-	return xml.NewEncoder(c.Response()).Encode(feed)
+	if !lastBuild.IsZero() {
+		feed.Channel.LastBuild = lastBuild.Format(time.RFC1123Z)
+	}
+	return renderXML(c, "application/rss+xml; charset=utf-8", feed, lastBuild)
+}
+
+// feedDescription returns the excerpt of a feed item with the post's
+// reading time, e.g. "A tour of generics (6 min read)".
+func feedDescription(cfg SiteConfig, p BlogPost) string {
+	excerpt, reading := p.Excerpt(cfg), p.ReadingTime()
+	switch {
+	case reading == "":
+		return excerpt
+	case excerpt == "":
+		return reading
+	}
+	return excerpt + " (" + reading + ")"
+}
+
+// lastModifiedTime parses BlogPost.LastModified, returning the zero time
+// when it is neither RFC3339 nor a "2006-01-02" date.
+func lastModifiedTime(p BlogPost) time.Time {
+	lm := p.LastModified()
+	if t, err := time.Parse(time.RFC3339, lm); err == nil {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02", lm)
+	return t
 }
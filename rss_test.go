@@ -0,0 +1,74 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTagFeed(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app,
+		BlogPost{Slug: "go-post", Title: "Go post", Date: "2024-01-01", Tags: []string{"go"}, Published: true},
+		BlogPost{Slug: "other", Title: "Other post", Date: "2024-01-02", Tags: []string{"web"}, Published: true},
+	)
+
+	rec := serveGet(app, "/feed.xml?tag=Go")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "<title>Blog: go</title>") || !strings.Contains(body, "Go post") || strings.Contains(body, "Other post") {
+		t.Errorf("GET /feed.xml?tag=Go = %d:\n%s", rec.Code, body)
+	}
+}
+
+func TestFeedLastModified(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "one", Title: "One", Date: "2024-01-01", Published: true})
+	post, err := app.Store.GetPost("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		app.Echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	etags := make(map[string]string)
+	for _, path := range []string{"/feed.xml", "/sitemap.xml"} {
+		rec := get(path, "")
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" || rec.Header().Get("Last-Modified") == "" {
+			t.Fatalf("GET %s = %d, ETag %q, Last-Modified %q", path, rec.Code, etag, rec.Header().Get("Last-Modified"))
+		}
+		if rec := get(path, etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("GET %s with its ETag = %d, want 304", path, rec.Code)
+		}
+		etags[path] = etag
+	}
+	post.Title = "One, edited"
+	if err := app.Store.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	app.Cache.Invalidate()
+	if rec := get("/feed.xml", etags["/feed.xml"]); rec.Code != http.StatusOK {
+		t.Errorf("GET /feed.xml after an edit with the old ETag = %d, want 200", rec.Code)
+	}
+
+	post, _ = app.Store.GetPost("one")
+	if feed := get("/feed.xml", "").Body.String(); !strings.Contains(feed, "<atom:updated>"+post.UpdatedAt+"</atom:updated>") ||
+		!strings.Contains(feed, `xmlns:atom="http://www.w3.org/2005/Atom"`) {
+		t.Errorf("feed item missing atom:updated %s:\n%s", post.UpdatedAt, feed)
+	}
+	sitemap := get("/sitemap.xml", "").Body.String()
+	if want := "<loc>" + BuildURL(app.Config.URL) + "</loc><lastmod>" + post.UpdatedAt + "</lastmod>"; !strings.Contains(sitemap, want) {
+		t.Errorf("sitemap missing home page lastmod %s:\n%s", post.UpdatedAt, sitemap)
+	}
+	if post.LastEdited() != post.UpdatedAt[:10]+" "+post.UpdatedAt[11:16] {
+		t.Errorf("LastEdited() = %q for UpdatedAt %q", post.LastEdited(), post.UpdatedAt)
+	}
+}
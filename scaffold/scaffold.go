@@ -9,3 +9,9 @@ import "embed"
 //
 //go:embed all:templates
 var Templates embed.FS
+
+// Deploy contains optional deployment template files, one directory per
+// target (e.g. deploy/docker). They use the same conventions as Templates.
+//
+//go:embed all:deploy
+var Deploy embed.FS
@@ -0,0 +1,178 @@
+package pubengine
+
+import "time"
+
+// publishCheckInterval is how often due scheduled posts are published.
+const publishCheckInterval = time.Minute
+
+// publishAtInputLayout is the format of datetime-local form inputs.
+const publishAtInputLayout = "2006-01-02T15:04"
+
+// Scheduled reports whether the post is a draft waiting to be published at
+// PublishAt.
+func (p BlogPost) Scheduled() bool {
+	return !p.Published && p.PublishAt != ""
+}
+
+// PublishTime returns the time a scheduled post is published at, and the
+// zero time for other posts.
+func (p BlogPost) PublishTime() time.Time {
+	if !p.Scheduled() {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, p.PublishAt)
+	return t
+}
+
+// PublishAtInput returns the time a scheduled post is published at in the
+// server's time zone, as the value of a datetime-local input, or "".
+func (p BlogPost) PublishAtInput() string {
+	t := p.PublishTime()
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(time.Local).Format(publishAtInputLayout)
+}
+
+// parsePublishAt parses the value of a datetime-local input in the
+// server's time zone. An empty value is the zero time.
+func parsePublishAt(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(publishAtInputLayout, value, time.Local)
+}
+
+// SchedulePost schedules the draft slug to be published at at, and dates
+// it the day of at in the server's time zone. The zero time unschedules
+// it, leaving its date. It returns sql.ErrNoRows if there is no draft with
+// the slug outside the trash.
+func (s *Store) SchedulePost(slug string, at time.Time) error {
+	if at.IsZero() {
+		return s.updatePost(`UPDATE posts SET publish_at = '', version = version + 1 WHERE slug = ? AND published = 0 AND trashed_at = ''`, slug)
+	}
+	return s.updatePost(`UPDATE posts SET publish_at = ?, date = ?, version = version + 1 WHERE slug = ? AND published = 0 AND trashed_at = ''`,
+		at.UTC().Format(time.RFC3339), at.In(time.Local).Format("2006-01-02"), slug)
+}
+
+// PublishDuePosts publishes the scheduled drafts whose PublishAt is now or
+// earlier and returns them. Each post is published by a single update, so
+// instances sharing a database never publish a post twice.
+func (s *Store) PublishDuePosts(now time.Time) ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT slug, publish_at FROM posts WHERE published = 0 AND publish_at != '' AND publish_at <= ? AND trashed_at = ''`,
+		now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	due := make(map[string]string)
+	for rows.Next() {
+		var slug, at string
+		if err := rows.Scan(&slug, &at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due[slug] = at
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var published []BlogPost
+	for slug, at := range due {
+		res, err := s.db.Exec(`UPDATE posts SET published = 1, publish_at = '', updated_at = ?, version = version + 1 WHERE slug = ? AND published = 0 AND publish_at = ?`,
+			now.UTC().Format(time.RFC3339), slug, at)
+		if err != nil {
+			return published, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return published, err
+		} else if n == 0 {
+			continue // published, rescheduled or trashed meanwhile
+		}
+		post, err := s.GetPost(slug)
+		if err != nil {
+			return published, err
+		}
+		published = append(published, post)
+	}
+	return published, nil
+}
+
+// schedulePost schedules a draft, see Store.SchedulePost. It returns
+// ErrNotFound if there is no draft with the slug.
+func (a *App) schedulePost(slug string, at time.Time) error {
+	if s, ok := a.Content.(postScheduler); ok {
+		return s.SchedulePost(slug, at)
+	}
+	post, err := a.Content.GetPostAny(slug)
+	if err != nil {
+		return err
+	}
+	if post.Published {
+		return ErrNotFound
+	}
+	post.PublishAt = ""
+	if !at.IsZero() {
+		post.PublishAt = at.UTC().Format(time.RFC3339)
+		post.Date = at.In(time.Local).Format("2006-01-02")
+	}
+	return a.Content.SavePost(post)
+}
+
+// publishDuePosts publishes the scheduled drafts that are due, see
+// Store.PublishDuePosts.
+func (a *App) publishDuePosts(now time.Time) ([]BlogPost, error) {
+	if s, ok := a.Content.(postScheduler); ok {
+		return s.PublishDuePosts(now)
+	}
+	all, err := a.Content.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	var published []BlogPost
+	for _, p := range all {
+		if at := p.PublishTime(); at.IsZero() || at.After(now) {
+			continue
+		}
+		p.Published = true
+		p.PublishAt = ""
+		if err := a.Content.SavePost(p); err != nil {
+			return published, err
+		}
+		published = append(published, p)
+	}
+	return published, nil
+}
+
+// startPublisher publishes scheduled posts when they are due, checking now
+// and every interval. Returns a stop function.
+func (a *App) startPublisher(interval time.Duration) func() {
+	check := func() {
+		posts, err := a.publishDuePosts(time.Now())
+		if len(posts) > 0 {
+			a.Cache.Invalidate()
+			a.purge(posts...)
+		}
+		if err != nil {
+			a.Echo.Logger.Errorf("publish scheduled posts: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		check()
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
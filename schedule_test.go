@@ -0,0 +1,200 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+func TestSchedulePost(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	for _, p := range []BlogPost{
+		{Slug: "draft", Title: "Draft", Date: "2024-01-01"},
+		{Slug: "live", Title: "Live", Date: "2024-01-01", Published: true},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	at := time.Date(2030, 5, 6, 9, 30, 0, 0, time.Local)
+	if err := s.SchedulePost("draft", at); err != nil {
+		t.Fatal(err)
+	}
+	post, err := s.GetPostAny("draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.Scheduled() || !post.PublishTime().Equal(at) || post.Date != "2030-05-06" {
+		t.Errorf("scheduled post = %q at %q, want 2030-05-06 at %v", post.Date, post.PublishAt, at)
+	}
+	if got := post.PublishAtInput(); got != "2030-05-06T09:30" {
+		t.Errorf("PublishAtInput() = %q", got)
+	}
+	if err := s.SchedulePost("live", at); err != ErrNotFound {
+		t.Errorf("SchedulePost(published post) = %v, want ErrNotFound", err)
+	}
+
+	// Saving the post keeps its schedule; publishing it drops it.
+	post.Title = "Draft, edited"
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if post, _ := s.GetPostAny("draft"); !post.Scheduled() {
+		t.Error("saving a scheduled post unscheduled it")
+	}
+	if _, err := s.BulkUpdatePublished([]string{"draft"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if post, _ := s.GetPostAny("draft"); post.PublishAt != "" {
+		t.Errorf("published post keeps PublishAt %q", post.PublishAt)
+	}
+
+	if err := s.SavePost(BlogPost{Slug: "later", Title: "Later", Date: "2024-01-01", PublishAt: at.UTC().Format(time.RFC3339)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SchedulePost("later", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if post, _ := s.GetPostAny("later"); post.Scheduled() || post.Date != "2024-01-01" {
+		t.Errorf("unscheduled post = %q at %q", post.Date, post.PublishAt)
+	}
+}
+
+func TestPublishDuePosts(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	now := time.Now()
+	for _, p := range []BlogPost{
+		{Slug: "due", Title: "Due", Date: "2024-01-01", PublishAt: now.Add(-time.Minute).UTC().Format(time.RFC3339)},
+		{Slug: "later", Title: "Later", Date: "2024-01-01", PublishAt: now.Add(time.Hour).UTC().Format(time.RFC3339)},
+		{Slug: "draft", Title: "Draft", Date: "2024-01-01"},
+		{Slug: "trashed", Title: "Trashed", Date: "2024-01-01", PublishAt: now.Add(-time.Minute).UTC().Format(time.RFC3339)},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TrashPost("trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	published, err := s.PublishDuePosts(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0].Slug != "due" || !published[0].Published || published[0].PublishAt != "" {
+		t.Fatalf("PublishDuePosts() = %+v, want due published", published)
+	}
+	for slug, want := range map[string]bool{"due": true, "later": false, "draft": false, "trashed": false} {
+		if post, err := s.GetPostAny(slug); err != nil || post.Published != want {
+			t.Errorf("%s published = %v, %v, want %v", slug, post.Published, err, want)
+		}
+	}
+	// A post is published once, even when checked again.
+	if published, err := s.PublishDuePosts(now); err != nil || len(published) != 0 {
+		t.Errorf("second PublishDuePosts() = %+v, %v, want none", published, err)
+	}
+	if published, err := s.PublishDuePosts(now.Add(2 * time.Hour)); err != nil || len(published) != 1 || published[0].Slug != "later" {
+		t.Errorf("PublishDuePosts(in 2 hours) = %+v, %v, want later", published, err)
+	}
+}
+
+func TestPublishDuePostsFallback(t *testing.T) {
+	app := newSetUpTestApp(t)
+	now := time.Now()
+	mem := &memContent{posts: map[string]BlogPost{
+		"due":   {Slug: "due", Title: "Due", Date: "2024-01-01", PublishAt: now.Add(-time.Minute).UTC().Format(time.RFC3339)},
+		"later": {Slug: "later", Title: "Later", Date: "2024-01-01", PublishAt: now.Add(time.Hour).UTC().Format(time.RFC3339)},
+		"draft": {Slug: "draft", Title: "Draft", Date: "2024-01-01"},
+	}}
+	WithContentStore(mem)(app)
+
+	published, err := app.publishDuePosts(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0].Slug != "due" {
+		t.Fatalf("publishDuePosts() = %+v, want due", published)
+	}
+	if p := mem.posts["due"]; !p.Published || p.PublishAt != "" {
+		t.Errorf("due post = %+v, want published", p)
+	}
+	if mem.posts["later"].Published || mem.posts["draft"].Published {
+		t.Error("publishDuePosts published a post that isn't due")
+	}
+
+	at := now.Add(24 * time.Hour)
+	if err := app.schedulePost("draft", at); err != nil {
+		t.Fatal(err)
+	}
+	if p := mem.posts["draft"]; !p.PublishTime().Equal(at.Truncate(time.Second)) || p.Date != at.Format("2006-01-02") {
+		t.Errorf("scheduled draft = %q at %q", p.Date, p.PublishAt)
+	}
+	if err := app.schedulePost("due", at); err != ErrNotFound {
+		t.Errorf("schedulePost(published post) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestScheduleFromAdmin(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.AdminDashboard = func(_ []BlogPost, msg, _ string) templ.Component { return templ.Raw(msg) }
+	app.Views.AdminCalendar = func(cal CalendarMonth, _ string) templ.Component { return templ.Raw(cal.Month) }
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	session := loginAdmin(t, app)
+
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+	rec := adminRequest(app, session, http.MethodPost, "/admin/save/", url.Values{
+		"slug":       {"soon"},
+		"title":      {"Soon"},
+		"date":       {"2024-01-01"},
+		"publish_at": {at.Format(publishAtInputLayout)},
+	})
+	if rec.Code != http.StatusOK || rec.Body.String() != "saved" {
+		t.Fatalf("save = %d %q", rec.Code, rec.Body.String())
+	}
+	post, err := app.Store.GetPostAny("soon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.PublishTime().Equal(at) || post.Date != at.Format("2006-01-02") {
+		t.Errorf("saved post = %q at %q, want %v", post.Date, post.PublishAt, at)
+	}
+	if rec := adminRequest(app, session, http.MethodPost, "/admin/save/", url.Values{
+		"slug": {"bad"}, "title": {"Bad"}, "publish_at": {"tomorrow"},
+	}); rec.Code != http.StatusSeeOther {
+		t.Errorf("save with an invalid publish time = %d, want a redirect", rec.Code)
+	}
+
+	// Dragging a scheduled post on the calendar keeps its time of day.
+	day := at.AddDate(0, 0, 3)
+	rec = adminRequest(app, session, http.MethodPost, "/admin/calendar/move/", url.Values{
+		"slug": {"soon"}, "date": {day.Format("2006-01-02")},
+	})
+	if rec.Code != http.StatusOK || rec.Body.String() != day.Format("2006-01") {
+		t.Fatalf("move = %d %q", rec.Code, rec.Body.String())
+	}
+	if post, _ := app.Store.GetPostAny("soon"); !post.PublishTime().Equal(day) || post.Date != day.Format("2006-01-02") || post.Published {
+		t.Errorf("moved post = %q at %q, want %v", post.Date, post.PublishAt, day)
+	}
+	rec = adminRequest(app, session, http.MethodPost, "/admin/calendar/move/", url.Values{
+		"slug": {"soon"}, "date": {time.Now().AddDate(0, 0, -1).Format("2006-01-02")},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("move into the past = %d, want 400", rec.Code)
+	}
+	if post, _ := app.Store.GetPostAny("soon"); !post.PublishTime().Equal(day) {
+		t.Errorf("post moved into the past is scheduled at %q", post.PublishAt)
+	}
+	if rec := adminRequest(app, session, http.MethodPost, "/admin/calendar/move/", url.Values{
+		"slug": {"missing"}, "date": {"2024-01-01"},
+	}); rec.Code != http.StatusNotFound {
+		t.Errorf("move of a missing post = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package pubengine
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxSearchResults is how many posts a search returns at most.
+const maxSearchResults = 50
+
+// maxSearchTerms is how many words of a query a search looks for; the rest
+// are ignored.
+const maxSearchTerms = 10
+
+// searchTerms splits a search query into lowercase words.
+func searchTerms(query string) []string {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// createSearchIndex creates posts_fts, the full-text index of the posts,
+// with the triggers keeping it in step with the posts table, and fills it.
+// Only SQLite built with FTS5 gets one; SearchPosts falls back to LIKE
+// without it.
+func (s *Store) createSearchIndex() error {
+	if s.db.dialect != dialectSQLite {
+		return nil
+	}
+	if ok, err := s.db.exists(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`); err != nil || !ok {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(slug UNINDEXED, title, summary, content, tags)`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (slug, title, summary, content, tags) VALUES (new.slug, new.title, new.summary, new.content, new.tags);
+END`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF slug, title, summary, content, tags ON posts BEGIN
+    DELETE FROM posts_fts WHERE slug = old.slug;
+    INSERT INTO posts_fts (slug, title, summary, content, tags) VALUES (new.slug, new.title, new.summary, new.content, new.tags);
+END`,
+		`CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_fts WHERE slug = old.slug;
+END`,
+		`DELETE FROM posts_fts`,
+		`INSERT INTO posts_fts (slug, title, summary, content, tags) SELECT slug, title, summary, content, tags FROM posts`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasSearchIndex reports whether the database has the posts_fts index.
+func (s *Store) hasSearchIndex() (bool, error) {
+	if s.db.dialect != dialectSQLite {
+		return false, nil
+	}
+	return s.db.exists(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts'`)
+}
+
+// SearchPosts returns the published posts, without their content, whose
+// title, summary, content or tags contain every word of query, at most
+// maxSearchResults. On SQLite with FTS5 the full-text index is searched,
+// words match at the start of words, and the best matches come first. On
+// other databases words match anywhere with LIKE and the newest posts come
+// first. An empty query finds nothing.
+func (s *Store) SearchPosts(query string) ([]BlogPost, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if s.fts {
+		return s.searchPostsFTS(terms)
+	}
+	return s.searchPostsLike(terms)
+}
+
+// searchPostsFTS searches posts_fts for terms, each quoted so that FTS5
+// syntax in a query is searched for rather than run.
+func (s *Store) searchPostsFTS(terms []string) ([]BlogPost, error) {
+	match := make([]string, len(terms))
+	for i, t := range terms {
+		match[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"*`
+	}
+	return s.queryPosts(`SELECT `+s.summaryColumns+` FROM posts
+JOIN (SELECT slug AS hit, rank AS score FROM posts_fts WHERE posts_fts MATCH ?) AS hits ON hits.hit = posts.slug
+WHERE published = 1 AND trashed_at = '' ORDER BY hits.score LIMIT ?`, strings.Join(match, " "), maxSearchResults)
+}
+
+// searchPostsLike searches the posts for terms with LIKE, for databases
+// without a full-text index.
+func (s *Store) searchPostsLike(terms []string) ([]BlogPost, error) {
+	var conds []string
+	var args []any
+	escape := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	for _, t := range terms {
+		pattern := "%" + escape.Replace(t) + "%"
+		conds = append(conds, `(lower(title) LIKE ? ESCAPE '!' OR lower(summary) LIKE ? ESCAPE '!' OR lower(content) LIKE ? ESCAPE '!' OR lower(tags) LIKE ? ESCAPE '!')`)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	args = append(args, maxSearchResults)
+	return s.queryPosts(`SELECT `+s.summaryColumns+` FROM posts WHERE published = 1 AND trashed_at = '' AND `+
+		strings.Join(conds, " AND ")+` ORDER BY date DESC LIMIT ?`, args...)
+}
+
+// queryPosts returns the posts a query selecting postColumns or
+// summaryColumns finds.
+func (s *Store) queryPosts(query string, args ...any) ([]BlogPost, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// searchPosts returns the published posts matching query, see
+// Store.SearchPosts. ContentStores without SearchPosts are searched in
+// memory, newest first.
+func (a *App) searchPosts(query string) ([]BlogPost, error) {
+	if s, ok := a.Content.(postSearcher); ok {
+		return s.SearchPosts(query)
+	}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	all, err := a.Content.ListPosts("")
+	if err != nil {
+		return nil, err
+	}
+	var posts []BlogPost
+	for _, p := range all {
+		text := strings.ToLower(p.Title + "\n" + p.Summary + "\n" + p.Content + "\n" + strings.Join(p.Tags, " "))
+		matches := true
+		for _, t := range terms {
+			if !strings.Contains(text, t) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			posts = append(posts, p)
+			if len(posts) == maxSearchResults {
+				break
+			}
+		}
+	}
+	return posts, nil
+}
+
+// handleSearch renders the Search view with the published posts matching
+// the q parameter. Result pages are kept out of search engines.
+func (a *App) handleSearch(c echo.Context) error {
+	if a.Views.Search == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	query := strings.TrimSpace(c.QueryParam("q"))
+	posts, err := a.searchPosts(query)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("X-Robots-Tag", "noindex")
+	return Render(c, a.Views.Search(query, posts, a.Config.URL))
+}
@@ -0,0 +1,147 @@
+package pubengine
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+// searchSlugs returns the slugs of the posts s finds for query.
+func searchSlugs(t *testing.T, s *Store, query string) string {
+	t.Helper()
+	posts, err := s.SearchPosts(query)
+	if err != nil {
+		t.Fatalf("SearchPosts(%q): %v", query, err)
+	}
+	var slugs []string
+	for _, p := range posts {
+		if p.Content != "" {
+			t.Errorf("SearchPosts(%q) returned the content of %s", query, p.Slug)
+		}
+		slugs = append(slugs, p.Slug)
+	}
+	return strings.Join(slugs, " ")
+}
+
+func TestSearchPosts(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	if !s.fts {
+		t.Fatal("SQLite store without the posts_fts index")
+	}
+	for _, p := range []BlogPost{
+		{Slug: "goroutines", Title: "Goroutines", Date: "2024-01-01", Tags: []string{"go"}, Summary: "Concurrency in Go", Content: "Channels and goroutines.", Published: true},
+		{Slug: "rust", Title: "Rust ownership", Date: "2024-02-01", Tags: []string{"rust"}, Content: "Borrowing, unlike Go's garbage collector.", Published: true},
+		{Slug: "discount", Title: "50% off", Date: "2024-03-01", Content: "Everything is 50% off today.", Published: true},
+		{Slug: "draft", Title: "Go draft", Date: "2024-04-01", Content: "Unfinished goroutines."},
+		{Slug: "trashed", Title: "Go trashed", Date: "2024-05-01", Content: "Old goroutines.", Published: true},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TrashPost("trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fts := range []bool{true, false} {
+		s.fts = fts
+		for query, want := range map[string]string{
+			"":                 "",
+			"goroutines":       "goroutines",
+			"GOROUTINES":       "goroutines",
+			"concurrency":      "goroutines",
+			"rust":             "rust",
+			"go channels":      "goroutines",
+			"go borrowing":     "rust",
+			"rust goroutines":  "",
+			"50%":              "discount",
+			`"unbalanced`:      "",
+			"NEAR(go rust) OR": "",
+		} {
+			if got := searchSlugs(t, s, query); got != want {
+				t.Errorf("fts %v: SearchPosts(%q) = %q, want %q", fts, query, got, want)
+			}
+		}
+		if got := searchSlugs(t, s, "go"); !strings.Contains(got, "goroutines") || !strings.Contains(got, "rust") || strings.Contains(got, "draft") || strings.Contains(got, "trashed") {
+			t.Errorf("fts %v: SearchPosts(go) = %q, want published posts only", fts, got)
+		}
+	}
+
+	// The index follows edits, renames and deletes.
+	s.fts = true
+	if err := s.SavePost(BlogPost{Slug: "rust", Title: "Rust lifetimes", Date: "2024-02-01", Content: "Lifetimes.", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchSlugs(t, s, "borrowing"); got != "" {
+		t.Errorf("SearchPosts(borrowing) after an edit = %q", got)
+	}
+	if err := s.RenamePost("rust", "rust-lifetimes"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchSlugs(t, s, "lifetimes"); got != "rust-lifetimes" {
+		t.Errorf("SearchPosts(lifetimes) after a rename = %q", got)
+	}
+	if err := s.DeletePost("rust-lifetimes"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchSlugs(t, s, "lifetimes"); got != "" {
+		t.Errorf("SearchPosts(lifetimes) after a delete = %q", got)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.Search = func(query string, posts []BlogPost, _ string) templ.Component {
+		var titles []string
+		for _, p := range posts {
+			titles = append(titles, p.Title)
+		}
+		return templ.Raw(query + ": " + strings.Join(titles, ", "))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Content: "Hello, world.", Published: true},
+		BlogPost{Slug: "bye", Title: "Bye", Date: "2024-01-02", Content: "Goodbye, world.", Published: true},
+	)
+
+	rec := serveGet(app, "/search/?q=hello")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello: Hello" {
+		t.Errorf("GET /search/?q=hello = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Robots-Tag") != "noindex" {
+		t.Errorf("search results aren't noindex: %v", rec.Header())
+	}
+	if rec := serveGet(app, "/search/"); rec.Code != http.StatusOK || rec.Body.String() != ": " {
+		t.Errorf("GET /search/ = %d %q", rec.Code, rec.Body.String())
+	}
+
+	// ContentStores without SearchPosts are searched in memory.
+	WithContentStore(&memContent{posts: map[string]BlogPost{
+		"hello": {Slug: "hello", Title: "Hello", Date: "2024-01-01", Content: "Hello, world.", Published: true},
+		"bye":   {Slug: "bye", Title: "Bye", Date: "2024-01-02", Content: "Goodbye, world.", Tags: []string{"farewell"}, Published: true},
+		"draft": {Slug: "draft", Title: "Draft", Date: "2024-01-03", Content: "Hello again."},
+	}})(app)
+	for query, want := range map[string]string{"hello": "Hello", "WORLD": "Bye Hello", "farewell": "Bye", "": ""} {
+		posts, err := app.searchPosts(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, p := range posts {
+			titles = append(titles, p.Title)
+		}
+		if got := strings.Join(titles, " "); got != want {
+			t.Errorf("in-memory search for %q = %q, want %q", query, got, want)
+		}
+	}
+
+	app = newSetUpTestApp(t)
+	if rec := serveGet(app, "/search/?q=hello"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /search/ without a Search view = %d, want 404", rec.Code)
+	}
+}
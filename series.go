@@ -0,0 +1,237 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Series groups posts that are read in order, like the parts of a
+// tutorial. Posts join a series by setting BlogPost.SeriesSlug.
+type Series struct {
+	Slug        string
+	Title       string
+	Description string
+	Posts       []BlogPost // published posts in reading order; empty from ListSeries
+}
+
+// SeriesNav is a post's place in its series, for "Part 2 of 5" navigation.
+type SeriesNav struct {
+	Slug  string
+	Title string
+	Part  int // 1-based position of the post
+	Total int // number of published posts in the series
+	Prev  *BlogPost
+	Next  *BlogPost
+}
+
+// URL returns the path of the series page.
+func (s Series) URL() string {
+	return "/series/" + s.Slug + "/"
+}
+
+// URL returns the path of the series page.
+func (n SeriesNav) URL() string {
+	return "/series/" + n.Slug + "/"
+}
+
+// SaveSeries upserts a series by slug.
+func (s *Store) SaveSeries(sr Series) error {
+	_, err := s.db.Exec(`INSERT INTO series (slug, title, description) VALUES (?, ?, ?)
+ON CONFLICT(slug) DO UPDATE SET title = excluded.title, description = excluded.description`,
+		sr.Slug, sr.Title, sr.Description)
+	return err
+}
+
+// EnsureSeries creates the series slug, titled after it, unless it exists.
+func (s *Store) EnsureSeries(slug string) error {
+	_, err := s.db.Exec(`INSERT INTO series (slug, title) VALUES (?, ?) ON CONFLICT(slug) DO NOTHING`, slug, seriesTitle(slug))
+	return err
+}
+
+// GetSeries returns a series with its published posts in reading order.
+func (s *Store) GetSeries(slug string) (Series, error) {
+	sr := Series{Slug: slug}
+	if err := s.db.QueryRow(`SELECT title, description FROM series WHERE slug = ?`, slug).Scan(&sr.Title, &sr.Description); err != nil {
+		return Series{}, err
+	}
+	rows, err := s.db.Query(`SELECT `+s.postColumns+` FROM posts WHERE series_slug = ? AND published = 1 AND trashed_at = '' ORDER BY series_order, date, slug`, slug)
+	if err != nil {
+		return Series{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return Series{}, err
+		}
+		sr.Posts = append(sr.Posts, post)
+	}
+	return sr, rows.Err()
+}
+
+// ListSeries returns every series without its posts, ordered by title.
+func (s *Store) ListSeries() ([]Series, error) {
+	rows, err := s.db.Query(`SELECT slug, title, description FROM series ORDER BY title, slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []Series
+	for rows.Next() {
+		var sr Series
+		if err := rows.Scan(&sr.Slug, &sr.Title, &sr.Description); err != nil {
+			return nil, err
+		}
+		series = append(series, sr)
+	}
+	return series, rows.Err()
+}
+
+// DeleteSeries removes a series. Its posts are kept and leave the series.
+func (s *Store) DeleteSeries(slug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE posts SET series_slug = '', series_order = 0 WHERE series_slug = ?`, slug); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM series WHERE slug = ?`, slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// seriesTitle turns a series slug into a title, "go-basics" into
+// "Go basics", for series created from the post form.
+func seriesTitle(slug string) string {
+	title := strings.ReplaceAll(slug, "-", " ")
+	if title == "" {
+		return ""
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+// sortSeriesPosts sorts posts into reading order.
+func sortSeriesPosts(posts []BlogPost) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].SeriesOrder != posts[j].SeriesOrder {
+			return posts[i].SeriesOrder < posts[j].SeriesOrder
+		}
+		if posts[i].Date != posts[j].Date {
+			return posts[i].Date < posts[j].Date
+		}
+		return posts[i].Slug < posts[j].Slug
+	})
+}
+
+// seriesNav returns post's place in sr, or nil when sr doesn't list it.
+func seriesNav(sr Series, post BlogPost) *SeriesNav {
+	for i, p := range sr.Posts {
+		if p.Slug != post.Slug {
+			continue
+		}
+		nav := &SeriesNav{Slug: sr.Slug, Title: sr.Title, Part: i + 1, Total: len(sr.Posts)}
+		if i > 0 {
+			prev := sr.Posts[i-1]
+			nav.Prev = &prev
+		}
+		if i+1 < len(sr.Posts) {
+			next := sr.Posts[i+1]
+			nav.Next = &next
+		}
+		return nav
+	}
+	return nil
+}
+
+// withSeries sets post.Series from the cache when the post is in a series.
+func (a *App) withSeries(post BlogPost) (BlogPost, error) {
+	if post.SeriesSlug == "" {
+		return post, nil
+	}
+	sr, err := a.Cache.GetSeries(post.SeriesSlug)
+	if err == sql.ErrNoRows {
+		return post, nil
+	}
+	if err != nil {
+		return post, err
+	}
+	post.Series = seriesNav(sr, post)
+	return post, nil
+}
+
+func (a *App) handleSeries(c echo.Context) error {
+	if a.Views.Series == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	sr, err := a.Cache.GetSeries(c.Param("slug"))
+	if err == sql.ErrNoRows || err == nil && len(sr.Posts) == 0 {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.Series(sr, a.Config.URL))
+}
+
+func (a *App) handleSeriesList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderSeriesList(c)
+}
+
+func (a *App) handleSeriesSave(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	sr := Series{
+		Slug:        strings.TrimSpace(c.FormValue("slug")),
+		Title:       strings.TrimSpace(c.FormValue("title")),
+		Description: strings.TrimSpace(c.FormValue("description")),
+	}
+	if sr.Slug == "" {
+		sr.Slug = sr.Title
+	}
+	sr.Slug = Slugify(sr.Slug)
+	if msg := ValidateSlug(sr.Slug); msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	if sr.Title == "" {
+		sr.Title = seriesTitle(sr.Slug)
+	}
+	if err := a.Store.SaveSeries(sr); err != nil {
+		return err
+	}
+	a.Cache.Invalidate()
+	return a.renderSeriesList(c)
+}
+
+func (a *App) handleSeriesDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if err := a.Store.DeleteSeries(c.Param("slug")); err != nil {
+		return err
+	}
+	a.Cache.Invalidate()
+	return a.renderSeriesList(c)
+}
+
+func (a *App) renderSeriesList(c echo.Context) error {
+	if a.Views.AdminSeries == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	series, err := a.Store.ListSeries()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminSeries(series, CsrfToken(c)))
+}
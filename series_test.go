@@ -0,0 +1,82 @@
+package pubengine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestSeries(t *testing.T) {
+	app := newTestApp(t)
+	app.Views.Post = func(p BlogPost, _ []BlogPost, _ string) templ.Component {
+		if p.Series == nil {
+			return templ.Raw(p.Title)
+		}
+		var prev, next string
+		if p.Series.Prev != nil {
+			prev = p.Series.Prev.Slug
+		}
+		if p.Series.Next != nil {
+			next = p.Series.Next.Slug
+		}
+		return templ.Raw(fmt.Sprintf("%s: part %d of %d in %s, prev %q, next %q", p.Title, p.Series.Part, p.Series.Total, p.Series.Title, prev, next))
+	}
+	app.Views.Series = func(sr Series, _ string) templ.Component {
+		var slugs []string
+		for _, p := range sr.Posts {
+			slugs = append(slugs, p.Slug)
+		}
+		return templ.Raw(sr.Title + ": " + strings.Join(slugs, ","))
+	}
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	savePosts(t, app,
+		BlogPost{Slug: "intro", Title: "Intro", Date: "2024-03-01", SeriesSlug: "go-basics", SeriesOrder: 1, Published: true},
+		BlogPost{Slug: "types", Title: "Types", Date: "2024-01-01", SeriesSlug: "go-basics", SeriesOrder: 2, Published: true},
+		BlogPost{Slug: "draft", Title: "Draft", Date: "2024-02-01", SeriesSlug: "go-basics", SeriesOrder: 3},
+		BlogPost{Slug: "loops", Title: "Loops", Date: "2024-04-01", SeriesSlug: "go-basics", SeriesOrder: 4, Published: true},
+		BlogPost{Slug: "other", Title: "Other", Date: "2024-04-02", Published: true},
+	)
+
+	get := func(path string) (int, string) {
+		rec := serveGet(app, path)
+		return rec.Code, rec.Body.String()
+	}
+	if code, body := get("/blog/types/"); code != http.StatusOK || body != `Types: part 2 of 3 in Go basics, prev "intro", next "loops"` {
+		t.Errorf("GET /blog/types/ = %d %q", code, body)
+	}
+	if code, body := get("/blog/other/"); code != http.StatusOK || body != "Other" {
+		t.Errorf("GET /blog/other/ = %d %q", code, body)
+	}
+	if code, body := get("/series/go-basics/"); code != http.StatusOK || body != "Go basics: intro,types,loops" {
+		t.Errorf("GET /series/go-basics/ = %d %q", code, body)
+	}
+	if code, _ := get("/series/missing/"); code != http.StatusNotFound {
+		t.Errorf("GET /series/missing/ = %d, want 404", code)
+	}
+
+	if err := app.Store.SaveSeries(Series{Slug: "go-basics", Title: "Learning Go", Description: "From zero"}); err != nil {
+		t.Fatal(err)
+	}
+	sr, err := app.Store.GetSeries("go-basics")
+	if err != nil {
+		t.Fatalf("GetSeries: %v", err)
+	}
+	if sr.Title != "Learning Go" || sr.Description != "From zero" || len(sr.Posts) != 3 {
+		t.Errorf("GetSeries = %+v", sr)
+	}
+	if err := app.Store.DeleteSeries("go-basics"); err != nil {
+		t.Fatalf("DeleteSeries: %v", err)
+	}
+	app.Cache.Invalidate()
+	if p, _ := app.Store.GetPost("intro"); p.SeriesSlug != "" || p.SeriesOrder != 0 {
+		t.Errorf("post still in deleted series: %q %d", p.SeriesSlug, p.SeriesOrder)
+	}
+	if code, _ := get("/series/go-basics/"); code != http.StatusNotFound {
+		t.Errorf("GET deleted series = %d, want 404", code)
+	}
+}
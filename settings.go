@@ -0,0 +1,157 @@
+package pubengine
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AICrawlers are the user agents of AI crawlers that can be blocked in
+// robots.txt from the admin settings page.
+var AICrawlers = []string{
+	"GPTBot",
+	"ChatGPT-User",
+	"OAI-SearchBot",
+	"ClaudeBot",
+	"anthropic-ai",
+	"CCBot",
+	"Google-Extended",
+	"Applebot-Extended",
+	"PerplexityBot",
+	"Bytespider",
+	"Amazonbot",
+	"meta-externalagent",
+}
+
+// Settings keys in the blog database.
+const (
+	settingCitation        = "llms_citation"
+	settingBlockedCrawlers = "blocked_crawlers"
+	settingDevtoAPIKey     = "devto_api_key"
+	settingHashnodeToken   = "hashnode_token"
+	settingHashnodePubID   = "hashnode_publication_id"
+	settingHomeLayout      = "home_layout"
+)
+
+// maxCitationLength limits the citation text shown in llms.txt.
+const maxCitationLength = 1000
+
+// SiteSettings are the settings managed from the admin settings page.
+type SiteSettings struct {
+	Citation string        // how to cite the site, added to llms.txt
+	Crawlers []CrawlerRule // one per AICrawlers entry, in that order
+
+	DevtoAPIKey           string // dev.to API key, for cross-posting
+	HashnodeToken         string // Hashnode personal access token, for cross-posting
+	HashnodePublicationID string // ID of the Hashnode blog to cross-post to
+
+	HomeLayout string // sections of the home page, one per line; empty for the plain list of posts
+}
+
+// CrawlerRule is an AI crawler and whether robots.txt disallows it.
+type CrawlerRule struct {
+	Name    string
+	Blocked bool
+}
+
+// BlockedCrawlers returns the names of the crawlers robots.txt disallows.
+func (s SiteSettings) BlockedCrawlers() []string {
+	var names []string
+	for _, r := range s.Crawlers {
+		if r.Blocked {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// siteSettings loads the site settings from the store.
+func (a *App) siteSettings() (SiteSettings, error) {
+	citation, err := a.Store.GetSetting(settingCitation)
+	if err != nil {
+		return SiteSettings{}, err
+	}
+	blocked, err := a.Store.GetSetting(settingBlockedCrawlers)
+	if err != nil {
+		return SiteSettings{}, err
+	}
+	names := strings.Split(blocked, ",")
+	settings := SiteSettings{Citation: citation}
+	for key, dst := range map[string]*string{
+		settingDevtoAPIKey:   &settings.DevtoAPIKey,
+		settingHashnodeToken: &settings.HashnodeToken,
+		settingHashnodePubID: &settings.HashnodePublicationID,
+		settingHomeLayout:    &settings.HomeLayout,
+	} {
+		if *dst, err = a.Store.GetSetting(key); err != nil {
+			return SiteSettings{}, err
+		}
+	}
+	for _, name := range AICrawlers {
+		settings.Crawlers = append(settings.Crawlers, CrawlerRule{Name: name, Blocked: slices.Contains(names, name)})
+	}
+	return settings, nil
+}
+
+func (a *App) handleSettings(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderSettings(c, "")
+}
+
+func (a *App) handleSettingsSave(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	citation := strings.TrimSpace(c.FormValue("citation"))
+	if len(citation) > maxCitationLength {
+		return c.String(http.StatusBadRequest, "Citation is too long (max 1000 characters)")
+	}
+	homeLayout := strings.TrimSpace(c.FormValue("home_layout"))
+	if _, err := parseHomeLayout(homeLayout); err != nil {
+		return c.String(http.StatusBadRequest, "Home layout: "+err.Error())
+	}
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	var blocked []string
+	for _, name := range AICrawlers {
+		if slices.Contains(form["blocked"], name) {
+			blocked = append(blocked, name)
+		}
+	}
+	if err := a.Store.SetSetting(settingCitation, citation); err != nil {
+		return err
+	}
+	if err := a.Store.SetSetting(settingBlockedCrawlers, strings.Join(blocked, ",")); err != nil {
+		return err
+	}
+	if err := a.Store.SetSetting(settingHomeLayout, homeLayout); err != nil {
+		return err
+	}
+	for key, field := range map[string]string{
+		settingDevtoAPIKey:   "devto_api_key",
+		settingHashnodeToken: "hashnode_token",
+		settingHashnodePubID: "hashnode_publication_id",
+	} {
+		if err := a.Store.SetSetting(key, strings.TrimSpace(c.FormValue(field))); err != nil {
+			return err
+		}
+	}
+	return a.renderSettings(c, "saved")
+}
+
+func (a *App) renderSettings(c echo.Context, msg string) error {
+	if a.Views.AdminSettings == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	settings, err := a.siteSettings()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminSettings(settings, msg, CsrfToken(c)))
+}
@@ -0,0 +1,181 @@
+package pubengine
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/html"
+)
+
+// Lengths beyond which social networks cut off shared titles and descriptions.
+const (
+	maxShareTitle       = 70
+	maxShareDescription = 200
+)
+
+// SharePreview is what social networks see when a post is shared, extracted
+// from the post page's OpenGraph, Twitter card and JSON-LD tags.
+type SharePreview struct {
+	Post        BlogPost
+	Title       string   // og:title, else twitter:title, else <title>
+	Description string   // og:description, else twitter:description, else meta description
+	Image       string   // og:image, else twitter:image
+	URL         string   // og:url, else the canonical link
+	SiteName    string   // og:site_name
+	Card        string   // twitter:card
+	JSONLD      []string // @type of each JSON-LD block
+	Warnings    []string
+}
+
+// handleSharePreview renders a post with the Post view, as visitors get it,
+// and shows the share preview extracted from it. Drafts can be checked
+// before they are published.
+func (a *App) handleSharePreview(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminSharePreview == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	post, err := a.Content.GetPostAny(c.Param("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	posts, err := a.Cache.ListPosts("")
+	if err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err := a.Views.Post(post, posts, a.Config.URL).Render(c.Request().Context(), &page); err != nil {
+		return err
+	}
+	p := extractSharePreview(page.Bytes())
+	p.Post = post
+	p.Warnings = shareWarnings(p, BuildURL(a.Config.URL, "blog", post.Slug))
+	if !post.Published {
+		p.Warnings = append(p.Warnings, "The post is a draft; its URL returns 404 until it is published.")
+	}
+	return Render(c, a.Views.AdminSharePreview(p))
+}
+
+// extractSharePreview reads the share metadata from the <head> of an HTML page.
+func extractSharePreview(page []byte) SharePreview {
+	var p SharePreview
+	meta := make(map[string]string)
+	var title, canonical string
+	var jsonLD []string
+
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tok := z.Token()
+		attr := func(name string) string {
+			for _, a := range tok.Attr {
+				if a.Key == name {
+					return a.Val
+				}
+			}
+			return ""
+		}
+		switch tok.Data {
+		case "meta":
+			key := attr("property")
+			if key == "" {
+				key = attr("name")
+			}
+			if _, seen := meta[key]; key != "" && !seen {
+				meta[key] = attr("content")
+			}
+		case "link":
+			if attr("rel") == "canonical" && canonical == "" {
+				canonical = attr("href")
+			}
+		case "title":
+			if title == "" && z.Next() == html.TextToken {
+				title = strings.TrimSpace(string(z.Text()))
+			}
+		case "script":
+			if attr("type") == "application/ld+json" && z.Next() == html.TextToken {
+				jsonLD = append(jsonLD, string(z.Text()))
+			}
+		}
+	}
+
+	first := func(vals ...string) string {
+		for _, v := range vals {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p.Title = first(meta["og:title"], meta["twitter:title"], title)
+	p.Description = first(meta["og:description"], meta["twitter:description"], meta["description"])
+	p.Image = first(meta["og:image"], meta["twitter:image"])
+	p.URL = first(meta["og:url"], canonical)
+	p.SiteName = meta["og:site_name"]
+	p.Card = meta["twitter:card"]
+
+	for _, block := range jsonLD {
+		var data struct {
+			Type string `json:"@type"`
+		}
+		if err := json.Unmarshal([]byte(block), &data); err != nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("JSON-LD is not valid JSON: %v", err))
+			continue
+		}
+		p.JSONLD = append(p.JSONLD, data.Type)
+	}
+	if meta["og:title"] == "" {
+		p.Warnings = append(p.Warnings, "No og:title tag; networks fall back to the page title.")
+	}
+	return p
+}
+
+// shareWarnings reports problems with a share preview of the post at postURL.
+func shareWarnings(p SharePreview, postURL string) []string {
+	warnings := p.Warnings
+	if p.Title == "" {
+		warnings = append(warnings, "No title.")
+	} else if n := utf8.RuneCountInString(p.Title); n > maxShareTitle {
+		warnings = append(warnings, fmt.Sprintf("Title is %d characters; networks cut it off after about %d.", n, maxShareTitle))
+	}
+	if p.Description == "" {
+		warnings = append(warnings, "No description. Add a summary to the post.")
+	} else if n := utf8.RuneCountInString(p.Description); n > maxShareDescription {
+		warnings = append(warnings, fmt.Sprintf("Description is %d characters; networks cut it off after about %d.", n, maxShareDescription))
+	}
+	if p.Image == "" {
+		warnings = append(warnings, "No image. Set a social image or add an image to the post.")
+	} else if u, err := url.Parse(p.Image); err != nil || !u.IsAbs() {
+		warnings = append(warnings, fmt.Sprintf("Image %q is not an absolute URL.", p.Image))
+	}
+	if p.URL == "" {
+		warnings = append(warnings, "No og:url or canonical link.")
+	} else if p.URL != postURL {
+		warnings = append(warnings, fmt.Sprintf("URL %q differs from the post URL %q.", p.URL, postURL))
+	}
+	if p.Card == "" {
+		warnings = append(warnings, "No twitter:card tag.")
+	}
+	if len(p.JSONLD) == 0 {
+		warnings = append(warnings, "No JSON-LD structured data.")
+	}
+	return warnings
+}
@@ -0,0 +1,53 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/eringen/pubengine/analytics"
+	"github.com/labstack/echo/v4"
+)
+
+// ShortURL returns the short link of a post with the given code, e.g.
+// "https://example.com/s/aB3x", or "" if code is empty.
+func ShortURL(base, code string) string {
+	if code == "" {
+		return ""
+	}
+	return AbsoluteURL(BuildURL(base), "s/"+code)
+}
+
+// handleShortLink permanently redirects a short link to its published post
+// and records the hit in analytics.
+func (a *App) handleShortLink(c echo.Context) error {
+	code := c.Param("code")
+	slug, err := a.Store.ShortCodeSlug(code)
+	if err == nil {
+		_, err = a.Cache.GetPost(slug)
+	}
+	if err == sql.ErrNoRows {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	req := c.Request()
+	if a.analyticsStore != nil && !analytics.IsBot(req.UserAgent()) {
+		hit := &analytics.ShortLinkHit{
+			Code:      code,
+			Slug:      slug,
+			IPHash:    analytics.HashIP(c.RealIP()),
+			Referrer:  analytics.CleanReferrer(req.Referer()),
+			Timestamp: time.Now().UTC(),
+		}
+		if err := a.analyticsStore.SaveShortLinkHit(hit); err != nil {
+			c.Logger().Errorf("record short link hit: %v", err)
+		}
+	}
+	// Browsers cache permanent redirects; no-store makes every follow
+	// reach the server and count.
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Redirect(http.StatusMovedPermanently, BuildURL(a.Config.URL, "blog", slug))
+}
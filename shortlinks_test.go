@@ -0,0 +1,27 @@
+package pubengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShortLinkRedirect(t *testing.T) {
+	app := newSetUpTestApp(t)
+	savePosts(t, app, BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Published: true})
+	post, err := app.Store.GetPost("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serveGet(app, "/s/"+post.ShortCode)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "http://localhost:3000/blog/hello/" {
+		t.Errorf("GET /s/%s = %d to %q, want a 301 to the post", post.ShortCode, rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	app.Echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/s/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /s/nope = %d, want 404", rec.Code)
+	}
+}
@@ -2,7 +2,7 @@ package pubengine
 
 import (
 	"encoding/xml"
-	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -18,23 +18,33 @@ type sitemapURL struct {
 	LastMod string `xml:"lastmod,omitempty"`
 }
 
+// renderSitemap writes the home page and posts as a sitemap, leaving out
+// posts excluded from it or not to be indexed, and cross-posts unless
+// SiteConfig.SitemapCrossPosts is set. Each post's lastmod is when it was
+// last saved, and the home page's that of the newest listed post, so
+// crawlers only fetch what changed.
 func (a *App) renderSitemap(c echo.Context, posts []BlogPost) error {
 	base := a.Config.URL
 	urls := []sitemapURL{
 		{Loc: BuildURL(base)},
 	}
+	var newest time.Time
 	for _, p := range posts {
+		if p.ExcludeFromSitemap || p.NoIndex || p.CanonicalURL != "" && !a.Config.SitemapCrossPosts {
+			continue
+		}
+		if t := lastModifiedTime(p); t.After(newest) {
+			newest = t
+			urls[0].LastMod = p.LastModified()
+		}
 		urls = append(urls, sitemapURL{
 			Loc:     BuildURL(base, "blog", p.Slug),
-			LastMod: p.Date,
+			LastMod: p.LastModified(),
 		})
 	}
 	sitemap := sitemapURLSet{
 		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
 		URLs:  urls,
 	}
-	c.Response().Header().Set(echo.HeaderContentType, "application/xml; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-	c.Response().Write([]byte(xml.Header))
-	return xml.NewEncoder(c.Response()).Encode(sitemap)
+	return renderXML(c, "application/xml; charset=utf-8", sitemap, newest)
 }
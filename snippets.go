@@ -0,0 +1,106 @@
+package pubengine
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxSnippetNameLength limits snippet names shown in the admin.
+const maxSnippetNameLength = 64
+
+// snippetDatePlaceholder is replaced with today's date when a template is used.
+const snippetDatePlaceholder = "{{date}}"
+
+// NewPost returns the draft post a template starts. Frontmatter in the
+// template fills the post fields; without it the whole template becomes the
+// content. "{{date}}" is replaced with the given date throughout.
+func (sn Snippet) NewPost(date string) BlogPost {
+	text := strings.ReplaceAll(sn.Content, snippetDatePlaceholder, date)
+	post, err := parseFrontmatter([]byte(text))
+	if err != nil {
+		return BlogPost{Date: date, Content: text}
+	}
+	if post.Slug == "" && post.Title != "" {
+		post.Slug = Slugify(post.Title)
+	}
+	if post.Date == "" {
+		post.Date = date
+	}
+	post.Published = false
+	return post
+}
+
+func (a *App) handleSnippetList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderSnippetList(c)
+}
+
+func (a *App) handleSnippetSave(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" {
+		return c.String(http.StatusBadRequest, "Snippet name is required")
+	}
+	if len(name) > maxSnippetNameLength {
+		return c.String(http.StatusBadRequest, "Snippet name is too long (max 64 characters)")
+	}
+	if err := a.Store.SaveSnippet(Snippet{
+		Name:      name,
+		Template:  c.FormValue("template") != "",
+		Content:   c.FormValue("content"),
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		return err
+	}
+	return a.renderSnippetList(c)
+}
+
+func (a *App) handleSnippetDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	name := c.QueryParam("name")
+	if name == "" {
+		return c.String(http.StatusBadRequest, "Snippet name required")
+	}
+	if err := a.Store.DeleteSnippet(name); err != nil {
+		return err
+	}
+	return a.renderSnippetList(c)
+}
+
+func (a *App) renderSnippetList(c echo.Context) error {
+	if a.Views.AdminSnippets == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	snippets, err := a.Store.ListSnippets()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminSnippets(snippets, CsrfToken(c)))
+}
+
+// newPostFromTemplate returns the empty post for the new post form, or the
+// post started by the named template.
+func (a *App) newPostFromTemplate(name string) (BlogPost, error) {
+	if name == "" {
+		return BlogPost{}, nil
+	}
+	sn, err := a.Store.GetSnippet(name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BlogPost{}, nil
+		}
+		return BlogPost{}, err
+	}
+	return sn.NewPost(time.Now().Format("2006-01-02")), nil
+}
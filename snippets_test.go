@@ -0,0 +1,27 @@
+package pubengine
+
+import "testing"
+
+func TestSnippetNewPost(t *testing.T) {
+	sn := Snippet{Template: true, Content: "---\ntitle: Weekly notes {{date}}\ntags: [weekly]\n---\n\n## Done\n\n## Next\n"}
+	p := sn.NewPost("2024-06-03")
+	if p.Title != "Weekly notes 2024-06-03" || p.Slug != "weekly-notes-2024-06-03" || p.Date != "2024-06-03" {
+		t.Errorf("unexpected post fields: %+v", p)
+	}
+	if len(p.Tags) != 1 || p.Tags[0] != "weekly" {
+		t.Errorf("tags = %q", p.Tags)
+	}
+	if p.Published {
+		t.Error("posts from templates should start as drafts")
+	}
+	if p.Content != "## Done\n\n## Next\n" {
+		t.Errorf("content = %q", p.Content)
+	}
+}
+
+func TestSnippetNewPostWithoutFrontmatter(t *testing.T) {
+	p := Snippet{Content: "Released on {{date}}."}.NewPost("2024-06-03")
+	if p.Title != "" || p.Content != "Released on 2024-06-03." || p.Date != "2024-06-03" {
+		t.Errorf("unexpected post: %+v", p)
+	}
+}
@@ -0,0 +1,35 @@
+package pubengine
+
+import (
+	"errors"
+
+	"github.com/eringen/pubengine/analytics"
+)
+
+// WithSpikeNotifier calls fn when a referrer that never sent traffic before
+// sends a burst of visits, e.g. to send an email when a post reaches Hacker
+// News. It runs alongside SpikeWebhookURL and needs analytics enabled.
+func WithSpikeNotifier(fn func(analytics.ReferrerSpike) error) Option {
+	return func(a *App) {
+		a.spikeNotifiers = append(a.spikeNotifiers, fn)
+	}
+}
+
+// spikeNotify returns a function calling the webhook and every notifier
+// for a spike, or nil when none are configured.
+func (a *App) spikeNotify() func(analytics.ReferrerSpike) error {
+	notifiers := a.spikeNotifiers
+	if a.Config.SpikeWebhookURL != "" {
+		notifiers = append([]func(analytics.ReferrerSpike) error{analytics.WebhookNotifier(a.Config.SpikeWebhookURL)}, notifiers...)
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return func(sp analytics.ReferrerSpike) error {
+		var errs []error
+		for _, fn := range notifiers {
+			errs = append(errs, fn(sp))
+		}
+		return errors.Join(errs...)
+	}
+}
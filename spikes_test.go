@@ -0,0 +1,80 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eringen/pubengine/analytics"
+)
+
+func TestReferrerSpikes(t *testing.T) {
+	var webhook struct {
+		Text     string `json:"text"`
+		Referrer string `json:"referrer"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			t.Errorf("decode webhook: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	app := newTestApp(t)
+	app.Config.AnalyticsEnabled = true
+	app.Config.AnalyticsDatabasePath = filepath.Join(t.TempDir(), "analytics.db")
+	app.Config.SpikeWebhookURL = srv.URL
+	var notified []analytics.ReferrerSpike
+	WithSpikeNotifier(func(sp analytics.ReferrerSpike) error {
+		notified = append(notified, sp)
+		return nil
+	})(app)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	now := time.Now().UTC()
+	visit := func(referrer, path string, at time.Time) {
+		t.Helper()
+		if err := app.analyticsStore.SaveVisit(&analytics.Visit{VisitorID: path + at.String(), Referrer: referrer, Path: path, Timestamp: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 3 {
+		visit("news.ycombinator.com", "/blog/hello/", now.Add(-time.Duration(i)*time.Minute))
+		visit("example.org", "/", now.Add(-time.Duration(i)*time.Minute))
+	}
+	visit("news.ycombinator.com", "/", now.Add(-2*time.Minute))
+	visit("example.org", "/", now.Add(-48*time.Hour)) // seen before, not new
+	visit("Direct", "/", now)
+
+	cfg := analytics.SpikeConfig{Threshold: 3}
+	spikes, err := app.analyticsStore.DetectReferrerSpikes(now, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spikes) != 1 || spikes[0].Referrer != "news.ycombinator.com" || spikes[0].Visits != 4 || spikes[0].Path != "/blog/hello/" {
+		t.Fatalf("spikes = %+v, want 4 visits from news.ycombinator.com to /blog/hello/", spikes)
+	}
+
+	if err := app.spikeNotify()(spikes[0]); err != nil {
+		t.Fatal(err)
+	}
+	if webhook.Referrer != "news.ycombinator.com" || !strings.HasPrefix(webhook.Text, "4 visits from news.ycombinator.com") {
+		t.Errorf("webhook got %+v", webhook)
+	}
+	if len(notified) != 1 {
+		t.Errorf("notifier called %d times, want 1", len(notified))
+	}
+
+	if err := app.analyticsStore.MarkReferrerAlerted(spikes[0].Referrer, now); err != nil {
+		t.Fatal(err)
+	}
+	if spikes, err := app.analyticsStore.DetectReferrerSpikes(now, cfg); err != nil || len(spikes) != 0 {
+		t.Errorf("spikes after alert = %+v, %v; want none", spikes, err)
+	}
+}
@@ -1,24 +1,36 @@
 package pubengine
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"errors"
+	"io"
+	"math/big"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
-// Store wraps a SQLite database and provides CRUD operations for blog posts.
+// Store wraps the blog database, SQLite or PostgreSQL, and provides CRUD
+// operations for blog posts.
 type Store struct {
-	db *sql.DB
+	db             *sqlDB
+	postColumns    string // the posts columns read by scanPost, in the dialect's SQL
+	summaryColumns string // postColumns with an empty string for the content
+	fts            bool   // whether posts are searched with the posts_fts index
 }
 
 // NewStore opens (or creates) the SQLite database at path, ensures the data
-// directory exists, and runs schema migrations.
+// directory exists, and runs schema migrations. Pass ":memory:" for a
+// throwaway in-memory database, e.g. in tests.
 func NewStore(path string) (*Store, error) {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return nil, err
+	memory := path == ":memory:"
+	if !memory {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
 	}
 	db, err := sql.Open("sqlite", path)
 	if err != nil {
@@ -39,10 +51,27 @@ func NewStore(path string) (*Store, error) {
 	}
 	db.SetMaxOpenConns(4)
 	db.SetMaxIdleConns(4)
-	s := &Store{db: db}
-	if err := s.ensureSchema(); err != nil {
+	if memory {
+		// Every connection to :memory: is a separate database, so keep one.
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+	}
+	return newStore(&sqlDB{DB: db, dialect: dialectSQLite})
+}
+
+// newStore runs schema migrations on db and returns a Store using it.
+func newStore(db *sqlDB) (*Store, error) {
+	s := &Store{db: db, postColumns: postColumns(db.dialect), summaryColumns: summaryColumns(db.dialect)}
+	if err := s.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	fts, err := s.hasSearchIndex()
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
+	s.fts = fts
 	return s, nil
 }
 
@@ -51,8 +80,56 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-func (s *Store) ensureSchema() error {
-	_, err := s.db.Exec(`
+// Ping checks that the database answers a query.
+func (s *Store) Ping() error {
+	var one int
+	return s.db.QueryRow(`SELECT 1`).Scan(&one)
+}
+
+// ErrSnapshotUnsupported is returned by Snapshot and Backup on a
+// PostgreSQL or MySQL store.
+var ErrSnapshotUnsupported = errors.New("snapshot: only SQLite databases can be snapshotted")
+
+// Snapshot writes a consistent copy of the database to path, which must
+// not exist, with VACUUM INTO. Writers are not blocked while it runs.
+// VACUUM INTO is SQLite's, so on PostgreSQL and MySQL it returns
+// ErrSnapshotUnsupported; back those up with their own tools, such as
+// pg_dump and mysqldump.
+func (s *Store) Snapshot(path string) error {
+	if s.db.dialect != dialectSQLite {
+		return ErrSnapshotUnsupported
+	}
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// Backup writes a consistent copy of the database to w, snapshotting it to
+// a temporary file first. Like Snapshot it returns ErrSnapshotUnsupported
+// unless the database is SQLite.
+func (s *Store) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "pubengine-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blog.db")
+	if err := s.Snapshot(path); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// baselineSchema creates the tables and columns of schema version 1, the
+// schema before migrations were numbered, that are missing, and backfills
+// the columns added to it over time.
+func (s *Store) baselineSchema() error {
+	_, err := s.db.Exec(s.db.dialect.ddl(`
 CREATE TABLE IF NOT EXISTS posts (
     slug TEXT PRIMARY KEY,
     title TEXT NOT NULL,
@@ -60,18 +137,52 @@ CREATE TABLE IF NOT EXISTS posts (
     tags TEXT NOT NULL,
     summary TEXT NOT NULL,
     content TEXT NOT NULL,
-    published INTEGER NOT NULL DEFAULT 1
+    published INTEGER NOT NULL DEFAULT 1,
+    og_image TEXT NOT NULL DEFAULT '',
+    trashed_at TEXT NOT NULL DEFAULT '',
+    series_slug TEXT NOT NULL DEFAULT '',
+    series_order INTEGER NOT NULL DEFAULT 0,
+    author_slug TEXT NOT NULL DEFAULT '',
+    exclude_from_feed INTEGER NOT NULL DEFAULT 0,
+    exclude_from_sitemap INTEGER NOT NULL DEFAULT 0,
+    no_index INTEGER NOT NULL DEFAULT 0,
+    canonical_url TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL DEFAULT '',
+    featured INTEGER NOT NULL DEFAULT 0
 );
-`)
+`))
 	if err != nil {
 		return err
 	}
-	if _, err := s.db.Exec(`ALTER TABLE posts ADD COLUMN published INTEGER NOT NULL DEFAULT 1;`); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "duplicate column") {
+	for _, col := range []string{
+		`published INTEGER NOT NULL DEFAULT 1`,
+		`og_image TEXT NOT NULL DEFAULT ''`,
+		`trashed_at TEXT NOT NULL DEFAULT ''`,
+		`series_slug TEXT NOT NULL DEFAULT ''`,
+		`series_order INTEGER NOT NULL DEFAULT 0`,
+		`author_slug TEXT NOT NULL DEFAULT ''`,
+		`exclude_from_feed INTEGER NOT NULL DEFAULT 0`,
+		`exclude_from_sitemap INTEGER NOT NULL DEFAULT 0`,
+		`no_index INTEGER NOT NULL DEFAULT 0`,
+		`canonical_url TEXT NOT NULL DEFAULT ''`,
+		`created_at TEXT NOT NULL DEFAULT ''`,
+		`updated_at TEXT NOT NULL DEFAULT ''`,
+		`featured INTEGER NOT NULL DEFAULT 0`,
+	} {
+		if err := s.db.addColumn("posts", col); err != nil {
 			return err
 		}
 	}
-	_, err = s.db.Exec(`
+	// Posts saved before timestamps were kept were created and last
+	// updated, as far as we know, at the start of their date.
+	if _, err := s.db.Exec(`UPDATE posts SET created_at = ` + s.db.dialect.concat("date", "'T00:00:00Z'") + ` WHERE created_at = ''`); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`UPDATE posts SET updated_at = created_at WHERE updated_at = ''`); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
 CREATE TABLE IF NOT EXISTS images (
     filename TEXT PRIMARY KEY,
     original_name TEXT NOT NULL,
@@ -80,20 +191,213 @@ CREATE TABLE IF NOT EXISTS images (
     size INTEGER NOT NULL,
     uploaded_at TEXT NOT NULL
 );
-`)
-	return err
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS attachments (
+    filename TEXT PRIMARY KEY,
+    original_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    uploaded_at TEXT NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    duration INTEGER NOT NULL DEFAULT 0,
+    poster TEXT NOT NULL DEFAULT ''
+);
+`))
+	if err != nil {
+		return err
+	}
+	for _, col := range []string{
+		`width INTEGER NOT NULL DEFAULT 0`,
+		`height INTEGER NOT NULL DEFAULT 0`,
+		`duration INTEGER NOT NULL DEFAULT 0`,
+		`poster TEXT NOT NULL DEFAULT ''`,
+	} {
+		if err := s.db.addColumn("attachments", col); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS snippets (
+    name TEXT PRIMARY KEY,
+    template INTEGER NOT NULL DEFAULT 0,
+    content TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS short_links (
+    code TEXT PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS draft_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    quote TEXT NOT NULL,
+    body TEXT NOT NULL,
+    resolved INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+`))
+	if err != nil {
+		return err
+	}
+	if err := s.db.createIndex("idx_draft_comments_slug", "draft_comments", "slug"); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TEXT NOT NULL,
+    locked_until TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    dead_at TEXT NOT NULL DEFAULT ''
+);
+`))
+	if err != nil {
+		return err
+	}
+	if err := s.db.createIndex("idx_jobs_due", "jobs", "dead_at, run_at"); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS post_meta (
+    slug TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (slug, key)
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS redirects (
+    from_slug TEXT PRIMARY KEY,
+    to_slug TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS series (
+    slug TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+`))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS authors (
+    slug TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    bio TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    avatar TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT ''
+);
+`))
+	if err != nil {
+		return err
+	}
+	for _, col := range []string{
+		`avatar TEXT NOT NULL DEFAULT ''`,
+		`email TEXT NOT NULL DEFAULT ''`,
+	} {
+		if err := s.db.addColumn("authors", col); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(s.db.dialect.ddl(`
+CREATE TABLE IF NOT EXISTS post_links (
+    from_slug TEXT NOT NULL,
+    to_slug TEXT NOT NULL,
+    PRIMARY KEY (from_slug, to_slug)
+);
+`))
+	if err != nil {
+		return err
+	}
+	if err := s.db.createIndex("idx_post_links_to", "post_links", "to_slug"); err != nil {
+		return err
+	}
+	if err := s.backfillPostLinks(); err != nil {
+		return err
+	}
+	return s.backfillShortCodes()
 }
 
-// ListPosts returns all published posts ordered by date descending.
+// backfillShortCodes gives posts saved before short links existed a code.
+func (s *Store) backfillShortCodes() error {
+	rows, err := s.db.Query(`SELECT slug FROM posts WHERE slug NOT IN (SELECT slug FROM short_links)`)
+	if err != nil {
+		return err
+	}
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			rows.Close()
+			return err
+		}
+		slugs = append(slugs, slug)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, slug := range slugs {
+		if _, err := s.ensureShortCode(slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPosts returns all published posts ordered by date descending. Posts
+// in the trash are left out here and everywhere else except GetPostAny and
+// ListTrashedPosts.
 // If tag is non-empty, results are filtered to posts containing that tag.
 func (s *Store) ListPosts(tag string) ([]BlogPost, error) {
 	var rows *sql.Rows
 	var err error
 	if tag == "" {
-		rows, err = s.db.Query(`SELECT slug, title, date, tags, summary, content, published FROM posts WHERE published = 1 ORDER BY date DESC`)
+		rows, err = s.db.Query(`SELECT ` + s.postColumns + ` FROM posts WHERE published = 1 AND trashed_at = '' ORDER BY date DESC`)
 	} else {
 		normalizedTag := strings.ToLower(strings.TrimSpace(tag))
-		rows, err = s.db.Query(`SELECT slug, title, date, tags, summary, content, published FROM posts WHERE published = 1 AND instr(lower(tags), ',' || ? || ',') > 0 ORDER BY date DESC`, normalizedTag)
+		rows, err = s.db.Query(`SELECT `+s.postColumns+` FROM posts WHERE published = 1 AND trashed_at = '' AND `+s.db.dialect.strpos("lower(tags)", "?")+` > 0 ORDER BY date DESC`, ","+normalizedTag+",")
 	}
 	if err != nil {
 		return nil, err
@@ -102,29 +406,66 @@ func (s *Store) ListPosts(tag string) ([]BlogPost, error) {
 
 	var posts []BlogPost
 	for rows.Next() {
-		var slug, title, date, tags, summary, content string
-		var published int
-		if err := rows.Scan(&slug, &title, &date, &tags, &summary, &content, &published); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		post := BlogPost{
-			Slug:      slug,
-			Title:     title,
-			Date:      date,
-			Tags:      ParseTags(tags),
-			Summary:   summary,
-			Content:   content,
-			Link:      "/blog/" + slug,
-			Published: published == 1,
-		}
 		posts = append(posts, post)
 	}
 	return posts, nil
 }
 
+// ListPostSummaries is ListPosts without the posts' content, for listings
+// that show titles and summaries. ReadingMinutes and SocialImage work as
+// on full posts.
+func (s *Store) ListPostSummaries(tag string) ([]BlogPost, error) {
+	var rows *sql.Rows
+	var err error
+	if tag == "" {
+		rows, err = s.db.Query(`SELECT ` + s.summaryColumns + ` FROM posts WHERE published = 1 AND trashed_at = '' ORDER BY date DESC`)
+	} else {
+		normalizedTag := strings.ToLower(strings.TrimSpace(tag))
+		rows, err = s.db.Query(`SELECT `+s.summaryColumns+` FROM posts WHERE published = 1 AND trashed_at = '' AND `+s.db.dialect.strpos("lower(tags)", "?")+` > 0 ORDER BY date DESC`, ","+normalizedTag+",")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// ListFeaturedPosts returns the published posts marked as featured,
+// ordered by date descending.
+func (s *Store) ListFeaturedPosts() ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT ` + s.postColumns + ` FROM posts WHERE published = 1 AND featured = 1 AND trashed_at = '' ORDER BY date DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
 // ListTags returns a sorted, deduplicated slice of all tags from published posts.
 func (s *Store) ListTags() ([]string, error) {
-	rows, err := s.db.Query(`SELECT tags FROM posts WHERE published = 1`)
+	rows, err := s.db.Query(`SELECT tags FROM posts WHERE published = 1 AND trashed_at = ''`)
 	if err != nil {
 		return nil, err
 	}
@@ -153,49 +494,18 @@ func (s *Store) ListTags() ([]string, error) {
 
 // GetPost returns a single published post by slug.
 func (s *Store) GetPost(slug string) (BlogPost, error) {
-	var title, date, tags, summary, content string
-	var published int
-	err := s.db.QueryRow(`SELECT title, date, tags, summary, content, published FROM posts WHERE slug = ? AND published = 1`, slug).
-		Scan(&title, &date, &tags, &summary, &content, &published)
-	if err != nil {
-		return BlogPost{}, err
-	}
-	return BlogPost{
-		Slug:      slug,
-		Title:     title,
-		Date:      date,
-		Tags:      ParseTags(tags),
-		Summary:   summary,
-		Content:   content,
-		Link:      "/blog/" + slug,
-		Published: published == 1,
-	}, nil
+	return scanPost(s.db.QueryRow(`SELECT `+s.postColumns+` FROM posts WHERE slug = ? AND published = 1 AND trashed_at = ''`, slug))
 }
 
-// GetPostAny returns a post by slug regardless of published status (for admin).
+// GetPostAny returns a post by slug regardless of published status (for
+// admin), including posts in the trash.
 func (s *Store) GetPostAny(slug string) (BlogPost, error) {
-	var title, date, tags, summary, content string
-	var published int
-	err := s.db.QueryRow(`SELECT title, date, tags, summary, content, published FROM posts WHERE slug = ?`, slug).
-		Scan(&title, &date, &tags, &summary, &content, &published)
-	if err != nil {
-		return BlogPost{}, err
-	}
-	return BlogPost{
-		Slug:      slug,
-		Title:     title,
-		Date:      date,
-		Tags:      ParseTags(tags),
-		Summary:   summary,
-		Content:   content,
-		Link:      "/blog/" + slug,
-		Published: published == 1,
-	}, nil
+	return scanPost(s.db.QueryRow(`SELECT `+s.postColumns+` FROM posts WHERE slug = ?`, slug))
 }
 
 // ListAllPosts returns every post (published and drafts) ordered by date descending.
 func (s *Store) ListAllPosts() ([]BlogPost, error) {
-	rows, err := s.db.Query(`SELECT slug, title, date, tags, summary, content, published FROM posts ORDER BY date DESC`)
+	rows, err := s.db.Query(`SELECT ` + s.postColumns + ` FROM posts WHERE trashed_at = '' ORDER BY date DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -203,47 +513,413 @@ func (s *Store) ListAllPosts() ([]BlogPost, error) {
 
 	var posts []BlogPost
 	for rows.Next() {
-		var slug, title, date, tags, summary, content string
-		var published int
-		if err := rows.Scan(&slug, &title, &date, &tags, &summary, &content, &published); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, BlogPost{
-			Slug:      slug,
-			Title:     title,
-			Date:      date,
-			Tags:      ParseTags(tags),
-			Summary:   summary,
-			Content:   content,
-			Link:      "/blog/" + slug,
-			Published: published == 1,
-		})
+		posts = append(posts, post)
 	}
 	return posts, nil
 }
 
-// SavePost upserts a blog post. Tags are normalized to lowercase.
+// ListTrashedPosts returns the posts in the trash, most recently trashed
+// first.
+func (s *Store) ListTrashedPosts() ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT ` + s.postColumns + ` FROM posts WHERE trashed_at != '' ORDER BY trashed_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// postColumns are the posts columns read by scanPost, in order, followed by
+// the post's short link code, author name and custom fields as a JSON object.
+func postColumns(d dialect) string {
+	return "slug, title, date, tags, summary, content, published, og_image, trashed_at, series_slug, series_order, author_slug, " +
+		"exclude_from_feed, exclude_from_sitemap, no_index, canonical_url, created_at, updated_at, featured, reading_minutes, content_image, version, publish_at, " +
+		"COALESCE((SELECT code FROM short_links WHERE short_links.slug = posts.slug), ''), " +
+		"COALESCE((SELECT name FROM authors WHERE authors.slug = posts.author_slug), ''), " +
+		"COALESCE((SELECT " + d.jsonObjectAgg("key", "value") + " FROM post_meta WHERE post_meta.slug = posts.slug), '{}')"
+}
+
+// summaryColumns are postColumns with the content left out, read as "".
+func summaryColumns(d dialect) string {
+	return strings.Replace(postColumns(d), "summary, content,", "summary, '',", 1)
+}
+
+// scanPost scans a row selected with postColumns or summaryColumns.
+func scanPost(row interface{ Scan(...any) error }) (BlogPost, error) {
+	var slug, title, date, tags, summary, content, ogImage, trashedAt, seriesSlug, authorSlug, canonicalURL, createdAt, updatedAt, contentImage, publishAt, shortCode, authorName, meta string
+	var published, seriesOrder, readingMinutes, version int
+	var excludeFromFeed, excludeFromSitemap, noIndex, featured bool
+	if err := row.Scan(&slug, &title, &date, &tags, &summary, &content, &published, &ogImage, &trashedAt, &seriesSlug, &seriesOrder, &authorSlug,
+		&excludeFromFeed, &excludeFromSitemap, &noIndex, &canonicalURL, &createdAt, &updatedAt, &featured, &readingMinutes, &contentImage, &version, &publishAt,
+		&shortCode, &authorName, &meta); err != nil {
+		return BlogPost{}, err
+	}
+	postMeta, err := parsePostMeta(meta)
+	if err != nil {
+		return BlogPost{}, err
+	}
+	return BlogPost{
+		Slug:        slug,
+		Title:       title,
+		Date:        date,
+		Tags:        ParseTags(tags),
+		Summary:     summary,
+		Content:     content,
+		Link:        "/blog/" + slug,
+		Published:   published == 1,
+		OGImage:     ogImage,
+		ShortCode:   shortCode,
+		TrashedAt:   trashedAt,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		Version:     version,
+		PublishAt:   publishAt,
+		Featured:    featured,
+		SeriesSlug:  seriesSlug,
+		SeriesOrder: seriesOrder,
+		AuthorSlug:  authorSlug,
+		AuthorName:  authorName,
+
+		ExcludeFromFeed:    excludeFromFeed,
+		ExcludeFromSitemap: excludeFromSitemap,
+		NoIndex:            noIndex,
+		CanonicalURL:       canonicalURL,
+		Meta:               postMeta,
+		ReadingMinutes:     readingMinutes,
+		contentImage:       contentImage,
+	}, nil
+}
+
+// SavePost upserts a blog post and gives new posts a short link code. Tags
+// are normalized to lowercase. CreatedAt is set when the post is first
+// saved, UpdatedAt and Version on every save, and ReadingMinutes is
+// computed from the content; the values in p are ignored. Every save keeps
+// a PostRevision. Meta
+// replaces the post's custom fields. Saving over a post in the trash takes it out
+// of the trash, and saving a published post clears its PublishAt. A series
+// or author the post names is created if it doesn't exist.
 func (s *Store) SavePost(p BlogPost) error {
 	normalizedTags := make([]string, len(p.Tags))
 	for i, t := range p.Tags {
 		normalizedTags[i] = strings.ToLower(strings.TrimSpace(t))
 	}
 	tagString := "," + strings.Join(normalizedTags, ",") + ","
-	published := 0
+	published, publishAt := 0, p.PublishAt
 	if p.Published {
-		published = 1
+		published, publishAt = 1, ""
 	}
-	_, err := s.db.Exec(`INSERT OR REPLACE INTO posts (slug, title, date, tags, summary, content, published) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		p.Slug, p.Title, p.Date, tagString, p.Summary, p.Content, published)
-	return err
+	now := time.Now().UTC().Format(time.RFC3339)
+	// Saving replaces everything but created_at, and takes a trashed post
+	// out of the trash.
+	_, err := s.db.Exec(`INSERT INTO posts (slug, title, date, tags, summary, content, published, og_image, series_slug, series_order, author_slug,
+    exclude_from_feed, exclude_from_sitemap, no_index, canonical_url, featured, reading_minutes, content_image, publish_at, trashed_at, created_at, updated_at, version)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '', ?, ?, 1)
+ON CONFLICT(slug) DO UPDATE SET title = excluded.title, date = excluded.date, tags = excluded.tags, summary = excluded.summary,
+    content = excluded.content, published = excluded.published, og_image = excluded.og_image, series_slug = excluded.series_slug,
+    series_order = excluded.series_order, author_slug = excluded.author_slug, exclude_from_feed = excluded.exclude_from_feed,
+    exclude_from_sitemap = excluded.exclude_from_sitemap, no_index = excluded.no_index, canonical_url = excluded.canonical_url,
+    featured = excluded.featured, reading_minutes = excluded.reading_minutes, content_image = excluded.content_image,
+    publish_at = excluded.publish_at, trashed_at = '', updated_at = excluded.updated_at, version = posts.version + 1`,
+		p.Slug, p.Title, p.Date, tagString, p.Summary, p.Content, published, p.OGImage, p.SeriesSlug, p.SeriesOrder, p.AuthorSlug,
+		p.ExcludeFromFeed, p.ExcludeFromSitemap, p.NoIndex, p.CanonicalURL, p.Featured, ReadingMinutes(p.Content), firstImage(p.Content), publishAt, now, now)
+	if err != nil {
+		return err
+	}
+	if err := s.savePostMeta(p.Slug, p.Meta); err != nil {
+		return err
+	}
+	if err := s.savePostLinks(p.Slug, p.Content); err != nil {
+		return err
+	}
+	if p.SeriesSlug != "" {
+		if err := s.EnsureSeries(p.SeriesSlug); err != nil {
+			return err
+		}
+	}
+	if p.AuthorSlug != "" {
+		if err := s.EnsureAuthor(p.AuthorSlug); err != nil {
+			return err
+		}
+	}
+	if _, err := s.ensureShortCode(p.Slug); err != nil {
+		return err
+	}
+	return s.saveRevision(p.Slug)
+}
+
+// SavePostIfVersion saves p like SavePost if the saved post with p.Slug is
+// still at version, the Version it had when it was read for editing, and
+// returns ErrPostChanged if it was changed since. A post that no longer
+// exists is saved anew.
+func (s *Store) SavePostIfVersion(p BlogPost, version int) error {
+	// Claiming the version first makes a concurrent save of the same
+	// version fail.
+	res, err := s.db.Exec(`UPDATE posts SET version = version + 1 WHERE slug = ? AND version = ? AND trashed_at = ''`, p.Slug, version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := s.GetPostAny(p.Slug); err == nil {
+			return ErrPostChanged
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return s.SavePost(p)
 }
 
-// DeletePost removes a post by slug.
+// ListPostsBetween returns every post (published and drafts) dated from
+// from up to but not including to, both "2006-01-02", oldest first.
+func (s *Store) ListPostsBetween(from, to string) ([]BlogPost, error) {
+	rows, err := s.db.Query(`SELECT `+s.postColumns+` FROM posts WHERE date >= ? AND date < ? AND trashed_at = '' ORDER BY date, title`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []BlogPost
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// SetPostDate changes the date of a post. It returns sql.ErrNoRows if there
+// is no post with the slug.
+func (s *Store) SetPostDate(slug, date string) error {
+	return s.updatePost(`UPDATE posts SET date = ?, version = version + 1 WHERE slug = ? AND trashed_at = ''`, date, slug)
+}
+
+// SetPostFeatured marks a post as featured, or not, without touching its
+// other fields or its update time. It returns sql.ErrNoRows if there is no
+// post with the slug outside the trash.
+func (s *Store) SetPostFeatured(slug string, featured bool) error {
+	return s.updatePost(`UPDATE posts SET featured = ? WHERE slug = ? AND trashed_at = ''`, featured, slug)
+}
+
+// DuplicatePost copies a post, in or out of the trash, into a new unfeatured,
+// unscheduled draft dated today under the first free slug of "<slug>-copy", "<slug>-copy-2"
+// and so on, and returns the copy. It returns sql.ErrNoRows if there is no
+// post with the slug.
+func (s *Store) DuplicatePost(slug string) (BlogPost, error) {
+	return duplicatePost(s, slug)
+}
+
+// TrashPost moves a post to the trash, hiding it from the site and the
+// admin post list until it is restored or deleted. It returns
+// sql.ErrNoRows if there is no post with the slug outside the trash.
+func (s *Store) TrashPost(slug string) error {
+	return s.updatePost(`UPDATE posts SET trashed_at = ? WHERE slug = ? AND trashed_at = ''`, time.Now().UTC().Format(time.RFC3339), slug)
+}
+
+// RestorePost takes a post out of the trash. It returns sql.ErrNoRows if
+// there is no post with the slug in the trash.
+func (s *Store) RestorePost(slug string) error {
+	return s.updatePost(`UPDATE posts SET trashed_at = '' WHERE slug = ? AND trashed_at != ''`, slug)
+}
+
+// BulkUpdatePublished publishes or unpublishes the posts outside the trash
+// with the given slugs, and returns how many changed. Slugs of posts that
+// don't exist or are already in that state are ignored. Changed posts are
+// no longer scheduled.
+func (s *Store) BulkUpdatePublished(slugs []string, published bool) (int, error) {
+	if len(slugs) == 0 {
+		return 0, nil
+	}
+	args := []any{published, time.Now().UTC().Format(time.RFC3339), published}
+	res, err := s.db.Exec(`UPDATE posts SET published = ?, publish_at = '', updated_at = ?, version = version + 1 WHERE published != ? AND trashed_at = '' AND slug IN (`+placeholders(len(slugs))+`)`,
+		append(args, stringArgs(slugs)...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// BulkTrash moves the posts with the given slugs to the trash, see
+// TrashPost, and returns how many were moved. Slugs of posts that don't
+// exist or are already in the trash are ignored.
+func (s *Store) BulkTrash(slugs []string) (int, error) {
+	if len(slugs) == 0 {
+		return 0, nil
+	}
+	args := []any{time.Now().UTC().Format(time.RFC3339)}
+	res, err := s.db.Exec(`UPDATE posts SET trashed_at = ? WHERE trashed_at = '' AND slug IN (`+placeholders(len(slugs))+`)`,
+		append(args, stringArgs(slugs)...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// placeholders returns n comma-separated ? placeholders, for IN lists.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// stringArgs returns ss as query arguments.
+func stringArgs(ss []string) []any {
+	args := make([]any, len(ss))
+	for i, s := range ss {
+		args[i] = s
+	}
+	return args
+}
+
+// updatePost runs an UPDATE of one post, returning sql.ErrNoRows if it
+// changed nothing.
+func (s *Store) updatePost(query string, args ...any) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePost permanently removes a post, its custom fields, its links to
+// other posts, its comments, draft comments, revisions and cross-post records by slug, whether it is in the trash or not. Its short link code
+// is kept, so printed links work again if a post with the same slug is
+// published.
 func (s *Store) DeletePost(slug string) error {
-	_, err := s.db.Exec(`DELETE FROM posts WHERE slug = ?`, slug)
+	_, err := s.BulkDelete([]string{slug})
 	return err
 }
 
+// BulkDelete permanently removes the posts with the given slugs, in the
+// trash or not, like DeletePost, and returns how many were removed.
+func (s *Store) BulkDelete(slugs []string) (int, error) {
+	if len(slugs) == 0 {
+		return 0, nil
+	}
+	in := placeholders(len(slugs))
+	args := stringArgs(slugs)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM posts WHERE slug IN (`+in+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	for _, q := range []string{
+		`DELETE FROM post_meta WHERE slug IN (` + in + `)`,
+		`DELETE FROM post_links WHERE from_slug IN (` + in + `)`,
+		`DELETE FROM draft_comments WHERE slug IN (` + in + `)`,
+		`DELETE FROM post_revisions WHERE slug IN (` + in + `)`,
+		`DELETE FROM comments WHERE slug IN (` + in + `)`,
+		`DELETE FROM syndications WHERE slug IN (` + in + `)`,
+	} {
+		if _, err := tx.Exec(q, args...); err != nil {
+			return 0, err
+		}
+	}
+	return int(n), tx.Commit()
+}
+
+// EmptyTrash permanently deletes the posts trashed before cutoff and
+// returns how many were deleted.
+func (s *Store) EmptyTrash(cutoff time.Time) (int, error) {
+	rows, err := s.db.Query(`SELECT slug FROM posts WHERE trashed_at != '' AND trashed_at < ?`, cutoff.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		slugs = append(slugs, slug)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, slug := range slugs {
+		if err := s.DeletePost(slug); err != nil {
+			return i, err
+		}
+	}
+	return len(slugs), nil
+}
+
+// shortCodeAlphabet is the alphabet of short link codes.
+const shortCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// ensureShortCode returns the short link code of slug, generating a random
+// one if it has none. Codes start at four characters and grow when random
+// picks keep colliding.
+func (s *Store) ensureShortCode(slug string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		var code string
+		err := s.db.QueryRow(`SELECT code FROM short_links WHERE slug = ?`, slug).Scan(&code)
+		if err != sql.ErrNoRows {
+			return code, err
+		}
+		code, err = randomShortCode(4 + attempt/8)
+		if err != nil {
+			return "", err
+		}
+		// A taken code or a concurrent save of the same slug inserts
+		// nothing; the next round checks for the latter.
+		if _, err := s.db.Exec(`INSERT INTO short_links (code, slug) VALUES (?, ?) ON CONFLICT DO NOTHING`, code, slug); err != nil {
+			return "", err
+		}
+	}
+}
+
+func randomShortCode(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(shortCodeAlphabet)))
+	for i := range b {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = shortCodeAlphabet[r.Int64()]
+	}
+	return string(b), nil
+}
+
+// ShortCodeSlug returns the slug of the post with the given short link code,
+// or sql.ErrNoRows for unknown codes.
+func (s *Store) ShortCodeSlug(code string) (string, error) {
+	var slug string
+	err := s.db.QueryRow(`SELECT slug FROM short_links WHERE code = ?`, code).Scan(&slug)
+	return slug, err
+}
+
 // SaveImage inserts image metadata into the database.
 func (s *Store) SaveImage(img Image) error {
 	_, err := s.db.Exec(`INSERT INTO images (filename, original_name, width, height, size, uploaded_at) VALUES (?, ?, ?, ?, ?, ?)`,
@@ -276,6 +952,315 @@ func (s *Store) DeleteImage(filename string) error {
 	return err
 }
 
+// SaveAttachment inserts attachment metadata into the database.
+func (s *Store) SaveAttachment(a Attachment) error {
+	_, err := s.db.Exec(`INSERT INTO attachments (filename, original_name, content_type, size, uploaded_at, width, height, duration, poster) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Filename, a.OriginalName, a.ContentType, a.Size, a.UploadedAt, a.Width, a.Height, a.Duration, a.Poster)
+	return err
+}
+
+// GetAttachment returns the metadata of one attachment, or sql.ErrNoRows.
+func (s *Store) GetAttachment(filename string) (Attachment, error) {
+	var a Attachment
+	err := s.db.QueryRow(`SELECT filename, original_name, content_type, size, uploaded_at, width, height, duration, poster FROM attachments WHERE filename = ?`, filename).
+		Scan(&a.Filename, &a.OriginalName, &a.ContentType, &a.Size, &a.UploadedAt, &a.Width, &a.Height, &a.Duration, &a.Poster)
+	return a, err
+}
+
+// ListAttachments returns all attachments ordered by upload time descending.
+func (s *Store) ListAttachments() ([]Attachment, error) {
+	rows, err := s.db.Query(`SELECT filename, original_name, content_type, size, uploaded_at, width, height, duration, poster FROM attachments ORDER BY uploaded_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.Filename, &a.OriginalName, &a.ContentType, &a.Size, &a.UploadedAt, &a.Width, &a.Height, &a.Duration, &a.Poster); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment removes attachment metadata from the database.
+func (s *Store) DeleteAttachment(filename string) error {
+	_, err := s.db.Exec(`DELETE FROM attachments WHERE filename = ?`, filename)
+	return err
+}
+
+// SaveSnippet upserts a snippet by name.
+func (s *Store) SaveSnippet(sn Snippet) error {
+	template := 0
+	if sn.Template {
+		template = 1
+	}
+	_, err := s.db.Exec(`INSERT INTO snippets (name, template, content, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET template = excluded.template, content = excluded.content, updated_at = excluded.updated_at`,
+		sn.Name, template, sn.Content, sn.UpdatedAt)
+	return err
+}
+
+// GetSnippet returns a snippet by name.
+func (s *Store) GetSnippet(name string) (Snippet, error) {
+	sn := Snippet{Name: name}
+	var template int
+	err := s.db.QueryRow(`SELECT template, content, updated_at FROM snippets WHERE name = ?`, name).
+		Scan(&template, &sn.Content, &sn.UpdatedAt)
+	if err != nil {
+		return Snippet{}, err
+	}
+	sn.Template = template == 1
+	return sn, nil
+}
+
+// ListSnippets returns all snippets, templates first, ordered by name.
+func (s *Store) ListSnippets() ([]Snippet, error) {
+	rows, err := s.db.Query(`SELECT name, template, content, updated_at FROM snippets ORDER BY template DESC, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		var sn Snippet
+		var template int
+		if err := rows.Scan(&sn.Name, &template, &sn.Content, &sn.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sn.Template = template == 1
+		snippets = append(snippets, sn)
+	}
+	return snippets, rows.Err()
+}
+
+// DeleteSnippet removes a snippet by name.
+func (s *Store) DeleteSnippet(name string) error {
+	_, err := s.db.Exec(`DELETE FROM snippets WHERE name = ?`, name)
+	return err
+}
+
+// AddDraftComment stores a new comment and returns its ID. A comment
+// without a Revision is on the post's latest revision.
+func (s *Store) AddDraftComment(dc DraftComment) (int64, error) {
+	resolved := 0
+	if dc.Resolved {
+		resolved = 1
+	}
+	if dc.Revision == 0 {
+		var err error
+		if dc.Revision, err = s.latestRevision(dc.Slug); err != nil {
+			return 0, err
+		}
+	}
+	return s.db.insert(`INSERT INTO draft_comments (slug, start_offset, end_offset, quote, body, resolved, created_at, revision_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		dc.Slug, dc.Start, dc.End, dc.Quote, dc.Body, resolved, dc.CreatedAt, dc.Revision)
+}
+
+// ListDraftComments returns the comments on a post, open ones first, each
+// group in order of position in the content. Comments on an earlier
+// revision than the latest are Outdated.
+func (s *Store) ListDraftComments(slug string) ([]DraftComment, error) {
+	latest, err := s.latestRevision(slug)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT id, start_offset, end_offset, quote, body, resolved, created_at, revision_id FROM draft_comments WHERE slug = ? ORDER BY resolved, start_offset, id`, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []DraftComment
+	for rows.Next() {
+		dc := DraftComment{Slug: slug}
+		var resolved int
+		if err := rows.Scan(&dc.ID, &dc.Start, &dc.End, &dc.Quote, &dc.Body, &resolved, &dc.CreatedAt, &dc.Revision); err != nil {
+			return nil, err
+		}
+		dc.Resolved = resolved == 1
+		dc.Outdated = dc.Revision != 0 && dc.Revision < latest
+		comments = append(comments, dc)
+	}
+	return comments, rows.Err()
+}
+
+// SetDraftCommentResolved resolves or reopens a comment on the post with the
+// given slug. It returns sql.ErrNoRows if there is no such comment.
+func (s *Store) SetDraftCommentResolved(slug string, id int64, resolved bool) error {
+	val := 0
+	if resolved {
+		val = 1
+	}
+	res, err := s.db.Exec(`UPDATE draft_comments SET resolved = ? WHERE id = ? AND slug = ?`, val, id, slug)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteDraftComment removes a comment on the post with the given slug.
+func (s *Store) DeleteDraftComment(slug string, id int64) error {
+	_, err := s.db.Exec(`DELETE FROM draft_comments WHERE id = ? AND slug = ?`, id, slug)
+	return err
+}
+
+// jobColumns are the jobs columns read by scanJob, in order.
+const jobColumns = "id, kind, payload, attempts, max_attempts, run_at, last_error, created_at, dead_at"
+
+func scanJob(row interface{ Scan(...any) error }) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.Payload, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &j.CreatedAt, &j.DeadAt)
+	return j, err
+}
+
+// EnqueueJob adds a job due at runAt and returns its ID.
+func (s *Store) EnqueueJob(kind, payload string, maxAttempts int, runAt time.Time) (int64, error) {
+	return s.db.insert(`INSERT INTO jobs (kind, payload, max_attempts, run_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		kind, payload, maxAttempts, runAt.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+}
+
+// ClaimJob takes the next due job, counting an attempt and locking it for
+// lease so other workers skip it. A job whose worker dies is claimed again
+// once the lease runs out. It returns sql.ErrNoRows when no job is due.
+func (s *Store) ClaimJob(now time.Time, lease time.Duration) (Job, error) {
+	at := now.UTC().Format(time.RFC3339)
+	if s.db.dialect == dialectMySQL {
+		return s.claimJobLocking(at, now.Add(lease).UTC().Format(time.RFC3339))
+	}
+	return scanJob(s.db.QueryRow(`UPDATE jobs SET attempts = attempts + 1, locked_until = ?
+WHERE id = (SELECT id FROM jobs WHERE dead_at = '' AND run_at <= ? AND locked_until <= ? ORDER BY run_at, id LIMIT 1) AND locked_until <= ?
+RETURNING `+jobColumns, now.Add(lease).UTC().Format(time.RFC3339), at, at, at))
+}
+
+// claimJobLocking is ClaimJob for MySQL, which can neither return updated
+// rows nor update a table it selects from, so it locks the job's row with
+// SELECT ... FOR UPDATE instead.
+func (s *Store) claimJobLocking(at, lockedUntil string) (Job, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Job{}, err
+	}
+	defer tx.Rollback()
+	const due = `dead_at = '' AND run_at <= ? AND locked_until <= ?`
+	var id int64
+	if err := tx.QueryRow(`SELECT id FROM jobs WHERE `+due+` ORDER BY run_at, id LIMIT 1 FOR UPDATE`, at, at).Scan(&id); err != nil {
+		return Job{}, err
+	}
+	// The row may have been claimed while this transaction waited for it.
+	res, err := tx.Exec(`UPDATE jobs SET attempts = attempts + 1, locked_until = ? WHERE id = ? AND `+due, lockedUntil, id, at, at)
+	if err != nil {
+		return Job{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return Job{}, err
+	} else if n == 0 {
+		return Job{}, sql.ErrNoRows
+	}
+	j, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err != nil {
+		return Job{}, err
+	}
+	return j, tx.Commit()
+}
+
+// ExtendJobLease keeps a running job locked until until, so that a job
+// outliving its lease isn't claimed again by another worker.
+func (s *Store) ExtendJobLease(id int64, until time.Time) error {
+	_, err := s.db.Exec(`UPDATE jobs SET locked_until = ? WHERE id = ? AND locked_until != ''`, until.UTC().Format(time.RFC3339), id)
+	return err
+}
+
+// CompleteJob removes a job that ran successfully.
+func (s *Store) CompleteJob(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
+	return err
+}
+
+// FailJob records a failed attempt of a job and schedules it to run again
+// at retryAt, or marks it dead when it has no attempts left.
+func (s *Store) FailJob(id int64, errMsg string, retryAt time.Time) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(`UPDATE jobs SET last_error = ?, locked_until = '', run_at = ?,
+dead_at = CASE WHEN attempts >= max_attempts THEN ? ELSE '' END
+WHERE id = ?`, errMsg, retryAt.UTC().Format(time.RFC3339), now, id)
+	return err
+}
+
+// ListDeadJobs returns the jobs that ran out of attempts, most recent first.
+func (s *Store) ListDeadJobs() ([]Job, error) {
+	rows, err := s.db.Query(`SELECT ` + jobColumns + ` FROM jobs WHERE dead_at != '' ORDER BY dead_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// CountJobs returns the number of queued and dead jobs.
+func (s *Store) CountJobs() (queued, dead int, err error) {
+	err = s.db.QueryRow(`SELECT COALESCE(SUM(CASE WHEN dead_at = '' THEN 1 ELSE 0 END), 0),
+    COALESCE(SUM(CASE WHEN dead_at != '' THEN 1 ELSE 0 END), 0) FROM jobs`).Scan(&queued, &dead)
+	return queued, dead, err
+}
+
+// RetryJob puts a dead job back in the queue with its attempts reset. It
+// returns sql.ErrNoRows if there is no dead job with the ID.
+func (s *Store) RetryJob(id int64) error {
+	res, err := s.db.Exec(`UPDATE jobs SET attempts = 0, dead_at = '', run_at = ? WHERE id = ? AND dead_at != ''`,
+		time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteJob removes a job, queued or dead.
+func (s *Store) DeleteJob(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
+	return err
+}
+
+// GetSetting returns the value of a site setting, or "" if it is not set.
+func (s *Store) GetSetting(key string) (string, error) {
+	var val string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&val)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return val, err
+}
+
+// SetSetting stores a site setting, replacing any previous value.
+func (s *Store) SetSetting(key, value string) error {
+	_, err := s.db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
 // ParseTags splits a comma-delimited tag string (e.g. ",go,web,") into a slice.
 func ParseTags(tagString string) []string {
 	tagString = strings.Trim(tagString, ",")
@@ -2,8 +2,14 @@ package pubengine
 
 import (
 	"database/sql"
+	"errors"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	_ "modernc.org/sqlite"
 )
@@ -127,6 +133,97 @@ func TestSavePostUpdate(t *testing.T) {
 	}
 }
 
+func TestSavePostIfVersion(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{Slug: "versioned", Title: "First", Date: "2024-01-01", Published: true}
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	opened, err := s.GetPostAny("versioned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Version == 0 {
+		t.Fatal("saved post has no version")
+	}
+
+	// Two tabs open the post; the first save wins.
+	post.Title = "Tab one"
+	if err := s.SavePostIfVersion(post, opened.Version); err != nil {
+		t.Fatalf("SavePostIfVersion: %v", err)
+	}
+	post.Title = "Tab two"
+	if err := s.SavePostIfVersion(post, opened.Version); err != ErrPostChanged {
+		t.Fatalf("SavePostIfVersion of a stale version = %v, want ErrPostChanged", err)
+	}
+	got, _ := s.GetPostAny("versioned")
+	if got.Title != "Tab one" || got.Version <= opened.Version {
+		t.Errorf("post = %q at version %d, want %q after version %d", got.Title, got.Version, "Tab one", opened.Version)
+	}
+
+	// Unpublishing changes the post too.
+	if _, err := s.BulkUpdatePublished([]string{"versioned"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePostIfVersion(post, got.Version); err != ErrPostChanged {
+		t.Errorf("SavePostIfVersion after unpublishing = %v, want ErrPostChanged", err)
+	}
+
+	// A post deleted meanwhile is saved anew.
+	if err := s.DeletePost("versioned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePostIfVersion(post, got.Version); err != nil {
+		t.Errorf("SavePostIfVersion of a deleted post: %v", err)
+	}
+}
+
+func TestPostOGImage(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{
+		Slug:      "og-image",
+		Title:     "OG Image",
+		Date:      "2024-01-01",
+		Content:   "Intro\n\n![first](/public/uploads/first.jpg)",
+		Published: true,
+	}
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+	got, err := s.GetPost("og-image")
+	if err != nil {
+		t.Fatalf("GetPost failed: %v", err)
+	}
+	if got.OGImage != "" {
+		t.Errorf("OGImage = %q, want empty", got.OGImage)
+	}
+	if img := got.SocialImage(); img != "/public/uploads/first.jpg" {
+		t.Errorf("SocialImage = %q, want the first content image", img)
+	}
+
+	post.OGImage = "/public/uploads/cover.jpg"
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost update failed: %v", err)
+	}
+	posts, err := s.ListPosts("")
+	if err != nil {
+		t.Fatalf("ListPosts failed: %v", err)
+	}
+	if len(posts) != 1 || posts[0].OGImage != post.OGImage {
+		t.Fatalf("ListPosts = %+v, want OGImage %q", posts, post.OGImage)
+	}
+	if img := posts[0].SocialImage(); img != post.OGImage {
+		t.Errorf("SocialImage = %q, want the OG image", img)
+	}
+	if got := AbsoluteURL("https://example.com", posts[0].SocialImage()); got != "https://example.com/public/uploads/cover.jpg" {
+		t.Errorf("AbsoluteURL = %q", got)
+	}
+}
+
 func TestGetPostNotFound(t *testing.T) {
 	s, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -386,6 +483,199 @@ func TestDeletePost(t *testing.T) {
 	}
 }
 
+func TestDuplicatePost(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	orig := BlogPost{Slug: "weekly", Title: "Weekly", Date: "2024-01-01", Tags: []string{"news"}, Summary: "S", Content: "Body", Published: true}
+	if err := s.SavePost(orig); err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.DuplicatePost("weekly")
+	if err != nil {
+		t.Fatalf("DuplicatePost: %v", err)
+	}
+	if first.Slug != "weekly-copy" || first.Title != "Weekly (copy)" || first.Published {
+		t.Errorf("copy = %q %q published=%v, want a weekly-copy draft", first.Slug, first.Title, first.Published)
+	}
+	if first.Content != orig.Content || first.Summary != orig.Summary || len(first.Tags) != 1 || first.Tags[0] != "news" {
+		t.Errorf("copy lost fields: %+v", first)
+	}
+	if first.Date != time.Now().Format("2006-01-02") {
+		t.Errorf("copy date = %q, want today", first.Date)
+	}
+	second, err := s.DuplicatePost("weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Slug != "weekly-copy-2" {
+		t.Errorf("second copy slug = %q, want weekly-copy-2", second.Slug)
+	}
+	if got, _ := s.GetPostAny("weekly"); !got.Published || got.Title != "Weekly" {
+		t.Errorf("original changed: %+v", got)
+	}
+	if _, err := s.DuplicatePost("missing"); err != sql.ErrNoRows {
+		t.Errorf("DuplicatePost(missing) = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestTrashPost(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{
+		Slug:      "to-trash",
+		Title:     "To Trash",
+		Date:      "2024-01-01",
+		Tags:      []string{"trash"},
+		Summary:   "s",
+		Content:   "c",
+		Published: true,
+	}
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+
+	if err := s.TrashPost("to-trash"); err != nil {
+		t.Fatalf("TrashPost failed: %v", err)
+	}
+	if err := s.TrashPost("to-trash"); err != sql.ErrNoRows {
+		t.Errorf("TrashPost twice: got err %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.GetPost("to-trash"); err != sql.ErrNoRows {
+		t.Errorf("trashed post should not be public, got err: %v", err)
+	}
+	if posts, _ := s.ListAllPosts(); len(posts) != 0 {
+		t.Errorf("ListAllPosts should leave out trashed posts, got %d", len(posts))
+	}
+	if tags, _ := s.ListTags(); len(tags) != 0 {
+		t.Errorf("ListTags should leave out trashed posts, got %v", tags)
+	}
+	trashed, err := s.ListTrashedPosts()
+	if err != nil {
+		t.Fatalf("ListTrashedPosts failed: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].Slug != "to-trash" || trashed[0].TrashedAt == "" {
+		t.Fatalf("ListTrashedPosts = %+v", trashed)
+	}
+
+	if err := s.RestorePost("to-trash"); err != nil {
+		t.Fatalf("RestorePost failed: %v", err)
+	}
+	if err := s.RestorePost("to-trash"); err != sql.ErrNoRows {
+		t.Errorf("RestorePost twice: got err %v, want sql.ErrNoRows", err)
+	}
+	got, err := s.GetPost("to-trash")
+	if err != nil {
+		t.Fatalf("restored post should be public: %v", err)
+	}
+	if got.TrashedAt != "" || !got.Published {
+		t.Errorf("restored post = %+v", got)
+	}
+
+	if err := s.TrashPost("to-trash"); err != nil {
+		t.Fatalf("TrashPost failed: %v", err)
+	}
+	if n, err := s.EmptyTrash(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("EmptyTrash before trashing = %d, %v", n, err)
+	}
+	if n, err := s.EmptyTrash(time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("EmptyTrash after trashing = %d, %v", n, err)
+	}
+	if _, err := s.GetPostAny("to-trash"); err != sql.ErrNoRows {
+		t.Errorf("emptied post should be gone, got err: %v", err)
+	}
+}
+
+func TestBulkPostOperations(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, slug := range []string{"a", "b", "c"} {
+		if err := s.SavePost(BlogPost{Slug: slug, Title: slug, Date: "2024-01-01", Content: "see /blog/c/"}); err != nil {
+			t.Fatalf("SavePost failed: %v", err)
+		}
+	}
+
+	if n, err := s.BulkUpdatePublished([]string{"a", "b", "missing"}, true); err != nil || n != 2 {
+		t.Errorf("BulkUpdatePublished = %d, %v, want 2", n, err)
+	}
+	if n, err := s.BulkUpdatePublished([]string{"a", "b"}, true); err != nil || n != 0 {
+		t.Errorf("BulkUpdatePublished again = %d, %v, want 0", n, err)
+	}
+	if posts, _ := s.ListPosts(""); len(posts) != 2 {
+		t.Errorf("published %d posts, want 2", len(posts))
+	}
+	if n, err := s.BulkUpdatePublished(nil, true); err != nil || n != 0 {
+		t.Errorf("BulkUpdatePublished(nil) = %d, %v", n, err)
+	}
+
+	if n, err := s.BulkTrash([]string{"a", "c"}); err != nil || n != 2 {
+		t.Errorf("BulkTrash = %d, %v, want 2", n, err)
+	}
+	if trashed, _ := s.ListTrashedPosts(); len(trashed) != 2 {
+		t.Errorf("trashed %d posts, want 2", len(trashed))
+	}
+	// Trashed posts can't be published.
+	if n, _ := s.BulkUpdatePublished([]string{"c"}, true); n != 0 {
+		t.Errorf("BulkUpdatePublished on the trash = %d, want 0", n)
+	}
+
+	if n, err := s.BulkDelete([]string{"a", "b", "missing"}); err != nil || n != 2 {
+		t.Errorf("BulkDelete = %d, %v, want 2", n, err)
+	}
+	links, _ := s.ListPostLinks()
+	for _, l := range links {
+		if l.From == "a" || l.From == "b" {
+			t.Errorf("link of deleted post kept: %+v", l)
+		}
+	}
+	if posts, _ := s.ListTrashedPosts(); len(posts) != 1 || posts[0].Slug != "c" {
+		t.Errorf("after BulkDelete, trash = %+v", posts)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	posts := []BlogPost{
+		{Slug: "a", Title: "A", Date: "2024-01-01", Tags: []string{"golang", "web"}, Published: true},
+		{Slug: "b", Title: "B", Date: "2024-01-02", Tags: []string{"go-lang", "go", "golang"}, Published: true},
+		{Slug: "c", Title: "C", Date: "2024-01-03", Tags: []string{"web_dev"}},
+	}
+	for _, p := range posts {
+		if err := s.SavePost(p); err != nil {
+			t.Fatalf("SavePost failed: %v", err)
+		}
+	}
+
+	if n, err := s.MergeTags("Go", "golang", "GO-LANG", "go"); err != nil || n != 2 {
+		t.Fatalf("MergeTags = %d, %v, want 2", n, err)
+	}
+	a, _ := s.GetPostAny("a")
+	b, _ := s.GetPostAny("b")
+	if !reflect.DeepEqual(a.Tags, []string{"go", "web"}) || !reflect.DeepEqual(b.Tags, []string{"go"}) {
+		t.Errorf("tags after merge: a %v, b %v", a.Tags, b.Tags)
+	}
+
+	// "web" is a LIKE pattern for "web_dev" too, but only "web" changes.
+	if n, err := s.RenameTag("web", "www"); err != nil || n != 1 {
+		t.Fatalf("RenameTag = %d, %v, want 1", n, err)
+	}
+	counts, err := s.ListTagCounts()
+	if err != nil {
+		t.Fatalf("ListTagCounts failed: %v", err)
+	}
+	want := []TagCount{{"go", 2}, {"web_dev", 1}, {"www", 1}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("ListTagCounts = %v, want %v", counts, want)
+	}
+	if n, err := s.RenameTag("missing", "go"); err != nil || n != 0 {
+		t.Errorf("RenameTag of a missing tag = %d, %v", n, err)
+	}
+}
+
 func TestDeleteNonexistentPost(t *testing.T) {
 	s, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -450,3 +740,1001 @@ func TestEmptyTags(t *testing.T) {
 		t.Errorf("Tags should be empty, got %v", got.Tags)
 	}
 }
+
+func TestSnippetCRUD(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if err := s.SaveSnippet(Snippet{Name: "sig", Content: "-- me", UpdatedAt: "2024-01-01T00:00:00Z"}); err != nil {
+		t.Fatalf("SaveSnippet: %v", err)
+	}
+	if err := s.SaveSnippet(Snippet{Name: "weekly", Template: true, Content: "notes", UpdatedAt: "2024-01-01T00:00:00Z"}); err != nil {
+		t.Fatalf("SaveSnippet: %v", err)
+	}
+	if err := s.SaveSnippet(Snippet{Name: "sig", Content: "-- updated", UpdatedAt: "2024-01-02T00:00:00Z"}); err != nil {
+		t.Fatalf("SaveSnippet update: %v", err)
+	}
+
+	snippets, err := s.ListSnippets()
+	if err != nil {
+		t.Fatalf("ListSnippets: %v", err)
+	}
+	if len(snippets) != 2 || snippets[0].Name != "weekly" || !snippets[0].Template {
+		t.Fatalf("expected template first, got %+v", snippets)
+	}
+
+	got, err := s.GetSnippet("sig")
+	if err != nil {
+		t.Fatalf("GetSnippet: %v", err)
+	}
+	if got.Content != "-- updated" || got.Template {
+		t.Errorf("unexpected snippet: %+v", got)
+	}
+
+	if err := s.DeleteSnippet("sig"); err != nil {
+		t.Fatalf("DeleteSnippet: %v", err)
+	}
+	if _, err := s.GetSnippet("sig"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows after delete, got %v", err)
+	}
+}
+
+func TestDraftComments(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if err := s.SavePost(BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-01", Content: "one two three"}); err != nil {
+		t.Fatal(err)
+	}
+	add := func(start, end int, quote, body string) int64 {
+		t.Helper()
+		id, err := s.AddDraftComment(DraftComment{Slug: "draft", Start: start, End: end, Quote: quote, Body: body, CreatedAt: "2024-01-01T00:00:00Z"})
+		if err != nil {
+			t.Fatalf("AddDraftComment: %v", err)
+		}
+		return id
+	}
+	second := add(8, 13, "three", "Cut this")
+	first := add(0, 3, "one", "Stronger opening?")
+
+	if err := s.SetDraftCommentResolved("draft", first, true); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if err := s.SetDraftCommentResolved("other", second, true); err != sql.ErrNoRows {
+		t.Errorf("resolve on another post = %v, want sql.ErrNoRows", err)
+	}
+	comments, err := s.ListDraftComments("draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].ID != second || comments[0].Resolved || comments[1].ID != first || !comments[1].Resolved {
+		t.Fatalf("expected open comment first, got %+v", comments)
+	}
+	if comments[1].Quote != "one" || comments[1].Start != 0 || comments[1].End != 3 {
+		t.Errorf("unexpected comment: %+v", comments[1])
+	}
+
+	if err := s.SetDraftCommentResolved("draft", first, false); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := s.DeleteDraftComment("draft", second); err != nil {
+		t.Fatal(err)
+	}
+	if comments, _ := s.ListDraftComments("draft"); len(comments) != 1 || comments[0].Resolved {
+		t.Errorf("after reopen and delete: %+v", comments)
+	}
+
+	if err := s.DeletePost("draft"); err != nil {
+		t.Fatal(err)
+	}
+	if comments, _ := s.ListDraftComments("draft"); len(comments) != 0 {
+		t.Errorf("comments kept after DeletePost: %+v", comments)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, p := range []BlogPost{
+		{Slug: "april", Title: "April", Date: "2024-04-29", Published: true},
+		{Slug: "may", Title: "May", Date: "2024-05-15", Published: true},
+		{Slug: "plan", Title: "Plan", Date: "2024-05-15"},
+		{Slug: "june", Title: "June", Date: "2024-06-03"},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	today := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	cal, err := buildCalendar(time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC), today, s.ListPostsBetween)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.Month != "2024-05" || cal.Title != "May 2024" || cal.Prev != "2024-04" || cal.Next != "2024-06" {
+		t.Errorf("unexpected month: %+v", cal)
+	}
+	// May 2024 starts on a Wednesday and ends on a Friday.
+	if len(cal.Weeks) != 5 || cal.Weeks[0][0].Date != "2024-04-29" || cal.Weeks[4][6].Date != "2024-06-02" {
+		t.Fatalf("unexpected grid: %d weeks from %s", len(cal.Weeks), cal.Weeks[0][0].Date)
+	}
+	if first := cal.Weeks[0][0]; first.InMonth || len(first.Posts) != 1 || first.Posts[0].Slug != "april" {
+		t.Errorf("first cell = %+v", first)
+	}
+	mid := cal.Weeks[2][2]
+	if mid.Date != "2024-05-15" || !mid.InMonth || len(mid.Posts) != 2 || mid.Posts[0].Slug != "may" || mid.Posts[1].Published {
+		t.Errorf("May 15 = %+v", mid)
+	}
+	if !cal.Weeks[3][0].Today {
+		t.Errorf("May 20 not marked today: %+v", cal.Weeks[3][0])
+	}
+
+	if err := s.SetPostDate("plan", "2024-06-03"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.GetPostAny("plan"); p.Date != "2024-06-03" {
+		t.Errorf("date after SetPostDate = %q", p.Date)
+	}
+	if err := s.SetPostDate("missing", "2024-06-03"); err != sql.ErrNoRows {
+		t.Errorf("SetPostDate(missing) = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, p := range []BlogPost{
+		{Slug: "a", Title: "A", Date: "2024-05-20", Published: true},
+		{Slug: "b", Title: "B", Date: "2024-05-02", Published: true},
+		{Slug: "c", Title: "C", Date: "2024-01-10", Published: true},
+		{Slug: "d", Title: "D", Date: "2023-12-31", Published: true},
+		{Slug: "draft", Title: "Draft", Date: "2024-05-25"},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatalf("SavePost %s: %v", p.Slug, err)
+		}
+	}
+
+	posts, err := s.ListPosts("")
+	if err != nil {
+		t.Fatalf("ListPosts failed: %v", err)
+	}
+	archive := BuildArchive(posts)
+	if len(archive) != 2 || archive[0].Year != 2024 || archive[0].Count != 3 || archive[1].Year != 2023 || archive[1].Count != 1 {
+		t.Fatalf("BuildArchive years = %+v", archive)
+	}
+	may := archive[0].Months[0]
+	if len(archive[0].Months) != 2 || may.Month != "2024-05" || may.Title != "May 2024" || may.Count != 2 {
+		t.Fatalf("BuildArchive 2024 months = %+v", archive[0].Months)
+	}
+	if may.Posts[0].Slug != "a" || may.Posts[1].Slug != "b" {
+		t.Errorf("May posts = %s, %s; want a, b", may.Posts[0].Slug, may.Posts[1].Slug)
+	}
+
+	counts, err := s.ArchiveCounts()
+	if err != nil {
+		t.Fatalf("ArchiveCounts failed: %v", err)
+	}
+	if len(counts) != 2 || counts[0].Count != 3 || len(counts[0].Months) != 2 || counts[0].Months[0].Count != 2 || counts[0].Months[0].Posts != nil {
+		t.Errorf("ArchiveCounts = %+v", counts)
+	}
+	if may.URL() != "/archive/2024/05/" || archive[0].URL() != "/archive/2024/" {
+		t.Errorf("URLs = %q, %q", may.URL(), archive[0].URL())
+	}
+
+	for _, tc := range []struct {
+		year, month int
+		want        []string
+	}{
+		{2024, 0, []string{"a", "b", "c"}},
+		{2024, 5, []string{"a", "b"}},
+		{2023, 12, []string{"d"}},
+		{2024, 2, nil},
+	} {
+		posts, err := s.ListPostsByYearMonth(tc.year, tc.month)
+		if err != nil {
+			t.Fatalf("ListPostsByYearMonth(%d, %d): %v", tc.year, tc.month, err)
+		}
+		var slugs []string
+		for _, p := range posts {
+			slugs = append(slugs, p.Slug)
+		}
+		if !reflect.DeepEqual(slugs, tc.want) {
+			t.Errorf("ListPostsByYearMonth(%d, %d) = %v, want %v", tc.year, tc.month, slugs, tc.want)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if got, err := s.GetSetting("missing"); err != nil || got != "" {
+		t.Fatalf("GetSetting(missing) = %q, %v; want empty", got, err)
+	}
+	for _, val := range []string{"first", "second"} {
+		if err := s.SetSetting("key", val); err != nil {
+			t.Fatalf("SetSetting: %v", err)
+		}
+		if got, err := s.GetSetting("key"); err != nil || got != val {
+			t.Errorf("GetSetting = %q, %v; want %q", got, err, val)
+		}
+	}
+}
+
+func TestNewStoreMemory(t *testing.T) {
+	s, err := NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("NewStore(:memory:): %v", err)
+	}
+	defer s.Close()
+
+	if err := s.SavePost(BlogPost{Slug: "mem", Title: "Memory", Date: "2024-01-01", Published: true}); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetPost("mem"); err != nil {
+			t.Fatalf("GetPost on call %d: %v", i, err)
+		}
+	}
+	if _, err := os.Stat(":memory:"); err == nil {
+		t.Error("expected no file named :memory: to be created")
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	s, err := OpenStore("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("OpenStore(sqlite): %v", err)
+	}
+	defer s.Close()
+	if s.db.dialect != dialectSQLite {
+		t.Errorf("dialect = %q, want sqlite", s.db.dialect)
+	}
+	if err := s.SavePost(BlogPost{Slug: "a", Title: "A", Date: "2024-01-01", Tags: []string{"Go"}, Published: true}); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	if posts, err := s.ListPosts("go"); err != nil || len(posts) != 1 {
+		t.Errorf("ListPosts(go) = %d posts, %v; want 1", len(posts), err)
+	}
+
+	if _, err := OpenStore("oracle", "blog"); err == nil {
+		t.Error("expected an error for an unsupported driver")
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	tests := []struct {
+		d     dialect
+		query string
+		want  string
+	}{
+		{dialectSQLite, `SELECT * FROM posts WHERE slug = ? AND date < ?`, `SELECT * FROM posts WHERE slug = ? AND date < ?`},
+		{dialectPostgres, `SELECT * FROM posts WHERE slug = ? AND date < ?`, `SELECT * FROM posts WHERE slug = $1 AND date < $2`},
+		{dialectPostgres, `UPDATE posts SET summary = 'why?' WHERE slug = ?`, `UPDATE posts SET summary = 'why?' WHERE slug = $1`},
+		{dialectPostgres, `SELECT 1`, `SELECT 1`},
+		{dialectMySQL, `SELECT value FROM settings WHERE key = ? AND value != 'key'`, "SELECT value FROM settings WHERE `key` = ? AND value != 'key'"},
+		{dialectMySQL, `INSERT INTO settings (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value`, "INSERT INTO settings (`key`, value) VALUES (?, ?)\nON DUPLICATE KEY UPDATE value = VALUES(value)"},
+		{dialectMySQL, `INSERT INTO series (slug, title) VALUES (?, ?) ON CONFLICT(slug) DO NOTHING`, `INSERT IGNORE INTO series (slug, title) VALUES (?, ?)`},
+		{dialectMySQL, `SELECT monkey, key_id FROM t`, `SELECT monkey, key_id FROM t`},
+	}
+	for _, tt := range tests {
+		if got := tt.d.rebind(tt.query); got != tt.want {
+			t.Errorf("%s rebind(%q) = %q, want %q", tt.d, tt.query, got, tt.want)
+		}
+	}
+
+	ddl := dialectPostgres.ddl(`CREATE TABLE jobs (id INTEGER PRIMARY KEY AUTOINCREMENT)`)
+	if ddl != `CREATE TABLE jobs (id BIGSERIAL PRIMARY KEY)` {
+		t.Errorf("postgres ddl = %q", ddl)
+	}
+	ddl = dialectMySQL.ddl(`
+CREATE TABLE IF NOT EXISTS post_meta (
+    slug TEXT NOT NULL,
+    value TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT ''
+);`)
+	want := `
+CREATE TABLE IF NOT EXISTS post_meta (
+    slug VARCHAR(255) NOT NULL,
+    value MEDIUMTEXT NOT NULL,
+    note MEDIUMTEXT NOT NULL DEFAULT ('')
+);`
+	if ddl != want {
+		t.Errorf("mysql ddl = %q, want %q", ddl, want)
+	}
+	if got := sqlArgs([]any{"a", true, false, 3}); got[1] != 1 || got[2] != 0 || got[0] != "a" {
+		t.Errorf("sqlArgs = %v, want bools as 1 and 0", got)
+	}
+}
+
+func TestNewStoreMigratesOGImage(t *testing.T) {
+	path := "data/test_migrate.db"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE posts (slug TEXT PRIMARY KEY, title TEXT NOT NULL, date TEXT NOT NULL, tags TEXT NOT NULL DEFAULT '', summary TEXT NOT NULL DEFAULT '', content TEXT NOT NULL DEFAULT '', published INTEGER NOT NULL DEFAULT 1);
+		INSERT INTO posts (slug, title, date) VALUES ('old', 'Old', '2023-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore on an old database: %v", err)
+	}
+	defer s.Close()
+	got, err := s.GetPost("old")
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if got.OGImage != "" {
+		t.Errorf("OGImage = %q, want empty", got.OGImage)
+	}
+	if got.ShortCode == "" {
+		t.Error("expected existing posts to get a short code")
+	}
+}
+
+func TestPostTimestamps(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{Slug: "stamped", Title: "Stamped", Date: "2024-01-01", Published: true}
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetPost("stamped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(time.RFC3339, got.CreatedAt); err != nil {
+		t.Errorf("CreatedAt = %q: %v", got.CreatedAt, err)
+	}
+	if got.UpdatedAt != got.CreatedAt {
+		t.Errorf("new post UpdatedAt = %q, want CreatedAt %q", got.UpdatedAt, got.CreatedAt)
+	}
+
+	// Backdate the post to tell the saves apart.
+	if _, err := s.db.Exec(`UPDATE posts SET created_at = '2024-01-01T09:00:00Z', updated_at = '2024-01-01T09:00:00Z'`); err != nil {
+		t.Fatal(err)
+	}
+	post.Title = "Edited"
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetPost("stamped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CreatedAt != "2024-01-01T09:00:00Z" {
+		t.Errorf("CreatedAt after edit = %q, want it kept", got.CreatedAt)
+	}
+	if got.UpdatedAt <= got.CreatedAt {
+		t.Errorf("UpdatedAt after edit = %q, want later than %q", got.UpdatedAt, got.CreatedAt)
+	}
+	if got.LastModified() != got.UpdatedAt || got.UpdatedDate() == "" {
+		t.Errorf("LastModified = %q, UpdatedDate = %q", got.LastModified(), got.UpdatedDate())
+	}
+}
+
+func TestPostMeta(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{Slug: "meta", Title: "Meta", Date: "2024-01-01", Published: true,
+		Meta: map[string]string{"layout": "wide", "hero_image": "/public/uploads/hero.jpg"}}
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePost(BlogPost{Slug: "plain", Title: "Plain", Date: "2024-01-02", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetPost("meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Meta, post.Meta) {
+		t.Errorf("Meta = %v, want %v", got.Meta, post.Meta)
+	}
+	if keys := got.MetaKeys(); !reflect.DeepEqual(keys, []string{"hero_image", "layout"}) {
+		t.Errorf("MetaKeys = %q", keys)
+	}
+	posts, err := s.ListPosts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 || posts[0].Meta != nil || posts[1].Meta["layout"] != "wide" {
+		t.Errorf("listed meta = %v, %v", posts[0].Meta, posts[1].Meta)
+	}
+
+	// Saving replaces the fields, dropping keys that are gone.
+	post.Meta = map[string]string{"layout": "narrow"}
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetPost("meta"); !reflect.DeepEqual(got.Meta, post.Meta) {
+		t.Errorf("Meta after save = %v, want %v", got.Meta, post.Meta)
+	}
+	copied, err := s.DuplicatePost("meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Meta["layout"] != "narrow" {
+		t.Errorf("copy Meta = %v", copied.Meta)
+	}
+
+	if err := s.DeletePost("meta"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM post_meta WHERE slug = 'meta'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d custom fields left after delete", n)
+	}
+}
+
+func TestNewStoreBackfillsTimestamps(t *testing.T) {
+	path := "data/test_migrate.db"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE posts (slug TEXT PRIMARY KEY, title TEXT NOT NULL, date TEXT NOT NULL, tags TEXT NOT NULL DEFAULT '', summary TEXT NOT NULL DEFAULT '', content TEXT NOT NULL DEFAULT '', published INTEGER NOT NULL DEFAULT 1);
+		INSERT INTO posts (slug, title, date) VALUES ('old', 'Old', '2023-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore on an old database: %v", err)
+	}
+	defer s.Close()
+	got, err := s.GetPost("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CreatedAt != "2023-01-01T00:00:00Z" || got.UpdatedAt != got.CreatedAt {
+		t.Errorf("CreatedAt, UpdatedAt = %q, %q, want both from the date", got.CreatedAt, got.UpdatedAt)
+	}
+	if got.UpdatedDate() != "" {
+		t.Errorf("UpdatedDate = %q, want empty for an unedited post", got.UpdatedDate())
+	}
+}
+
+func TestShortCodes(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{Slug: "short", Title: "Short", Date: "2024-01-01", Published: true}
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost failed: %v", err)
+	}
+	got, err := s.GetPost("short")
+	if err != nil {
+		t.Fatalf("GetPost failed: %v", err)
+	}
+	if len(got.ShortCode) != 4 {
+		t.Fatalf("ShortCode = %q, want 4 characters", got.ShortCode)
+	}
+
+	post.Title = "Updated"
+	if err := s.SavePost(post); err != nil {
+		t.Fatalf("SavePost update failed: %v", err)
+	}
+	again, err := s.GetPostAny("short")
+	if err != nil {
+		t.Fatalf("GetPostAny failed: %v", err)
+	}
+	if again.ShortCode != got.ShortCode {
+		t.Errorf("ShortCode changed on update: %q to %q", got.ShortCode, again.ShortCode)
+	}
+
+	slug, err := s.ShortCodeSlug(got.ShortCode)
+	if err != nil || slug != "short" {
+		t.Errorf("ShortCodeSlug = %q, %v", slug, err)
+	}
+	if _, err := s.ShortCodeSlug("none"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an unknown code, got %v", err)
+	}
+	if u := ShortURL("https://example.com", got.ShortCode); u != "https://example.com/s/"+got.ShortCode {
+		t.Errorf("ShortURL = %q", u)
+	}
+}
+
+func TestRenamePost(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	post := BlogPost{Slug: "old", Title: "Old", Date: "2024-01-01", Published: true, Meta: map[string]string{"layout": "wide"}}
+	if err := s.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePost(BlogPost{Slug: "other", Title: "Other", Date: "2024-01-02", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := s.GetPost("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RenamePost("old", "new"); err != nil {
+		t.Fatalf("RenamePost failed: %v", err)
+	}
+	got, err := s.GetPost("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Meta["layout"] != "wide" || got.ShortCode != before.ShortCode {
+		t.Errorf("renamed post lost data: meta %v, short code %q want %q", got.Meta, got.ShortCode, before.ShortCode)
+	}
+	if _, err := s.GetPostAny("old"); err != sql.ErrNoRows {
+		t.Errorf("old slug still has a post: %v", err)
+	}
+	if to, err := s.ResolveRedirect("old"); err != nil || to != "new" {
+		t.Errorf("ResolveRedirect(old) = %q, %v", to, err)
+	}
+
+	// Renaming again points earlier redirects at the newest slug, and
+	// renaming back drops the redirect from the slug the post returns to.
+	if err := s.RenamePost("new", "newer"); err != nil {
+		t.Fatal(err)
+	}
+	if to, _ := s.ResolveRedirect("old"); to != "newer" {
+		t.Errorf("ResolveRedirect(old) = %q after second rename, want newer", to)
+	}
+	if err := s.RenamePost("newer", "old"); err != nil {
+		t.Fatal(err)
+	}
+	redirects, err := s.ListRedirects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range redirects {
+		if r.From == "old" || r.To != "old" {
+			t.Errorf("redirect %+v after renaming back", r)
+		}
+	}
+	if len(redirects) != 2 {
+		t.Errorf("got %d redirects, want new and newer", len(redirects))
+	}
+
+	if err := s.RenamePost("old", "other"); err == nil {
+		t.Error("expected an error renaming onto a taken slug")
+	}
+	if err := s.RenamePost("missing", "fresh"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows renaming a missing post, got %v", err)
+	}
+	if err := s.DeleteRedirect("new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResolveRedirect("new"); err != sql.ErrNoRows {
+		t.Errorf("deleted redirect still resolves: %v", err)
+	}
+}
+
+func TestParsePostLinks(t *testing.T) {
+	content := "See [the intro](/blog/intro/) and [setup](/blog/setup#install).\n" +
+		"Again: [intro](/blog/intro/), <a href=\"/blog/html-link/\">html</a>.\n" +
+		"[ref]: /blog/by-reference/\n" +
+		"[elsewhere](https://example.com/blog/external/) [tagged](/tags/go/)\n" +
+		"```\n[in code](/blog/code/)\n```\n"
+	got := ParsePostLinks(content)
+	want := []string{"intro", "setup", "html-link", "by-reference"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ParsePostLinks = %v, want %v", got, want)
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, p := range []BlogPost{
+		{Slug: "target", Title: "Target", Date: "2024-01-01", Published: true},
+		{Slug: "old", Title: "Old", Date: "2024-02-01", Content: "Read [this](/blog/target/).", Published: true},
+		{Slug: "new", Title: "New", Date: "2024-03-01", Content: "More on [target](/blog/target/), and [me](/blog/new/).", Published: true},
+		{Slug: "draft", Title: "Draft", Date: "2024-04-01", Content: "[t](/blog/target/)"},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatalf("SavePost(%s): %v", p.Slug, err)
+		}
+	}
+	slugs := func(posts []BlogPost) string {
+		var out []string
+		for _, p := range posts {
+			out = append(out, p.Slug)
+		}
+		return strings.Join(out, ",")
+	}
+
+	got, err := s.ListBacklinks("target")
+	if err != nil {
+		t.Fatalf("ListBacklinks: %v", err)
+	}
+	if slugs(got) != "draft,new,old" {
+		t.Errorf("ListBacklinks = %s, want draft,new,old", slugs(got))
+	}
+	if got, _ := s.ListBacklinks("new"); len(got) != 0 {
+		t.Errorf("self link counted as a backlink: %s", slugs(got))
+	}
+
+	cache := NewPostCache(s, time.Minute)
+	if got, _ := cache.ListBacklinks("target"); slugs(got) != "new,old" {
+		t.Errorf("cached backlinks = %s, want the published new,old", slugs(got))
+	}
+
+	// Renaming keeps links both ways, and links to the old slug count for
+	// the new one.
+	if err := s.RenamePost("target", "moved"); err != nil {
+		t.Fatalf("RenamePost: %v", err)
+	}
+	if err := s.SavePost(BlogPost{Slug: "late", Title: "Late", Date: "2024-05-01", Content: "[t](/blog/target/)", Published: true}); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	if got, _ := s.ListBacklinks("moved"); slugs(got) != "late,draft,new,old" {
+		t.Errorf("backlinks after rename = %s, want late,draft,new,old", slugs(got))
+	}
+
+	// Editing away a link drops it; trashed and deleted posts don't link.
+	if err := s.SavePost(BlogPost{Slug: "old", Title: "Old", Date: "2024-02-01", Content: "No links now.", Published: true}); err != nil {
+		t.Fatalf("SavePost: %v", err)
+	}
+	if err := s.TrashPost("new"); err != nil {
+		t.Fatalf("TrashPost: %v", err)
+	}
+	if err := s.DeletePost("draft"); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if got, _ := s.ListBacklinks("moved"); slugs(got) != "late" {
+		t.Errorf("backlinks = %s, want late", slugs(got))
+	}
+}
+
+func TestJobs(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	now := time.Now()
+	id, err := s.EnqueueJob("send", `{"to":"a"}`, 2, now)
+	if err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+	if _, err := s.EnqueueJob("later", `{}`, 2, now.Add(time.Hour)); err != nil {
+		t.Fatalf("EnqueueJob failed: %v", err)
+	}
+
+	job, err := s.ClaimJob(now, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimJob failed: %v", err)
+	}
+	if job.ID != id || job.Kind != "send" || job.Payload != `{"to":"a"}` || job.Attempts != 1 {
+		t.Fatalf("claimed %+v", job)
+	}
+	if _, err := s.ClaimJob(now, time.Minute); err != sql.ErrNoRows {
+		t.Errorf("claiming a locked job: got err %v, want sql.ErrNoRows", err)
+	}
+	// A running worker keeps the job by extending its lease.
+	if err := s.ExtendJobLease(id, now.Add(3*time.Minute)); err != nil {
+		t.Fatalf("ExtendJobLease failed: %v", err)
+	}
+	if _, err := s.ClaimJob(now.Add(2*time.Minute), time.Minute); err != sql.ErrNoRows {
+		t.Errorf("claiming a job with an extended lease: got err %v, want sql.ErrNoRows", err)
+	}
+	// A worker that died holding the job loses it when the lease runs out.
+	if job, err = s.ClaimJob(now.Add(4*time.Minute), time.Minute); err != nil || job.ID != id || job.Attempts != 2 {
+		t.Fatalf("reclaim after lease = %+v, %v", job, err)
+	}
+
+	if err := s.FailJob(id, "boom", now); err != nil {
+		t.Fatalf("FailJob failed: %v", err)
+	}
+	dead, err := s.ListDeadJobs()
+	if err != nil {
+		t.Fatalf("ListDeadJobs failed: %v", err)
+	}
+	if len(dead) != 1 || dead[0].LastError != "boom" || dead[0].DeadAt == "" {
+		t.Fatalf("dead jobs = %+v", dead)
+	}
+	if queued, n, err := s.CountJobs(); err != nil || queued != 1 || n != 1 {
+		t.Errorf("CountJobs = %d, %d, %v", queued, n, err)
+	}
+	if _, err := s.ClaimJob(now.Add(6*time.Minute), time.Minute); err != sql.ErrNoRows {
+		t.Errorf("claiming a dead job: got err %v, want sql.ErrNoRows", err)
+	}
+
+	if err := s.RetryJob(id); err != nil {
+		t.Fatalf("RetryJob failed: %v", err)
+	}
+	if err := s.RetryJob(id); err != sql.ErrNoRows {
+		t.Errorf("RetryJob of a queued job: got err %v, want sql.ErrNoRows", err)
+	}
+	job, err = s.ClaimJob(time.Now(), time.Minute)
+	if err != nil || job.ID != id || job.Attempts != 1 {
+		t.Fatalf("claim after retry = %+v, %v", job, err)
+	}
+	// A failure with attempts left schedules a retry instead.
+	if err := s.FailJob(id, "again", now.Add(time.Minute)); err != nil {
+		t.Fatalf("FailJob failed: %v", err)
+	}
+	if _, n, _ := s.CountJobs(); n != 0 {
+		t.Errorf("dead jobs after a retryable failure = %d", n)
+	}
+	if err := s.CompleteJob(id); err != nil {
+		t.Fatalf("CompleteJob failed: %v", err)
+	}
+	if queued, _, _ := s.CountJobs(); queued != 1 {
+		t.Errorf("queued after complete = %d", queued)
+	}
+}
+
+func TestFeaturedPosts(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, p := range []BlogPost{
+		{Slug: "old", Title: "Old", Date: "2024-01-01", Published: true},
+		{Slug: "new", Title: "New", Date: "2024-03-01", Published: true},
+		{Slug: "draft", Title: "Draft", Date: "2024-02-01"},
+	} {
+		if err := s.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, slug := range []string{"old", "draft"} {
+		if err := s.SetPostFeatured(slug, true); err != nil {
+			t.Fatalf("SetPostFeatured(%s): %v", slug, err)
+		}
+	}
+	if err := s.SetPostFeatured("missing", true); err != sql.ErrNoRows {
+		t.Errorf("SetPostFeatured(missing) = %v, want sql.ErrNoRows", err)
+	}
+
+	featured, err := s.ListFeaturedPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(featured) != 1 || featured[0].Slug != "old" || !featured[0].Featured {
+		t.Errorf("ListFeaturedPosts() = %+v, want only the published old post", featured)
+	}
+
+	posts, err := s.ListPosts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := featuredFirst(posts); len(got) != 2 || got[0].Slug != "old" || got[1].Slug != "new" {
+		t.Errorf("featuredFirst order = %v, want old then new", got)
+	}
+
+	if err := s.SetPostFeatured("old", false); err != nil {
+		t.Fatal(err)
+	}
+	if featured, err := s.ListFeaturedPosts(); err != nil || len(featured) != 0 {
+		t.Errorf("ListFeaturedPosts() after unfeaturing = %v, %v", featured, err)
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	prose := strings.Repeat("word ", 450)
+	code := "```go\n" + strings.Repeat("x := compute(a, b, c, d, e, f)\n", 80) + "```\n"
+	for _, tc := range []struct {
+		content string
+		want    int
+	}{
+		{"", 0},
+		{"Just a few words.", 1},
+		{prose, 3},
+		{code, 2}, // 80 lines at 5 words each, not their 560 words
+		{prose + "\n" + code, 5},
+	} {
+		if got := ReadingMinutes(tc.content); got != tc.want {
+			t.Errorf("ReadingMinutes(%.20q...) = %d, want %d", tc.content, got, tc.want)
+		}
+	}
+
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	if err := s.SavePost(BlogPost{Slug: "long", Title: "Long", Date: "2024-01-01", Content: prose, Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetPost("long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ReadingMinutes != 3 || got.ReadingTime() != "3 min read" {
+		t.Errorf("ReadingMinutes = %d, ReadingTime = %q", got.ReadingMinutes, got.ReadingTime())
+	}
+	if d := feedDescription(SiteConfig{}, BlogPost{Summary: "A tour", ReadingMinutes: 3}); d != "A tour (3 min read)" {
+		t.Errorf("feedDescription = %q", d)
+	}
+}
+
+func TestBackfillPostStats(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	// More posts than one UPDATE sets, with the stats of posts saved
+	// before migration 4.
+	n := postStatsBatch + 1
+	for i := range n {
+		content := "Short"
+		if i == n-1 {
+			content = "![Cover](/public/uploads/cover.jpg){}\n\n" + strings.Repeat("word ", 450)
+		}
+		if _, err := s.db.Exec(`INSERT INTO posts (slug, title, date, tags, summary, content) VALUES (?, ?, ?, '', '', ?)`,
+			"post-"+strconv.Itoa(i), "Post", "2024-01-01", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.backfillPostStats(); err != nil {
+		t.Fatalf("backfillPostStats: %v", err)
+	}
+	var minutes int
+	var image string
+	if err := s.db.QueryRow(`SELECT reading_minutes, content_image FROM posts WHERE slug = ?`, "post-"+strconv.Itoa(n-1)).Scan(&minutes, &image); err != nil {
+		t.Fatal(err)
+	}
+	if minutes != 3 || image != "/public/uploads/cover.jpg" {
+		t.Errorf("last post stats = %d, %q", minutes, image)
+	}
+	var unset int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM posts WHERE reading_minutes = 0`).Scan(&unset); err != nil || unset != 0 {
+		t.Errorf("posts without a reading time = %d, %v", unset, err)
+	}
+}
+
+func TestListPostSummaries(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+	content := "![Cover](/public/uploads/cover.jpg){}\n\n" + strings.Repeat("word ", 450)
+	if err := s.SavePost(BlogPost{Slug: "long", Title: "Long", Date: "2024-01-01", Tags: []string{"go"}, Content: content, Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SavePost(BlogPost{Slug: "draft", Title: "Draft", Date: "2024-01-02", Content: "Draft", Published: false}); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := s.ListPostSummaries("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Slug != "long" || posts[0].Content != "" {
+		t.Fatalf("summaries = %+v", posts)
+	}
+	if posts[0].ReadingMinutes != 3 || posts[0].SocialImage() != "/public/uploads/cover.jpg" {
+		t.Errorf("ReadingMinutes = %d, SocialImage = %q", posts[0].ReadingMinutes, posts[0].SocialImage())
+	}
+
+	// The cache lists summaries and reads content on GetPost.
+	cache := NewPostCache(s, time.Minute)
+	if posts, _ := cache.ListPosts(""); len(posts) != 1 || posts[0].Content != "" {
+		t.Errorf("cached posts = %+v", posts)
+	}
+	for range 2 {
+		if p, err := cache.GetPost("long"); err != nil || p.Content != content {
+			t.Errorf("cached post content = %.20q, %v", p.Content, err)
+		}
+	}
+	if _, err := cache.GetPost("draft"); err != ErrNotFound {
+		t.Errorf("cached draft: %v", err)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Short summary", 160, "Short summary"},
+		{"  Spaces\n and\tnewlines  ", 160, "Spaces and newlines"},
+		{"A tour of generics in Go, with examples", 20, "A tour of generics…"},
+		{"A tour of generics, in Go", 20, "A tour of generics…"},
+		{"Ünïcödé wörds everywhere", 12, "Ünïcödé…"},
+		{"Supercalifragilistic", 10, "Supercali…"},
+		{"Anything at all", -1, "Anything at all"},
+		{"Anything at all", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := TruncateText(tt.in, tt.n); got != tt.want {
+			t.Errorf("TruncateText(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+		if got := TruncateText(tt.in, tt.n); tt.n >= 0 && utf8.RuneCountInString(got) > tt.n {
+			t.Errorf("TruncateText(%q, %d) is %d characters long", tt.in, tt.n, utf8.RuneCountInString(got))
+		}
+	}
+
+	p := BlogPost{Summary: strings.Repeat("word ", 50)}
+	if got := p.Excerpt(SiteConfig{SummaryLength: 160}); utf8.RuneCountInString(got) > 160 || !strings.HasSuffix(got, "…") {
+		t.Errorf("Excerpt with a length = %q", got)
+	}
+	if got := p.Excerpt(SiteConfig{}); got != strings.TrimSpace(p.Summary) {
+		t.Errorf("Excerpt without a length = %q", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	latest := s.storeMigrations()[len(s.storeMigrations())-1].Version
+	if v, _ := s.GetSetting("schema_version"); v != strconv.Itoa(latest) {
+		t.Errorf("schema_version = %q, want %d", v, latest)
+	}
+	runs := 0
+	migrations := []Migration{
+		{2, "add views", func(db MigrationDB) error {
+			runs++
+			return db.AddColumn("notes", "views INTEGER NOT NULL DEFAULT 0")
+		}},
+		{1, "create notes", func(db MigrationDB) error {
+			runs++
+			if err := db.CreateTable(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, body TEXT NOT NULL)`); err != nil {
+				return err
+			}
+			return db.CreateIndex("idx_notes_body", "notes", "body")
+		}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(migrations...); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("migrations ran %d times, want 2", runs)
+	}
+	if v, _ := s.GetSetting("app_schema_version"); v != "2" {
+		t.Errorf("app_schema_version = %q, want 2", v)
+	}
+	if _, err := s.db.Exec(`INSERT INTO notes (body, views) VALUES (?, ?)`, "hi", 3); err != nil {
+		t.Errorf("insert into migrated table: %v", err)
+	}
+
+	dup := append(migrations, Migration{2, "again", func(MigrationDB) error { return nil }})
+	if err := s.Migrate(dup...); err == nil {
+		t.Error("Migrate with a duplicate version succeeded")
+	}
+	if err := s.Migrate(migrations[1]); err == nil {
+		t.Error("Migrate below the recorded app version succeeded")
+	}
+	failing := append(migrations, Migration{3, "broken", func(MigrationDB) error { return errors.New("boom") }})
+	if err := s.Migrate(failing...); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Migrate with a failing migration = %v", err)
+	}
+	if v, _ := s.GetSetting("app_schema_version"); v != "2" {
+		t.Errorf("app_schema_version after a failure = %q, want 2", v)
+	}
+
+	// A migration that failed after adding a column runs again.
+	fail := true
+	partial := append(migrations, Migration{3, "add title", func(db MigrationDB) error {
+		if err := db.AddColumn("notes", "title TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}})
+	if err := s.Migrate(partial...); err == nil {
+		t.Fatal("Migrate with a failing migration succeeded")
+	}
+	fail = false
+	if err := s.Migrate(partial...); err != nil {
+		t.Fatalf("rerun of a partly applied migration: %v", err)
+	}
+	if v, _ := s.GetSetting("app_schema_version"); v != "3" {
+		t.Errorf("app_schema_version after the rerun = %q, want 3", v)
+	}
+}
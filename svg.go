@@ -0,0 +1,205 @@
+package pubengine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// svgDroppedElements are removed from uploaded SVGs along with their
+// content: they run scripts or embed HTML.
+var svgDroppedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// looksLikeSVG reports whether data is an XML document whose root element
+// is <svg>.
+func looksLikeSVG(data []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t.Name.Local == "svg"
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return false
+			}
+		}
+	}
+}
+
+// sanitizeSVG rewrites an uploaded SVG without scripts, foreignObject and
+// other embedding elements, event handler attributes, links other than
+// fragment and raster data: URLs, comments and processing instructions.
+// Documents with a DOCTYPE are rejected, since entity declarations can
+// expand to anything. Returns the cleaned document and the dimensions from
+// the root's width and height, or its viewBox.
+func sanitizeSVG(data []byte) ([]byte, int, int, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		out      bytes.Buffer
+		stack    []string
+		skip     int
+		w, h     int
+		seenRoot bool
+	)
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("parse svg: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := svgName(t.Name)
+			if seenRoot && len(stack) == 0 {
+				return nil, 0, 0, errors.New("parse svg: content after the root element")
+			}
+			stack = append(stack, name)
+			if skip > 0 || svgDropElement(t) {
+				skip++
+				continue
+			}
+			if !seenRoot {
+				if t.Name.Local != "svg" {
+					return nil, 0, 0, errors.New("root element is not <svg>")
+				}
+				seenRoot = true
+				w, h = svgSize(t.Attr)
+			}
+			out.WriteString("<" + name)
+			for _, attr := range t.Attr {
+				if svgDropAttr(attr) {
+					continue
+				}
+				out.WriteString(" " + svgName(attr.Name) + `="`)
+				xml.EscapeText(&out, []byte(attr.Value))
+				out.WriteByte('"')
+			}
+			out.WriteByte('>')
+		case xml.EndElement:
+			name := svgName(t.Name)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, 0, 0, fmt.Errorf("parse svg: unexpected </%s>", name)
+			}
+			stack = stack[:len(stack)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + name + ">")
+		case xml.CharData:
+			if skip > 0 || len(stack) == 0 || svgUnsafeText(string(t)) {
+				continue
+			}
+			xml.EscapeText(&out, t)
+		case xml.Directive:
+			return nil, 0, 0, errors.New("DOCTYPE and entity declarations are not allowed in SVG")
+		}
+	}
+	if !seenRoot || len(stack) > 0 {
+		return nil, 0, 0, errors.New("parse svg: incomplete document")
+	}
+	return out.Bytes(), w, h, nil
+}
+
+// svgName returns the prefixed name as written in the document.
+func svgName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// svgDropElement reports whether el is removed with its content: embedding
+// elements, and animations that change a link.
+func svgDropElement(el xml.StartElement) bool {
+	local := strings.ToLower(el.Name.Local)
+	if svgDroppedElements[local] {
+		return true
+	}
+	if local == "set" || strings.HasPrefix(local, "animate") {
+		for _, attr := range el.Attr {
+			if attr.Name.Local == "attributeName" && strings.Contains(strings.ToLower(attr.Value), "href") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// svgDropAttr reports whether attr is removed: event handlers, links that
+// aren't fragments or raster data: URLs, and values mentioning javascript:.
+func svgDropAttr(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	if strings.HasPrefix(local, "on") {
+		return true
+	}
+	if local == "href" || local == "src" {
+		v := strings.TrimSpace(attr.Value)
+		return !strings.HasPrefix(v, "#") &&
+			!strings.HasPrefix(v, "data:image/png") &&
+			!strings.HasPrefix(v, "data:image/jpeg") &&
+			!strings.HasPrefix(v, "data:image/gif") &&
+			!strings.HasPrefix(v, "data:image/webp")
+	}
+	return svgUnsafeText(attr.Value)
+}
+
+// svgUnsafeText reports whether text or CSS could load a script or an
+// external stylesheet.
+func svgUnsafeText(s string) bool {
+	s = strings.ToLower(s)
+	return strings.Contains(s, "javascript:") || strings.Contains(s, "@import")
+}
+
+// svgSize returns the width and height of the root element in pixels, from
+// its width and height attributes or else its viewBox. Unknown sizes are 0.
+func svgSize(attrs []xml.Attr) (int, int) {
+	var w, h int
+	var viewBox string
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "width":
+			w = svgLength(attr.Value)
+		case "height":
+			h = svgLength(attr.Value)
+		case "viewBox":
+			viewBox = attr.Value
+		}
+	}
+	if w > 0 && h > 0 {
+		return w, h
+	}
+	f := strings.Fields(strings.ReplaceAll(viewBox, ",", " "))
+	if len(f) == 4 {
+		return svgLength(f[2]), svgLength(f[3])
+	}
+	return w, h
+}
+
+// svgLength parses a length in pixels such as "120" or "120px". Other
+// units return 0.
+func svgLength(s string) int {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return int(v + 0.5)
+}
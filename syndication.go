@@ -0,0 +1,406 @@
+package pubengine
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Cross-posting services a post can be syndicated to.
+const (
+	ServiceDevto    = "devto"
+	ServiceHashnode = "hashnode"
+)
+
+// Syndication statuses.
+const (
+	SyndicationPending = "pending" // opted in, not posted yet
+	SyndicationPosted  = "posted"
+	SyndicationFailed  = "failed"  // the last attempt failed; it is retried
+	SyndicationStopped = "stopped" // opted out after being posted; the copy is no longer updated
+)
+
+// syndicateJobKind is the job kind of cross-posts.
+const syndicateJobKind = "syndicate"
+
+// Cross-posting API endpoints, variables for tests.
+var (
+	devtoAPIURL    = "https://dev.to/api/articles"
+	hashnodeAPIURL = "https://gql.hashnode.com"
+)
+
+var syndicationClient = &http.Client{Timeout: 30 * time.Second}
+
+// Syndication is a post's copy on a cross-posting service.
+type Syndication struct {
+	Slug      string
+	Service   string // ServiceDevto or ServiceHashnode
+	Status    string // SyndicationPending, SyndicationPosted, SyndicationFailed or SyndicationStopped; empty when the post was never opted in
+	RemoteID  string // the copy's ID on the service, once posted
+	URL       string // the copy's URL, once posted
+	Error     string // why the last attempt failed
+	UpdatedAt string // RFC3339 time of the last change
+}
+
+// OptedIn reports whether the post is cross-posted to the service on
+// publish and updated there on save.
+func (s Syndication) OptedIn() bool {
+	return s.Status != "" && s.Status != SyndicationStopped
+}
+
+// ServiceName returns the name of the service for display, e.g. "dev.to".
+func (s Syndication) ServiceName() string {
+	switch s.Service {
+	case ServiceDevto:
+		return "dev.to"
+	case ServiceHashnode:
+		return "Hashnode"
+	}
+	return s.Service
+}
+
+// GetSyndication returns the syndication of a post to a service, or
+// sql.ErrNoRows if the post isn't opted in.
+func (s *Store) GetSyndication(slug, service string) (Syndication, error) {
+	var sy Syndication
+	err := s.db.QueryRow(`SELECT slug, service, status, remote_id, url, error, updated_at FROM syndications WHERE slug = ? AND service = ?`, slug, service).
+		Scan(&sy.Slug, &sy.Service, &sy.Status, &sy.RemoteID, &sy.URL, &sy.Error, &sy.UpdatedAt)
+	return sy, err
+}
+
+// ListSyndications returns every syndication, by slug and service.
+func (s *Store) ListSyndications() ([]Syndication, error) {
+	rows, err := s.db.Query(`SELECT slug, service, status, remote_id, url, error, updated_at FROM syndications ORDER BY slug, service`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Syndication
+	for rows.Next() {
+		var sy Syndication
+		if err := rows.Scan(&sy.Slug, &sy.Service, &sy.Status, &sy.RemoteID, &sy.URL, &sy.Error, &sy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, sy)
+	}
+	return list, rows.Err()
+}
+
+// SaveSyndication inserts or replaces the syndication of sy.Slug to
+// sy.Service. UpdatedAt is set to now.
+func (s *Store) SaveSyndication(sy Syndication) error {
+	_, err := s.db.Exec(`INSERT INTO syndications (slug, service, status, remote_id, url, error, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(slug, service) DO UPDATE SET status = excluded.status, remote_id = excluded.remote_id, url = excluded.url,
+    error = excluded.error, updated_at = excluded.updated_at`,
+		sy.Slug, sy.Service, sy.Status, sy.RemoteID, sy.URL, sy.Error, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// DeleteSyndication opts a post out of a service. A copy already posted
+// stays on the service.
+func (s *Store) DeleteSyndication(slug, service string) error {
+	_, err := s.db.Exec(`DELETE FROM syndications WHERE slug = ? AND service = ?`, slug, service)
+	return err
+}
+
+// syndicationServices returns the services with credentials in settings.
+func (s SiteSettings) syndicationServices() []string {
+	var services []string
+	if s.DevtoAPIKey != "" {
+		services = append(services, ServiceDevto)
+	}
+	if s.HashnodeToken != "" && s.HashnodePublicationID != "" {
+		services = append(services, ServiceHashnode)
+	}
+	return services
+}
+
+// postSyndications returns a Syndication for each service with
+// credentials, with an empty Status for those slug isn't opted in to.
+func (a *App) postSyndications(slug string) ([]Syndication, error) {
+	settings, err := a.siteSettings()
+	if err != nil {
+		return nil, err
+	}
+	var list []Syndication
+	for _, service := range settings.syndicationServices() {
+		sy, err := a.Store.GetSyndication(slug, service)
+		if err == sql.ErrNoRows {
+			sy = Syndication{Slug: slug, Service: service}
+		} else if err != nil {
+			return nil, err
+		}
+		list = append(list, sy)
+	}
+	return list, nil
+}
+
+// withSyndications sets Syndications on the posts opted in to a service.
+func (a *App) withSyndications(posts []BlogPost) error {
+	list, err := a.Store.ListSyndications()
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	bySlug := make(map[string][]Syndication)
+	for _, sy := range list {
+		bySlug[sy.Slug] = append(bySlug[sy.Slug], sy)
+	}
+	for i := range posts {
+		posts[i].Syndications = bySlug[posts[i].Slug]
+	}
+	return nil
+}
+
+// syndicate opts post in to the services checked in the post form and out
+// of the others, then queues a cross-post, or an update of the copy, to
+// each service it is opted in to if it is published.
+func (a *App) syndicate(post BlogPost, checked []string) error {
+	current, err := a.postSyndications(post.Slug)
+	if err != nil {
+		return err
+	}
+	for _, sy := range current {
+		want := slices.Contains(checked, sy.Service)
+		if want != sy.OptedIn() {
+			switch {
+			case want && sy.RemoteID != "":
+				sy.Status = SyndicationPosted
+			case want:
+				sy.Status = SyndicationPending
+			case sy.RemoteID != "":
+				// The copy stays on the service, no longer updated.
+				sy.Status = SyndicationStopped
+			default:
+				if err := a.Store.DeleteSyndication(sy.Slug, sy.Service); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := a.Store.SaveSyndication(sy); err != nil {
+				return err
+			}
+		}
+		if want && post.Published {
+			if err := a.Enqueue(syndicateJobKind, syndicateJob{Slug: post.Slug, Service: sy.Service}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// queueSyndication queues a cross-post, or an update of the copy, of the
+// post slug to each service it is opted in to.
+func (a *App) queueSyndication(slug string) error {
+	list, err := a.postSyndications(slug)
+	if err != nil {
+		return err
+	}
+	for _, sy := range list {
+		if sy.OptedIn() {
+			if err := a.Enqueue(syndicateJobKind, syndicateJob{Slug: slug, Service: sy.Service}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syndicateJob is the payload of a cross-post job.
+type syndicateJob struct {
+	Slug    string `json:"slug"`
+	Service string `json:"service"`
+}
+
+// runSyndicate cross-posts a published post to a service, or updates the
+// copy posted before, and records the outcome. Posts that were
+// unpublished or opted out since the job was queued are skipped.
+func (a *App) runSyndicate(payload []byte) error {
+	var job syndicateJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	sy, err := a.Store.GetSyndication(job.Slug, job.Service)
+	if err == sql.ErrNoRows || err == nil && !sy.OptedIn() {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	post, err := a.Content.GetPost(job.Slug)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	settings, err := a.siteSettings()
+	if err != nil {
+		return err
+	}
+	post = post.ForCrossPost(a.Config)
+	post.OGImage = AbsoluteURL(BuildURL(a.Config.URL), a.Config.mediaURL(post.SocialImage()))
+
+	var id, url string
+	switch job.Service {
+	case ServiceDevto:
+		id, url, err = postToDevto(settings.DevtoAPIKey, sy.RemoteID, post)
+	case ServiceHashnode:
+		id, url, err = postToHashnode(settings.HashnodeToken, settings.HashnodePublicationID, sy.RemoteID, post)
+	default:
+		return fmt.Errorf("unknown syndication service %q", job.Service)
+	}
+	if err != nil {
+		sy.Status, sy.Error = SyndicationFailed, err.Error()
+		if serr := a.Store.SaveSyndication(sy); serr != nil {
+			return errors.Join(err, serr)
+		}
+		return err
+	}
+	sy.Status, sy.RemoteID, sy.URL, sy.Error = SyndicationPosted, id, url, ""
+	return a.Store.SaveSyndication(sy)
+}
+
+// devtoTags returns the first four tags of a post as dev.to accepts them:
+// lowercase letters and digits only.
+func devtoTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		t = strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, t)
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+		if len(out) == 4 {
+			break
+		}
+	}
+	return out
+}
+
+// postToDevto creates a published dev.to article from post, or updates the
+// article id, and returns its id and URL.
+func postToDevto(apiKey, id string, post BlogPost) (string, string, error) {
+	article := map[string]any{
+		"title":         post.Title,
+		"body_markdown": post.Content,
+		"published":     true,
+		"tags":          devtoTags(post.Tags),
+		"canonical_url": post.CanonicalURL,
+		"description":   post.Summary,
+	}
+	if post.OGImage != "" {
+		article["main_image"] = post.OGImage
+	}
+	body, err := json.Marshal(map[string]any{"article": article})
+	if err != nil {
+		return "", "", err
+	}
+	method, endpoint := http.MethodPost, devtoAPIURL
+	if id != "" {
+		method, endpoint = http.MethodPut, devtoAPIURL+"/"+id
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.forem.api-v1+json")
+	req.Header.Set("api-key", apiKey)
+	var resp struct {
+		ID  int64  `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := doSyndicationRequest(req, &resp); err != nil {
+		return "", "", fmt.Errorf("dev.to: %w", err)
+	}
+	return strconv.FormatInt(resp.ID, 10), resp.URL, nil
+}
+
+// Hashnode GraphQL mutations creating and updating a post.
+const (
+	hashnodePublishPost = `mutation PublishPost($input: PublishPostInput!) { publishPost(input: $input) { post { id url } } }`
+	hashnodeUpdatePost  = `mutation UpdatePost($input: UpdatePostInput!) { updatePost(input: $input) { post { id url } } }`
+)
+
+// postToHashnode publishes post to a Hashnode publication, or updates the
+// post id, and returns its id and URL.
+func postToHashnode(token, publicationID, id string, post BlogPost) (string, string, error) {
+	tags := make([]map[string]string, 0, len(post.Tags))
+	for _, t := range post.Tags {
+		tags = append(tags, map[string]string{"slug": Slugify(t), "name": t})
+	}
+	input := map[string]any{
+		"title":              post.Title,
+		"contentMarkdown":    post.Content,
+		"tags":               tags,
+		"originalArticleURL": post.CanonicalURL,
+	}
+	if post.Summary != "" {
+		input["subtitle"] = post.Summary
+	}
+	if post.OGImage != "" {
+		input["coverImageOptions"] = map[string]string{"coverImageURL": post.OGImage}
+	}
+	query, field := hashnodePublishPost, "publishPost"
+	if id != "" {
+		input["id"] = id
+		query, field = hashnodeUpdatePost, "updatePost"
+	} else {
+		input["publicationId"] = publicationID
+	}
+	body, err := json.Marshal(map[string]any{"query": query, "variables": map[string]any{"input": input}})
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, hashnodeAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	var resp struct {
+		Data   map[string]struct{ Post struct{ ID, URL string } } `json:"data"`
+		Errors []struct{ Message string }                         `json:"errors"`
+	}
+	if err := doSyndicationRequest(req, &resp); err != nil {
+		return "", "", fmt.Errorf("hashnode: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return "", "", fmt.Errorf("hashnode: %s", resp.Errors[0].Message)
+	}
+	p := resp.Data[field].Post
+	if p.ID == "" {
+		return "", "", errors.New("hashnode: no post in response")
+	}
+	return p.ID, p.URL, nil
+}
+
+// doSyndicationRequest sends req and decodes a 2xx JSON response into v.
+func doSyndicationRequest(req *http.Request, v any) error {
+	resp, err := syndicationClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body[:min(len(body), 200)])))
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,142 @@
+package pubengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrossPost(t *testing.T) {
+	app := newSetUpTestApp(t)
+	post := BlogPost{Slug: "cross", Title: "Cross", Date: "2024-01-01", Published: true, CanonicalURL: "https://www.dev.to/jane/cross"}
+	if err := app.Store.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if got := post.OriginalSource(); got != "dev.to" {
+		t.Errorf("OriginalSource = %q, want dev.to", got)
+	}
+	get := func(path string) string {
+		rec := serveGet(app, path)
+		return rec.Body.String()
+	}
+
+	if feed := get("/feed.xml"); !strings.Contains(feed, `<source url="https://www.dev.to/jane/cross">dev.to</source>`) {
+		t.Errorf("feed missing source:\n%s", feed)
+	}
+	if strings.Contains(get("/sitemap.xml"), "/blog/cross/") {
+		t.Error("sitemap should leave out cross-posts by default")
+	}
+	app.Config.SitemapCrossPosts = true
+	if !strings.Contains(get("/sitemap.xml"), "/blog/cross/") {
+		t.Error("sitemap should list cross-posts with SitemapCrossPosts")
+	}
+	if plain := get("/blog/cross/plain/"); !strings.Contains(plain, `<link rel="canonical" href="https://www.dev.to/jane/cross">`) {
+		t.Errorf("plain page canonical not the original:\n%s", plain)
+	}
+
+	saved, err := app.Store.GetPost("cross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := HeadMeta(app.Config, &saved).Component().Render(context.Background(), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<link rel="canonical" href="https://www.dev.to/jane/cross">`) {
+		t.Errorf("head canonical not the original:\n%s", b.String())
+	}
+}
+
+func TestSyndicateDevto(t *testing.T) {
+	type request struct {
+		Method, Path, Key string
+		Article           map[string]any
+	}
+	requests := make(chan request, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Article map[string]any }
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- request{r.Method, r.URL.Path, r.Header.Get("api-key"), body.Article}
+		w.Write([]byte(`{"id": 42, "url": "https://dev.to/me/hello-1a2b"}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { devtoAPIURL = u }(devtoAPIURL)
+	devtoAPIURL = srv.URL + "/api/articles"
+
+	app := newTestApp(t)
+	app.Config.URL = "https://example.com"
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := app.Store.SetSetting(settingDevtoAPIKey, "key"); err != nil {
+		t.Fatal(err)
+	}
+	post := BlogPost{Slug: "hello", Title: "Hello", Date: "2024-01-01", Tags: []string{"Go", "web-dev"}, Content: "See [about](/about/)", Published: true}
+	if err := app.Store.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	next := func() request {
+		t.Helper()
+		select {
+		case r := <-requests:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("dev.to not called")
+		}
+		return request{}
+	}
+	waitStatus := func(status string) Syndication {
+		t.Helper()
+		for range 100 {
+			if sy, _ := app.Store.GetSyndication("hello", ServiceDevto); sy.Status == status {
+				return sy
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("syndication never reached %q", status)
+		return Syndication{}
+	}
+
+	if err := app.syndicate(post, []string{ServiceDevto}); err != nil {
+		t.Fatalf("syndicate: %v", err)
+	}
+	r := next()
+	if r.Method != http.MethodPost || r.Path != "/api/articles" || r.Key != "key" {
+		t.Errorf("create request = %s %s, key %q", r.Method, r.Path, r.Key)
+	}
+	if r.Article["canonical_url"] != "https://example.com/blog/hello/" || r.Article["body_markdown"] != "See [about](https://example.com/about/)" {
+		t.Errorf("article = %v", r.Article)
+	}
+	if tags, _ := r.Article["tags"].([]any); len(tags) != 2 || tags[1] != "webdev" {
+		t.Errorf("tags = %v", r.Article["tags"])
+	}
+	sy := waitStatus(SyndicationPosted)
+	if sy.RemoteID != "42" || sy.URL != "https://dev.to/me/hello-1a2b" {
+		t.Errorf("syndication = %+v", sy)
+	}
+
+	// Saving again updates the copy.
+	if err := app.syndicate(post, []string{ServiceDevto}); err != nil {
+		t.Fatalf("syndicate: %v", err)
+	}
+	if r := next(); r.Method != http.MethodPut || r.Path != "/api/articles/42" {
+		t.Errorf("update request = %s %s", r.Method, r.Path)
+	}
+
+	// Opting out keeps the record of the copy but stops updates.
+	if err := app.syndicate(post, nil); err != nil {
+		t.Fatalf("syndicate: %v", err)
+	}
+	if sy := waitStatus(SyndicationStopped); sy.URL == "" {
+		t.Errorf("stopped syndication lost its URL: %+v", sy)
+	}
+	select {
+	case r := <-requests:
+		t.Errorf("opted out post sent to dev.to: %s %s", r.Method, r.Path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,226 @@
+package pubengine
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TagCount is a tag and how many posts outside the trash, drafts included,
+// have it.
+type TagCount struct {
+	Tag   string
+	Posts int
+}
+
+// tagCounts counts the tags of posts, sorted by tag.
+func tagCounts(posts []BlogPost) []TagCount {
+	counts := make(map[string]int)
+	for _, p := range posts {
+		for _, t := range p.Tags {
+			if t = normalizeTag(t); t != "" {
+				counts[t]++
+			}
+		}
+	}
+	result := make([]TagCount, 0, len(counts))
+	for t, n := range counts {
+		result = append(result, TagCount{Tag: t, Posts: n})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+	return result
+}
+
+// mergeTags replaces the tags in from with into in tags, keeping the order
+// and dropping repeats, and reports whether anything changed.
+func mergeTags(tags []string, into string, from []string) ([]string, bool) {
+	var merged []string
+	changed := false
+	for _, t := range tags {
+		t = normalizeTag(t)
+		if slices.Contains(from, t) {
+			t, changed = into, true
+		}
+		if t != "" && !slices.Contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged, changed
+}
+
+// ListTagCounts returns every tag of the posts outside the trash, drafts
+// included, with how many posts have it, sorted by tag.
+func (s *Store) ListTagCounts() ([]TagCount, error) {
+	posts, err := s.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	return tagCounts(posts), nil
+}
+
+// RenameTag renames the tag old to new on every post, see MergeTags.
+func (s *Store) RenameTag(old, new string) (int, error) {
+	return s.MergeTags(new, old)
+}
+
+// MergeTags replaces the tags in from with into on every post, in the
+// trash too, in one transaction, and returns how many posts changed. A
+// post with several of the tags ends up with into once. Tags are compared
+// in lowercase.
+func (s *Store) MergeTags(into string, from ...string) (int, error) {
+	into = normalizeTag(into)
+	var normalized []string
+	for _, t := range from {
+		if t = normalizeTag(t); t != "" && t != into {
+			normalized = append(normalized, t)
+		}
+	}
+	from = normalized
+	if len(from) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// LIKE narrows the posts down; mergeTags decides.
+	conds := make([]string, len(from))
+	args := make([]any, len(from))
+	for i, t := range from {
+		conds[i] = `tags LIKE ?`
+		args[i] = "%," + t + ",%"
+	}
+	rows, err := tx.Query(`SELECT slug, tags FROM posts WHERE `+strings.Join(conds, " OR "), args...)
+	if err != nil {
+		return 0, err
+	}
+	updates := make(map[string]string)
+	for rows.Next() {
+		var slug, tags string
+		if err := rows.Scan(&slug, &tags); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if merged, changed := mergeTags(ParseTags(tags), into, from); changed {
+			updates[slug] = "," + strings.Join(merged, ",") + ","
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for slug, tags := range updates {
+		if _, err := tx.Exec(`UPDATE posts SET tags = ?, updated_at = ?, version = version + 1 WHERE slug = ?`, tags, now, slug); err != nil {
+			return 0, err
+		}
+	}
+	return len(updates), tx.Commit()
+}
+
+// listTagCounts returns the tags of the posts with how many posts have
+// each.
+func (a *App) listTagCounts() ([]TagCount, error) {
+	if t, ok := a.Content.(tagCounter); ok {
+		return t.ListTagCounts()
+	}
+	posts, err := a.Content.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	return tagCounts(posts), nil
+}
+
+// mergePostTags replaces the tags in from with into on every post and
+// returns the posts that changed, as they were before.
+func (a *App) mergePostTags(into string, from []string) ([]BlogPost, error) {
+	into = normalizeTag(into)
+	from = slices.DeleteFunc(slices.Clone(from), func(t string) bool { return t == into })
+	posts, err := a.Content.ListAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	var changed []BlogPost
+	for _, p := range posts {
+		if _, ok := mergeTags(p.Tags, into, from); ok {
+			changed = append(changed, p)
+		}
+	}
+	if m, ok := a.Content.(tagMerger); ok {
+		_, err := m.MergeTags(into, from...)
+		return changed, err
+	}
+	for _, p := range changed {
+		p.Tags, _ = mergeTags(p.Tags, into, from)
+		if err := a.Content.SavePost(p); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+func (a *App) handleTagList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderTagList(c)
+}
+
+// handleTagMerge renames a tag, or merges several, given comma-separated
+// in from, into the tag to.
+func (a *App) handleTagMerge(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	if a.Views.AdminTags == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	var from []string
+	for _, t := range strings.Split(c.FormValue("from"), ",") {
+		if t = normalizeTag(t); t != "" {
+			from = append(from, t)
+		}
+	}
+	to := normalizeTag(c.FormValue("to"))
+	if len(from) == 0 || to == "" {
+		return c.String(http.StatusBadRequest, "Enter the tags to rename and the new tag.")
+	}
+	if strings.Contains(to, ",") {
+		return c.String(http.StatusBadRequest, "Rename to one tag, without commas.")
+	}
+	changed, err := a.mergePostTags(to, from)
+	if len(changed) > 0 {
+		a.Cache.Invalidate()
+		var purged []BlogPost
+		for _, p := range changed {
+			if p.Published {
+				after, _ := a.Content.GetPostAny(p.Slug)
+				purged = append(purged, p, after)
+			}
+		}
+		if len(purged) > 0 {
+			a.purge(purged...)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return a.renderTagList(c)
+}
+
+func (a *App) renderTagList(c echo.Context) error {
+	if a.Views.AdminTags == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	tags, err := a.listTagCounts()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminTags(tags, CsrfToken(c)))
+}
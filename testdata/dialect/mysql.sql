@@ -0,0 +1,31 @@
+-- select
+SELECT slug FROM posts WHERE tags LIKE ? AND summary != 'why?' AND date < ?
+
+-- upsert
+INSERT INTO settings (`key`, value) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE value = VALUES(value)
+
+-- insert or ignore
+INSERT IGNORE INTO series (slug, title) VALUES (?, ?)
+
+-- create table
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    kind MEDIUMTEXT NOT NULL,
+    payload MEDIUMTEXT NOT NULL DEFAULT (''),
+    run_at VARCHAR(255) NOT NULL,
+    dead_at VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+-- add column
+content_image MEDIUMTEXT NOT NULL DEFAULT ('')
+
+-- create index
+CREATE INDEX idx_jobs_due ON jobs(dead_at, run_at)
+
+-- column exists
+SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+
+-- index exists
+SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?
+
@@ -0,0 +1,31 @@
+-- select
+SELECT slug FROM posts WHERE tags LIKE $1 AND summary != 'why?' AND date < $2
+
+-- upsert
+INSERT INTO settings (key, value) VALUES ($1, $2)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value
+
+-- insert or ignore
+INSERT INTO series (slug, title) VALUES ($1, $2) ON CONFLICT(slug) DO NOTHING
+
+-- create table
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL DEFAULT '',
+    run_at TEXT NOT NULL,
+    dead_at TEXT NOT NULL DEFAULT ''
+);
+
+-- add column
+content_image TEXT NOT NULL DEFAULT ''
+
+-- create index
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(dead_at, run_at)
+
+-- column exists
+SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+
+-- index exists
+SELECT COUNT(*) FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1 AND indexname = $2
+
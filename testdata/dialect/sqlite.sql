@@ -0,0 +1,31 @@
+-- select
+SELECT slug FROM posts WHERE tags LIKE ? AND summary != 'why?' AND date < ?
+
+-- upsert
+INSERT INTO settings (key, value) VALUES (?, ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value
+
+-- insert or ignore
+INSERT INTO series (slug, title) VALUES (?, ?) ON CONFLICT(slug) DO NOTHING
+
+-- create table
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL DEFAULT '',
+    run_at TEXT NOT NULL,
+    dead_at TEXT NOT NULL DEFAULT ''
+);
+
+-- add column
+content_image TEXT NOT NULL DEFAULT ''
+
+-- create index
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(dead_at, run_at)
+
+-- column exists
+SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?
+
+-- index exists
+SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?
+
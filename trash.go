@@ -0,0 +1,88 @@
+package pubengine
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (a *App) handleTrashList(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	return a.renderTrash(c)
+}
+
+// handleTrashRestore takes a post out of the trash, back to the state it
+// was trashed in.
+func (a *App) handleTrashRestore(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	slug := c.Param("slug")
+	if err := a.Store.RestorePost(slug); err != nil {
+		if err == sql.ErrNoRows {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return err
+	}
+	a.Cache.Invalidate()
+	if post, err := a.Store.GetPostAny(slug); err == nil && post.Published {
+		a.purge(post)
+	}
+	return a.renderTrash(c)
+}
+
+// handleTrashDelete deletes a post in the trash for good.
+func (a *App) handleTrashDelete(c echo.Context) error {
+	if !IsAdmin(c) {
+		return c.Redirect(http.StatusSeeOther, "/admin/")
+	}
+	post, err := a.Store.GetPostAny(c.Param("slug"))
+	if err == sql.ErrNoRows || err == nil && post.TrashedAt == "" {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if err := a.Store.DeletePost(post.Slug); err != nil {
+		return err
+	}
+	return a.renderTrash(c)
+}
+
+func (a *App) renderTrash(c echo.Context) error {
+	if a.Views.AdminTrash == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	posts, err := a.Store.ListTrashedPosts()
+	if err != nil {
+		return err
+	}
+	return Render(c, a.Views.AdminTrash(posts, CsrfToken(c)))
+}
+
+// startTrashEmptier empties posts trashed more than retentionDays ago from
+// the trash every interval, logging failures. Returns a stop function.
+func (a *App) startTrashEmptier(retentionDays int, interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := a.Store.EmptyTrash(time.Now().AddDate(0, 0, -retentionDays)); err != nil {
+					a.Echo.Logger.Errorf("empty trash: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
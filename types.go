@@ -10,6 +10,38 @@ type BlogPost struct {
 	Slug      string
 	Content   string
 	Published bool
+	Featured  bool   // shown before newer posts on the home page; see Store.SetPostFeatured
+	OGImage   string // social preview image, a site path like "/public/uploads/x.jpg" or an absolute URL
+	ShortCode string // short link code, served at /s/<code>; set by the store
+	Variant   string // experiment variant template, empty outside experiments
+	TrashedAt string // RFC3339 time the post was moved to the trash, empty outside the trash; set by the store
+	CreatedAt string // RFC3339 time the post was first saved; set by the store
+	UpdatedAt string // RFC3339 time the post was last saved; set by the store
+	Version   int    // counts changes to the post, for Store.SavePostIfVersion; set by the store
+	PublishAt string // RFC3339 time a draft is published at, empty unless it is scheduled; see BlogPost.Scheduled
+
+	ReadingMinutes int    // estimated reading time of Content, see ReadingMinutes; set by the store
+	contentImage   string // the first image in Content, kept by the store for posts listed without it
+
+	SeriesSlug  string     // series the post is part of, empty when none
+	SeriesOrder int        // position in the series; posts with the same order are sorted by date
+	Series      *SeriesNav // the post's place in its series; set by the post handlers, nil outside a series
+
+	AuthorSlug string  // author the post is by, empty for SiteConfig.Author
+	AuthorName string  // the author's name, empty without an author; set by the store
+	Author     *Author // the post's author; set by the post handlers, nil without an author
+
+	ExcludeFromFeed    bool // leave the post out of the RSS feeds
+	ExcludeFromSitemap bool // leave the post out of sitemap.xml
+	NoIndex            bool // ask search engines not to index the post; also leaves it out of the sitemap and llms.txt
+
+	CanonicalURL string // absolute URL where a cross-posted post was first published; empty for original posts
+
+	Backlinks []BlogPost // posts linking to this one, newest first; set by the post handlers (published posts) and the admin post form (drafts too)
+
+	Syndications []Syndication // cross-posts to dev.to or Hashnode; set by the admin dashboard and post form
+
+	Meta map[string]string // custom fields for themes, e.g. "hero_image" or "layout"; nil when the post has none
 }
 
 // Image represents an uploaded image stored in the uploads directory.
@@ -22,10 +54,97 @@ type Image struct {
 	UploadedAt   string // RFC3339
 }
 
+// Attachment is a non-image file in the media library, such as a PDF, an
+// audio or video file or a zip archive, stored in the files directory.
+type Attachment struct {
+	Filename     string // e.g. "slides.pdf"
+	OriginalName string
+	ContentType  string // detected from the content, e.g. "application/pdf"
+	Size         int64  // bytes
+	UploadedAt   string // RFC3339
+	Width        int    // videos only, 0 when unknown
+	Height       int    // videos only, 0 when unknown
+	Duration     int    // seconds, videos only, 0 when unknown
+	Poster       string // poster frame file name, videos only, "" when none
+}
+
+// DraftComment is editorial feedback on a range of a post's content, shown
+// next to the post in the admin editor.
+type DraftComment struct {
+	ID        int64
+	Slug      string
+	Start     int    // selection start in the editor content
+	End       int    // selection end; equal to Start for a comment on a position
+	Quote     string // the selected text, used to find the range again after edits
+	Body      string
+	Resolved  bool
+	CreatedAt string // RFC3339
+	Revision  int64  // ID of the PostRevision Start and End are offsets in, 0 if none was kept
+	Outdated  bool   // the post was saved since, so Start and End may be off (set by the store)
+}
+
+// Comment is a reader's comment on a post. New comments are shown once the
+// admin approves them.
+type Comment struct {
+	ID        int64
+	Slug      string
+	Name      string
+	Body      string
+	Approved  bool
+	CreatedAt string // RFC3339
+}
+
+// Subscriber is a newsletter subscriber.
+type Subscriber struct {
+	Email        string
+	Token        string // secret in the subscriber's unsubscribe link
+	SubscribedAt string // RFC3339
+}
+
+// PostRevision is the content of a post as a save left it, kept so the
+// admin can look back at earlier versions and restore one.
+type PostRevision struct {
+	ID        int64
+	Slug      string
+	Version   int // the post's Version after the save
+	Title     string
+	Date      string
+	Tags      []string
+	Summary   string
+	Content   string
+	CreatedAt string // RFC3339
+}
+
+// Job is background work in the persistent queue, see App.Enqueue. Jobs
+// that fail are retried with backoff until MaxAttempts, then kept as dead
+// jobs for the admin to retry or delete.
+type Job struct {
+	ID          int64
+	Kind        string // selects the handler registered with WithJobHandler
+	Payload     string // JSON
+	Attempts    int
+	MaxAttempts int
+	RunAt       string // RFC3339 time the job is next due
+	LastError   string
+	CreatedAt   string // RFC3339
+	DeadAt      string // RFC3339 time the job ran out of attempts, empty while it's queued
+}
+
+// Snippet is reusable post content managed in the admin. Templates start
+// new posts and may begin with frontmatter; other snippets are copied into
+// the editor.
+type Snippet struct {
+	Name      string
+	Template  bool
+	Content   string // Markdown, with "{{date}}" replaced by today's date in templates
+	UpdatedAt string // RFC3339
+}
+
 // PageMeta carries per-page OpenGraph and SEO metadata into the <head> template.
 type PageMeta struct {
 	Title       string
 	Description string
 	URL         string // canonical + og:url
 	OGType      string // "website" or "article"
+	Image       string // absolute og:image URL, optional
 }
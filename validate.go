@@ -0,0 +1,89 @@
+package pubengine
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eringen/pubengine/markdown"
+)
+
+// uploadsURLPrefix is the public URL prefix of uploaded images.
+const uploadsURLPrefix = "/public/" + uploadsSubdir + "/"
+
+// maxContentWarnings caps the warnings reported for one post.
+const maxContentWarnings = 10
+
+// validImageRef reports whether ref can be used as a post's OG image: a site
+// path starting with a single "/" or an absolute http(s) URL.
+func validImageRef(ref string) bool {
+	if strings.HasPrefix(ref, "/") {
+		return !strings.HasPrefix(ref, "//")
+	}
+	u, err := url.Parse(ref)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validCanonicalURL reports whether ref can be a post's CanonicalURL: an
+// absolute http(s) URL.
+func validCanonicalURL(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ogImageWarning reports an OG image pointing to an upload that doesn't exist.
+func (a *App) ogImageWarning(ref string) string {
+	if !strings.HasPrefix(ref, uploadsURLPrefix) {
+		return ""
+	}
+	name := filepath.Base(strings.TrimPrefix(ref, uploadsURLPrefix))
+	if _, err := os.Stat(filepath.Join(a.staticDir, uploadsSubdir, name)); err != nil {
+		return fmt.Sprintf("OG image %q not found in uploads", ref)
+	}
+	return ""
+}
+
+// contentWarnings checks post Markdown for problems readers would otherwise
+// find first: malformed link or image syntax, URLs the renderer drops because
+// of their scheme, images missing the {style} suffix, and images pointing to
+// uploads that don't exist. With LintAccessibility set it also runs the
+// accessibility checks. Warnings never block saving.
+func (a *App) contentWarnings(content string) []string {
+	refs, problems := markdown.Refs(content)
+
+	var warnings []string
+	for _, p := range problems {
+		warnings = append(warnings, fmt.Sprintf("line %d: %s", p.Line, p.Message))
+	}
+	for _, r := range refs {
+		kind := "link"
+		if r.Image {
+			kind = "image"
+		}
+		switch {
+		case strings.TrimSpace(r.URL) == "":
+			warnings = append(warnings, fmt.Sprintf("line %d: %s %q has no URL", r.Line, kind, r.Text))
+		case markdown.SafeURL(r.URL) == "":
+			warnings = append(warnings, fmt.Sprintf("line %d: %s URL %q is not allowed (use http, https, mailto, tel or a path starting with /)", r.Line, kind, r.URL))
+		case r.Image && !r.Style:
+			warnings = append(warnings, fmt.Sprintf("line %d: image %q needs a {style} suffix, e.g. ![alt](url){}", r.Line, r.URL))
+		case r.Image && strings.HasPrefix(r.URL, uploadsURLPrefix):
+			name := filepath.Base(strings.TrimPrefix(r.URL, uploadsURLPrefix))
+			if _, err := os.Stat(filepath.Join(a.staticDir, uploadsSubdir, name)); err != nil {
+				warnings = append(warnings, fmt.Sprintf("line %d: image %q not found in uploads", r.Line, r.URL))
+			}
+		}
+	}
+
+	if a.Config.LintAccessibility {
+		warnings = append(warnings, accessibilityWarnings(content)...)
+	}
+
+	if len(warnings) > maxContentWarnings {
+		more := len(warnings) - maxContentWarnings
+		warnings = append(warnings[:maxContentWarnings], fmt.Sprintf("and %d more", more))
+	}
+	return warnings
+}
@@ -0,0 +1,134 @@
+package pubengine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// videoToolTimeout bounds each ffprobe and ffmpeg run on an upload.
+const videoToolTimeout = 30 * time.Second
+
+// videoProbe is the part of ffprobe's JSON output read for a video.
+type videoProbe struct {
+	Streams []struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probeVideo reads the dimensions and duration of a video with ffprobe.
+// It returns an error when ffprobe isn't installed or can't read the file.
+func probeVideo(path string) (width, height, seconds int, err error) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), videoToolTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height:format=duration",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	var probe videoProbe
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, 0, 0, fmt.Errorf("ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return 0, 0, 0, errors.New("ffprobe: no video stream")
+	}
+	d, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	return probe.Streams[0].Width, probe.Streams[0].Height, int(math.Round(d)), nil
+}
+
+// generatePoster writes a JPEG frame of the video at path to dst, taken a
+// second in (or at the start of shorter videos) and at most maxImageWidth
+// wide. It returns an error when ffmpeg isn't installed or fails.
+func generatePoster(path, dst string, seconds int) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return err
+	}
+	at := "1"
+	if seconds < 2 {
+		at = "0"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), videoToolTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, ffmpeg,
+		"-v", "error", "-y",
+		"-ss", at,
+		"-i", path,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", maxImageWidth),
+		"-q:v", "3",
+		dst,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// posterFilename returns the file name of the poster frame of a video.
+// Attachments never end in .jpg, so it can't clash with one.
+func posterFilename(video string) string {
+	return video + ".poster.jpg"
+}
+
+// processVideo fills in the dimensions, duration and poster of an uploaded
+// video. Without ffprobe and ffmpeg on the PATH the video is kept as is:
+// the {{video}} shortcode then has no poster to show.
+func (a *App) processVideo(c echo.Context, att *Attachment) {
+	path := filepath.Join(a.attachmentsDir(), att.Filename)
+	w, h, seconds, err := probeVideo(path)
+	if err != nil {
+		c.Logger().Warnf("probe video %s: %v", att.Filename, err)
+		return
+	}
+	att.Width, att.Height, att.Duration = w, h, seconds
+	poster := posterFilename(att.Filename)
+	if err := generatePoster(path, filepath.Join(a.attachmentsDir(), poster), seconds); err != nil {
+		c.Logger().Warnf("video poster %s: %v", att.Filename, err)
+		return
+	}
+	att.Poster = poster
+}
+
+// handleAttachmentPoster serves the poster frame of a video attachment,
+// the poster the {{video}} shortcode links to.
+func (a *App) handleAttachmentPoster(c echo.Context) error {
+	att, err := a.Store.GetAttachment(c.Param("filename"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && att.Poster == "") {
+		return echo.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.attachmentsDir(), att.Poster)
+	if _, err := os.Stat(path); err != nil {
+		return echo.ErrNotFound
+	}
+	c.Response().Header().Set(echo.HeaderContentType, mimeJPEG)
+	return c.File(path)
+}
@@ -0,0 +1,142 @@
+package pubengine
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// WithWellKnown serves files under /.well-known/, keyed by name relative to
+// it (e.g. "security.txt" or "nostr.json"). Names not given here are served
+// from the .well-known directory in the static dir when it has them.
+func WithWellKnown(files map[string]string) Option {
+	return func(a *App) {
+		if a.wellKnown == nil {
+			a.wellKnown = make(map[string]string)
+		}
+		for name, content := range files {
+			a.wellKnown[strings.TrimPrefix(name, "/")] = content
+		}
+	}
+}
+
+func (a *App) handleWellKnown(c echo.Context) error {
+	name := c.Param("*")
+	if name == "" || path.Clean("/"+name) != "/"+name {
+		return echo.ErrNotFound
+	}
+	if content, ok := a.wellKnown[name]; ok {
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = echo.MIMETextPlainCharsetUTF8
+		}
+		return c.Blob(http.StatusOK, ctype, []byte(content))
+	}
+	file := filepath.Join(a.staticDir, ".well-known", filepath.FromSlash(name))
+	if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+		return echo.ErrNotFound
+	}
+	return c.File(file)
+}
+
+// handleHumans serves humans.txt from the static dir, or generates one from
+// the site config.
+func (a *App) handleHumans(c echo.Context) error {
+	file := filepath.Join(a.staticDir, "humans.txt")
+	if _, err := os.Stat(file); err == nil {
+		return c.File(file)
+	}
+	var b strings.Builder
+	b.WriteString("/* TEAM */\n")
+	if a.Config.Author != "" {
+		fmt.Fprintf(&b, "Author: %s\n", a.Config.Author)
+	}
+	fmt.Fprintf(&b, "Site: %s\n", BuildURL(a.Config.URL))
+	b.WriteString("\n/* SITE */\n")
+	fmt.Fprintf(&b, "Name: %s\n", a.Config.Name)
+	if posts, err := a.Cache.ListPosts(""); err == nil && len(posts) > 0 {
+		fmt.Fprintf(&b, "Last update: %s\n", posts[0].Date)
+	}
+	b.WriteString("Software: pubengine, Go, SQLite\n")
+	return c.String(http.StatusOK, b.String())
+}
+
+// webManifest is a PWA web app manifest.
+type webManifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	Description     string         `json:"description,omitempty"`
+	StartURL        string         `json:"start_url"`
+	Scope           string         `json:"scope"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color,omitempty"`
+	BackgroundColor string         `json:"background_color"`
+	Icons           []manifestIcon `json:"icons"`
+}
+
+type manifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// handleManifest serves manifest.json generated from the site config, with
+// the configured AppIcons looked up in the media library for their sizes.
+func (a *App) handleManifest(c echo.Context) error {
+	m := webManifest{
+		Name:            a.Config.Name,
+		ShortName:       a.Config.Name,
+		Description:     a.Config.Description,
+		StartURL:        "/",
+		Scope:           "/",
+		Display:         "standalone",
+		ThemeColor:      a.Config.ThemeColor,
+		BackgroundColor: a.Config.BackgroundColor,
+	}
+	icons, err := a.manifestIcons()
+	if err != nil {
+		return err
+	}
+	m.Icons = icons
+	c.Response().Header().Set(echo.HeaderContentType, "application/manifest+json")
+	return c.JSONPretty(http.StatusOK, m, "  ")
+}
+
+// manifestIcons returns the AppIcons found in the media library, or the
+// favicon when there are none.
+func (a *App) manifestIcons() ([]manifestIcon, error) {
+	favicon := []manifestIcon{{Src: "/favicon.svg", Sizes: "any", Type: "image/svg+xml"}}
+	if len(a.Config.AppIcons) == 0 {
+		return favicon, nil
+	}
+	images, err := a.Store.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Image, len(images))
+	for _, img := range images {
+		byName[img.Filename] = img
+	}
+	var icons []manifestIcon
+	for _, name := range a.Config.AppIcons {
+		img, ok := byName[name]
+		if !ok {
+			continue
+		}
+		icons = append(icons, manifestIcon{
+			Src:   a.Config.mediaURL(uploadsURLPrefix + img.Filename),
+			Sizes: fmt.Sprintf("%dx%d", img.Width, img.Height),
+			Type:  mime.TypeByExtension(path.Ext(img.Filename)),
+		})
+	}
+	if len(icons) == 0 {
+		return favicon, nil
+	}
+	return icons, nil
+}
@@ -0,0 +1,43 @@
+package pubengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSiteFiles(t *testing.T) {
+	app := newTestApp(t)
+	app.Config.Author = "Jo"
+	app.Config.AppIcons = []string{"icon.jpg", "missing.jpg"}
+	WithWellKnown(map[string]string{"security.txt": "Contact: mailto:jo@example.com\n"})(app)
+	if err := app.Setup(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if err := app.Store.SaveImage(Image{Filename: "icon.jpg", Width: 512, Height: 512, UploadedAt: "2024-01-01T00:00:00Z"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serveGet(app, "/.well-known/security.txt"); rec.Code != http.StatusOK || rec.Body.String() != "Contact: mailto:jo@example.com\n" {
+		t.Errorf("security.txt = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serveGet(app, "/.well-known/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing well-known file = %d, want 404", rec.Code)
+	}
+	if rec := serveGet(app, "/humans.txt"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Author: Jo") {
+		t.Errorf("humans.txt = %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec := serveGet(app, "/manifest.json")
+	var m webManifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if rec.Header().Get("Content-Type") != "application/manifest+json" || m.BackgroundColor != "#ffffff" || m.Display != "standalone" {
+		t.Errorf("unexpected manifest: %s %+v", rec.Header().Get("Content-Type"), m)
+	}
+	if len(m.Icons) != 1 || m.Icons[0] != (manifestIcon{Src: "/public/uploads/icon.jpg", Sizes: "512x512", Type: "image/jpeg"}) {
+		t.Errorf("icons = %+v", m.Icons)
+	}
+}